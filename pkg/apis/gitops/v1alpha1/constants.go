@@ -4,6 +4,9 @@ const (
 	// APIVersion the api version
 	APIVersion = "gitops.jenkins-x.io/v1alpha1"
 
+	// KindKptLock the kind
+	KindKptLock = "KptLock"
+
 	// KindSecretMapping the kind
 	KindSecretMapping = "SecretMapping"
 
@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"gopkg.in/validator.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KptCatalogFileName default name of the kpt catalog file
+	KptCatalogFileName = "kpt-catalog.yaml"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KptCatalog a catalog of the canonical kpt packages and their pinned versions
+//
+// +k8s:openapi-gen=true
+type KptCatalog struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the packages in the catalog
+	Spec KptCatalogSpec `json:"spec"`
+}
+
+// KptCatalogSpec defines the packages in the catalog
+type KptCatalogSpec struct {
+	// Packages the canonical packages
+	Packages []KptCatalogPackage `json:"packages" validate:"nonzero"`
+}
+
+// KptCatalogPackage the canonical location and version of a kpt package
+type KptCatalogPackage struct {
+	// Name the logical name of the package which matches the metadata.name of the Kptfile
+	Name string `json:"name" validate:"nonzero"`
+	// Repo the git repository URL of the package
+	Repo string `json:"repo" validate:"nonzero"`
+	// Directory the directory within the git repository of the package
+	Directory string `json:"directory,omitempty"`
+	// Version the git reference or commit the package is pinned to
+	Version string `json:"version" validate:"nonzero"`
+}
+
+// Validate validates the catalog
+func (c *KptCatalog) Validate() error {
+	return validator.Validate(c)
+}
+
+// FindPackage finds the package with the given name or returns nil
+func (c *KptCatalog) FindPackage(name string) *KptCatalogPackage {
+	for i := range c.Spec.Packages {
+		p := &c.Spec.Packages[i]
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
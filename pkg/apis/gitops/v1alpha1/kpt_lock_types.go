@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"gopkg.in/validator.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KptLockFileName default name of the kpt lock file
+	KptLockFileName = "kpt-lock.yaml"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KptLock a lock file of the pinned upstream commits of the kpt packages in a directory tree
+//
+// +k8s:openapi-gen=true
+type KptLock struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Spec holds the locked packages
+	Spec KptLockSpec `json:"spec"`
+}
+
+// KptLockSpec defines the locked packages
+type KptLockSpec struct {
+	// Packages the locked packages
+	Packages []KptLockPackage `json:"packages" validate:"nonzero"`
+}
+
+// KptLockPackage the pinned upstream of a kpt package
+type KptLockPackage struct {
+	// Path the relative path of the package directory using '/' as the separator
+	Path string `json:"path" validate:"nonzero"`
+	// Name the metadata.name of the Kptfile
+	Name string `json:"name,omitempty"`
+	// Repo the git repository URL of the package
+	Repo string `json:"repo" validate:"nonzero"`
+	// Directory the directory within the git repository of the package
+	Directory string `json:"directory,omitempty"`
+	// Ref the git reference such as a branch or tag the commit was resolved from
+	Ref string `json:"ref,omitempty"`
+	// Commit the pinned git commit
+	Commit string `json:"commit" validate:"nonzero"`
+}
+
+// Validate validates the lock file
+func (l *KptLock) Validate() error {
+	return validator.Validate(l)
+}
+
+// FindPackage finds the package with the given path or returns nil
+func (l *KptLock) FindPackage(path string) *KptLockPackage {
+	for i := range l.Spec.Packages {
+		p := &l.Spec.Packages[i]
+		if p.Path == path {
+			return p
+		}
+	}
+	return nil
+}
@@ -2,13 +2,17 @@ package annotate
 
 import (
 	"fmt"
-	"sort"
-	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/watch"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -17,21 +21,53 @@ import (
 var (
 	annotateLong = templates.LongDesc(`
 		Annotates all kubernetes resources in the given directory tree
+
+		Every document of the *.yaml and *.yml files is updated including the items of List resources. Documents which are not kubernetes resources are left untouched. Existing annotations with a different value are only replaced if --overwrite is specified. An annotation can be removed by using the key- argument or the --invert flag.
+
+		If --source-info is specified the git URL, branch and commit SHA of the source repository are found via git in the directory and added as the annotations 'gitops.jenkins-x.io/source-url', 'gitops.jenkins-x.io/source-branch' and 'gitops.jenkins-x.io/source-sha' along with 'gitops.jenkins-x.io/build-number' and 'gitops.jenkins-x.io/pipeline' from $BUILD_NUMBER and $JOB_NAME if they are defined. Any of the values can be specified via flags such as if the directory is not inside a git repository. Existing source annotations are always replaced as they refer to a previous build.
 `)
 
 	annotateExample = templates.Examples(`
-		# updates recursively annotates all resources in the current directory 
+		# updates recursively annotates all resources in the current directory
 		%s annotate myannotate=cheese another=thing
-		# updates recursively all resources 
+		# updates recursively all resources
 		%s annotate --dir myresource-dir foo=bar
+		# replaces the value of an existing annotation on the deployments and removes another annotation
+		%s annotate --kind Deployment --overwrite fluxcd.io/automated=true owner-
+		# lists the resources which would be annotated without modifying them
+		%s annotate --dry-run fluxcd.io/automated=true
+		# annotates all resources with the git repository, commit and pipeline which produced them
+		%s annotate --dir config-root --source-info
 	`)
 )
 
 // AnnotateOptions the options for the command
 type Options struct {
 	kyamls.Filter
-	Dir      string
-	Annotate string
+	common.WalkOptions
+	Watch         watch.Options
+	Dir           string
+	Annotations   []string
+	Overwrite     bool
+	Invert        bool
+	DryRun        bool
+	SourceInfo    bool
+	Source        SourceInfo
+	CommandRunner cmdrunner.CommandRunner
+	Changes       []Change
+	kept          int
+}
+
+// Change a resource whose annotations are modified
+type Change struct {
+	// Path the file containing the resource
+	Path string
+
+	// Kind the kind of the resource
+	Kind string
+
+	// Name the name of the resource
+	Name string
 }
 
 // NewCmdUpdate creates a command object for the command
@@ -42,37 +78,106 @@ func NewCmdUpdateAnnotate() (*cobra.Command, *Options) {
 		Use:     "annotate",
 		Short:   "Annotates all kubernetes resources in the given directory tree",
 		Long:    annotateLong,
-		Example: fmt.Sprintf(annotateExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Example: fmt.Sprintf(annotateExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
-			err := UpdateAnnotateInYamlFiles(o.Dir, args, o.Filter)
+			o.Annotations = args
+			err := o.Watch.Run([]string{o.Dir}, o.Run)
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "replaces the value of existing annotations with the same key. By default existing annotations are kept")
+	cmd.Flags().BoolVarP(&o.Invert, "invert", "", false, "removes the annotations with the given keys rather than adding them")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "lists the resources which would be modified without modifying any files")
+	cmd.Flags().BoolVarP(&o.SourceInfo, "source-info", "", false, "adds the annotations of the git repository, commit and pipeline which produced the resources")
+	cmd.Flags().StringVarP(&o.Source.URL, "source-url", "", "", "the git URL of the source repository for --source-info. Defaults to the URL of the origin remote")
+	cmd.Flags().StringVarP(&o.Source.Branch, "source-branch", "", "", "the git branch of the source repository for --source-info. Defaults to the current branch")
+	cmd.Flags().StringVarP(&o.Source.SHA, "source-sha", "", "", "the git commit SHA of the source repository for --source-info. Defaults to the current commit")
+	cmd.Flags().StringVarP(&o.Source.BuildNumber, "build-number", "", "", "the build number of the pipeline for --source-info. Defaults to $BUILD_NUMBER")
+	cmd.Flags().StringVarP(&o.Source.Pipeline, "pipeline", "", "", "the name of the pipeline for --source-info. Defaults to $JOB_NAME")
 	o.Filter.AddFlags(cmd)
+	o.WalkOptions.AddFlags(cmd)
+	o.Watch.AddFlags(cmd)
 	return cmd, o
 }
 
-// UpdateAnnotateInYamlFiles updates the annotations in yaml files
+// UpdateAnnotateInYamlFiles updates the annotations in yaml files replacing the values of any existing annotations
 func UpdateAnnotateInYamlFiles(dir string, annotations []string, filter kyamls.Filter) error {
-	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		sort.Strings(annotations)
-
-		for _, a := range annotations {
-			paths := strings.SplitN(a, "=", 2)
-			k := paths[0]
-			v := ""
-			if len(paths) > 1 {
-				v = paths[1]
-			}
+	o := &Options{
+		Filter:      filter,
+		Dir:         dir,
+		Annotations: annotations,
+		Overwrite:   true,
+	}
+	return o.Run()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	changes := &resourcehelpers.KeyValueChanges{}
+	var err error
+	if !o.SourceInfo || len(o.Annotations) > 0 {
+		changes, err = resourcehelpers.ParseKeyValueChanges(o.Annotations, o.Invert)
+		if err != nil {
+			return errors.Wrapf(err, "invalid annotations")
+		}
+	}
+	changes.Overwrite = o.Overwrite
 
-			err := node.PipeE(yaml.SetAnnotation(k, v))
-			if err != nil {
-				return false, errors.Wrapf(err, "failed to set annotation %s=%s", k, v)
+	var sourceChanges *resourcehelpers.KeyValueChanges
+	if o.SourceInfo {
+		source, err := o.resolveSourceInfo()
+		if err != nil {
+			return err
+		}
+		sourceChanges = &resourcehelpers.KeyValueChanges{Set: source.Annotations(), Overwrite: true}
+		for _, k := range SourceAnnotations {
+			if _, ok := sourceChanges.Set[k]; !ok {
+				sourceChanges.Remove = append(sourceChanges.Remove, k)
 			}
 		}
+	}
+
+	o.Changes = nil
+	o.kept = 0
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		existing, err := resourcehelpers.NodeStringMap(node, "metadata", "annotations")
+		if err != nil {
+			return false, err
+		}
+		annotations, modified, kept := changes.Apply(existing)
+		o.kept += kept
+		if sourceChanges != nil {
+			var sourceModified bool
+			annotations, sourceModified, _ = sourceChanges.Apply(annotations)
+			modified = modified || sourceModified
+		}
+		if !modified {
+			return false, nil
+		}
+		o.Changes = append(o.Changes, Change{Path: path, Kind: kyamls.GetKind(node, path), Name: kyamls.GetName(node, path)})
+		if o.DryRun {
+			return false, nil
+		}
+		err = resourcehelpers.SetNodeStringMap(node, annotations, "metadata", "annotations")
+		if err != nil {
+			return false, err
+		}
 		return true, nil
 	}
 
-	return kyamls.ModifyFiles(dir, modifyFn, filter)
+	err = resourcehelpers.ModifyFilteredNodes(o.Dir, o.WalkOptions, o.Filter, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate resources in dir %s", o.Dir)
+	}
+	if o.DryRun {
+		for _, c := range o.Changes {
+			log.Logger().Infof("would annotate %s %s in file %s", c.Kind, termcolor.ColorInfo(c.Name), termcolor.ColorInfo(c.Path))
+		}
+		log.Logger().Infof("would annotate %d resources", len(o.Changes))
+	}
+	if o.kept > 0 {
+		log.Logger().Infof("kept %d existing annotations with different values. Use --overwrite to replace them", o.kept)
+	}
+	return nil
 }
@@ -8,10 +8,12 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/annotate"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestUpdateAnnotatesInYamlFiles(t *testing.T) {
@@ -73,3 +75,80 @@ func TestUpdateAnnotatesInYamlFiles(t *testing.T) {
 		}
 	}
 }
+
+func TestAnnotateOverwriteRemoveAndDryRun(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+	path := filepath.Join(tmpDir, "resources.yaml")
+	source := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    fluxcd.io/automated: "false"
+    fluxcd.io/automated-tag: semver
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	err = ioutil.WriteFile(path, []byte(source), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", path)
+	description := "owned by the payments team\nsee the runbook for details"
+
+	// a dry run should list the changes without modifying the file
+	_, o := annotate.NewCmdUpdateAnnotate()
+	o.Dir = tmpDir
+	o.Annotations = []string{"fluxcd.io/automated=true", "description=" + description}
+	o.DryRun = true
+	err = o.Run()
+	require.NoError(t, err, "failed to dry run")
+	assert.Equal(t, []annotate.Change{
+		{Path: path, Kind: "Deployment", Name: "web"},
+		{Path: path, Kind: "Service", Name: "web"},
+	}, o.Changes, "changes of the dry run")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, source, string(data), "a dry run should not modify the file")
+
+	// only the deployments are overwritten
+	o.DryRun = false
+	o.Overwrite = true
+	o.Kinds = []string{"Deployment"}
+	err = o.Run()
+	require.NoError(t, err, "failed to annotate")
+	assertAnnotations(t, path, []map[string]string{
+		{"fluxcd.io/automated": "true", "fluxcd.io/automated-tag": "semver", "description": description},
+		nil,
+	})
+
+	// existing annotations are kept without --overwrite and removal only removes the exact key
+	_, o = annotate.NewCmdUpdateAnnotate()
+	o.Dir = tmpDir
+	o.Annotations = []string{"fluxcd.io/automated=false", "fluxcd.io/automated-tag-", "owner=payments"}
+	err = o.Run()
+	require.NoError(t, err, "failed to annotate")
+	assertAnnotations(t, path, []map[string]string{
+		{"fluxcd.io/automated": "true", "description": description, "owner": "payments"},
+		{"fluxcd.io/automated": "false", "owner": "payments"},
+	})
+
+	_, o = annotate.NewCmdUpdateAnnotate()
+	o.Dir = tmpDir
+	o.Annotations = []string{"fluxcd.io/automated", "description", "owner"}
+	o.Invert = true
+	err = o.Run()
+	require.NoError(t, err, "failed to remove annotations")
+	assertAnnotations(t, path, []map[string]string{nil, nil})
+}
+
+func assertAnnotations(t *testing.T, path string, expected []map[string]string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, len(expected), "documents in %s", path)
+	for i, u := range resources {
+		actual, _, _ := unstructured.NestedStringMap(u.Object, "metadata", "annotations")
+		assert.Equal(t, expected[i], actual, "annotations of document %d in %s", i+1, path)
+	}
+}
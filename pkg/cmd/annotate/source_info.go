@@ -0,0 +1,133 @@
+package annotate
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SourceAnnotationPrefix the prefix of the annotations describing the source which produced a resource
+	SourceAnnotationPrefix = "gitops.jenkins-x.io/"
+
+	// AnnotationSourceURL the annotation of the git URL of the source repository
+	AnnotationSourceURL = SourceAnnotationPrefix + "source-url"
+
+	// AnnotationSourceBranch the annotation of the git branch of the source repository
+	AnnotationSourceBranch = SourceAnnotationPrefix + "source-branch"
+
+	// AnnotationSourceSHA the annotation of the git commit SHA of the source repository
+	AnnotationSourceSHA = SourceAnnotationPrefix + "source-sha"
+
+	// AnnotationBuildNumber the annotation of the build number of the pipeline
+	AnnotationBuildNumber = SourceAnnotationPrefix + "build-number"
+
+	// AnnotationPipeline the annotation of the name of the pipeline
+	AnnotationPipeline = SourceAnnotationPrefix + "pipeline"
+)
+
+var (
+	// SourceAnnotations the annotations describing the source which are replaced on each build
+	SourceAnnotations = []string{AnnotationSourceURL, AnnotationSourceBranch, AnnotationSourceSHA, AnnotationBuildNumber, AnnotationPipeline}
+)
+
+// SourceInfo the provenance of the resources
+type SourceInfo struct {
+	// URL the git URL of the source repository without any credentials
+	URL string
+
+	// Branch the git branch
+	Branch string
+
+	// SHA the git commit SHA
+	SHA string
+
+	// BuildNumber the build number of the pipeline which defaults to $BUILD_NUMBER
+	BuildNumber string
+
+	// Pipeline the name of the pipeline which defaults to $JOB_NAME
+	Pipeline string
+}
+
+// Annotations returns the non blank source annotations
+func (s *SourceInfo) Annotations() map[string]string {
+	answer := map[string]string{}
+	for k, v := range map[string]string{
+		AnnotationSourceURL:    s.URL,
+		AnnotationSourceBranch: s.Branch,
+		AnnotationSourceSHA:    s.SHA,
+		AnnotationBuildNumber:  s.BuildNumber,
+		AnnotationPipeline:     s.Pipeline,
+	} {
+		if v != "" {
+			answer[k] = v
+		}
+	}
+	return answer
+}
+
+// resolveSourceInfo determines any source information which is not specified via the flags using git and the
+// pipeline environment variables
+func (o *Options) resolveSourceInfo() (*SourceInfo, error) {
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	s := o.Source
+	var err error
+	if s.URL == "" {
+		s.URL, err = o.git("failed to find the git remote URL so please specify --source-url", "config", "--get", "remote.origin.url")
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.URL = removeCredentials(s.URL)
+	if s.Branch == "" {
+		s.Branch, err = o.git("failed to find the git branch so please specify --source-branch", "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		// lets use the branch of the pipeline if the commit is checked out without a branch
+		if s.Branch == "HEAD" {
+			s.Branch = os.Getenv("BRANCH_NAME")
+		}
+	}
+	if s.SHA == "" {
+		s.SHA, err = o.git("failed to find the git commit SHA so please specify --source-sha", "rev-parse", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.BuildNumber == "" {
+		s.BuildNumber = os.Getenv("BUILD_NUMBER")
+	}
+	if s.Pipeline == "" {
+		s.Pipeline = os.Getenv("JOB_NAME")
+	}
+	return &s, nil
+}
+
+func (o *Options) git(message string, args ...string) (string, error) {
+	c := &cmdrunner.Command{
+		Dir:  o.Dir,
+		Name: "git",
+		Args: args,
+	}
+	text, err := o.CommandRunner(c)
+	if err != nil {
+		return "", errors.Wrapf(err, message)
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// removeCredentials removes any user name or token from the git URL so they are not stored in the resources
+func removeCredentials(gitURL string) string {
+	u, err := url.Parse(gitURL)
+	if err != nil || u.User == nil {
+		return gitURL
+	}
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,179 @@
+package combine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Combines the kubernetes resources in the YAML files of a directory tree into a single YAML file
+
+		This is the inverse of the split command. The resources are sorted by the install order of their kind then by namespace and name so that the output is the same every time. Documents which are not kubernetes resources are ignored.
+
+		Files which cannot be parsed are reported and skipped unless --strict is specified. Paths can be excluded via --exclude patterns which are matched against the relative path, the file name and the directory names of each file.
+`)
+
+	cmdExample = templates.Examples(`
+		# combines the resources in the current directory into all.yaml
+		%s combine --dir . --output all.yaml
+
+		# combines the resources removing the source files and failing if any file cannot be parsed
+		%s combine --dir config-root --output all.yaml --remove-sources --strict
+	`)
+
+	// DefaultExcludes the default paths which are not combined
+	DefaultExcludes = []string{"Kptfile", "kustomization.yaml", "kustomization.yml", "templates"}
+)
+
+// Options the options for the command
+type Options struct {
+	Dir           string
+	OutFile       string
+	Excludes      []string
+	RemoveSources bool
+	Strict        bool
+
+	// Resources the combined resources
+	Resources []*unstructured.Unstructured
+
+	// Sources the files which have been combined
+	Sources []string
+
+	// Skipped the files which could not be parsed
+	Skipped []string
+}
+
+// NewCmdCombine creates a command object for the command
+func NewCmdCombine() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "combine",
+		Short:   "Combines the kubernetes resources in the YAML files of a directory tree into a single YAML file",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.OutFile, "output", "o", "all.yaml", "the YAML file to save the combined resources to")
+	cmd.Flags().StringArrayVarP(&o.Excludes, "exclude", "", DefaultExcludes, "the patterns of the paths to exclude which can include wildcards such as '*-values.yaml'")
+	cmd.Flags().BoolVarP(&o.RemoveSources, "remove-sources", "", false, "removes the source files once the resources have been combined")
+	cmd.Flags().BoolVarP(&o.Strict, "strict", "", false, "fails if any file cannot be parsed rather than skipping it")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.OutFile == "" {
+		o.OutFile = "all.yaml"
+	}
+	outFile, err := filepath.Abs(o.OutFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the absolute path of %s", o.OutFile)
+	}
+
+	o.Resources = nil
+	o.Sources = nil
+	o.Skipped = nil
+	err = filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err == nil && abs == outFile {
+			return nil
+		}
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		if o.excluded(filepath.ToSlash(rel)) {
+			return nil
+		}
+		return o.combineFile(path)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to combine the YAML files in dir %s", o.Dir)
+	}
+
+	resourcehelpers.SortByInstallOrder(o.Resources)
+	err = os.MkdirAll(filepath.Dir(outFile), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(outFile))
+	}
+	err = resourcehelpers.SaveFile(o.Resources, o.OutFile)
+	if err != nil {
+		return err
+	}
+
+	if o.RemoveSources {
+		for _, path := range o.Sources {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+		}
+	}
+	log.Logger().Infof("combined %d resources from %d files into %s", len(o.Resources), len(o.Sources), termcolor.ColorInfo(o.OutFile))
+	return nil
+}
+
+// combineFile adds the resources of the file. The file is only a source to remove if it only contains resources
+func (o *Options) combineFile(path string) error {
+	resources, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		if o.Strict {
+			return err
+		}
+		log.Logger().Warnf("skipping file %s as it cannot be parsed: %s", termcolor.ColorInfo(path), err.Error())
+		o.Skipped = append(o.Skipped, path)
+		return nil
+	}
+	resources, _ = resourcehelpers.ExpandLists(resources)
+	source := true
+	for _, u := range resources {
+		if !resourcehelpers.IsResource(u) {
+			source = false
+			continue
+		}
+		o.Resources = append(o.Resources, u)
+	}
+	if !source {
+		if o.RemoveSources {
+			log.Logger().Warnf("file %s contains documents which are not kubernetes resources so it will not be removed", termcolor.ColorInfo(path))
+		}
+		return nil
+	}
+	o.Sources = append(o.Sources, path)
+	return nil
+}
+
+// excluded returns true if the relative path, file name or any directory name matches an exclude pattern
+func (o *Options) excluded(rel string) bool {
+	if resourcehelpers.MatchesAnyPattern(rel, o.Excludes) {
+		return true
+	}
+	for _, name := range strings.Split(rel, "/") {
+		if resourcehelpers.MatchesAnyPattern(name, o.Excludes) {
+			return true
+		}
+	}
+	return false
+}
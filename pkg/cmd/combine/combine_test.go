@@ -0,0 +1,79 @@
+package combine_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/combine"
+	gitopstesthelpers "github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+func TestCombine(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+	outFile := filepath.Join(tmpDir, "all.yaml")
+
+	_, o := combine.NewCmdCombine()
+	o.Dir = tmpDir
+	o.OutFile = outFile
+	err := o.Run()
+	require.NoError(t, err, "failed to combine files in dir %s", tmpDir)
+
+	assert.Len(t, o.Resources, 7, "resources")
+	assert.Equal(t, []string{filepath.Join(tmpDir, "cluster", "invalid.yaml")}, o.Skipped, "skipped files")
+
+	expectedFile := filepath.Join("test_data", "expected.yaml")
+	if generateTestOutput {
+		data, err := ioutil.ReadFile(outFile)
+		require.NoError(t, err, "failed to load %s", outFile)
+		err = ioutil.WriteFile(expectedFile, data, files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", expectedFile)
+	}
+	testhelpers.AssertTextFilesEqual(t, expectedFile, outFile, "combined file")
+
+	// the output should not change when combining again as the previous output is ignored
+	first, err := ioutil.ReadFile(outFile)
+	require.NoError(t, err, "failed to load %s", outFile)
+	err = o.Run()
+	require.NoError(t, err, "failed to combine files again in dir %s", tmpDir)
+	second, err := ioutil.ReadFile(outFile)
+	require.NoError(t, err, "failed to load %s", outFile)
+	assert.Equal(t, string(first), string(second), "combined file of the second run")
+
+	assert.FileExists(t, filepath.Join(tmpDir, "app", "service.yaml"), "the sources should not be removed by default")
+}
+
+func TestCombineRemoveSourcesAndStrict(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+	outFile := filepath.Join(tmpDir, "all.yaml")
+
+	_, o := combine.NewCmdCombine()
+	o.Dir = tmpDir
+	o.OutFile = outFile
+	o.Strict = true
+	err := o.Run()
+	require.Error(t, err, "should fail to parse cluster/invalid.yaml")
+	assert.NoFileExists(t, outFile, "the output should not be saved")
+
+	o.Strict = false
+	o.RemoveSources = true
+	err = o.Run()
+	require.NoError(t, err, "failed to combine files in dir %s", tmpDir)
+
+	for _, name := range []string{"app/deployment.yaml", "app/service.yaml", "cluster/resources.yaml"} {
+		assert.NoFileExists(t, filepath.Join(tmpDir, name), "the source should be removed")
+	}
+	for _, name := range []string{"app/values.yaml", "cluster/invalid.yaml", "chart/templates/service.yaml", "kustomization.yaml"} {
+		assert.FileExists(t, filepath.Join(tmpDir, name), "the file should be kept")
+	}
+	assert.FileExists(t, outFile, "combined file")
+}
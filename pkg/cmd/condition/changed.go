@@ -0,0 +1,228 @@
+package condition
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultHashFile the default file relative to the working directory used to store the content hashes of the
+	// directories when not using git
+	DefaultHashFile = ".jx/condition-hashes.yaml"
+)
+
+// changedDirs returns the directories which have changed using git if --since is specified and the working
+// directory is inside a git repository otherwise the content hashes of the directories are compared with the
+// hash file
+func (o *Options) changedDirs() ([]string, error) {
+	if o.Since != "" && o.isGitRepository() {
+		changed, err := o.SinceOptions.ChangedFiles(o.CommandRunner, o.Dir)
+		if err != nil {
+			return nil, err
+		}
+		var answer []string
+		for _, dir := range o.Dirs {
+			rel, err := o.relPath(dir)
+			if err != nil {
+				return nil, err
+			}
+			if changed.ContainsDir(rel) {
+				answer = append(answer, dir)
+			}
+		}
+		return answer, nil
+	}
+	if o.Since != "" {
+		log.Logger().Infof("comparing the content hashes of the directories as %s is not inside a git repository", termcolor.ColorInfo(o.workDir()))
+	}
+
+	hashes, err := LoadHashes(o.hashFile())
+	if err != nil {
+		return nil, err
+	}
+	o.hashes = map[string]string{}
+	var answer []string
+	for _, dir := range o.Dirs {
+		rel, err := o.relPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := HashDir(o.resolve(dir), o.hashFile())
+		if err != nil {
+			return nil, err
+		}
+		o.hashes[rel] = hash
+		if hashes[rel] != hash {
+			answer = append(answer, dir)
+		}
+	}
+	return answer, nil
+}
+
+// saveHashes saves the current content hashes of the directories if they were used to detect changes
+func (o *Options) saveHashes() error {
+	if o.hashes == nil {
+		return nil
+	}
+	path := o.hashFile()
+	hashes, err := LoadHashes(path)
+	if err != nil {
+		return err
+	}
+	for k, v := range o.hashes {
+		hashes[k] = v
+	}
+	data, err := yaml.Marshal(hashes)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the hashes")
+	}
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+func (o *Options) isGitRepository() bool {
+	_, err := o.CommandRunner(&cmdrunner.Command{
+		Dir:  o.Dir,
+		Name: "git",
+		Args: []string{"rev-parse", "--is-inside-work-tree"},
+	})
+	return err == nil
+}
+
+func (o *Options) workDir() string {
+	if o.Dir == "" {
+		return "."
+	}
+	return o.Dir
+}
+
+// resolve returns the path of a directory which is relative to the working directory
+func (o *Options) resolve(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(o.workDir(), dir)
+}
+
+// relPath returns the path of the directory relative to the working directory
+func (o *Options) relPath(dir string) (string, error) {
+	if !filepath.IsAbs(dir) {
+		return filepath.Clean(dir), nil
+	}
+	base, err := filepath.Abs(o.workDir())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the absolute path of %s", o.workDir())
+	}
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the path of %s relative to %s", dir, base)
+	}
+	return rel, nil
+}
+
+func (o *Options) hashFile() string {
+	if o.HashFile == "" {
+		return o.resolve(DefaultHashFile)
+	}
+	return o.resolve(o.HashFile)
+}
+
+// LoadHashes loads the content hashes of the directories from the hash file returning empty hashes if the file
+// does not exist
+func LoadHashes(path string) (map[string]string, error) {
+	answer := map[string]string{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return answer, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	err = yaml.Unmarshal(data, &answer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	if answer == nil {
+		answer = map[string]string{}
+	}
+	return answer, nil
+}
+
+// HashDir returns the sha256 hash of the relative paths and contents of the files in the directory tree ignoring
+// any .git directories and the given hash file. A missing directory has a blank hash
+func HashDir(dir, hashFile string) (string, error) {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return "", nil
+	}
+	absHashFile, _ := filepath.Abs(hashFile)
+
+	var paths []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); abs == absHashFile {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to walk dir %s", dir)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		h.Write([]byte(filepath.ToSlash(rel)))
+		h.Write([]byte{0})
+		f, err := os.Open(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to open file %s", path)
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read file %s", path)
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/common"
 	"github.com/jenkins-x/jx-gitops/pkg/filters"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
@@ -20,6 +21,10 @@ import (
 var (
 	cmdLong = templates.LongDesc(`
 		Runs a command if the condition is true
+
+		If one or more --changed-dir flags are specified the command is only run if any files in the directories have changed. If --since is specified and the --dir is inside a git repository the files which have changed since the git ref, including any uncommitted or untracked files, are used. Otherwise the content hashes of the directories are compared with the hashes stored in the --hash-file which is updated after the command succeeds. Use --invert to only run the command if nothing has changed.
+
+		If the command fails its exit code is used as the exit code of this command.
 `)
 
 	cmdExample = templates.Examples(`
@@ -31,6 +36,11 @@ you can use ! in front of a filter to be the equivalant of not matching the cond
 		# runs a command if the last commit message does not have a given prefix
 		%s condition --last-commit-msg-prefix '!Merge pull request' -- make all commit push
 
+		# runs a command if the config root directory has changed since the last commit
+		%s condition --changed-dir config-root --since HEAD~1 -- make regen-phase-1 push
+
+		# runs a command if either directory has changed since it last succeeded outside of a git repository
+		%s condition --changed-dir charts --changed-dir helmfiles -- make all
 	`)
 
 	pathSeparator = string(os.PathSeparator)
@@ -38,13 +48,20 @@ you can use ! in front of a filter to be the equivalant of not matching the cond
 
 // KptOptions the options for the command
 type Options struct {
+	common.SinceOptions
 	Dir                     string
+	Dirs                    []string
+	HashFile                string
+	Invert                  bool
 	Args                    []string
 	LastCommitMessageFilter filters.StringFilter
+	Skipped                 bool
+	ExitCode                int
 	BatchMode               bool
 	CommandRunner           cmdrunner.CommandRunner
 	Out                     io.Writer
 	Err                     io.Writer
+	hashes                  map[string]string
 }
 
 // NewCmdCondition creates a command object for the command
@@ -55,14 +72,21 @@ func NewCmdCondition() (*cobra.Command, *Options) {
 		Use:     "condition [flags] command arguments...",
 		Short:   "Runs a command if the condition is true",
 		Long:    cmdLong,
-		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			o.Args = args
 			err := o.Run()
+			if err != nil && o.ExitCode > 0 {
+				helper.Fatal("error: "+err.Error(), o.ExitCode)
+			}
 			helper.CheckErr(err)
 		},
 	}
-	cmd.Flags().StringVarP(&o.Dir, "dir", "d", "", "the directory to run the git push command from")
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", "", "the directory to run the git commands and the command from")
+	cmd.Flags().StringArrayVarP(&o.Dirs, "changed-dir", "", nil, "the directories relative to the --dir which must have changed for the command to be run. Can be specified multiple times")
+	cmd.Flags().StringVarP(&o.Since, "since", "", "", "the git ref such as 'origin/main' or 'HEAD~1' the directories must have changed since. If not specified or not inside a git repository the content hashes of the directories are used")
+	cmd.Flags().StringVarP(&o.HashFile, "hash-file", "", DefaultHashFile, "the file relative to the --dir used to store the content hashes of the directories when not using git")
+	cmd.Flags().BoolVarP(&o.Invert, "invert", "", false, "only runs the command if none of the directories have changed")
 
 	o.LastCommitMessageFilter.AddFlags(cmd, "last-commit-msg", "last commit message")
 	return cmd, o
@@ -77,6 +101,31 @@ func (o *Options) Run() error {
 	if o.CommandRunner == nil {
 		o.CommandRunner = cmdrunner.DefaultCommandRunner
 	}
+	o.Skipped = false
+	o.ExitCode = 0
+	o.hashes = nil
+
+	if len(o.Dirs) > 0 {
+		changed, err := o.changedDirs()
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if the directories have changed")
+		}
+		if len(changed) > 0 {
+			log.Logger().Infof("found changes in %s", termcolor.ColorInfo(strings.Join(changed, ", ")))
+		}
+		if (len(changed) > 0) == o.Invert {
+			o.Skipped = true
+			if o.Invert {
+				log.Logger().Infof("skipped running %s as the directories have changed: %s", termcolor.ColorInfo(strings.Join(o.Args, " ")), strings.Join(changed, ", "))
+			} else {
+				log.Logger().Infof("skipped running %s as none of the directories have changed: %s", termcolor.ColorInfo(strings.Join(o.Args, " ")), strings.Join(o.Dirs, ", "))
+			}
+			return nil
+		}
+	}
+	if o.LastCommitMessageFilter.Prefix == "" && o.LastCommitMessageFilter.Suffix == "" && o.LastCommitMessageFilter.Contains == "" {
+		return o.runCommand()
+	}
 
 	c := &cmdrunner.Command{
 		Dir:  o.Dir,
@@ -90,24 +139,33 @@ func (o *Options) Run() error {
 	lastCommitMessage = strings.TrimSpace(lastCommitMessage)
 	log.Logger().Infof("found last commit message: %s", termcolor.ColorStatus(lastCommitMessage))
 
-	if o.LastCommitMessageFilter.Matches(lastCommitMessage) {
-		if o.Out == nil {
-			o.Out = os.Stdout
-		}
-		if o.Err == nil {
-			o.Err = os.Stderr
-		}
-		c = &cmdrunner.Command{
-			Dir:  o.Dir,
-			Name: o.Args[0],
-			Args: o.Args[1:],
-			Out:  o.Out,
-			Err:  o.Err,
-		}
-		_, err = o.CommandRunner(c)
-		if err != nil {
-			return errors.Wrapf(err, "failed to run %s", c.CLI())
-		}
+	if !o.LastCommitMessageFilter.Matches(lastCommitMessage) {
+		o.Skipped = true
+		return nil
+	}
+	return o.runCommand()
+}
+
+// runCommand runs the command recording its exit code and saving the content hashes of the directories if it
+// succeeds
+func (o *Options) runCommand() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Err == nil {
+		o.Err = os.Stderr
+	}
+	c := &cmdrunner.Command{
+		Dir:  o.Dir,
+		Name: o.Args[0],
+		Args: o.Args[1:],
+		Out:  o.Out,
+		Err:  o.Err,
+	}
+	_, err := o.CommandRunner(c)
+	if err != nil {
+		o.ExitCode = common.ExitCode(err)
+		return errors.Wrapf(err, "failed to run %s", c.CLI())
 	}
-	return nil
+	return o.saveHashes()
 }
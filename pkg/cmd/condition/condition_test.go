@@ -1,11 +1,18 @@
 package condition_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/condition"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -61,3 +68,151 @@ func TestConditionNotMatch(t *testing.T) {
 		},
 	)
 }
+
+func TestConditionChangedHashFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	configFile := filepath.Join(tmpDir, "config-root", "cm.yaml")
+	writeFile(t, configFile, "apiVersion: v1\nkind: ConfigMap\n")
+	writeFile(t, filepath.Join(tmpDir, "charts", "values.yaml"), "replicaCount: 1\n")
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := condition.NewCmdCondition()
+	o.CommandRunner = runner.Run
+	o.Dir = tmpDir
+	o.Dirs = []string{"config-root", "charts"}
+	o.HashFile = condition.DefaultHashFile
+	o.Args = []string{"make", "all"}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run conditional")
+	assert.False(t, o.Skipped, "the command should run the first time")
+	hashes, err := condition.LoadHashes(filepath.Join(tmpDir, condition.DefaultHashFile))
+	require.NoError(t, err, "failed to load hashes")
+	assert.Len(t, hashes, 2, "hashes")
+	assert.NotEmpty(t, hashes["config-root"], "hash of config-root")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run conditional")
+	assert.True(t, o.Skipped, "the command should be skipped if nothing changed")
+
+	o.Invert = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run conditional")
+	assert.False(t, o.Skipped, "the inverted command should run if nothing changed")
+
+	writeFile(t, configFile, "apiVersion: v1\nkind: ConfigMap\ndata:\n  foo: bar\n")
+	err = o.Run()
+	require.NoError(t, err, "failed to run conditional")
+	assert.True(t, o.Skipped, "the inverted command should be skipped if a directory changed")
+
+	o.Invert = false
+	err = o.Run()
+	require.NoError(t, err, "failed to run conditional")
+	assert.False(t, o.Skipped, "the command should run if a directory changed")
+
+	runner.ExpectResults(t,
+		fakerunner.FakeResult{CLI: "make all"},
+		fakerunner.FakeResult{CLI: "make all"},
+		fakerunner.FakeResult{CLI: "make all"},
+	)
+}
+
+func TestConditionChangedHashFileNotSavedOnFailure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	writeFile(t, filepath.Join(tmpDir, "config-root", "cm.yaml"), "apiVersion: v1\nkind: ConfigMap\n")
+
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, exitErr, "should fail to run the command")
+
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			if c.Name == "git" {
+				return "", errors.New("fatal: not a git repository")
+			}
+			return "", exitErr
+		},
+	}
+	_, o := condition.NewCmdCondition()
+	o.CommandRunner = runner.Run
+	o.Dir = tmpDir
+	o.Dirs = []string{"config-root"}
+	o.HashFile = "hashes.yaml"
+	o.Since = "HEAD~1"
+	o.Args = []string{"make", "all"}
+
+	err = o.Run()
+	require.Error(t, err, "should fail if the command fails")
+	assert.Equal(t, 3, o.ExitCode, "the exit code of the command")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "hashes.yaml"), "the hashes should not be saved if the command fails")
+
+	runner.ExpectResults(t,
+		fakerunner.FakeResult{CLI: "git rev-parse --is-inside-work-tree"},
+		fakerunner.FakeResult{CLI: "make all"},
+	)
+}
+
+func TestConditionChangedSinceGit(t *testing.T) {
+	testCases := []struct {
+		changed  string
+		dirs     []string
+		invert   bool
+		expected bool
+	}{
+		{
+			changed:  "config-root/namespaces/jx/cm.yaml\n",
+			dirs:     []string{"charts", "config-root"},
+			expected: true,
+		},
+		{
+			changed:  "charts/values.yaml\n",
+			dirs:     []string{"config-root"},
+			expected: false,
+		},
+		{
+			changed:  "charts/values.yaml\n",
+			dirs:     []string{"config-root"},
+			invert:   true,
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		runner := &fakerunner.FakeRunner{
+			CommandRunner: func(c *cmdrunner.Command) (string, error) {
+				if c.Name == "git" && c.Args[0] == "diff" {
+					return tc.changed, nil
+				}
+				return "", nil
+			},
+		}
+		_, o := condition.NewCmdCondition()
+		o.CommandRunner = runner.Run
+		o.Dirs = tc.dirs
+		o.Since = "HEAD~1"
+		o.Invert = tc.invert
+		o.Args = []string{"make", "all"}
+		err := o.Run()
+		require.NoError(t, err, "failed to run conditional for changes %s", tc.changed)
+
+		assert.Equal(t, !tc.expected, o.Skipped, "skipped for changes %s in dirs %v", tc.changed, tc.dirs)
+		results := []fakerunner.FakeResult{
+			{CLI: "git rev-parse --is-inside-work-tree"},
+			{CLI: "git rev-parse --verify --quiet HEAD~1^{commit}"},
+			{CLI: "git diff --name-only --relative HEAD~1 --"},
+			{CLI: "git ls-files --others --exclude-standard"},
+		}
+		if tc.expected {
+			results = append(results, fakerunner.FakeResult{CLI: "make all"})
+		}
+		runner.ExpectResults(t, results...)
+	}
+}
+
+func writeFile(t *testing.T, path, text string) {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir for %s", path)
+	err = ioutil.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write file %s", path)
+}
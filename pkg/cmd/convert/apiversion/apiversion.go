@@ -0,0 +1,217 @@
+package apiversion
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/canonicalizeapiversion"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Converts the kubernetes resources in the given directory tree which use apiVersions deprecated or removed by the target kubernetes version to their replacement apiVersions
+
+		The apiVersions are converted using the same table of conversions as the resources canonicalize-apiversion command but only those deprecated by the --target-version are converted so that the resources can still be applied to a cluster of that version. Fields are adjusted where the structure differs between the versions such as the serviceName and servicePort of Ingress backends which become service.name and service.port.
+
+		Resources which cannot be converted safely are left untouched and reported as warnings. Resources whose kinds have no replacement, such as PodSecurityPolicies which were removed in kubernetes 1.25, are reported with guidance on how to migrate them by hand.
+
+		If --check is specified the resources are not modified. The resources which would be converted are reported and the command fails if any of the resources use apiVersions which are removed by the --target-version so that it can be used to verify a repository before upgrading a cluster.
+`)
+
+	cmdExample = templates.Examples(`
+		# converts the resources which use apiVersions deprecated or removed by kubernetes 1.25
+		%s convert apiversion --target-version 1.25
+
+		# fails if any resources use apiVersions removed by kubernetes 1.25 without modifying them
+		%s convert apiversion --dir config-root --target-version 1.25 --check
+	`)
+)
+
+// Result a resource which uses apiVersions deprecated by the target version
+type Result struct {
+	// Path the file containing the resource
+	Path string
+
+	// Resource the key of the resource
+	Resource string
+
+	// From the deprecated apiVersion
+	From string
+
+	// To the replacement apiVersion
+	To string
+
+	// Removed true if the From apiVersion is removed by the target version
+	Removed bool
+}
+
+// Warning a resource which uses apiVersions deprecated by the target version which cannot be converted
+type Warning struct {
+	// Path the file containing the resource
+	Path string
+
+	// Resource the key of the resource
+	Resource string
+
+	// APIVersion the deprecated apiVersion of the resource
+	APIVersion string
+
+	// Message the reason the resource cannot be converted or how to migrate it by hand
+	Message string
+
+	// Removed true if the APIVersion is removed by the target version
+	Removed bool
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir           string
+	TargetVersion string
+	Check         bool
+	Results       []Result
+	Warnings      []Warning
+	version       canonicalizeapiversion.KubernetesVersion
+}
+
+// NewCmdAPIVersion creates a command object for the command
+func NewCmdAPIVersion() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "apiversion",
+		Aliases: []string{"apiversions"},
+		Short:   "Converts the kubernetes resources which use apiVersions deprecated or removed by the target kubernetes version",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.TargetVersion, "target-version", "t", "", "the kubernetes version the resources are converted for such as '1.25'")
+	cmd.Flags().BoolVarP(&o.Check, "check", "", false, "reports the resources which need converting without modifying them failing if any use apiVersions removed by the target version")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.TargetVersion == "" {
+		return options.MissingOption("target-version")
+	}
+	var err error
+	o.version, err = canonicalizeapiversion.ParseKubernetesVersion(o.TargetVersion)
+	if err != nil {
+		return options.InvalidOptionf("target-version", o.TargetVersion, "should be a kubernetes version such as '1.25'")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	o.Results = nil
+	o.Warnings = nil
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, o.convert)
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert the apiVersions of resources in dir %s", o.Dir)
+	}
+
+	verb := "converted"
+	if o.Check {
+		verb = "would convert"
+	}
+	var removed []string
+	for _, r := range o.Results {
+		log.Logger().Infof("%s %s in file %s from %s to %s", verb, r.Resource, o.relPath(r.Path), r.From, termcolor.ColorInfo(r.To))
+		if r.Removed {
+			removed = append(removed, r.Resource)
+		}
+	}
+	for _, w := range o.Warnings {
+		log.Logger().Warnf("cannot convert %s in file %s from %s: %s", w.Resource, o.relPath(w.Path), w.APIVersion, w.Message)
+		if w.Removed {
+			removed = append(removed, w.Resource)
+		}
+	}
+	log.Logger().Infof("%s %d resources with apiVersions deprecated by kubernetes %s with %d warnings", verb, len(o.Results), o.version.String(), len(o.Warnings))
+	if o.Check && len(removed) > 0 {
+		return errors.Errorf("found %d resources using apiVersions removed by kubernetes %s: %s", len(removed), o.version.String(), strings.Join(removed, ", "))
+	}
+	return nil
+}
+
+// convert converts the resource through each of the conversions deprecated by the target version. The resource is
+// converted on a copy so that it is left untouched if any of the conversions cannot be applied
+func (o *Options) convert(u *unstructured.Unstructured, path string) (bool, error) {
+	from := u.GetAPIVersion()
+	converted := u.DeepCopy()
+	removed := false
+	for {
+		apiVersion := converted.GetAPIVersion()
+		c := canonicalizeapiversion.FindConversion(apiVersion, converted.GetKind())
+		if c == nil || !c.IsDeprecatedBy(o.version) {
+			break
+		}
+		removed = removed || c.IsRemovedBy(o.version)
+		reason := c.Guidance
+		if c.To != "" && c.Convert != nil {
+			var err error
+			reason, err = c.Convert(converted)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to convert from %s to %s", apiVersion, c.To)
+			}
+		}
+		if c.To == "" || reason != "" {
+			o.Warnings = append(o.Warnings, Warning{
+				Path:       path,
+				Resource:   resourcehelpers.ResourceKey(u),
+				APIVersion: apiVersion,
+				Message:    reason,
+				Removed:    removed,
+			})
+			return false, nil
+		}
+		converted.SetAPIVersion(c.To)
+	}
+	to := converted.GetAPIVersion()
+	if to == from {
+		return false, nil
+	}
+	o.Results = append(o.Results, Result{
+		Path:     path,
+		Resource: resourcehelpers.ResourceKey(u),
+		From:     from,
+		To:       to,
+		Removed:  removed,
+	})
+	if o.Check {
+		return false, nil
+	}
+	u.Object = converted.Object
+	return true, nil
+}
+
+func (o *Options) relPath(path string) string {
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,128 @@
+package apiversion_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/convert/apiversion"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestConvertAPIVersion(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := apiversion.NewCmdAPIVersion()
+	o.Dir = tmpDir
+	o.TargetVersion = "1.25"
+	err := o.Run()
+	require.NoError(t, err, "failed to convert the apiVersions")
+
+	assert.Equal(t, []string{
+		"jx/Ingress/web extensions/v1beta1 => networking.k8s.io/v1 removed",
+		"jx/CronJob/cleanup batch/v1beta1 => batch/v1 removed",
+		"jx/HorizontalPodAutoscaler/web autoscaling/v2beta2 => autoscaling/v2 deprecated",
+	}, conversions(o), "conversions")
+
+	require.Len(t, o.Warnings, 1, "warnings")
+	w := o.Warnings[0]
+	assert.Equal(t, "PodSecurityPolicy/restricted", w.Resource, "warning resource")
+	assert.Equal(t, "policy/v1beta1", w.APIVersion, "warning apiVersion")
+	assert.Contains(t, w.Message, "pod-security.kubernetes.io", "warning guidance")
+	assert.True(t, w.Removed, "PodSecurityPolicy should be removed")
+
+	assertResourcesEqual(t, filepath.Join("test_data", "expected-resources.yaml"), filepath.Join(tmpDir, "resources.yaml"))
+	assertResourcesEqual(t, filepath.Join("test_data", "src", "psp.yaml"), filepath.Join(tmpDir, "psp.yaml"))
+	assertResourcesEqual(t, filepath.Join("test_data", "src", "current.yaml"), filepath.Join(tmpDir, "current.yaml"))
+
+	// converting again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to convert the apiVersions again")
+	assert.Empty(t, o.Results, "conversions on the second run")
+	assert.Len(t, o.Warnings, 1, "warnings on the second run")
+}
+
+func TestConvertAPIVersionOlderTarget(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := apiversion.NewCmdAPIVersion()
+	o.Dir = tmpDir
+	o.TargetVersion = "v1.20.4"
+	err := o.Run()
+	require.NoError(t, err, "failed to convert the apiVersions")
+
+	// the CronJob and HorizontalPodAutoscaler replacements are not served by kubernetes 1.20
+	assert.Equal(t, []string{
+		"PodSecurityPolicy/restricted extensions/v1beta1 => policy/v1beta1 removed",
+		"jx/Ingress/web extensions/v1beta1 => networking.k8s.io/v1 deprecated",
+	}, conversions(o), "conversions")
+	assert.Empty(t, o.Warnings, "warnings")
+}
+
+func TestConvertAPIVersionCheck(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := apiversion.NewCmdAPIVersion()
+	o.Dir = tmpDir
+	o.TargetVersion = "1.25"
+	o.Check = true
+	err := o.Run()
+	require.Error(t, err, "should fail as resources use apiVersions removed by 1.25")
+	t.Logf("got expected error: %s", err.Error())
+
+	assert.Len(t, o.Results, 3, "conversions")
+	assert.Len(t, o.Warnings, 1, "warnings")
+	for _, name := range []string{"resources.yaml", "psp.yaml", "current.yaml"} {
+		assertResourcesEqual(t, filepath.Join("test_data", "src", name), filepath.Join(tmpDir, name))
+	}
+
+	// the CronJob apiVersion is only deprecated by kubernetes 1.21
+	o.TargetVersion = "1.21"
+	o.Kinds = []string{"CronJob", "HorizontalPodAutoscaler"}
+	err = o.Run()
+	require.NoError(t, err, "should not fail as no CronJobs use apiVersions removed by 1.21")
+	assert.Equal(t, []string{
+		"jx/CronJob/cleanup batch/v1beta1 => batch/v1 deprecated",
+	}, conversions(o), "conversions")
+}
+
+func TestConvertAPIVersionInvalidTarget(t *testing.T) {
+	for _, version := range []string{"", "latest", "1"} {
+		_, o := apiversion.NewCmdAPIVersion()
+		o.Dir = filepath.Join("test_data", "src")
+		o.TargetVersion = version
+		err := o.Run()
+		require.Error(t, err, "should fail for target version '%s'", version)
+	}
+}
+
+func conversions(o *apiversion.Options) []string {
+	var answer []string
+	for _, r := range o.Results {
+		status := "deprecated"
+		if r.Removed {
+			status = "removed"
+		}
+		answer = append(answer, r.Resource+" "+r.From+" => "+r.To+" "+status)
+	}
+	return answer
+}
+
+// assertResourcesEqual compares the resources rather than the text of the files as the converted files are saved
+// with sorted keys
+func assertResourcesEqual(t *testing.T, expectedFile, actualFile string) {
+	expected, err := resourcehelpers.LoadFile(expectedFile)
+	require.NoError(t, err, "failed to load %s", expectedFile)
+	actual, err := resourcehelpers.LoadFile(actualFile)
+	require.NoError(t, err, "failed to load %s", actualFile)
+	assert.Equal(t, toYAML(t, expected), toYAML(t, actual), "resources in %s", actualFile)
+}
+
+func toYAML(t *testing.T, resources []*unstructured.Unstructured) string {
+	data, err := resourcehelpers.ToYAML(resources)
+	require.NoError(t, err, "failed to marshal the resources")
+	return string(data)
+}
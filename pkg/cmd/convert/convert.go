@@ -0,0 +1,24 @@
+package convert
+
+import (
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/convert/apiversion"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdConvert creates the new command
+func NewCmdConvert() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "convert",
+		Short: "Commands for converting kubernetes resources",
+		Run: func(command *cobra.Command, args []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Errorf(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(apiversion.NewCmdAPIVersion()))
+	return command
+}
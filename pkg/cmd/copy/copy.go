@@ -28,6 +28,10 @@ var (
 
 	cmdLong = templates.LongDesc(`
 		Copies kubernetes resources (by default confimaps) from a namespace to the current namespace
+
+		If --from is specified the resource documents matching the kinds, name and selector are copied from the YAML files in the --from directory tree to the --to directory tree instead. Each document is extracted from its source file and written to the same relative directory of the destination using the canonical file name of the rename command such as 'myapp-deploy.yaml'. The namespace can be changed via --to-namespace.
+
+		A resource with the same kind, name and namespace which already exists in the destination tree is only replaced if --overwrite is specified otherwise the command fails without copying anything. Use --dry-run to list the resources which would be copied.
 `)
 
 	cmdExample = templates.Examples(`
@@ -39,13 +43,23 @@ var (
 
 		# copies resources matching a selector and kind
 		%s copy --kind ingresses -l mylabel=something --to=foo
+
+		# copies the resources of an app from the staging tree to the production tree
+		%s copy --from envs/staging --to envs/prod --kind Deployment,Service --name 'myapp*'
+
+		# lists the resources which would be copied changing their namespace
+		%s copy --from envs/staging --to envs/prod --to-namespace jx-production --dry-run
 	`)
 )
 
 // Options the options for the command
 type Options struct {
 	Namespace       string
+	To              string
 	ToNamespace     string
+	From            string
+	Overwrite       bool
+	DryRun          bool
 	Group           string
 	Version         string
 	Kind            string
@@ -54,6 +68,7 @@ type Options struct {
 	Query           string
 	CreateNamespace bool
 	Count           int
+	Copies          []FileCopy
 	DynamicClient   dynamic.Interface
 	KubeClient      kubernetes.Interface
 }
@@ -66,28 +81,41 @@ func NewCmdCopy() (*cobra.Command, *Options) {
 		Use:     "copy",
 		Short:   "Copies resources (by default confimaps) with the given selector or name from a source namespace to a destination namespace",
 		Long:    cmdLong,
-		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Namespace, "ns", "n", "", "the namespace to find the resources to copy. Defaults to the current namespace")
-	cmd.Flags().StringVarP(&o.ToNamespace, "to", "t", "", "the namespace to copy the secrets to")
+	cmd.Flags().StringVarP(&o.To, "to", "t", "", "the namespace to copy the resources to or the destination directory if --from is specified")
+	cmd.Flags().StringVarP(&o.ToNamespace, "to-namespace", "", "", "the namespace to copy the resources to. If --from is specified the namespace of the copied resources is changed to it")
+	cmd.Flags().StringVarP(&o.From, "from", "", "", "the directory tree of the YAML files to copy the resources from instead of a namespace")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "replaces the resources which already exist in the destination directory tree")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "lists the resources which would be copied from the --from directory tree without copying them")
 	cmd.Flags().StringVarP(&o.Group, "group", "g", "", "the API group such as 'apps' for Deployemnts")
 	cmd.Flags().StringVarP(&o.Version, "version", "", "v1", "the API version of the resources to copy")
-	cmd.Flags().StringVarP(&o.Kind, "kind", "k", "configmaps", "the kind name")
+	cmd.Flags().StringVarP(&o.Kind, "kind", "k", "", "the kind name. Defaults to configmaps when copying from a namespace. If --from is specified this is a comma separated list of kinds such as 'Deployment,Service'")
 	cmd.Flags().StringVarP(&o.Selector, "selector", "l", "", "the label selector to find the resources to copy")
-	cmd.Flags().StringVarP(&o.Name, "name", "", "", "the name of the resource to copy instead of a selector")
+	cmd.Flags().StringVarP(&o.Name, "name", "", "", "the name of the resource to copy instead of a selector. If --from is specified this can include wildcards such as 'myapp*'")
 	cmd.Flags().BoolVarP(&o.CreateNamespace, "create-namespace", "", false, "create the to Namespace if it does not already exist")
 	return cmd, o
 }
 
 // Run implements the command
 func (o *Options) Run() error {
+	if o.From != "" {
+		return o.copyFiles()
+	}
+	if o.ToNamespace == "" {
+		o.ToNamespace = o.To
+	}
 	if o.ToNamespace == "" {
 		return options.MissingOption("to")
 	}
+	if o.Kind == "" {
+		o.Kind = "configmaps"
+	}
 	if o.Selector == "" && o.Name == "" {
 		return options.MissingOption("selector")
 	}
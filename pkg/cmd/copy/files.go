@@ -0,0 +1,210 @@
+package copy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/rename"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// FileCopy a resource document copied from a file of the source directory tree to a file of the destination tree
+type FileCopy struct {
+	// From the source file containing the resource
+	From string
+
+	// To the destination file of the resource
+	To string
+
+	// Resource the key of the resource in the destination tree
+	Resource string
+
+	// Overwrite true if the resource replaces an existing resource in the destination file
+	Overwrite bool
+
+	resource *unstructured.Unstructured
+}
+
+// String returns a description of the copy
+func (c *FileCopy) String() string {
+	if c.Overwrite {
+		return fmt.Sprintf("%s: %s => %s (overwrite)", c.From, c.Resource, c.To)
+	}
+	return fmt.Sprintf("%s: %s => %s", c.From, c.Resource, c.To)
+}
+
+// copyFiles copies the matching resource documents from the YAML files in the source directory to the destination
+func (o *Options) copyFiles() error {
+	if o.To == "" {
+		return options.MissingOption("to")
+	}
+	selector, err := o.fileSelector()
+	if err != nil {
+		return err
+	}
+
+	// lets index the resources which already exist in the destination
+	existing := map[string]string{}
+	exists, err := files.DirExists(o.To)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", o.To)
+	}
+	if exists {
+		err = resourcehelpers.VisitFiles(o.To, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+			existing[resourcehelpers.ResourceKey(u)] = path
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to load resources in dir %s", o.To)
+		}
+	}
+
+	o.Copies = nil
+	copied := map[string]bool{}
+	taken := map[string]bool{}
+	var conflicts []string
+	err = resourcehelpers.VisitFiles(o.From, selector, func(u *unstructured.Unstructured, path string) error {
+		u = u.DeepCopy()
+		if o.ToNamespace != "" && u.GetNamespace() != "" {
+			u.SetNamespace(o.ToNamespace)
+		}
+		key := resourcehelpers.ResourceKey(u)
+		if copied[key] {
+			log.Logger().Warnf("ignoring duplicate resource %s in file %s", termcolor.ColorWarning(key), path)
+			return nil
+		}
+		copied[key] = true
+
+		c := FileCopy{From: path, Resource: key, resource: u}
+		if dest := existing[key]; dest != "" {
+			if !o.Overwrite {
+				conflicts = append(conflicts, fmt.Sprintf("%s in %s", key, dest))
+				return nil
+			}
+			c.To = dest
+			c.Overwrite = true
+		} else {
+			rel, err := filepath.Rel(o.From, filepath.Dir(path))
+			if err != nil {
+				return errors.Wrapf(err, "failed to find the relative dir of %s", path)
+			}
+			c.To, err = uniquePath(filepath.Join(o.To, rel), u, taken)
+			if err != nil {
+				return err
+			}
+		}
+		o.Copies = append(o.Copies, c)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the resources to copy in dir %s", o.From)
+	}
+	if len(conflicts) > 0 {
+		return errors.Errorf("found %d resources which already exist in dir %s so use --overwrite to replace them: %s", len(conflicts), o.To, strings.Join(conflicts, ", "))
+	}
+
+	if o.DryRun {
+		for i := range o.Copies {
+			log.Logger().Infof("would copy %s", termcolor.ColorInfo(o.Copies[i].String()))
+		}
+		log.Logger().Infof("would copy %d resources from %s to %s", len(o.Copies), o.From, o.To)
+		return nil
+	}
+	for i := range o.Copies {
+		c := &o.Copies[i]
+		err = c.write()
+		if err != nil {
+			return errors.Wrapf(err, "failed to copy %s", c.String())
+		}
+		log.Logger().Infof("copied %s", termcolor.ColorInfo(c.String()))
+		o.Count++
+	}
+	if len(o.Copies) == 0 {
+		log.Logger().Infof("did not find any resources in dir %s matching the filters", o.From)
+	}
+	return nil
+}
+
+// fileSelector returns the selector of the resources to copy from the source directory
+func (o *Options) fileSelector() (resourcehelpers.Selector, error) {
+	selector := resourcehelpers.Selector{}
+	for _, kind := range strings.Split(o.Kind, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind != "" {
+			selector.Kinds = append(selector.Kinds, kind)
+		}
+	}
+	if o.Name != "" {
+		selector.Names = []string{o.Name}
+	}
+	if o.Namespace != "" {
+		selector.Namespaces = []string{o.Namespace}
+	}
+	if o.Selector != "" {
+		m, err := labels.ConvertSelectorToLabelsMap(o.Selector)
+		if err != nil {
+			return selector, options.InvalidOptionf("selector", o.Selector, "should be of the form 'key=value,key2=value2'")
+		}
+		selector.Labels = m
+	}
+	return selector, nil
+}
+
+// write writes the resource to the destination file replacing the existing document if it is an overwrite
+func (c *FileCopy) write() error {
+	if !c.Overwrite {
+		err := os.MkdirAll(filepath.Dir(c.To), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(c.To))
+		}
+		return resourcehelpers.SaveFile([]*unstructured.Unstructured{c.resource}, c.To)
+	}
+	resources, err := resourcehelpers.LoadFile(c.To)
+	if err != nil {
+		return err
+	}
+	for i, u := range resources {
+		if resourcehelpers.IsResource(u) && resourcehelpers.ResourceKey(u) == c.Resource {
+			resources[i] = c.resource
+			return resourcehelpers.SaveFile(resources, c.To)
+		}
+	}
+	return errors.Errorf("could not find %s in file %s", c.Resource, c.To)
+}
+
+// uniquePath returns the path of a new file in the dir using the canonical file name of the resource appending
+// the namespace or an index if the file already exists
+func uniquePath(dir string, u *unstructured.Unstructured, taken map[string]bool) (string, error) {
+	name := strings.TrimSuffix(rename.CanonicalFileName(u.GetAPIVersion(), u.GetKind(), u.GetName()), ".yaml")
+	candidates := []string{name}
+	if ns := u.GetNamespace(); ns != "" {
+		candidates = append(candidates, name+"-"+ns)
+	}
+	for i := 2; ; i++ {
+		for _, candidate := range candidates {
+			path := filepath.Join(dir, candidate+".yaml")
+			if taken[path] {
+				continue
+			}
+			exists, err := files.FileExists(path)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to check if file exists %s", path)
+			}
+			if !exists {
+				taken[path] = true
+				return path, nil
+			}
+		}
+		candidates = []string{name + "-" + strconv.Itoa(i)}
+	}
+}
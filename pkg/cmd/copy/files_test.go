@@ -0,0 +1,104 @@
+package copy_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/copy"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCopyFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	err = files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test data")
+
+	fromDir := filepath.Join(tmpDir, "staging")
+	toDir := filepath.Join(tmpDir, "prod")
+	deployFile := filepath.Join(toDir, "apps", "myapp-deploy.yaml")
+	servicesFile := filepath.Join(toDir, "apps", "services.yaml")
+
+	_, o := copy.NewCmdCopy()
+	o.From = fromDir
+	o.To = toDir
+	o.ToNamespace = "jx-production"
+	o.Kind = "Deployment, Service"
+	o.Name = "myapp*"
+	err = o.Run()
+	require.Error(t, err, "should fail as the service already exists")
+	assert.Contains(t, err.Error(), "jx-production/Service/myapp", "error message")
+	assert.NoFileExists(t, deployFile, "nothing should be copied if a resource already exists")
+
+	o.DryRun = true
+	o.Overwrite = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run a dry run")
+	require.Len(t, o.Copies, 2, "copies")
+	assert.Equal(t, deployFile, o.Copies[0].To, "destination of the deployment")
+	assert.False(t, o.Copies[0].Overwrite, "the deployment is a new file")
+	assert.Equal(t, servicesFile, o.Copies[1].To, "destination of the service")
+	assert.True(t, o.Copies[1].Overwrite, "the service replaces the existing service")
+	assert.NoFileExists(t, deployFile, "nothing should be copied in a dry run")
+
+	o.DryRun = false
+	err = o.Run()
+	require.NoError(t, err, "failed to copy the resources")
+	assert.Equal(t, 2, o.Count, "copied resources")
+
+	resources := loadFile(t, deployFile)
+	require.Len(t, resources, 1, "the deployment should be extracted into its own file")
+	assert.Equal(t, "myapp", resources[0].GetName(), "name of the deployment")
+	assert.Equal(t, "jx-production", resources[0].GetNamespace(), "namespace of the deployment")
+
+	resources = loadFile(t, servicesFile)
+	require.Len(t, resources, 2, "the other service should be kept")
+	assert.Equal(t, "web", resources[0].GetName(), "name of the other service")
+	ports, _, err := unstructured.NestedSlice(resources[1].Object, "spec", "ports")
+	require.NoError(t, err, "failed to get ports")
+	assert.EqualValues(t, 8080, ports[0].(map[string]interface{})["port"], "the service should be replaced")
+
+	source := loadFile(t, filepath.Join(fromDir, "apps", "myapp.yaml"))
+	assert.Len(t, source, 3, "the source file should not be modified")
+	assert.Equal(t, "jx-staging", source[0].GetNamespace(), "the namespace of the source should not be modified")
+}
+
+func TestCopyFilesNewDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	toDir := filepath.Join(tmpDir, "prod")
+
+	_, o := copy.NewCmdCopy()
+	o.From = filepath.Join("test_data", "staging")
+	o.To = toDir
+	o.Kind = "Deployment,ConfigMap"
+	err = o.Run()
+	require.NoError(t, err, "failed to copy the resources")
+
+	var paths []string
+	for i := range o.Copies {
+		paths = append(paths, o.Copies[i].To)
+	}
+	assert.Equal(t, []string{
+		filepath.Join(toDir, "apps", "myapp-deploy.yaml"),
+		filepath.Join(toDir, "apps", "myapp-config-cm.yaml"),
+		filepath.Join(toDir, "apps", "other-deploy.yaml"),
+	}, paths, "destination files")
+	for _, path := range paths {
+		assert.FileExists(t, path, "copied file")
+	}
+	resources := loadFile(t, paths[1])
+	require.Len(t, resources, 1, "resources in the copied config map file")
+	assert.Equal(t, "jx-staging", resources[0].GetNamespace(), "the namespace should not be changed")
+}
+
+func loadFile(t *testing.T, path string) []*unstructured.Unstructured {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load file %s", path)
+	return resources
+}
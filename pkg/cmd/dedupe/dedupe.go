@@ -0,0 +1,326 @@
+package dedupe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Reports the kubernetes resources in the given directory tree which are defined more than once
+
+		Resources are identified by their apiVersion, kind, namespace and name. Identical duplicates can be removed with --fix which keeps the first occurrence and removes any files left empty. Conflicting definitions of the same resource are always reported as an error listing the fields which differ and no files are modified.
+
+		The exit code is 0 if there are no duplicates, 2 if there are identical duplicates, which have been removed if --fix is specified, 3 if there are conflicting definitions and 1 for any other error.
+`)
+
+	cmdExample = templates.Examples(`
+		# reports the duplicate resources in the current directory
+		%s dedupe --dir .
+
+		# removes the identical duplicate resources
+		%s dedupe --dir config-root --fix
+	`)
+)
+
+const (
+	// ExitCodeClean the exit code if there are no duplicates
+	ExitCodeClean = 0
+
+	// ExitCodeError the exit code of any other error
+	ExitCodeError = 1
+
+	// ExitCodeDuplicates the exit code if there are identical duplicates
+	ExitCodeDuplicates = 2
+
+	// ExitCodeConflicts the exit code if there are conflicting definitions
+	ExitCodeConflicts = 3
+
+	// maxValueLength the maximum length of the values in a field difference
+	maxValueLength = 60
+)
+
+// Occurrence a document defining a resource
+type Occurrence struct {
+	// File the file containing the document relative to the directory
+	File string
+
+	// Document the index of the document in the file
+	Document int
+
+	path string
+	u    *unstructured.Unstructured
+}
+
+// String returns the file and position of the document
+func (o Occurrence) String() string {
+	return fmt.Sprintf("%s (document %d)", o.File, o.Document+1)
+}
+
+// Duplicate a resource which is defined more than once
+type Duplicate struct {
+	// Resource the apiVersion, kind, namespace and name of the resource
+	Resource string
+
+	// Occurrences the documents defining the resource in the order they were found
+	Occurrences []Occurrence
+
+	// Differences the fields which differ from the first occurrence. Empty if the occurrences are identical
+	Differences []string
+}
+
+// Conflict returns true if the occurrences are not identical
+func (d *Duplicate) Conflict() bool {
+	return len(d.Differences) > 0
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir        string
+	Fix        bool
+	Duplicates []*Duplicate
+	Removed    int
+}
+
+// NewCmdDedupe creates a command object for the command
+func NewCmdDedupe() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "dedupe",
+		Short:   "Reports the kubernetes resources in the given directory tree which are defined more than once",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			code := o.ExitCode(err)
+			if code != ExitCodeClean {
+				msg := ""
+				if err != nil {
+					msg = "error: " + err.Error()
+				}
+				helper.Fatal(msg, code)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Fix, "fix", "", false, "removes the identical duplicates keeping the first occurrence")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.Duplicates = nil
+	o.Removed = 0
+	var keys []string
+	occurrences := map[string][]Occurrence{}
+	visitFn := func(u *unstructured.Unstructured, path string, index int) error {
+		key := resourceKey(u)
+		if occurrences[key] == nil {
+			keys = append(keys, key)
+		}
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		occurrences[key] = append(occurrences[key], Occurrence{File: filepath.ToSlash(rel), Document: index, path: path, u: u})
+		return nil
+	}
+	err := resourcehelpers.VisitDocuments(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the resources in dir %s", o.Dir)
+	}
+
+	var conflicts []string
+	for _, key := range keys {
+		list := occurrences[key]
+		if len(list) < 2 {
+			continue
+		}
+		d := &Duplicate{Resource: key, Occurrences: list}
+		for _, other := range list[1:] {
+			for _, diff := range Diff(list[0].u.Object, other.u.Object) {
+				d.Differences = append(d.Differences, fmt.Sprintf("%s: %s", other.String(), diff))
+			}
+		}
+		o.Duplicates = append(o.Duplicates, d)
+
+		var locations []string
+		for _, oc := range list {
+			locations = append(locations, oc.String())
+		}
+		if d.Conflict() {
+			log.Logger().Warnf("conflicting definitions of %s in %s", termcolor.ColorWarning(key), strings.Join(locations, ", "))
+			conflicts = append(conflicts, fmt.Sprintf("%s differs from %s in %s", key, list[0].String(), strings.Join(d.Differences, "; ")))
+			continue
+		}
+		log.Logger().Infof("identical definitions of %s in %s", termcolor.ColorInfo(key), strings.Join(locations, ", "))
+	}
+	if len(conflicts) > 0 {
+		return errors.Errorf("found %d resources with conflicting definitions: %s", len(conflicts), strings.Join(conflicts, ", "))
+	}
+	if len(o.Duplicates) == 0 {
+		log.Logger().Infof("no duplicate resources found")
+		return nil
+	}
+	if !o.Fix {
+		log.Logger().Infof("use --fix to remove the %d identical duplicate resources", len(o.Duplicates))
+		return nil
+	}
+	return o.removeDuplicates()
+}
+
+// ExitCode returns the exit code of the command for the error returned by Run
+func (o *Options) ExitCode(err error) int {
+	for _, d := range o.Duplicates {
+		if d.Conflict() {
+			return ExitCodeConflicts
+		}
+	}
+	switch {
+	case err != nil:
+		return ExitCodeError
+	case len(o.Duplicates) > 0:
+		return ExitCodeDuplicates
+	default:
+		return ExitCodeClean
+	}
+}
+
+// removeDuplicates removes all but the first occurrence of the duplicates
+func (o *Options) removeDuplicates() error {
+	removeDocs := map[string]map[int]bool{}
+	var paths []string
+	for _, d := range o.Duplicates {
+		for _, oc := range d.Occurrences[1:] {
+			if removeDocs[oc.path] == nil {
+				removeDocs[oc.path] = map[int]bool{}
+				paths = append(paths, oc.path)
+			}
+			removeDocs[oc.path][oc.Document] = true
+		}
+	}
+	for _, path := range paths {
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		var answer []*unstructured.Unstructured
+		for i, u := range resources {
+			if removeDocs[path][i] {
+				o.Removed++
+				continue
+			}
+			answer = append(answer, u)
+		}
+		if len(answer) == 0 {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+			log.Logger().Infof("removed file %s", termcolor.ColorInfo(path))
+			continue
+		}
+		err = resourcehelpers.SaveFile(answer, path)
+		if err != nil {
+			return err
+		}
+	}
+	log.Logger().Infof("removed %d duplicate resources", o.Removed)
+	return nil
+}
+
+// Diff returns the differences between the two objects as the dot separated path of each field which differs
+// followed by the values
+func Diff(a, b interface{}) []string {
+	var answer []string
+	diffValues("", a, b, &answer)
+	return answer
+}
+
+func diffValues(path string, a, b interface{}, answer *[]string) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		keys := map[string]bool{}
+		for k := range am {
+			keys[k] = true
+		}
+		for k := range bm {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			av, aFound := am[k]
+			bv, bFound := bm[k]
+			switch {
+			case !aFound:
+				*answer = append(*answer, fmt.Sprintf("%s: <missing> != %s", childPath, valueText(bv)))
+			case !bFound:
+				*answer = append(*answer, fmt.Sprintf("%s: %s != <missing>", childPath, valueText(av)))
+			default:
+				diffValues(childPath, av, bv, answer)
+			}
+		}
+		return
+	}
+	al, aok := a.([]interface{})
+	bl, bok := b.([]interface{})
+	if aok && bok && len(al) == len(bl) {
+		for i := range al {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), al[i], bl[i], answer)
+		}
+		return
+	}
+	*answer = append(*answer, fmt.Sprintf("%s: %s != %s", path, valueText(a), valueText(b)))
+}
+
+// valueText returns the value as compact JSON truncating long values
+func valueText(value interface{}) string {
+	data, err := json.Marshal(value)
+	text := string(data)
+	if err != nil {
+		text = fmt.Sprintf("%v", value)
+	}
+	if len(text) > maxValueLength {
+		text = text[:maxValueLength-3] + "..."
+	}
+	return text
+}
+
+// resourceKey returns the apiVersion, kind, namespace and name of the resource
+func resourceKey(u *unstructured.Unstructured) string {
+	parts := []string{u.GetAPIVersion(), u.GetKind()}
+	if u.GetNamespace() != "" {
+		parts = append(parts, u.GetNamespace())
+	}
+	return strings.Join(append(parts, u.GetName()), "/")
+}
@@ -0,0 +1,74 @@
+package dedupe_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/dedupe"
+	gitopstesthelpers "github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupeIdentical(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "identical"))
+
+	_, o := dedupe.NewCmdDedupe()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to find the duplicates")
+	require.Len(t, o.Duplicates, 2, "duplicates")
+	assert.Equal(t, "v1/ConfigMap/jx/shared-config", o.Duplicates[0].Resource, "first duplicate")
+	assert.Equal(t, "chart-a/resources.yaml (document 1)", o.Duplicates[0].Occurrences[0].String(), "first occurrence")
+	assert.Equal(t, "chart-b/resources.yaml (document 1)", o.Duplicates[0].Occurrences[1].String(), "second occurrence")
+	assert.Equal(t, "rbac.authorization.k8s.io/v1/ClusterRole/reader", o.Duplicates[1].Resource, "second duplicate")
+	assert.False(t, o.Duplicates[1].Conflict(), "identical duplicates should not conflict")
+	assert.Equal(t, dedupe.ExitCodeDuplicates, o.ExitCode(err), "exit code")
+	assert.FileExists(t, filepath.Join(tmpDir, "chart-b", "role.yaml"), "files should not be modified without --fix")
+
+	o.Fix = true
+	err = o.Run()
+	require.NoError(t, err, "failed to remove the duplicates")
+	assert.Equal(t, 2, o.Removed, "removed resources")
+	assert.Equal(t, dedupe.ExitCodeDuplicates, o.ExitCode(err), "exit code")
+	testhelpers.AssertTextFilesEqual(t, filepath.Join("test_data", "expected-resources.yaml"), filepath.Join(tmpDir, "chart-b", "resources.yaml"), "resources.yaml")
+	testhelpers.AssertTextFilesEqual(t, filepath.Join("test_data", "expected-role.yaml"), filepath.Join(tmpDir, "chart-b", "role.yaml"), "role.yaml")
+
+	// the tree should now be clean
+	err = o.Run()
+	require.NoError(t, err, "failed to find the duplicates again")
+	assert.Empty(t, o.Duplicates, "duplicates after the fix")
+	assert.Equal(t, dedupe.ExitCodeClean, o.ExitCode(err), "exit code")
+}
+
+func TestDedupeConflicts(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "conflict"))
+	path := filepath.Join(tmpDir, "b.yaml")
+	original, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+
+	_, o := dedupe.NewCmdDedupe()
+	o.Dir = tmpDir
+	o.Fix = true
+	err = o.Run()
+	require.Error(t, err, "should fail with conflicting definitions")
+	assert.Equal(t, dedupe.ExitCodeConflicts, o.ExitCode(err), "exit code")
+	require.Len(t, o.Duplicates, 2, "duplicates")
+
+	d := o.Duplicates[0]
+	assert.Equal(t, "apps/v1/Deployment/jx/web", d.Resource, "conflicting resource")
+	assert.True(t, d.Conflict(), "should conflict")
+	assert.Equal(t, []string{
+		`b.yaml (document 2): metadata.labels: <missing> != {"app":"web"}`,
+		`b.yaml (document 2): spec.replicas: 1 != 2`,
+		`b.yaml (document 2): spec.template.spec.containers[0].image: "nginx:1.21" != "nginx:1.22"`,
+	}, d.Differences, "differences")
+	assert.Contains(t, err.Error(), "spec.replicas: 1 != 2", "the error should include the differences")
+	assert.False(t, o.Duplicates[1].Conflict(), "the identical services should not conflict")
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, string(original), string(data), "no files should be modified if there are conflicts")
+}
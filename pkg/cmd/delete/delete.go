@@ -0,0 +1,162 @@
+package delete
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Deletes the kubernetes resources matching the selector from the YAML files in the given directory tree
+
+		Each matching document is removed from its file leaving the text of the other documents in the file untouched. Files which no longer contain any documents are deleted. The names can contain wildcards such as 'web*' and resources can be matched by their labels via --selector. At least one of --kind, --name, --namespace or --selector must be specified so that every resource is not deleted by mistake.
+
+		The command fails if no resources match unless --allow-missing is specified. Use --dry-run to list the resources which would be deleted without modifying any files.
+`)
+
+	cmdExample = templates.Examples(`
+		# deletes the ConfigMap named foo in namespace bar
+		%s delete --dir . --kind ConfigMap --name foo --namespace bar
+
+		# lists the resources of an app which would be deleted
+		%s delete --dir config-root --name 'myapp*' --selector app=myapp --dry-run
+
+		# deletes a resource which may have already been deleted
+		%s delete --kind Secret --name old-token --allow-missing
+	`)
+)
+
+// Deletion a resource which was deleted from a file
+type Deletion struct {
+	// File the file containing the resource relative to the directory
+	File string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Document the index of the document of the resource in the file
+	Document int
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir          string
+	DryRun       bool
+	AllowMissing bool
+	Deletions    []Deletion
+	DeletedFiles []string
+}
+
+// NewCmdDelete creates a command object for the command
+func NewCmdDelete() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Short:   "Deletes the kubernetes resources matching the selector from the YAML files in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "lists the resources which would be deleted without modifying any files")
+	cmd.Flags().BoolVarP(&o.AllowMissing, "allow-missing", "", false, "does not fail if no resources match")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if len(o.Kinds) == 0 && len(o.Names) == 0 && len(o.Namespaces) == 0 && len(o.Labels) == 0 {
+		return errors.Errorf("must specify at least one of --kind, --name, --namespace or --selector")
+	}
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	o.Deletions = nil
+	o.DeletedFiles = nil
+
+	documents := map[string]map[int]bool{}
+	var paths []string
+	err = resourcehelpers.VisitDocuments(o.Dir, o.Selector, func(u *unstructured.Unstructured, path string, index int) error {
+		if documents[path] == nil {
+			documents[path] = map[int]bool{}
+			paths = append(paths, path)
+		}
+		documents[path][index] = true
+		o.Deletions = append(o.Deletions, Deletion{
+			File:     o.relPath(path),
+			Resource: resourcehelpers.ResourceKey(u),
+			Document: index,
+		})
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the resources to delete in dir %s", o.Dir)
+	}
+	if len(o.Deletions) == 0 {
+		if o.AllowMissing {
+			log.Logger().Infof("no resources matched in dir %s", o.Dir)
+			return nil
+		}
+		return errors.Errorf("no resources matched in dir %s", o.Dir)
+	}
+
+	verb := "deleted"
+	if o.DryRun {
+		verb = "would delete"
+	}
+	for _, d := range o.Deletions {
+		log.Logger().Infof("%s %s from file %s", verb, info(d.Resource), d.File)
+	}
+	if o.DryRun {
+		log.Logger().Infof("would delete %d resources from %d files", len(o.Deletions), len(paths))
+		return nil
+	}
+
+	for _, path := range paths {
+		removed, err := resourcehelpers.RemoveDocuments(path, documents[path])
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete the resources from file %s", path)
+		}
+		if removed {
+			o.DeletedFiles = append(o.DeletedFiles, o.relPath(path))
+			log.Logger().Infof("deleted empty file %s", info(o.relPath(path)))
+		}
+	}
+	log.Logger().Infof("deleted %d resources from %d files", len(o.Deletions), len(paths))
+	return nil
+}
+
+func (o *Options) relPath(path string) string {
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
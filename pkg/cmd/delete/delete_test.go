@@ -0,0 +1,108 @@
+package delete_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/delete"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := delete.NewCmdDelete()
+	o.Dir = tmpDir
+	o.Kinds = []string{"ConfigMap"}
+	o.Names = []string{"foo"}
+	o.Namespaces = []string{"bar"}
+	err := o.Run()
+	require.NoError(t, err, "failed to delete the resources")
+
+	assert.Equal(t, []delete.Deletion{
+		{File: "configmaps.yaml", Resource: "bar/ConfigMap/foo", Document: 0},
+	}, o.Deletions, "deletions")
+	assert.Empty(t, o.DeletedFiles, "deleted files")
+
+	// the comments and key order of the other documents should be untouched
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-configmaps.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "secret.yaml"), filepath.Join(tmpDir, "secret.yaml"))
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "apps", "web.yaml"), filepath.Join(tmpDir, "apps", "web.yaml"))
+}
+
+func TestDeleteNameGlobAndSelector(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := delete.NewCmdDelete()
+	o.Dir = tmpDir
+	o.Names = []string{"foo*"}
+	o.Labels = map[string]string{"app": "extra"}
+	err := o.Run()
+	require.NoError(t, err, "failed to delete the resources")
+
+	assert.Equal(t, []delete.Deletion{
+		{File: "configmaps.yaml", Resource: "bar/ConfigMap/foo-extra", Document: 2},
+	}, o.Deletions, "deletions")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-configmaps-selector.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+}
+
+func TestDeleteRemovesEmptyFiles(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := delete.NewCmdDelete()
+	o.Dir = tmpDir
+	o.Namespaces = []string{"bar"}
+	o.Kinds = []string{"Secret", "Deployment"}
+	err := o.Run()
+	require.NoError(t, err, "failed to delete the resources")
+
+	assert.Equal(t, []delete.Deletion{
+		{File: "apps/web.yaml", Resource: "bar/Deployment/web", Document: 0},
+		{File: "secret.yaml", Resource: "bar/Secret/old-token", Document: 0},
+	}, o.Deletions, "deletions")
+	assert.Equal(t, []string{"apps/web.yaml", "secret.yaml"}, o.DeletedFiles, "deleted files")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "secret.yaml"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "apps", "web.yaml"))
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "configmaps.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := delete.NewCmdDelete()
+	o.Dir = tmpDir
+	o.Names = []string{"foo*"}
+	o.DryRun = true
+	err := o.Run()
+	require.NoError(t, err, "failed to list the resources to delete")
+
+	assert.Len(t, o.Deletions, 3, "deletions")
+	assert.Empty(t, o.DeletedFiles, "deleted files")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "configmaps.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+}
+
+func TestDeleteMissing(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := delete.NewCmdDelete()
+	o.Dir = tmpDir
+	o.Kinds = []string{"ConfigMap"}
+	o.Names = []string{"does-not-exist"}
+	err := o.Run()
+	require.Error(t, err, "should fail if no resources match")
+	t.Logf("got expected error: %s", err.Error())
+
+	o.AllowMissing = true
+	err = o.Run()
+	require.NoError(t, err, "should not fail with --allow-missing")
+	assert.Empty(t, o.Deletions, "deletions")
+
+	// lets make sure every resource cannot be deleted by mistake
+	_, o = delete.NewCmdDelete()
+	o.Dir = tmpDir
+	err = o.Run()
+	require.Error(t, err, "should fail without a selector")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "configmaps.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+}
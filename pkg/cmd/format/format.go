@@ -0,0 +1,257 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Formats the kubernetes resources in the given directory tree as canonical YAML so that diffs only show real changes
+
+		Each document is written with a two space indent with the apiVersion, kind and metadata fields first followed by the other fields in alphabetical order. Strings are only quoted if they would otherwise be read as some other type, multi line strings use the literal block style, comments are kept and the documents are separated by '---'.
+
+		Files which cannot be parsed or which contain documents which are not kubernetes resources, such as helm templates or values files, are skipped. If --check is specified the files which are not canonical are listed and the command fails without modifying any files.
+`)
+
+	cmdExample = templates.Examples(`
+		# formats the resources in the current directory
+		%s format --dir .
+
+		# fails if any of the resources are not formatted such as in a CI pipeline
+		%s format --dir config-root --check
+	`)
+
+	// firstFields the fields of a resource which come before the other fields
+	firstFields = []string{"apiVersion", "kind", "metadata"}
+)
+
+// Options the options for the command
+type Options struct {
+	Dir   string
+	Check bool
+
+	// Files the files which are not canonical
+	Files []string
+}
+
+// NewCmdFormat creates a command object for the command
+func NewCmdFormat() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "format",
+		Aliases: []string{"fmt"},
+		Short:   "Formats the kubernetes resources in the given directory tree as canonical YAML so that diffs only show real changes",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Check, "check", "", false, "lists the files which are not canonical and fails if there are any without modifying any files")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.Files = nil
+	err := filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load file %s", path)
+		}
+		formatted, err := Format(data)
+		if err != nil {
+			log.Logger().Debugf("skipping file %s: %s", path, err.Error())
+			return nil
+		}
+		if bytes.Equal(data, formatted) {
+			return nil
+		}
+		o.Files = append(o.Files, path)
+		if o.Check {
+			return nil
+		}
+		err = ioutil.WriteFile(path, formatted, files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to format the files in dir %s", o.Dir)
+	}
+
+	if o.Check {
+		for _, f := range o.Files {
+			log.Logger().Infof("file %s is not formatted", termcolor.ColorInfo(f))
+		}
+		if len(o.Files) > 0 {
+			return errors.Errorf("%d files are not formatted. Run '%s format --dir %s' to format them", len(o.Files), rootcmd.BinaryName, o.Dir)
+		}
+		return nil
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("formatted file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("formatted %d files", len(o.Files))
+	return nil
+}
+
+// Format returns the canonical YAML of the kubernetes resources. Fails if the data cannot be parsed or if any
+// document is not a kubernetes resource
+func Format(data []byte) ([]byte, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse YAML document %d", i)
+		}
+		if isEmpty(doc) {
+			// lets drop empty documents such as from repeated separators
+			continue
+		}
+		if len(doc.Content) == 0 || !isResource(doc.Content[0]) {
+			return nil, errors.Errorf("YAML document %d is not a kubernetes resource", i)
+		}
+		canonicalise(doc.Content[0], true)
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	for i, doc := range docs {
+		err := encoder.Encode(doc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal YAML document %d", i+1)
+		}
+	}
+	err := encoder.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal YAML")
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalise sorts the fields of the maps and resets the quoting and flow styles of the node and its children
+func canonicalise(node *yaml.Node, top bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		node.Style = 0
+		sortFields(node, top)
+		for _, child := range node.Content {
+			canonicalise(child, false)
+		}
+	case yaml.SequenceNode:
+		node.Style = 0
+		for _, child := range node.Content {
+			canonicalise(child, false)
+		}
+	case yaml.ScalarNode:
+		// the encoder quotes any strings which would otherwise be read as some other type
+		node.Style &= yaml.TaggedStyle
+	}
+}
+
+// sortFields sorts the fields of the map alphabetically putting the firstFields first for the top level map
+func sortFields(node *yaml.Node, top bool) {
+	type field struct {
+		key   *yaml.Node
+		value *yaml.Node
+	}
+	var fields []field
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fields = append(fields, field{key: node.Content[i], value: node.Content[i+1]})
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	// lets keep any comment at the start of the map at the start
+	headComment := fields[0].key.HeadComment
+	fields[0].key.HeadComment = ""
+	rank := func(f field) int {
+		if top {
+			for i, name := range firstFields {
+				if f.key.Value == name {
+					return i
+				}
+			}
+		}
+		return len(firstFields)
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		ri, rj := rank(fields[i]), rank(fields[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return fields[i].key.Value < fields[j].key.Value
+	})
+	if headComment != "" {
+		if fields[0].key.HeadComment != "" {
+			headComment += "\n" + fields[0].key.HeadComment
+		}
+		fields[0].key.HeadComment = headComment
+	}
+	node.Content = node.Content[:0]
+	for _, f := range fields {
+		node.Content = append(node.Content, f.key, f.value)
+	}
+}
+
+// isResource returns true if the node is a map with an apiVersion and kind
+func isResource(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	found := 0
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i].Value
+		if (k == "apiVersion" || k == "kind") && node.Content[i+1].Kind == yaml.ScalarNode && node.Content[i+1].Value != "" {
+			found++
+		}
+	}
+	return found == 2
+}
+
+// isEmpty returns true if the document has no content or comments
+func isEmpty(doc *yaml.Node) bool {
+	if doc.HeadComment != "" || doc.FootComment != "" {
+		return false
+	}
+	if len(doc.Content) == 0 {
+		return true
+	}
+	node := doc.Content[0]
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.HeadComment == "" && node.LineComment == "" && node.FootComment == ""
+}
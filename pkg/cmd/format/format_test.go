@@ -0,0 +1,66 @@
+package format_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/format"
+	gitopstesthelpers "github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := format.NewCmdFormat()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to format")
+	assert.Equal(t, []string{filepath.Join(tmpDir, "deployment.yml")}, o.Files, "formatted files")
+	gitopstesthelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected.yaml"), filepath.Join(tmpDir, "deployment.yml"))
+
+	// the files which are not kubernetes resources should be left alone
+	for _, name := range []string{"formatted.yaml", "helmfile.yaml", "values.yaml"} {
+		testhelpers.AssertTextFilesEqual(t, filepath.Join("test_data", "src", name), filepath.Join(tmpDir, name), name)
+	}
+
+	// formatting again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to format again")
+	assert.Empty(t, o.Files, "formatted files on the second run")
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "deployment.yml"))
+	require.NoError(t, err, "failed to load deployment.yml")
+	formatted, err := format.Format(data)
+	require.NoError(t, err, "failed to format deployment.yml")
+	assert.Equal(t, string(data), string(formatted), "formatting should be idempotent")
+}
+
+func TestFormatCheck(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+	path := filepath.Join(tmpDir, "deployment.yml")
+	original, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+
+	_, o := format.NewCmdFormat()
+	o.Dir = tmpDir
+	o.Check = true
+	err = o.Run()
+	require.Error(t, err, "should fail as a file is not formatted")
+	assert.Equal(t, []string{path}, o.Files, "files which are not formatted")
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, string(original), string(data), "the file should not be modified with --check")
+
+	o.Check = false
+	err = o.Run()
+	require.NoError(t, err, "failed to format")
+
+	o.Check = true
+	err = o.Run()
+	require.NoError(t, err, "should not fail once the files are formatted")
+}
@@ -0,0 +1,156 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ConfigMapHashPrefix the prefix of the pod template annotations containing the hash of a ConfigMap
+	ConfigMapHashPrefix = "gitops.jenkins-x.io/config-hash."
+
+	// SecretHashPrefix the prefix of the pod template annotations containing the hash of a Secret
+	SecretHashPrefix = "gitops.jenkins-x.io/secret-hash."
+)
+
+var (
+	// hashPrefixes the annotation prefixes indexed by the kind of the referenced resource
+	hashPrefixes = map[string]string{
+		"ConfigMap": ConfigMapHashPrefix,
+		"Secret":    SecretHashPrefix,
+	}
+
+	// dataFields the fields of the ConfigMaps and Secrets which are hashed
+	dataFields = []string{"data", "binaryData", "stringData"}
+)
+
+// hashConfigs annotates the pod template of each workload with the hashes of the ConfigMaps and Secrets it uses
+func (o *Options) hashConfigs() error {
+	hashes := map[string]string{}
+	err := resourcehelpers.VisitFiles(o.Dir, resourcehelpers.Selector{Kinds: []string{"ConfigMap", "Secret"}}, func(u *unstructured.Unstructured, path string) error {
+		hash, err := ConfigHash(u)
+		if err != nil {
+			return errors.Wrapf(err, "failed to hash %s in file %s", resourcehelpers.ResourceKey(u), path)
+		}
+		hashes[configKey(u.GetKind(), u.GetNamespace(), u.GetName())] = hash
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the ConfigMaps and Secrets in dir %s", o.Dir)
+	}
+
+	o.Annotated = nil
+	o.Missing = nil
+	err = resourcehelpers.ModifyFiles(o.Dir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) (bool, error) {
+		podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+		if len(podSpecPath) < 2 {
+			return false, nil
+		}
+		annotations := map[string]string{}
+		for _, ref := range validaterefs.AllReferences(u) {
+			prefix := hashPrefixes[ref.Kind]
+			if prefix == "" || strings.HasSuffix(ref.Field, "imagePullSecrets.name") {
+				continue
+			}
+			ns := ref.Namespace
+			if ns == "" {
+				ns = u.GetNamespace()
+			}
+			hash, ok := hashes[configKey(ref.Kind, ns, ref.Name)]
+			if !ok {
+				if !ref.Optional {
+					ref.File = path
+					o.Missing = append(o.Missing, ref)
+					log.Logger().Warnf("could not find %s %s referenced by %s in file %s", ref.Kind, termcolor.ColorWarning(ref.Name), resourcehelpers.ResourceKey(u), path)
+				}
+				continue
+			}
+			annotations[prefix+ref.Name] = hash
+		}
+		fieldPath := append(append([]string{}, podSpecPath[:len(podSpecPath)-1]...), "metadata", "annotations")
+		modified, err := setHashAnnotations(u, fieldPath, annotations)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to annotate %s", resourcehelpers.ResourceKey(u))
+		}
+		if modified {
+			o.Annotated = append(o.Annotated, resourcehelpers.ResourceKey(u))
+		}
+		return modified, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate the workloads in dir %s", o.Dir)
+	}
+	log.Logger().Infof("updated the config hashes of %s workloads in dir %s", termcolor.ColorInfo(fmt.Sprintf("%d", len(o.Annotated))), o.Dir)
+	return nil
+}
+
+// ConfigHash returns the sha256 hash of the data of the ConfigMap or Secret ignoring its metadata
+func ConfigHash(u *unstructured.Unstructured) (string, error) {
+	values := map[string]interface{}{}
+	for _, f := range dataFields {
+		if v, ok := u.Object[f]; ok && v != nil {
+			values[f] = v
+		}
+	}
+	// the keys of maps are sorted when marshalled so the hash does not depend on the order of the YAML
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal the data")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// setHashAnnotations sets the hash annotations at the given path removing any stale hash annotations of
+// resources which are no longer referenced. Returns true if the annotations were modified
+func setHashAnnotations(u *unstructured.Unstructured, fieldPath []string, hashes map[string]string) (bool, error) {
+	annotations, _, err := unstructured.NestedStringMap(u.Object, fieldPath...)
+	if err != nil {
+		return false, err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	modified := false
+	for k := range annotations {
+		if _, ok := hashes[k]; !ok && isHashAnnotation(k) {
+			delete(annotations, k)
+			modified = true
+		}
+	}
+	for k, v := range hashes {
+		if annotations[k] != v {
+			annotations[k] = v
+			modified = true
+		}
+	}
+	if !modified {
+		return false, nil
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(u.Object, fieldPath...)
+		return true, nil
+	}
+	return true, unstructured.SetNestedStringMap(u.Object, annotations, fieldPath...)
+}
+
+func isHashAnnotation(key string) bool {
+	for _, prefix := range hashPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func configKey(kind, ns, name string) string {
+	return kind + "/" + ns + "/" + name
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/annotate"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -24,11 +25,16 @@ const DefaultAnnotation = "jenkins-x.io/hash"
 var (
 	cmdLong = templates.LongDesc(`
 		Annotates the given files with a hash of the given source files for ConfigMaps/Secrets
+
+		If no --source files are specified the hash of the data of each ConfigMap and Secret in the directory tree is added to the pod template of every workload which uses it via volumes, projected volumes, env or envFrom. The annotations are 'gitops.jenkins-x.io/config-hash.<name>' for ConfigMaps and 'gitops.jenkins-x.io/secret-hash.<name>' for Secrets so that any change to the configuration changes the pod template and so rolls out the workload. The annotations of resources which are no longer used are removed and a warning is logged for each reference to a resource which is not in the tree. The --kind and --kind-ignore flags only apply to the --source files.
 `)
 
 	cmdExample = templates.Examples(`
 		# annotates the Deployments in a dir from some source ConfigMaps
 		%s hash -s foo/configmap.yaml -s another/configmap.yaml -d someDir
+
+		# annotates the workloads with the hashes of the ConfigMaps and Secrets they use
+		%s hash --dir .
 	`)
 )
 
@@ -38,6 +44,8 @@ type Options struct {
 	Annotation  string
 	SourceFiles []string
 	Filter      kyamls.Filter
+	Annotated   []string
+	Missing     []validaterefs.ResourceReference
 }
 
 // NewCmdHashAnnotate creates a command object for the command
@@ -48,7 +56,7 @@ func NewCmdHashAnnotate() (*cobra.Command, *Options) {
 		Use:     "hash",
 		Short:   "Annotates the given files with a hash of the given source files for ConfigMaps/Secrets",
 		Long:    cmdLong,
-		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
@@ -67,13 +75,13 @@ func NewCmdHashAnnotate() (*cobra.Command, *Options) {
 
 // Run run the command
 func (o *Options) Run() error {
+	if len(o.SourceFiles) == 0 {
+		return o.hashConfigs()
+	}
 	if o.Annotation == "" {
 		return options.MissingOption("annotation")
 
 	}
-	if len(o.SourceFiles) == 0 {
-		return options.MissingOption("source")
-	}
 	buff := bytes.Buffer{}
 	for _, s := range o.SourceFiles {
 		exists, err := files.FileExists(s)
@@ -3,6 +3,7 @@ package hash_test
 import (
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -46,4 +47,90 @@ func TestUpdateAnnotatesInYamlFiles(t *testing.T) {
 	require.NotEmpty(t, value, "no annotation %s found on file %s", hash.DefaultAnnotation, outFile)
 
 	t.Logf("found annotation %s value: %s on file %s\n", hash.DefaultAnnotation, value, outFile)
+
+	// lets change a source file and check the hash is replaced so that the deployment rolls out
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err = files.CopyFile(filepath.Join(sourceDir, "config.yaml"), configFile)
+	require.NoError(t, err, "failed to copy %s", configFile)
+	data, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err, "failed to load %s", configFile)
+	err = ioutil.WriteFile(configFile, append(data, []byte("# changed\n")...), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", configFile)
+	ho.SourceFiles[0] = configFile
+
+	err = ho.Run()
+	require.NoError(t, err, "failed to hash the changed source files")
+
+	deploy = appsv1.Deployment{}
+	err = yamls.LoadFile(outFile, &deploy)
+	require.NoError(t, err, "failed to load YAML file %s", outFile)
+	newValue := deploy.Annotations[hash.DefaultAnnotation]
+	assert.NotEmpty(t, newValue, "no annotation %s found on file %s after changing the source", hash.DefaultAnnotation, outFile)
+	assert.NotEqual(t, value, newValue, "the annotation %s should be replaced after changing the source", hash.DefaultAnnotation)
+}
+
+func TestHashConfigs(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("test_data", "workloads"), tmpDir)
+	require.NoError(t, err, "failed to copy test data to %s", tmpDir)
+
+	_, ho := hash.NewCmdHashAnnotate()
+	ho.Dir = tmpDir
+	err = ho.Run()
+	require.NoError(t, err, "failed to hash the configs")
+
+	assert.Equal(t, []string{"jx/CronJob/cleanup", "jx/Deployment/app"}, ho.Annotated, "annotated workloads")
+	require.Len(t, ho.Missing, 1, "missing references")
+	assert.Equal(t, "feature-flags", ho.Missing[0].Name, "missing reference")
+
+	deploy := loadDeployment(t, filepath.Join(tmpDir, "deployment.yaml"))
+	annotations := deploy.Spec.Template.Annotations
+	configHash := annotations[hash.ConfigMapHashPrefix+"app-config"]
+	assert.Len(t, configHash, 64, "config hash")
+	assert.NotEmpty(t, annotations[hash.ConfigMapHashPrefix+"projected-config"], "projected config hash")
+	assert.NotEmpty(t, annotations[hash.SecretHashPrefix+"app-secret"], "secret hash")
+	assert.NotContains(t, annotations, hash.ConfigMapHashPrefix+"old-config", "the stale hash should be removed")
+	assert.NotContains(t, annotations, hash.ConfigMapHashPrefix+"optional-config", "the missing optional config should be ignored")
+	assert.NotContains(t, annotations, hash.SecretHashPrefix+"registry-secret", "the image pull secret should be ignored")
+	assert.Equal(t, "true", annotations["prometheus.io/scrape"], "other annotations should be kept")
+	assert.Empty(t, deploy.Annotations, "the deployment metadata should not be annotated")
+
+	// lets check running again does not change anything
+	before := readFiles(t, tmpDir)
+	err = ho.Run()
+	require.NoError(t, err, "failed to hash the configs again")
+	assert.Empty(t, ho.Annotated, "annotated workloads on the second run")
+	assert.Equal(t, before, readFiles(t, tmpDir), "files after the second run")
+
+	// lets change the config
+	configFile := filepath.Join(tmpDir, "configmap.yaml")
+	data, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err, "failed to load %s", configFile)
+	err = ioutil.WriteFile(configFile, []byte(strings.Replace(string(data), "log.level: info", "log.level: debug", 1)), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", configFile)
+
+	err = ho.Run()
+	require.NoError(t, err, "failed to hash the changed configs")
+	assert.Len(t, ho.Annotated, 2, "the workloads using the changed config should be annotated")
+	deploy = loadDeployment(t, filepath.Join(tmpDir, "deployment.yaml"))
+	assert.NotEqual(t, configHash, deploy.Spec.Template.Annotations[hash.ConfigMapHashPrefix+"app-config"], "config hash after the change")
+}
+
+func loadDeployment(t *testing.T, path string) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{}
+	err := yamls.LoadFile(path, deploy)
+	require.NoError(t, err, "failed to load YAML file %s", path)
+	return deploy
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	answer := map[string]string{}
+	fileInfos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err, "failed to read dir %s", dir)
+	for _, f := range fileInfos {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err, "failed to load %s", f.Name())
+		answer[f.Name()] = string(data)
+	}
+	return answer
 }
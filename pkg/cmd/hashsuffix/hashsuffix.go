@@ -0,0 +1,461 @@
+package hashsuffix
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/hash"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/renamer"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// Annotation the annotation with the value 'true' which opts a ConfigMap or Secret into having a hash suffix
+	Annotation = "gitops.jenkins-x.io/hash-suffix"
+
+	// NameAnnotation the annotation recording the name of a ConfigMap or Secret before its hash suffix was added
+	// so that the suffix is replaced rather than appended when its data changes
+	NameAnnotation = "gitops.jenkins-x.io/hash-suffix-name"
+
+	// HashLength the number of characters of the hash of the data used as the suffix
+	HashLength = 8
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Appends a hash of the data to the names of the ConfigMaps and Secrets in the given directory tree and rewrites the references to them
+
+		Each ConfigMap and Secret with the annotation '` + Annotation + `: "true"', or every one if --all is specified, is renamed to '<name>-<hash>' where the hash is the first 8 characters of the sha256 hash of its data. Any change to the data changes the name and so rolls out the workloads which use it like the kustomize configMapGenerator. The resources can then be made immutable.
+
+		The references to the renamed resources from all the resources in the tree are rewritten. These are the volumes, projected volumes, env valueFrom, envFrom and imagePullSecrets of the pods of all the workload kinds and the TLS secretName of Ingresses.
+
+		The original name is recorded in the '` + NameAnnotation + `' annotation so that the suffix of a resource renamed by a previous run is replaced rather than appended to when its data changes and references to the original name, such as from regenerated resources, are rewritten too. Copies with an older suffix which are no longer referenced are removed if --prune is specified.
+`)
+
+	cmdExample = templates.Examples(`
+		# appends the hash suffix to the ConfigMaps and Secrets with the annotation
+		%s hash-suffix --dir .
+
+		# appends the hash suffix to all the ConfigMaps and removes the unreferenced copies from previous runs
+		%s hash-suffix --dir config-root --all --kind ConfigMap --prune
+	`)
+
+	// Kinds the kinds of resource which are renamed
+	Kinds = []string{"ConfigMap", "Secret"}
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir        string
+	All        bool
+	Prune      bool
+	Renames    []renamer.Rename
+	References []validaterefs.ResourceReference
+	Replaced   []string
+	Pruned     []string
+}
+
+// config a ConfigMap or Secret which has a hash suffix
+type config struct {
+	u     *unstructured.Unstructured
+	path  string
+	index int
+
+	// base the name without the hash suffix
+	base string
+
+	// name the name with the hash suffix of the current data
+	name string
+
+	// hashed the resource was renamed by a previous run
+	hashed bool
+
+	// replaced the resource is a copy from a previous run with the same data as the current resource
+	replaced bool
+}
+
+// group the ConfigMaps or Secrets with the same kind, namespace and base name
+type group struct {
+	configs []*config
+
+	// current the ConfigMap or Secret which the references to the base name use
+	current *config
+}
+
+type renamed struct {
+	namespace string
+	name      string
+}
+
+// NewCmdHashSuffix creates a command object for the command
+func NewCmdHashSuffix() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "hash-suffix",
+		Short:   "Appends a hash of the data to the names of the ConfigMaps and Secrets and rewrites the references to them",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.All, "all", "", false, "appends the hash suffix to all the ConfigMaps and Secrets rather than only those with the "+Annotation+" annotation")
+	cmd.Flags().BoolVarP(&o.Prune, "prune", "", false, "removes the copies of the ConfigMaps and Secrets from previous runs which are no longer referenced")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.Renames = nil
+	o.References = nil
+	o.Replaced = nil
+	o.Pruned = nil
+
+	groups, existing, err := o.findConfigs()
+	if err != nil {
+		return err
+	}
+
+	// lets remove the copies from previous runs which the current resources are about to be renamed to
+	removals := map[string]map[int]bool{}
+	for _, g := range groups {
+		if g.current == nil {
+			continue
+		}
+		for _, c := range g.configs {
+			if c != g.current && (c.name == g.current.name || c.u.GetName() == g.current.name) {
+				c.replaced = true
+				if removals[c.path] == nil {
+					removals[c.path] = map[int]bool{}
+				}
+				removals[c.path][c.index] = true
+				o.Replaced = append(o.Replaced, resourcehelpers.ResourceKey(c.u))
+			}
+		}
+	}
+	err = removeDocuments(removals)
+	if err != nil {
+		return err
+	}
+
+	updates, index, err := o.planRenames(groups, existing)
+	if err != nil {
+		return err
+	}
+	lookup := func(kind, ns, name string) string {
+		for _, rn := range index[kind+"/"+name] {
+			// resources without a namespace may be defaulted later so lets treat them as matching
+			if rn.namespace == ns || rn.namespace == "" || ns == "" {
+				return rn.name
+			}
+		}
+		return ""
+	}
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified := false
+		ns := u.GetNamespace()
+		if c := updates[resourcehelpers.ResourceKey(u)]; c != nil {
+			u.SetName(c.name)
+			annotations := u.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[NameAnnotation] = c.base
+			u.SetAnnotations(annotations)
+			modified = true
+		}
+		refs := validaterefs.RenameReferences(u, func(kind, refNs, name string) string {
+			if refNs == "" {
+				refNs = ns
+			}
+			return lookup(kind, refNs, name)
+		})
+		for i := range refs {
+			refs[i].File = o.relPath(path)
+			o.References = append(o.References, refs[i])
+		}
+		return modified || len(refs) > 0, nil
+	}
+	err = resourcehelpers.ModifyFiles(o.Dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to rename the ConfigMaps and Secrets in dir %s", o.Dir)
+	}
+
+	if o.Prune {
+		err = o.prune(groups)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range o.Renames {
+		log.Logger().Infof("renamed %s", info(o.Renames[i].String()))
+	}
+	for _, key := range o.Replaced {
+		log.Logger().Infof("removed %s as it has the same data as the current resource", info(key))
+	}
+	for _, key := range o.Pruned {
+		log.Logger().Infof("pruned %s as it is no longer referenced", info(key))
+	}
+	log.Logger().Infof("renamed %d resources and %d references to them", len(o.Renames), len(o.References))
+	return nil
+}
+
+// findConfigs finds the ConfigMaps and Secrets which have a hash suffix grouped by their base name along with the
+// keys of all the resources in the tree
+func (o *Options) findConfigs() ([]*group, map[string]bool, error) {
+	var groups []*group
+	groupIndex := map[string]*group{}
+	existing := map[string]bool{}
+	err := resourcehelpers.VisitDocuments(o.Dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, func(u *unstructured.Unstructured, path string, index int) error {
+		existing[resourcehelpers.ResourceKey(u)] = true
+		if !isConfig(u) || u.GetName() == "" {
+			return nil
+		}
+		annotations := u.GetAnnotations()
+		base := annotations[NameAnnotation]
+		hashed := base != ""
+		target := u
+		if hashed {
+			// lets match the selector on the name without the suffix so previous copies are selected too
+			target = u.DeepCopy()
+			target.SetName(base)
+		} else {
+			if !o.All && annotations[Annotation] != "true" {
+				return nil
+			}
+			base = u.GetName()
+		}
+		if !o.Selector.Matches(target) {
+			return nil
+		}
+		h, err := hash.ConfigHash(u)
+		if err != nil {
+			return errors.Wrapf(err, "failed to hash %s in file %s", resourcehelpers.ResourceKey(u), path)
+		}
+		c := &config{
+			u:      u,
+			path:   path,
+			index:  index,
+			base:   base,
+			name:   base + "-" + h[:HashLength],
+			hashed: hashed,
+		}
+		key := u.GetKind() + "/" + u.GetNamespace() + "/" + base
+		g := groupIndex[key]
+		if g == nil {
+			g = &group{}
+			groupIndex[key] = g
+			groups = append(groups, g)
+		}
+		g.configs = append(g.configs, c)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to find the ConfigMaps and Secrets in dir %s", o.Dir)
+	}
+
+	// the current resource is the one without a suffix, such as a regenerated resource, or the only copy
+	for _, g := range groups {
+		for _, c := range g.configs {
+			if c.hashed {
+				continue
+			}
+			if g.current != nil {
+				return nil, nil, errors.Errorf("duplicate %s in files %s and %s", resourcehelpers.ResourceKey(c.u), g.current.path, c.path)
+			}
+			g.current = c
+		}
+		if g.current == nil && len(g.configs) == 1 {
+			g.current = g.configs[0]
+		}
+	}
+	return groups, existing, nil
+}
+
+// planRenames returns the resources to update indexed by their key and the new names of the references indexed by
+// the kind and old name
+func (o *Options) planRenames(groups []*group, existing map[string]bool) (map[string]*config, map[string][]renamed, error) {
+	updates := map[string]*config{}
+	index := map[string][]renamed{}
+	add := func(kind, ns, from, to string) {
+		index[kind+"/"+from] = append(index[kind+"/"+from], renamed{namespace: ns, name: to})
+	}
+	for _, g := range groups {
+		for _, c := range g.configs {
+			from := c.u.GetName()
+			if c.replaced || from == c.name {
+				continue
+			}
+			key := resourcehelpers.ResourceKey(c.u)
+			kind := c.u.GetKind()
+			ns := c.u.GetNamespace()
+			if len(c.name) > validation.DNS1123SubdomainMaxLength {
+				return nil, nil, errors.Errorf("cannot rename %s as the name '%s' is too long: %s", key, c.name, validation.MaxLenError(validation.DNS1123SubdomainMaxLength))
+			}
+			toKey := configKey(kind, ns, c.name)
+			if existing[toKey] && !isRenamedAway(groups, toKey) {
+				return nil, nil, errors.Errorf("cannot rename %s to %s as there is already a resource %s", key, c.name, toKey)
+			}
+			updates[key] = c
+			add(kind, ns, from, c.name)
+			o.Renames = append(o.Renames, renamer.Rename{File: o.relPath(c.path), Kind: kind, Namespace: ns, From: from, To: c.name})
+		}
+
+		// lets rewrite the references to the base name and to any replaced copies to the current resource
+		current := g.current
+		if current == nil {
+			continue
+		}
+		kind := current.u.GetKind()
+		ns := current.u.GetNamespace()
+		if current.hashed {
+			add(kind, ns, current.base, current.name)
+		}
+		for _, c := range g.configs {
+			if c.replaced && c.u.GetName() != current.name {
+				add(kind, ns, c.u.GetName(), current.name)
+			}
+		}
+	}
+	return updates, index, nil
+}
+
+// isRenamedAway returns true if the resource with the key is renamed or removed so that another resource can be
+// renamed to its name
+func isRenamedAway(groups []*group, key string) bool {
+	for _, g := range groups {
+		for _, c := range g.configs {
+			if resourcehelpers.ResourceKey(c.u) == key && (c.replaced || c.u.GetName() != c.name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prune removes the copies of the ConfigMaps and Secrets from previous runs which are no longer referenced. The
+// current resource of each group is never removed and neither are the copies of a group which has no current
+// resource unless one of them is still referenced
+func (o *Options) prune(groups []*group) error {
+	referenced := map[string][]string{}
+	locations := map[string][]string{}
+	indexes := map[string]int{}
+	err := resourcehelpers.VisitDocuments(o.Dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, func(u *unstructured.Unstructured, path string, index int) error {
+		if isConfig(u) {
+			key := resourcehelpers.ResourceKey(u)
+			locations[key] = append(locations[key], path)
+			indexes[key+"@"+path] = index
+		}
+		for _, ref := range validaterefs.AllReferences(u) {
+			ns := ref.Namespace
+			if ns == "" {
+				ns = u.GetNamespace()
+			}
+			key := ref.Kind + "/" + ref.Name
+			referenced[key] = append(referenced[key], ns)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the references to the ConfigMaps and Secrets in dir %s", o.Dir)
+	}
+	isReferenced := func(c *config) bool {
+		ns := c.u.GetNamespace()
+		for _, n := range referenced[c.u.GetKind()+"/"+c.name] {
+			if n == ns || n == "" || ns == "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	removals := map[string]map[int]bool{}
+	for _, g := range groups {
+		anyReferenced := false
+		for _, c := range g.configs {
+			if !c.replaced && isReferenced(c) {
+				anyReferenced = true
+			}
+		}
+		if g.current == nil && !anyReferenced {
+			continue
+		}
+		for _, c := range g.configs {
+			if c == g.current || c.replaced || isReferenced(c) {
+				continue
+			}
+			key := configKey(c.u.GetKind(), c.u.GetNamespace(), c.name)
+			for _, path := range locations[key] {
+				if removals[path] == nil {
+					removals[path] = map[int]bool{}
+				}
+				removals[path][indexes[key+"@"+path]] = true
+			}
+			o.Pruned = append(o.Pruned, key)
+		}
+	}
+	sort.Strings(o.Pruned)
+	return removeDocuments(removals)
+}
+
+// removeDocuments removes the documents with the given indexes from each file
+func removeDocuments(removals map[string]map[int]bool) error {
+	var paths []string
+	for path := range removals {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		_, err := resourcehelpers.RemoveDocuments(path, removals[path])
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove the resources from file %s", path)
+		}
+	}
+	return nil
+}
+
+// configKey returns the key of the resource in the same format as resourcehelpers.ResourceKey
+func configKey(kind, ns, name string) string {
+	key := kind + "/" + name
+	if ns != "" {
+		key = ns + "/" + key
+	}
+	return key
+}
+
+func isConfig(u *unstructured.Unstructured) bool {
+	kind := u.GetKind()
+	for _, k := range Kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Options) relPath(path string) string {
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,201 @@
+package hashsuffix_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/hashsuffix"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/renamer"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	appConfig     = "app-config-93bcc432"
+	oldAppConfig  = "app-config-81de069d"
+	warnAppConfig = "app-config-12a7e3ad"
+	dbCreds       = "db-creds-98ae3fbf"
+	tlsCert       = "tls-cert-906d754f"
+)
+
+func TestHashSuffix(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := hashsuffix.NewCmdHashSuffix()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes")
+
+	assert.Equal(t, []renamer.Rename{
+		{File: "configmaps.yaml", Kind: "ConfigMap", Namespace: "jx", From: "app-config", To: appConfig},
+		{File: "secrets.yaml", Kind: "Secret", Namespace: "jx", From: "db-creds", To: dbCreds},
+		{File: "secrets.yaml", Kind: "Secret", Namespace: "jx", From: "tls-cert", To: tlsCert},
+	}, o.Renames, "renames")
+	assert.Len(t, o.References, 8, "references")
+	assert.Empty(t, o.Replaced, "replaced")
+	assert.Empty(t, o.Pruned, "pruned")
+
+	configMaps := loadFile(t, filepath.Join(tmpDir, "configmaps.yaml"))
+	require.Len(t, configMaps, 3, "ConfigMaps")
+	assert.Equal(t, appConfig, configMaps[0].GetName(), "name of the ConfigMap")
+	assert.Equal(t, "app-config", configMaps[0].GetAnnotations()[hashsuffix.NameAnnotation], "original name annotation")
+	assert.Equal(t, oldAppConfig, configMaps[1].GetName(), "the copy from the previous run should be kept without --prune")
+	assert.Equal(t, "plain", configMaps[2].GetName(), "ConfigMaps without the annotation should not be renamed")
+
+	deployment := loadFile(t, filepath.Join(tmpDir, "deployment.yaml"))[0]
+	podSpec := []string{"spec", "template", "spec"}
+	assertField(t, deployment, appConfig, podSpec, "volumes", 0, "configMap", "name")
+	assertField(t, deployment, appConfig, podSpec, "containers", 0, "envFrom", 0, "configMapRef", "name")
+	assertField(t, deployment, dbCreds, podSpec, "containers", 0, "env", 0, "valueFrom", "secretKeyRef", "name")
+	assertField(t, deployment, "plain", podSpec, "containers", 0, "env", 1, "valueFrom", "configMapKeyRef", "name")
+
+	cronJob := loadFile(t, filepath.Join(tmpDir, "cronjob.yaml"))[0]
+	podSpec = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	assertField(t, cronJob, appConfig, podSpec, "volumes", 0, "projected", "sources", 0, "configMap", "name")
+	assertField(t, cronJob, dbCreds, podSpec, "volumes", 0, "projected", "sources", 1, "secret", "name")
+
+	statefulSet := loadFile(t, filepath.Join(tmpDir, "statefulset.yaml"))[0]
+	podSpec = []string{"spec", "template", "spec"}
+	assertField(t, statefulSet, dbCreds, podSpec, "initContainers", 0, "envFrom", 0, "secretRef", "name")
+	assertField(t, statefulSet, dbCreds, podSpec, "volumes", 0, "secret", "secretName")
+
+	ingress := loadFile(t, filepath.Join(tmpDir, "ingress.yaml"))[0]
+	assertField(t, ingress, tlsCert, []string{"spec"}, "tls", 0, "secretName")
+	assertField(t, ingress, "web", []string{"spec"}, "rules", 0, "http", "paths", 0, "backend", "service", "name")
+
+	// running again should not stack the suffixes
+	err = o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes again")
+	assert.Empty(t, o.Renames, "renames on second run")
+	assert.Empty(t, o.References, "references on second run")
+
+	// changing the data should replace the suffix
+	configMaps = loadFile(t, filepath.Join(tmpDir, "configmaps.yaml"))
+	configMaps[0].Object["data"] = map[string]interface{}{"level": "warn"}
+	err = resourcehelpers.SaveFile(configMaps, filepath.Join(tmpDir, "configmaps.yaml"))
+	require.NoError(t, err, "failed to save the modified ConfigMap")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes after changing the data")
+	assert.Equal(t, []renamer.Rename{
+		{File: "configmaps.yaml", Kind: "ConfigMap", Namespace: "jx", From: appConfig, To: warnAppConfig},
+	}, o.Renames, "renames after changing the data")
+	assert.Len(t, o.References, 3, "references after changing the data")
+	deployment = loadFile(t, filepath.Join(tmpDir, "deployment.yaml"))[0]
+	assertField(t, deployment, warnAppConfig, []string{"spec", "template", "spec"}, "volumes", 0, "configMap", "name")
+}
+
+func TestHashSuffixAll(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := hashsuffix.NewCmdHashSuffix()
+	o.Dir = tmpDir
+	o.All = true
+	o.Kinds = []string{"ConfigMap"}
+	err := o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes")
+
+	assert.Equal(t, []renamer.Rename{
+		{File: "configmaps.yaml", Kind: "ConfigMap", Namespace: "jx", From: "app-config", To: appConfig},
+		{File: "configmaps.yaml", Kind: "ConfigMap", Namespace: "jx", From: "plain", To: "plain-55784a87"},
+	}, o.Renames, "renames")
+
+	deployment := loadFile(t, filepath.Join(tmpDir, "deployment.yaml"))[0]
+	podSpec := []string{"spec", "template", "spec"}
+	assertField(t, deployment, "plain-55784a87", podSpec, "containers", 0, "env", 1, "valueFrom", "configMapKeyRef", "name")
+	assertField(t, deployment, "db-creds", podSpec, "containers", 0, "env", 0, "valueFrom", "secretKeyRef", "name")
+}
+
+func TestHashSuffixRegenerated(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := hashsuffix.NewCmdHashSuffix()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes")
+
+	// lets regenerate the ConfigMap and the Deployment which references the original names
+	err = files.CopyFile(filepath.Join("test_data", "regenerated", "app-config.yaml"), filepath.Join(tmpDir, "app-config.yaml"))
+	require.NoError(t, err, "failed to copy the regenerated ConfigMap")
+	err = files.CopyFile(filepath.Join("test_data", "src", "deployment.yaml"), filepath.Join(tmpDir, "deployment.yaml"))
+	require.NoError(t, err, "failed to copy the regenerated Deployment")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes to the regenerated resources")
+
+	assert.Equal(t, []string{"jx/ConfigMap/" + appConfig}, o.Replaced, "replaced")
+	assert.Equal(t, []renamer.Rename{
+		{File: "app-config.yaml", Kind: "ConfigMap", Namespace: "jx", From: "app-config", To: appConfig},
+	}, o.Renames, "renames")
+	assert.Len(t, o.References, 3, "references")
+
+	configMaps := loadFile(t, filepath.Join(tmpDir, "configmaps.yaml"))
+	require.Len(t, configMaps, 2, "ConfigMaps")
+	assert.Equal(t, oldAppConfig, configMaps[0].GetName(), "name of the remaining copy")
+	assert.Equal(t, appConfig, loadFile(t, filepath.Join(tmpDir, "app-config.yaml"))[0].GetName(), "name of the regenerated ConfigMap")
+
+	deployment := loadFile(t, filepath.Join(tmpDir, "deployment.yaml"))[0]
+	podSpec := []string{"spec", "template", "spec"}
+	assertField(t, deployment, appConfig, podSpec, "volumes", 0, "configMap", "name")
+	assertField(t, deployment, dbCreds, podSpec, "containers", 0, "env", 0, "valueFrom", "secretKeyRef", "name")
+}
+
+func TestHashSuffixPrune(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := hashsuffix.NewCmdHashSuffix()
+	o.Dir = tmpDir
+	o.Prune = true
+	err := o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes")
+
+	assert.Equal(t, []string{"jx/ConfigMap/" + oldAppConfig}, o.Pruned, "pruned")
+	configMaps := loadFile(t, filepath.Join(tmpDir, "configmaps.yaml"))
+	require.Len(t, configMaps, 2, "ConfigMaps")
+	assert.Equal(t, appConfig, configMaps[0].GetName(), "name of the current ConfigMap")
+	assert.Equal(t, "plain", configMaps[1].GetName(), "ConfigMaps without the annotation should not be pruned")
+	assert.Len(t, loadFile(t, filepath.Join(tmpDir, "secrets.yaml")), 2, "Secrets")
+
+	// copies which are still referenced should be kept
+	tmpDir = testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+	err = files.CopyFile(filepath.Join("test_data", "extra", "job.yaml"), filepath.Join(tmpDir, "job.yaml"))
+	require.NoError(t, err, "failed to copy the Job")
+
+	o.Dir = tmpDir
+	err = o.Run()
+	require.NoError(t, err, "failed to add the hash suffixes")
+	assert.Empty(t, o.Pruned, "pruned")
+	assert.Len(t, loadFile(t, filepath.Join(tmpDir, "configmaps.yaml")), 3, "ConfigMaps")
+}
+
+func assertField(t *testing.T, u *unstructured.Unstructured, expected string, prefix []string, path ...interface{}) {
+	var value interface{} = u.Object
+	var fields []interface{}
+	for _, p := range prefix {
+		fields = append(fields, p)
+	}
+	fields = append(fields, path...)
+	for _, f := range fields {
+		switch k := f.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			require.True(t, ok, "%s is not an object at %s in %v", u.GetName(), k, fields)
+			value = m[k]
+		case int:
+			list, ok := value.([]interface{})
+			require.True(t, ok && k < len(list), "%s has no element %d in %v", u.GetName(), k, fields)
+			value = list[k]
+		}
+	}
+	assert.Equal(t, expected, value, "%s %s field %v", u.GetKind(), u.GetName(), fields)
+}
+
+func loadFile(t *testing.T, path string) []*unstructured.Unstructured {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	return resources
+}
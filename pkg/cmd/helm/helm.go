@@ -3,7 +3,10 @@ package helm
 import (
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/build"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/escape"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/postrender"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/release"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/strip"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/valuesmerge"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/spf13/cobra"
@@ -24,6 +27,10 @@ func NewCmdHelm() *cobra.Command {
 	command.AddCommand(cobras.SplitCommand(NewCmdHelmTemplate()))
 	command.AddCommand(cobras.SplitCommand(build.NewCmdHelmBuild()))
 	command.AddCommand(cobras.SplitCommand(escape.NewCmdEscape()))
+	command.AddCommand(cobras.SplitCommand(NewCmdHelmInflate()))
 	command.AddCommand(cobras.SplitCommand(release.NewCmdHelmRelease()))
+	command.AddCommand(cobras.SplitCommand(postrender.NewCmdPostRender()))
+	command.AddCommand(cobras.SplitCommand(strip.NewCmdHelmStrip()))
+	command.AddCommand(cobras.SplitCommand(valuesmerge.NewCmdHelmValuesMerge()))
 	return command
 }
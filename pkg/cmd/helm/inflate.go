@@ -0,0 +1,162 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/plugins"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	helmInflateLong = templates.LongDesc(`
+		Generates the kubernetes resources from a helm chart for each environment
+
+		Each *.yaml or *.yml file in the environment values directory defines an environment named after the file. The chart is templated with each file to the output directory named after the environment.
+`)
+
+	helmInflateExample = templates.Examples(`
+		# generates the resources of the chart into out/dev and out/prod using the env/dev.yaml and env/prod.yaml files
+		%s helm inflate --chart charts/myapp --env-values-dir env
+
+		# generates the resources using a common values file before the environment values file
+		%s helm inflate --chart charts/myapp --env-values-dir env --values values.yaml --output-dir config-root
+	`)
+)
+
+// InflateOptions the options for the command
+type InflateOptions struct {
+	Template     TemplateOptions
+	EnvValuesDir string
+	Environments []string
+}
+
+// NewCmdHelmInflate creates a command object for the command
+func NewCmdHelmInflate() (*cobra.Command, *InflateOptions) {
+	o := &InflateOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "inflate",
+		Short:   "Generates the kubernetes resources from a helm chart for each environment",
+		Long:    helmInflateLong,
+		Example: fmt.Sprintf(helmInflateExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	t := &o.Template
+	cmd.Flags().StringVarP(&t.Chart, "chart", "c", "", "the chart to template")
+	cmd.Flags().StringVarP(&o.EnvValuesDir, "env-values-dir", "e", "", "the directory containing a values file for each environment such as dev.yaml and prod.yaml")
+	cmd.Flags().StringVarP(&t.OutDir, "output-dir", "o", "out", "the output directory. The resources of each environment are generated into a directory named after the environment which is removed first")
+	cmd.Flags().StringVarP(&t.ReleaseName, "name", "n", "", "the name of the helm release to template. Defaults to the name of the chart")
+	cmd.Flags().StringVarP(&t.Namespace, "namespace", "", "", "specifies the namespace to use to generate the templates in")
+	cmd.Flags().StringArrayVarP(&t.ValuesFiles, "values", "f", nil, "the helm values.yaml files used for all environments before the environment values file")
+	cmd.Flags().StringVarP(&t.Version, "version", "v", "", "the version of the helm chart to use. If not specified then the latest one is used")
+	cmd.Flags().StringVarP(&t.Repository, "repository", "r", "", "the helm chart repository to locate the chart")
+	cmd.Flags().StringSliceVarP(&o.Environments, "env", "", nil, "the names of the environments to generate. If not specified all the environments in the environment values directory are generated")
+	cmd.Flags().BoolVarP(&t.NoSplit, "no-split", "", false, "if set then disable splitting of multiple resources into separate files")
+	cmd.Flags().BoolVarP(&t.IncludeCRDs, "include-crds", "", true, "if CRDs should be included in the output")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *InflateOptions) Run() error {
+	t := o.Template
+	if t.Chart == "" {
+		return options.MissingOption("chart")
+	}
+	if o.EnvValuesDir == "" {
+		return options.MissingOption("env-values-dir")
+	}
+	if t.ReleaseName == "" {
+		t.ReleaseName = filepath.Base(t.Chart)
+	}
+	if t.OutDir == "" {
+		t.OutDir = "out"
+	}
+	if t.CommandRunner == nil {
+		t.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	if t.HelmBinary == "" {
+		var err error
+		t.HelmBinary, err = plugins.GetHelmBinary(plugins.HelmVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	envFiles, err := o.EnvironmentValuesFiles()
+	if err != nil {
+		return err
+	}
+	if len(envFiles) == 0 {
+		return errors.Errorf("no environment values files found in dir %s", o.EnvValuesDir)
+	}
+
+	var envs []string
+	for env := range envFiles {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	for _, env := range envs {
+		eo := t
+		eo.OutDir = filepath.Join(t.OutDir, env)
+		eo.ValuesFiles = append(append([]string{}, t.ValuesFiles...), envFiles[env])
+
+		err = os.RemoveAll(eo.OutDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove output dir %s", eo.OutDir)
+		}
+		err = eo.Run()
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate the resources for environment %s", env)
+		}
+		log.Logger().Infof("generated the resources of chart %s for environment %s to %s", termcolor.ColorInfo(t.Chart), termcolor.ColorInfo(env), termcolor.ColorInfo(eo.OutDir))
+	}
+	return nil
+}
+
+// EnvironmentValuesFiles returns the values files in the environment values directory indexed by the environment name
+func (o *InflateOptions) EnvironmentValuesFiles() (map[string]string, error) {
+	fileInfos, err := ioutil.ReadDir(o.EnvValuesDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read environment values dir %s", o.EnvValuesDir)
+	}
+	answer := map[string]string{}
+	for _, f := range fileInfos {
+		name := f.Name()
+		ext := filepath.Ext(name)
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		env := strings.TrimSuffix(name, ext)
+		if len(o.Environments) > 0 && stringhelpers.StringArrayIndex(o.Environments, env) < 0 {
+			continue
+		}
+		if answer[env] != "" {
+			return nil, errors.Errorf("duplicate values files for environment %s in dir %s", env, o.EnvValuesDir)
+		}
+		answer[env] = filepath.Join(o.EnvValuesDir, name)
+	}
+	for _, env := range o.Environments {
+		if answer[env] == "" {
+			return nil, errors.Errorf("no values file for environment %s in dir %s", env, o.EnvValuesDir)
+		}
+	}
+	return answer, nil
+}
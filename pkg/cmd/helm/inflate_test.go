@@ -0,0 +1,111 @@
+package helm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelmInflate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create tmp dir")
+
+	outDir := filepath.Join(tmpDir, "out")
+	staleFile := filepath.Join(outDir, "dev", "stale.yaml")
+	err = os.MkdirAll(filepath.Dir(staleFile), files.DefaultDirWritePermissions)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(staleFile, []byte("stale: true\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err)
+
+	// simulates helm template generating a file with multiple resources using the replica count of the values file
+	fakeHelmTemplate := func(c *cmdrunner.Command) error {
+		valuesFile := c.Args[4]
+		values, err := ioutil.ReadFile(valuesFile)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(c.Args[2], "mychart", "templates")
+		err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		text := "apiVersion: v1\nkind: Service\nmetadata:\n  name: mychart\n---\n" +
+			"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: mychart\nspec:\n  " + string(values)
+		return ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(text), files.DefaultFileWritePermissions)
+	}
+
+	chart := filepath.Join("test_data", "mychart")
+	envDir := filepath.Join("test_data", "inflate", "env")
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--values", filepath.Join(envDir, "dev.yaml"), "--include-crds", "mychart", chart},
+			Callback: fakeHelmTemplate,
+		},
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--values", filepath.Join(envDir, "prod.yaml"), "--include-crds", "mychart", chart},
+			Callback: fakeHelmTemplate,
+		},
+	)
+
+	_, o := helm.NewCmdHelmInflate()
+	o.Template.Chart = chart
+	o.Template.HelmBinary = "helm"
+	o.Template.OutDir = outDir
+	o.Template.CommandRunner = runner.Run
+	o.EnvValuesDir = envDir
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command")
+
+	runner.Verify(t)
+
+	assert.NoFileExists(t, staleFile, "the output dir of the environment should have been removed")
+	for env, replicas := range map[string]string{"dev": "1", "prod": "3"} {
+		assert.FileExists(t, filepath.Join(outDir, env, "resources.yaml"), "the service for %s", env)
+
+		data, err := ioutil.ReadFile(filepath.Join(outDir, env, "resources2.yaml"))
+		require.NoError(t, err, "failed to load the deployment for %s", env)
+		assert.Contains(t, string(data), "replicaCount: "+replicas, "the deployment for %s", env)
+	}
+}
+
+func TestHelmInflateEnvironments(t *testing.T) {
+	_, o := helm.NewCmdHelmInflate()
+	o.EnvValuesDir = filepath.Join("test_data", "inflate", "env")
+
+	envFiles, err := o.EnvironmentValuesFiles()
+	require.NoError(t, err, "failed to find the environment values files")
+	assert.Equal(t, map[string]string{
+		"dev":  filepath.Join(o.EnvValuesDir, "dev.yaml"),
+		"prod": filepath.Join(o.EnvValuesDir, "prod.yaml"),
+	}, envFiles)
+
+	o.Environments = []string{"prod"}
+	envFiles, err = o.EnvironmentValuesFiles()
+	require.NoError(t, err, "failed to find the environment values files")
+	assert.Equal(t, []string{"prod"}, keys(envFiles))
+
+	o.Environments = []string{"staging"}
+	_, err = o.EnvironmentValuesFiles()
+	require.Error(t, err, "should have failed for a missing environment")
+}
+
+func keys(m map[string]string) []string {
+	var answer []string
+	for k := range m {
+		answer = append(answer, k)
+	}
+	return answer
+}
@@ -0,0 +1,200 @@
+package postrender
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/annotate"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/label"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/namespace"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Transforms the rendered manifests of a helm chart so that it can be used as a helm post renderer
+
+		The manifests are read from stdin, the transforms in the --post-render-config file are applied in order and the result is written to stdout. Each transform can set the namespace, add or remove labels or add or remove annotations in the same way as the namespace, label and annotate commands. For example:
+
+		    transforms:
+		    - namespace: jx
+		      onlyMissing: true
+		    - labels:
+		      - team=payments
+		      podTemplate: true
+		    - annotations:
+		      - fluxcd.io/automated=false
+		      overwrite: true
+`)
+
+	cmdExample = templates.Examples(`
+		# uses the post renderer when templating a chart
+		helm template mychart --post-renderer %s --post-renderer-args helm --post-renderer-args post-render --post-renderer-args --post-render-config=post-render.yaml
+
+		# transforms some manifests
+		cat manifests.yaml | %s helm post-render --post-render-config post-render.yaml
+	`)
+)
+
+// Config the configuration of the post renderer
+type Config struct {
+	// Transforms the transforms applied to the manifests in order
+	Transforms []Transform `json:"transforms,omitempty"`
+}
+
+// Transform a transform of the manifests. Only one of Namespace, Labels or Annotations should be specified
+type Transform struct {
+	// Namespace the namespace to set on the namespaced resources
+	Namespace string `json:"namespace,omitempty"`
+
+	// OnlyMissing only sets the namespace of resources which do not have a namespace
+	OnlyMissing bool `json:"onlyMissing,omitempty"`
+
+	// Labels the labels to add as 'key=value' or remove as 'key-'
+	Labels []string `json:"labels,omitempty"`
+
+	// PodTemplate also updates the labels of the pod templates of workloads
+	PodTemplate bool `json:"podTemplate,omitempty"`
+
+	// Annotations the annotations to add as 'key=value' or remove as 'key-'
+	Annotations []string `json:"annotations,omitempty"`
+
+	// Overwrite replaces the value of existing labels or annotations with the same key
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// Options the options for the command
+type Options struct {
+	ConfigFile string
+	Config     Config
+	In         io.Reader
+	Out        io.Writer
+}
+
+// NewCmdPostRender creates a command object for the command
+func NewCmdPostRender() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "post-render",
+		Short:   "Transforms the rendered manifests of a helm chart so that it can be used as a helm post renderer",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			// lets keep stdout for the manifests
+			log.SetOutput(os.Stderr)
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.ConfigFile, "post-render-config", "", "", "the YAML file containing the transforms to apply to the manifests")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.ConfigFile != "" {
+		err := yamls.LoadFile(o.ConfigFile, &o.Config)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load post render config file %s", o.ConfigFile)
+		}
+	}
+	for i, t := range o.Config.Transforms {
+		count := 0
+		if t.Namespace != "" {
+			count++
+		}
+		if len(t.Labels) > 0 {
+			count++
+		}
+		if len(t.Annotations) > 0 {
+			count++
+		}
+		if count != 1 {
+			return errors.Errorf("transform %d should specify one of namespace, labels or annotations", i+1)
+		}
+	}
+	if o.In == nil {
+		o.In = os.Stdin
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(o.In)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the manifests")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "jx-post-render-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "manifests.yaml")
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save %s", path)
+	}
+	for i, t := range o.Config.Transforms {
+		err = o.transform(tmpDir, t)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply transform %d", i+1)
+		}
+	}
+
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", path)
+	}
+	_, err = o.Out.Write(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to write the manifests")
+	}
+	return nil
+}
+
+// transform applies the transform to the manifests in the directory
+func (o *Options) transform(dir string, t Transform) error {
+	switch {
+	case t.Namespace != "":
+		no := &namespace.Options{
+			OnlyMissing: t.OnlyMissing,
+		}
+		return no.UpdateNamespace(dir, t.Namespace)
+	case len(t.Labels) > 0:
+		lo := &label.Options{
+			Dir:         dir,
+			Labels:      t.Labels,
+			PodTemplate: t.PodTemplate,
+			Overwrite:   t.Overwrite,
+		}
+		return lo.Run()
+	default:
+		ao := &annotate.Options{
+			Dir:         dir,
+			Annotations: t.Annotations,
+			Overwrite:   t.Overwrite,
+		}
+		return ao.Run()
+	}
+}
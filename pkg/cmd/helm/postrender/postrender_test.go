@@ -0,0 +1,78 @@
+package postrender_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/postrender"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+func TestPostRender(t *testing.T) {
+	manifestsFile := filepath.Join("test_data", "manifests.yaml")
+	in, err := os.Open(manifestsFile)
+	require.NoError(t, err, "failed to open %s", manifestsFile)
+	defer in.Close()
+
+	out := &bytes.Buffer{}
+	_, o := postrender.NewCmdPostRender()
+	o.ConfigFile = filepath.Join("test_data", "post-render.yaml")
+	o.In = in
+	o.Out = out
+	err = o.Run()
+	require.NoError(t, err, "failed to post render")
+	require.Len(t, o.Config.Transforms, 3, "transforms")
+
+	expectedFile := filepath.Join("test_data", "expected.yaml")
+	if generateTestOutput {
+		err = ioutil.WriteFile(expectedFile, out.Bytes(), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", expectedFile)
+	}
+	tmpDir := t.TempDir()
+	actualFile := filepath.Join(tmpDir, "actual.yaml")
+	err = ioutil.WriteFile(actualFile, out.Bytes(), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", actualFile)
+	testhelpers.AssertTextFilesEqual(t, expectedFile, actualFile, "post rendered manifests")
+}
+
+func TestPostRenderNoTransforms(t *testing.T) {
+	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"
+	out := &bytes.Buffer{}
+	o := &postrender.Options{
+		In:  strings.NewReader(input),
+		Out: out,
+	}
+	err := o.Run()
+	require.NoError(t, err, "failed to post render")
+	assert.Equal(t, input, out.String(), "the manifests should not be modified")
+}
+
+func TestPostRenderInvalidTransform(t *testing.T) {
+	o := &postrender.Options{
+		Config: postrender.Config{
+			Transforms: []postrender.Transform{
+				{
+					Namespace: "jx",
+					Labels:    []string{"team=payments"},
+				},
+			},
+		},
+		In:  strings.NewReader(""),
+		Out: &bytes.Buffer{},
+	}
+	err := o.Run()
+	require.Error(t, err, "should fail for a transform with a namespace and labels")
+	assert.Contains(t, err.Error(), "transform 1", "error message")
+}
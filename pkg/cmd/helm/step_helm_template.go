@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/namespace"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/split"
+	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/plugins"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
@@ -19,16 +22,24 @@ import (
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var (
 	helmTemplateLong = templates.LongDesc(`
 		Generate the kubernetes resources from a helm chart
+
+		The chart is rendered via 'helm template' and any files containing multiple resources are split into a file per resource named by the --filename-template. If --namespace is specified it is set on the namespaced resources which do not have a namespace. The 'helm.sh/chart' label which changes with every chart version is removed unless --no-strip-helm-labels is specified and the chart tests are removed if --skip-tests is specified.
+
+		If --clean is specified the output directory is removed first so that the generated directory only contains the resources of the chart and is the same every time the command is run with the same chart and values.
 `)
 
 	helmTemplateExample = templates.Examples(`
 		# generates the resources from a helm chart
 		%s step helm template
+
+		# generates the resources of a remote chart into a namespace directory
+		%s helm template --repo https://charts.jenkins.io --chart jenkins --release jenkins --values values.yaml --set controller.tag=2.263 --namespace jx --out-dir config-root/namespaces/jx/jenkins --skip-tests --clean
 	`)
 )
 
@@ -40,6 +51,8 @@ type TemplateOptions struct {
 	Namespace        string
 	Chart            string
 	ValuesFiles      []string
+	SetValues        []string
+	FilenameTemplate string
 	DefaultDomain    string
 	GitCommitMessage string
 	Version          string
@@ -50,6 +63,9 @@ type TemplateOptions struct {
 	NoExtSecrets     bool
 	IncludeCRDs      bool
 	CheckExists      bool
+	SkipTests        bool
+	NoStripLabels    bool
+	Clean            bool
 	Gitter           gitclient.Interface
 	CommandRunner    cmdrunner.CommandRunner
 }
@@ -62,19 +78,27 @@ func NewCmdHelmTemplate() (*cobra.Command, *TemplateOptions) {
 		Use:     "template",
 		Short:   "Generate the kubernetes resources from a helm chart",
 		Long:    helmTemplateLong,
-		Example: fmt.Sprintf(helmTemplateExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(helmTemplateExample, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.OutDir, "output-dir", "o", "", "the output directory to generate the templates to. Defaults to charts/$name/resources")
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "", "", "an alias of --output-dir")
 	cmd.Flags().StringVarP(&o.ReleaseName, "name", "n", "", "the name of the helm release to template. Defaults to $APP_NAME if not specified")
+	cmd.Flags().StringVarP(&o.ReleaseName, "release", "", "", "an alias of --name")
 	cmd.Flags().StringVarP(&o.Namespace, "namespace", "", "", "specifies the namespace to use to generate the templates in")
 	cmd.Flags().StringVarP(&o.Chart, "chart", "c", "", "the chart name to template. Defaults to 'charts/$name'")
 	cmd.Flags().StringArrayVarP(&o.ValuesFiles, "values", "f", nil, "the helm values.yaml file used to template values in the generated template")
+	cmd.Flags().StringArrayVarP(&o.SetValues, "set", "", nil, "the helm values to set on the command line as 'key=value'")
 	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "the version of the helm chart to use. If not specified then the latest one is used")
 	cmd.Flags().StringVarP(&o.Repository, "repository", "r", "", "the helm chart repository to locate the chart")
+	cmd.Flags().StringVarP(&o.Repository, "repo", "", "", "an alias of --repository")
+	cmd.Flags().StringVarP(&o.FilenameTemplate, "filename-template", "", split.DefaultFilenameTemplate, "the go template of the names of the files of the resources split from files containing multiple resources")
+	cmd.Flags().BoolVarP(&o.SkipTests, "skip-tests", "", false, "if set then the helm test hooks of the chart are removed")
+	cmd.Flags().BoolVarP(&o.NoStripLabels, "no-strip-helm-labels", "", false, "if set then the helm.sh/chart labels are not removed")
+	cmd.Flags().BoolVarP(&o.Clean, "clean", "", false, "if set then the output directory is removed before generating the resources")
 	cmd.Flags().StringVarP(&o.GitCommitMessage, "commit-message", "", "chore: generated kubernetes resources from helm chart", "the git commit message used")
 
 	o.AddFlags(cmd)
@@ -136,15 +160,20 @@ func (o *TemplateOptions) Run() error {
 	if outDir == "" {
 		outDir = filepath.Join(chart, "resources")
 	}
-	err = os.MkdirAll(outDir, files.DefaultDirWritePermissions)
-	if err != nil {
-		return errors.Wrapf(err, "failed to ensure output directory exists %s", outDir)
-	}
 
 	tmpDir, err := ioutil.TempDir("", "")
 	if err != nil {
 		return errors.Wrap(err, "failed to create temporary directory")
 	}
+	defer os.RemoveAll(tmpDir)
+
+	// lets generate the resources into a staging directory so that only the generated files are processed
+	stagingDir := filepath.Join(tmpDir, "staging")
+	renderDir := filepath.Join(tmpDir, "render")
+	err = os.MkdirAll(renderDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", renderDir)
+	}
 
 	tmpChartDir := ""
 	if o.Repository != "" {
@@ -175,10 +204,13 @@ func (o *TemplateOptions) Run() error {
 
 	cmdDir := ""
 
-	args := []string{"template", "--output-dir", tmpDir}
+	args := []string{"template", "--output-dir", renderDir}
 	for _, valuesFile := range o.ValuesFiles {
 		args = append(args, "--values", valuesFile)
 	}
+	for _, value := range o.SetValues {
+		args = append(args, "--set", value)
+	}
 
 	if o.Repository != "" {
 		args = append(args, "--repo", o.Repository)
@@ -206,19 +238,19 @@ func (o *TemplateOptions) Run() error {
 		return errors.Wrapf(err, "failed to run %s got: %s", c.CLI(), results)
 	}
 
-	// now lets copy the templates from the temp dir to the outDir
-	crdsDir := filepath.Join(tmpDir, name, "crds")
+	// now lets copy the templates from the render dir to the staging dir
+	crdsDir := filepath.Join(renderDir, name, "crds")
 	exists, err := files.DirExists(crdsDir)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check if crds dir was generated")
 	}
 	if exists {
-		err = files.CopyDirOverwrite(crdsDir, outDir)
+		err = files.CopyDirOverwrite(crdsDir, stagingDir)
 		if err != nil {
-			return errors.Wrapf(err, "failed to copy generated crds at %s to %s", crdsDir, outDir)
+			return errors.Wrapf(err, "failed to copy generated crds at %s to %s", crdsDir, stagingDir)
 		}
 	}
-	templatesDir := filepath.Join(tmpDir, name, "templates")
+	templatesDir := filepath.Join(renderDir, name, "templates")
 	exists, err = files.DirExists(templatesDir)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check if templates dir was generated")
@@ -226,13 +258,13 @@ func (o *TemplateOptions) Run() error {
 	if !exists {
 		return errors.Errorf("no templates directory was created at %s", templatesDir)
 	}
-	err = files.CopyDirOverwrite(templatesDir, outDir)
+	err = files.CopyDirOverwrite(templatesDir, stagingDir)
 	if err != nil {
-		return errors.Wrapf(err, "failed to copy generated templates at %s to %s", templatesDir, outDir)
+		return errors.Wrapf(err, "failed to copy generated templates at %s to %s", templatesDir, stagingDir)
 	}
 
 	// lets copy all dependent chart templates folders
-	dependentChartsDir := filepath.Join(tmpDir, name, "charts")
+	dependentChartsDir := filepath.Join(renderDir, name, "charts")
 	exists, err = files.DirExists(dependentChartsDir)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check if charts dir was generated")
@@ -249,7 +281,7 @@ func (o *TemplateOptions) Run() error {
 				return errors.Wrapf(err, "failed to check if templates dir was generated for %s", depTemplateDir)
 			}
 			if exists {
-				depOutDir := filepath.Join(outDir, f.Name())
+				depOutDir := filepath.Join(stagingDir, f.Name())
 				err = files.CopyDirOverwrite(depTemplateDir, depOutDir)
 				if err != nil {
 					return errors.Wrapf(err, "failed to copy generated templates at %s to %s", depTemplateDir, depOutDir)
@@ -258,19 +290,24 @@ func (o *TemplateOptions) Run() error {
 		}
 	}
 
-	err = os.RemoveAll(tmpDir)
+	err = o.processResources(stagingDir)
 	if err != nil {
-		return errors.Wrapf(err, "failed to remove tmp dir %s", tmpDir)
+		return err
 	}
-	if !o.NoSplit {
-		so := &split.Options{
-			Dir: outDir,
-		}
-		err = so.Run()
+	if o.Clean {
+		err = os.RemoveAll(outDir)
 		if err != nil {
-			return errors.Wrapf(err, "failed to split YAML files at %s", outDir)
+			return errors.Wrapf(err, "failed to remove output directory %s", outDir)
 		}
 	}
+	err = os.MkdirAll(outDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to ensure output directory exists %s", outDir)
+	}
+	err = files.CopyDirOverwrite(stagingDir, outDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy generated resources at %s to %s", stagingDir, outDir)
+	}
 	if !o.DoGitCommit {
 		return nil
 	}
@@ -278,6 +315,88 @@ func (o *TemplateOptions) Run() error {
 	return o.GitCommit(outDir, o.GitCommitMessage)
 }
 
+// processResources removes the helm specific metadata from the generated resources, sets the namespace and
+// splits the files containing multiple resources
+func (o *TemplateOptions) processResources(dir string) error {
+	err := o.stripHelmMetadata(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove the helm metadata from %s", dir)
+	}
+	if o.Namespace != "" {
+		no := &namespace.Options{
+			OnlyMissing: true,
+		}
+		err = no.UpdateNamespace(dir, o.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	if o.NoSplit {
+		return nil
+	}
+	so := &split.Options{
+		Dir:              dir,
+		FilenameTemplate: o.FilenameTemplate,
+	}
+	err = so.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to split YAML files at %s", dir)
+	}
+	return nil
+}
+
+// stripHelmMetadata removes the chart labels and the test hooks if required from the resources in the directory
+// removing any files which no longer contain any resources
+func (o *TemplateOptions) stripHelmMetadata(dir string) error {
+	if o.NoStripLabels && !o.SkipTests {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		modified := false
+		var answer []*unstructured.Unstructured
+		for _, u := range resources {
+			if !resourcehelpers.IsResource(u) {
+				answer = append(answer, u)
+				continue
+			}
+			if o.SkipTests && helmhelpers.IsTestHook(u) {
+				log.Logger().Debugf("removing the test %s %s from %s", u.GetKind(), u.GetName(), path)
+				modified = true
+				continue
+			}
+			if !o.NoStripLabels {
+				var podTemplatePath []string
+				podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+				if len(podSpecPath) > 1 {
+					podTemplatePath = podSpecPath[:len(podSpecPath)-1]
+				}
+				if helmhelpers.RemoveChartLabels(u, podTemplatePath) {
+					modified = true
+				}
+			}
+			answer = append(answer, u)
+		}
+		if !modified {
+			return nil
+		}
+		if len(answer) == 0 {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+			return nil
+		}
+		return resourcehelpers.SaveFile(answer, path)
+	})
+}
+
 func (o *TemplateOptions) GitCommit(outDir string, commitMessage string) error {
 	gitter := o.Git()
 	_, err := gitter.Command(outDir, "add", "*")
@@ -2,15 +2,20 @@ package helm_test
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestStepHelmTemplate(t *testing.T) {
@@ -49,6 +54,106 @@ func TestStepHelmTemplate(t *testing.T) {
 	}
 }
 
+func TestStepHelmTemplateProcessesResources(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create tmp dir")
+
+	outDir := filepath.Join(tmpDir, "config-root", "namespaces", "jx", "mychart")
+	staleFile := filepath.Join(outDir, "stale.yaml")
+	err = os.MkdirAll(outDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(staleFile, []byte("stale: true\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err)
+
+	// simulates helm template generating a file with multiple resources including a test hook
+	fakeHelmTemplate := func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Args[2], "mychart", "templates")
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		text := `apiVersion: v1
+kind: Service
+metadata:
+  name: mychart
+  labels:
+    app: mychart
+    helm.sh/chart: mychart-1.2.3
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: mychart
+  namespace: other
+  labels:
+    helm.sh/chart: mychart-1.2.3
+spec:
+  template:
+    metadata:
+      labels:
+        app: mychart
+        helm.sh/chart: mychart-1.2.3
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: mychart-test-connection
+  annotations:
+    helm.sh/hook: test-success
+`
+		err = ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(text), files.DefaultFileWritePermissions)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "tests.yaml"), []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: mychart-test\n  annotations:\n    helm.sh/hook: test\n"), files.DefaultFileWritePermissions)
+	}
+
+	chart := filepath.Join("test_data", "mychart")
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--values", "values.yaml", "--set", "replicaCount=2", "--set", "image.tag=1.0.0", "--namespace", "jx", "--include-crds", "mychart", chart},
+			Callback: fakeHelmTemplate,
+		},
+	)
+
+	_, o := helm.NewCmdHelmTemplate()
+	o.HelmBinary = "helm"
+	o.ReleaseName = "mychart"
+	o.Chart = chart
+	o.OutDir = outDir
+	o.Namespace = "jx"
+	o.ValuesFiles = []string{"values.yaml"}
+	o.SetValues = []string{"replicaCount=2", "image.tag=1.0.0"}
+	o.SkipTests = true
+	o.Clean = true
+	o.CommandRunner = runner.Run
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command")
+	runner.Verify(t)
+
+	assert.NoFileExists(t, staleFile, "the output dir should have been cleaned")
+	assert.NoFileExists(t, filepath.Join(outDir, "resources.yaml"), "the file with multiple resources should have been split")
+	assert.NoFileExists(t, filepath.Join(outDir, "tests.yaml"), "the file only containing tests should have been removed")
+	assert.NoFileExists(t, filepath.Join(outDir, "mychart-test-connection-pod.yaml"), "the test should have been removed")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(outDir, "mychart-service.yaml"))
+	require.NoError(t, err, "failed to load the service")
+	require.Len(t, resources, 1, "resources in the service file")
+	assert.Equal(t, "jx", resources[0].GetNamespace(), "namespace of the service")
+	assert.Equal(t, map[string]string{"app": "mychart"}, resources[0].GetLabels(), "labels of the service")
+
+	resources, err = resourcehelpers.LoadFile(filepath.Join(outDir, "mychart-deployment.yaml"))
+	require.NoError(t, err, "failed to load the deployment")
+	require.Len(t, resources, 1, "resources in the deployment file")
+	assert.Equal(t, "other", resources[0].GetNamespace(), "the namespace of the deployment should not be changed")
+	assert.Empty(t, resources[0].GetLabels(), "labels of the deployment")
+	podLabels, _, err := unstructured.NestedStringMap(resources[0].Object, "spec", "template", "metadata", "labels")
+	require.NoError(t, err, "failed to get the pod template labels")
+	assert.Equal(t, map[string]string{"app": "mychart"}, podLabels, "labels of the pod template")
+}
+
 // HasHelmBinary lets test if we are running the tests in a container with the helm binary
 func HasHelmBinary(t *testing.T, helmBin string) bool {
 	c := &cmdrunner.Command{
@@ -0,0 +1,165 @@
+package strip
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Removes the helm specific labels and annotations from the rendered kubernetes resources in the given directory tree
+
+		The labels and annotations are removed from each resource and the pod template of any workload. Each --label and --annotation is a key which can include wildcards such as 'checksum/*' optionally followed by '=value' to only remove it if it has the value. They replace the default labels and annotations which are:
+
+		* labels: app.kubernetes.io/managed-by=Helm, heritage=Helm and helm.sh/chart
+		* annotations: checksum/*, meta.helm.sh/release-name, meta.helm.sh/release-namespace and helm.sh/hook*
+
+		Labels used by the selector of a resource are never removed so that the selector still matches the pods. If --remove-hooks is specified the resources with a 'helm.sh/hook' annotation are removed along with any files which no longer contain any resources. Otherwise the hooks are kept as regular resources without their hook annotations.
+
+		Running the command again does not modify any files.
+`)
+
+	cmdExample = templates.Examples(`
+		# removes the default helm labels and annotations from the rendered resources
+		%s helm strip --dir config-root
+
+		# also removes the helm hook resources such as tests
+		%s helm strip --dir config-root --remove-hooks
+
+		# only removes the checksum annotations
+		%s helm strip --annotation 'checksum/*' --label ''
+	`)
+
+	// DefaultLabels the default labels to remove
+	DefaultLabels = []string{"app.kubernetes.io/managed-by=Helm", "heritage=Helm", helmhelpers.ChartLabel}
+
+	// DefaultAnnotations the default annotations to remove
+	DefaultAnnotations = []string{"checksum/*", "meta.helm.sh/release-name", "meta.helm.sh/release-namespace", helmhelpers.HookAnnotation + "*"}
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir                string
+	Labels             []string
+	Annotations        []string
+	RemoveHooks        bool
+	RemovedLabels      int
+	RemovedAnnotations int
+	RemovedHooks       int
+	ModifiedFiles      []string
+}
+
+// NewCmdHelmStrip creates a command object for the command
+func NewCmdHelmStrip() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "strip",
+		Short:   "Removes the helm specific labels and annotations from the rendered kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Labels, "label", "l", DefaultLabels, "the labels to remove which can include wildcards and a value such as 'heritage=Helm'. Replaces the default labels")
+	cmd.Flags().StringArrayVarP(&o.Annotations, "annotation", "a", DefaultAnnotations, "the annotations to remove which can include wildcards and a value such as 'checksum/*'. Replaces the default annotations")
+	cmd.Flags().BoolVarP(&o.RemoveHooks, "remove-hooks", "", false, "removes the resources which are helm hooks")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.RemovedLabels = 0
+	o.RemovedAnnotations = 0
+	o.RemovedHooks = 0
+	o.ModifiedFiles = nil
+	err := filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		return o.stripFile(path)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to strip the helm metadata of the resources in dir %s", o.Dir)
+	}
+	log.Logger().Infof("removed %s labels, %s annotations and %s hook resources in %d files",
+		termcolor.ColorInfo(fmt.Sprintf("%d", o.RemovedLabels)),
+		termcolor.ColorInfo(fmt.Sprintf("%d", o.RemovedAnnotations)),
+		termcolor.ColorInfo(fmt.Sprintf("%d", o.RemovedHooks)),
+		len(o.ModifiedFiles))
+	return nil
+}
+
+// stripFile strips the resources in the file removing the file if all of its resources are removed hooks
+func (o *Options) stripFile(path string) error {
+	resources, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	modified := false
+	var answer []*unstructured.Unstructured
+	for _, u := range resources {
+		if !resourcehelpers.IsResource(u) || !o.Selector.Matches(u) {
+			answer = append(answer, u)
+			continue
+		}
+		if o.RemoveHooks && helmhelpers.IsHook(u) {
+			log.Logger().Debugf("removing the hook %s from %s", resourcehelpers.ResourceKey(u), path)
+			o.RemovedHooks++
+			modified = true
+			continue
+		}
+		var podTemplatePath []string
+		podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+		if len(podSpecPath) > 1 {
+			podTemplatePath = podSpecPath[:len(podSpecPath)-1]
+		}
+		labels, annotations := helmhelpers.RemoveMetadata(u, podTemplatePath, nonBlank(o.Labels), nonBlank(o.Annotations))
+		if len(labels) > 0 || len(annotations) > 0 {
+			o.RemovedLabels += len(labels)
+			o.RemovedAnnotations += len(annotations)
+			modified = true
+		}
+		answer = append(answer, u)
+	}
+	if !modified {
+		return nil
+	}
+	o.ModifiedFiles = append(o.ModifiedFiles, path)
+	if len(answer) == 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove file %s", path)
+		}
+		return nil
+	}
+	return resourcehelpers.SaveFile(answer, path)
+}
+
+// nonBlank returns the non blank values so that a blank flag can be used to disable the defaults
+func nonBlank(values []string) []string {
+	var answer []string
+	for _, v := range values {
+		if v != "" {
+			answer = append(answer, v)
+		}
+	}
+	return answer
+}
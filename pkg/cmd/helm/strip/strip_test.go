@@ -0,0 +1,74 @@
+package strip_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/strip"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	fileNames = []string{"admission-webhook-job.yaml", "configmap.yaml", "controller-deployment.yaml", "controller-service.yaml"}
+)
+
+func TestHelmStrip(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := strip.NewCmdHelmStrip()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to strip")
+
+	assert.Equal(t, 7, o.RemovedLabels, "removed labels")
+	assert.Equal(t, 7, o.RemovedAnnotations, "removed annotations")
+	assert.Equal(t, 0, o.RemovedHooks, "removed hooks")
+	assert.Len(t, o.ModifiedFiles, 3, "modified files")
+
+	for _, name := range fileNames {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected", "nginx-ingress", name), filepath.Join(tmpDir, "nginx-ingress", name))
+	}
+
+	// lets check running again does not change anything
+	err = o.Run()
+	require.NoError(t, err, "failed to strip again")
+	assert.Equal(t, 0, o.RemovedLabels, "removed labels on the second run")
+	assert.Equal(t, 0, o.RemovedAnnotations, "removed annotations on the second run")
+	assert.Empty(t, o.ModifiedFiles, "modified files on the second run")
+}
+
+func TestHelmStripRemoveHooks(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := strip.NewCmdHelmStrip()
+	o.Dir = tmpDir
+	o.RemoveHooks = true
+	err := o.Run()
+	require.NoError(t, err, "failed to strip")
+
+	assert.Equal(t, 6, o.RemovedLabels, "removed labels")
+	assert.Equal(t, 5, o.RemovedAnnotations, "removed annotations")
+	assert.Equal(t, 1, o.RemovedHooks, "removed hooks")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "nginx-ingress", "admission-webhook-job.yaml"), "the hook file should be removed")
+
+	for _, name := range fileNames[1:] {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected", "nginx-ingress", name), filepath.Join(tmpDir, "nginx-ingress", name))
+	}
+}
+
+func TestHelmStripCustomMetadata(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := strip.NewCmdHelmStrip()
+	o.Dir = tmpDir
+	o.Labels = []string{"chart=nginx-ingress-1.41.3"}
+	o.Annotations = []string{"checksum/*"}
+	err := o.Run()
+	require.NoError(t, err, "failed to strip")
+
+	assert.Equal(t, 1, o.RemovedLabels, "removed labels")
+	assert.Equal(t, 1, o.RemovedAnnotations, "removed annotations")
+	assert.Equal(t, []string{filepath.Join(tmpDir, "nginx-ingress", "controller-deployment.yaml")}, o.ModifiedFiles, "modified files")
+}
@@ -0,0 +1,146 @@
+package valuesmerge
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Deep merges helm values files in order into a single values file
+
+		The values files are merged in the order they are specified in the same way as helm so that objects are merged recursively, the scalar values of later files win and a null value removes a field. Lists are replaced unless --merge-lists is specified which merges the objects in the lists with the same name and appends the other elements. The strategy of the list at a dot separated path can be specified via --list-merge.
+
+		The keys of the merged values are sorted so the output is the same for the same input files such as for caching.
+`)
+
+	cmdExample = templates.Examples(`
+		# merges the values files writing the result to stdout
+		%s helm values-merge --values values.yaml --values prod.yaml
+
+		# merges the values files into a file merging the lists by name
+		%s helm values-merge --values values.yaml --values prod.yaml --merge-lists --output merged.yaml
+
+		# merges the values files appending the tolerations
+		%s helm values-merge --values values.yaml --values prod.yaml --list-merge tolerations=append
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.MergeOptions
+	ValuesFiles []string
+	OutFile     string
+	MergeLists  bool
+	Values      map[string]interface{}
+	Out         io.Writer
+}
+
+// NewCmdHelmValuesMerge creates a command object for the command
+func NewCmdHelmValuesMerge() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "values-merge",
+		Short:   "Deep merges helm values files in order into a single values file",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&o.ValuesFiles, "values", "f", nil, "the values files to merge in order. Can be specified multiple times")
+	cmd.Flags().StringVarP(&o.OutFile, "output", "o", "", "the file to write the merged values to. Defaults to stdout")
+	cmd.Flags().BoolVarP(&o.MergeLists, "merge-lists", "", false, "merges the objects in lists which have the same name and appends the other elements rather than replacing the lists")
+	cmd.Flags().StringArrayVarP(&o.ListMerges, "list-merge", "", nil, "the merge strategy of the list at a dot separated path in the format 'path=strategy' where the strategy is replace, append or merge-by-key=<field> such as 'tolerations=append'")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if len(o.ValuesFiles) == 0 {
+		return options.MissingOption("values")
+	}
+	// the default merge keys are for kubernetes resources rather than values
+	o.NoDefaultListMerges = true
+	o.DefaultStrategy = resourcehelpers.ListMergeStrategy{}
+	if o.MergeLists {
+		o.DefaultStrategy = resourcehelpers.ListMergeStrategy{Type: resourcehelpers.ListMergeByKey, Key: "name"}
+	}
+	err := o.MergeOptions.Validate()
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Values = map[string]interface{}{}
+	for _, path := range o.ValuesFiles {
+		values, err := LoadValuesFile(path)
+		if err != nil {
+			return err
+		}
+		o.Values = o.Merge(o.Values, values)
+	}
+
+	data, err := resourcehelpers.ToYAML([]*unstructured.Unstructured{{Object: o.Values}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the merged values")
+	}
+	if o.OutFile == "" {
+		_, err = o.Out.Write(data)
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(o.OutFile), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(o.OutFile))
+	}
+	err = ioutil.WriteFile(o.OutFile, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", o.OutFile)
+	}
+	log.Logger().Infof("merged %d values files into %s", len(o.ValuesFiles), termcolor.ColorInfo(o.OutFile))
+	return nil
+}
+
+// LoadValuesFile loads the values in the file returning empty values if the file has no values
+func LoadValuesFile(path string) (map[string]interface{}, error) {
+	docs, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch len(docs) {
+	case 0:
+		return map[string]interface{}{}, nil
+	case 1:
+		return docs[0].Object, nil
+	default:
+		return nil, errors.Errorf("the values file %s should contain a single YAML document but has %d", path, len(docs))
+	}
+}
@@ -0,0 +1,88 @@
+package valuesmerge_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/valuesmerge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelmValuesMerge(t *testing.T) {
+	buf := &bytes.Buffer{}
+	_, o := valuesmerge.NewCmdHelmValuesMerge()
+	o.ValuesFiles = []string{filepath.Join("test_data", "values.yaml"), filepath.Join("test_data", "empty.yaml"), filepath.Join("test_data", "prod.yaml")}
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err, "failed to merge the values")
+
+	assert.Equal(t, `env:
+- name: LOG_LEVEL
+  value: warn
+- name: REGION
+  value: eu
+image:
+  pullPolicy: IfNotPresent
+  repository: ghcr.io/org/myapp
+  tag: 1.2.0
+replicaCount: 3
+service:
+  port: 80
+tolerations:
+- key: dedicated
+  operator: Equal
+  value: prod
+`, buf.String(), "merged values")
+}
+
+func TestHelmValuesMergeLists(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	outFile := filepath.Join(tmpDir, "merged", "values.yaml")
+
+	_, o := valuesmerge.NewCmdHelmValuesMerge()
+	o.ValuesFiles = []string{filepath.Join("test_data", "values.yaml"), filepath.Join("test_data", "prod.yaml")}
+	o.OutFile = outFile
+	o.MergeLists = true
+	o.ListMerges = []string{"tolerations=replace"}
+	err = o.Run()
+	require.NoError(t, err, "failed to merge the values")
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "LOG_LEVEL", "value": "warn"},
+		map[string]interface{}{"name": "CACHE", "value": "true"},
+		map[string]interface{}{"name": "REGION", "value": "eu"},
+	}, o.Values["env"], "the env should be merged by name")
+	assert.Len(t, o.Values["tolerations"], 1, "the tolerations should be replaced")
+
+	data, err := ioutil.ReadFile(outFile)
+	require.NoError(t, err, "failed to load the merged values")
+
+	// lets check the output is the same each time
+	for i := 0; i < 5; i++ {
+		err = o.Run()
+		require.NoError(t, err, "failed to merge the values again")
+		again, err := ioutil.ReadFile(outFile)
+		require.NoError(t, err, "failed to load the merged values")
+		assert.Equal(t, string(data), string(again), "the merged values should be stable")
+	}
+}
+
+func TestHelmValuesMergeInvalid(t *testing.T) {
+	_, o := valuesmerge.NewCmdHelmValuesMerge()
+	err := o.Run()
+	require.Error(t, err, "should fail without values files")
+
+	o.ValuesFiles = []string{filepath.Join("test_data", "values.yaml")}
+	o.ListMerges = []string{"tolerations=sort"}
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid list merge")
+
+	o.ListMerges = nil
+	o.ValuesFiles = []string{filepath.Join("test_data", "missing.yaml")}
+	err = o.Run()
+	require.Error(t, err, "should fail for a missing values file")
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/list"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/pin"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/set"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-gitops/pkg/versionstreamer"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
@@ -91,6 +95,9 @@ func NewCmdUpdateImage() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.SourceDir, "source-dir", "s", "content-root", "the directory to recursively look for the *.yaml files to modify")
 	o.Filter.AddFlags(cmd)
 	o.VersionStreamer.AddFlags(cmd)
+	cmd.AddCommand(cobras.SplitCommand(list.NewCmdImageList()))
+	cmd.AddCommand(cobras.SplitCommand(pin.NewCmdImagePin()))
+	cmd.AddCommand(cobras.SplitCommand(set.NewCmdImageSet()))
 	return cmd, o
 }
 
@@ -0,0 +1,54 @@
+package list
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lists an inventory of the container images used by the kubernetes resources in the given directory tree
+
+		The images of the containers, init containers and ephemeral containers of the pods, workloads, jobs and cron jobs are listed once each with their tag or digest and the resources which use them. With --output json or --output yaml the files containing the resources are also included.
+
+		If --fail-on is specified the command fails if any images match the patterns so that forbidden images can be detected in a pipeline. A pattern starting with ':' matches the tag of the image where ':latest' also matches images without a tag or digest. Other patterns are matched against the image both as it is written and with its registry such as 'docker.io/library/*' where '*' matches any characters other than '/'.
+`)
+
+	cmdExample = templates.Examples(`
+		# lists the images used in the current directory
+		%s image list --dir .
+
+		# lists the images and the files which use them as JSON
+		%s image list --dir config-root --output json
+
+		# fails if any images use the latest tag or are official docker hub images
+		%s image list --fail-on :latest --fail-on 'docker.io/library/*'
+	`)
+)
+
+// NewCmdImageList creates a command object for the command
+func NewCmdImageList() (*cobra.Command, *imagelist.Options) {
+	o := &imagelist.Options{
+		WithFiles:     true,
+		WithResources: true,
+	}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "Lists an inventory of the container images used by the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd, o
+}
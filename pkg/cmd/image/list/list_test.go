@@ -0,0 +1,60 @@
+package list_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/list"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageList(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := list.NewCmdImageList()
+	o.Dir = "test_data"
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to list the images")
+	assert.Equal(t, `IMAGE                 TAG/DIGEST  RESOURCES
+busybox               1.33        jx/CronJob/backup,jx/Deployment/web
+ghcr.io/myorg/backup              jx/CronJob/backup
+ghcr.io/myorg/web     1.2.3       jx/Deployment/web
+`, out.String(), "the default output should be a table")
+}
+
+func TestImageListJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := list.NewCmdImageList()
+	o.Dir = "test_data"
+	o.Format = "json"
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to list the images")
+
+	images := map[string]*imagelist.Image{}
+	err = json.Unmarshal(out.Bytes(), &images)
+	require.NoError(t, err, "failed to parse the JSON output %s", out.String())
+	require.Len(t, images, 3, "images")
+	assert.Equal(t, &imagelist.Image{
+		Registry:   "docker.io",
+		Repository: "busybox",
+		Tag:        "1.33",
+		Resources:  []string{"jx/CronJob/backup", "jx/Deployment/web"},
+		Files:      []string{filepath.Join("jx", "cronjob.yaml"), filepath.Join("jx", "deployment.yaml")},
+	}, images["busybox:1.33"], "image used by both resources")
+	assert.Equal(t, []string{filepath.Join("jx", "deployment.yaml")}, images["ghcr.io/myorg/web:1.2.3"].Files, "files of the web image")
+}
+
+func TestImageListFailOn(t *testing.T) {
+	_, o := list.NewCmdImageList()
+	o.Dir = "test_data"
+	o.Out = &bytes.Buffer{}
+	o.FailOn = []string{":latest", "ghcr.io/otherorg/*"}
+	err := o.Run()
+	require.Error(t, err, "should fail as an image has no tag")
+	assert.Equal(t, []string{"ghcr.io/myorg/backup"}, o.Forbidden, "forbidden images")
+}
@@ -0,0 +1,218 @@
+package pin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/set"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/registries"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Pins the container images of the workloads in the given directory tree to the digests of their tags
+
+		The tag of each image of the containers and init containers of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector is resolved to the digest of its manifest using the docker registry v2 API of Docker Hub, GHCR, GCR, Artifact Registry or any other registry. Images without a tag use the 'latest' tag. The image is then replaced with 'repository@digest' and the original image is recorded in the annotation 'pin.gitops.jenkins-x.io/<container>' of the resource.
+
+		The credentials of the registries are loaded from the docker config file including its credHelpers and credsStore and any --registry-auth values which take precedence. Images which already have a digest or are go templates are left as they are. If the digest of an image cannot be resolved the other images are still pinned and the command fails at the end with the errors of all the images which could not be resolved.
+
+		If --check is specified the registries are not used and no files are modified. The unpinned images are reported and the command fails if there are any.
+`)
+
+	cmdExample = templates.Examples(`
+		# pins all the images to their digests
+		%s image pin --dir .
+
+		# pins the images using the credentials of a private registry
+		%s image pin --registry-auth ghcr.io=myuser:$GITHUB_TOKEN
+
+		# fails if any images are not pinned
+		%s image pin --check
+	`)
+)
+
+const (
+	// AnnotationPrefix the prefix of the annotations recording the original image of each container
+	AnnotationPrefix = "pin.gitops.jenkins-x.io/"
+)
+
+// Unpinned a container whose image is not pinned to a digest
+type Unpinned struct {
+	// File the file containing the resource
+	File string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Container the name of the container
+	Container string
+
+	// Image the image of the container
+	Image string
+}
+
+// String returns a description of the unpinned image
+func (u *Unpinned) String() string {
+	return fmt.Sprintf("%s %s container %s: %s", u.File, u.Resource, u.Container, u.Image)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir          string
+	Check        bool
+	DockerConfig string
+	RegistryAuth []string
+	Client       registries.Client
+	Changes      []set.Change
+	Unpinned     []Unpinned
+	Skipped      []string
+	Errors       *common.ErrorList
+	digests      map[string]string
+	failed       map[string]bool
+}
+
+// NewCmdImagePin creates a command object for the command
+func NewCmdImagePin() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "pin",
+		Short:   "Pins the container images of the workloads in the given directory tree to the digests of their tags",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Check, "check", "", false, "only reports the images which are not pinned failing if there are any")
+	cmd.Flags().StringVarP(&o.DockerConfig, "docker-config", "", registries.DefaultDockerConfigFile(), "the docker config file containing the credentials of the registries")
+	cmd.Flags().StringArrayVarP(&o.RegistryAuth, "registry-auth", "", nil, "the credentials of a registry of the form 'host=user:password'. Can be specified multiple times")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Check || o.Client != nil {
+		return nil
+	}
+	creds, err := registries.LoadDockerConfig(o.DockerConfig)
+	if err != nil {
+		return err
+	}
+	for _, text := range o.RegistryAuth {
+		host, c, err := registries.ParseCredentials(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid --registry-auth value")
+		}
+		creds.Merge(registries.CredentialSet{host: c})
+	}
+	o.Client = registries.NewClient(creds)
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Changes = nil
+	o.Unpinned = nil
+	o.Errors = common.NewErrorList("image")
+	o.digests = map[string]string{}
+	o.failed = map[string]bool{}
+	visitor := &set.ImageVisitor{Selector: o.Selector}
+	err = visitor.Visit(o.Dir, o.pinImage)
+	o.Skipped = visitor.Skipped
+	if err != nil {
+		return errors.Wrapf(err, "failed to pin the images in dir %s", o.Dir)
+	}
+
+	if o.Check {
+		for i := range o.Unpinned {
+			log.Logger().Infof("unpinned %s", termcolor.ColorWarning(o.Unpinned[i].String()))
+		}
+		if len(o.Unpinned) > 0 {
+			return errors.Errorf("found %d containers whose images are not pinned to a digest", len(o.Unpinned))
+		}
+		log.Logger().Infof("all the images are pinned to a digest")
+		return nil
+	}
+	for i := range o.Changes {
+		log.Logger().Infof("pinned %s", termcolor.ColorInfo(o.Changes[i].String()))
+	}
+	log.Logger().Infof("pinned the image of %d containers", len(o.Changes))
+	return o.Errors.ErrorOrNil()
+}
+
+// pinImage returns the image pinned to the digest of its tag recording the original image in an annotation of the
+// resource. In check mode the image is recorded as unpinned and left as it is
+func (o *Options) pinImage(u *unstructured.Unstructured, path, container, image string) (string, error) {
+	img := imagelist.ParseImage(image)
+	if img.Digest != "" {
+		return image, nil
+	}
+	key := resourcehelpers.ResourceKey(u)
+	if o.Check {
+		o.Unpinned = append(o.Unpinned, Unpinned{File: path, Resource: key, Container: container, Image: image})
+		return image, nil
+	}
+	digest := o.resolveDigest(img, image, path)
+	if digest == "" {
+		return image, nil
+	}
+	newImage := image
+	if img.Tag != "" {
+		newImage = strings.TrimSuffix(image, ":"+img.Tag)
+	}
+	newImage += "@" + digest
+
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationPrefix+container] = image
+	u.SetAnnotations(annotations)
+	o.Changes = append(o.Changes, set.Change{File: path, Resource: key, Container: container, From: image, To: newImage})
+	return newImage, nil
+}
+
+// resolveDigest returns the digest of the image caching the results so that each image is only resolved once.
+// Returns blank if the digest could not be resolved recording the error the first time
+func (o *Options) resolveDigest(img *imagelist.Image, image, path string) string {
+	if digest, ok := o.digests[image]; ok {
+		return digest
+	}
+	if o.failed[image] {
+		return ""
+	}
+	tag := img.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	digest, err := o.Client.Digest(img.Registry, img.Repository, tag)
+	if err != nil {
+		o.failed[image] = true
+		o.Errors.Append(&common.ErrorItem{Path: path, Phase: "resolve digest", Err: errors.Wrapf(err, "failed to resolve the digest of image %s", image)})
+		log.Logger().Warnf("failed to resolve the digest of image %s: %s", termcolor.ColorWarning(image), err.Error())
+		return ""
+	}
+	o.digests[image] = digest
+	return digest
+}
@@ -0,0 +1,113 @@
+package pin_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/pin"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/set"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakeClient a fake registry client returning the digests of the images of the form 'registry/repository:tag'
+type fakeClient struct {
+	digests  map[string]string
+	requests []string
+}
+
+func (c *fakeClient) Digest(registry, repository, tag string) (string, error) {
+	image := registry + "/" + repository + ":" + tag
+	c.requests = append(c.requests, image)
+	digest, ok := c.digests[image]
+	if !ok {
+		return "", errors.Errorf("manifest unknown")
+	}
+	return digest, nil
+}
+
+func TestImagePin(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deployment.yaml")
+	cronJobFile := filepath.Join(tmpDir, "cronjob.yaml")
+
+	client := &fakeClient{
+		digests: map[string]string{
+			"ghcr.io/org/app:latest":             "sha256:aaaa",
+			"ghcr.io/org/app:1.0.0":              "sha256:bbbb",
+			"docker.io/envoyproxy/envoy:v1.16.0": "sha256:cccc",
+			"gcr.io/org/pinned:1.0.0":            "sha256:eeee",
+		},
+	}
+	_, o := pin.NewCmdImagePin()
+	o.Dir = tmpDir
+	o.Client = client
+	err := o.Run()
+	require.Error(t, err, "should fail as an image could not be resolved")
+
+	var list *common.ErrorList
+	require.True(t, errors.As(err, &list), "the error should contain the images which failed")
+	require.Equal(t, 1, list.Len(), "failed images")
+	assert.Equal(t, cronJobFile, list.Items[0].Path, "file of the failed image")
+	assert.Contains(t, list.Items[0].Error(), "localhost:5000/org/missing:2.0", "error of the failed image")
+
+	assert.Equal(t, []set.Change{
+		{File: deployFile, Resource: "Deployment/web", Container: "web", From: "ghcr.io/org/app:1.0.0", To: "ghcr.io/org/app@sha256:bbbb"},
+		{File: deployFile, Resource: "Deployment/web", Container: "proxy", From: "envoyproxy/envoy:v1.16.0", To: "envoyproxy/envoy@sha256:cccc"},
+		{File: deployFile, Resource: "Deployment/web", Container: "migrate", From: "ghcr.io/org/app", To: "ghcr.io/org/app@sha256:aaaa"},
+	}, o.Changes, "changes")
+	assert.ElementsMatch(t, []string{"localhost:5000/org/missing:2.0", "ghcr.io/org/app:1.0.0", "docker.io/envoyproxy/envoy:v1.16.0", "ghcr.io/org/app:latest"}, client.requests, "each image should be resolved once")
+
+	u := testhelpers.LoadResource(t, deployFile)
+	assert.Equal(t, map[string]string{
+		"owner":                           "web-team",
+		"pin.gitops.jenkins-x.io/web":     "ghcr.io/org/app:1.0.0",
+		"pin.gitops.jenkins-x.io/proxy":   "envoyproxy/envoy:v1.16.0",
+		"pin.gitops.jenkins-x.io/migrate": "ghcr.io/org/app",
+	}, u.GetAnnotations(), "annotations of the deployment")
+	containers, _, err := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err, "failed to get the containers")
+	assert.Equal(t, "gcr.io/org/pinned:1.0.0@sha256:1111", containers[2].(map[string]interface{})["image"], "the pinned image should not be modified")
+
+	assert.Empty(t, testhelpers.LoadResource(t, cronJobFile).GetAnnotations(), "the cron job should not be modified")
+
+	// lets check the images are now pinned apart from the one which failed
+	_, o = pin.NewCmdImagePin()
+	o.Dir = tmpDir
+	o.Check = true
+	err = o.Run()
+	require.Error(t, err, "should fail as an image is not pinned")
+	assert.Equal(t, []pin.Unpinned{
+		{File: cronJobFile, Resource: "CronJob/cleanup", Container: "cleanup", Image: "localhost:5000/org/missing:2.0"},
+	}, o.Unpinned, "unpinned images")
+	assert.Empty(t, o.Changes, "check should not modify anything")
+}
+
+func TestImagePinCheck(t *testing.T) {
+	_, o := pin.NewCmdImagePin()
+	o.Dir = "test_data"
+	o.Check = true
+	o.Kinds = []string{"Deployment"}
+	err := o.Run()
+	require.Error(t, err, "should fail as the images are not pinned")
+	assert.Contains(t, err.Error(), "found 3 containers whose images are not pinned", "error message")
+	assert.Nil(t, o.Client, "the registries should not be used")
+
+	o.Kinds = []string{"Service"}
+	err = o.Run()
+	require.NoError(t, err, "should not fail if there are no unpinned images")
+}
+
+func TestImagePinInvalidRegistryAuth(t *testing.T) {
+	_, o := pin.NewCmdImagePin()
+	o.Dir = "test_data"
+	o.DockerConfig = ""
+	o.RegistryAuth = []string{"ghcr.io"}
+	err := o.Run()
+	require.Error(t, err, "should fail for invalid registry credentials")
+	assert.Contains(t, err.Error(), "--registry-auth", "error message")
+}
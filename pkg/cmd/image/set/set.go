@@ -0,0 +1,206 @@
+package set
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Sets the tag or digest of the container images of the workloads in the given directory tree
+
+		The containers and init containers of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector whose image is in the repository given by --image are modified. Images without a registry match the docker.io registry. The registry, repository and any tag of the image are kept as they are written in the resource.
+
+		If --tag is specified the tag is replaced and any digest removed. If only --digest is specified the digest is replaced keeping any tag. Images which are go templates such as '{{ .Values.image }}' and files which cannot be parsed because they contain templates are skipped with a warning.
+
+		If --expect-changes is specified the command fails unless exactly that number of containers are modified so that pipelines can check the images were updated.
+`)
+
+	cmdExample = templates.Examples(`
+		# sets the tag of the image in all the resources
+		%s image set --image ghcr.io/myorg/myapp --tag 1.2.3
+
+		# pins the image of the web container of a deployment to a digest and checks it was modified
+		%s image set --image myorg/myapp --digest sha256:0123456789abcdef --kind Deployment --name web --container web --expect-changes 1
+	`)
+
+	// containerFields the fields of a pod spec which contain the containers whose images are visited
+	containerFields = []string{"containers", "initContainers"}
+)
+
+// Change a container whose image has been modified
+type Change struct {
+	// File the file containing the resource
+	File string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Container the name of the container
+	Container string
+
+	// From the image before it was modified
+	From string
+
+	// To the new image
+	To string
+}
+
+// String returns a description of the change
+func (c *Change) String() string {
+	return fmt.Sprintf("%s %s container %s: %s => %s", c.File, c.Resource, c.Container, c.From, c.To)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir           string
+	Image         string
+	Tag           string
+	Digest        string
+	Containers    []string
+	ExpectChanges int
+	Changes       []Change
+	Skipped       []string
+	image         *imagelist.Image
+}
+
+// NewCmdImageSet creates a command object for the command
+func NewCmdImageSet() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "set",
+		Short:   "Sets the tag or digest of the container images of the workloads in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Image, "image", "i", "", "the repository of the images to modify such as 'ghcr.io/myorg/myapp'")
+	cmd.Flags().StringVarP(&o.Tag, "tag", "t", "", "the new tag of the images")
+	cmd.Flags().StringVarP(&o.Digest, "digest", "", "", "the new digest of the images such as 'sha256:0123456789abcdef'")
+	cmd.Flags().StringArrayVarP(&o.Containers, "container", "c", nil, "the names of the containers to modify. If not specified all the containers are modified")
+	cmd.Flags().IntVarP(&o.ExpectChanges, "expect-changes", "", -1, "if specified the command fails unless this number of containers are modified")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Image == "" {
+		return options.MissingOption("image")
+	}
+	if o.Tag == "" && o.Digest == "" {
+		return errors.Errorf("missing option: --tag or --digest")
+	}
+	if strings.ContainsAny(o.Tag, ":@/") {
+		return errors.Errorf("invalid --tag value '%s'", o.Tag)
+	}
+	if o.Digest != "" && !strings.Contains(o.Digest, ":") {
+		return errors.Errorf("invalid --digest value '%s' should be of the form algorithm:hex such as sha256:0123456789abcdef", o.Digest)
+	}
+	o.image = imagelist.ParseImage(o.Image)
+	if o.image.Tag != "" || o.image.Digest != "" {
+		return errors.Errorf("the --image value '%s' should not include a tag or digest", o.Image)
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Changes = nil
+	visitor := &ImageVisitor{Selector: o.Selector}
+	err = visitor.Visit(o.Dir, o.modifyImage)
+	o.Skipped = visitor.Skipped
+	if err != nil {
+		return errors.Wrapf(err, "failed to set the images in dir %s", o.Dir)
+	}
+
+	for i := range o.Changes {
+		log.Logger().Infof("modified %s", termcolor.ColorInfo(o.Changes[i].String()))
+	}
+	log.Logger().Infof("modified the image of %d containers", len(o.Changes))
+	if o.ExpectChanges >= 0 && len(o.Changes) != o.ExpectChanges {
+		return errors.Errorf("expected %d containers to be modified but %d were modified", o.ExpectChanges, len(o.Changes))
+	}
+	return nil
+}
+
+// modifyImage returns the image with the new tag or digest if the container should be modified recording the change
+func (o *Options) modifyImage(u *unstructured.Unstructured, path, container, image string) (string, error) {
+	if len(o.Containers) > 0 && stringhelpers.StringArrayIndex(o.Containers, container) < 0 {
+		return image, nil
+	}
+	newImage, ok := o.SetImage(image)
+	if !ok || newImage == image {
+		return image, nil
+	}
+	o.Changes = append(o.Changes, Change{File: path, Resource: resourcehelpers.ResourceKey(u), Container: container, From: image, To: newImage})
+	return newImage, nil
+}
+
+// SetImage returns the image with the new tag or digest and true if the image is in the repository of the options
+func (o *Options) SetImage(image string) (string, bool) {
+	img := imagelist.ParseImage(image)
+	if !sameRepository(img, o.image) {
+		return image, false
+	}
+
+	// lets keep the name of the image as it is written
+	name := image
+	idx := strings.Index(name, "@")
+	if idx >= 0 {
+		name = name[:idx]
+	}
+	if img.Tag != "" {
+		name = strings.TrimSuffix(name, ":"+img.Tag)
+	}
+
+	tag, digest := img.Tag, o.Digest
+	if o.Tag != "" {
+		tag = o.Tag
+	}
+	answer := name
+	if tag != "" {
+		answer += ":" + tag
+	}
+	if digest != "" {
+		answer += "@" + digest
+	}
+	return answer, true
+}
+
+// sameRepository returns true if the images are in the same repository of the same registry
+func sameRepository(a, b *imagelist.Image) bool {
+	return a.Registry == b.Registry && normalizeRepository(a) == normalizeRepository(b)
+}
+
+// normalizeRepository returns the repository adding the 'library/' prefix of the official docker hub images
+func normalizeRepository(img *imagelist.Image) string {
+	if img.Registry == imagelist.DefaultRegistry && !strings.Contains(img.Repository, "/") {
+		return "library/" + img.Repository
+	}
+	return img.Repository
+}
@@ -0,0 +1,144 @@
+package set_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/image/set"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestImageSet(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	cronJobFile := filepath.Join(tmpDir, "cronjob.yaml")
+	deployFile := filepath.Join(tmpDir, "deployment.yaml")
+	templatedFile := filepath.Join(tmpDir, "templated.yaml")
+
+	_, o := set.NewCmdImageSet()
+	o.Dir = tmpDir
+	o.Image = "ghcr.io/org/app"
+	o.Tag = "1.2.3"
+	o.ExpectChanges = 3
+	err := o.Run()
+	require.NoError(t, err, "failed to set the images")
+
+	assert.Equal(t, []set.Change{
+		{File: cronJobFile, Resource: "CronJob/cleanup", Container: "cleanup", From: "ghcr.io/org/app:0.9.0@sha256:0000", To: "ghcr.io/org/app:1.2.3"},
+		{File: deployFile, Resource: "Deployment/web", Container: "web", From: "ghcr.io/org/app:1.0.0", To: "ghcr.io/org/app:1.2.3"},
+		{File: deployFile, Resource: "Deployment/web", Container: "migrate", From: "ghcr.io/org/app", To: "ghcr.io/org/app:1.2.3"},
+	}, o.Changes, "changes")
+	assert.Equal(t, []string{templatedFile}, o.Skipped, "skipped files")
+
+	assert.Equal(t, []string{"ghcr.io/org/app:1.2.3", "envoyproxy/envoy:v1.16.0", "ghcr.io/org/app-other:1.0.0", "ghcr.io/org/app:1.2.3"},
+		loadImages(t, deployFile, "spec", "template", "spec"), "images of the deployment")
+	assert.Equal(t, []string{"localhost:5000/org/app:1.0.0", "{{ .Values.image }}"},
+		loadImages(t, filepath.Join(tmpDir, "statefulset.yaml"), "spec", "template", "spec"), "images of the statefulset in another registry")
+
+	// setting the same tag again should not modify anything
+	o.ExpectChanges = -1
+	err = o.Run()
+	require.NoError(t, err, "failed to set the images again")
+	assert.Empty(t, o.Changes, "changes on second run")
+
+	// lets fail if the expected changes are not made
+	o.ExpectChanges = 1
+	err = o.Run()
+	require.Error(t, err, "should fail as no containers were modified")
+	assert.Contains(t, err.Error(), "expected 1 containers to be modified but 0 were modified", "error message")
+}
+
+func TestImageSetDigestSelector(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	statefulSetFile := filepath.Join(tmpDir, "statefulset.yaml")
+
+	_, o := set.NewCmdImageSet()
+	o.Dir = tmpDir
+	o.Image = "localhost:5000/org/app"
+	o.Digest = "sha256:abcd"
+	o.Kinds = []string{"StatefulSet"}
+	o.Containers = []string{"db"}
+	o.ExpectChanges = 1
+	err := o.Run()
+	require.NoError(t, err, "failed to set the images")
+
+	require.Len(t, o.Changes, 1, "changes")
+	assert.Equal(t, "localhost:5000/org/app:1.0.0@sha256:abcd", o.Changes[0].To, "new image")
+	assert.Equal(t, []string{"localhost:5000/org/app:1.0.0@sha256:abcd", "{{ .Values.image }}"},
+		loadImages(t, statefulSetFile, "spec", "template", "spec"), "images of the statefulset")
+}
+
+func TestSetImage(t *testing.T) {
+	testCases := []struct {
+		image       string
+		tag         string
+		digest      string
+		input       string
+		expected    string
+		expectedSet bool
+	}{
+		{image: "ghcr.io/org/app", tag: "2.0", input: "ghcr.io/org/app:1.0", expected: "ghcr.io/org/app:2.0", expectedSet: true},
+		{image: "ghcr.io/org/app", tag: "2.0", input: "ghcr.io/org/app", expected: "ghcr.io/org/app:2.0", expectedSet: true},
+		{image: "ghcr.io/org/app", tag: "2.0", input: "ghcr.io/org/app:1.0@sha256:abc", expected: "ghcr.io/org/app:2.0", expectedSet: true},
+		{image: "ghcr.io/org/app", digest: "sha256:def", input: "ghcr.io/org/app:1.0@sha256:abc", expected: "ghcr.io/org/app:1.0@sha256:def", expectedSet: true},
+		{image: "ghcr.io/org/app", tag: "2.0", digest: "sha256:def", input: "ghcr.io/org/app:1.0", expected: "ghcr.io/org/app:2.0@sha256:def", expectedSet: true},
+		{image: "ghcr.io/org/app", tag: "2.0", input: "ghcr.io/org/app-other:1.0", expected: "ghcr.io/org/app-other:1.0"},
+		{image: "ghcr.io/org/app", tag: "2.0", input: "docker.io/org/app:1.0", expected: "docker.io/org/app:1.0"},
+		{image: "registry.local:5000/app", tag: "2.0", input: "registry.local:5000/app:1.0", expected: "registry.local:5000/app:2.0", expectedSet: true},
+		{image: "registry.local:5000/app", tag: "2.0", input: "registry.local:5000/app", expected: "registry.local:5000/app:2.0", expectedSet: true},
+		{image: "nginx", tag: "1.19", input: "docker.io/library/nginx:1.18", expected: "docker.io/library/nginx:1.19", expectedSet: true},
+		{image: "docker.io/library/nginx", tag: "1.19", input: "nginx", expected: "nginx:1.19", expectedSet: true},
+		{image: "nginx", tag: "1.19", input: "myorg/nginx:1.18", expected: "myorg/nginx:1.18"},
+	}
+	for _, tc := range testCases {
+		_, o := set.NewCmdImageSet()
+		o.Image = tc.image
+		o.Tag = tc.tag
+		o.Digest = tc.digest
+		require.NoError(t, o.Validate(), "failed to validate the options for %s", tc.image)
+
+		actual, ok := o.SetImage(tc.input)
+		assert.Equal(t, tc.expectedSet, ok, "matched %s for %s", tc.input, tc.image)
+		assert.Equal(t, tc.expected, actual, "image %s for %s", tc.input, tc.image)
+	}
+}
+
+func TestImageSetInvalid(t *testing.T) {
+	for _, values := range [][]string{
+		{"", "1.0", ""},
+		{"ghcr.io/org/app", "", ""},
+		{"ghcr.io/org/app:1.0", "2.0", ""},
+		{"ghcr.io/org/app", "2.0:bad", ""},
+		{"ghcr.io/org/app", "", "abcd"},
+	} {
+		_, o := set.NewCmdImageSet()
+		o.Dir = "test_data"
+		o.Image = values[0]
+		o.Tag = values[1]
+		o.Digest = values[2]
+		err := o.Run()
+		assert.Error(t, err, "should fail for %v", values)
+	}
+}
+
+// loadImages returns the images of the containers then the init containers of the pod spec of the first resource
+func loadImages(t *testing.T, path string, podSpecPath ...string) []string {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.NotEmpty(t, resources, "resources in %s", path)
+
+	var answer []string
+	for _, field := range []string{"containers", "initContainers"} {
+		fields := append(append([]string{}, podSpecPath...), field)
+		containers, _, err := unstructured.NestedSlice(resources[0].Object, fields...)
+		require.NoError(t, err, "failed to get %s of %s", field, path)
+		for _, c := range containers {
+			image, _ := c.(map[string]interface{})["image"].(string)
+			answer = append(answer, image)
+		}
+	}
+	return answer
+}
@@ -0,0 +1,120 @@
+package set
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageFn returns the new image of the named container of the resource in the given file or the same image if it
+// should not be modified
+type ImageFn func(u *unstructured.Unstructured, path, container, image string) (string, error)
+
+// ImageVisitor invokes a function on the image of each container and init container of the workloads matching the
+// selector in a directory tree saving the files whose images are modified. Files which cannot be parsed because they
+// contain templates and images which are templates are skipped
+type ImageVisitor struct {
+	resourcehelpers.Selector
+
+	// Skipped the files which were skipped as they contain templates which cannot be parsed
+	Skipped []string
+}
+
+// Visit invokes the function on the images of the YAML files in the directory
+func (v *ImageVisitor) Visit(dir string, fn ImageFn) error {
+	v.Skipped = nil
+	return resourcehelpers.WalkYAMLFiles(dir, v.WalkOptions, func(path, rel string, _ os.FileInfo) error {
+		return v.visitFile(path, fn)
+	})
+}
+
+// visitFile invokes the function on the images of the resources in the file saving it if it is modified
+func (v *ImageVisitor) visitFile(path string, fn ImageFn) error {
+	resources, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		data, readErr := ioutil.ReadFile(path)
+		if readErr == nil && strings.Contains(string(data), "{{") {
+			log.Logger().Warnf("skipping file %s as it contains templates which cannot be parsed: %s", termcolor.ColorWarning(path), err.Error())
+			v.Skipped = append(v.Skipped, path)
+			return nil
+		}
+		return err
+	}
+	modified := false
+	for _, u := range resources {
+		if !resourcehelpers.IsResource(u) || !v.Selector.Matches(u) {
+			continue
+		}
+		flag, err := VisitResourceImages(u, path, fn)
+		if err != nil {
+			return errors.Wrapf(err, "failed to modify the images of %s in file %s", resourcehelpers.ResourceKey(u), path)
+		}
+		if flag {
+			modified = true
+		}
+	}
+	if !modified {
+		return nil
+	}
+	return resourcehelpers.SaveFile(resources, path)
+}
+
+// VisitResourceImages invokes the function on the images of the containers of the pod spec of the resource returning
+// true if any of them were modified
+func VisitResourceImages(u *unstructured.Unstructured, path string, fn ImageFn) (bool, error) {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return false, nil
+	}
+	modified := false
+	for _, field := range containerFields {
+		fieldPath := append(append([]string{}, podSpecPath...), field)
+		containers, found, err := unstructured.NestedSlice(u.Object, fieldPath...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get %s", strings.Join(fieldPath, "."))
+		}
+		if !found {
+			continue
+		}
+		changed := false
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			image, ok := container["image"].(string)
+			if !ok || image == "" {
+				continue
+			}
+			if strings.Contains(image, "{{") {
+				log.Logger().Warnf("skipping the templated image of %s container %s in file %s", resourcehelpers.ResourceKey(u), name, path)
+				continue
+			}
+			newImage, err := fn(u, path, name, image)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to modify the image of container %s", name)
+			}
+			if newImage == image {
+				continue
+			}
+			container["image"] = newImage
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		err = unstructured.SetNestedSlice(u.Object, containers, fieldPath...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set %s", strings.Join(fieldPath, "."))
+		}
+		modified = true
+	}
+	return modified, nil
+}
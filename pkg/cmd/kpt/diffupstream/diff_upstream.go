@@ -0,0 +1,331 @@
+package diffupstream
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Displays the differences between the local kpt packages and the upstream commits they are pinned to
+
+		The pinned upstream of each package is fetched into a temporary directory and a unified diff of each file is displayed so that local changes can be reviewed before upgrading a package
+
+		If --since is specified only the packages with a file changed since the given git ref are compared unless a --package is specified
+`)
+
+	cmdExample = templates.Examples(`
+		# displays the local changes of all the kpt packages in the current directory
+		%s kpt diff-upstream
+
+		# displays the local changes of a single package
+		%s kpt diff-upstream --package config-root/namespaces/jx/lighthouse
+
+		# displays the local changes of the packages changed since the main branch
+		%s kpt diff-upstream --since origin/main
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Options the options for the command
+type Options struct {
+	common.WalkOptions
+	common.SinceOptions
+	Dir           string
+	Package       string
+	KptBinary     string
+	Context       int
+	Out           io.Writer
+	CommandRunner cmdrunner.CommandRunner
+	Diffs         []PackageDiff
+}
+
+// PackageDiff the differences between a local package and its pinned upstream
+type PackageDiff struct {
+	// Dir the directory of the package relative to the directory being walked
+	Dir string
+
+	// Expression the kpt expression of the pinned upstream package
+	Expression string
+
+	// Files the files which differ from the upstream
+	Files []FileDiff
+}
+
+// FileDiff the unified diff of a file in a package
+type FileDiff struct {
+	// Path the path of the file relative to the package directory
+	Path string
+
+	// Diff the unified diff from the upstream file to the local file
+	Diff string
+}
+
+// NewCmdKptDiffUpstream creates a command object for the command
+func NewCmdKptDiffUpstream() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "diff-upstream",
+		Short:   "Displays the differences between the local kpt packages and their pinned upstream commits",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the Kptfile files")
+	cmd.Flags().StringVarP(&o.Package, "package", "p", "", "if specified only the package in this directory relative to the --dir is compared")
+	cmd.Flags().StringVarP(&o.KptBinary, "bin", "", "", "the 'kpt' binary to use. If not specified $"+common.BinaryEnvVar("kpt")+" or the kpt binary on the PATH is used")
+	cmd.Flags().IntVarP(&o.Context, "context", "", 3, "the number of lines of context in the unified diffs")
+	o.WalkOptions.AddFlags(cmd)
+	o.SinceOptions.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.CommandRunner == nil {
+		bin, _, err := common.ResolveBinary("kpt", common.WithOverride("bin", o.KptBinary), common.WithVersionArgs("version"))
+		if err != nil {
+			return err
+		}
+		o.KptBinary = bin
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	if o.KptBinary == "" {
+		o.KptBinary = "kpt"
+	}
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	}
+	pkg := ""
+	if o.Package != "" {
+		pkg = filepath.Clean(o.Package)
+	}
+	changed, err := o.ChangedFiles(o.CommandRunner, dir)
+	if err != nil {
+		return err
+	}
+
+	o.Diffs = nil
+	walkOptions := o.WalkOptions
+	walkOptions.Patterns = []string{kptfiles.FileName}
+	err = common.WalkFiles(dir, walkOptions, func(path, rel string, _ os.FileInfo) error {
+		rel = filepath.Dir(rel)
+		if pkg != "" && rel != pkg {
+			return nil
+		}
+		if pkg == "" && !changed.ContainsDir(rel) {
+			log.Logger().Debugf("skipping %s as it has not changed since %s", rel, o.Since)
+			return nil
+		}
+		d, err := o.diffPackage(path, rel)
+		if err != nil {
+			return errors.Wrapf(err, "failed to diff package %s", rel)
+		}
+		o.Diffs = append(o.Diffs, *d)
+		return o.printDiff(d)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to diff kpt packages in dir %s", dir)
+	}
+	if pkg != "" && len(o.Diffs) == 0 {
+		return errors.Errorf("could not find a %s for package %s in dir %s", kptfiles.FileName, o.Package, dir)
+	}
+	return nil
+}
+
+func (o *Options) diffPackage(path, rel string) (*PackageDiff, error) {
+	kf, err := kptfiles.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Repo == "" {
+		return nil, &kptfiles.MissingFieldError{Path: path, Field: "upstream.git.repo", Description: "git URL"}
+	}
+	commit := kf.ResolvedCommit()
+	if commit == "" {
+		field := "upstream.git.commit"
+		if kf.IsV1() {
+			field = "upstreamLock.git.commit"
+		}
+		return nil, &kptfiles.MissingFieldError{Path: path, Field: field, Description: "pinned upstream commit"}
+	}
+	expression := kf.Expression(commit)
+
+	tmpDir, err := ioutil.TempDir("", "jx-kpt-upstream-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	c := &cmdrunner.Command{
+		Name: o.KptBinary,
+		Args: []string{"pkg", "get", expression, "upstream"},
+		Dir:  tmpDir,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch upstream %s", expression)
+	}
+
+	localFiles, err := packageFiles(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	upstreamFiles, err := packageFiles(filepath.Join(tmpDir, "upstream"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range localFiles {
+		names = append(names, name)
+	}
+	for name := range upstreamFiles {
+		if _, ok := localFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	answer := &PackageDiff{
+		Dir:        rel,
+		Expression: expression,
+	}
+	for _, name := range names {
+		text, err := o.diffFile(filepath.Join(rel, name), upstreamFiles[name], localFiles[name])
+		if err != nil {
+			return nil, err
+		}
+		if text != "" {
+			answer.Files = append(answer.Files, FileDiff{Path: name, Diff: text})
+		}
+	}
+	return answer, nil
+}
+
+// diffFile returns the unified diff of the files or blank if they are the same. A blank path
+// means the file does not exist
+func (o *Options) diffFile(name, upstreamPath, localPath string) (string, error) {
+	upstream, err := readFile(upstreamPath)
+	if err != nil {
+		return "", err
+	}
+	local, err := readFile(localPath)
+	if err != nil {
+		return "", err
+	}
+	if upstream == local {
+		return "", nil
+	}
+	name = filepath.ToSlash(name)
+	ud := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(upstream),
+		B:        difflib.SplitLines(local),
+		FromFile: "upstream/" + name,
+		ToFile:   "local/" + name,
+		Context:  o.Context,
+	}
+	if upstreamPath == "" {
+		ud.A = nil
+		ud.FromFile = "/dev/null"
+	}
+	if localPath == "" {
+		ud.B = nil
+		ud.ToFile = "/dev/null"
+	}
+	text, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to diff file %s", name)
+	}
+	return text, nil
+}
+
+func (o *Options) printDiff(d *PackageDiff) error {
+	if len(d.Files) == 0 {
+		log.Logger().Infof("package %s has no local changes from %s", info(d.Dir), d.Expression)
+		return nil
+	}
+	log.Logger().Infof("package %s has %d changed files from %s", info(d.Dir), len(d.Files), d.Expression)
+	for _, f := range d.Files {
+		_, err := io.WriteString(o.Out, f.Diff)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the diff of %s", f.Path)
+		}
+	}
+	return nil
+}
+
+// packageFiles returns the paths of the files in the package indexed by their path relative to the package
+// directory. The Kptfile and any nested packages are ignored
+func packageFiles(dir string) (map[string]string, error) {
+	answer := map[string]string{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, kptfiles.FileName)); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to calculate the relative path of %s", path)
+		}
+		if rel == kptfiles.FileName {
+			return nil
+		}
+		answer[rel] = path
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to walk package dir %s", dir)
+	}
+	return answer, nil
+}
+
+func readFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,152 @@
+package diffupstream_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/diffupstream"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	lighthouseExpression = "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23"
+	nginxExpression      = "https://github.com/jenkins-x/jxr-kube-resources.git/nginx@5dd7c9e8e2a2ab2a4a0f8e6fb5e1b9c1b3d4e5f6"
+)
+
+var (
+	upstreamLighthouse = map[string]string{
+		"Kptfile": "apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\nmetadata:\n  name: lighthouse\n",
+		"deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: lighthouse
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: lighthouse
+        image: lighthouse:1.0.0
+`,
+		"removed.yaml": "apiVersion: v1\nkind: Secret\n",
+		"service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: lighthouse\n",
+	}
+
+	upstreamNginx = map[string]string{
+		"service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: nginx\n",
+	}
+)
+
+func TestKptDiffUpstream(t *testing.T) {
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "kpt",
+			Args:     []string{"pkg", "get", lighthouseExpression, "upstream"},
+			Callback: fakeKptGet(upstreamLighthouse),
+		},
+		testhelpers.Expectation{
+			Name:     "kpt",
+			Args:     []string{"pkg", "get", nginxExpression, "upstream"},
+			Callback: fakeKptGet(upstreamNginx),
+		},
+	)
+
+	out := &bytes.Buffer{}
+	_, o := diffupstream.NewCmdKptDiffUpstream()
+	o.Dir = "test_data"
+	o.CommandRunner = runner.Run
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run diff-upstream")
+	runner.Verify(t)
+
+	require.Len(t, o.Diffs, 2, "package diffs")
+
+	lighthouse := o.Diffs[0]
+	assert.Equal(t, filepath.Join("config-root", "namespaces", "jx", "lighthouse"), lighthouse.Dir, "lighthouse dir")
+	assert.Equal(t, lighthouseExpression, lighthouse.Expression, "lighthouse expression")
+
+	var paths []string
+	for _, f := range lighthouse.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"deployment.yaml", "local.yaml", "removed.yaml"}, paths, "changed files which should ignore the Kptfile")
+
+	assert.Equal(t, `--- upstream/config-root/namespaces/jx/lighthouse/deployment.yaml
++++ local/config-root/namespaces/jx/lighthouse/deployment.yaml
+@@ -3,7 +3,7 @@
+ metadata:
+   name: lighthouse
+ spec:
+-  replicas: 1
++  replicas: 2
+   template:
+     spec:
+       containers:
+`, lighthouse.Files[0].Diff, "deployment.yaml diff")
+	assert.True(t, strings.HasPrefix(lighthouse.Files[1].Diff, "--- /dev/null\n+++ local/config-root/namespaces/jx/lighthouse/local.yaml\n"), "local only file diff: %s", lighthouse.Files[1].Diff)
+	assert.True(t, strings.HasPrefix(lighthouse.Files[2].Diff, "--- upstream/config-root/namespaces/jx/lighthouse/removed.yaml\n+++ /dev/null\n"), "upstream only file diff: %s", lighthouse.Files[2].Diff)
+
+	nginx := o.Diffs[1]
+	assert.Equal(t, nginxExpression, nginx.Expression, "nginx expression should use the commit of the upstream lock")
+	assert.Empty(t, nginx.Files, "nginx should have no local changes")
+
+	assert.Equal(t, lighthouse.Files[0].Diff+lighthouse.Files[1].Diff+lighthouse.Files[2].Diff, out.String(), "printed diffs")
+}
+
+func TestKptDiffUpstreamPackage(t *testing.T) {
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "kpt",
+			Args:     []string{"pkg", "get", nginxExpression, "upstream"},
+			Callback: fakeKptGet(upstreamNginx),
+		},
+	)
+
+	out := &bytes.Buffer{}
+	_, o := diffupstream.NewCmdKptDiffUpstream()
+	o.Dir = "test_data"
+	o.Package = "config-root/namespaces/jx/nginx/"
+	o.CommandRunner = runner.Run
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run diff-upstream")
+	runner.Verify(t)
+
+	require.Len(t, o.Diffs, 1, "package diffs")
+	assert.Empty(t, o.Diffs[0].Files, "nginx should have no local changes")
+	assert.Empty(t, out.String(), "printed diffs")
+
+	o.Package = "config-root/namespaces/jx/does-not-exist"
+	err = o.Run()
+	require.Error(t, err, "should fail for a missing package")
+	assert.Contains(t, err.Error(), "could not find a Kptfile for package config-root/namespaces/jx/does-not-exist")
+}
+
+// fakeKptGet simulates kpt fetching the upstream package into the destination directory
+func fakeKptGet(upstreamFiles map[string]string) func(c *cmdrunner.Command) error {
+	return func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Dir, c.Args[3])
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		for name, text := range upstreamFiles {
+			err = ioutil.WriteFile(filepath.Join(dir, name), []byte(text), files.DefaultFileWritePermissions)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,189 @@
+package exportversions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Exports a lock file of the pinned upstream commits of all the kpt packages in the given directory
+
+		The lock file lists the path, repository, directory and commit of every package so that a known good set of package versions can be restored later via 'kpt sync --apply-lock'
+`)
+
+	cmdExample = templates.Examples(`
+		# writes the lock file of the kpt packages in the current directory to the terminal
+		%s kpt export-versions
+
+		# writes the lock file as JSON
+		%s kpt export-versions --file kpt-lock.json
+
+		# restores the package versions from the lock file
+		%s kpt sync --apply-lock kpt-lock.yaml
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+const (
+	// FormatYAML the YAML format
+	FormatYAML = "yaml"
+
+	// FormatJSON the JSON format
+	FormatJSON = "json"
+)
+
+// Options the options for the command
+type Options struct {
+	common.WalkOptions
+	Dir     string
+	OutFile string
+	Format  string
+	Out     io.Writer
+	Lock    *v1alpha1.KptLock
+}
+
+// NewCmdKptExportVersions creates a command object for the command
+func NewCmdKptExportVersions() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "export-versions",
+		Short:   "Exports a lock file of the pinned upstream commits of all the kpt packages in the given directory",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the Kptfile files")
+	cmd.Flags().StringVarP(&o.OutFile, "file", "f", "", "the lock file to write. If not specified the lock file is written to the terminal")
+	cmd.Flags().StringVarP(&o.Format, "format", "", "", "the format of the lock file. Supported values: yaml, json. Defaults to json if the file has a .json extension otherwise yaml")
+	o.WalkOptions.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Format == "" {
+		o.Format = FormatYAML
+		if strings.ToLower(filepath.Ext(o.OutFile)) == ".json" {
+			o.Format = FormatJSON
+		}
+	}
+	if o.Format != FormatYAML && o.Format != FormatJSON {
+		return errors.Errorf("unsupported format '%s'. Supported values: %s, %s", o.Format, FormatYAML, FormatJSON)
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	}
+
+	o.Lock = &v1alpha1.KptLock{}
+	o.Lock.APIVersion = v1alpha1.APIVersion
+	o.Lock.Kind = v1alpha1.KindKptLock
+	o.Lock.Spec.Packages = []v1alpha1.KptLockPackage{}
+	walkOptions := o.WalkOptions
+	walkOptions.Patterns = []string{kptfiles.FileName}
+	err = common.WalkFiles(dir, walkOptions, func(path, rel string, _ os.FileInfo) error {
+		rel = filepath.ToSlash(filepath.Dir(rel))
+		kf, err := kptfiles.Load(path)
+		if err != nil {
+			return err
+		}
+		commit := kf.ResolvedCommit()
+		if kf.Repo == "" || commit == "" {
+			log.Logger().Warnf("ignoring kpt package %s as it is not pinned to an upstream commit", info(rel))
+			return nil
+		}
+		repo, directory, ref := kf.Repo, kf.Directory, kf.Ref
+		if kf.Lock != nil {
+			if kf.Lock.Repo != "" {
+				repo = kf.Lock.Repo
+			}
+			if kf.Lock.Directory != "" {
+				directory = kf.Lock.Directory
+			}
+			if kf.Lock.Ref != "" {
+				ref = kf.Lock.Ref
+			}
+		}
+		o.Lock.Spec.Packages = append(o.Lock.Spec.Packages, v1alpha1.KptLockPackage{
+			Path:      rel,
+			Name:      kf.Name,
+			Repo:      repo,
+			Directory: directory,
+			Ref:       ref,
+			Commit:    commit,
+		})
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find kpt packages in dir %s", dir)
+	}
+
+	data, err := o.marshal()
+	if err != nil {
+		return err
+	}
+	if o.OutFile == "" {
+		_, err = o.Out.Write(data)
+		return err
+	}
+	err = ioutil.WriteFile(o.OutFile, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", o.OutFile)
+	}
+	log.Logger().Infof("exported the versions of %d kpt packages to %s", len(o.Lock.Spec.Packages), info(o.OutFile))
+	return nil
+}
+
+func (o *Options) marshal() ([]byte, error) {
+	if o.Format == FormatJSON {
+		data, err := json.MarshalIndent(o.Lock, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal the lock file to JSON")
+		}
+		return append(data, '\n'), nil
+	}
+	data, err := yaml.Marshal(o.Lock)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the lock file to YAML")
+	}
+	return data, nil
+}
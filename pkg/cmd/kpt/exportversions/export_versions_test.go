@@ -0,0 +1,116 @@
+package exportversions_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/exportversions"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/sync"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKptExportVersions(t *testing.T) {
+	_, o := exportversions.NewCmdKptExportVersions()
+	o.Dir = "test_data"
+	buf := &bytes.Buffer{}
+	o.Out = buf
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run kpt export-versions")
+
+	expected := `apiVersion: gitops.jenkins-x.io/v1alpha1
+kind: KptLock
+spec:
+  packages:
+  - commit: 4cc6b80d49808060b1f06f530399b986ed344f23
+    directory: /jenkins-x/lighthouse
+    name: lighthouse
+    path: config-root/namespaces/jx/lighthouse
+    ref: master
+    repo: https://github.com/jenkins-x/jxr-kube-resources
+  - commit: 5dd7c9e8e2a2ab2a4a0f8e6fb5e1b9c1b3d4e5f6
+    directory: /nginx
+    name: nginx
+    path: config-root/namespaces/jx/nginx
+    ref: v1.2.3
+    repo: https://github.com/jenkins-x/jxr-kube-resources
+`
+	assert.Equal(t, expected, buf.String(), "the exported lock file")
+}
+
+func TestKptExportVersionsApplyLock(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+	err = files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test_data to %s", tmpDir)
+
+	lockFile := filepath.Join(tmpDir, "kpt-lock.json")
+	_, o := exportversions.NewCmdKptExportVersions()
+	o.Dir = tmpDir
+	o.OutFile = lockFile
+	err = o.Run()
+	require.NoError(t, err, "failed to run kpt export-versions")
+	assert.Equal(t, exportversions.FormatJSON, o.Format, "the format should default from the file extension")
+
+	lock := &v1alpha1.KptLock{}
+	err = yamls.LoadFile(lockFile, lock)
+	require.NoError(t, err, "failed to load %s", lockFile)
+	assert.Equal(t, o.Lock, lock, "the lock file %s", lockFile)
+
+	// lets upgrade the packages then restore them from the lock file
+	jxDir := filepath.Join(tmpDir, "config-root", "namespaces", "jx")
+	originals := map[string]string{}
+	for _, name := range []string{"lighthouse", "nginx"} {
+		path := filepath.Join(jxDir, name, kptfiles.FileName)
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err, "failed to read %s", path)
+		originals[path] = string(data)
+
+		kf, err := kptfiles.Load(path)
+		require.NoError(t, err, "failed to load %s", path)
+		kf.Ref = "v2.0.0"
+		if kf.Lock != nil {
+			kf.Lock.Ref = "v2.0.0"
+			kf.Lock.Commit = "9f8e7d6c5b4a"
+		} else {
+			kf.Commit = "9f8e7d6c5b4a"
+		}
+		err = kf.Save(path)
+		require.NoError(t, err, "failed to save %s", path)
+	}
+
+	_, so := sync.NewCmdKptSync()
+	so.Dir = tmpDir
+	so.LockFile = lockFile
+	err = so.Run()
+	require.NoError(t, err, "failed to run kpt sync --apply-lock")
+
+	assert.Equal(t, []string{filepath.Join("config-root", "namespaces", "jx", "lighthouse"), filepath.Join("config-root", "namespaces", "jx", "nginx")}, so.OutOfDate, "restored packages")
+	assert.Equal(t, []string{filepath.Join("config-root", "namespaces", "jx", "local")}, so.NotInCatalog, "packages not in the lock file")
+	for path, expected := range originals {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err, "failed to read %s", path)
+		assert.Equal(t, expected, string(data), "the restored Kptfile %s", path)
+	}
+
+	// applying again should find everything up to date
+	err = so.Run()
+	require.NoError(t, err, "failed to run kpt sync --apply-lock again")
+	assert.Empty(t, so.OutOfDate, "out of date packages after applying the lock file")
+	assert.Len(t, so.UpToDate, 2, "up to date packages after applying the lock file")
+}
+
+func TestKptExportVersionsInvalidFormat(t *testing.T) {
+	_, o := exportversions.NewCmdKptExportVersions()
+	o.Dir = "test_data"
+	o.Format = "xml"
+	err := o.Run()
+	require.Error(t, err, "expected an error for an invalid format")
+}
@@ -1,7 +1,10 @@
 package kpt
 
 import (
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/diffupstream"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/exportversions"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/recreate"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/sync"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/update"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -20,7 +23,10 @@ func NewCmdKpt() *cobra.Command {
 			}
 		},
 	}
+	command.AddCommand(cobras.SplitCommand(diffupstream.NewCmdKptDiffUpstream()))
+	command.AddCommand(cobras.SplitCommand(exportversions.NewCmdKptExportVersions()))
 	command.AddCommand(cobras.SplitCommand(recreate.NewCmdKptRecreate()))
+	command.AddCommand(cobras.SplitCommand(sync.NewCmdKptSync()))
 	command.AddCommand(cobras.SplitCommand(update.NewCmdKptUpdate()))
 	return command
 }
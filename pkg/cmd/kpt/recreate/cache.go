@@ -0,0 +1,137 @@
+package recreate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CacheHit the package was fetched from a commit which was already in the cache directory
+	CacheHit = "hit"
+
+	// CacheMiss the upstream repository had to be cloned or fetched into the cache directory
+	CacheMiss = "miss"
+)
+
+var (
+	commitSHA         = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	invalidCacheChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	repositoryScheme  = regexp.MustCompile(`^[a-z+]+://`)
+)
+
+// cachedGet fetches the package from a bare clone of the upstream repository in the --cache-dir which is cloned
+// the first time the repository is used and reused for any other packages from the same repository. The directory
+// of the package is checked out via a sparse checkout of a local clone which shares the objects of the cached
+// repository. Returns whether the cache was hit along with the combined result of the git commands
+func (o *Options) cachedGet(ctx context.Context, runner common.ResultCommandRunner, kf *kptfiles.Kptfile, version, kptDir string) (*common.CommandResult, string, error) {
+	total := &common.CommandResult{}
+	repoDir := filepath.Join(o.CacheDir, cacheName(kf.Repo))
+	commit, cache, err := o.cachedCommit(ctx, runner, kptfiles.NormalizeRepo(kf.Repo), repoDir, version, total)
+	if err != nil {
+		return total, cache, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "kpt-cache-")
+	if err != nil {
+		return total, cache, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	directory := strings.Trim(filepath.ToSlash(kf.Directory), "/")
+	err = runGit(ctx, runner, tmpDir, total,
+		[]string{"clone", "--quiet", "--shared", "--no-checkout", repoDir, "."},
+		[]string{"sparse-checkout", "set", directory},
+		[]string{"checkout", "--quiet", commit},
+	)
+	if err != nil {
+		return total, cache, err
+	}
+	if o.DryRun {
+		return total, cache, nil
+	}
+	err = copyUpstream(kf, version, commit, tmpDir, kptDir)
+	if err != nil {
+		return total, cache, err
+	}
+	total.Stdout = ""
+	return total, cache, nil
+}
+
+// cachedCommit returns the commit of the version in the cached repository cloning the repository if it is not in
+// the cache. The branches and tags of a cached repository are fetched at most once per run if the version is not a
+// commit which is already in the cache
+func (o *Options) cachedCommit(ctx context.Context, runner common.ResultCommandRunner, repo, repoDir, version string, total *common.CommandResult) (string, string, error) {
+	if o.cacheFetched == nil {
+		o.cacheFetched = map[string]bool{}
+	}
+	exists, err := files.DirExists(repoDir)
+	if err != nil {
+		return "", CacheMiss, errors.Wrapf(err, "failed to check if dir exists %s", repoDir)
+	}
+	if !exists {
+		err = os.MkdirAll(o.CacheDir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return "", CacheMiss, errors.Wrapf(err, "failed to create the cache dir %s", o.CacheDir)
+		}
+		err = runGit(ctx, runner, o.CacheDir, total, []string{"clone", "--quiet", "--bare", repo, repoDir})
+		if err != nil {
+			return "", CacheMiss, errors.Wrapf(err, "failed to clone %s into the cache", repo)
+		}
+		o.cacheFetched[repoDir] = true
+		commit, err := o.resolveCommit(ctx, runner, repoDir, version, total)
+		return commit, CacheMiss, err
+	}
+
+	if o.cacheFetched[repoDir] || commitSHA.MatchString(version) {
+		commit, err := o.resolveCommit(ctx, runner, repoDir, version, total)
+		if err == nil {
+			return commit, CacheHit, nil
+		}
+		if o.cacheFetched[repoDir] {
+			return "", CacheHit, err
+		}
+	}
+	err = runGit(ctx, runner, repoDir, total, []string{"fetch", "--quiet", "--tags", "--force", "origin", "+refs/heads/*:refs/heads/*"})
+	if err != nil {
+		return "", CacheMiss, errors.Wrapf(err, "failed to fetch %s into the cache", repo)
+	}
+	o.cacheFetched[repoDir] = true
+	commit, err := o.resolveCommit(ctx, runner, repoDir, version, total)
+	return commit, CacheMiss, err
+}
+
+// resolveCommit returns the commit of the version in the cached repository
+func (o *Options) resolveCommit(ctx context.Context, runner common.ResultCommandRunner, repoDir, version string, total *common.CommandResult) (string, error) {
+	err := runGit(ctx, runner, repoDir, total, []string{"rev-parse", "--verify", "--quiet", version + "^{commit}"})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find version %s in the cached repository %s", version, repoDir)
+	}
+	if o.DryRun {
+		return version, nil
+	}
+	commit := total.Stdout
+	if commit == "" {
+		return "", errors.Errorf("no commit returned by git rev-parse for version %s in the cached repository %s", version, repoDir)
+	}
+	return commit, nil
+}
+
+// cacheName returns the name of the directory of the repository in the cache such as 'github.com_org_repo.git'
+func cacheName(repo string) string {
+	name := repositoryScheme.ReplaceAllString(kptfiles.NormalizeRepo(repo), "")
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
+	if idx := strings.LastIndex(name, "@"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	name = strings.Trim(invalidCacheChars.ReplaceAllString(name, "_"), "_.")
+	return name + ".git"
+}
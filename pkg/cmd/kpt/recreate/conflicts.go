@@ -0,0 +1,251 @@
+package recreate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OnConflictKeep keeps the locally modified files of a package when it is recreated
+	OnConflictKeep = "keep"
+
+	// OnConflictOverwrite replaces the locally modified files of a package with the upstream files
+	OnConflictOverwrite = "overwrite"
+
+	// OnConflictError fails if a package has locally modified files
+	OnConflictError = "error"
+
+	// DefaultManifestFile the default file relative to the directory used to store the hashes of the files of
+	// each package when it was last fetched
+	DefaultManifestFile = ".jx/gitops/kpt-manifest.yaml"
+)
+
+var (
+	// OnConflictValues the supported values of --on-conflict
+	OnConflictValues = []string{OnConflictKeep, OnConflictOverwrite, OnConflictError}
+)
+
+// Manifest the hashes of the files of each package when it was last fetched from upstream
+type Manifest struct {
+	// Packages the sha256 hashes of the files indexed by the package directory then by the file path relative to
+	// the package
+	Packages map[string]map[string]string `json:"packages,omitempty"`
+}
+
+// LoadManifest loads the manifest from the given file returning an empty manifest if the file does not exist
+func LoadManifest(path string) (*Manifest, error) {
+	answer := &Manifest{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if exists {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load file %s", path)
+		}
+		err = yaml.Unmarshal(data, answer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse file %s", path)
+		}
+	}
+	if answer.Packages == nil {
+		answer.Packages = map[string]map[string]string{}
+	}
+	return answer, nil
+}
+
+// Save saves the manifest to the given file
+func (m *Manifest) Save(path string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the kpt manifest")
+	}
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// LocalModifications returns the sorted files of the package which have been added or modified since it was
+// fetched. Files which were deleted locally are ignored as there is nothing to keep. Returns nil if the package
+// is not in the manifest as the modifications cannot be detected
+func (m *Manifest) LocalModifications(pkg, kptDir string) ([]string, error) {
+	fetched := m.Packages[pkg]
+	if fetched == nil {
+		return nil, nil
+	}
+	hashes, err := HashPackage(kptDir)
+	if err != nil {
+		return nil, err
+	}
+	var answer []string
+	for name, hash := range hashes {
+		if fetched[name] != hash {
+			answer = append(answer, name)
+		}
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
+
+// HashPackage returns the sha256 hashes of the files of the package indexed by their slash separated path
+// relative to the package ignoring the Kptfile which is always rewritten when the package is fetched
+func HashPackage(kptDir string) (map[string]string, error) {
+	answer := map[string]string{}
+	exists, err := files.DirExists(kptDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if dir exists %s", kptDir)
+	}
+	if !exists {
+		return answer, nil
+	}
+	err = filepath.Walk(kptDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(kptDir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == kptfiles.FileName {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read file %s", path)
+		}
+		sum := sha256.Sum256(data)
+		answer[rel] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to hash the files of dir %s", kptDir)
+	}
+	return answer, nil
+}
+
+// readFiles reads the given files of the package so that they can be restored after it is fetched
+func readFiles(kptDir string, names []string) (map[string][]byte, error) {
+	answer := map[string][]byte{}
+	for _, name := range names {
+		path := filepath.Join(kptDir, filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read file %s", path)
+		}
+		answer[name] = data
+	}
+	return answer, nil
+}
+
+// restoreFiles writes the kept files back into the package
+func restoreFiles(kptDir string, kept map[string][]byte) error {
+	for name, data := range kept {
+		path := filepath.Join(kptDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(path))
+		}
+		err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore file %s", path)
+		}
+	}
+	return nil
+}
+
+// manifestPath returns the path of the manifest in the directory the packages are recreated in
+func (o *Options) manifestPath(dir string) string {
+	path := o.ManifestFile
+	if path == "" {
+		path = DefaultManifestFile
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+// LocalModificationsError the error returned by --on-conflict error if a package has been modified locally
+type LocalModificationsError struct {
+	// Package the directory of the package
+	Package string
+
+	// Files the files of the package which have been modified locally
+	Files []string
+}
+
+// Error returns the error message
+func (e *LocalModificationsError) Error() string {
+	return fmt.Sprintf("the package %s has local modifications so use --on-conflict keep or overwrite: %s", e.Package, strings.Join(e.Files, ", "))
+}
+
+// localModifications detects the local modifications of the package before it is removed failing if
+// --on-conflict is error or returning the contents of the files to restore if it is keep
+func (o *Options) localModifications(pkg, kptDir string) (map[string][]byte, error) {
+	if o.OnConflict == OnConflictOverwrite || o.OnConflict == "" {
+		return nil, nil
+	}
+	modified, err := o.manifest.LocalModifications(pkg, kptDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(modified) == 0 {
+		return nil, nil
+	}
+	if o.OnConflict == OnConflictError {
+		return nil, &LocalModificationsError{Package: pkg, Files: modified}
+	}
+	o.Debugf("keeping the local modifications of package %s: %s", pkg, strings.Join(modified, ", "))
+	return readFiles(kptDir, modified)
+}
+
+// recordFetched records the hashes of the fetched files of the package in the manifest then restores any kept
+// files returning their sorted names
+func (o *Options) recordFetched(pkg, kptDir string, kept map[string][]byte) ([]string, error) {
+	hashes, err := HashPackage(kptDir)
+	if err != nil {
+		return nil, err
+	}
+	o.manifest.Packages[pkg] = hashes
+	if len(kept) == 0 {
+		return nil, nil
+	}
+	err = restoreFiles(kptDir, kept)
+	if err != nil {
+		return nil, err
+	}
+	var answer []string
+	for name := range kept {
+		answer = append(answer, name)
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
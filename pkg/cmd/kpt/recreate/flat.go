@@ -0,0 +1,181 @@
+package recreate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// OutputFormatNested the packages are recreated in their own directories in the output directory
+	OutputFormatNested = "nested"
+
+	// OutputFormatFlat the resources of the packages are written to a single output directory
+	OutputFormatFlat = "flat"
+)
+
+var (
+	// OutputFormatValues the supported values of --output-format
+	OutputFormatValues = []string{OutputFormatNested, OutputFormatFlat}
+
+	invalidFlatFileChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// Collision the resources of the recreated packages which have the same file name in the flat output directory
+type Collision struct {
+	// File the name of the file in the flat output directory
+	File string
+
+	// Resources the keys of the resources with the file name
+	Resources []string
+
+	// Paths the files of the resources relative to the directory the packages were recreated in
+	Paths []string
+}
+
+// flatResource a resource of a recreated package to be written to the flat output directory
+type flatResource struct {
+	resource *unstructured.Unstructured
+	key      string
+	path     string
+}
+
+// FlatFileName returns the file name of the resource in the flat output directory using the kind, namespace and
+// name of the resource. The namespace is omitted for cluster scoped resources
+func FlatFileName(u *unstructured.Unstructured) string {
+	parts := []string{u.GetKind()}
+	if u.GetNamespace() != "" {
+		parts = append(parts, u.GetNamespace())
+	}
+	parts = append(parts, u.GetName())
+	name := strings.ToLower(strings.Join(parts, "-"))
+	return invalidFlatFileChars.ReplaceAllString(name, "-") + ".yaml"
+}
+
+// flattenPackages writes each resource of the packages which were recreated in the work directory to its own
+// file in the flat directory. If any resources have the same file name they are reported as collisions and
+// nothing is written unless --force is specified in which case numeric suffixes are added to the file names
+func (o *Options) flattenPackages(workDir, flatDir string, packageDirs []string) error {
+	o.Collisions = nil
+	var names []string
+	byName := map[string][]*flatResource{}
+	visited := map[string]bool{}
+	for _, pkgDir := range packageDirs {
+		err := filepath.Walk(pkgDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !resourcehelpers.IsYAMLFile(path) || visited[path] {
+				return nil
+			}
+			// lets avoid flattening the resources of nested packages twice
+			visited[path] = true
+			rel, err := filepath.Rel(workDir, path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find the path of %s relative to %s", path, workDir)
+			}
+			resources, err := resourcehelpers.LoadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, u := range resources {
+				if !resourcehelpers.IsResource(u) {
+					o.Debugf("ignoring a document which is not a kubernetes resource in %s", rel)
+					continue
+				}
+				name := FlatFileName(u)
+				if byName[name] == nil {
+					names = append(names, name)
+				}
+				byName[name] = append(byName[name], &flatResource{
+					resource: u,
+					key:      resourcehelpers.ResourceKey(u),
+					path:     filepath.ToSlash(rel),
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to load the resources of package %s", pkgDir)
+		}
+	}
+
+	outputs := map[string]*flatResource{}
+	for _, name := range names {
+		resources := byName[name]
+		outputs[name] = resources[0]
+		if len(resources) == 1 {
+			continue
+		}
+		collision := Collision{File: name}
+		for _, r := range resources {
+			collision.Resources = append(collision.Resources, r.key)
+			collision.Paths = append(collision.Paths, r.path)
+		}
+		o.Collisions = append(o.Collisions, collision)
+	}
+	if len(o.Collisions) > 0 {
+		if !o.Force {
+			return collisionsError(o.Collisions)
+		}
+		for _, c := range o.Collisions {
+			resources := byName[c.File]
+			base := strings.TrimSuffix(c.File, ".yaml")
+			for i, r := range resources[1:] {
+				name := uniqueFileName(outputs, base, i+2)
+				outputs[name] = r
+				o.Warnf("renamed the colliding resource %s from %s to %s", info(r.key), r.path, info(name))
+			}
+		}
+	}
+
+	err := os.MkdirAll(flatDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the flat output directory %s", flatDir)
+	}
+	var fileNames []string
+	for name := range outputs {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+	for _, name := range fileNames {
+		err = resourcehelpers.SaveFile([]*unstructured.Unstructured{outputs[name].resource}, filepath.Join(flatDir, name))
+		if err != nil {
+			return err
+		}
+	}
+	o.Infof("flattened %d resources from %d packages into %s", len(fileNames), len(packageDirs), info(flatDir))
+	return nil
+}
+
+// uniqueFileName returns the first file name with the base name and a numeric suffix starting at the given
+// number which is not already used
+func uniqueFileName(used map[string]*flatResource, base string, n int) string {
+	for ; ; n++ {
+		name := fmt.Sprintf("%s-%d.yaml", base, n)
+		if used[name] == nil {
+			return name
+		}
+	}
+}
+
+// collisionsError returns the error listing the resources of each collision and the files they came from
+func collisionsError(collisions []Collision) error {
+	var lines []string
+	for _, c := range collisions {
+		var sources []string
+		for i := range c.Resources {
+			sources = append(sources, fmt.Sprintf("%s in %s", c.Resources[i], c.Paths[i]))
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", c.File, strings.Join(sources, ", ")))
+	}
+	return errors.Errorf("found %d file name collisions in the flat output directory, use --force to add numeric suffixes:\n%s", len(collisions), strings.Join(lines, "\n"))
+}
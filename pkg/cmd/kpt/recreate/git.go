@@ -0,0 +1,92 @@
+package recreate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCommitMessage the default message of the commit of the recreated packages
+	DefaultCommitMessage = "chore: recreated kpt packages"
+)
+
+// gitRepository returns the root directory of the git repository the recreated packages are committed to or
+// blank if they are not committed. The --git-dir defaults to the output directory and must be inside a git
+// repository which contains the output directory
+func (o *Options) gitRepository(outDir string) (string, error) {
+	if o.GitDir == "" && !o.GitCommit {
+		return "", nil
+	}
+	gitDir := outDir
+	if o.GitDir != "" {
+		var err error
+		gitDir, err = filepath.Abs(o.GitDir)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find abs dir of %s", o.GitDir)
+		}
+		info, err := os.Stat(gitDir)
+		if err != nil || !info.IsDir() {
+			return "", errors.Errorf("the --git-dir %s does not exist", o.GitDir)
+		}
+	}
+	root, err := o.git(gitDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", errors.Wrapf(err, "the directory %s is not inside a git repository", gitDir)
+	}
+	root = filepath.Clean(strings.TrimSpace(root))
+	rel, err := filepath.Rel(root, resolveSymlinks(outDir))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+pathSeparator) {
+		return "", errors.Errorf("the output directory %s is not inside the git repository %s", outDir, root)
+	}
+	return root, nil
+}
+
+// commitPackages adds the changes to the output directory and commits them to the git repository if there are
+// any changes. Only the changes inside the output directory are added
+func (o *Options) commitPackages(gitDir, outDir string) error {
+	rel, err := filepath.Rel(gitDir, resolveSymlinks(outDir))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the path of %s relative to %s", outDir, gitDir)
+	}
+	if o.GitClient == nil {
+		o.GitClient = cli.NewCLIClient("", o.gitRunner)
+	}
+	_, err = o.GitClient.Command(gitDir, "add", "--all", "--", filepath.ToSlash(rel))
+	if err != nil {
+		return errors.Wrapf(err, "failed to add the recreated packages in %s to git", outDir)
+	}
+	message := o.CommitMessage
+	if message == "" {
+		message = DefaultCommitMessage
+	}
+	err = gitclient.CommitIfChanges(o.GitClient, gitDir, message)
+	if err != nil {
+		return errors.Wrapf(err, "failed to commit the recreated packages in git repository %s", gitDir)
+	}
+	o.Infof("committed the recreated packages to the git repository %s", info(gitDir))
+	return nil
+}
+
+func (o *Options) git(dir string, args ...string) (string, error) {
+	return o.gitRunner(&cmdrunner.Command{
+		Dir:  dir,
+		Name: "git",
+		Args: args,
+	})
+}
+
+// resolveSymlinks resolves any symlinks in the path as git returns the root of the repository with its symlinks
+// resolved
+func resolveSymlinks(path string) string {
+	answer, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return answer
+}
@@ -0,0 +1,112 @@
+package recreate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+// MarkdownSummary returns a markdown summary of the results suitable for a pull request comment
+func (o *Options) MarkdownSummary() string {
+	var changed, failed []PackageResult
+	refreshed := 0
+	for _, r := range o.Results {
+		switch {
+		case r.Error != "":
+			failed = append(failed, r)
+		case r.CommitChanged():
+			changed = append(changed, r)
+			refreshed++
+		default:
+			refreshed++
+		}
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString("### kpt recreate\n\n")
+	fmt.Fprintf(buf, "* refreshed packages: **%d**\n", refreshed)
+	fmt.Fprintf(buf, "* changed commit: **%d**\n", len(changed))
+	fmt.Fprintf(buf, "* failed: **%d**\n", len(failed))
+	if o.CacheDir != "" {
+		hits, misses := o.cacheCounts()
+		fmt.Fprintf(buf, "* cache hits: **%d**\n", hits)
+		fmt.Fprintf(buf, "* cache misses: **%d**\n", misses)
+	}
+
+	if len(changed) > 0 {
+		buf.WriteString("\n#### Changed commit\n\n")
+		buf.WriteString("| Package | From | To |\n")
+		buf.WriteString("| --- | --- | --- |\n")
+		for _, r := range changed {
+			fmt.Fprintf(buf, "| `%s` | `%s` | `%s` |\n", r.Dir, r.FromCommit, r.ToCommit)
+		}
+	}
+	if len(failed) > 0 {
+		buf.WriteString("\n#### Failures\n\n")
+		buf.WriteString("| Package | Exit Code | Error |\n")
+		buf.WriteString("| --- | --- | --- |\n")
+		for _, r := range failed {
+			fmt.Fprintf(buf, "| `%s` | %d | %s |\n", r.Dir, r.ExitCode, markdownTableCell(r.Error))
+		}
+	}
+	if len(o.NotInLock) > 0 {
+		buf.WriteString("\n#### Not in lock file\n\n")
+		for _, dir := range o.NotInLock {
+			fmt.Fprintf(buf, "* `%s`\n", dir)
+		}
+	}
+	var kept []PackageResult
+	for _, r := range o.Results {
+		if len(r.KeptFiles) > 0 {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) > 0 {
+		buf.WriteString("\n#### Kept local modifications\n\n")
+		buf.WriteString("| Package | Files |\n")
+		buf.WriteString("| --- | --- |\n")
+		for _, r := range kept {
+			fmt.Fprintf(buf, "| `%s` | %s |\n", r.Dir, markdownTableCell(strings.Join(r.KeptFiles, ", ")))
+		}
+	}
+	return buf.String()
+}
+
+func (o *Options) writeMarkdownSummary() error {
+	path := o.MarkdownFile
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the directory for the markdown summary file %s", path)
+	}
+	err = ioutil.WriteFile(path, []byte(o.MarkdownSummary()), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save the markdown summary file %s", path)
+	}
+	o.Logger().Infof("saved the markdown summary to %s", info(path))
+	return nil
+}
+
+// markdownTableCell returns the first line of the text escaped so that it can be used in a table cell
+func markdownTableCell(text string) string {
+	text = strings.TrimSpace(text)
+	idx := strings.Index(text, "\n")
+	if idx >= 0 {
+		text = strings.TrimSpace(text[:idx])
+	}
+	return strings.ReplaceAll(text, "|", "\\|")
+}
+
+// kptfileCommit returns the resolved upstream commit of the Kptfile or blank if it cannot be loaded
+func kptfileCommit(path string) string {
+	kf, err := kptfiles.Load(path)
+	if err != nil {
+		return ""
+	}
+	return kf.ResolvedCommit()
+}
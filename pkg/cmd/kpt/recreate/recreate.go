@@ -1,45 +1,205 @@
 package recreate
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
-	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"sigs.k8s.io/yaml"
 )
 
 var (
 	kptLong = templates.LongDesc(`
 		Updates the kpt packages in the given directory
+
+		If --lock-file is specified each package is recreated from the commit recorded in the lock file exported via 'kpt export-versions' rather than the commit in its Kptfile. Packages which are not in the lock file are recreated from their Kptfile and reported.
+
+		If --packages-from-file is specified only the packages whose directories are listed in the file, one per line relative to the --dir, are recreated. The other packages are left untouched.
+
+		If --post-command is specified it is run via 'sh -c' in the directory of each package after it has been fetched successfully. The command is a go template which can use the absolute directory of the package as .Dir and its directory relative to the --dir as .Package. If the command fails the package fails in the same way as if kpt failed so --ignore-errors and --fail-at-end can be used to carry on with the other packages.
+
+		If --shallow is specified each package is fetched with a shallow sparse git checkout of just the version and directory of the upstream package, as 'kpt pkg get' has no option to limit the depth of its clone, which is much faster for large upstream repositories. The Kptfile is written in the same way as kpt. If the shallow fetch fails, such as if the git server does not allow fetching a commit directly, a warning is logged and the package is fetched via 'kpt pkg get' instead.
+
+		If --cache-dir is specified each upstream repository is cloned once into the cache directory and reused for all the packages from that repository, including on later runs, rather than being cloned by 'kpt pkg get' for every package. Each package is checked out from the cached repository with a sparse checkout of its directory. The branches and tags of a cached repository are fetched at most once per run unless the version of the package is a commit which is already in the cache. The number of packages which were fetched from the cache without any network access is reported as hits and those which needed the repository to be cloned or fetched as misses. If fetching from the cache fails a warning is logged and the package is fetched via 'kpt pkg get' instead. The --cache-dir cannot be used with --shallow.
+
+		The hashes of the files of each package are recorded in the --manifest-file when it is fetched so that local modifications can be detected the next time it is recreated. The --on-conflict option controls what happens to the files which have been added or modified since the package was last fetched: 'overwrite' replaces them with the upstream files, 'keep' restores the local files after the package is fetched and 'error' fails before the package is removed. Packages which are not in the manifest have no known local modifications.
+
+		If --git-commit is specified the changes to the recreated packages are committed to the git repository of the --git-dir which defaults to the --out-dir. The --git-dir can be a checkout of another repository which contains the --out-dir so that the packages can be recreated into a different repository than the one the command is run in. The --git-dir is checked to be a git repository containing the --out-dir before any packages are recreated.
+
+		If --output-format is 'flat' the packages are recreated in a temporary directory and then each resource of the recreated packages is written to its own file named kind-namespace-name.yaml directly in the --out-dir, omitting the namespace of cluster scoped resources, for tools which do not walk subdirectories. Existing files with the same names are replaced. If more than one resource has the same file name the collisions are reported and nothing is written unless --force is specified in which case numeric suffixes such as '-2' are added to the file names of the later resources.
 `)
 
 	kptExample = templates.Examples(`
 		# updates the kpt of all the yaml resources in the given directory
 		%s kpt --dir .
+
+		# recreates the packages from the frozen versions in a lock file
+		%s kpt recreate --lock-file kpt-lock.yaml
+
+		# only recreates the packages which have changed
+		%s kpt recreate --packages-from-file changed-packages.txt
+
+		# only recreates the packages which have changed since the main branch
+		%s kpt recreate --since origin/main
+
+		# formats each package after it has been fetched
+		%s kpt recreate --post-command 'jx-gitops format --dir {{ .Dir }}'
+
+		# speeds up fetching the packages from large upstream repositories
+		%s kpt recreate --shallow
+
+		# reuses the clones of the upstream repositories across packages and runs
+		%s kpt recreate --cache-dir ~/.cache/kpt-repos
+
+		# keeps any local patches of the packages
+		%s kpt recreate --on-conflict keep
+
+		# recreates the packages into a checkout of another repository and commits them
+		%s kpt recreate --out-dir ../infra/config-root --git-dir ../infra --git-commit
+
+		# writes all the resources of the packages into a single directory
+		%s kpt recreate --out-dir flat-resources --output-format flat
 	`)
 
 	pathSeparator = string(os.PathSeparator)
+
+	info = termcolor.ColorInfo
 )
 
 // KptOptions the options for the command
 type Options struct {
+	common.WalkOptions
+	common.SinceOptions
+	common.CommandEnvironment
+	common.Verbosity
 	Dir           string
 	OutDir        string
+	KptBinary     string
 	Version       string
+	LockFile      string
+	Lock          *v1alpha1.KptLock
+	PackagesFile  string
+	Packages      []string
+	PostCommand   string
+	Shallow       bool
+	CacheDir      string
+	OnConflict    string
+	ManifestFile  string
+	GitDir        string
+	GitCommit     bool
+	CommitMessage string
 	IgnoreErrors  bool
+	FailAtEnd     bool
+	Output        string
+	OutputFormat  string
+	Force         bool
 	DryRun        bool
+	Timeout       time.Duration
+	MarkdownFile  string
 	CommandRunner cmdrunner.CommandRunner
+	GitClient     gitclient.Interface
+	Out           io.Writer
+	Err           io.Writer
+	Results       []PackageResult
+	NotInLock     []string
+	Collisions    []Collision
+	Errors        *common.ErrorList
+	postCommand   *template.Template
+	manifest      *Manifest
+	cacheFetched  map[string]bool
+	gitRunner     cmdrunner.CommandRunner
+}
+
+// PostCommandData the values which can be used in the post command template
+type PostCommandData struct {
+	// Dir the absolute directory of the package
+	Dir string
+
+	// Package the directory of the package relative to the directory being recreated
+	Package string
+}
+
+// Result the result of recreating the kpt packages
+type Result struct {
+	// Dir the directory the packages were recreated in
+	Dir string
+
+	// Packages the results of the packages which were processed
+	Packages []PackageResult
+
+	// NotInLock the directories of the packages which were not in the lock file so were recreated from their Kptfile
+	NotInLock []string
+
+	// Collisions the resources which had the same file name in the flat output directory
+	Collisions []Collision
+
+	// Errors the errors of the packages which failed
+	Errors *common.ErrorList
+}
+
+// Failed returns the results of the packages which failed
+func (r *Result) Failed() []PackageResult {
+	var answer []PackageResult
+	for _, p := range r.Packages {
+		if p.Error != "" {
+			answer = append(answer, p)
+		}
+	}
+	return answer
+}
+
+// PackageResult the result of recreating a kpt package
+type PackageResult struct {
+	// Dir the directory the package was recreated in relative to the output directory
+	Dir string
+
+	// Expression the kpt expression of the upstream package
+	Expression string
+
+	// ExitCode the exit code of the kpt command
+	ExitCode int
+
+	// Duration how long the kpt command took
+	Duration time.Duration
+
+	// Error the error if the package could not be recreated
+	Error string
+
+	// FromCommit the upstream commit of the package before it was recreated
+	FromCommit string
+
+	// ToCommit the upstream commit of the recreated package or blank if it is not known
+	ToCommit string
+
+	// KeptFiles the locally modified files of the package which were kept
+	KeptFiles []string
+
+	// Cache whether the commit of the package was already in the --cache-dir: 'hit', 'miss' or blank if the cache
+	// was not used
+	Cache string
+}
+
+// CommitChanged returns true if the package was recreated from a different upstream commit
+func (r *PackageResult) CommitChanged() bool {
+	return r.Error == "" && r.ToCommit != "" && r.ToCommit != r.FromCommit
 }
 
 // NewCmdKptRecreate creates a command object for the command
@@ -50,108 +210,283 @@ func NewCmdKptRecreate() (*cobra.Command, *Options) {
 		Use:     "recreate",
 		Short:   "Recreates the kpt packages in the given directory",
 		Long:    kptLong,
-		Example: fmt.Sprintf(kptExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(kptExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
-			helper.CheckErr(err)
+			common.CheckErr(err, o.Output)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "", "the output directory to generate the output")
+	cmd.Flags().StringVarP(&o.KptBinary, "bin", "", "", "the 'kpt' binary to use. If not specified $"+common.BinaryEnvVar("kpt")+" or the kpt binary on the PATH is used")
 	cmd.Flags().StringVarP(&o.Version, "version", "", "", "if specified overrides the versions used in the kpt packages (e.g. to 'master')")
+	cmd.Flags().StringVarP(&o.LockFile, "lock-file", "", "", "if specified the packages are recreated from the commits in the given lock file exported via 'kpt export-versions' rather than the commits in the Kptfiles")
+	cmd.Flags().StringVarP(&o.PackagesFile, "packages-from-file", "", "", "if specified only the packages whose directories relative to the --dir are listed in the given file, one per line, are recreated")
+	cmd.Flags().StringVarP(&o.PostCommand, "post-command", "", "", "if specified the go template of a command run via 'sh -c' in the directory of each package after it has been fetched. The template can use .Dir and .Package")
+	cmd.Flags().BoolVarP(&o.Shallow, "shallow", "", false, "fetches the packages with a shallow sparse git checkout of the upstream version and directory falling back to 'kpt pkg get' if it fails")
+	cmd.Flags().StringVarP(&o.CacheDir, "cache-dir", "", "", "if specified the upstream repositories are cloned once into this directory and reused for all the packages from the same repository")
+	cmd.Flags().StringVarP(&o.OnConflict, "on-conflict", "", OnConflictOverwrite, "what to do with the files of a package which were modified locally since it was last fetched. Supported values: "+strings.Join(OnConflictValues, ", "))
+	cmd.Flags().StringVarP(&o.ManifestFile, "manifest-file", "", DefaultManifestFile, "the file relative to the --dir which records the hashes of the files of each package when it was last fetched")
+	cmd.Flags().StringVarP(&o.GitDir, "git-dir", "", "", "the git repository the recreated packages are committed to if it is not the --out-dir such as a parent directory or a checkout of another repository containing the --out-dir")
+	cmd.Flags().BoolVarP(&o.GitCommit, "git-commit", "", false, "commits the changes to the recreated packages to the git repository of the --git-dir or --out-dir")
+	cmd.Flags().StringVarP(&o.CommitMessage, "commit-message", "", DefaultCommitMessage, "the git commit message used with --git-commit")
 	cmd.Flags().BoolVarP(&o.IgnoreErrors, "ignore-errors", "i", false, "if enabled we continue processing on kpt errors")
+	cmd.Flags().BoolVarP(&o.FailAtEnd, "fail-at-end", "", false, "if enabled we continue processing on kpt errors then fail with the errors of all the packages which failed")
+	cmd.Flags().StringVarP(&o.Output, "output", "", common.ErrorOutputText, "the format of the errors if any packages fail. Supported values: text, json")
+	cmd.Flags().StringVarP(&o.OutputFormat, "output-format", "", OutputFormatNested, "the layout of the recreated packages in the --out-dir. Supported values: "+strings.Join(OutputFormatValues, ", "))
+	cmd.Flags().BoolVarP(&o.Force, "force", "", false, "adds numeric suffixes to the file names of the resources which collide in the flat output directory rather than failing")
 	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "just output the commands to be executed")
+	o.WalkOptions.AddFlags(cmd)
+	o.SinceOptions.AddFlags(cmd)
+	o.CommandEnvironment.AddFlags(cmd)
+	o.Verbosity.AddFlags(cmd)
+	cmd.Flags().StringVarP(&o.MarkdownFile, "markdown-summary-file", "", "", "if specified a markdown summary of the recreated packages is written to this file even if some packages fail")
+	cmd.Flags().DurationVarP(&o.Timeout, "timeout", "", 0, "the maximum time each kpt command can take before it is killed such as '5m'. 0 means no timeout")
 	return cmd, o
 }
 
-// Run implements the command
-func (o *Options) Run() error {
+// Validate validates the options
+func (o *Options) Validate() error {
+	err := o.Verbosity.Validate()
+	if err != nil {
+		return err
+	}
+	if o.Output != "" && o.Output != common.ErrorOutputText && o.Output != common.ErrorOutputJSON {
+		return options.InvalidOption("output", o.Output, []string{common.ErrorOutputText, common.ErrorOutputJSON})
+	}
+	if o.OnConflict == "" {
+		o.OnConflict = OnConflictOverwrite
+	}
+	if stringhelpers.StringArrayIndex(OnConflictValues, o.OnConflict) < 0 {
+		return options.InvalidOption("on-conflict", o.OnConflict, OnConflictValues)
+	}
+	if o.OutputFormat == "" {
+		o.OutputFormat = OutputFormatNested
+	}
+	if stringhelpers.StringArrayIndex(OutputFormatValues, o.OutputFormat) < 0 {
+		return options.InvalidOption("output-format", o.OutputFormat, OutputFormatValues)
+	}
+	if o.Version != "" && (o.LockFile != "" || o.Lock != nil) {
+		return errors.Errorf("cannot specify both --version and --lock-file")
+	}
+	if o.Shallow && o.CacheDir != "" {
+		return errors.Errorf("cannot specify both --shallow and --cache-dir")
+	}
 	if o.Dir == "" {
 		o.Dir = "."
 	}
-	dir, err := filepath.Abs(o.Dir)
-	if err != nil {
-		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	if o.PostCommand != "" && o.postCommand == nil {
+		o.postCommand, err = template.New("post-command").Option("missingkey=error").Parse(o.PostCommand)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse the --post-command template %s", o.PostCommand)
+		}
 	}
+	return nil
+}
 
+// Run implements the command recreating the packages in a temporary directory if no output directory is
+// specified and writing the markdown summary if enabled
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	// lets check kpt can be used before creating any directories
+	_, err = o.kptBinary()
+	if err != nil {
+		return err
+	}
 	if o.OutDir == "" {
 		o.OutDir, err = ioutil.TempDir("", "")
 		if err != nil {
 			return errors.Wrap(err, "failed to create temp dir")
 		}
 	}
-	if o.DryRun {
-		o.CommandRunner = cmdrunner.DryRunCommandRunner
-	}
-	if o.CommandRunner == nil {
-		o.CommandRunner = cmdrunner.DefaultCommandRunner
+
+	result, err := o.Recreate(context.Background())
+
+	// lets write the summary even if a package failed so that it can be reported
+	if result != nil && o.MarkdownFile != "" {
+		summaryErr := o.writeMarkdownSummary()
+		if summaryErr != nil {
+			if err == nil {
+				return summaryErr
+			}
+			o.Warnf(summaryErr.Error())
+		}
 	}
+	return err
+}
 
-	err = files.CopyDirOverwrite(dir, o.OutDir)
+// Recreate recreates the kpt packages in the output directory or in place if there is no output directory.
+// The result is returned even if some packages fail so that the failures can be reported. The commands are
+// killed if the context is done
+func (o *Options) Recreate(ctx context.Context) (*Result, error) {
+	err := o.Validate()
 	if err != nil {
-		return errors.Wrapf(err, "failed to copy %s to %s", dir, o.OutDir)
+		return nil, err
 	}
-	dir = o.OutDir
-
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if info == nil || info.IsDir() {
-			return nil
-		}
-		kptDir, name := filepath.Split(path)
-		if name != "Kptfile" {
-			return nil
-		}
-		rel, err := filepath.Rel(dir, kptDir)
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	}
+	// lets check kpt can be used and the lock file is valid before removing any packages
+	bin, err := o.kptBinary()
+	if err != nil {
+		return nil, err
+	}
+	if o.LockFile != "" && o.Lock == nil {
+		o.Lock, err = kptfiles.LoadLock(o.LockFile)
 		if err != nil {
-			return errors.Wrapf(err, "failed to calculate the relative directory of %s", kptDir)
+			return nil, err
 		}
-		kptDir = strings.TrimSuffix(kptDir, pathSeparator)
-		parentDir, kptDirName := filepath.Split(kptDir)
-		parentDir = strings.TrimSuffix(parentDir, pathSeparator)
-
-		u := &unstructured.Unstructured{}
-		data, err := ioutil.ReadFile(path)
+	}
+	if o.PackagesFile != "" && o.Packages == nil {
+		o.Packages, err = loadPackages(o.PackagesFile)
 		if err != nil {
-			return errors.Wrapf(err, "failed to read file %s", path)
+			return nil, err
 		}
-		err = yaml.Unmarshal(data, u)
+	}
+	packages, err := o.packagesSet(dir)
+	if err != nil {
+		return nil, err
+	}
+	if o.CacheDir != "" {
+		o.CacheDir, err = filepath.Abs(o.CacheDir)
 		if err != nil {
-			return errors.Wrapf(err, "failed to parse URL for %s", path)
+			return nil, errors.Wrapf(err, "failed to find abs dir of %s", o.CacheDir)
 		}
+	}
+	// lets run git with the same environment, masking, audit log and timeout as kpt. The git commands are
+	// run even with --dry-run as they do not modify anything until the packages are committed
+	var gitRunner common.ResultCommandRunner
+	if o.CommandRunner != nil {
+		gitRunner = common.FromCommandRunner(o.CommandRunner)
+	}
+	o.gitRunner = common.ToCommandRunner(o.newResultRunner(gitRunner, true))
+
+	changed, err := o.ChangedFiles(o.gitRunner, dir)
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := o.Out, o.Err
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	var runner common.ResultCommandRunner
+	switch {
+	case o.DryRun:
+		runner = common.FromCommandRunner(common.NewDryRunCommandRunner(stdout).Run)
+	case o.CommandRunner != nil:
+		runner = common.FromCommandRunner(o.CommandRunner)
+	case o.Level() == common.VerbosityVerbose:
+		streamer := common.NewStreamingCommandRunner(stdout, stderr)
+		streamer.Prefix = packagePrefix
+		runner = streamer.RunResult
+	}
+	if !o.DryRun {
+		runner = o.newResultRunner(runner, o.Level() == common.VerbosityQuiet)
+	}
 
-		gitURL, _, err := unstructured.NestedString(u.Object, "upstream", "git", "repo")
+	outDir := dir
+	if o.OutDir != "" {
+		outDir, err = filepath.Abs(o.OutDir)
 		if err != nil {
-			return errors.Wrapf(err, "failed to find git URL for path %s", path)
+			return nil, errors.Wrapf(err, "failed to find abs dir of %s", o.OutDir)
 		}
-		if gitURL == "" {
-			return errors.Errorf("no git URL for path %s", path)
+	}
+	// lets check the git repository before modifying anything
+	gitDir, err := o.gitRepository(outDir)
+	if err != nil {
+		return nil, err
+	}
+	flat := o.OutputFormat == OutputFormatFlat
+	flatDir := ""
+	if flat {
+		if outDir == dir {
+			return nil, errors.Errorf("cannot use --output-format %s without an --out-dir which is not the --dir", OutputFormatFlat)
 		}
-		directory, _, err := unstructured.NestedString(u.Object, "upstream", "git", "directory")
+		// lets recreate the packages in a work directory then flatten them into the output directory
+		flatDir = outDir
+		outDir, err = ioutil.TempDir("", "kpt-recreate-")
 		if err != nil {
-			return errors.Wrapf(err, "failed to find git directory for path %s", path)
+			return nil, errors.Wrap(err, "failed to create temp dir")
 		}
-		if directory == "" {
-			return errors.Errorf("no git directory for path %s", path)
+		defer os.RemoveAll(outDir)
+	}
+	// lets avoid copying the files onto themselves if we are recreating in place
+	if outDir != dir {
+		err = files.CopyDirOverwrite(dir, outDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to copy %s to %s", dir, outDir)
 		}
-		version := o.Version
-		if version == "" {
-			version, _, err = unstructured.NestedString(u.Object, "upstream", "git", "commit")
+		if o.FollowSymlinks {
+			err = copySymlinks(dir, outDir)
 			if err != nil {
-				return errors.Wrapf(err, "failed to find git commit for path %s", path)
-			}
-			if version == "" {
-				return errors.Errorf("no git version for path %s", path)
+				return nil, errors.Wrapf(err, "failed to copy symlinks from %s to %s", dir, outDir)
 			}
 		}
+	}
+	dir = outDir
+
+	manifestPath := o.manifestPath(dir)
+	o.manifest, err = LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Results = nil
+	o.NotInLock = nil
+	o.Collisions = nil
+	var packageDirs []string
+	o.Errors = common.NewErrorList("package")
+	walkOptions := o.WalkOptions
+	walkOptions.Patterns = []string{kptfiles.FileName}
+	walkOptions.OnSkip = func(path, rel string) {
+		o.Debugf("skipping %s", rel)
+	}
+	err = common.WalkFiles(dir, walkOptions, func(path, rel string, info os.FileInfo) error {
+		rel = filepath.Dir(rel)
+		if packages != nil && !packages[filepath.ToSlash(rel)] {
+			o.Debugf("skipping %s as it is not in the packages file", rel)
+			return nil
+		}
+		if !changed.ContainsDir(rel) {
+			o.Debugf("skipping %s as it has not changed since %s", rel, o.Since)
+			return nil
+		}
+		kptDir := filepath.Dir(path)
+		kptDirName := filepath.Base(kptDir)
 
-		if !strings.HasSuffix(gitURL, ".git") {
-			gitURL = strings.TrimSuffix(gitURL, "/") + ".git"
+		kf, err := kptfiles.Load(path)
+		if err != nil {
+			return err
+		}
+		commit := kf.ResolvedCommit()
+		version := o.Version
+		if o.Lock != nil {
+			version = o.lockedVersion(kf, rel)
 		}
-		if !strings.HasPrefix(directory, pathSeparator) {
-			directory = pathSeparator + directory
+		if kf.Repo == "" {
+			return &kptfiles.MissingFieldError{Path: path, Field: "upstream.git.repo", Description: "git URL"}
+		}
+		if kf.Directory == "" {
+			return &kptfiles.MissingFieldError{Path: path, Field: "upstream.git.directory", Description: "git directory"}
+		}
+		if version == "" {
+			version = kf.Version()
+			if version == "" {
+				return &kptfiles.MissingFieldError{Path: path, Field: "upstream.git.ref", Description: "git version"}
+			}
 		}
 
-		expression := fmt.Sprintf("%s%s@%s", gitURL, directory, version)
+		expression := kf.Expression(version)
+		directory := kptfiles.NormalizeDirectory(kf.Directory)
+		o.Debugf("using expression %s for package %s from repository %s directory %s and version %s", expression, rel, kf.Repo, directory, version)
 		directories := strings.Split(directory, pathSeparator)
 
 		// if the folder resource name is the same as the namespace then lets omit
@@ -162,27 +497,315 @@ func (o *Options) Run() error {
 		}
 		args := []string{"pkg", "get", expression, destDir}
 		c := &cmdrunner.Command{
-			Name: "kpt",
+			Name: bin,
 			Args: args,
 			Dir:  dir,
 		}
 
-		err = os.RemoveAll(kptDir)
+		pkg := filepath.ToSlash(rel)
+		kept, err := o.localModifications(pkg, kptDir)
 		if err != nil {
-			return errors.Wrapf(err, "failed to remove kpt directory %s", kptDir)
+			return err
+		}
+
+		// a dry run only prints the commands so must leave the existing package in place
+		if !o.DryRun {
+			err = os.RemoveAll(kptDir)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove kpt directory %s", kptDir)
+			}
+		}
+		var result *common.CommandResult
+		fetched := false
+		cache := ""
+		if o.Shallow || o.CacheDir != "" {
+			method := "shallow fetch"
+			if o.CacheDir != "" {
+				method = "fetch from the cache"
+				result, cache, err = o.cachedGet(ctx, runner, kf, version, kptDir)
+			} else {
+				result, err = o.shallowGet(ctx, runner, kf, version, kptDir)
+			}
+			if err == nil {
+				fetched = true
+			} else {
+				o.Warnf("failed to %s %s so using kpt pkg get: %s", method, info(rel), err.Error())
+				if !o.DryRun {
+					err = os.RemoveAll(kptDir)
+					if err != nil {
+						return errors.Wrapf(err, "failed to remove kpt directory %s", kptDir)
+					}
+				}
+			}
+		}
+		if !fetched {
+			result, err = runner(ctx, c)
+		}
+		// the output has already been streamed in verbose mode and is suppressed in quiet mode
+		if o.Level() == common.VerbosityNormal {
+			o.Logger().Infof(result.Output())
+		}
+		pr := PackageResult{
+			Dir:        destDir,
+			Expression: expression,
+			FromCommit: commit,
+			Cache:      cache,
+		}
+		if result != nil {
+			pr.ExitCode = result.ExitCode
+			pr.Duration = result.Duration
 		}
-		text, err := o.CommandRunner(c)
-		log.Logger().Infof(text)
+		phase := "kpt pkg get"
+		if err == nil {
+			pr.ToCommit = kptfileCommit(path)
+			if o.postCommand != nil {
+				phase = "post command"
+				c, result, err = o.runPostCommand(ctx, runner, kptDir, rel)
+				if result != nil {
+					pr.ExitCode = result.ExitCode
+					pr.Duration += result.Duration
+				}
+			}
+		}
+		if err == nil && !o.DryRun {
+			pr.KeptFiles, err = o.recordFetched(pkg, kptDir, kept)
+			if err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			pr.Error = err.Error()
+		} else {
+			packageDirs = append(packageDirs, kptDir)
+		}
+		o.Results = append(o.Results, pr)
 		if err != nil {
-			if !o.IgnoreErrors {
-				return errors.Wrapf(err, "failed to run kpt command")
+			// lets make sure the failure can be told apart from invalid Kptfiles by callers using errors.As
+			var commandErr *common.CommandError
+			if !errors.As(err, &commandErr) {
+				commandErr = common.NewCommandError(c, result, err)
+				commandErr.Command = o.Mask(c, commandErr.Command)
+				err = commandErr
 			}
-			log.Logger().Warnf(err.Error())
+			o.Errors.Append(&common.ErrorItem{Path: path, Package: destDir, Phase: phase, Err: err})
+			if !o.IgnoreErrors && !o.FailAtEnd {
+				return o.Errors
+			}
+			o.Warnf(err.Error())
 		}
 		return nil
 	})
+	if !o.DryRun && len(o.manifest.Packages) > 0 {
+		saveErr := o.manifest.Save(manifestPath)
+		if saveErr != nil {
+			if err == nil {
+				err = saveErr
+			} else {
+				o.Warnf(saveErr.Error())
+			}
+		}
+	}
+	if err == nil && o.FailAtEnd {
+		err = o.Errors.ErrorOrNil()
+	}
+	if flat {
+		if err == nil && !o.DryRun {
+			err = o.flattenPackages(dir, flatDir, packageDirs)
+		}
+		dir = flatDir
+	}
+	if err == nil && o.GitCommit && !o.DryRun {
+		err = o.commitPackages(gitDir, dir)
+	}
+	o.logResults()
+
+	result := &Result{
+		Dir:        dir,
+		Packages:   o.Results,
+		NotInLock:  o.NotInLock,
+		Collisions: o.Collisions,
+		Errors:     o.Errors,
+	}
 	if err != nil {
-		return errors.Wrapf(err, "failed to upgrade kpt packages in dir %s", dir)
+		return result, errors.Wrapf(err, "failed to upgrade kpt packages in dir %s", dir)
 	}
-	return nil
+	return result, nil
+}
+
+// runPostCommand runs the post command in the directory of the package logging its output. The evaluated
+// command is returned so that it can be reported if it fails
+func (o *Options) runPostCommand(ctx context.Context, runner common.ResultCommandRunner, kptDir, rel string) (*cmdrunner.Command, *common.CommandResult, error) {
+	c := &cmdrunner.Command{
+		Name: "sh",
+		Args: []string{"-c", o.PostCommand},
+		Dir:  kptDir,
+	}
+	var buf strings.Builder
+	err := o.postCommand.Execute(&buf, &PostCommandData{Dir: kptDir, Package: filepath.ToSlash(rel)})
+	if err != nil {
+		return c, nil, errors.Wrapf(err, "failed to evaluate the --post-command template for package %s", rel)
+	}
+	c.Args[1] = buf.String()
+	result, err := runner(ctx, c)
+	if o.Level() == common.VerbosityNormal && result.Output() != "" {
+		o.Logger().Infof(result.Output())
+	}
+	return c, result, err
+}
+
+// loadPackages loads the newline separated package directories from the given file ignoring blank lines and
+// comments starting with '#'
+func loadPackages(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load packages file %s", path)
+	}
+	answer := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		answer = append(answer, filepath.ToSlash(filepath.Clean(line)))
+	}
+	return answer, nil
+}
+
+// packagesSet returns the set of the package directories to recreate or nil if all packages should be recreated.
+// Each package must have a Kptfile in the given directory
+func (o *Options) packagesSet(dir string) (map[string]bool, error) {
+	if o.Packages == nil {
+		return nil, nil
+	}
+	answer := map[string]bool{}
+	for _, pkg := range o.Packages {
+		rel := filepath.Clean(filepath.FromSlash(pkg))
+		path := filepath.Join(dir, rel, kptfiles.FileName)
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+		}
+		if !exists {
+			return nil, errors.Errorf("the package %s has no %s in dir %s", pkg, kptfiles.FileName, dir)
+		}
+		answer[filepath.ToSlash(rel)] = true
+	}
+	return answer, nil
+}
+
+// lockedVersion returns the commit of the package in the lock file updating the upstream of the Kptfile to
+// match the lock. If the package is not in the lock file it is recorded and the Kptfile version is used
+func (o *Options) lockedVersion(kf *kptfiles.Kptfile, rel string) string {
+	pkg := o.Lock.FindPackage(filepath.ToSlash(rel))
+	if pkg == nil {
+		o.Debugf("kpt package at %s is not in the lock file", rel)
+		o.NotInLock = append(o.NotInLock, filepath.ToSlash(rel))
+		return ""
+	}
+	kf.Repo = pkg.Repo
+	if pkg.Directory != "" {
+		kf.Directory = pkg.Directory
+	}
+	return pkg.Commit
+}
+
+func (o *Options) logResults() {
+	for _, r := range o.Results {
+		if r.Error != "" {
+			o.Warnf("failed to recreate %s from %s with exit code %d after %s", info(r.Dir), r.Expression, r.ExitCode, r.Duration.String())
+			continue
+		}
+		o.Infof("recreated %s from %s in %s", info(r.Dir), r.Expression, r.Duration.String())
+		if len(r.KeptFiles) > 0 {
+			o.Infof("kept the local modifications of %s in %s", strings.Join(r.KeptFiles, ", "), info(r.Dir))
+		}
+	}
+	if o.CacheDir != "" {
+		hits, misses := o.cacheCounts()
+		o.Infof("fetched packages from the cache dir %s with %d hits and %d misses", info(o.CacheDir), hits, misses)
+	}
+	if len(o.NotInLock) > 0 {
+		o.Warnf("recreated %d kpt packages from their Kptfile as they are not in the lock file: %s", len(o.NotInLock), strings.Join(o.NotInLock, ", "))
+	}
+}
+
+// cacheCounts returns the number of packages whose commits were already in the --cache-dir and the number which
+// needed the upstream repository to be cloned or fetched
+func (o *Options) cacheCounts() (int, int) {
+	hits, misses := 0, 0
+	for _, r := range o.Results {
+		switch r.Cache {
+		case CacheHit:
+			hits++
+		case CacheMiss:
+			misses++
+		}
+	}
+	return hits, misses
+}
+
+// packagePrefix returns the destination directory of the kpt command so that the streamed output of
+// each package can be told apart
+func packagePrefix(c *cmdrunner.Command) string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return c.Args[len(c.Args)-1] + ": "
+}
+
+// copySymlinks copies the symbolic links in the source directory tree to the destination as they are
+// skipped when copying the files
+func copySymlinks(srcDir, dstDir string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to calculate the relative path of %s", path)
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read symlink %s", path)
+		}
+		dstPath := filepath.Join(dstDir, rel)
+		if _, err := os.Lstat(dstPath); err == nil {
+			return nil
+		}
+		err = os.Symlink(target, dstPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create symlink %s", dstPath)
+		}
+		return nil
+	})
+}
+
+// newResultRunner decorates the runner with the timeout and passes the environment to the commands masking any
+// secrets when logging them. If quiet is true the command lines are only logged at debug level
+func (o *Options) newResultRunner(next common.ResultCommandRunner, quiet bool) common.ResultCommandRunner {
+	if o.Timeout > 0 {
+		next = common.NewTimeoutResultRunner(next, o.Timeout)
+	}
+	if o.CommandEnvironment.Log == nil {
+		o.CommandEnvironment.Log = o.Verbosity.Log
+	}
+	env := o.CommandEnvironment
+	env.QuietLogging = quiet
+	return env.NewResultRunner(next)
+}
+
+// kptBinary resolves the kpt binary unless the commands are not being executed
+func (o *Options) kptBinary() (string, error) {
+	if o.DryRun || o.CommandRunner != nil {
+		if o.KptBinary != "" {
+			return o.KptBinary, nil
+		}
+		return "kpt", nil
+	}
+	bin, version, err := common.ResolveBinary("kpt", common.WithOverride("bin", o.KptBinary), common.WithVersionArgs("version"))
+	if err != nil {
+		return "", err
+	}
+	o.Debugf("using kpt binary %s version %s", bin, version)
+	return bin, nil
 }
@@ -1,11 +1,23 @@
 package recreate_test
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/recreate"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,21 +27,1179 @@ func TestKptRecreate(t *testing.T) {
 	require.NoError(t, err, "failed to find abs dir of %s", sourceDir)
 	require.DirExists(t, absSourceDir)
 
+	outDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
 	_, uk := recreate.NewCmdKptRecreate()
 
-	runner := &fakerunner.FakeRunner{}
+	// simulates kpt fetching the package into the destination directory
+	fakeKptGet := func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Dir, c.Args[3])
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "fetched.yaml"), []byte("fetched: true\n"), files.DefaultFileWritePermissions)
+	}
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "kpt",
+			Args:     []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/myapps/app1"},
+			Callback: fakeKptGet,
+		},
+		testhelpers.Expectation{
+			Name:     "kpt",
+			Args:     []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/app2"},
+			Callback: fakeKptGet,
+		},
+	)
 	uk.CommandRunner = runner.Run
 	uk.Dir = sourceDir
+	uk.OutDir = outDir
 
 	err = uk.Run()
 	require.NoError(t, err, "failed to run recreate kpt")
 
-	runner.ExpectResults(t,
-		fakerunner.FakeResult{
-			CLI: "kpt pkg get https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23 config-root/namespaces/myapps/app1",
+	runner.Verify(t)
+
+	assert.FileExists(t, filepath.Join(outDir, "config-root", "namespaces", "myapps", "app1", "fetched.yaml"))
+	assert.FileExists(t, filepath.Join(outDir, "config-root", "namespaces", "app2", "fetched.yaml"))
+	assert.NoFileExists(t, filepath.Join(outDir, "config-root", "namespaces", "myapps", "app1", "Kptfile"), "the old Kptfile should have been removed")
+}
+
+func TestKptRecreateIgnoreErrors(t *testing.T) {
+	sourceDir := filepath.Join("test_data")
+
+	_, uk := recreate.NewCmdKptRecreate()
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{
+			Name:   "kpt",
+			Args:   []string{"pkg", "get", "https://github.com/another/thing.git/*", "*"},
+			Output: "error: failed to clone",
+			Error:  os.ErrNotExist,
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/*", "*"},
+		},
+	)
+	uk.CommandRunner = runner.Run
+	uk.Dir = sourceDir
+	uk.IgnoreErrors = true
+
+	err := uk.Run()
+	require.NoError(t, err, "failed to run recreate kpt")
+
+	runner.Verify(t)
+
+	require.Len(t, uk.Results, 2, "results")
+	assert.Equal(t, "config-root/namespaces/app2", uk.Results[0].Dir)
+	assert.Equal(t, 1, uk.Results[0].ExitCode, "exit code of the failed package")
+	assert.Equal(t, os.ErrNotExist.Error(), uk.Results[0].Error, "error of the failed package")
+	assert.Equal(t, "config-root/namespaces/myapps/app1", uk.Results[1].Dir)
+	assert.Equal(t, 0, uk.Results[1].ExitCode, "exit code of the recreated package")
+	assert.Empty(t, uk.Results[1].Error)
+}
+
+func TestKptRecreateMaxDepth(t *testing.T) {
+	sourceDir := filepath.Join("test_data")
+
+	_, uk := recreate.NewCmdKptRecreate()
+
+	// the Kptfiles are all 5 levels deep so none of them should be recreated
+	runner := testhelpers.NewFakeCommandRunner()
+	uk.CommandRunner = runner.Run
+	uk.Dir = sourceDir
+	uk.MaxDepth = 4
+
+	err := uk.Run()
+	require.NoError(t, err, "failed to run recreate kpt")
+
+	runner.Verify(t)
+	assert.Empty(t, runner.Invocations(), "should not have run any commands")
+}
+
+func TestKptRecreateQuiet(t *testing.T) {
+	sourceDir := filepath.Join("test_data")
+
+	for _, quiet := range []bool{false, true} {
+		_, uk := recreate.NewCmdKptRecreate()
+
+		runner := testhelpers.NewFakeCommandRunner()
+		for i := 0; i < 2; i++ {
+			runner.Expect(testhelpers.Expectation{
+				Name:   "kpt",
+				Output: "fetching package from upstream",
+			})
+		}
+		uk.CommandRunner = runner.Run
+		uk.Dir = sourceDir
+		uk.Quiet = quiet
+
+		var err error
+		logs := log.CaptureOutput(func() {
+			err = uk.Run()
+		})
+		require.NoError(t, err, "failed to run recreate kpt with quiet %v", quiet)
+
+		if quiet {
+			assert.NotContains(t, logs, "fetching package from upstream", "the kpt output should be suppressed")
+			assert.NotContains(t, logs, "about to run", "the commands should not be logged")
+		} else {
+			assert.Contains(t, logs, "fetching package from upstream", "the kpt output should be logged")
+			assert.Contains(t, logs, "about to run", "the commands should be logged")
+		}
+	}
+
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = sourceDir
+	uk.Verbose = true
+	uk.Quiet = true
+	err := uk.Run()
+	require.Error(t, err, "should fail with both --verbose and --quiet")
+}
+
+func TestKptRecreateMarkdownSummary(t *testing.T) {
+	sourceDir := filepath.Join("test_data")
+
+	// simulates kpt fetching a newer commit of the package
+	fakeKptGet := func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Dir, c.Args[3])
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		kptfile := "apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\nmetadata:\n  name: app1\nupstream:\n  type: git\n  git:\n    commit: 9f8e7d6c5b4a\n"
+		return ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kptfile), files.DefaultFileWritePermissions)
+	}
+
+	for _, ignoreErrors := range []bool{true, false} {
+		tmpDir, err := ioutil.TempDir("", "")
+		require.NoError(t, err, "failed to create temp dir")
+		summaryFile := filepath.Join(tmpDir, "summary", "kpt.md")
+
+		_, uk := recreate.NewCmdKptRecreate()
+		runner := testhelpers.NewFakeCommandRunner()
+		runner.Ordered = true
+		runner.Expect(
+			testhelpers.Expectation{
+				Name:   "kpt",
+				Args:   []string{"pkg", "get", "https://github.com/another/thing.git/*", "*"},
+				Output: "error: failed to clone",
+				Error:  errors.New("failed to clone | exit status 1\ncaused by network"),
+			},
+			testhelpers.Expectation{
+				Name:     "kpt",
+				Args:     []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/*", "*"},
+				Callback: fakeKptGet,
+			},
+		)
+		uk.CommandRunner = runner.Run
+		uk.Dir = sourceDir
+		uk.OutDir = filepath.Join(tmpDir, "out")
+		uk.IgnoreErrors = ignoreErrors
+		uk.MarkdownFile = summaryFile
+
+		err = uk.Run()
+		if ignoreErrors {
+			require.NoError(t, err, "failed to run recreate kpt")
+		} else {
+			require.Error(t, err, "should have failed to recreate app2")
+		}
+
+		data, err := ioutil.ReadFile(summaryFile)
+		require.NoError(t, err, "failed to load the markdown summary with ignore errors %v", ignoreErrors)
+
+		expected := "### kpt recreate\n\n* refreshed packages: **1**\n* changed commit: **1**\n* failed: **1**\n\n" +
+			"#### Changed commit\n\n| Package | From | To |\n| --- | --- | --- |\n" +
+			"| `config-root/namespaces/myapps/app1` | `4cc6b80d49808060b1f06f530399b986ed344f23` | `9f8e7d6c5b4a` |\n\n" +
+			"#### Failures\n\n| Package | Exit Code | Error |\n| --- | --- | --- |\n" +
+			"| `config-root/namespaces/app2` | 1 | failed to clone \\| exit status 1 |\n"
+		if !ignoreErrors {
+			// the walk stops at the first failure
+			expected = "### kpt recreate\n\n* refreshed packages: **0**\n* changed commit: **0**\n* failed: **1**\n\n" +
+				"#### Failures\n\n| Package | Exit Code | Error |\n| --- | --- | --- |\n" +
+				"| `config-root/namespaces/app2` | 1 | failed to clone \\| exit status 1 |\n"
+		}
+		assert.Equal(t, expected, string(data), "markdown summary with ignore errors %v", ignoreErrors)
+	}
+}
+
+func TestKptRecreateFollowSymlinks(t *testing.T) {
+	for _, inPlace := range []bool{true, false} {
+		repoDir := createSymlinkedPackage(t)
+
+		_, uk := recreate.NewCmdKptRecreate()
+
+		// config-root is walked before overlays so the package is recreated via the symlink and the
+		// real directory is then skipped as it has already been walked
+		runner := testhelpers.NewFakeCommandRunner().Expect(
+			testhelpers.Expectation{
+				Name: "kpt",
+				Args: []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/shared/app3"},
+			},
+		)
+		uk.CommandRunner = runner.Run
+		uk.Dir = repoDir
+		if inPlace {
+			uk.OutDir = repoDir
+		}
+		uk.FollowSymlinks = true
+
+		err := uk.Run()
+		require.NoError(t, err, "failed to run recreate kpt in place %v", inPlace)
+
+		runner.Verify(t)
+	}
+}
+
+// createSymlinkedPackage creates a package which is only reachable via the config-root/namespaces/shared symlink
+func createSymlinkedPackage(t *testing.T) string {
+	repoDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	sharedDir := filepath.Join(repoDir, "overlays", "shared", "app3")
+	err = os.MkdirAll(sharedDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir %s", sharedDir)
+	err = files.CopyFile(filepath.Join("test_data", "config-root", "namespaces", "app2", "app2", "Kptfile"), filepath.Join(sharedDir, "Kptfile"))
+	require.NoError(t, err, "failed to copy Kptfile")
+	err = os.MkdirAll(filepath.Join(repoDir, "config-root", "namespaces"), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create namespaces dir")
+	err = os.Symlink(filepath.Join("..", "..", "overlays", "shared"), filepath.Join(repoDir, "config-root", "namespaces", "shared"))
+	require.NoError(t, err, "failed to create symlink")
+	return repoDir
+}
+
+func TestKptRecreateTimeout(t *testing.T) {
+	_, uk := recreate.NewCmdKptRecreate()
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name: "kpt",
+			Callback: func(c *cmdrunner.Command) error {
+				time.Sleep(2 * time.Second)
+				return nil
+			},
+		},
+	)
+	uk.CommandRunner = runner.Run
+	uk.Dir = filepath.Join("test_data")
+	uk.Timeout = 100 * time.Millisecond
+
+	err := uk.Run()
+	require.Error(t, err, "should have timed out")
+	assert.Contains(t, err.Error(), "timed out after 100ms")
+}
+
+func TestKptRecreateMissingBinary(t *testing.T) {
+	emptyDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	outDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	oldPath := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", emptyDir))
+	t.Cleanup(func() {
+		_ = os.Setenv("PATH", oldPath)
+	})
+
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.KptBinary = filepath.Join(emptyDir, "kpt")
+
+	err = uk.Run()
+	require.Error(t, err, "should fail if kpt is missing")
+	assert.Contains(t, err.Error(), "Please install kpt", "error")
+
+	// nothing should have been copied or removed
+	assert.NoDirExists(t, filepath.Join(outDir, "config-root"), "the packages should not have been copied")
+	assert.FileExists(t, filepath.Join("test_data", "config-root", "namespaces", "myapps", "app1", "Kptfile"))
+}
+
+func TestKptRecreateFailAtEnd(t *testing.T) {
+	_, uk := recreate.NewCmdKptRecreate()
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{
+			Name:   "kpt",
+			Args:   []string{"pkg", "get", "https://github.com/another/thing.git/*", "*"},
+			Output: "error: failed to clone",
+			Error:  os.ErrNotExist,
 		},
-		fakerunner.FakeResult{
-			CLI: "kpt pkg get https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23 config-root/namespaces/app2",
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/*", "*"},
+		},
+	)
+	uk.CommandRunner = runner.Run
+	uk.Dir = filepath.Join("test_data")
+	uk.FailAtEnd = true
+
+	err := uk.Run()
+	require.Error(t, err, "should fail after recreating all the packages")
+	t.Logf("got expected error: %s", err.Error())
+
+	// all the packages should have been processed
+	runner.Verify(t)
+	require.Len(t, uk.Results, 2, "results")
+
+	var list *common.ErrorList
+	require.True(t, errors.As(err, &list), "the error should contain the list of failed packages")
+	require.Equal(t, 1, list.Len(), "failed packages")
+	assert.Equal(t, "config-root/namespaces/app2", list.Items[0].Package, "failed package")
+	assert.Equal(t, "kpt pkg get", list.Items[0].Phase, "failed phase")
+	assert.True(t, errors.Is(err, os.ErrNotExist), "the cause should be found through the list")
+
+	var commandErr *common.CommandError
+	require.True(t, errors.As(err, &commandErr), "the error should contain the failed command")
+	assert.Equal(t, "kpt pkg get https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23 config-root/namespaces/app2", commandErr.Command, "failed command")
+	assert.Equal(t, "error: failed to clone", commandErr.Output, "output of the failed command")
+	assert.Equal(t, 1, commandErr.ExitCode, "exit code of the failed command")
+}
+
+func TestKptRecreateInvalidKptfile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	pkgDir := filepath.Join(tmpDir, "config-root", "namespaces", "app")
+	err = os.MkdirAll(pkgDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir %s", pkgDir)
+
+	path := filepath.Join(pkgDir, kptfiles.FileName)
+	err = ioutil.WriteFile(path, []byte("apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\nmetadata:\n  name: app\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", path)
+
+	_, uk := recreate.NewCmdKptRecreate()
+	runner := testhelpers.NewFakeCommandRunner()
+	uk.CommandRunner = runner.Run
+	uk.Dir = tmpDir
+
+	err = uk.Run()
+	require.Error(t, err, "should fail for a Kptfile without an upstream")
+	runner.Verify(t)
+
+	var missingErr *kptfiles.MissingFieldError
+	require.True(t, errors.As(err, &missingErr), "the error should be a missing field error but was %s", err.Error())
+	assert.Equal(t, "upstream.git.repo", missingErr.Field, "missing field")
+	relPath := filepath.Join("config-root", "namespaces", "app", kptfiles.FileName)
+	assert.True(t, strings.HasSuffix(missingErr.Path, relPath), "path %s of the Kptfile", missingErr.Path)
+
+	var commandErr *common.CommandError
+	assert.False(t, errors.As(err, &commandErr), "the error should not be a command error")
+
+	err = ioutil.WriteFile(path, []byte("upstream: [\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", path)
+
+	err = uk.Run()
+	require.Error(t, err, "should fail for an invalid Kptfile")
+
+	var parseErr *kptfiles.ParseError
+	require.True(t, errors.As(err, &parseErr), "the error should be a parse error but was %s", err.Error())
+	assert.True(t, strings.HasSuffix(parseErr.Path, relPath), "path %s of the Kptfile", parseErr.Path)
+}
+
+func TestRecreateLibrary(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	err = files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test_data to %s", tmpDir)
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{
+			Name:   "kpt",
+			Args:   []string{"pkg", "get", "https://github.com/another/thing.git/*", "*"},
+			Output: "error: failed to clone",
+			Error:  os.ErrNotExist,
+		},
+		testhelpers.Expectation{
+			Name:   "kpt",
+			Args:   []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/*", "*"},
+			Output: "fetched package",
+		},
+	)
+	logger := &testhelpers.FakeLogger{}
+
+	// the options can be used without a cobra command
+	o := &recreate.Options{
+		Dir:           tmpDir,
+		IgnoreErrors:  true,
+		CommandRunner: runner.Run,
+		Verbosity:     common.Verbosity{Log: logger},
+	}
+	var result *recreate.Result
+	logs := log.CaptureOutput(func() {
+		result, err = o.Recreate(context.Background())
+	})
+	require.NoError(t, err, "failed to recreate")
+	runner.Verify(t)
+	t.Logf("logs: %s", logger.String())
+	assert.Empty(t, logs, "nothing should be logged to the global logger")
+
+	// without an output directory the packages are recreated in place
+	require.NotNil(t, result, "result")
+	assert.Equal(t, tmpDir, result.Dir, "dir of the result")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "config-root", "namespaces", "myapps", "app1", "Kptfile"), "the old Kptfile should have been removed")
+	require.Len(t, result.Packages, 2, "packages")
+	failed := result.Failed()
+	require.Len(t, failed, 1, "failed packages")
+	assert.Equal(t, "config-root/namespaces/app2", failed[0].Dir, "failed package")
+	assert.Equal(t, 1, result.Errors.Len(), "errors")
+
+	messages := logger.String()
+	assert.Contains(t, messages, "info: fetched package", "logs")
+	assert.Contains(t, messages, "warn: failed to recreate", "logs")
+}
+
+func TestRecreateDryRun(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	runner := testhelpers.NewFakeCommandRunner()
+	var buf strings.Builder
+
+	// without an output directory a dry run must not remove the packages it would recreate in place
+	o := &recreate.Options{
+		Dir:           tmpDir,
+		DryRun:        true,
+		CommandRunner: runner.Run,
+		Out:           &buf,
+		Verbosity:     common.Verbosity{Log: &testhelpers.FakeLogger{}},
+	}
+	result, err := o.Recreate(context.Background())
+	require.NoError(t, err, "failed to recreate")
+	runner.Verify(t)
+
+	require.NotNil(t, result, "result")
+	assert.Len(t, result.Packages, 2, "packages")
+	for _, name := range []string{"Kptfile", "service.yaml"} {
+		assert.FileExists(t, filepath.Join(tmpDir, "config-root", "namespaces", "myapps", "app1", name))
+		assert.FileExists(t, filepath.Join(tmpDir, "config-root", "namespaces", "app2", "app2", name))
+	}
+	assert.Contains(t, buf.String(), "kpt pkg get", "dry run output")
+}
+
+func TestKptRecreateLockFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	lockFile := filepath.Join(tmpDir, "kpt-lock.yaml")
+	lock := `apiVersion: gitops.jenkins-x.io/v1alpha1
+kind: KptLock
+spec:
+  packages:
+  - path: config-root/namespaces/myapps/app1
+    repo: https://github.com/jenkins-x/jxr-kube-resources-fork
+    directory: /jenkins-x/lighthouse
+    commit: 1a2b3c4d5e6f
+`
+	err = ioutil.WriteFile(lockFile, []byte(lock), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", lockFile)
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/app2"},
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources-fork.git/jenkins-x/lighthouse@1a2b3c4d5e6f", "config-root/namespaces/myapps/app1"},
+		},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.LockFile = lockFile
+	uk.MarkdownFile = filepath.Join(tmpDir, "kpt.md")
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate from the lock file")
+	runner.Verify(t)
+
+	assert.Equal(t, []string{"config-root/namespaces/app2/app2"}, uk.NotInLock, "packages not in the lock file")
+	data, err := ioutil.ReadFile(uk.MarkdownFile)
+	require.NoError(t, err, "failed to load the markdown summary")
+	assert.Contains(t, string(data), "#### Not in lock file\n\n* `config-root/namespaces/app2/app2`\n", "markdown summary")
+
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = "test_data"
+	uk.LockFile = lockFile
+	uk.Version = "master"
+	err = uk.Run()
+	require.Error(t, err, "should fail with both --version and --lock-file")
+
+	uk.Version = ""
+	uk.LockFile = filepath.Join(tmpDir, "does-not-exist.yaml")
+	err = uk.Run()
+	require.Error(t, err, "should fail for a missing lock file")
+	assert.Contains(t, err.Error(), "does not exist", "error message")
+}
+
+func TestKptRecreatePackagesFromFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	packagesFile := filepath.Join(tmpDir, "packages.txt")
+	err = ioutil.WriteFile(packagesFile, []byte("# the changed packages\nconfig-root/namespaces/app2/app2/\n\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", packagesFile)
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/app2"},
 		},
 	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.PackagesFile = packagesFile
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the packages from the file")
+	runner.Verify(t)
+	assert.Equal(t, []string{"config-root/namespaces/app2/app2"}, uk.Packages, "packages")
+	assert.FileExists(t, filepath.Join(uk.OutDir, "config-root", "namespaces", "myapps", "app1", "Kptfile"), "the packages not listed should be left untouched")
+
+	err = ioutil.WriteFile(packagesFile, []byte("config-root/namespaces/app3\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", packagesFile)
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out2")
+	uk.PackagesFile = packagesFile
+	err = uk.Run()
+	require.Error(t, err, "should fail for a package without a Kptfile")
+	assert.Contains(t, err.Error(), "config-root/namespaces/app3 has no Kptfile", "error message")
+}
+
+func TestKptRecreateSince(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"rev-parse", "--verify", "--quiet", "origin/main^{commit}"},
+		},
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"diff", "--name-only", "--relative", "origin/main", "--"},
+			Output: "README.md\nconfig-root/namespaces/myapps/app1/service.yaml\n",
+		},
+		testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"ls-files", "--others", "--exclude-standard"},
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/myapps/app1"},
+		},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.Since = "origin/main"
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the changed packages")
+	runner.Verify(t)
+	require.Len(t, uk.Results, 1, "results")
+	assert.Equal(t, "config-root/namespaces/myapps/app1", uk.Results[0].Dir, "recreated package")
+	assert.FileExists(t, filepath.Join(uk.OutDir, "config-root", "namespaces", "app2", "app2", "Kptfile"), "the unchanged packages should be left untouched")
+
+	// lets recreate all the packages if the ref does not exist
+	runner = testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:  "git",
+			Args:  []string{"rev-parse", "--verify", "--quiet", "origin/main^{commit}"},
+			Error: errors.New("exit status 1"),
+		},
+		testhelpers.Expectation{Name: "kpt"},
+		testhelpers.Expectation{Name: "kpt"},
+	)
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out2")
+	uk.Since = "origin/main"
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the packages without the ref")
+	runner.Verify(t)
+	assert.Len(t, uk.Results, 2, "results")
+}
+
+func TestKptRecreatePostCommand(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	outDir := filepath.Join(tmpDir, "out")
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/another/thing.git/*", "config-root/namespaces/app2"},
+		},
+		testhelpers.Expectation{
+			Name:   "sh",
+			Args:   []string{"-c", "format " + filepath.Join(outDir, "config-root", "namespaces", "app2", "app2") + " config-root/namespaces/app2/app2"},
+			Output: "formatted app2",
+			Matcher: func(c *cmdrunner.Command) bool {
+				return c.Dir == filepath.Join(outDir, "config-root", "namespaces", "app2", "app2")
+			},
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/*", "config-root/namespaces/myapps/app1"},
+		},
+		testhelpers.Expectation{
+			Name:   "sh",
+			Args:   []string{"-c", "format *"},
+			Output: "error: invalid YAML",
+			Error:  os.ErrInvalid,
+		},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.PostCommand = "format {{ .Dir }} {{ .Package }}"
+	uk.FailAtEnd = true
+
+	err = uk.Run()
+	require.Error(t, err, "should fail as the post command failed")
+	runner.Verify(t)
+
+	require.Len(t, uk.Results, 2, "results")
+	assert.Empty(t, uk.Results[0].Error, "error of the package whose post command succeeded")
+	assert.Equal(t, "config-root/namespaces/myapps/app1", uk.Results[1].Dir, "failed package")
+	assert.Equal(t, os.ErrInvalid.Error(), uk.Results[1].Error, "error of the package whose post command failed")
+
+	var list *common.ErrorList
+	require.True(t, errors.As(err, &list), "the error should contain the list of failed packages")
+	require.Equal(t, 1, list.Len(), "failed packages")
+	assert.Equal(t, "post command", list.Items[0].Phase, "failed phase")
+
+	var commandErr *common.CommandError
+	require.True(t, errors.As(err, &commandErr), "the error should contain the failed command")
+	assert.Equal(t, "error: invalid YAML", commandErr.Output, "output of the failed command")
+
+	// lets fail before fetching any packages if the template is invalid
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out2")
+	uk.PostCommand = "format {{ .Dir"
+	err = uk.Run()
+	require.Error(t, err, "should fail for an invalid post command template")
+	assert.Contains(t, err.Error(), "--post-command", "error message")
+}
+
+func TestKptRecreateShallow(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	outDir := filepath.Join(tmpDir, "out")
+	commit := "0123456789abcdef0123456789abcdef01234567"
+
+	// simulates git checking out the sparse directory of the upstream package
+	fakeCheckout := func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Dir, "kubernetes", "app2")
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "fetched.yaml"), []byte("fetched: true\n"), files.DefaultFileWritePermissions)
+	}
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		testhelpers.Expectation{Name: "git", Args: []string{"init", "--quiet"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"remote", "add", "origin", "https://github.com/another/thing.git"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"sparse-checkout", "set", "kubernetes/app2"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"fetch", "--quiet", "--depth", "1", "--filter=blob:none", "origin", "4cc6b80d49808060b1f06f530399b986ed344f23"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"checkout", "--quiet", "FETCH_HEAD"}, Callback: fakeCheckout},
+		testhelpers.Expectation{Name: "git", Args: []string{"rev-parse", "HEAD"}, Output: commit + "\n"},
+
+		// lets fall back to kpt if the server does not allow fetching the commit
+		testhelpers.Expectation{Name: "git", Args: []string{"init", "--quiet"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"remote", "add", "origin", "*"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"sparse-checkout", "set", "jenkins-x/lighthouse"}},
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"fetch", "*"},
+			Output: "error: Server does not allow request for unadvertised object",
+			Error:  os.ErrPermission,
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/myapps/app1"},
+		},
+	)
+	logger := &testhelpers.FakeLogger{}
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.Shallow = true
+	uk.Verbosity.Log = logger
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the packages")
+	runner.Verify(t)
+
+	pkgDir := filepath.Join(outDir, "config-root", "namespaces", "app2", "app2")
+	assert.FileExists(t, filepath.Join(pkgDir, "fetched.yaml"), "the file of the upstream package")
+	assert.NoFileExists(t, filepath.Join(pkgDir, "service.yaml"), "the old files should have been removed")
+
+	kf, err := kptfiles.Load(filepath.Join(pkgDir, kptfiles.FileName))
+	require.NoError(t, err, "failed to load the Kptfile of the shallow package")
+	assert.Equal(t, "app2", kf.Name, "name")
+	assert.Equal(t, "https://github.com/another/thing", kf.Repo, "repo")
+	assert.Equal(t, "/kubernetes/app2", kf.Directory, "directory")
+	assert.Equal(t, "4cc6b80d49808060b1f06f530399b986ed344f23", kf.Ref, "ref")
+	assert.Equal(t, commit, kf.Commit, "commit")
+
+	require.Len(t, uk.Results, 2, "results")
+	assert.Equal(t, commit, uk.Results[0].ToCommit, "commit of the shallow package")
+	assert.Empty(t, uk.Results[1].Error, "error of the package fetched via kpt")
+	assert.Contains(t, logger.String(), "failed to shallow fetch", "the fallback should be logged")
+}
+
+func TestKptRecreateOnConflict(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	err = files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test_data to %s", tmpDir)
+
+	pkgDir := filepath.Join(tmpDir, "config-root", "namespaces", "myapps", "app1")
+	fetchedFile := filepath.Join(pkgDir, "fetched.yaml")
+	patchFile := filepath.Join(pkgDir, "patch.yaml")
+
+	// simulates kpt fetching the package into the destination directory
+	fakeKptGet := func(c *cmdrunner.Command) error {
+		dir := filepath.Join(c.Dir, c.Args[3])
+		err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "fetched.yaml"), []byte("fetched: true\n"), files.DefaultFileWritePermissions)
+	}
+	recreatePackages := func(onConflict string, packages ...string) (*recreate.Options, *testhelpers.FakeCommandRunner, error) {
+		runner := testhelpers.NewFakeCommandRunner()
+		for _, pkg := range packages {
+			runner.Expect(testhelpers.Expectation{
+				Name:     "kpt",
+				Args:     []string{"pkg", "get", "*", pkg},
+				Callback: fakeKptGet,
+			})
+		}
+		_, uk := recreate.NewCmdKptRecreate()
+		uk.CommandRunner = runner.Run
+		uk.Dir = tmpDir
+		uk.OutDir = tmpDir
+		uk.OnConflict = onConflict
+		err := uk.Run()
+		return uk, runner, err
+	}
+
+	_, runner, err := recreatePackages(recreate.OnConflictError, "config-root/namespaces/app2", "config-root/namespaces/myapps/app1")
+	require.NoError(t, err, "failed to recreate the packages without a manifest")
+	runner.Verify(t)
+
+	manifest, err := recreate.LoadManifest(filepath.Join(tmpDir, recreate.DefaultManifestFile))
+	require.NoError(t, err, "failed to load the manifest")
+	assert.Contains(t, manifest.Packages["config-root/namespaces/myapps/app1"], "fetched.yaml", "the fetched file should be in the manifest")
+
+	// lets patch the package locally
+	err = ioutil.WriteFile(fetchedFile, []byte("fetched: patched\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to modify %s", fetchedFile)
+	err = ioutil.WriteFile(patchFile, []byte("patch: true\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", patchFile)
+
+	_, runner, err = recreatePackages(recreate.OnConflictError, "config-root/namespaces/app2")
+	require.Error(t, err, "should fail as the package has local modifications")
+	runner.Verify(t)
+	var conflictErr *recreate.LocalModificationsError
+	require.True(t, errors.As(err, &conflictErr), "the error should contain the local modifications")
+	assert.Equal(t, "config-root/namespaces/myapps/app1", conflictErr.Package, "package")
+	assert.Equal(t, []string{"fetched.yaml", "patch.yaml"}, conflictErr.Files, "modified files")
+	assert.FileExists(t, patchFile, "the package should not be removed")
+
+	uk, runner, err := recreatePackages(recreate.OnConflictKeep, "config-root/namespaces/app2", "config-root/namespaces/myapps/app1")
+	require.NoError(t, err, "failed to recreate the packages keeping the local modifications")
+	runner.Verify(t)
+	require.Len(t, uk.Results, 2, "results")
+	assert.Equal(t, []string{"fetched.yaml", "patch.yaml"}, uk.Results[1].KeptFiles, "kept files")
+	assertFileContents(t, fetchedFile, "fetched: patched\n")
+	assertFileContents(t, patchFile, "patch: true\n")
+
+	// the manifest should still record the upstream files so the modifications are kept again
+	uk, runner, err = recreatePackages(recreate.OnConflictKeep, "config-root/namespaces/app2", "config-root/namespaces/myapps/app1")
+	require.NoError(t, err, "failed to recreate the packages keeping the local modifications again")
+	runner.Verify(t)
+	assert.Equal(t, []string{"fetched.yaml", "patch.yaml"}, uk.Results[1].KeptFiles, "kept files on the second run")
+
+	uk, runner, err = recreatePackages(recreate.OnConflictOverwrite, "config-root/namespaces/app2", "config-root/namespaces/myapps/app1")
+	require.NoError(t, err, "failed to recreate the packages overwriting the local modifications")
+	runner.Verify(t)
+	assert.Empty(t, uk.Results[1].KeptFiles, "kept files when overwriting")
+	assertFileContents(t, fetchedFile, "fetched: true\n")
+	assert.NoFileExists(t, patchFile, "the local file should be removed")
+
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = tmpDir
+	uk.OnConflict = "merge"
+	err = uk.Run()
+	require.Error(t, err, "should fail for an invalid --on-conflict")
+}
+
+func assertFileContents(t *testing.T, path, expected string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, expected, string(data), "contents of %s", path)
+}
+
+func TestKptRecreateGitDir(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err, "failed to resolve the temp dir")
+	gitDir := filepath.Join(tmpDir, "infra")
+	outDir := filepath.Join(gitDir, "config")
+	err = os.MkdirAll(gitDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create %s", gitDir)
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"rev-parse", "--show-toplevel"},
+			Output: gitDir + "\n",
+		},
+		testhelpers.Expectation{Name: "kpt"},
+		testhelpers.Expectation{Name: "kpt"},
+		testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"add", "--all", "--", "config"},
+		},
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"status", "-s"},
+			Output: " M config/config-root/namespaces/app2/Kptfile\n",
+		},
+		testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"commit", "-m", "chore: upgrade packages"},
+		},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.GitDir = gitDir
+	uk.GitCommit = true
+	uk.CommitMessage = "chore: upgrade packages"
+	uk.Env = map[string]string{"GIT_SSH_COMMAND": "ssh -o StrictHostKeyChecking=no"}
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate and commit the packages")
+	runner.Verify(t)
+	for _, c := range runner.Invocations() {
+		if c.Name == "git" {
+			assert.Equal(t, gitDir, c.Dir, "the git command %s should run in the git dir", c.CLI())
+			assert.Equal(t, "ssh -o StrictHostKeyChecking=no", c.Env["GIT_SSH_COMMAND"], "the git command %s should be passed the environment", c.CLI())
+		}
+	}
+}
+
+func TestKptRecreateInvalidGitDir(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// lets check nothing is recreated if the git dir is not a git repository
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:  "git",
+			Args:  []string{"rev-parse", "--show-toplevel"},
+			Error: errors.New("fatal: not a git repository"),
+		},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.GitDir = tmpDir
+	uk.GitCommit = true
+
+	err := uk.Run()
+	require.Error(t, err, "should have failed as the git dir is not a git repository")
+	assert.Contains(t, err.Error(), "is not inside a git repository")
+	runner.Verify(t)
+	assert.NoDirExists(t, uk.OutDir, "nothing should have been recreated")
+
+	// lets check the output dir must be inside the git repository
+	otherRepo := filepath.Join(tmpDir, "other")
+	runner = testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"rev-parse", "--show-toplevel"},
+			Output: otherRepo,
+		},
+	)
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.GitDir = tmpDir
+
+	err = uk.Run()
+	require.Error(t, err, "should have failed as the output dir is not inside the git repository")
+	assert.Contains(t, err.Error(), "is not inside the git repository")
+	runner.Verify(t)
+
+	// lets check the git dir must exist
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = "test_data"
+	uk.OutDir = filepath.Join(tmpDir, "out")
+	uk.GitDir = filepath.Join(tmpDir, "does-not-exist")
+
+	err = uk.Run()
+	require.Error(t, err, "should have failed as the git dir does not exist")
+}
+
+func TestKptRecreateCacheDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	outDir := filepath.Join(tmpDir, "out")
+	cacheDir := filepath.Join(tmpDir, "cache")
+	commit := "4cc6b80d49808060b1f06f530399b986ed344f23"
+	masterCommit := "0123456789abcdef0123456789abcdef01234567"
+
+	err := files.CopyDirOverwrite("test_data", srcDir)
+	require.NoError(t, err, "failed to copy the test data")
+
+	// lets add another package from the same upstream repository as app2 which uses a branch
+	app3Dir := filepath.Join(srcDir, "config-root", "namespaces", "app3", "app3")
+	err = os.MkdirAll(app3Dir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create %s", app3Dir)
+	kf := &kptfiles.Kptfile{APIVersion: kptfiles.APIVersionV1Alpha1}
+	kf.Name = "app3"
+	kf.Repo = "https://github.com/another/thing"
+	kf.Directory = "/kubernetes/app3"
+	kf.Ref = "master"
+	err = kf.Save(filepath.Join(app3Dir, kptfiles.FileName))
+	require.NoError(t, err, "failed to save the Kptfile of app3")
+
+	// lets simulate the upstream repository of app2 and app3 already being in the cache
+	thingRepoDir := filepath.Join(cacheDir, "github.com_another_thing.git")
+	err = os.MkdirAll(thingRepoDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create %s", thingRepoDir)
+	lighthouseRepoDir := filepath.Join(cacheDir, "github.com_jenkins-x_jxr-kube-resources.git")
+
+	// simulates git checking out the sparse directory of the upstream package
+	fakeCheckout := func(directory string) func(c *cmdrunner.Command) error {
+		return func(c *cmdrunner.Command) error {
+			dir := filepath.Join(c.Dir, filepath.FromSlash(directory))
+			err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(dir, "fetched.yaml"), []byte("fetched: true\n"), files.DefaultFileWritePermissions)
+		}
+	}
+	inDir := func(dir string) func(c *cmdrunner.Command) bool {
+		return func(c *cmdrunner.Command) bool {
+			return c.Dir == dir
+		}
+	}
+
+	runner := testhelpers.NewFakeCommandRunner()
+	runner.Ordered = true
+	runner.Expect(
+		// the commit of app2 is already in the cache
+		testhelpers.Expectation{Name: "git", Args: []string{"rev-parse", "--verify", "--quiet", commit + "^{commit}"}, Matcher: inDir(thingRepoDir), Output: commit + "\n"},
+		testhelpers.Expectation{Name: "git", Args: []string{"clone", "--quiet", "--shared", "--no-checkout", thingRepoDir, "."}},
+		testhelpers.Expectation{Name: "git", Args: []string{"sparse-checkout", "set", "kubernetes/app2"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"checkout", "--quiet", commit}, Callback: fakeCheckout("kubernetes/app2")},
+
+		// the branch of app3 needs fetching
+		testhelpers.Expectation{Name: "git", Args: []string{"fetch", "--quiet", "--tags", "--force", "origin", "+refs/heads/*:refs/heads/*"}, Matcher: inDir(thingRepoDir)},
+		testhelpers.Expectation{Name: "git", Args: []string{"rev-parse", "--verify", "--quiet", "master^{commit}"}, Output: masterCommit + "\n"},
+		testhelpers.Expectation{Name: "git", Args: []string{"clone", "--quiet", "--shared", "--no-checkout", thingRepoDir, "."}},
+		testhelpers.Expectation{Name: "git", Args: []string{"sparse-checkout", "set", "kubernetes/app3"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"checkout", "--quiet", masterCommit}, Callback: fakeCheckout("kubernetes/app3")},
+
+		// the upstream repository of app1 is not in the cache
+		testhelpers.Expectation{Name: "git", Args: []string{"clone", "--quiet", "--bare", "https://github.com/jenkins-x/jxr-kube-resources.git", lighthouseRepoDir}, Matcher: inDir(cacheDir)},
+		testhelpers.Expectation{Name: "git", Args: []string{"rev-parse", "--verify", "--quiet", commit + "^{commit}"}, Matcher: inDir(lighthouseRepoDir), Output: commit + "\n"},
+		testhelpers.Expectation{Name: "git", Args: []string{"clone", "--quiet", "--shared", "--no-checkout", lighthouseRepoDir, "."}},
+		testhelpers.Expectation{Name: "git", Args: []string{"sparse-checkout", "set", "jenkins-x/lighthouse"}},
+		testhelpers.Expectation{Name: "git", Args: []string{"checkout", "--quiet", commit}, Callback: fakeCheckout("jenkins-x/lighthouse")},
+	)
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = srcDir
+	uk.OutDir = outDir
+	uk.CacheDir = cacheDir
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the packages from the cache")
+	runner.Verify(t)
+
+	require.Len(t, uk.Results, 3, "results")
+	var caches []string
+	for _, r := range uk.Results {
+		assert.Empty(t, r.Error, "error of package %s", r.Dir)
+		caches = append(caches, r.Dir+" "+r.Cache)
+	}
+	assert.Equal(t, []string{
+		"config-root/namespaces/app2 hit",
+		"config-root/namespaces/app3 miss",
+		"config-root/namespaces/myapps/app1 miss",
+	}, caches, "cache hits and misses")
+
+	pkgDir := filepath.Join(outDir, "config-root", "namespaces", "app3", "app3")
+	assert.FileExists(t, filepath.Join(pkgDir, "fetched.yaml"), "the file of the upstream package")
+	kf, err = kptfiles.Load(filepath.Join(pkgDir, kptfiles.FileName))
+	require.NoError(t, err, "failed to load the Kptfile of app3")
+	assert.Equal(t, "master", kf.Ref, "ref")
+	assert.Equal(t, masterCommit, kf.Commit, "commit")
+
+	summary := uk.MarkdownSummary()
+	assert.Contains(t, summary, "* cache hits: **1**", "markdown summary")
+	assert.Contains(t, summary, "* cache misses: **2**", "markdown summary")
+
+	// lets check the cache cannot be combined with a shallow fetch
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.Shallow = true
+	uk.CacheDir = cacheDir
+	err = uk.Validate()
+	require.Error(t, err, "should not allow both --shallow and --cache-dir")
+}
+
+func TestKptRecreateOutputFormatFlat(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// simulates kpt fetching the resources of the package into the package directory
+	fakeKptGet := func(pkgDir, data string) func(c *cmdrunner.Command) error {
+		return func(c *cmdrunner.Command) error {
+			dir := filepath.Join(c.Dir, filepath.FromSlash(pkgDir))
+			err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(data), files.DefaultFileWritePermissions)
+		}
+	}
+	app1Resources := `apiVersion: v1
+kind: Service
+metadata:
+  name: app1
+  namespace: myapps
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:reader
+`
+	app2Resources := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app2
+  namespace: app2
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:reader
+`
+	newRunner := func() *testhelpers.FakeCommandRunner {
+		return testhelpers.NewFakeCommandRunner().Expect(
+			testhelpers.Expectation{
+				Name:     "kpt",
+				Args:     []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/myapps/app1"},
+				Callback: fakeKptGet("config-root/namespaces/myapps/app1", app1Resources),
+			},
+			testhelpers.Expectation{
+				Name:     "kpt",
+				Args:     []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", "config-root/namespaces/app2"},
+				Callback: fakeKptGet("config-root/namespaces/app2/app2", app2Resources),
+			},
+		)
+	}
+
+	// lets check the collision is reported without writing any files
+	outDir := filepath.Join(tmpDir, "collisions")
+	_, uk := recreate.NewCmdKptRecreate()
+	uk.CommandRunner = newRunner().Run
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.OutputFormat = recreate.OutputFormatFlat
+
+	err := uk.Run()
+	require.Error(t, err, "should have failed with the colliding ClusterRoles")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), "clusterrole-system-reader.yaml", "error should contain the colliding file name")
+	assert.Equal(t, []recreate.Collision{
+		{
+			File:      "clusterrole-system-reader.yaml",
+			Resources: []string{"ClusterRole/system:reader", "ClusterRole/system:reader"},
+			Paths:     []string{"config-root/namespaces/app2/app2/resources.yaml", "config-root/namespaces/myapps/app1/resources.yaml"},
+		},
+	}, uk.Collisions, "collisions")
+	assert.NoFileExists(t, filepath.Join(outDir, "service-myapps-app1.yaml"), "nothing should be written if there are collisions")
+
+	// lets resolve the collision with numeric suffixes
+	outDir = filepath.Join(tmpDir, "force")
+	runner := newRunner()
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = runner.Run
+	uk.Dir = "test_data"
+	uk.OutDir = outDir
+	uk.OutputFormat = recreate.OutputFormatFlat
+	uk.Force = true
+
+	err = uk.Run()
+	require.NoError(t, err, "failed to recreate the packages into a flat directory")
+	runner.Verify(t)
+	assert.Len(t, uk.Collisions, 1, "collisions")
+
+	fileInfos, err := ioutil.ReadDir(outDir)
+	require.NoError(t, err, "failed to read dir %s", outDir)
+	var names []string
+	for _, f := range fileInfos {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{
+		"clusterrole-system-reader-2.yaml",
+		"clusterrole-system-reader.yaml",
+		"configmap-app2-app2.yaml",
+		"service-myapps-app1.yaml",
+	}, names, "files in the flat output directory")
+
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "service-myapps-app1.yaml"))
+	require.NoError(t, err, "failed to load the flattened Service")
+	assert.Equal(t, "apiVersion: v1\nkind: Service\nmetadata:\n  name: app1\n  namespace: myapps\n", string(data), "flattened Service")
+
+	// lets check the flat format needs a separate output directory
+	_, uk = recreate.NewCmdKptRecreate()
+	uk.CommandRunner = testhelpers.NewFakeCommandRunner().Run
+	uk.Dir = "test_data"
+	uk.OutputFormat = recreate.OutputFormatFlat
+	_, err = uk.Recreate(context.Background())
+	require.Error(t, err, "should not flatten the packages in place")
+
+	uk.OutputFormat = "tree"
+	err = uk.Validate()
+	require.Error(t, err, "should not allow an unknown output format")
 }
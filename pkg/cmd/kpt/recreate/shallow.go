@@ -0,0 +1,128 @@
+package recreate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+// shallowGet fetches the package using a shallow sparse git checkout of just the version and directory of the
+// upstream package rather than the full clone used by 'kpt pkg get' then writes the Kptfile in the same way as
+// kpt. The durations of the git commands are combined into the result
+func (o *Options) shallowGet(ctx context.Context, runner common.ResultCommandRunner, kf *kptfiles.Kptfile, version, kptDir string) (*common.CommandResult, error) {
+	tmpDir, err := ioutil.TempDir("", "kpt-shallow-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	directory := strings.Trim(filepath.ToSlash(kf.Directory), "/")
+	commands := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", kptfiles.NormalizeRepo(kf.Repo)},
+		{"sparse-checkout", "set", directory},
+		{"fetch", "--quiet", "--depth", "1", "--filter=blob:none", "origin", version},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+		{"rev-parse", "HEAD"},
+	}
+	total := &common.CommandResult{}
+	err = runGit(ctx, runner, tmpDir, total, commands...)
+	if err != nil {
+		return total, err
+	}
+	if o.DryRun {
+		return total, nil
+	}
+	commit := total.Stdout
+	if commit == "" {
+		return total, errors.Errorf("no commit returned by git rev-parse HEAD for %s", kf.Expression(version))
+	}
+	err = copyUpstream(kf, version, commit, tmpDir, kptDir)
+	if err != nil {
+		return total, err
+	}
+	total.Stdout = ""
+	return total, nil
+}
+
+// copyUpstream copies the directory of the upstream package from the git checkout into the package directory
+// and writes its Kptfile
+func copyUpstream(kf *kptfiles.Kptfile, version, commit, checkoutDir, kptDir string) error {
+	directory := strings.Trim(filepath.ToSlash(kf.Directory), "/")
+	srcDir := filepath.Join(checkoutDir, filepath.FromSlash(directory))
+	exists, err := files.DirExists(srcDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", srcDir)
+	}
+	if !exists {
+		return errors.Errorf("the directory %s does not exist in %s at %s", kf.Directory, kf.Repo, version)
+	}
+	err = os.MkdirAll(kptDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", kptDir)
+	}
+	err = files.CopyDirOverwrite(srcDir, kptDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy %s to %s", srcDir, kptDir)
+	}
+	return writeUpstream(kf, version, commit, kptDir)
+}
+
+// runGit runs the git commands in the directory combining their durations into the total result which has the
+// output of the last command
+func runGit(ctx context.Context, runner common.ResultCommandRunner, dir string, total *common.CommandResult, commands ...[]string) error {
+	for _, args := range commands {
+		c := &cmdrunner.Command{
+			Name: "git",
+			Args: args,
+			Dir:  dir,
+		}
+		result, err := runner(ctx, c)
+		if result != nil {
+			total.Stdout = strings.TrimSpace(result.Stdout)
+			total.Stderr = result.Stderr
+			total.ExitCode = result.ExitCode
+			total.Duration += result.Duration
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUpstream writes the upstream of the fetched package into its Kptfile keeping any Kptfile of the upstream
+// package in the same way as 'kpt pkg get'
+func writeUpstream(upstream *kptfiles.Kptfile, version, commit, kptDir string) error {
+	path := filepath.Join(kptDir, kptfiles.FileName)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	kf := &kptfiles.Kptfile{APIVersion: kptfiles.APIVersionV1Alpha1}
+	if exists {
+		kf, err = kptfiles.Load(path)
+		if err != nil {
+			return err
+		}
+	}
+	kf.Name = filepath.Base(kptDir)
+	kf.Repo = upstream.Repo
+	kf.Directory = upstream.Directory
+	kf.Ref = version
+	if kf.IsV1() {
+		kf.Commit = ""
+		kf.Lock = &kptfiles.Lock{Repo: upstream.Repo, Directory: upstream.Directory, Ref: version, Commit: commit}
+	} else {
+		kf.Commit = commit
+	}
+	return kf.Save(path)
+}
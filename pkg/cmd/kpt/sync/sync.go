@@ -0,0 +1,355 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/recreate"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	kptLong = templates.LongDesc(`
+		Synchronises the upstream of the kpt packages in the given directory with the versions in a catalog file
+
+		The catalog file maps the logical package names (the metadata.name of the Kptfile) to the canonical git repository, directory and version so that a single catalog change can be propagated to every package.
+
+		The commit of the tag or branch of the catalog version is resolved via 'git ls-remote' so that the upstream lock records the SHA. If it cannot be resolved the commit is left blank for 'kpt pkg update' or 'kpt recreate' to fill in.
+
+		Alternatively the packages can be pinned to the commits in a lock file exported via 'kpt export-versions' using --apply-lock so that a known good set of package versions can be restored.
+`)
+
+	kptExample = templates.Examples(`
+		# updates all the Kptfiles in the current directory to the versions in the catalog
+		%s kpt sync --catalog .jx/gitops/kpt-catalog.yaml
+
+		# reports which packages are out of date without modifying anything
+		%s kpt sync --dry-run
+
+		# updates the Kptfiles then recreates the packages that changed
+		%s kpt sync --recreate
+
+		# pins the packages to the commits in a lock file then recreates the packages that changed
+		%s kpt sync --apply-lock kpt-lock.yaml --recreate
+	`)
+
+	info = termcolor.ColorInfo
+
+	commitSHA = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// Options the options for the command
+type Options struct {
+	common.WalkOptions
+	common.Verbosity
+	Dir           string
+	CatalogFile   string
+	LockFile      string
+	DryRun        bool
+	Recreate      bool
+	Catalog       *v1alpha1.KptCatalog
+	Lock          *v1alpha1.KptLock
+	UpToDate      []string
+	OutOfDate     []string
+	NotInCatalog  []string
+	CommandRunner cmdrunner.CommandRunner
+	commits       map[string]string
+}
+
+// NewCmdKptSync creates a command object for the command
+func NewCmdKptSync() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "sync",
+		Short:   "Synchronises the upstream of the kpt packages in the given directory with the versions in a catalog file",
+		Long:    kptLong,
+		Example: fmt.Sprintf(kptExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the Kptfile files")
+	cmd.Flags().StringVarP(&o.CatalogFile, "catalog", "c", "", "the catalog file of the canonical packages and versions. Defaults to .jx/gitops/"+v1alpha1.KptCatalogFileName+" in the directory")
+	cmd.Flags().StringVarP(&o.LockFile, "apply-lock", "", "", "pins the packages to the commits in the given lock file exported via 'kpt export-versions' instead of using the catalog")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "only report the out of date packages without modifying the Kptfiles")
+	cmd.Flags().BoolVarP(&o.Recreate, "recreate", "", false, "recreates the packages that were updated")
+	o.WalkOptions.AddFlags(cmd)
+	o.Verbosity.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Verbosity.Validate()
+	if err != nil {
+		return err
+	}
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	}
+	source := "catalog"
+	if o.LockFile != "" || o.Lock != nil {
+		if o.CatalogFile != "" {
+			return errors.Errorf("cannot specify both --catalog and --apply-lock")
+		}
+		source = "lock file"
+		if o.Lock == nil {
+			o.Lock, err = kptfiles.LoadLock(o.LockFile)
+			if err != nil {
+				return err
+			}
+		}
+	} else if o.Catalog == nil {
+		o.Catalog, err = o.loadCatalog()
+		if err != nil {
+			return err
+		}
+	}
+
+	// lets check kpt can be used before modifying any Kptfiles
+	kptBinary := ""
+	if o.Recreate && !o.DryRun && o.CommandRunner == nil {
+		kptBinary, _, err = common.ResolveBinary("kpt", common.WithVersionArgs("version"))
+		if err != nil {
+			return err
+		}
+	}
+
+	o.UpToDate = nil
+	o.OutOfDate = nil
+	o.NotInCatalog = nil
+	o.commits = map[string]string{}
+	walkOptions := o.WalkOptions
+	walkOptions.Patterns = []string{"Kptfile"}
+	walkOptions.OnSkip = func(path, rel string) {
+		o.Debugf("skipping %s", rel)
+	}
+	err = common.WalkFiles(dir, walkOptions, func(path, rel string, info os.FileInfo) error {
+		if o.Lock != nil {
+			return o.applyLock(path, filepath.Dir(rel))
+		}
+		return o.syncKptfile(path, filepath.Dir(rel))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to sync kpt packages in dir %s", dir)
+	}
+
+	log.Logger().Infof("kpt packages up to date: %d, out of date: %d, not in %s: %d", len(o.UpToDate), len(o.OutOfDate), source, len(o.NotInCatalog))
+
+	if o.DryRun || !o.Recreate || len(o.OutOfDate) == 0 {
+		return nil
+	}
+	_, ro := recreate.NewCmdKptRecreate()
+	ro.Dir = dir
+	ro.OutDir = dir
+	ro.KptBinary = kptBinary
+	ro.WalkOptions = o.WalkOptions
+	ro.Verbosity = o.Verbosity
+	ro.CommandRunner = o.CommandRunner
+	err = ro.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to recreate kpt packages in dir %s", dir)
+	}
+	return nil
+}
+
+func (o *Options) loadCatalog() (*v1alpha1.KptCatalog, error) {
+	path := o.CatalogFile
+	if path == "" {
+		path = filepath.Join(o.Dir, ".jx", "gitops", v1alpha1.KptCatalogFileName)
+	}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return nil, errors.Errorf("kpt catalog file %s does not exist", path)
+	}
+	catalog := &v1alpha1.KptCatalog{}
+	err = yamls.LoadFile(path, catalog)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load kpt catalog file %s", path)
+	}
+	err = catalog.Validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to validate kpt catalog file %s", path)
+	}
+	return catalog, nil
+}
+
+func (o *Options) applyLock(path string, rel string) error {
+	pkg := o.Lock.FindPackage(filepath.ToSlash(rel))
+	if pkg == nil {
+		log.Logger().Debugf("kpt package at %s is not in the lock file", rel)
+		o.NotInCatalog = append(o.NotInCatalog, rel)
+		return nil
+	}
+	kf, err := kptfiles.Load(path)
+	if err != nil {
+		return err
+	}
+	commit := kf.ResolvedCommit()
+	if sameRepo(kf.Repo, pkg.Repo) && sameDirectory(kf.Directory, pkg.Directory) && commit == pkg.Commit {
+		o.UpToDate = append(o.UpToDate, rel)
+		return nil
+	}
+	o.OutOfDate = append(o.OutOfDate, rel)
+
+	from := fmt.Sprintf("%s%s@%s", kf.Repo, kf.Directory, commit)
+	to := fmt.Sprintf("%s%s@%s", pkg.Repo, pkg.Directory, pkg.Commit)
+	if o.DryRun {
+		log.Logger().Infof("kpt package %s at %s differs from the lock file: %s => %s", info(kf.Name), info(rel), from, to)
+		return nil
+	}
+
+	kf.Repo = pkg.Repo
+	kf.Directory = pkg.Directory
+	if pkg.Ref != "" {
+		kf.Ref = pkg.Ref
+	}
+	if kf.IsV1() {
+		kf.Lock = &kptfiles.Lock{
+			Repo:      pkg.Repo,
+			Directory: pkg.Directory,
+			Ref:       kf.Ref,
+			Commit:    pkg.Commit,
+		}
+	} else {
+		kf.Commit = pkg.Commit
+	}
+	err = kf.Save(path)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("pinned kpt package %s at %s: %s => %s", info(kf.Name), info(rel), from, to)
+	return nil
+}
+
+func (o *Options) syncKptfile(path string, rel string) error {
+	kf, err := kptfiles.Load(path)
+	if err != nil {
+		return err
+	}
+	pkg := o.Catalog.FindPackage(kf.Name)
+	if pkg == nil {
+		log.Logger().Debugf("kpt package %s at %s is not in the catalog", kf.Name, rel)
+		o.NotInCatalog = append(o.NotInCatalog, rel)
+		return nil
+	}
+
+	// a package pinned to a tag or branch records the commit it resolved to so either can match the catalog version
+	version := kf.Version()
+	if sameRepo(kf.Repo, pkg.Repo) && sameDirectory(kf.Directory, pkg.Directory) && (kf.Ref == pkg.Version || version == pkg.Version) {
+		o.UpToDate = append(o.UpToDate, rel)
+		return nil
+	}
+	o.OutOfDate = append(o.OutOfDate, rel)
+
+	from := fmt.Sprintf("%s%s@%s", kf.Repo, kf.Directory, version)
+	to := fmt.Sprintf("%s%s@%s", pkg.Repo, pkg.Directory, pkg.Version)
+	if o.DryRun {
+		log.Logger().Infof("kpt package %s at %s is out of date: %s => %s", info(kf.Name), info(rel), from, to)
+		return nil
+	}
+
+	commit := o.resolveCommit(pkg.Repo, pkg.Version)
+	kf.Repo = pkg.Repo
+	kf.Directory = pkg.Directory
+	kf.Ref = pkg.Version
+	if kf.IsV1() {
+		kf.Lock = &kptfiles.Lock{
+			Repo:      pkg.Repo,
+			Directory: pkg.Directory,
+			Ref:       pkg.Version,
+			Commit:    commit,
+		}
+	} else {
+		kf.Commit = commit
+	}
+	err = kf.Save(path)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("updated kpt package %s at %s: %s => %s", info(kf.Name), info(rel), from, to)
+	return nil
+}
+
+// resolveCommit returns the commit SHA of the tag or branch of the repository using 'git ls-remote' preferring the
+// commit an annotated tag points to. Returns blank if the commit cannot be resolved so that the lock is left for
+// kpt to fill in
+func (o *Options) resolveCommit(repo, ref string) string {
+	if commitSHA.MatchString(ref) {
+		return ref
+	}
+	key := repo + "@" + ref
+	if commit, ok := o.commits[key]; ok {
+		return commit
+	}
+	runner := o.CommandRunner
+	if runner == nil {
+		runner = cmdrunner.QuietCommandRunner
+	}
+	c := &cmdrunner.Command{
+		Name: "git",
+		Args: []string{"ls-remote", kptfiles.NormalizeRepo(repo), ref, ref + "^{}"},
+	}
+	text, err := runner(c)
+	if err != nil {
+		log.Logger().Warnf("failed to resolve the commit of %s in %s so leaving it blank: %s", info(ref), repo, err.Error())
+		o.commits[key] = ""
+		return ""
+	}
+	commit := ""
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !commitSHA.MatchString(fields[0]) {
+			continue
+		}
+		if strings.HasSuffix(fields[1], "^{}") {
+			commit = fields[0]
+			break
+		}
+		if commit == "" {
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		log.Logger().Warnf("could not find %s in %s so leaving the commit blank", info(ref), repo)
+	}
+	o.commits[key] = commit
+	return commit
+}
+
+func sameRepo(r1, r2 string) bool {
+	u1, err1 := common.ParseGitURL(r1)
+	u2, err2 := common.ParseGitURL(r2)
+	if err1 != nil || err2 != nil {
+		return r1 == r2
+	}
+	return u1.SameRepository(u2)
+}
+
+func sameDirectory(d1, d2 string) bool {
+	return strings.Trim(d1, "/") == strings.Trim(d2, "/")
+}
@@ -0,0 +1,304 @@
+package sync_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kpt/sync"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// lighthouseCommit the commit of the v1.2.3 tag of the lighthouse package
+	lighthouseCommit = "0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c"
+
+	// app2TagObject the object of the annotated v1.3.0 tag of the app2 package
+	app2TagObject = "1111111111111111111111111111111111111111"
+
+	// app2Commit the commit the annotated v1.3.0 tag of the app2 package points to
+	app2Commit = "2222222222222222222222222222222222222222"
+)
+
+// expectLsRemote returns the expectation of the 'git ls-remote' command resolving the ref of the repository
+func expectLsRemote(repo, ref, output string) testhelpers.Expectation {
+	return testhelpers.Expectation{
+		Name:   "git",
+		Args:   []string{"ls-remote", repo, ref, ref + "^{}"},
+		Output: output,
+	}
+}
+
+func TestKptSync(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		expectLsRemote("https://github.com/jenkins-x/jxr-kube-resources.git", "v1.2.3", lighthouseCommit+"\trefs/tags/v1.2.3"),
+	)
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+	o.CommandRunner = runner.Run
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run kpt sync")
+	runner.Verify(t)
+
+	assert.Equal(t, []string{filepath.Join("config-root", "namespaces", "jx", "lighthouse")}, o.OutOfDate, "out of date packages")
+
+	// the repo and directory only differ by a .git suffix and leading slash
+	assert.Equal(t, []string{filepath.Join("config-root", "namespaces", "jx", "app2")}, o.UpToDate, "up to date packages")
+	assert.Equal(t, []string{filepath.Join("config-root", "namespaces", "jx", "other")}, o.NotInCatalog, "packages not in the catalog")
+
+	path := filepath.Join(tmpDir, "config-root", "namespaces", "jx", "lighthouse", "Kptfile")
+	node, err := yaml.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, lighthouseCommit, getField(t, node, "commit"), "the commit of the tag should be resolved for %s", path)
+	assert.Equal(t, "v1.2.3", getField(t, node, "ref"), "ref for %s", path)
+	assert.Equal(t, "https://github.com/jenkins-x/jxr-kube-resources", getField(t, node, "repo"), "repo for %s", path)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to read %s", path)
+	assert.Contains(t, string(data), "# the old version", "should have preserved comments in %s", path)
+	assert.Equal(t, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: lighthouse
+upstream:
+  type: git
+  git:
+    # the old version
+    commit: 0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c
+    repo: https://github.com/jenkins-x/jxr-kube-resources
+    directory: /jenkins-x/lighthouse
+    ref: v1.2.3
+`, string(data), "only the changed values should be modified in %s", path)
+
+	otherPath := filepath.Join(tmpDir, "config-root", "namespaces", "jx", "other", "Kptfile")
+	assert.FileExists(t, otherPath)
+	node, err = yaml.ReadFile(otherPath)
+	require.NoError(t, err, "failed to load %s", otherPath)
+	assert.Equal(t, "abc1234", getField(t, node, "commit"), "commit for %s", otherPath)
+
+	// running again should find everything up to date
+	err = o.Run()
+	require.NoError(t, err, "failed to run kpt sync again")
+	assert.Empty(t, o.OutOfDate, "out of date packages after sync")
+	assert.Len(t, o.UpToDate, 2, "up to date packages after sync")
+	runner.Verify(t)
+}
+
+func TestKptSyncDryRun(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	path := filepath.Join(tmpDir, "config-root", "namespaces", "jx", "lighthouse", "Kptfile")
+	before, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to read %s", path)
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+	o.DryRun = true
+	o.Recreate = true
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run kpt sync")
+
+	assert.Len(t, o.OutOfDate, 1, "out of date packages")
+
+	after, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to read %s", path)
+	assert.Equal(t, string(before), string(after), "should not have modified %s", path)
+}
+
+func TestKptSyncV1AndResolvedTags(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	sources := map[string]string{
+		"tagged": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: tagged
+upstream:
+  type: git
+  git:
+    commit: 4cc6b80d49808060b1f06f530399b986ed344f23
+    repo: https://github.com/another/tagged.git
+    directory: /tagged
+    ref: v1.2.3
+`,
+		"app2": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app2
+upstream:
+  type: git
+  git:
+    repo: https://github.com/another/thing.git
+    directory: /kubernetes/app2
+    ref: v1.2.3
+  updateStrategy: resource-merge
+upstreamLock:
+  type: git
+  git:
+    repo: https://github.com/another/thing.git
+    directory: /kubernetes/app2
+    ref: v1.2.3
+    commit: 9f8e7d6c5b4a3f2e1d0c
+`,
+	}
+	for name, text := range sources {
+		dir := filepath.Join(tmpDir, name)
+		err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+		require.NoError(t, err, "failed to create dir %s", dir)
+		err = ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(text), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save Kptfile in %s", dir)
+	}
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		expectLsRemote("https://github.com/another/thing.git", "v1.3.0", app2TagObject+"\trefs/tags/v1.3.0\n"+app2Commit+"\trefs/tags/v1.3.0^{}\n"),
+	)
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+	o.CommandRunner = runner.Run
+	o.Catalog = &v1alpha1.KptCatalog{
+		Spec: v1alpha1.KptCatalogSpec{
+			Packages: []v1alpha1.KptCatalogPackage{
+				{
+					Name:      "tagged",
+					Repo:      "https://github.com/another/tagged.git",
+					Directory: "/tagged",
+					Version:   "v1.2.3",
+				},
+				{
+					Name:      "app2",
+					Repo:      "https://github.com/another/thing.git",
+					Directory: "/kubernetes/app2",
+					Version:   "v1.3.0",
+				},
+			},
+		},
+	}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run kpt sync")
+
+	// the tagged package records the commit the tag resolved to
+	assert.Equal(t, []string{"tagged"}, o.UpToDate, "up to date packages")
+	assert.Equal(t, []string{"app2"}, o.OutOfDate, "out of date packages")
+
+	path := filepath.Join(tmpDir, "app2", "Kptfile")
+	node, err := yaml.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, "v1.3.0", getField(t, node, "ref"), "ref for %s", path)
+
+	commit, err := node.Pipe(yaml.Lookup("upstream", "git", "commit"))
+	require.NoError(t, err, "failed to lookup upstream.git.commit")
+	assert.Nil(t, commit, "should not have added upstream.git.commit to %s", path)
+
+	// the lock should record the commit the annotated tag points to rather than the tag
+	expectedLock := map[string]string{"ref": "v1.3.0", "commit": app2Commit}
+	for field, expected := range expectedLock {
+		value, err := node.Pipe(yaml.Lookup("upstreamLock", "git", field))
+		require.NoError(t, err, "failed to lookup upstreamLock.git.%s", field)
+		require.NotNil(t, value, "missing field upstreamLock.git.%s in %s", field, path)
+		assert.Equal(t, expected, yaml.GetValue(value), "upstreamLock.git.%s for %s", field, path)
+	}
+	runner.Verify(t)
+
+	// running again should find everything up to date
+	err = o.Run()
+	require.NoError(t, err, "failed to run kpt sync again")
+	assert.Empty(t, o.OutOfDate, "out of date packages after sync")
+	assert.Len(t, o.UpToDate, 2, "up to date packages after sync")
+}
+
+func TestKptSyncRecreate(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		expectLsRemote("https://github.com/jenkins-x/jxr-kube-resources.git", "v1.2.3", lighthouseCommit+"\trefs/tags/v1.2.3"),
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@" + lighthouseCommit, filepath.Join("config-root", "namespaces", "jx")},
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/another/thing.git/kubernetes/app2@4cc6b80d49808060b1f06f530399b986ed344f23", filepath.Join("config-root", "namespaces", "jx")},
+		},
+		testhelpers.Expectation{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/another/other.git/other@abc1234", filepath.Join("config-root", "namespaces", "jx")},
+		},
+	)
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+	o.Recreate = true
+	o.CommandRunner = runner.Run
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run kpt sync")
+
+	runner.Verify(t)
+}
+
+func TestKptSyncMissingCatalog(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+
+	err = o.Run()
+	require.Error(t, err, "should have failed without a catalog")
+}
+
+func TestKptSyncUnresolvedCommit(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		expectLsRemote("https://github.com/jenkins-x/jxr-kube-resources.git", "v1.2.3", ""),
+	)
+
+	_, o := sync.NewCmdKptSync()
+	o.Dir = tmpDir
+	o.CommandRunner = runner.Run
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run kpt sync")
+	runner.Verify(t)
+
+	// the commit should be left for kpt to fill in rather than recording the tag
+	path := filepath.Join(tmpDir, "config-root", "namespaces", "jx", "lighthouse", "Kptfile")
+	node, err := yaml.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, "v1.2.3", getField(t, node, "ref"), "ref for %s", path)
+	commit, err := node.Pipe(yaml.Lookup("upstream", "git", "commit"))
+	require.NoError(t, err, "failed to lookup upstream.git.commit")
+	assert.Nil(t, commit, "should have removed the old upstream.git.commit from %s", path)
+}
+
+func getField(t *testing.T, node *yaml.RNode, field string) string {
+	value, err := node.Pipe(yaml.Lookup("upstream", "git", field))
+	require.NoError(t, err, "failed to lookup %s", field)
+	require.NotNil(t, value, "missing field %s", field)
+	return yaml.GetValue(value)
+}
+
+func TestKptSyncCatalogAndLock(t *testing.T) {
+	_, o := sync.NewCmdKptSync()
+	o.Dir = "test_data"
+	o.CatalogFile = "test_data/.jx/gitops/kpt-catalog.yaml"
+	o.LockFile = "kpt-lock.yaml"
+
+	err := o.Run()
+	require.Error(t, err, "should not be able to specify both a catalog and a lock file")
+}
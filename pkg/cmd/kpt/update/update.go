@@ -14,6 +14,7 @@ import (
 	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 
 	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
@@ -106,6 +107,13 @@ func (o *Options) Run() error {
 		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
 	}
 
+	// lets check an explicit kpt binary can be used before modifying anything
+	if o.KptBinary != "" && o.CommandRunner == nil {
+		o.KptBinary, _, err = common.ResolveBinary("kpt", common.WithOverride("bin", o.KptBinary), common.WithVersionArgs("version"))
+		if err != nil {
+			return err
+		}
+	}
 	if o.CommandRunner == nil {
 		o.CommandRunner = cmdrunner.DefaultCommandRunner
 	}
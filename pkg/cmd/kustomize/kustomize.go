@@ -9,8 +9,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kustomize/overlay"
 	"github.com/jenkins-x/jx-gitops/pkg/kustomizes"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
@@ -67,6 +69,7 @@ func NewCmdKustomize() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.SourceDir, "source", "s", ".", "the directory to recursively look for the source *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.TargetDir, "target", "t", "", "the directory to recursively look for the target *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.OutputDir, "output", "o", "", "the output directory to store the overlays")
+	cmd.AddCommand(cobras.SplitCommand(overlay.NewCmdOverlay()))
 	return cmd, o
 }
 
@@ -0,0 +1,420 @@
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/kustomizes"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/resid"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Generates a kustomize overlay from a base directory and a hand edited copy of it for an environment
+
+		The resources of the two directory trees are matched by their kind, namespace and name. The differences of each resource are written as a strategic merge patch. Elements of the lists of kubernetes resources with a merge key, such as containers or environment variables, are patched individually. If a list without a merge key differs a JSON 6902 patch is written instead unless --patch-type is specified.
+
+		Resources which are only in the environment directory are copied into the overlay as additional resources. Resources which are only in the base directory are deleted with a '$patch: delete' patch.
+
+		A kustomization.yaml file referencing the base and the patches is written to the output directory. If the base directory has no kustomization.yaml file one is created listing its resources.
+`)
+
+	cmdExample = templates.Examples(`
+		# generates the overlay of the staging environment
+		%s kustomize overlay --base base --env envs/staging --out overlays/staging
+
+		# generates the overlay using JSON 6902 patches
+		%s kustomize overlay --base base --env envs/production --out overlays/production --patch-type json6902
+	`)
+)
+
+const (
+	// patchesDir the directory of the patches in the output directory
+	patchesDir = "patches"
+
+	// resourcesDir the directory of the additional resources in the output directory
+	resourcesDir = "resources"
+
+	// kustomizationFile the name of the kustomize file
+	kustomizationFile = "kustomization.yaml"
+)
+
+// Patch a patch of a resource in the overlay
+type Patch struct {
+	// Resource the key of the resource
+	Resource string
+
+	// Type the patch type
+	Type string
+
+	// Path the path of the patch file relative to the output directory
+	Path string
+}
+
+// Options the options for the command
+type Options struct {
+	common.WalkOptions
+	BaseDir       string
+	EnvDir        string
+	OutDir        string
+	PatchType     string
+	Patches       []Patch
+	Added         []string
+	Removed       []string
+	Kustomization *types.Kustomization
+}
+
+// NewCmdOverlay creates a command object for the command
+func NewCmdOverlay() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "overlay",
+		Short:   "Generates a kustomize overlay from a base directory and a hand edited copy of it for an environment",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.BaseDir, "base", "b", "", "the base directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.EnvDir, "env", "e", "", "the directory of the hand edited copy of the base for the environment")
+	cmd.Flags().StringVarP(&o.OutDir, "out", "o", "", "the output directory of the overlay. Any patches and resources directories in it are replaced")
+	cmd.Flags().StringVarP(&o.PatchType, "patch-type", "", PatchTypeAuto, fmt.Sprintf("the type of the patches. Values: %s", strings.Join(PatchTypes, ", ")))
+	o.WalkOptions.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.BaseDir == "" {
+		return options.MissingOption("base")
+	}
+	if o.EnvDir == "" {
+		return options.MissingOption("env")
+	}
+	if o.OutDir == "" {
+		return options.MissingOption("out")
+	}
+	if o.PatchType == "" {
+		o.PatchType = PatchTypeAuto
+	}
+	if stringhelpers.StringArrayIndex(PatchTypes, o.PatchType) < 0 {
+		return options.InvalidOption("patch-type", o.PatchType, PatchTypes)
+	}
+	for _, dir := range []string{o.BaseDir, o.EnvDir} {
+		if filepath.Clean(dir) == filepath.Clean(o.OutDir) {
+			return errors.Errorf("the output directory %s must not be the base or environment directory", o.OutDir)
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	baseResources, err := o.loadResources(o.BaseDir)
+	if err != nil {
+		return err
+	}
+	envResources, err := o.loadResources(o.EnvDir)
+	if err != nil {
+		return err
+	}
+	err = o.lazyCreateBaseKustomization()
+	if err != nil {
+		return err
+	}
+
+	// lets remove any previously generated files
+	for _, name := range []string{patchesDir, resourcesDir} {
+		err = os.RemoveAll(filepath.Join(o.OutDir, name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove the %s directory of %s", name, o.OutDir)
+		}
+	}
+	relBase, err := filepath.Rel(o.OutDir, o.BaseDir)
+	if err != nil {
+		relBase, err = filepath.Abs(o.BaseDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find absolute path of %s", o.BaseDir)
+		}
+	}
+	o.Kustomization = kustomizes.LazyCreate(nil)
+	o.Kustomization.Resources = []string{filepath.ToSlash(relBase)}
+	o.Patches = nil
+	o.Added = nil
+	o.Removed = nil
+
+	for _, key := range sortedResourceKeys(envResources) {
+		target := envResources[key]
+		base := baseResources[key]
+		if base == nil {
+			err = o.addResource(key, target)
+		} else {
+			err = o.addPatch(key, base, target)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate the overlay of %s", key)
+		}
+	}
+	for _, key := range sortedResourceKeys(baseResources) {
+		if envResources[key] != nil {
+			continue
+		}
+		err = o.addDeletePatch(key, baseResources[key])
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate the delete patch of %s", key)
+		}
+	}
+
+	err = os.MkdirAll(o.OutDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", o.OutDir)
+	}
+	err = kustomizes.SaveKustomization(o.Kustomization, o.OutDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range o.Patches {
+		log.Logger().Infof("patched %s with the %s patch %s", p.Resource, p.Type, termcolor.ColorInfo(p.Path))
+	}
+	for _, key := range o.Added {
+		log.Logger().Infof("added %s which is only in the environment directory", termcolor.ColorInfo(key))
+	}
+	for _, key := range o.Removed {
+		log.Logger().Infof("deleted %s which is only in the base directory", termcolor.ColorInfo(key))
+	}
+	log.Logger().Infof("created the kustomize overlay at %s with %d patches, %d added and %d deleted resources", termcolor.ColorInfo(o.OutDir), len(o.Patches), len(o.Added), len(o.Removed))
+	return nil
+}
+
+func (o *Options) addPatch(key string, base, target *unstructured.Unstructured) error {
+	patchType := o.PatchType
+	var smp map[string]interface{}
+	if patchType != PatchTypeJSON6902 {
+		var ok bool
+		smp, ok = StrategicMergePatch(base.Object, target.Object, patchType == PatchTypeStrategicMerge)
+		if len(smp) == 0 {
+			return nil
+		}
+		if !ok {
+			patchType = PatchTypeJSON6902
+		}
+	}
+
+	name := fileName(target)
+	if patchType == PatchTypeJSON6902 {
+		ops := JSON6902Patch(base.Object, target.Object)
+		if len(ops) == 0 {
+			return nil
+		}
+		data, err := yaml.Marshal(ops)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the JSON 6902 patch")
+		}
+		rel := patchesDir + "/" + name + "-json6902.yaml"
+		err = o.writeFile(rel, data)
+		if err != nil {
+			return err
+		}
+		group, version := splitAPIVersion(target.GetAPIVersion())
+		o.Kustomization.PatchesJson6902 = append(o.Kustomization.PatchesJson6902, types.PatchJson6902{
+			Target: &types.PatchTarget{
+				Gvk:       resid.Gvk{Group: group, Version: version, Kind: target.GetKind()},
+				Namespace: target.GetNamespace(),
+				Name:      target.GetName(),
+			},
+			Path: rel,
+		})
+		o.Patches = append(o.Patches, Patch{Resource: key, Type: PatchTypeJSON6902, Path: rel})
+		return nil
+	}
+
+	smp["apiVersion"] = target.GetAPIVersion()
+	smp["kind"] = target.GetKind()
+	metadata, _ := smp["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		smp["metadata"] = metadata
+	}
+	metadata["name"] = target.GetName()
+	if target.GetNamespace() != "" {
+		metadata["namespace"] = target.GetNamespace()
+	}
+	rel := patchesDir + "/" + name + ".yaml"
+	err := o.writeResource(rel, smp)
+	if err != nil {
+		return err
+	}
+	o.Kustomization.PatchesStrategicMerge = append(o.Kustomization.PatchesStrategicMerge, types.PatchStrategicMerge(rel))
+	o.Patches = append(o.Patches, Patch{Resource: key, Type: PatchTypeStrategicMerge, Path: rel})
+	return nil
+}
+
+func (o *Options) addResource(key string, u *unstructured.Unstructured) error {
+	rel := resourcesDir + "/" + fileName(u) + ".yaml"
+	err := o.writeResource(rel, u.Object)
+	if err != nil {
+		return err
+	}
+	o.Kustomization.Resources = append(o.Kustomization.Resources, rel)
+	o.Added = append(o.Added, key)
+	return nil
+}
+
+func (o *Options) addDeletePatch(key string, u *unstructured.Unstructured) error {
+	metadata := map[string]interface{}{
+		"name": u.GetName(),
+	}
+	if u.GetNamespace() != "" {
+		metadata["namespace"] = u.GetNamespace()
+	}
+	patch := map[string]interface{}{
+		"apiVersion": u.GetAPIVersion(),
+		"kind":       u.GetKind(),
+		"metadata":   metadata,
+		"$patch":     "delete",
+	}
+	rel := patchesDir + "/" + fileName(u) + "-delete.yaml"
+	err := o.writeResource(rel, patch)
+	if err != nil {
+		return err
+	}
+	o.Kustomization.PatchesStrategicMerge = append(o.Kustomization.PatchesStrategicMerge, types.PatchStrategicMerge(rel))
+	o.Removed = append(o.Removed, key)
+	return nil
+}
+
+func (o *Options) writeResource(rel string, obj map[string]interface{}) error {
+	data, err := resourcehelpers.ToYAML([]*unstructured.Unstructured{{Object: obj}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s", rel)
+	}
+	return o.writeFile(rel, data)
+}
+
+func (o *Options) writeFile(rel string, data []byte) error {
+	path := filepath.Join(o.OutDir, filepath.FromSlash(rel))
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// lazyCreateBaseKustomization creates the kustomization.yaml file of the base directory listing its resource
+// files if it does not exist
+func (o *Options) lazyCreateBaseKustomization() error {
+	path := filepath.Join(o.BaseDir, kustomizationFile)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if exists {
+		return nil
+	}
+	k := kustomizes.LazyCreate(nil)
+	err = resourcehelpers.WalkYAMLFiles(o.BaseDir, o.WalkOptions, func(path, rel string, _ os.FileInfo) error {
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, u := range resources {
+			if resourcehelpers.IsResource(u) && !isKustomization(u) {
+				k.Resources = append(k.Resources, filepath.ToSlash(rel))
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the resources in dir %s", o.BaseDir)
+	}
+	err = kustomizes.SaveKustomization(k, o.BaseDir)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("created %s listing the %d resource files of the base", termcolor.ColorInfo(path), len(k.Resources))
+	return nil
+}
+
+// loadResources loads the resources in the directory tree indexed by their key
+func (o *Options) loadResources(dir string) (map[string]*unstructured.Unstructured, error) {
+	answer := map[string]*unstructured.Unstructured{}
+	paths := map[string]string{}
+	visitFn := func(u *unstructured.Unstructured, path string) error {
+		if isKustomization(u) {
+			return nil
+		}
+		key := resourcehelpers.ResourceKey(u)
+		if paths[key] != "" {
+			return errors.Errorf("duplicate resource %s in files %s and %s", key, paths[key], path)
+		}
+		answer[key] = u
+		paths[key] = path
+		return nil
+	}
+	err := resourcehelpers.VisitFiles(dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, visitFn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the resources in dir %s", dir)
+	}
+	return answer, nil
+}
+
+func isKustomization(u *unstructured.Unstructured) bool {
+	return u.GetKind() == "Kustomization" && strings.HasPrefix(u.GetAPIVersion(), "kustomize.config.k8s.io/")
+}
+
+// fileName returns the name of the file of the resource without an extension
+func fileName(u *unstructured.Unstructured) string {
+	name := u.GetKind() + "-" + u.GetName()
+	if u.GetNamespace() != "" {
+		name = u.GetNamespace() + "-" + name
+	}
+	return strings.ToLower(name)
+}
+
+func splitAPIVersion(apiVersion string) (string, string) {
+	idx := strings.LastIndex(apiVersion, "/")
+	if idx < 0 {
+		return "", apiVersion
+	}
+	return apiVersion[:idx], apiVersion[idx+1:]
+}
+
+func sortedResourceKeys(m map[string]*unstructured.Unstructured) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+package overlay_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kustomize/overlay"
+	gitopstesthelpers "github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/kustomizes"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+func TestOverlay(t *testing.T) {
+	tmpDir := gitopstesthelpers.CopyTestData(t, "test_data")
+
+	_, o := overlay.NewCmdOverlay()
+	o.BaseDir = filepath.Join(tmpDir, "base")
+	o.EnvDir = filepath.Join(tmpDir, "env")
+	o.OutDir = filepath.Join(tmpDir, "overlays", "staging")
+	err := o.Run()
+	require.NoError(t, err, "failed to generate the overlay")
+
+	assert.Equal(t, []overlay.Patch{
+		{Resource: "jx/Deployment/web", Type: overlay.PatchTypeStrategicMerge, Path: "patches/jx-deployment-web.yaml"},
+		{Resource: "jx/Pipeline/release", Type: overlay.PatchTypeJSON6902, Path: "patches/jx-pipeline-release-json6902.yaml"},
+	}, o.Patches, "patches")
+	assert.Equal(t, []string{"jx/ConfigMap/staging-config"}, o.Added, "added resources")
+	assert.Equal(t, []string{"jx/ConfigMap/old-config"}, o.Removed, "removed resources")
+	assert.FileExists(t, filepath.Join(o.BaseDir, "kustomization.yaml"), "the kustomization of the base should have been created")
+
+	k, err := kustomizes.LoadKustomization(o.OutDir)
+	require.NoError(t, err, "failed to load the kustomization of the overlay")
+	require.NotNil(t, k, "no kustomization in the overlay")
+	assert.Equal(t, []string{"../../base", "resources/jx-configmap-staging-config.yaml"}, k.Resources, "kustomization resources")
+	assert.Equal(t, []types.PatchStrategicMerge{"patches/jx-deployment-web.yaml", "patches/jx-configmap-old-config-delete.yaml"}, k.PatchesStrategicMerge, "kustomization patchesStrategicMerge")
+	require.Len(t, k.PatchesJson6902, 1, "kustomization patchesJson6902")
+	assert.Equal(t, "patches/jx-pipeline-release-json6902.yaml", k.PatchesJson6902[0].Path, "patchesJson6902 path")
+	require.NotNil(t, k.PatchesJson6902[0].Target, "patchesJson6902 target")
+	assert.Equal(t, "tekton.dev", k.PatchesJson6902[0].Target.Group, "patchesJson6902 target group")
+	assert.Equal(t, "v1beta1", k.PatchesJson6902[0].Target.Version, "patchesJson6902 target version")
+	assert.Equal(t, "Pipeline", k.PatchesJson6902[0].Target.Kind, "patchesJson6902 target kind")
+	assert.Equal(t, "jx", k.PatchesJson6902[0].Target.Namespace, "patchesJson6902 target namespace")
+	assert.Equal(t, "release", k.PatchesJson6902[0].Target.Name, "patchesJson6902 target name")
+
+	expectedDir := filepath.Join("test_data", "expected")
+	if generateTestOutput {
+		require.NoError(t, os.RemoveAll(expectedDir), "failed to remove %s", expectedDir)
+		require.NoError(t, files.CopyDirOverwrite(o.OutDir, expectedDir), "failed to copy %s to %s", o.OutDir, expectedDir)
+	}
+	for _, rel := range []string{
+		"patches/jx-configmap-old-config-delete.yaml",
+		"patches/jx-deployment-web.yaml",
+		"patches/jx-pipeline-release-json6902.yaml",
+		"resources/jx-configmap-staging-config.yaml",
+	} {
+		testhelpers.AssertTextFilesEqual(t, filepath.Join(expectedDir, rel), filepath.Join(o.OutDir, rel), rel)
+	}
+
+	assertOverlayBuildsEnv(t, o)
+}
+
+func TestOverlayPatchTypes(t *testing.T) {
+	for _, patchType := range []string{overlay.PatchTypeStrategicMerge, overlay.PatchTypeJSON6902} {
+		tmpDir := gitopstesthelpers.CopyTestData(t, "test_data")
+
+		_, o := overlay.NewCmdOverlay()
+		o.BaseDir = filepath.Join(tmpDir, "base")
+		o.EnvDir = filepath.Join(tmpDir, "env")
+		o.OutDir = filepath.Join(tmpDir, "overlay")
+		o.PatchType = patchType
+		err := o.Run()
+		require.NoError(t, err, "failed to generate the overlay with %s patches", patchType)
+		require.Len(t, o.Patches, 2, "patches for %s", patchType)
+		for _, p := range o.Patches {
+			assert.Equal(t, patchType, p.Type, "patch type of %s", p.Resource)
+		}
+		assertOverlayBuildsEnv(t, o)
+
+		// lets check regenerating the overlay replaces the old patches
+		err = o.Run()
+		require.NoError(t, err, "failed to regenerate the overlay with %s patches", patchType)
+		patches, err := ioutil.ReadDir(filepath.Join(o.OutDir, "patches"))
+		require.NoError(t, err, "failed to read the patches dir")
+		assert.Len(t, patches, 3, "patch files for %s", patchType)
+	}
+
+	_, o := overlay.NewCmdOverlay()
+	o.BaseDir = "base"
+	o.EnvDir = "env"
+	o.OutDir = "env"
+	err := o.Run()
+	require.Error(t, err, "should fail if the output dir is the env dir")
+}
+
+// assertOverlayBuildsEnv asserts that building the overlay with kustomize results in the resources of the env dir
+func assertOverlayBuildsEnv(t *testing.T, o *overlay.Options) {
+	k := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions())
+	m, err := k.Run(o.OutDir)
+	require.NoError(t, err, "failed to build the overlay %s", o.OutDir)
+	data, err := m.AsYaml()
+	require.NoError(t, err, "failed to marshal the built resources")
+	built, err := resourcehelpers.ParseDocuments(data)
+	require.NoError(t, err, "failed to parse the built resources")
+
+	expected := map[string]interface{}{}
+	err = resourcehelpers.VisitFiles(o.EnvDir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+		expected[resourcehelpers.ResourceKey(u)] = u.Object
+		return nil
+	})
+	require.NoError(t, err, "failed to load the env resources")
+
+	actual := map[string]interface{}{}
+	var keys []string
+	for _, u := range built {
+		key := resourcehelpers.ResourceKey(u)
+		actual[key] = u.Object
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	t.Logf("built resources %v", keys)
+	assert.Equal(t, expected, actual, "the built overlay should match the env dir for %s patches", o.PatchType)
+}
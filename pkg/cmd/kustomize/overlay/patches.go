@@ -0,0 +1,258 @@
+package overlay
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// PatchTypeAuto uses strategic merge patches unless a list without a merge key differs
+	PatchTypeAuto = "auto"
+
+	// PatchTypeStrategicMerge always uses strategic merge patches replacing any lists without a merge key
+	PatchTypeStrategicMerge = "strategic-merge"
+
+	// PatchTypeJSON6902 always uses JSON 6902 patches
+	PatchTypeJSON6902 = "json6902"
+)
+
+var (
+	// PatchTypes the supported patch types
+	PatchTypes = []string{PatchTypeAuto, PatchTypeStrategicMerge, PatchTypeJSON6902}
+
+	// mergeKeys the strategic merge keys of the lists of the kubernetes resources indexed by the field name
+	mergeKeys = map[string]string{
+		"containers":          "name",
+		"ephemeralContainers": "name",
+		"env":                 "name",
+		"hostAliases":         "ip",
+		"imagePullSecrets":    "name",
+		"initContainers":      "name",
+		"ports":               "containerPort",
+		"volumeMounts":        "mountPath",
+		"volumes":             "name",
+	}
+)
+
+// Operation a JSON 6902 patch operation
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// IsBuiltin returns true if the apiVersion is of a built in kubernetes API group whose lists can have merge keys.
+// The lists of custom resources are always replaced
+func IsBuiltin(apiVersion string) bool {
+	idx := strings.Index(apiVersion, "/")
+	if idx < 0 {
+		return true
+	}
+	group := apiVersion[:idx]
+	return !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
+
+// MergeKey returns the strategic merge key of the list field of the built in kind or blank if the list is replaced
+func MergeKey(kind string, path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	field := path[len(path)-1]
+	if field == "ports" && kind == "Service" {
+		return "port"
+	}
+	return mergeKeys[field]
+}
+
+// StrategicMergePatch returns the strategic merge patch fragment which converts the base object into the target
+// object. Fields removed from the target are set to null and elements of lists with a merge key which are removed
+// are deleted with the '$patch: delete' directive. Returns false if a list without a merge key differs and
+// replaceLists is false as the whole list would have to be replaced
+func StrategicMergePatch(base, target map[string]interface{}, replaceLists bool) (map[string]interface{}, bool) {
+	apiVersion, _ := target["apiVersion"].(string)
+	kind, _ := target["kind"].(string)
+	d := &smpDiffer{kind: kind, builtin: IsBuiltin(apiVersion), replaceLists: replaceLists, ok: true}
+	patch, _ := d.diffMaps(nil, base, target)
+	return patch, d.ok
+}
+
+type smpDiffer struct {
+	kind         string
+	builtin      bool
+	replaceLists bool
+	ok           bool
+}
+
+// diffMaps returns the patch of the maps and true if they differ
+func (d *smpDiffer) diffMaps(path []string, base, target map[string]interface{}) (map[string]interface{}, bool) {
+	patch := map[string]interface{}{}
+	for _, k := range sortedKeys(target) {
+		tv := target[k]
+		bv, ok := base[k]
+		if !ok {
+			patch[k] = tv
+			continue
+		}
+		pv, changed := d.diff(append(path, k), bv, tv)
+		if changed {
+			patch[k] = pv
+		}
+	}
+	for _, k := range sortedKeys(base) {
+		if _, ok := target[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch, len(patch) > 0
+}
+
+func (d *smpDiffer) diff(path []string, base, target interface{}) (interface{}, bool) {
+	if reflect.DeepEqual(base, target) {
+		return nil, false
+	}
+	switch t := target.(type) {
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			return d.diffMaps(path, b, t)
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			return d.diffLists(path, b, t)
+		}
+	}
+	return target, true
+}
+
+func (d *smpDiffer) diffLists(path []string, base, target []interface{}) (interface{}, bool) {
+	key := ""
+	if d.builtin {
+		key = MergeKey(d.kind, path)
+	}
+	if key == "" || !hasMergeKey(base, key) || !hasMergeKey(target, key) {
+		if !d.replaceLists {
+			d.ok = false
+		}
+		return target, true
+	}
+	var patch []interface{}
+	for _, t := range target {
+		tm := t.(map[string]interface{})
+		bm := findElement(base, key, tm[key])
+		if bm == nil {
+			patch = append(patch, tm)
+			continue
+		}
+		pm, changed := d.diffMaps(path, bm, tm)
+		if changed {
+			pm[key] = tm[key]
+			patch = append(patch, pm)
+		}
+	}
+	for _, b := range base {
+		bm := b.(map[string]interface{})
+		if findElement(target, key, bm[key]) == nil {
+			patch = append(patch, map[string]interface{}{key: bm[key], "$patch": "delete"})
+		}
+	}
+	if len(patch) == 0 {
+		// lets ignore elements which have only been reordered
+		return nil, false
+	}
+	return patch, true
+}
+
+// JSON6902Patch returns the JSON 6902 operations which convert the base object into the target object
+func JSON6902Patch(base, target map[string]interface{}) []Operation {
+	var ops []Operation
+	diffJSON("", base, target, &ops)
+	return ops
+}
+
+func diffJSON(pointer string, base, target interface{}, ops *[]Operation) {
+	if reflect.DeepEqual(base, target) {
+		return
+	}
+	switch t := target.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(b) {
+			if _, ok := t[k]; !ok {
+				*ops = append(*ops, Operation{Op: "remove", Path: pointer + "/" + escapePointer(k)})
+			}
+		}
+		for _, k := range sortedKeys(t) {
+			child := pointer + "/" + escapePointer(k)
+			bv, ok := b[k]
+			if !ok {
+				*ops = append(*ops, Operation{Op: "add", Path: child, Value: t[k]})
+				continue
+			}
+			diffJSON(child, bv, t[k], ops)
+		}
+		return
+
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			break
+		}
+		common := len(b)
+		if len(t) < common {
+			common = len(t)
+		}
+		for i := 0; i < common; i++ {
+			diffJSON(fmt.Sprintf("%s/%d", pointer, i), b[i], t[i], ops)
+		}
+		// lets remove from the end so that the indexes remain valid
+		for i := len(b) - 1; i >= common; i-- {
+			*ops = append(*ops, Operation{Op: "remove", Path: fmt.Sprintf("%s/%d", pointer, i)})
+		}
+		for i := common; i < len(t); i++ {
+			*ops = append(*ops, Operation{Op: "add", Path: pointer + "/-", Value: t[i]})
+		}
+		return
+	}
+	*ops = append(*ops, Operation{Op: "replace", Path: pointer, Value: target})
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func hasMergeKey(list []interface{}, key string) bool {
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := m[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func findElement(list []interface{}, key string, value interface{}) map[string]interface{} {
+	for _, item := range list {
+		m := item.(map[string]interface{})
+		if reflect.DeepEqual(m[key], value) {
+			return m
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
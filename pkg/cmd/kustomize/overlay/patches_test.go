@@ -0,0 +1,402 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/kustomize/overlay"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestStrategicMergePatch(t *testing.T) {
+	testCases := []struct {
+		name         string
+		base         string
+		target       string
+		replaceLists bool
+		expected     string
+		expectedOK   bool
+	}{
+		{
+			name: "identical",
+			base: `
+apiVersion: v1
+kind: ConfigMap
+data:
+  a: "1"
+`,
+			target: `
+apiVersion: v1
+kind: ConfigMap
+data:
+  a: "1"
+`,
+			expected:   `{}`,
+			expectedOK: true,
+		},
+		{
+			name: "changed added and removed fields",
+			base: `
+apiVersion: v1
+kind: ConfigMap
+data:
+  a: "1"
+  b: "2"
+`,
+			target: `
+apiVersion: v1
+kind: ConfigMap
+data:
+  a: "10"
+  c: "3"
+`,
+			expected: `
+data:
+  a: "10"
+  b: null
+  c: "3"
+`,
+			expectedOK: true,
+		},
+		{
+			name: "removed nested object",
+			base: `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 1
+  strategy:
+    type: Recreate
+`,
+			target: `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 1
+`,
+			expected: `
+spec:
+  strategy: null
+`,
+			expectedOK: true,
+		},
+		{
+			name: "list with merge key",
+			base: `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: a
+        image: a:1
+        env:
+        - name: X
+          value: "1"
+        - name: W
+          value: "2"
+      - name: b
+        image: b:1
+`,
+			target: `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: c
+        image: c:1
+      - name: a
+        image: a:1
+        env:
+        - name: X
+          value: "1"
+        - name: Z
+          value: "3"
+`,
+			expected: `
+spec:
+  template:
+    spec:
+      containers:
+      - name: c
+        image: c:1
+      - name: a
+        env:
+        - name: Z
+          value: "3"
+        - name: W
+          $patch: delete
+      - name: b
+        $patch: delete
+`,
+			expectedOK: true,
+		},
+		{
+			name: "reordered list with merge key",
+			base: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: a
+  - name: b
+`,
+			target: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: b
+  - name: a
+`,
+			expected:   `{}`,
+			expectedOK: true,
+		},
+		{
+			name: "service ports merge on port",
+			base: `
+apiVersion: v1
+kind: Service
+spec:
+  ports:
+  - port: 80
+    targetPort: 8080
+  - port: 443
+    targetPort: 8443
+`,
+			target: `
+apiVersion: v1
+kind: Service
+spec:
+  ports:
+  - port: 80
+    targetPort: 9090
+`,
+			expected: `
+spec:
+  ports:
+  - port: 80
+    targetPort: 9090
+  - port: 443
+    $patch: delete
+`,
+			expectedOK: true,
+		},
+		{
+			name: "list without merge key",
+			base: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+    - two
+`,
+			target: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+`,
+			expected: `
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+`,
+			expectedOK: false,
+		},
+		{
+			name: "list without merge key replaced",
+			base: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+    - two
+`,
+			target: `
+apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+`,
+			replaceLists: true,
+			expected: `
+spec:
+  containers:
+  - name: a
+    args:
+    - one
+`,
+			expectedOK: true,
+		},
+		{
+			name: "custom resource lists are replaced",
+			base: `
+apiVersion: tekton.dev/v1beta1
+kind: Task
+spec:
+  steps:
+  - name: a
+    image: a:1
+`,
+			target: `
+apiVersion: tekton.dev/v1beta1
+kind: Task
+spec:
+  steps:
+  - name: a
+    image: a:2
+`,
+			expected: `
+spec:
+  steps:
+  - name: a
+    image: a:2
+`,
+			expectedOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		base := parseYAML(t, tc.base)
+		target := parseYAML(t, tc.target)
+		expected := parseYAML(t, tc.expected)
+
+		patch, ok := overlay.StrategicMergePatch(base, target, tc.replaceLists)
+		assert.Equal(t, tc.expectedOK, ok, "ok for %s", tc.name)
+		assert.Equal(t, expected, patch, "patch for %s", tc.name)
+	}
+}
+
+func TestJSON6902Patch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		base     string
+		target   string
+		expected []overlay.Operation
+	}{
+		{
+			name: "identical",
+			base: `
+a: b
+`,
+			target: `
+a: b
+`,
+		},
+		{
+			name: "changed added and removed fields",
+			base: `
+data:
+  a: "1"
+  b: "2"
+  x/y: "3"
+`,
+			target: `
+data:
+  a: "10"
+  c: "3"
+`,
+			expected: []overlay.Operation{
+				{Op: "remove", Path: "/data/b"},
+				{Op: "remove", Path: "/data/x~1y"},
+				{Op: "replace", Path: "/data/a", Value: "10"},
+				{Op: "add", Path: "/data/c", Value: "3"},
+			},
+		},
+		{
+			name: "removed list elements",
+			base: `
+list:
+- a
+- b
+- c
+`,
+			target: `
+list:
+- x
+`,
+			expected: []overlay.Operation{
+				{Op: "replace", Path: "/list/0", Value: "x"},
+				{Op: "remove", Path: "/list/2"},
+				{Op: "remove", Path: "/list/1"},
+			},
+		},
+		{
+			name: "added list elements",
+			base: `
+list:
+- name: a
+`,
+			target: `
+list:
+- name: a
+  value: b
+- name: c
+`,
+			expected: []overlay.Operation{
+				{Op: "add", Path: "/list/0/value", Value: "b"},
+				{Op: "add", Path: "/list/-", Value: map[string]interface{}{"name": "c"}},
+			},
+		},
+		{
+			name: "changed type",
+			base: `
+value:
+  a: b
+`,
+			target: `
+value:
+- a
+`,
+			expected: []overlay.Operation{
+				{Op: "replace", Path: "/value", Value: []interface{}{"a"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		base := parseYAML(t, tc.base)
+		target := parseYAML(t, tc.target)
+
+		ops := overlay.JSON6902Patch(base, target)
+		assert.Equal(t, tc.expected, ops, "operations for %s", tc.name)
+	}
+}
+
+func TestMergeKey(t *testing.T) {
+	assert.Equal(t, "name", overlay.MergeKey("Deployment", []string{"spec", "template", "spec", "containers"}), "containers")
+	assert.Equal(t, "containerPort", overlay.MergeKey("Deployment", []string{"spec", "template", "spec", "containers", "ports"}), "container ports")
+	assert.Equal(t, "port", overlay.MergeKey("Service", []string{"spec", "ports"}), "service ports")
+	assert.Equal(t, "", overlay.MergeKey("Deployment", []string{"spec", "template", "spec", "containers", "args"}), "args")
+	assert.Equal(t, "", overlay.MergeKey("Deployment", nil), "no path")
+
+	assert.True(t, overlay.IsBuiltin("v1"), "core")
+	assert.True(t, overlay.IsBuiltin("apps/v1"), "apps")
+	assert.True(t, overlay.IsBuiltin("networking.k8s.io/v1"), "networking")
+	assert.False(t, overlay.IsBuiltin("tekton.dev/v1beta1"), "tekton")
+}
+
+func parseYAML(t *testing.T, text string) map[string]interface{} {
+	answer := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(text), &answer)
+	assert.NoError(t, err, "failed to parse YAML %s", text)
+	return answer
+}
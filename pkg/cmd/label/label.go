@@ -2,13 +2,17 @@ package label
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/watch"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -16,7 +20,11 @@ import (
 
 var (
 	cmdLong = templates.LongDesc(`
-		Updates all kubernetes resources in the given directory tree to add/override the given label
+		Updates all kubernetes resources in the given directory tree to add or remove the given labels
+
+		Every document of the *.yaml and *.yml files is updated including the items of List resources. Documents which are not kubernetes resources are left untouched. Existing labels with a different value are only replaced if --overwrite is specified. A label can be removed by using the key- argument or the --invert flag.
+
+		If --pod-template is specified the labels are also added to the pod templates of the workloads. As the spec.selector of Deployments, StatefulSets, DaemonSets and ReplicaSets is immutable, changing a pod template label used by the selector fails unless --force is specified in which case the selector is changed too.
 `)
 
 	cmdExample = templates.Examples(`
@@ -24,55 +32,183 @@ var (
 		%s label mylabel=cheese another=thing
 		# updates recursively all resources 
 		%s label --dir myresource-dir foo=bar
+		# updates recursively all resources and the pod templates of the workloads
+		%s label --pod-template team=payments
+		# replaces the value of an existing label and removes another label
+		%s label --overwrite team=billing owner-
 	`)
 )
 
 // Options the options for the command
 type Options struct {
 	kyamls.Filter
-	Dir   string
-	Label string
+	common.WalkOptions
+	Watch       watch.Options
+	Dir         string
+	Label       string
+	Labels      []string
+	PodTemplate bool
+	Force       bool
+	Overwrite   bool
+	Invert      bool
+	kept        int
 }
 
+var (
+	// selectorKinds the kinds whose immutable spec.selector.matchLabels must match the labels of their pod template
+	selectorKinds = map[string]bool{
+		"Deployment":  true,
+		"StatefulSet": true,
+		"DaemonSet":   true,
+		"ReplicaSet":  true,
+	}
+)
+
 // NewCmdUpdate creates a command object for the command
 func NewCmdUpdateLabel() (*cobra.Command, *Options) {
 	o := &Options{}
 
 	cmd := &cobra.Command{
 		Use:     "label",
-		Short:   "Updates all kubernetes resources in the given directory tree to add/override the given label",
+		Short:   "Updates all kubernetes resources in the given directory tree to add or remove the given labels",
 		Long:    cmdLong,
-		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
-			err := UpdateLabelInYamlFiles(o.Dir, args, o.Filter)
+			o.Labels = args
+			err := o.Watch.Run([]string{o.Dir}, o.Run)
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.PodTemplate, "pod-template", "", false, "also adds the labels to the pod templates of workloads such as Deployments and CronJobs")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "replaces the value of existing labels with the same key. By default existing labels are kept")
+	cmd.Flags().BoolVarP(&o.Invert, "invert", "", false, "removes the labels with the given keys rather than adding them")
+	cmd.Flags().BoolVarP(&o.Force, "force", "", false, "if a label of a pod template is used by the immutable spec.selector of its Deployment, StatefulSet, DaemonSet or ReplicaSet then change the selector too rather than failing")
 	o.Filter.AddFlags(cmd)
+	o.WalkOptions.AddFlags(cmd)
+	o.Watch.AddFlags(cmd)
 	return cmd, o
 }
 
-// UpdateLabelInYamlFiles updates the labels in yaml files
+// UpdateLabelInYamlFiles updates the labels in yaml files replacing the values of any existing labels
 func UpdateLabelInYamlFiles(dir string, labels []string, filter kyamls.Filter) error {
+	o := &Options{
+		Filter:    filter,
+		Dir:       dir,
+		Labels:    labels,
+		Overwrite: true,
+	}
+	return o.Run()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	changes, err := resourcehelpers.ParseKeyValueChanges(o.Labels, o.Invert)
+	if err != nil {
+		return errors.Wrapf(err, "invalid labels")
+	}
+	changes.Overwrite = o.Overwrite
+
+	o.kept = 0
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		sort.Strings(labels)
-
-		for _, a := range labels {
-			paths := strings.SplitN(a, "=", 2)
-			k := paths[0]
-			v := ""
-			if len(paths) > 1 {
-				v = paths[1]
+		existing, err := resourcehelpers.NodeStringMap(node, "metadata", "labels")
+		if err != nil {
+			return false, err
+		}
+		newLabels, modified, kept := changes.Apply(existing)
+		o.kept += kept
+		if modified {
+			err = resourcehelpers.SetNodeStringMap(node, newLabels, "metadata", "labels")
+			if err != nil {
+				return false, err
 			}
+		}
+		if o.PodTemplate {
+			flag, err := o.updatePodTemplateLabels(node, path, changes)
+			if err != nil {
+				return false, err
+			}
+			if flag {
+				modified = true
+			}
+		}
+		return modified, nil
+	}
+
+	err = resourcehelpers.ModifyFilteredNodes(o.Dir, o.WalkOptions, o.Filter, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to label resources in dir %s", o.Dir)
+	}
+	if o.kept > 0 {
+		log.Logger().Infof("kept %d existing labels with different values. Use --overwrite to replace them", o.kept)
+	}
+	return nil
+}
 
-			err := node.PipeE(yaml.SetLabel(k, v))
+// updatePodTemplateLabels updates the labels on the pod template of a workload. If a label is used by the
+// immutable selector with a different value then the selector no longer matches the pods so this fails unless forced
+func (o *Options) updatePodTemplateLabels(node *yaml.RNode, path string, changes *resourcehelpers.KeyValueChanges) (bool, error) {
+	kind := kyamls.GetKind(node, path)
+	name := kyamls.GetName(node, path)
+	podSpecPath := resourcehelpers.PodSpecPath(kind)
+	if len(podSpecPath) < 2 {
+		return false, nil
+	}
+	templatePath := podSpecPath[:len(podSpecPath)-1]
+	template, err := node.Pipe(yaml.Lookup(templatePath...))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find %s of %s", strings.Join(templatePath, "."), path)
+	}
+	if template == nil || template.YNode().Kind != yaml.MappingNode {
+		return false, nil
+	}
+	labelsPath := append(append([]string{}, templatePath...), "metadata", "labels")
+	existing, err := resourcehelpers.NodeStringMap(node, labelsPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get %s of %s", strings.Join(labelsPath, "."), path)
+	}
+	newLabels, modified, kept := changes.Apply(existing)
+	o.kept += kept
+	if !modified {
+		return false, nil
+	}
+
+	if selectorKinds[kind] {
+		selectorPath := []string{"spec", "selector", "matchLabels"}
+		selector, err := resourcehelpers.NodeStringMap(node, selectorPath...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get %s of %s", strings.Join(selectorPath, "."), path)
+		}
+		changed := false
+		for _, k := range stringhelpers.SortedMapKeys(selector) {
+			current := selector[k]
+			old, oldOK := existing[k]
+			v, ok := newLabels[k]
+			if ok == oldOK && v == old {
+				continue
+			}
+			if !o.Force {
+				return false, errors.Errorf("cannot change the label %s of the pod template of %s %s in file %s from %s to %s as it is used by the immutable spec.selector. Use --force to change the selector too", k, kind, name, path, current, v)
+			}
+			if ok {
+				selector[k] = v
+			} else {
+				delete(selector, k)
+			}
+			changed = true
+		}
+		if changed {
+			log.Logger().Warnf("changing the immutable spec.selector of %s %s in file %s so it will need to be deleted and recreated", kind, name, path)
+			err = resourcehelpers.SetNodeStringMap(node, selector, selectorPath...)
 			if err != nil {
-				return false, errors.Wrapf(err, "failed to set label %s=%s", k, v)
+				return false, errors.Wrapf(err, "failed to set %s of %s", strings.Join(selectorPath, "."), path)
 			}
 		}
-		return true, nil
 	}
 
-	return kyamls.ModifyFiles(dir, modifyFn, filter)
+	err = resourcehelpers.SetNodeStringMap(node, newLabels, labelsPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to set %s of %s", strings.Join(labelsPath, "."), path)
+	}
+	return true, nil
 }
@@ -8,10 +8,13 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/label"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestUpdateLabelsInYamlFiles(t *testing.T) {
@@ -73,3 +76,125 @@ func TestUpdateLabelsInYamlFiles(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateLabelsPodTemplate(t *testing.T) {
+	srcFile := filepath.Join("test_data", "deployment", "source.yaml")
+	newDir := func() (string, string) {
+		tmpDir, err := ioutil.TempDir("", "")
+		require.NoError(t, err, "could not create temp dir")
+		outFile := filepath.Join(tmpDir, "deployment.yaml")
+		err = files.CopyFile(srcFile, outFile)
+		require.NoError(t, err, "failed to copy %s to %s", srcFile, outFile)
+		return tmpDir, outFile
+	}
+
+	tmpDir, outFile := newDir()
+	_, o := label.NewCmdUpdateLabel()
+	o.Dir = tmpDir
+	o.Labels = []string{"wine=merlot", "beer=stella"}
+	o.PodTemplate = true
+	err := o.Run()
+	require.NoError(t, err, "failed to update labels in dir %s", tmpDir)
+	testhelpers.AssertTextFilesEqual(t, filepath.Join("test_data", "deployment", "expected-pod-template.yaml"), outFile, "labelled pod template")
+
+	// changing a label used by the immutable selector would create a deployment which fails to apply
+	tmpDir, outFile = newDir()
+	_, o = label.NewCmdUpdateLabel()
+	o.Dir = tmpDir
+	o.Labels = []string{"app=api"}
+	o.PodTemplate = true
+	o.Overwrite = true
+	err = o.Run()
+	require.Error(t, err, "should fail to change a label used by the selector")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), "spec.selector", "error message")
+	testhelpers.AssertTextFilesEqual(t, srcFile, outFile, "the file should not be modified")
+
+	// unless forced in which case the selector is kept consistent with the pod template
+	o.Force = true
+	err = o.Run()
+	require.NoError(t, err, "failed to force the label change in dir %s", tmpDir)
+	resources, err := resourcehelpers.LoadFile(outFile)
+	require.NoError(t, err, "failed to load %s", outFile)
+	require.Len(t, resources, 1, "resources")
+	u := resources[0]
+	selector, _, _ := unstructured.NestedStringMap(u.Object, "spec", "selector", "matchLabels")
+	podLabels, _, _ := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"app": "api", "wine": "merlot"}, selector, "selector")
+	assert.Equal(t, selector, podLabels, "the pod template labels should match the selector")
+	assert.Equal(t, "api", u.GetLabels()["app"], "label of the deployment")
+}
+
+func TestUpdateLabelsOverwriteAndRemove(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+	err = files.CopyDirOverwrite(filepath.Join("test_data", "multi-doc"), tmpDir)
+	require.NoError(t, err, "failed to copy test data")
+	sourceFile := filepath.Join(tmpDir, "source.yaml")
+	notResourceFile := filepath.Join(tmpDir, "values.yaml")
+	notResourceData := "# not a kubernetes resource\nbeer: 'corona'\n"
+	err = ioutil.WriteFile(notResourceFile, []byte(notResourceData), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", notResourceFile)
+
+	_, o := label.NewCmdUpdateLabel()
+	o.Dir = tmpDir
+	o.Labels = []string{"beer=stella", "wine=merlot"}
+	o.Overwrite = true
+	err = o.Run()
+	require.NoError(t, err, "failed to overwrite labels in dir %s", tmpDir)
+	assertLabels(t, sourceFile, []map[string]string{
+		{"beer": "stella", "wine": "merlot"},
+		nil,
+		{"beer": "stella", "wine": "merlot"},
+	})
+
+	_, o = label.NewCmdUpdateLabel()
+	o.Dir = tmpDir
+	o.Labels = []string{"beer-", "team=cheese"}
+	err = o.Run()
+	require.NoError(t, err, "failed to remove labels in dir %s", tmpDir)
+	assertLabels(t, sourceFile, []map[string]string{
+		{"team": "cheese", "wine": "merlot"},
+		nil,
+		{"team": "cheese", "wine": "merlot"},
+	})
+
+	_, o = label.NewCmdUpdateLabel()
+	o.Dir = tmpDir
+	o.Labels = []string{"team", "wine=merlot"}
+	o.Invert = true
+	err = o.Run()
+	require.NoError(t, err, "failed to remove inverted labels in dir %s", tmpDir)
+	assertLabels(t, sourceFile, []map[string]string{nil, nil, nil})
+
+	data, err := ioutil.ReadFile(notResourceFile)
+	require.NoError(t, err, "failed to load %s", notResourceFile)
+	assert.Equal(t, notResourceData, string(data), "files without kubernetes resources should not be modified")
+
+	for _, args := range [][]string{nil, {"=cheese"}, {"-"}} {
+		_, o = label.NewCmdUpdateLabel()
+		o.Dir = tmpDir
+		o.Labels = args
+		err = o.Run()
+		assert.Error(t, err, "should fail for labels %v", args)
+	}
+}
+
+func assertLabels(t *testing.T, path string, expected []map[string]string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, len(expected), "documents in %s", path)
+	for i, u := range resources {
+		actual, _, _ := unstructured.NestedStringMap(u.Object, "metadata", "labels")
+		assert.Equal(t, expected[i], actual, "labels of document %d in %s", i+1, path)
+	}
+}
+
+func TestUpdateLabelsMissingDir(t *testing.T) {
+	_, o := label.NewCmdUpdateLabel()
+	o.Dir = filepath.Join("test_data", "does-not-exist")
+	o.Labels = []string{"wine=merlot"}
+	err := o.Run()
+	require.Error(t, err, "should fail to label the resources in a missing dir")
+	t.Logf("got expected error: %s", err.Error())
+}
@@ -6,7 +6,10 @@ import (
 
 	"github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/lint/names"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/lint/resources"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/linter"
@@ -53,6 +56,8 @@ func NewCmdLint() (*cobra.Command, *Options) {
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.AddCommand(cobras.SplitCommand(names.NewCmdLintNames()))
+	cmd.AddCommand(cobras.SplitCommand(resources.NewCmdLintResources()))
 	return cmd, o
 }
 
@@ -0,0 +1,108 @@
+package names
+
+import (
+	"io/ioutil"
+	"regexp"
+
+	"github.com/jenkins-x/jx-gitops/pkg/policies"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Config the naming conventions loaded from the --config file
+type Config struct {
+	// Pattern the regular expression the names of all the kinds without their own pattern must match
+	Pattern string `json:"pattern,omitempty"`
+
+	// MaxLength the maximum length of the names of all the kinds
+	MaxLength int `json:"maxLength,omitempty"`
+
+	// Rules the naming conventions of specific kinds
+	Rules []KindRule `json:"rules,omitempty"`
+
+	// Exempt the resources which are not checked
+	Exempt []Exempt `json:"exempt,omitempty"`
+}
+
+// KindRule the naming convention of some kinds of resource
+type KindRule struct {
+	// Kinds the kinds the rule applies to which can be prefixed with an API version such as 'apps/v1/Deployment'
+	Kinds []string `json:"kinds"`
+
+	// Pattern the regular expression the names must match instead of the default pattern
+	Pattern string `json:"pattern,omitempty"`
+
+	// MaxLength the maximum length of the names
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
+// Exempt selects the resources which are not checked
+type Exempt struct {
+	// Kinds the kinds to match
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Names the names to match which can contain wildcards
+	Names []string `json:"names,omitempty"`
+
+	// Namespaces the namespaces to match
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Labels the labels which must match
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// LoadConfig loads the naming conventions from the given file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	config := &Config{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	return config, nil
+}
+
+// Engine creates the rule engine of the naming conventions
+func (c *Config) Engine() (*policies.Engine, error) {
+	engine := &policies.Engine{}
+	var patternKinds []string
+	for i, r := range c.Rules {
+		if len(r.Kinds) == 0 {
+			return nil, errors.Errorf("the naming rule %d has no kinds", i+1)
+		}
+		if r.Pattern != "" {
+			pattern, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern %s of the naming rule for %v", r.Pattern, r.Kinds)
+			}
+			engine.Rules = append(engine.Rules, &policies.NamePatternRule{Kinds: r.Kinds, Pattern: pattern})
+			patternKinds = append(patternKinds, r.Kinds...)
+		}
+		if r.MaxLength > 0 {
+			engine.Rules = append(engine.Rules, &policies.NameLengthRule{Kinds: r.Kinds, MaxLength: r.MaxLength})
+		}
+	}
+	if c.Pattern != "" {
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %s", c.Pattern)
+		}
+		engine.Rules = append(engine.Rules, &policies.NamePatternRule{ExcludeKinds: patternKinds, Pattern: pattern})
+	}
+	if c.MaxLength > 0 {
+		engine.Rules = append(engine.Rules, &policies.NameLengthRule{MaxLength: c.MaxLength})
+	}
+	for _, e := range c.Exempt {
+		engine.Exempt = append(engine.Exempt, resourcehelpers.Selector{
+			Kinds:      e.Kinds,
+			Names:      e.Names,
+			Namespaces: e.Namespaces,
+			Labels:     e.Labels,
+		})
+	}
+	return engine, nil
+}
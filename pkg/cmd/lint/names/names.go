@@ -0,0 +1,159 @@
+package names
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/policies"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lints the names of the kubernetes resources in the given directory tree against naming conventions
+
+		The names of all the resources must match the --pattern regular expression. The --config file can specify the default pattern, patterns and maximum name lengths of specific kinds, such as for Services which are used in DNS names, and the resources which are exempt:
+
+		    pattern: '^[a-z][a-z0-9-]{1,40}$'
+		    rules:
+		    - kinds: [Service]
+		      pattern: '^team-[a-z0-9-]+$'
+		      maxLength: 15
+		    exempt:
+		    - kinds: [Secret]
+		      names: ['sh.helm.release.*']
+
+		A kind with its own pattern is only checked against that pattern. The --pattern overrides the default pattern of the config file. Resources with a generateName and no name are skipped unless --skip-generate-name=false is specified.
+
+		Every violation is reported with the file, kind, name and the rule which is broken and the command fails if there are any.
+`)
+
+	cmdExample = templates.Examples(`
+		# lints the names of the resources in the current directory
+		%s lint names --pattern '^[a-z][a-z0-9-]{1,40}$'
+
+		# lints the names using the naming conventions in a file and outputs JSON
+		%s lint names --dir config-root --config naming.yaml --output json
+
+		# skips the resources with a label
+		%s lint names --config naming.yaml --exempt-selector lint.jenkins-x.io/ignore=true
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	output.Options
+	Dir              string
+	Pattern          string
+	ConfigFile       string
+	ExemptSelector   string
+	SkipGenerateName bool
+	Out              io.Writer
+	Violations       []policies.Violation
+	engine           *policies.Engine
+}
+
+// NewCmdLintNames creates a command object for the command
+func NewCmdLintNames() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "names",
+		Short:   "Lints the names of the kubernetes resources in the given directory tree against naming conventions",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Pattern, "pattern", "p", "", "the regular expression the names of the resources must match")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config", "c", "", "the YAML file containing the naming conventions")
+	cmd.Flags().StringVarP(&o.ExemptSelector, "exempt-selector", "", "", "the label selector of the resources which are not checked such as 'lint.jenkins-x.io/ignore=true'")
+	cmd.Flags().BoolVarP(&o.SkipGenerateName, "skip-generate-name", "", true, "skips the resources which have a generateName and no name")
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	err := o.Options.Validate()
+	if err != nil {
+		return err
+	}
+	if o.engine != nil {
+		return nil
+	}
+	if o.Pattern == "" && o.ConfigFile == "" {
+		return options.MissingOption("pattern")
+	}
+	config := &Config{}
+	if o.ConfigFile != "" {
+		config, err = LoadConfig(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+	if o.Pattern != "" {
+		config.Pattern = o.Pattern
+	}
+	if o.ExemptSelector != "" {
+		m, err := labels.ConvertSelectorToLabelsMap(o.ExemptSelector)
+		if err != nil {
+			return options.InvalidOptionf("exempt-selector", o.ExemptSelector, "should be of the form 'key=value,key2=value2'")
+		}
+		config.Exempt = append(config.Exempt, Exempt{Labels: m})
+	}
+	o.engine, err = config.Engine()
+	if err != nil {
+		return err
+	}
+	o.engine.SkipGenerateName = o.SkipGenerateName
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	o.Violations, err = o.engine.CheckDir(o.Dir)
+	if err != nil {
+		return err
+	}
+	err = o.render()
+	if err != nil {
+		return errors.Wrapf(err, "failed to output the violations")
+	}
+	if len(o.Violations) > 0 {
+		return errors.Errorf("found %d violations of the naming conventions in dir %s", len(o.Violations), o.Dir)
+	}
+	log.Logger().Infof("the names of the resources in dir %s follow the naming conventions", o.Dir)
+	return nil
+}
+
+func (o *Options) render() error {
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "FILE", Field: "File"},
+		output.Column{Header: "DOCUMENT", Field: "Document"},
+		output.Column{Header: "KIND", Field: "Kind"},
+		output.Column{Header: "NAME", Field: "Name"},
+		output.Column{Header: "RULE", Field: "Rule"},
+		output.Column{Header: "MESSAGE", Field: "Message"},
+	)
+	return renderer.Render(o.Violations)
+}
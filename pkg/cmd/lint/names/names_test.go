@@ -0,0 +1,79 @@
+package names_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/lint/names"
+	"github.com/jenkins-x/jx-gitops/pkg/policies"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintNames(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := names.NewCmdLintNames()
+	o.Dir = filepath.Join("test_data", "config")
+	o.ConfigFile = filepath.Join("test_data", "naming.yaml")
+	o.ExemptSelector = "lint.jenkins-x.io/ignore=true"
+	o.Out = &buf
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with violations")
+	assert.Contains(t, err.Error(), "found 2 violations")
+
+	require.Len(t, o.Violations, 2, "violations")
+	v := o.Violations[0]
+	assert.Equal(t, "web/resources.yaml", v.File, "file")
+	assert.Equal(t, 1, v.Document, "document")
+	assert.Equal(t, "Service", v.Kind, "kind")
+	assert.Equal(t, "web-frontend-public", v.Name, "name")
+	assert.Equal(t, policies.RuleNameLength, v.Rule, "rule")
+
+	v = o.Violations[1]
+	assert.Equal(t, 2, v.Document, "document")
+	assert.Equal(t, "Web_Settings", v.Name, "name")
+	assert.Equal(t, policies.RuleNamePattern, v.Rule, "rule")
+
+	assert.Regexp(t, `(?m)^FILE\s+DOCUMENT\s+KIND\s+NAME\s+RULE\s+MESSAGE$`, buf.String(), "table header")
+	assert.Regexp(t, `(?m)^web/resources\.yaml\s+2\s+ConfigMap\s+Web_Settings\s+name-pattern\s+the name 'Web_Settings' does not match the pattern`, buf.String(), "table row")
+}
+
+func TestLintNamesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := names.NewCmdLintNames()
+	o.Dir = filepath.Join("test_data", "config")
+	o.Pattern = "^[a-z][a-z0-9.-]*$"
+	o.SkipGenerateName = false
+	o.Format = "json"
+	o.Out = &buf
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with violations")
+
+	var violations []policies.Violation
+	err = json.Unmarshal(buf.Bytes(), &violations)
+	require.NoError(t, err, "failed to parse JSON %s", buf.String())
+
+	var found []string
+	for _, v := range violations {
+		found = append(found, v.Kind+"/"+v.Name)
+	}
+	assert.Equal(t, []string{"Job/", "ConfigMap/LegacySettings", "ConfigMap/Web_Settings"}, found, "violations")
+}
+
+func TestLintNamesValid(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := names.NewCmdLintNames()
+	o.Dir = filepath.Join("test_data", "config", "web")
+	o.Pattern = "^[A-Za-z_-]+$"
+	o.Format = "json"
+	o.Out = &buf
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Empty(t, o.Violations, "violations")
+	assert.Equal(t, "[]\n", buf.String(), "output")
+}
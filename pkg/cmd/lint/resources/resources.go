@@ -0,0 +1,235 @@
+package resources
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lints the containers of the workloads in the given directory tree against the resource and health probe policies
+
+		The containers of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector must declare resource requests and limits and the containers must also have a liveness or readiness probe. The init containers are only checked for their requests and limits as they cannot have probes. Each rule can be disabled such as via --probes=false.
+
+		A workload is exempt from all the rules if it has the annotation 'lint.jenkins-x.io/exempt: "true"' or from some of the rules if the annotation is a comma separated list of their names such as 'lint.jenkins-x.io/exempt: probes'.
+
+		Every violation is reported with the file, resource, container and the rule which is broken and the command fails if there are any unless --warn-only is specified.
+`)
+
+	cmdExample = templates.Examples(`
+		# lints the containers of the workloads in the current directory
+		%s lint resources --dir .
+
+		# only checks the requests and limits and outputs the violations as JSON
+		%s lint resources --dir config-root --probes=false --output json
+
+		# reports the violations without failing
+		%s lint resources --warn-only
+	`)
+
+	// Rules the names of the rules which can be checked
+	Rules = []string{RuleRequests, RuleLimits, RuleProbes}
+)
+
+const (
+	// RuleRequests the rule which checks containers declare their resource requests
+	RuleRequests = "requests"
+
+	// RuleLimits the rule which checks containers declare their resource limits
+	RuleLimits = "limits"
+
+	// RuleProbes the rule which checks containers have a liveness or readiness probe
+	RuleProbes = "probes"
+
+	// ExemptAnnotation the annotation of the workloads which are exempt from all the rules if it is 'true' or from
+	// the comma separated rules
+	ExemptAnnotation = "lint.jenkins-x.io/exempt"
+)
+
+// Violation a container which breaks a rule
+type Violation struct {
+	// File the file containing the workload relative to the directory
+	File string `json:"file"`
+
+	// Resource the key of the workload
+	Resource string `json:"resource"`
+
+	// Container the name of the container
+	Container string `json:"container"`
+
+	// Rule the name of the rule which is broken
+	Rule string `json:"rule"`
+
+	// Message the description of how the rule is broken
+	Message string `json:"message"`
+}
+
+// String returns a description of the violation
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: %s container %s: %s: %s", v.File, v.Resource, v.Container, v.Rule, v.Message)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir        string
+	Requests   bool
+	Limits     bool
+	Probes     bool
+	WarnOnly   bool
+	Out        io.Writer
+	Violations []Violation
+	Checked    int
+}
+
+// NewCmdLintResources creates a command object for the command
+func NewCmdLintResources() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "resources",
+		Short:   "Lints the containers of the workloads in the given directory tree against the resource and health probe policies",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Requests, "requests", "", true, "checks the containers declare their resource requests")
+	cmd.Flags().BoolVarP(&o.Limits, "limits", "", true, "checks the containers declare their resource limits")
+	cmd.Flags().BoolVarP(&o.Probes, "probes", "", true, "checks the containers have a liveness or readiness probe")
+	cmd.Flags().BoolVarP(&o.WarnOnly, "warn-only", "w", false, "only log the violations rather than failing")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if !o.Requests && !o.Limits && !o.Probes {
+		return errors.Errorf("all the rules are disabled so there is nothing to check")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Violations = nil
+	o.Checked = 0
+	err = resourcehelpers.VisitFiles(o.Dir, o.Selector, func(u *unstructured.Unstructured, path string) error {
+		file, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			file = path
+		}
+		return o.checkResource(u, filepath.ToSlash(file))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to lint the resources in dir %s", o.Dir)
+	}
+	err = o.render()
+	if err != nil {
+		return errors.Wrapf(err, "failed to output the violations")
+	}
+
+	count := len(o.Violations)
+	if count == 0 {
+		log.Logger().Infof("all %d containers in dir %s follow the resource and probe policies", o.Checked, o.Dir)
+		return nil
+	}
+	if o.WarnOnly {
+		log.Logger().Warnf("found %s violations of the resource and probe policies", termcolor.ColorWarning(fmt.Sprintf("%d", count)))
+		return nil
+	}
+	return errors.Errorf("found %d violations of the resource and probe policies in dir %s", count, o.Dir)
+}
+
+// checkResource checks the containers of the pod spec of the resource against the rules which are enabled and
+// which the resource is not exempt from
+func (o *Options) checkResource(u *unstructured.Unstructured, file string) error {
+	key := resourcehelpers.ResourceKey(u)
+	containers, err := resourcehelpers.PodContainers(u, "containers", "initContainers")
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the containers of %s", key)
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+	exempt := exemptRules(u)
+	enabled := func(rule string) bool {
+		return !exempt[rule] && !exempt["true"]
+	}
+	for i := range containers {
+		c := &containers[i]
+		o.Checked++
+		add := func(rule, message string) {
+			o.Violations = append(o.Violations, Violation{File: file, Resource: key, Container: c.Name(), Rule: rule, Message: message})
+		}
+		resources, _ := c.Object["resources"].(map[string]interface{})
+		if o.Requests && enabled(RuleRequests) && isEmpty(resources["requests"]) {
+			add(RuleRequests, "no resources.requests are declared")
+		}
+		if o.Limits && enabled(RuleLimits) && isEmpty(resources["limits"]) {
+			add(RuleLimits, "no resources.limits are declared")
+		}
+		if o.Probes && enabled(RuleProbes) && c.Field == "containers" && c.Object["livenessProbe"] == nil && c.Object["readinessProbe"] == nil {
+			add(RuleProbes, "no livenessProbe or readinessProbe is declared")
+		}
+	}
+	return nil
+}
+
+func (o *Options) render() error {
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "FILE", Field: "File"},
+		output.Column{Header: "RESOURCE", Field: "Resource"},
+		output.Column{Header: "CONTAINER", Field: "Container"},
+		output.Column{Header: "RULE", Field: "Rule"},
+		output.Column{Header: "MESSAGE", Field: "Message"},
+	)
+	return renderer.Render(o.Violations)
+}
+
+// exemptRules returns the set of rules in the exemption annotation of the resource which includes 'true' if the
+// resource is exempt from all the rules
+func exemptRules(u *unstructured.Unstructured) map[string]bool {
+	answer := map[string]bool{}
+	value := u.GetAnnotations()[ExemptAnnotation]
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule != "" {
+			answer[rule] = true
+		}
+	}
+	return answer
+}
+
+// isEmpty returns true if the value is missing or is an empty map
+func isEmpty(value interface{}) bool {
+	m, ok := value.(map[string]interface{})
+	return !ok || len(m) == 0
+}
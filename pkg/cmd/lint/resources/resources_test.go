@@ -0,0 +1,71 @@
+package resources_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/lint/resources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintResources(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := resources.NewCmdLintResources()
+	o.Dir = "test_data"
+	o.Out = &buf
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with violations")
+	assert.Contains(t, err.Error(), "found 6 violations")
+
+	assert.Equal(t, []resources.Violation{
+		{File: "cronjob.yaml", Resource: "jx/CronJob/cleanup", Container: "cleanup", Rule: resources.RuleRequests, Message: "no resources.requests are declared"},
+		{File: "cronjob.yaml", Resource: "jx/CronJob/cleanup", Container: "cleanup", Rule: resources.RuleLimits, Message: "no resources.limits are declared"},
+		{File: "cronjob.yaml", Resource: "jx/CronJob/cleanup", Container: "cleanup", Rule: resources.RuleProbes, Message: "no livenessProbe or readinessProbe is declared"},
+		{File: "deployment.yaml", Resource: "jx/Deployment/web", Container: "proxy", Rule: resources.RuleLimits, Message: "no resources.limits are declared"},
+		{File: "deployment.yaml", Resource: "jx/Deployment/web", Container: "migrate", Rule: resources.RuleRequests, Message: "no resources.requests are declared"},
+		{File: "exempt.yaml", Resource: "jx/Job/setup", Container: "setup", Rule: resources.RuleLimits, Message: "no resources.limits are declared"},
+	}, o.Violations, "violations")
+
+	assert.Regexp(t, `(?m)^FILE\s+RESOURCE\s+CONTAINER\s+RULE\s+MESSAGE$`, buf.String(), "table header")
+	assert.Regexp(t, `(?m)^deployment\.yaml\s+jx/Deployment/web\s+proxy\s+limits\s+no resources\.limits are declared$`, buf.String(), "table row")
+}
+
+func TestLintResourcesRulesAndJSON(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := resources.NewCmdLintResources()
+	o.Dir = "test_data"
+	o.Requests = false
+	o.Limits = false
+	o.Format = "json"
+	o.Out = &buf
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with violations")
+
+	var violations []resources.Violation
+	err = json.Unmarshal(buf.Bytes(), &violations)
+	require.NoError(t, err, "failed to parse JSON %s", buf.String())
+	require.Len(t, violations, 1, "violations")
+	assert.Equal(t, "jx/CronJob/cleanup", violations[0].Resource, "resource")
+	assert.Equal(t, resources.RuleProbes, violations[0].Rule, "rule")
+}
+
+func TestLintResourcesWarnOnly(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := resources.NewCmdLintResources()
+	o.Dir = "test_data"
+	o.Kinds = []string{"Deployment"}
+	o.WarnOnly = true
+	o.Out = &buf
+
+	err := o.Run()
+	require.NoError(t, err, "should not fail with --warn-only")
+	assert.Len(t, o.Violations, 2, "violations")
+
+	o.Format = "xml"
+	err = o.Run()
+	require.Error(t, err, "should not allow an unknown output format")
+}
@@ -0,0 +1,183 @@
+package namespace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/rename"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	createResourcesLong = templates.LongDesc(`
+		Generates a Namespace resource for each namespace used by the kubernetes resources in the given directory tree
+
+		The distinct namespaces of the resources are collected and a Namespace resource is saved for each of them into the --out-dir which defaults to the 'namespaces' directory inside the --dir. Namespaces which already have a Namespace resource anywhere in the directory tree are skipped so that running the command again does not duplicate or modify any existing Namespace resources. The namespaces which always exist in a cluster such as 'default' are skipped unless --skip-namespace is specified.
+
+		The generated Namespaces have the --name-label label set to the name of the namespace along with any --label values.
+`)
+
+	createResourcesExample = templates.Examples(`
+		# generates the missing Namespace resources into config-root/namespaces
+		%s namespace create-resources --dir config-root
+
+		# generates the missing Namespace resources with an extra label
+		%s namespace create-resources --dir config-root --out-dir config-root/cluster/namespaces --label team=platform
+	`)
+
+	// DefaultSkipNamespaces the namespaces which always exist in a cluster so do not need a Namespace resource
+	DefaultSkipNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+)
+
+// CreateResourcesOptions the options for the create-resources command
+type CreateResourcesOptions struct {
+	Dir            string
+	OutDir         string
+	NameLabel      string
+	Labels         []string
+	SkipNamespaces []string
+	Created        []string
+	labels         map[string]string
+}
+
+// NewCmdCreateResources creates a command object for the command
+func NewCmdCreateResources() (*cobra.Command, *CreateResourcesOptions) {
+	o := &CreateResourcesOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "create-resources",
+		Aliases: []string{"create"},
+		Short:   "Generates a Namespace resource for each namespace used by the kubernetes resources in the given directory tree",
+		Long:    createResourcesLong,
+		Example: fmt.Sprintf(createResourcesExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "", "the directory to save the generated Namespace resources. Defaults to the 'namespaces' directory inside the --dir")
+	cmd.Flags().StringVarP(&o.NameLabel, "name-label", "", "name", "the label set to the name of the namespace on the generated Namespace resources. If blank it is not added")
+	cmd.Flags().StringArrayVarP(&o.Labels, "label", "l", nil, "the labels of the form 'key=value' to add to the generated Namespace resources")
+	cmd.Flags().StringArrayVarP(&o.SkipNamespaces, "skip-namespace", "", DefaultSkipNamespaces, "the namespaces which do not need a Namespace resource")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *CreateResourcesOptions) Validate() error {
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	if o.OutDir == "" {
+		o.OutDir = filepath.Join(o.Dir, "namespaces")
+	}
+	o.labels = map[string]string{}
+	for _, text := range o.Labels {
+		idx := strings.Index(text, "=")
+		if idx <= 0 {
+			return options.InvalidOptionf("label", text, "should be of the form 'key=value'")
+		}
+		o.labels[text[:idx]] = text[idx+1:]
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *CreateResourcesOptions) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	used := map[string]bool{}
+	existing := map[string]bool{}
+	err = resourcehelpers.VisitFiles(o.Dir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+		if u.GetKind() == "Namespace" {
+			existing[u.GetName()] = true
+			return nil
+		}
+		if ns := u.GetNamespace(); ns != "" {
+			used[ns] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the namespaces of the resources in dir %s", o.Dir)
+	}
+
+	var missing []string
+	for ns := range used {
+		if !existing[ns] && stringhelpers.StringArrayIndex(o.SkipNamespaces, ns) < 0 {
+			missing = append(missing, ns)
+		}
+	}
+	sort.Strings(missing)
+
+	o.Created = nil
+	for _, ns := range missing {
+		err = o.createNamespaceResource(ns)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the Namespace resource %s", ns)
+		}
+		o.Created = append(o.Created, ns)
+	}
+	log.Logger().Infof("created %s Namespace resources in dir %s", termcolor.ColorInfo(fmt.Sprintf("%d", len(o.Created))), o.OutDir)
+	return nil
+}
+
+// createNamespaceResource saves the Namespace resource failing rather than replacing a file which already exists
+func (o *CreateResourcesOptions) createNamespaceResource(ns string) error {
+	fileName := filepath.Join(o.OutDir, rename.CanonicalFileName("v1", "Namespace", ns))
+	exists, err := files.FileExists(fileName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", fileName)
+	}
+	if exists {
+		return errors.Errorf("file %s already exists but does not contain the Namespace resource", fileName)
+	}
+
+	labels := map[string]string{}
+	if o.NameLabel != "" {
+		labels[o.NameLabel] = ns
+	}
+	for k, v := range o.labels {
+		labels[k] = v
+	}
+	namespace := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ns,
+			Labels: labels,
+		},
+	}
+	err = os.MkdirAll(o.OutDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", o.OutDir)
+	}
+	err = yamls.SaveFile(namespace, fileName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", fileName)
+	}
+	log.Logger().Debugf("no Namespace resource %s so created file %s", termcolor.ColorInfo(ns), termcolor.ColorInfo(fileName))
+	return nil
+}
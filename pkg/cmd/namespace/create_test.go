@@ -0,0 +1,97 @@
+package namespace_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/namespace"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateResources(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("test_data", "create"), tmpDir)
+	require.NoError(t, err, "failed to copy test data")
+
+	handMaintained := filepath.Join(tmpDir, "cluster", "monitoring-ns.yaml")
+	handMaintainedData, err := ioutil.ReadFile(handMaintained)
+	require.NoError(t, err, "failed to read %s", handMaintained)
+
+	_, o := namespace.NewCmdCreateResources()
+	o.Dir = tmpDir
+	o.Labels = []string{"team=apps", "istio-injection=enabled"}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, []string{"billing", "shop"}, o.Created, "created namespaces")
+
+	for _, ns := range o.Created {
+		path := filepath.Join(tmpDir, "namespaces", ns+"-ns.yaml")
+		resources, err := resourcehelpers.LoadFile(path)
+		require.NoError(t, err, "failed to load %s", path)
+		require.Len(t, resources, 1, "resources in %s", path)
+		u := resources[0]
+		assert.Equal(t, "Namespace", u.GetKind(), "kind in %s", path)
+		assert.Equal(t, ns, u.GetName(), "name in %s", path)
+		assert.Equal(t, map[string]string{"name": ns, "team": "apps", "istio-injection": "enabled"}, u.GetLabels(), "labels in %s", path)
+	}
+	assert.NoFileExists(t, filepath.Join(tmpDir, "namespaces", "default-ns.yaml"), "the default namespace should be skipped")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "namespaces", "monitoring-ns.yaml"), "the hand maintained namespace should not be duplicated")
+
+	generated := filepath.Join(tmpDir, "namespaces", "shop-ns.yaml")
+	generatedData, err := ioutil.ReadFile(generated)
+	require.NoError(t, err, "failed to read %s", generated)
+
+	// lets check running again does not create or modify any Namespace resources
+	_, o = namespace.NewCmdCreateResources()
+	o.Dir = tmpDir
+	o.Labels = []string{"team=other"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run again")
+	assert.Empty(t, o.Created, "created namespaces when run again")
+
+	data, err := ioutil.ReadFile(handMaintained)
+	require.NoError(t, err, "failed to read %s", handMaintained)
+	assert.Equal(t, string(handMaintainedData), string(data), "the hand maintained namespace should not be modified")
+
+	data, err = ioutil.ReadFile(generated)
+	require.NoError(t, err, "failed to read %s", generated)
+	assert.Equal(t, string(generatedData), string(data), "the generated namespace should not be modified")
+}
+
+func TestCreateResourcesDoesNotReplaceFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("test_data", "create"), tmpDir)
+	require.NoError(t, err, "failed to copy test data")
+
+	outDir := filepath.Join(tmpDir, "cluster")
+	existing := filepath.Join(outDir, "shop-ns.yaml")
+	err = ioutil.WriteFile(existing, []byte("# not a namespace\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write %s", existing)
+
+	_, o := namespace.NewCmdCreateResources()
+	o.Dir = tmpDir
+	o.OutDir = outDir
+	o.SkipNamespaces = nil
+
+	err = o.Run()
+	require.Error(t, err, "should have failed to replace %s", existing)
+
+	data, err := ioutil.ReadFile(existing)
+	require.NoError(t, err, "failed to read %s", existing)
+	assert.Equal(t, "# not a namespace\n", string(data), "the existing file should not be replaced")
+	assert.FileExists(t, filepath.Join(outDir, "default-ns.yaml"), "the default namespace should be created when not skipped")
+}
+
+func TestCreateResourcesInvalidLabel(t *testing.T) {
+	_, o := namespace.NewCmdCreateResources()
+	o.Dir = filepath.Join("test_data", "create")
+	o.Labels = []string{"team"}
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with an invalid label")
+}
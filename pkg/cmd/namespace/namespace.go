@@ -6,7 +6,11 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/watch"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
@@ -20,12 +24,15 @@ import (
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 var (
 	namespaceLong = templates.LongDesc(`
 		Updates all kubernetes resources in the given directory to the given namespace
+
+		Every document of the *.yaml and *.yml files is updated including the items of List resources. Cluster scoped resources such as Namespaces, ClusterRoles and CustomResourceDefinitions are skipped. The cluster scoped kinds can be changed via --cluster-scoped-kinds.
 `)
 
 	namespaceExample = templates.Examples(`
@@ -37,16 +44,52 @@ var (
 		# e.g. so that the files 'config-root/namespaces/cheese/*.yaml' get set to namespace 'cheese' 
 		# and 'config-root/namespaces/wine/*.yaml' are set to 'wine'
 		%s namespace --dir-mode --dir config-root/namespaces
+
+		# only sets the namespace of the resources which do not have a namespace
+		%s namespace -n jx-staging --only-missing
+
+		# sets the namespace again whenever the files in the directory change until interrupted
+		%s namespace -n dev --dir src --watch
 	`)
+
+	// DefaultClusterScopedKinds the kinds of the cluster scoped resources which are skipped by default which can
+	// include wildcards
+	DefaultClusterScopedKinds = []string{
+		"APIService",
+		"CSIDriver",
+		"CSINode",
+		"CertificateSigningRequest",
+		"Cluster*",
+		"ComponentStatus",
+		"CustomResourceDefinition",
+		"IngressClass",
+		"MutatingWebhookConfiguration",
+		"Namespace",
+		"Node",
+		"PersistentVolume",
+		"PodSecurityPolicy",
+		"PriorityClass",
+		"RuntimeClass",
+		"StorageClass",
+		"ValidatingWebhookConfiguration",
+		"VolumeAttachment",
+		"VolumeSnapshotClass",
+	}
 )
 
 // NamespaceOptions the options for the command
 type Options struct {
 	kyamls.Filter
-	Dir        string
-	ClusterDir string
-	Namespace  string
-	DirMode    bool
+	common.WalkOptions
+	Watch              watch.Options
+	Dir                string
+	ClusterDir         string
+	Namespace          string
+	DirMode            bool
+	ClusterScopedKinds []string
+	OnlyMissing        bool
+	Modified           int
+	Skipped            int
 }
 
 // NewCmdUpdate creates a command object for the command
@@ -58,9 +101,9 @@ func NewCmdUpdateNamespace() (*cobra.Command, *Options) {
 		Aliases: []string{"ns"},
 		Short:   "Updates all kubernetes resources in the given directory to the given namespace",
 		Long:    namespaceLong,
-		Example: fmt.Sprintf(namespaceExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Example: fmt.Sprintf(namespaceExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
-			err := o.Run()
+			err := o.Watch.Run([]string{o.Dir}, o.Run)
 			helper.CheckErr(err)
 		},
 	}
@@ -68,7 +111,12 @@ func NewCmdUpdateNamespace() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.ClusterDir, "cluster-dir", "", "", "the directory to recursively look for the *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace to modify the resources to")
 	cmd.Flags().BoolVarP(&o.DirMode, "dir-mode", "", false, "assumes the first child directory is the name of the namespace to use")
+	cmd.Flags().StringArrayVarP(&o.ClusterScopedKinds, "cluster-scoped-kinds", "", DefaultClusterScopedKinds, "the kinds of the cluster scoped resources to skip which can include wildcards such as 'Cluster*'")
+	cmd.Flags().BoolVarP(&o.OnlyMissing, "only-missing", "", false, "only sets the namespace of resources which do not have a namespace rather than replacing the existing namespace")
 	o.Filter.AddFlags(cmd)
+	o.WalkOptions.AddFlags(cmd)
+	o.Watch.AddFlags(cmd)
+	cmd.AddCommand(cobras.SplitCommand(NewCmdCreateResources()))
 	return cmd, o
 }
 
@@ -83,14 +131,24 @@ func (o *Options) Run() error {
 			return errors.Wrapf(err, "failed to create cluster namespaces dir %s", o.ClusterDir)
 		}
 	}
+	o.Modified = 0
+	o.Skipped = 0
 	if !o.DirMode {
 		if ns == "" {
 			return options.MissingOption("namespace")
 		}
-		return UpdateNamespaceInYamlFiles(o.Dir, ns, o.Filter)
+		err := o.UpdateNamespace(o.Dir, ns)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := o.RunDirMode()
+		if err != nil {
+			return err
+		}
 	}
-
-	return o.RunDirMode()
+	log.Logger().Infof("set the namespace of %d resources and skipped %d resources", o.Modified, o.Skipped)
+	return nil
 }
 
 func (o *Options) RunDirMode() error {
@@ -110,7 +168,7 @@ func (o *Options) RunDirMode() error {
 		name := f.Name()
 
 		dir := filepath.Join(o.Dir, name)
-		err = UpdateNamespaceInYamlFiles(dir, name, o.Filter)
+		err = o.UpdateNamespace(dir, name)
 		if err != nil {
 			return err
 		}
@@ -135,21 +193,17 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 
 	found := false
 
-	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		kind := kyamls.GetKind(node, path)
-		if kind == "Namespace" {
-			name := kyamls.GetName(node, path)
-			if name == ns {
-				found = true
-			}
+	visitFn := func(u *unstructured.Unstructured, path string) error {
+		if u.GetName() == ns {
+			found = true
 		}
-		return false, nil
+		return nil
 	}
 
-	filter := kyamls.Filter{
+	selector := resourcehelpers.Selector{
 		Kinds: []string{"Namespace"},
 	}
-	err := kyamls.ModifyFiles(dir, modifyFn, filter)
+	err := resourcehelpers.VisitFiles(dir, selector, visitFn)
 	if err != nil {
 		return errors.Wrapf(err, "failed to walk namespaces in dir %s", dir)
 	}
@@ -182,22 +236,38 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 
 // UpdateNamespaceInYamlFiles updates the namespace in yaml files
 func UpdateNamespaceInYamlFiles(dir string, ns string, filter kyamls.Filter) error {
-	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		kind := kyamls.GetKind(node, path)
+	o := &Options{
+		Filter: filter,
+	}
+	return o.UpdateNamespace(dir, ns)
+}
 
+// UpdateNamespace sets the namespace of the namespaced resources in the yaml files in the given directory
+func (o *Options) UpdateNamespace(dir string, ns string) error {
+	clusterScopedKinds := o.ClusterScopedKinds
+	if len(clusterScopedKinds) == 0 {
+		clusterScopedKinds = DefaultClusterScopedKinds
+	}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
 		// ignore common cluster based resources
-		if kyamls.IsClusterKind(kind) {
+		if resourcehelpers.MatchesAnyPattern(kyamls.GetKind(node, path), clusterScopedKinds) {
+			o.Skipped++
+			return false, nil
+		}
+		current := kyamls.GetNamespace(node, path)
+		if current == ns || (o.OnlyMissing && current != "") {
+			o.Skipped++
 			return false, nil
 		}
-
 		err := node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "metadata", "namespace"), yaml.FieldSetter{StringValue: ns})
 		if err != nil {
 			return false, errors.Wrapf(err, "failed to set metadata.namespace to %s", ns)
 		}
+		o.Modified++
 		return true, nil
 	}
 
-	err := kyamls.ModifyFiles(dir, modifyFn, filter)
+	err := resourcehelpers.ModifyFilteredNodes(dir, o.WalkOptions, o.Filter, modifyFn)
 	if err != nil {
 		return errors.Wrapf(err, "failed to modify namespace to %s in dir %s", ns, dir)
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/namespace"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
 	"github.com/pkg/errors"
@@ -150,3 +151,74 @@ func TestNamespaceDirMode(t *testing.T) {
 	}
 	assert.Len(t, found, 2, "found namespaces")
 }
+
+func TestNamespaceOnlyMissingAndClusterScopedKinds(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+	path := filepath.Join(tmpDir, "resources.yaml")
+	source := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cheese
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cheese-config
+    namespace: jx
+- apiVersion: scheduling.k8s.io/v1
+  kind: PriorityClass
+  metadata:
+    name: high-priority
+- apiVersion: cert-manager.io/v1
+  kind: ClusterIssuer
+  metadata:
+    name: letsencrypt
+`
+	err = ioutil.WriteFile(path, []byte(source), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", path)
+
+	_, o := namespace.NewCmdUpdateNamespace()
+	o.Dir = tmpDir
+	o.ClusterDir = filepath.Join(tmpDir, "cluster")
+	o.Namespace = "jx-staging"
+	o.OnlyMissing = true
+	err = o.Run()
+	require.NoError(t, err, "failed to set the missing namespaces")
+	assert.Equal(t, 1, o.Modified, "modified resources")
+	assert.Equal(t, 3, o.Skipped, "skipped resources")
+	assertNamespaces(t, path, map[string]string{
+		"ServiceAccount/cheese":       "jx-staging",
+		"ConfigMap/cheese-config":     "jx",
+		"PriorityClass/high-priority": "",
+		"ClusterIssuer/letsencrypt":   "",
+	})
+
+	// the cluster scoped kinds can be overridden
+	o.OnlyMissing = false
+	o.ClusterScopedKinds = []string{"PriorityClass"}
+	err = o.Run()
+	require.NoError(t, err, "failed to set the namespaces")
+	assert.Equal(t, 2, o.Modified, "modified resources")
+	assert.Equal(t, 2, o.Skipped, "skipped resources")
+	assertNamespaces(t, path, map[string]string{
+		"ServiceAccount/cheese":       "jx-staging",
+		"ConfigMap/cheese-config":     "jx-staging",
+		"PriorityClass/high-priority": "",
+		"ClusterIssuer/letsencrypt":   "jx-staging",
+	})
+}
+
+func assertNamespaces(t *testing.T, path string, expected map[string]string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	resources, _ = resourcehelpers.ExpandLists(resources)
+	actual := map[string]string{}
+	for _, u := range resources {
+		actual[u.GetKind()+"/"+u.GetName()] = u.GetNamespace()
+	}
+	assert.Equal(t, expected, actual, "namespaces in %s", path)
+}
@@ -0,0 +1,76 @@
+package patch
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	// directiveMarker the field of a map in a patch containing a directive
+	directiveMarker = "$patch"
+
+	// deleteDirective the directive to delete the map, list element or whole resource
+	deleteDirective = "delete"
+
+	// replaceDirective the directive to replace the map rather than merging it
+	replaceDirective = "replace"
+)
+
+// IsDeletePatch returns true if the patch deletes the whole resource
+func IsDeletePatch(patch *unstructured.Unstructured) bool {
+	return patch.Object[directiveMarker] == deleteDirective
+}
+
+// MergePatch applies the patch to the resource returning the patched object. Kinds which are known to the
+// kubernetes client use a strategic merge so that lists such as containers and ports are merged by their merge
+// keys. Other kinds such as custom resources are deep merged where lists are replaced
+func MergePatch(u, patch *unstructured.Unstructured) (map[string]interface{}, error) {
+	original := u.DeepCopy().Object
+	patchObj := patch.DeepCopy().Object
+
+	dataStruct, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		if !runtime.IsNotRegisteredError(err) {
+			return nil, errors.Wrapf(err, "failed to find the schema of %s", u.GroupVersionKind().String())
+		}
+		return deepMerge(original, patchObj), nil
+	}
+	answer, err := strategicpatch.StrategicMergeMapPatch(original, patchObj, dataStruct)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to apply strategic merge patch")
+	}
+	return answer, nil
+}
+
+// deepMerge merges the patch into the object like a JSON merge patch where a null value removes the field and
+// lists are replaced. A map with a '$patch: delete' directive removes the field and '$patch: replace' replaces it
+func deepMerge(dst, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if k == directiveMarker {
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if v == nil || (ok && m[directiveMarker] == deleteDirective) {
+			delete(dst, k)
+			continue
+		}
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		if m[directiveMarker] == replaceDirective {
+			delete(m, directiveMarker)
+			dst[k] = m
+			continue
+		}
+		d, ok := dst[k].(map[string]interface{})
+		if !ok {
+			d = map[string]interface{}{}
+		}
+		dst[k] = deepMerge(d, m)
+	}
+	return dst
+}
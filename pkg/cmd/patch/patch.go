@@ -0,0 +1,359 @@
+package patch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Applies the patches in the patches directory to the kubernetes resources in the given directory tree
+
+		Each document in the YAML files of the --patches-dir is a patch which declares the apiVersion, kind, metadata.name and optionally metadata.namespace of the resource it targets along with the fields to merge into it. If the namespace is omitted the patch matches the resource with the name in any namespace. This lets local modifications be kept alongside generated resources and reapplied each time they are regenerated.
+
+		Kinds known to kubernetes are patched using a strategic merge so that lists such as containers, environment variables and ports are merged by their merge keys. Other kinds such as custom resources are deep merged where lists are replaced and a null value removes a field. A '$patch: delete' directive removes a list element or map, or the whole resource if it is at the top level of the patch.
+
+		The command fails without modifying any files if any patch does not match a resource. Use --dry-run to display the differences each patch would make without modifying any files.
+`)
+
+	cmdExample = templates.Examples(`
+		# applies the patches in the patches directory to the generated resources
+		%s patch --dir config-root --patches-dir patches
+
+		# displays the differences the patches would make
+		%s patch --dir config-root --patches-dir patches --dry-run
+	`)
+)
+
+// Result a resource which was patched
+type Result struct {
+	// Patch the file containing the patch relative to the patches directory
+	Patch string
+
+	// Resource the key of the resource
+	Resource string
+
+	// File the file containing the resource relative to the directory
+	File string
+
+	// Deleted the resource was deleted by a '$patch: delete' directive
+	Deleted bool
+}
+
+// Options the options for the command
+type Options struct {
+	common.WalkOptions
+	Dir        string
+	PatchesDir string
+	DryRun     bool
+	Out        io.Writer
+	Results    []Result
+}
+
+// patchDocument a patch loaded from the patches directory
+type patchDocument struct {
+	u       *unstructured.Unstructured
+	file    string
+	matches []string
+}
+
+// fileChange the resources of a file before and after the patches are applied
+type fileChange struct {
+	path   string
+	before []*unstructured.Unstructured
+	after  []*unstructured.Unstructured
+}
+
+// NewCmdPatch creates a command object for the command
+func NewCmdPatch() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "patch",
+		Short:   "Applies the patches in the patches directory to the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files to patch")
+	cmd.Flags().StringVarP(&o.PatchesDir, "patches-dir", "p", "patches", "the directory to recursively look for the *.yaml or *.yml patch files")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "displays the differences the patches would make without modifying any files")
+	o.WalkOptions.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	if o.PatchesDir == "" {
+		o.PatchesDir = "patches"
+	}
+	exists, err := files.DirExists(o.PatchesDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", o.PatchesDir)
+	}
+	if !exists {
+		return errors.Errorf("the --patches-dir %s does not exist", o.PatchesDir)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	o.Results = nil
+
+	patches, err := o.loadPatches()
+	if err != nil {
+		return err
+	}
+	if len(patches) == 0 {
+		log.Logger().Infof("no patches found in dir %s", o.PatchesDir)
+		return nil
+	}
+
+	changes, err := o.applyPatches(patches)
+	if err != nil {
+		return err
+	}
+
+	var missing, ambiguous []string
+	for _, p := range patches {
+		switch len(p.matches) {
+		case 0:
+			missing = append(missing, fmt.Sprintf("%s (%s)", p.file, resourcehelpers.ResourceKey(p.u)))
+		case 1:
+		default:
+			ambiguous = append(ambiguous, fmt.Sprintf("%s matches %s", p.file, strings.Join(p.matches, ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("no resources in dir %s match the patches: %s", o.Dir, strings.Join(missing, ", "))
+	}
+	if len(ambiguous) > 0 {
+		return errors.Errorf("patches match multiple resources so specify their metadata.namespace: %s", strings.Join(ambiguous, "; "))
+	}
+
+	verb := "patched"
+	if o.DryRun {
+		verb = "would patch"
+	}
+	for _, r := range o.Results {
+		if r.Deleted {
+			log.Logger().Infof("%s %s by deleting it from file %s with %s", verb, info(r.Resource), r.File, r.Patch)
+			continue
+		}
+		log.Logger().Infof("%s %s in file %s with %s", verb, info(r.Resource), r.File, r.Patch)
+	}
+
+	if o.DryRun {
+		for _, c := range changes {
+			err = o.printDiff(c)
+			if err != nil {
+				return err
+			}
+		}
+		log.Logger().Infof("would modify %d files", len(changes))
+		return nil
+	}
+	for _, c := range changes {
+		if len(c.after) == 0 {
+			err = os.Remove(c.path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", c.path)
+			}
+			log.Logger().Infof("deleted empty file %s", info(o.relPath(o.Dir, c.path)))
+			continue
+		}
+		err = resourcehelpers.SaveFile(c.after, c.path)
+		if err != nil {
+			return err
+		}
+	}
+	log.Logger().Infof("modified %d files", len(changes))
+	return nil
+}
+
+// loadPatches loads the patch documents in the patches directory
+func (o *Options) loadPatches() ([]*patchDocument, error) {
+	var answer []*patchDocument
+	err := resourcehelpers.WalkYAMLFiles(o.PatchesDir, common.WalkOptions{}, func(path, rel string, _ os.FileInfo) error {
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for i, u := range resources {
+			if !resourcehelpers.IsResource(u) || u.GetName() == "" {
+				return errors.Errorf("document %d of patch file %s does not declare the apiVersion, kind and metadata.name of the resource to patch", i+1, rel)
+			}
+			answer = append(answer, &patchDocument{u: u, file: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the patches in dir %s", o.PatchesDir)
+	}
+	return answer, nil
+}
+
+// applyPatches applies the patches to the resources in memory returning the files which are changed
+func (o *Options) applyPatches(patches []*patchDocument) ([]*fileChange, error) {
+	patchesDir, err := filepath.Abs(o.PatchesDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find abs dir of %s", o.PatchesDir)
+	}
+
+	var answer []*fileChange
+	err = resourcehelpers.WalkYAMLFiles(o.Dir, o.WalkOptions, func(path, rel string, _ os.FileInfo) error {
+		// lets not patch the patches if they are inside the directory
+		if abs, err := filepath.Abs(path); err == nil && strings.HasPrefix(abs, patchesDir+string(os.PathSeparator)) {
+			return nil
+		}
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		modified := false
+		var after []*unstructured.Unstructured
+		for _, u := range resources {
+			patched, deleted, err := o.patchResource(u, rel, patches)
+			if err != nil {
+				return errors.Wrapf(err, "failed to patch %s %s in file %s", u.GetKind(), u.GetName(), path)
+			}
+			if deleted {
+				modified = true
+				continue
+			}
+			if !reflect.DeepEqual(patched.Object, u.Object) {
+				modified = true
+			}
+			after = append(after, patched)
+		}
+		if modified {
+			answer = append(answer, &fileChange{path: path, before: resources, after: after})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to patch the files in dir %s", o.Dir)
+	}
+	return answer, nil
+}
+
+// patchResource applies the matching patches to the resource in order returning the patched resource or true if
+// the resource is deleted
+func (o *Options) patchResource(u *unstructured.Unstructured, file string, patches []*patchDocument) (*unstructured.Unstructured, bool, error) {
+	if !resourcehelpers.IsResource(u) {
+		return u, false, nil
+	}
+	key := resourcehelpers.ResourceKey(u)
+	answer := u
+	deleted := false
+	for _, p := range patches {
+		if !matches(p.u, u) {
+			continue
+		}
+		p.matches = append(p.matches, file+" "+key)
+		if deleted {
+			// the other patches of a deleted resource still match so that they are not reported as missing
+			continue
+		}
+		deleted = IsDeletePatch(p.u)
+		o.Results = append(o.Results, Result{
+			Patch:    p.file,
+			Resource: key,
+			File:     file,
+			Deleted:  deleted,
+		})
+		if deleted {
+			continue
+		}
+		obj, err := MergePatch(answer, p.u)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to apply patch %s", p.file)
+		}
+		answer = &unstructured.Unstructured{Object: obj}
+	}
+	return answer, deleted, nil
+}
+
+// matches returns true if the patch targets the resource. A patch without a namespace matches any namespace
+func matches(patch, u *unstructured.Unstructured) bool {
+	if patch.GetAPIVersion() != u.GetAPIVersion() || patch.GetKind() != u.GetKind() || patch.GetName() != u.GetName() {
+		return false
+	}
+	ns := patch.GetNamespace()
+	return ns == "" || ns == u.GetNamespace()
+}
+
+func (o *Options) printDiff(c *fileChange) error {
+	before, err := resourcehelpers.ToYAML(c.before)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal file %s", c.path)
+	}
+	after, err := resourcehelpers.ToYAML(c.after)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the patched file %s", c.path)
+	}
+	name := o.relPath(o.Dir, c.path)
+	ud := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	}
+	if len(c.after) == 0 {
+		ud.B = nil
+		ud.ToFile = "/dev/null"
+	}
+	text, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return errors.Wrapf(err, "failed to diff file %s", name)
+	}
+	_, err = io.WriteString(o.Out, text)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the diff of %s", name)
+	}
+	return nil
+}
+
+func (o *Options) relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,174 @@
+package patch_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/patch"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPatch(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := patch.NewCmdPatch()
+	o.Dir = tmpDir
+	o.PatchesDir = filepath.Join("test_data", "patches")
+	err := o.Run()
+	require.NoError(t, err, "failed to patch the resources")
+
+	assert.Equal(t, []patch.Result{
+		{Patch: "apps/web.yaml", Resource: "jx/Deployment/web", File: "apps/web.yaml"},
+		{Patch: "certificate.yaml", Resource: "jx/Certificate/tls", File: "certificate.yaml"},
+		{Patch: "configmaps.yaml", Resource: "jx/ConfigMap/settings", File: "configmaps.yaml"},
+		{Patch: "configmaps.yaml", Resource: "jx/ConfigMap/legacy", File: "configmaps.yaml", Deleted: true},
+	}, o.Results, "results")
+
+	// the containers are merged by name and their ports by containerPort whereas the args are replaced
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-web.yaml"), filepath.Join(tmpDir, "apps", "web.yaml"))
+
+	// the lists of custom resources are replaced
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-certificate.yaml"), filepath.Join(tmpDir, "certificate.yaml"))
+
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-configmaps.yaml"), filepath.Join(tmpDir, "configmaps.yaml"))
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", "apps", "service.yaml"), filepath.Join(tmpDir, "apps", "service.yaml"))
+}
+
+func TestPatchDryRun(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	out := &bytes.Buffer{}
+	_, o := patch.NewCmdPatch()
+	o.Dir = tmpDir
+	o.PatchesDir = filepath.Join("test_data", "patches")
+	o.DryRun = true
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to patch the resources")
+
+	assert.Len(t, o.Results, 4, "results")
+	text := out.String()
+	t.Logf("got diff:\n%s", text)
+	assert.Contains(t, text, "--- a/apps/web.yaml\n+++ b/apps/web.yaml\n", "diff")
+	assert.Contains(t, text, "-  replicas: 1\n+  replicas: 3\n", "diff")
+	assert.Contains(t, text, "-    name: letsencrypt-staging\n+    name: letsencrypt-prod\n", "diff")
+	assert.NotContains(t, text, "service.yaml", "diff")
+
+	for _, name := range []string{"certificate.yaml", "configmaps.yaml", filepath.Join("apps", "web.yaml")} {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "src", name), filepath.Join(tmpDir, name))
+	}
+}
+
+func TestPatchMissingTarget(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := patch.NewCmdPatch()
+	o.Dir = tmpDir
+	o.PatchesDir = filepath.Join("test_data", "missing-patches")
+	err := o.Run()
+	require.Error(t, err, "should fail if a patch does not match a resource")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), "missing.yaml", "error should contain the patch file")
+}
+
+func TestMergePatch(t *testing.T) {
+	service := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name": "web",
+			},
+			"spec": map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"name": "http", "port": int64(80)},
+					map[string]interface{}{"name": "https", "port": int64(443)},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		u        *unstructured.Unstructured
+		patch    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name: "merge-ports-by-port",
+			u:    service,
+			patch: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"ports": []interface{}{
+						map[string]interface{}{"port": int64(443), "targetPort": int64(8443)},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"name": "http", "port": int64(80)},
+					map[string]interface{}{"name": "https", "port": int64(443), "targetPort": int64(8443)},
+				},
+			},
+		},
+		{
+			name: "delete-port",
+			u:    service,
+			patch: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"ports": []interface{}{
+						map[string]interface{}{"port": int64(80), "$patch": "delete"},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"name": "https", "port": int64(443)},
+				},
+			},
+		},
+		{
+			name: "custom-resource-replaces-lists",
+			u: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "Widget",
+					"metadata": map[string]interface{}{
+						"name": "thing",
+					},
+					"spec": map[string]interface{}{
+						"ports": []interface{}{
+							map[string]interface{}{"name": "http", "port": int64(80)},
+						},
+						"size":  "large",
+						"extra": map[string]interface{}{"a": "b"},
+					},
+				},
+			},
+			patch: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"ports": []interface{}{
+						map[string]interface{}{"port": int64(443)},
+					},
+					"size":  nil,
+					"extra": map[string]interface{}{"$patch": "delete"},
+				},
+			},
+			expected: map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"port": int64(443)},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		obj, err := patch.MergePatch(tc.u, &unstructured.Unstructured{Object: tc.patch})
+		require.NoError(t, err, "failed to merge patch for %s", tc.name)
+		assert.Equal(t, tc.expected, obj["spec"], "spec for %s", tc.name)
+	}
+}
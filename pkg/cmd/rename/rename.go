@@ -4,36 +4,64 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/split"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 var (
 	splitLong = templates.LongDesc(`
 		Renames yaml files to use canonical file names based on the resource name and kind
+
+		Each file containing a single resource is renamed to '<name>-<kind>.yaml' where the kind is abbreviated such as 'deploy' or 'svc'. Additional abbreviations can be specified via --kind-abbreviation. Any characters which are not valid in file names are replaced with '-'. If the file name is already used then the namespace or an index is appended.
+
+		Files containing multiple resources are skipped unless --split-first is specified which splits them into separate files first. Files are not split with --dry-run.
 `)
 
 	splitExample = templates.Examples(`
 		# renames files to use a canonical file name
 		%s rename --dir .
+
+		# lists the files which would be renamed without renaming them
+		%s rename --dir . --dry-run
+
+		# splits the files containing multiple resources then renames them abbreviating StatefulSet to sts
+		%s rename --split-first --kind-abbreviation StatefulSet=sts
 	`)
 
-	// resourcesSeparator is used to separate multiple objects stored in the same YAML file
-	resourcesSeparator = "---\n"
+	// invalidFilenameChars the characters which are not valid in file names
+	invalidFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
 )
 
 // Options the options for the command
 type Options struct {
-	Dir     string
-	Verbose bool
+	Dir               string
+	Verbose           bool
+	DryRun            bool
+	SplitFirst        bool
+	KindAbbreviations []string
+	Changes           []Change
+	abbreviations     map[string]string
+}
+
+// Change a file which is renamed
+type Change struct {
+	// From the original path of the file
+	From string
+
+	// To the new path of the file
+	To string
 }
 
 // NewCmdRename creates a command object for the command
@@ -44,66 +72,144 @@ func NewCmdRename() (*cobra.Command, *Options) {
 		Use:     "rename",
 		Short:   "Renames yaml files to use canonical file names based on the resource name and kind",
 		Long:    splitLong,
-		Example: fmt.Sprintf(splitExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(splitExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "lists the files which would be renamed without renaming them")
+	cmd.Flags().BoolVarP(&o.SplitFirst, "split-first", "", false, "splits any files containing multiple resources into separate files before renaming them")
+	cmd.Flags().StringArrayVarP(&o.KindAbbreviations, "kind-abbreviation", "", nil, "the abbreviation of a kind used in the file names of the form 'Kind=abbreviation' which overrides the built in abbreviations")
 	return cmd, o
 }
 
+// Validate validates the options
+func (o *Options) Validate() error {
+	o.abbreviations = map[string]string{}
+	for k, v := range kindSuffixes {
+		o.abbreviations[k] = v
+	}
+	for _, text := range o.KindAbbreviations {
+		idx := strings.Index(text, "=")
+		if idx <= 0 || idx == len(text)-1 {
+			return options.InvalidOptionf("kind-abbreviation", text, "should be of the form 'Kind=abbreviation'")
+		}
+		o.abbreviations[strings.ToLower(text[:idx])] = text[idx+1:]
+	}
+	return nil
+}
+
 // Run implements the command
 func (o *Options) Run() error {
-	err := filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
-		if info == nil || info.IsDir() {
-			return nil
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	if o.SplitFirst && !o.DryRun {
+		err = split.ProcessYamlFiles(o.Dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to split the YAML files in dir %s", o.Dir)
 		}
-		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+	}
+
+	// lets find the files first so that the renamed files are not processed again
+	var paths []string
+	err = filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
 			return nil
 		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the YAML files in dir %s", o.Dir)
+	}
 
-		node, err := yaml.ReadFile(path)
+	o.Changes = nil
+	taken := map[string]bool{}
+	vacated := map[string]bool{}
+	for _, path := range paths {
+		newPath, err := o.renamePath(path, taken, vacated)
 		if err != nil {
-			return errors.Wrapf(err, "failed to load file %s", path)
+			return errors.Wrapf(err, "failed to rename YAML files in dir %s", o.Dir)
 		}
-
-		name := kyamls.GetName(node, path)
-		if name == "" {
-			log.Logger().Warnf("no name for file %s so ignoring", path)
-			return nil
+		if newPath == "" || newPath == path {
+			continue
 		}
+		file := filepath.Base(path)
+		newFile := filepath.Base(newPath)
+		o.Changes = append(o.Changes, Change{From: path, To: newPath})
+		vacated[path] = true
+		if o.DryRun {
+			log.Logger().Infof("would rename %s => %s", path, newPath)
+			continue
+		}
+		if o.Verbose {
+			log.Logger().Infof("renaming %s => %s", file, newFile)
+		} else {
+			log.Logger().Debugf("renaming %s => %s", file, newFile)
+		}
+		err = os.Rename(path, newPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to rename %s to %s", file, newFile)
+		}
+	}
+	if o.DryRun {
+		log.Logger().Infof("would rename %d files", len(o.Changes))
+	}
+	return nil
+}
 
-		kind := kyamls.GetKind(node, path)
-		apiVersion := kyamls.GetAPIVersion(node, path)
-
-		dir, file := filepath.Split(path)
-		ext := filepath.Ext(path)
-
-		cn := o.canonicalName(apiVersion, kind, name)
-
-		newFile := cn + ext
-		newPath := filepath.Join(dir, newFile)
+// renamePath returns the canonical path of the file or blank if the file should not be renamed
+func (o *Options) renamePath(path string, taken, vacated map[string]bool) (string, error) {
+	resources, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(resources) == 0 {
+		return "", nil
+	}
+	if len(resources) > 1 {
+		log.Logger().Warnf("file %s contains %d resources so ignoring. Use --split-first to split it first", path, len(resources))
+		return "", nil
+	}
+	u := resources[0]
+	name := u.GetName()
+	if name == "" {
+		log.Logger().Warnf("no name for file %s so ignoring", path)
+		return "", nil
+	}
 
-		if newPath != path {
-			if o.Verbose {
-				log.Logger().Infof("renaming %s => %s", file, newFile)
-			} else {
-				log.Logger().Debugf("renaming %s => %s", file, newFile)
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	cn := invalidFilenameChars.ReplaceAllString(o.canonicalName(u.GetAPIVersion(), u.GetKind(), name), "-")
+	candidates := []string{cn + ext}
+	if ns := u.GetNamespace(); ns != "" {
+		candidates = append(candidates, cn+"-"+invalidFilenameChars.ReplaceAllString(ns, "-")+ext)
+	}
+	for i := 2; ; i++ {
+		for _, candidate := range candidates {
+			newPath := filepath.Join(dir, candidate)
+			if newPath == path {
+				taken[newPath] = true
+				return newPath, nil
 			}
-			err = os.Rename(path, newPath)
+			if taken[newPath] {
+				continue
+			}
+			exists, err := files.FileExists(newPath)
 			if err != nil {
-				return errors.Wrapf(err, "failed to rename %s to %s", file, newFile)
+				return "", errors.Wrapf(err, "failed to check if file exists %s", newPath)
+			}
+			if !exists || vacated[newPath] {
+				taken[newPath] = true
+				return newPath, nil
 			}
-
 		}
-		return nil
-	})
-	if err != nil {
-		return errors.Wrapf(err, "failed to rename YAML files in dir %s", o.Dir)
+		candidates = []string{cn + "-" + strconv.Itoa(i) + ext}
 	}
-	return nil
 }
 
 var (
@@ -123,7 +229,7 @@ var (
 
 func (o *Options) canonicalName(apiVersion, kind, name string) string {
 	lk := strings.ToLower(kind)
-	suffix := kindSuffixes[lk]
+	suffix := o.abbreviations[lk]
 	if suffix == "svc" && strings.Contains(apiVersion, "knative") {
 		suffix = "ksvc"
 	}
@@ -135,3 +241,10 @@ func (o *Options) canonicalName(apiVersion, kind, name string) string {
 	}
 	return name + "-" + suffix
 }
+
+// CanonicalFileName returns the canonical file name of a resource using the built in kind abbreviations
+// such as 'myapp-deploy.yaml'
+func CanonicalFileName(apiVersion, kind, name string) string {
+	o := &Options{abbreviations: kindSuffixes}
+	return invalidFilenameChars.ReplaceAllString(o.canonicalName(apiVersion, kind, name), "-") + ".yaml"
+}
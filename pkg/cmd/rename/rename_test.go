@@ -40,3 +40,71 @@ func TestRenameYamlFiles(t *testing.T) {
 		assert.FileExists(t, filepath.Join(tmpDir, f))
 	}
 }
+
+func TestRenameCollisionsAndDryRun(t *testing.T) {
+	srcDir := filepath.Join("test_data", "collisions")
+	require.DirExists(t, srcDir)
+
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcDir, tmpDir)
+
+	source := fileNames(t, tmpDir)
+
+	_, o := rename.NewCmdRename()
+	o.Dir = tmpDir
+	o.DryRun = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run in dir %s", tmpDir)
+
+	expected := []rename.Change{
+		{From: "other.yaml", To: "foo-svc.yaml"},
+		{From: "templates_clusterrole.yaml", To: "system-foo-clusterrole.yaml"},
+		{From: "templates_service.yaml", To: "foo-svc-jx.yaml"},
+		{From: "templates_service2.yaml", To: "foo-svc-staging.yaml"},
+		{From: "zz.yaml", To: "foo-svc-2.yaml"},
+	}
+	for i := range expected {
+		expected[i].From = filepath.Join(tmpDir, expected[i].From)
+		expected[i].To = filepath.Join(tmpDir, expected[i].To)
+	}
+	assert.Equal(t, expected, o.Changes, "changes of the dry run")
+	assert.Equal(t, source, fileNames(t, tmpDir), "the dry run should not rename any files")
+
+	o.DryRun = false
+	err = o.Run()
+	require.NoError(t, err, "failed to run in dir %s", tmpDir)
+	assert.Equal(t, expected, o.Changes, "changes")
+
+	renamed := fileNames(t, tmpDir)
+	assert.Equal(t, []string{"foo-svc-2.yaml", "foo-svc-jx.yaml", "foo-svc-staging.yaml", "foo-svc.yaml", "system-foo-clusterrole.yaml", "templates_multi.yaml"}, renamed, "renamed files")
+
+	// running again should not rename any files
+	err = o.Run()
+	require.NoError(t, err, "failed to run again in dir %s", tmpDir)
+	assert.Empty(t, o.Changes, "changes of the second run")
+	assert.Equal(t, renamed, fileNames(t, tmpDir), "files after the second run")
+
+	// now lets split the file containing multiple resources
+	o.SplitFirst = true
+	o.KindAbbreviations = []string{"StatefulSet=sts"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run with --split-first in dir %s", tmpDir)
+	assert.FileExists(t, filepath.Join(tmpDir, "db-config-cm.yaml"))
+	assert.FileExists(t, filepath.Join(tmpDir, "db-sts.yaml"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "templates_multi.yaml"))
+
+	o.KindAbbreviations = []string{"StatefulSet"}
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid kind abbreviation")
+}
+
+func fileNames(t *testing.T, dir string) []string {
+	fs, err := ioutil.ReadDir(dir)
+	require.NoError(t, err, "failed to read dir %s", dir)
+	var answer []string
+	for _, f := range fs {
+		answer = append(answer, f.Name())
+	}
+	return answer
+}
@@ -0,0 +1,207 @@
+package addenv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds environment variables to the containers of the workloads in the given directory tree
+
+		The variables are added to the containers of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector. Existing variables with the same name are updated unless --no-overwrite is specified.
+`)
+
+	cmdExample = templates.Examples(`
+		# adds an environment variable to all the containers in the staging namespace
+		%s resources add-env --dir config-root/namespaces/jx-staging --env ENVIRONMENT=staging
+
+		# adds environment variables to the containers and init containers of the matching deployments
+		%s resources add-env --kind Deployment --selector tier=web --env LOG_LEVEL=debug --env TRACING=true --init-containers
+	`)
+)
+
+// EnvVar an environment variable to add
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir            string
+	Env            []string
+	Containers     []string
+	InitContainers bool
+	NoOverwrite    bool
+	EnvVars        []EnvVar
+	Added          int
+	Updated        int
+	Files          []string
+}
+
+// NewCmdAddEnv creates a command object for the command
+func NewCmdAddEnv() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "add-env",
+		Short:   "Adds environment variables to the containers of the workloads in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Env, "env", "e", nil, "the environment variables to add in the format KEY=VALUE")
+	cmd.Flags().StringArrayVarP(&o.Containers, "container", "c", nil, "the names of the containers to modify. If not specified all the containers are modified")
+	cmd.Flags().BoolVarP(&o.InitContainers, "init-containers", "", false, "also adds the environment variables to the init containers")
+	cmd.Flags().BoolVarP(&o.NoOverwrite, "no-overwrite", "", false, "does not update the existing environment variables with the same name")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if len(o.Env) == 0 {
+		return errors.Errorf("missing option: --env")
+	}
+	o.EnvVars = nil
+	for _, text := range o.Env {
+		idx := strings.Index(text, "=")
+		if idx <= 0 {
+			return errors.Errorf("invalid --env value '%s' should be of the form KEY=VALUE", text)
+		}
+		o.EnvVars = append(o.EnvVars, EnvVar{Name: text[:idx], Value: text[idx+1:]})
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Added = 0
+	o.Updated = 0
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified, err := o.addEnv(u)
+		if err != nil || !modified {
+			return false, err
+		}
+		if len(o.Files) == 0 || o.Files[len(o.Files)-1] != path {
+			o.Files = append(o.Files, path)
+		}
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add environment variables to resources in dir %s", o.Dir)
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("added %d and updated %d environment variables", o.Added, o.Updated)
+	return nil
+}
+
+// addEnv adds the environment variables to the containers of the pod spec of the resource
+func (o *Options) addEnv(u *unstructured.Unstructured) (bool, error) {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return false, nil
+	}
+	fields := []string{"containers"}
+	if o.InitContainers {
+		fields = append(fields, "initContainers")
+	}
+	modified := false
+	for _, field := range fields {
+		path := append(append([]string{}, podSpecPath...), field)
+		containers, found, err := unstructured.NestedSlice(u.Object, path...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get %s of %s", strings.Join(path, "."), resourcehelpers.ResourceKey(u))
+		}
+		if !found {
+			continue
+		}
+		changed := false
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			if len(o.Containers) > 0 && stringhelpers.StringArrayIndex(o.Containers, name) < 0 {
+				continue
+			}
+			if o.upsertEnv(container, fmt.Sprintf("%s container %s", resourcehelpers.ResourceKey(u), name)) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		err = unstructured.SetNestedSlice(u.Object, containers, path...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set %s of %s", strings.Join(path, "."), resourcehelpers.ResourceKey(u))
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+// upsertEnv adds or updates the environment variables of the container returning true if it was modified
+func (o *Options) upsertEnv(container map[string]interface{}, description string) bool {
+	env, _ := container["env"].([]interface{})
+	modified := false
+	for _, ev := range o.EnvVars {
+		idx := -1
+		for i, e := range env {
+			m, ok := e.(map[string]interface{})
+			if ok && m["name"] == ev.Name {
+				idx = i
+				break
+			}
+		}
+		entry := map[string]interface{}{"name": ev.Name, "value": ev.Value}
+		if idx < 0 {
+			env = append(env, entry)
+			o.Added++
+			modified = true
+			log.Logger().Debugf("added %s to %s", ev.Name, description)
+			continue
+		}
+		existing := env[idx].(map[string]interface{})
+		if o.NoOverwrite || (existing["value"] == ev.Value && existing["valueFrom"] == nil) {
+			continue
+		}
+		// lets replace the entry so that any valueFrom is removed
+		env[idx] = entry
+		o.Updated++
+		modified = true
+		log.Logger().Debugf("updated %s of %s", ev.Name, description)
+	}
+	if modified {
+		container["env"] = env
+	}
+	return modified
+}
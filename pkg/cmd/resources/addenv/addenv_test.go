@@ -0,0 +1,106 @@
+package addenv_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addenv"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddEnv(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	cronJobFile := filepath.Join(tmpDir, "cronjob.yaml")
+
+	_, o := addenv.NewCmdAddEnv()
+	o.Dir = tmpDir
+	o.Env = []string{"ENVIRONMENT=staging", "LOG_LEVEL=debug"}
+	err := o.Run()
+	require.NoError(t, err, "failed to add env")
+
+	assert.Equal(t, []string{cronJobFile, deployFile}, o.Files, "modified files")
+	assert.Equal(t, 4, o.Added, "added env vars")
+	assert.Equal(t, 2, o.Updated, "updated env vars")
+
+	containers := loadContainers(t, deployFile, "spec", "template", "spec", "containers")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+		map[string]interface{}{"name": "ENVIRONMENT", "value": "staging"},
+	}, containers[0]["env"], "env of the web container")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "ENVIRONMENT", "value": "staging"},
+		map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+	}, containers[1]["env"], "env of the proxy container")
+
+	initContainers := loadContainers(t, deployFile, "spec", "template", "spec", "initContainers")
+	assert.Nil(t, initContainers[0]["env"], "init containers should not be modified by default")
+
+	containers = loadContainers(t, cronJobFile, "spec", "jobTemplate", "spec", "template", "spec", "containers")
+	assert.Len(t, containers[0]["env"], 2, "env of the cron job container")
+
+	// adding the same values again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to add env again")
+	assert.Empty(t, o.Files, "modified files on second run")
+	assert.Equal(t, 0, o.Added+o.Updated, "changed env vars on second run")
+}
+
+func TestAddEnvNoOverwriteInitContainers(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+
+	_, o := addenv.NewCmdAddEnv()
+	o.Dir = tmpDir
+	o.Env = []string{"LOG_LEVEL=debug", "TRACING=true"}
+	o.Kinds = []string{"Deployment"}
+	o.Containers = []string{"web", "migrate"}
+	o.InitContainers = true
+	o.NoOverwrite = true
+	err := o.Run()
+	require.NoError(t, err, "failed to add env")
+
+	assert.Equal(t, []string{deployFile}, o.Files, "modified files")
+	assert.Equal(t, 3, o.Added, "added env vars")
+	assert.Equal(t, 0, o.Updated, "updated env vars")
+
+	containers := loadContainers(t, deployFile, "spec", "template", "spec", "containers")
+	env := containers[0]["env"].([]interface{})
+	require.Len(t, env, 3, "env of the web container")
+	assert.Equal(t, map[string]interface{}{"name": "LOG_LEVEL", "value": "info"}, env[0], "existing env var should not be overwritten")
+	assert.Equal(t, map[string]interface{}{"name": "TRACING", "value": "true"}, env[2], "added env var")
+	assert.Nil(t, containers[1]["env"], "the proxy container does not match")
+
+	initContainers := loadContainers(t, deployFile, "spec", "template", "spec", "initContainers")
+	assert.Len(t, initContainers[0]["env"], 2, "env of the init container")
+}
+
+func TestAddEnvInvalid(t *testing.T) {
+	for _, env := range [][]string{nil, {"NOVALUE"}, {"=value"}} {
+		_, o := addenv.NewCmdAddEnv()
+		o.Dir = "test_data"
+		o.Env = env
+		err := o.Run()
+		assert.Error(t, err, "should fail for --env %v", env)
+	}
+}
+
+func loadContainers(t *testing.T, path string, fields ...string) []map[string]interface{} {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, 1, "resources in %s", path)
+
+	values, found, err := unstructured.NestedSlice(resources[0].Object, fields...)
+	require.NoError(t, err, "failed to get containers of %s", path)
+	require.True(t, found, "no containers in %s", path)
+
+	var answer []map[string]interface{}
+	for _, v := range values {
+		answer = append(answer, v.(map[string]interface{}))
+	}
+	return answer
+}
@@ -0,0 +1,140 @@
+package addownerref
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds an owner reference to all the kubernetes resources in the given directory tree which match the selector
+
+		Resources which already have an identical owner reference are not modified.
+`)
+
+	cmdExample = templates.Examples(`
+		# adds an owner reference to an Application to all the resources in the current directory
+		%s resources add-owner-ref --owner-apiversion argoproj.io/v1alpha1 --owner-kind Application --owner-name myapp --owner-uid 1234-5678
+
+		# adds an owner reference to the Deployments only
+		%s resources add-owner-ref --kind Deployment --owner-apiversion argoproj.io/v1alpha1 --owner-kind Application --owner-name myapp --owner-uid 1234-5678
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir                string
+	OwnerAPIVersion    string
+	OwnerKind          string
+	OwnerName          string
+	OwnerUID           string
+	Controller         bool
+	BlockOwnerDeletion bool
+	Count              int
+}
+
+// NewCmdAddOwnerRef creates a command object for the command
+func NewCmdAddOwnerRef() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "add-owner-ref",
+		Short:   "Adds an owner reference to all the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.OwnerAPIVersion, "owner-apiversion", "", "", "the apiVersion of the owner resource")
+	cmd.Flags().StringVarP(&o.OwnerKind, "owner-kind", "", "", "the kind of the owner resource")
+	cmd.Flags().StringVarP(&o.OwnerName, "owner-name", "", "", "the name of the owner resource")
+	cmd.Flags().StringVarP(&o.OwnerUID, "owner-uid", "", "", "the uid of the owner resource which kubernetes requires for the owner reference to be valid")
+	cmd.Flags().BoolVarP(&o.Controller, "controller", "", false, "marks the owner as the managing controller of the resources")
+	cmd.Flags().BoolVarP(&o.BlockOwnerDeletion, "block-owner-deletion", "", false, "blocks the deletion of the owner until the resources are deleted")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.OwnerAPIVersion == "" {
+		return options.MissingOption("owner-apiversion")
+	}
+	if o.OwnerKind == "" {
+		return options.MissingOption("owner-kind")
+	}
+	if o.OwnerName == "" {
+		return options.MissingOption("owner-name")
+	}
+	if o.OwnerUID == "" {
+		return options.MissingOption("owner-uid")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	ownerRef := o.OwnerReference()
+
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		refs := u.GetOwnerReferences()
+		for _, r := range refs {
+			if SameOwner(r, ownerRef) {
+				return false, nil
+			}
+		}
+		u.SetOwnerReferences(append(refs, ownerRef))
+		log.Logger().Infof("added owner %s %s to %s %s in file %s", o.OwnerKind, termcolor.ColorInfo(o.OwnerName), u.GetKind(), termcolor.ColorInfo(u.GetName()), path)
+		o.Count++
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add owner references in dir %s", o.Dir)
+	}
+	log.Logger().Infof("modified %d resources", o.Count)
+	return nil
+}
+
+// OwnerReference returns the owner reference to add
+func (o *Options) OwnerReference() metav1.OwnerReference {
+	ref := metav1.OwnerReference{
+		APIVersion: o.OwnerAPIVersion,
+		Kind:       o.OwnerKind,
+		Name:       o.OwnerName,
+		UID:        types.UID(o.OwnerUID),
+	}
+	if o.Controller {
+		ref.Controller = &o.Controller
+	}
+	if o.BlockOwnerDeletion {
+		ref.BlockOwnerDeletion = &o.BlockOwnerDeletion
+	}
+	return ref
+}
+
+// SameOwner returns true if the owner references refer to the same owner
+func SameOwner(r1, r2 metav1.OwnerReference) bool {
+	return r1.APIVersion == r2.APIVersion && r1.Kind == r2.Kind && r1.Name == r2.Name && r1.UID == r2.UID
+}
@@ -0,0 +1,107 @@
+package addownerref_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addownerref"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddOwnerRef(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := addownerref.NewCmdAddOwnerRef()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.OwnerAPIVersion = "argoproj.io/v1alpha1"
+	o.OwnerKind = "Application"
+	o.OwnerName = "myapp"
+	o.OwnerUID = "1234-5678"
+	o.Controller = true
+	err := o.Run()
+	require.NoError(t, err, "failed to run add-owner-ref")
+	assert.Equal(t, 2, o.Count, "modified count")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "web-deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	refs := resources[0].GetOwnerReferences()
+	require.Len(t, refs, 1, "owner references")
+	assert.Equal(t, "argoproj.io/v1alpha1", refs[0].APIVersion)
+	assert.Equal(t, "Application", refs[0].Kind)
+	assert.Equal(t, "myapp", refs[0].Name)
+	assert.Equal(t, "1234-5678", string(refs[0].UID))
+	require.NotNil(t, refs[0].Controller)
+	assert.True(t, *refs[0].Controller)
+	assert.Nil(t, refs[0].BlockOwnerDeletion)
+
+	resources, err = resourcehelpers.LoadFile(filepath.Join(tmpDir, "backend-deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Len(t, resources[0].GetOwnerReferences(), 1, "should not have duplicated the existing owner reference")
+
+	resources, err = resourcehelpers.LoadFile(filepath.Join(tmpDir, "multi.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Len(t, resources[0].GetOwnerReferences(), 1, "Deployment owner references")
+	assert.Empty(t, resources[1].GetOwnerReferences(), "the Service should not match the selector")
+
+	// running again should not modify anything
+	o.Count = 0
+	err = o.Run()
+	require.NoError(t, err, "failed to run add-owner-ref again")
+	assert.Equal(t, 0, o.Count, "modified count on second run")
+}
+
+func TestAddOwnerRefDifferentOwner(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := addownerref.NewCmdAddOwnerRef()
+	o.Dir = tmpDir
+	o.Names = []string{"backend"}
+	o.OwnerAPIVersion = "argoproj.io/v1alpha1"
+	o.OwnerKind = "Application"
+	o.OwnerName = "another"
+	o.OwnerUID = "8765-4321"
+	err := o.Run()
+	require.NoError(t, err, "failed to run add-owner-ref")
+	assert.Equal(t, 1, o.Count, "modified count")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "backend-deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	refs := resources[0].GetOwnerReferences()
+	require.Len(t, refs, 2, "owner references")
+	assert.Equal(t, "myapp", refs[0].Name)
+	assert.Equal(t, "another", refs[1].Name)
+}
+
+func TestAddOwnerRefMissingOptions(t *testing.T) {
+	testCases := []struct {
+		option string
+		modify func(o *addownerref.Options)
+	}{
+		{"owner-apiversion", func(o *addownerref.Options) { o.OwnerAPIVersion = "" }},
+		{"owner-kind", func(o *addownerref.Options) { o.OwnerKind = "" }},
+		{"owner-name", func(o *addownerref.Options) { o.OwnerName = "" }},
+		{"owner-uid", func(o *addownerref.Options) { o.OwnerUID = "" }},
+	}
+	for _, tc := range testCases {
+		_, o := addownerref.NewCmdAddOwnerRef()
+		o.Dir = "test_data"
+		o.OwnerAPIVersion = "argoproj.io/v1alpha1"
+		o.OwnerKind = "Application"
+		o.OwnerName = "myapp"
+		o.OwnerUID = "1234-5678"
+		tc.modify(o)
+
+		err := o.Run()
+		require.Error(t, err, "should fail without the %s option", tc.option)
+		assert.Equal(t, options.MissingOption(tc.option).Error(), err.Error(), "error without the %s option", tc.option)
+	}
+}
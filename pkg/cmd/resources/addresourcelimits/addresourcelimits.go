@@ -0,0 +1,292 @@
+package addresourcelimits
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds default resource requests and limits to the containers of the workloads in the given directory tree
+
+		The defaults are added to the containers of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector which do not specify them. Requests and limits which are already specified are left untouched unless --overwrite is specified.
+
+		A default request is never added above an existing limit of the container and a default limit is never added below an existing request. The existing value is used instead so that the container remains valid.
+`)
+
+	cmdExample = templates.Examples(`
+		# adds default requests and limits to all the containers missing them
+		%s resources add-resource-limits --cpu-request 100m --mem-request 128Mi --cpu-limit 500m --mem-limit 512Mi
+
+		# adds a default memory limit to the containers of the deployments in the staging namespace
+		%s resources add-resource-limits --dir config-root/namespaces/jx-staging --kind Deployment --mem-limit 1Gi
+	`)
+)
+
+const (
+	// requests the field of the resource requests of a container
+	requests = "requests"
+
+	// limits the field of the resource limits of a container
+	limits = "limits"
+)
+
+// Default a default resource request or limit
+type Default struct {
+	// Field the field of the resources of the container which is either 'requests' or 'limits'
+	Field string
+
+	// Resource the name of the resource such as 'cpu' or 'memory'
+	Resource string
+
+	// Quantity the default quantity
+	Quantity resource.Quantity
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir            string
+	CPURequest     string
+	MemoryRequest  string
+	CPULimit       string
+	MemoryLimit    string
+	Containers     []string
+	InitContainers bool
+	Overwrite      bool
+	Defaults       []Default
+	Modified       []string
+	Files          []string
+}
+
+// NewCmdAddResourceLimits creates a command object for the command
+func NewCmdAddResourceLimits() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "add-resource-limits",
+		Short:   "Adds default resource requests and limits to the containers of the workloads in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.CPURequest, "cpu-request", "", "", "the default CPU request such as '100m'")
+	cmd.Flags().StringVarP(&o.MemoryRequest, "mem-request", "", "", "the default memory request such as '128Mi'")
+	cmd.Flags().StringVarP(&o.CPULimit, "cpu-limit", "", "", "the default CPU limit such as '500m'")
+	cmd.Flags().StringVarP(&o.MemoryLimit, "mem-limit", "", "", "the default memory limit such as '512Mi'")
+	cmd.Flags().StringArrayVarP(&o.Containers, "container", "c", nil, "the names of the containers to modify. If not specified all the containers are modified")
+	cmd.Flags().BoolVarP(&o.InitContainers, "init-containers", "", false, "also adds the defaults to the init containers")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "replaces the requests and limits which are already specified")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	o.Defaults = nil
+	for _, d := range []struct {
+		flag     string
+		field    string
+		resource string
+		value    string
+	}{
+		{"cpu-request", requests, "cpu", o.CPURequest},
+		{"mem-request", requests, "memory", o.MemoryRequest},
+		{"cpu-limit", limits, "cpu", o.CPULimit},
+		{"mem-limit", limits, "memory", o.MemoryLimit},
+	} {
+		if d.value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(d.value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid --%s value '%s'", d.flag, d.value)
+		}
+		o.Defaults = append(o.Defaults, Default{Field: d.field, Resource: d.resource, Quantity: q})
+	}
+	if len(o.Defaults) == 0 {
+		return errors.Errorf("missing option: one of --cpu-request, --mem-request, --cpu-limit or --mem-limit")
+	}
+	for _, r := range o.Defaults {
+		if r.Field != requests {
+			continue
+		}
+		for _, l := range o.Defaults {
+			if l.Field == limits && l.Resource == r.Resource && r.Quantity.Cmp(l.Quantity) > 0 {
+				return errors.Errorf("the %s request %s is greater than the limit %s", r.Resource, r.Quantity.String(), l.Quantity.String())
+			}
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Modified = nil
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified, err := o.addDefaults(u)
+		if err != nil || !modified {
+			return false, err
+		}
+		if len(o.Files) == 0 || o.Files[len(o.Files)-1] != path {
+			o.Files = append(o.Files, path)
+		}
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add resource limits to resources in dir %s", o.Dir)
+	}
+	for _, c := range o.Modified {
+		log.Logger().Infof("modified %s", termcolor.ColorInfo(c))
+	}
+	log.Logger().Infof("added default resource requests or limits to %d containers in %d files", len(o.Modified), len(o.Files))
+	return nil
+}
+
+// addDefaults adds the default requests and limits to the containers of the pod spec of the resource
+func (o *Options) addDefaults(u *unstructured.Unstructured) (bool, error) {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return false, nil
+	}
+	fields := []string{"containers"}
+	if o.InitContainers {
+		fields = append(fields, "initContainers")
+	}
+	modified := false
+	for _, field := range fields {
+		path := append(append([]string{}, podSpecPath...), field)
+		containers, found, err := unstructured.NestedSlice(u.Object, path...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get %s of %s", strings.Join(path, "."), resourcehelpers.ResourceKey(u))
+		}
+		if !found {
+			continue
+		}
+		changed := false
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			if len(o.Containers) > 0 && stringhelpers.StringArrayIndex(o.Containers, name) < 0 {
+				continue
+			}
+			description := fmt.Sprintf("%s container %s", resourcehelpers.ResourceKey(u), name)
+			flag, err := o.addContainerDefaults(container, description)
+			if err != nil {
+				return false, err
+			}
+			if flag {
+				o.Modified = append(o.Modified, description)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		err = unstructured.SetNestedSlice(u.Object, containers, path...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set %s of %s", strings.Join(path, "."), resourcehelpers.ResourceKey(u))
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+// addContainerDefaults adds the missing defaults to the resources of the container returning true if it was modified
+func (o *Options) addContainerDefaults(container map[string]interface{}, description string) (bool, error) {
+	resources, _ := container["resources"].(map[string]interface{})
+	if resources == nil {
+		resources = map[string]interface{}{}
+	}
+	modified := false
+	for _, d := range o.Defaults {
+		values, _ := resources[d.Field].(map[string]interface{})
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		existing, ok := values[d.Resource]
+		if ok && !o.Overwrite {
+			continue
+		}
+		q, err := o.defaultQuantity(resources, d, description)
+		if err != nil {
+			return false, err
+		}
+		value := q.String()
+		if ok && fmt.Sprint(existing) == value {
+			continue
+		}
+		values[d.Resource] = value
+		resources[d.Field] = values
+		modified = true
+		log.Logger().Debugf("set the %s %s of %s to %s", d.Resource, strings.TrimSuffix(d.Field, "s"), description, value)
+	}
+	if modified {
+		container["resources"] = resources
+	}
+	return modified, nil
+}
+
+// defaultQuantity returns the default quantity to use keeping a request no greater than an existing limit and a
+// limit no less than an existing request which is not going to be overwritten
+func (o *Options) defaultQuantity(resources map[string]interface{}, d Default, description string) (resource.Quantity, error) {
+	other := limits
+	if d.Field == limits {
+		other = requests
+	}
+	if o.Overwrite && o.hasDefault(other, d.Resource) {
+		return d.Quantity, nil
+	}
+	values, _ := resources[other].(map[string]interface{})
+	text, ok := values[d.Resource]
+	if !ok {
+		return d.Quantity, nil
+	}
+	existing, err := resource.ParseQuantity(fmt.Sprint(text))
+	if err != nil {
+		return d.Quantity, errors.Wrapf(err, "invalid %s %s of %s", d.Resource, strings.TrimSuffix(other, "s"), description)
+	}
+	cmp := d.Quantity.Cmp(existing)
+	if (d.Field == requests && cmp > 0) || (d.Field == limits && cmp < 0) {
+		log.Logger().Debugf("using the %s %s %s of %s as the default %s", d.Resource, strings.TrimSuffix(other, "s"), existing.String(), description, strings.TrimSuffix(d.Field, "s"))
+		return existing, nil
+	}
+	return d.Quantity, nil
+}
+
+// hasDefault returns true if there is a default for the field and resource
+func (o *Options) hasDefault(field, name string) bool {
+	for _, d := range o.Defaults {
+		if d.Field == field && d.Resource == name {
+			return true
+		}
+	}
+	return false
+}
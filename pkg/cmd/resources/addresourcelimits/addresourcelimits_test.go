@@ -0,0 +1,138 @@
+package addresourcelimits_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addresourcelimits"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddResourceLimits(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	cronJobFile := filepath.Join(tmpDir, "cronjob.yaml")
+
+	_, o := addresourcelimits.NewCmdAddResourceLimits()
+	o.Dir = tmpDir
+	o.CPURequest = "100m"
+	o.MemoryRequest = "128Mi"
+	o.CPULimit = "500m"
+	o.MemoryLimit = "512Mi"
+	err := o.Run()
+	require.NoError(t, err, "failed to add resource limits")
+
+	assert.Equal(t, []string{cronJobFile, deployFile}, o.Files, "modified files")
+	assert.Equal(t, []string{
+		"CronJob/cleanup container cleanup",
+		"Deployment/web container web",
+		"Deployment/web container proxy",
+	}, o.Modified, "modified containers")
+
+	containers := loadContainers(t, deployFile, "spec", "template", "spec", "containers")
+	require.Len(t, containers, 3, "containers")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "200m", "memory": "64Mi"},
+		"limits":   map[string]interface{}{"cpu": "500m", "memory": "64Mi"},
+	}, containers[0]["resources"], "the existing values of the web container should be kept and the memory request should not exceed the limit")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+		"limits":   map[string]interface{}{"cpu": "500m", "memory": "512Mi"},
+	}, containers[1]["resources"], "resources of the proxy container")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "50m", "memory": "32Mi"},
+		"limits":   map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+	}, containers[2]["resources"], "the complete container should not be modified")
+
+	initContainers := loadContainers(t, deployFile, "spec", "template", "spec", "initContainers")
+	assert.Nil(t, initContainers[0]["resources"], "init containers should not be modified by default")
+
+	containers = loadContainers(t, cronJobFile, "spec", "jobTemplate", "spec", "template", "spec", "containers")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+		"limits":   map[string]interface{}{"cpu": "500m", "memory": "512Mi"},
+	}, containers[0]["resources"], "resources of the cron job container")
+
+	// adding the same defaults again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to add resource limits again")
+	assert.Empty(t, o.Files, "modified files on second run")
+	assert.Empty(t, o.Modified, "modified containers on second run")
+}
+
+func TestAddResourceLimitsOverwriteInitContainers(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+
+	_, o := addresourcelimits.NewCmdAddResourceLimits()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.Containers = []string{"web", "migrate", "complete"}
+	o.InitContainers = true
+	o.Overwrite = true
+	o.CPULimit = "2"
+	o.MemoryLimit = "16Mi"
+	err := o.Run()
+	require.NoError(t, err, "failed to add resource limits")
+
+	assert.Equal(t, []string{deployFile}, o.Files, "modified files")
+	assert.Equal(t, []string{
+		"Deployment/web container web",
+		"Deployment/web container complete",
+		"Deployment/web container migrate",
+	}, o.Modified, "modified containers")
+
+	containers := loadContainers(t, deployFile, "spec", "template", "spec", "containers")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "200m"},
+		"limits":   map[string]interface{}{"cpu": "2", "memory": "16Mi"},
+	}, containers[0]["resources"], "the memory limit of the web container should be overwritten")
+	assert.Nil(t, containers[1]["resources"], "the proxy container does not match")
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "50m", "memory": "32Mi"},
+		"limits":   map[string]interface{}{"cpu": "2", "memory": "32Mi"},
+	}, containers[2]["resources"], "the memory limit of the complete container should not be less than its request")
+
+	initContainers := loadContainers(t, deployFile, "spec", "template", "spec", "initContainers")
+	assert.Equal(t, map[string]interface{}{
+		"limits": map[string]interface{}{"cpu": "2", "memory": "16Mi"},
+	}, initContainers[0]["resources"], "resources of the init container")
+}
+
+func TestAddResourceLimitsInvalid(t *testing.T) {
+	for _, values := range [][]string{
+		{"", "", "", ""},
+		{"lots", "", "", ""},
+		{"", "", "", "1Zi"},
+		{"2", "", "1", ""},
+	} {
+		_, o := addresourcelimits.NewCmdAddResourceLimits()
+		o.Dir = "test_data"
+		o.CPURequest = values[0]
+		o.MemoryRequest = values[1]
+		o.CPULimit = values[2]
+		o.MemoryLimit = values[3]
+		err := o.Run()
+		assert.Error(t, err, "should fail for %v", values)
+	}
+}
+
+func loadContainers(t *testing.T, path string, fields ...string) []map[string]interface{} {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, 1, "resources in %s", path)
+
+	values, found, err := unstructured.NestedSlice(resources[0].Object, fields...)
+	require.NoError(t, err, "failed to get containers of %s", path)
+	require.True(t, found, "no containers in %s", path)
+
+	var answer []map[string]interface{}
+	for _, v := range values {
+		answer = append(answer, v.(map[string]interface{}))
+	}
+	return answer
+}
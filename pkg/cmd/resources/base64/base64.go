@@ -0,0 +1,190 @@
+package base64
+
+import (
+	b64 "encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Decodes the base64 data of the Secrets in the given directory tree into stringData so they can be reviewed or encodes the stringData back into data
+
+		With --decode each value of the data of a Secret is moved to its stringData unless it is binary or the stringData already has the key, which takes precedence. With --encode each value of the stringData is base64 encoded and moved to the data. The values are never logged.
+`)
+
+	cmdExample = templates.Examples(`
+		# decodes the data of the Secrets so they can be reviewed
+		%s resources base64 --decode --dir config-root
+
+		# encodes the stringData of a Secret back into data
+		%s resources base64 --encode --name my-secret
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir      string
+	Decode   bool
+	Encode   bool
+	Modified int
+}
+
+// NewCmdBase64 creates a command object for the command
+func NewCmdBase64() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "base64",
+		Short:   "Decodes the base64 data of the Secrets in the given directory tree into stringData or encodes the stringData into data",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Decode, "decode", "", false, "decodes the data of the Secrets into stringData")
+	cmd.Flags().BoolVarP(&o.Encode, "encode", "", false, "encodes the stringData of the Secrets into data")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Decode == o.Encode {
+		return errors.Errorf("must specify one of --decode or --encode")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	o.Modified = 0
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		if u.GetKind() != "Secret" {
+			return false, nil
+		}
+		var modified bool
+		var err error
+		if o.Decode {
+			modified, err = decode(u, path)
+		} else {
+			modified, err = encode(u)
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to convert Secret %s in file %s", u.GetName(), path)
+		}
+		if modified {
+			o.Modified++
+			log.Logger().Debugf("converted Secret %s in file %s", termcolor.ColorInfo(u.GetName()), termcolor.ColorInfo(path))
+		}
+		return modified, nil
+	}
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert the Secrets in dir %s", o.Dir)
+	}
+	action := "encoded"
+	if o.Decode {
+		action = "decoded"
+	}
+	log.Logger().Infof("%s %d Secrets", action, o.Modified)
+	return nil
+}
+
+// decode moves the data of the Secret into the stringData keeping any binary values in the data
+func decode(u *unstructured.Unstructured, path string) (bool, error) {
+	data, _, err := unstructured.NestedStringMap(u.Object, "data")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get data")
+	}
+	if len(data) == 0 {
+		return false, nil
+	}
+	stringData, _, err := unstructured.NestedStringMap(u.Object, "stringData")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get stringData")
+	}
+	if stringData == nil {
+		stringData = map[string]string{}
+	}
+	modified := false
+	for k, v := range data {
+		if _, ok := stringData[k]; ok {
+			// the stringData takes precedence over the data
+			delete(data, k)
+			modified = true
+			continue
+		}
+		value, err := b64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to decode the value of key %s", k)
+		}
+		if !utf8.Valid(value) {
+			log.Logger().Warnf("not decoding key %s of Secret %s in file %s as it is binary", k, u.GetName(), path)
+			continue
+		}
+		stringData[k] = string(value)
+		delete(data, k)
+		modified = true
+	}
+	if !modified {
+		return false, nil
+	}
+	return true, setMaps(u, data, stringData)
+}
+
+// encode moves the stringData of the Secret into the data
+func encode(u *unstructured.Unstructured) (bool, error) {
+	stringData, _, err := unstructured.NestedStringMap(u.Object, "stringData")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get stringData")
+	}
+	if len(stringData) == 0 {
+		return false, nil
+	}
+	data, _, err := unstructured.NestedStringMap(u.Object, "data")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get data")
+	}
+	if data == nil {
+		data = map[string]string{}
+	}
+	for k, v := range stringData {
+		data[k] = b64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return true, setMaps(u, data, nil)
+}
+
+// setMaps sets the data and stringData of the Secret removing them if they are empty
+func setMaps(u *unstructured.Unstructured, data, stringData map[string]string) error {
+	fields := map[string]map[string]string{"data": data, "stringData": stringData}
+	for field, m := range fields {
+		if len(m) == 0 {
+			unstructured.RemoveNestedField(u.Object, field)
+			continue
+		}
+		err := unstructured.SetNestedStringMap(u.Object, m, field)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set %s", field)
+		}
+	}
+	return nil
+}
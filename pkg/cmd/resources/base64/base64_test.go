@@ -0,0 +1,61 @@
+package base64_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/base64"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase64DecodeAndEncode(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := base64.NewCmdBase64()
+	o.Dir = tmpDir
+	o.Decode = true
+	err := o.Run()
+	require.NoError(t, err, "failed to decode")
+	assert.Equal(t, 2, o.Modified, "decoded secrets")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "decoded.yaml"), filepath.Join(tmpDir, "secrets.yaml"))
+
+	// decoding again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to decode again")
+	assert.Equal(t, 0, o.Modified, "decoded secrets on the second run")
+
+	o.Decode = false
+	o.Encode = true
+	err = o.Run()
+	require.NoError(t, err, "failed to encode")
+	assert.Equal(t, 3, o.Modified, "encoded secrets")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "encoded.yaml"), filepath.Join(tmpDir, "secrets.yaml"))
+
+	// encoding again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to encode again")
+	assert.Equal(t, 0, o.Modified, "encoded secrets on the second run")
+}
+
+func TestBase64Selector(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := base64.NewCmdBase64()
+	o.Dir = tmpDir
+	o.Encode = true
+	o.Namespaces = []string{"jx"}
+	err := o.Run()
+	require.NoError(t, err, "failed to encode")
+	assert.Equal(t, 1, o.Modified, "encoded secrets")
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "other.yaml"))
+	require.NoError(t, err, "failed to load other.yaml")
+	assert.Contains(t, string(data), "url: https://example.com", "the Secret in another namespace should not be modified")
+
+	o.Decode = true
+	err = o.Run()
+	require.Error(t, err, "should fail with both --decode and --encode")
+}
@@ -0,0 +1,430 @@
+package canonicalizeapiversion
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Rewrites the deprecated apiVersions of the kubernetes resources in the given directory tree to their current equivalents
+
+		Fields are adjusted where the structure differs between the versions such as the backends of Ingresses or the selector which is required by apps/v1 workloads. Defaults which changed between the versions, such as the failure policy of webhooks, are set explicitly so that the behaviour is unchanged.
+
+		Resources which cannot be converted safely, such as v1beta1 CustomResourceDefinitions, or which have no replacement, such as PodSecurityPolicies, are left untouched and reported as warnings.
+`)
+
+	cmdExample = templates.Examples(`
+		# rewrites the deprecated apiVersions of the resources in the current directory
+		%s resources canonicalize-apiversion
+
+		# rewrites the deprecated apiVersions of the Ingresses in a directory
+		%s resources canonicalize-apiversion --dir config-root --kind Ingress
+	`)
+
+	// Conversions the conversions of the deprecated apiVersions of each kind
+	Conversions = []Conversion{
+		{Kinds: []string{"Deployment", "DaemonSet", "ReplicaSet", "StatefulSet"}, From: []string{"extensions/v1beta1", "apps/v1beta1", "apps/v1beta2"}, To: "apps/v1", DeprecatedIn: "1.9", RemovedIn: "1.16", Convert: convertWorkload},
+		{Kinds: []string{"Ingress"}, From: []string{"extensions/v1beta1", "networking.k8s.io/v1beta1"}, To: "networking.k8s.io/v1", DeprecatedIn: "1.19", RemovedIn: "1.22", Convert: convertIngress},
+		{Kinds: []string{"IngressClass"}, From: []string{"networking.k8s.io/v1beta1"}, To: "networking.k8s.io/v1", DeprecatedIn: "1.19", RemovedIn: "1.22"},
+		{Kinds: []string{"NetworkPolicy"}, From: []string{"extensions/v1beta1"}, To: "networking.k8s.io/v1", DeprecatedIn: "1.9", RemovedIn: "1.16"},
+		{Kinds: []string{"PodSecurityPolicy"}, From: []string{"extensions/v1beta1"}, To: "policy/v1beta1", DeprecatedIn: "1.10", RemovedIn: "1.16"},
+		{Kinds: []string{"PodSecurityPolicy"}, From: []string{"policy/v1beta1"}, DeprecatedIn: "1.21", RemovedIn: "1.25", Guidance: podSecurityPolicyGuidance},
+		{Kinds: []string{"PodDisruptionBudget"}, From: []string{"policy/v1beta1"}, To: "policy/v1", DeprecatedIn: "1.21", RemovedIn: "1.25", Convert: convertPodDisruptionBudget},
+		{Kinds: []string{"CronJob"}, From: []string{"batch/v1beta1", "batch/v2alpha1"}, To: "batch/v1", DeprecatedIn: "1.21", RemovedIn: "1.25"},
+		{Kinds: []string{"HorizontalPodAutoscaler"}, From: []string{"autoscaling/v2beta1"}, To: "autoscaling/v2", DeprecatedIn: "1.23", RemovedIn: "1.25", Convert: convertHorizontalPodAutoscaler},
+		{Kinds: []string{"HorizontalPodAutoscaler"}, From: []string{"autoscaling/v2beta2"}, To: "autoscaling/v2", DeprecatedIn: "1.23", RemovedIn: "1.26", Convert: convertHorizontalPodAutoscaler},
+		{Kinds: []string{"Role", "ClusterRole", "RoleBinding", "ClusterRoleBinding"}, From: []string{"rbac.authorization.k8s.io/v1beta1", "rbac.authorization.k8s.io/v1alpha1"}, To: "rbac.authorization.k8s.io/v1", DeprecatedIn: "1.17", RemovedIn: "1.22"},
+		{Kinds: []string{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"}, From: []string{"admissionregistration.k8s.io/v1beta1"}, To: "admissionregistration.k8s.io/v1", DeprecatedIn: "1.16", RemovedIn: "1.22", Convert: convertWebhookConfiguration},
+		{Kinds: []string{"CustomResourceDefinition"}, From: []string{"apiextensions.k8s.io/v1beta1"}, To: "apiextensions.k8s.io/v1", DeprecatedIn: "1.16", RemovedIn: "1.22", Convert: convertCustomResourceDefinition},
+		{Kinds: []string{"PriorityClass"}, From: []string{"scheduling.k8s.io/v1beta1", "scheduling.k8s.io/v1alpha1"}, To: "scheduling.k8s.io/v1", DeprecatedIn: "1.14", RemovedIn: "1.22"},
+		{Kinds: []string{"StorageClass", "CSIDriver", "CSINode", "VolumeAttachment"}, From: []string{"storage.k8s.io/v1beta1"}, To: "storage.k8s.io/v1", DeprecatedIn: "1.19", RemovedIn: "1.22"},
+		{Kinds: []string{"Lease"}, From: []string{"coordination.k8s.io/v1beta1"}, To: "coordination.k8s.io/v1", DeprecatedIn: "1.14", RemovedIn: "1.22"},
+		{Kinds: []string{"RuntimeClass"}, From: []string{"node.k8s.io/v1beta1"}, To: "node.k8s.io/v1", DeprecatedIn: "1.20", RemovedIn: "1.25"},
+	}
+
+	podSecurityPolicyGuidance = "PodSecurityPolicy has no replacement so enforce the Pod Security Standards with the pod-security.kubernetes.io labels on the Namespaces or use a policy engine such as Kyverno or OPA Gatekeeper"
+)
+
+// Conversion converts the resources of some kinds from deprecated apiVersions to the current apiVersion
+type Conversion struct {
+	// Kinds the kinds of the resources
+	Kinds []string
+
+	// From the deprecated apiVersions
+	From []string
+
+	// To the current apiVersion or blank if the kinds have no replacement
+	To string
+
+	// DeprecatedIn the kubernetes version which deprecated the From apiVersions and supports the To apiVersion so
+	// that the resources can be converted
+	DeprecatedIn string
+
+	// RemovedIn the kubernetes version which no longer serves the From apiVersions
+	RemovedIn string
+
+	// Guidance how to migrate the resources by hand if there is no To apiVersion
+	Guidance string
+
+	// Convert if specified adjusts the fields of the resource for the current apiVersion returning the reason if
+	// the resource cannot be converted safely in which case it must not be modified
+	Convert func(u *unstructured.Unstructured) (string, error)
+}
+
+// FindConversion returns the conversion of the apiVersion and kind or nil if it is not deprecated
+func FindConversion(apiVersion, kind string) *Conversion {
+	for i := range Conversions {
+		c := &Conversions[i]
+		if contains(c.Kinds, kind) && contains(c.From, apiVersion) {
+			return c
+		}
+	}
+	return nil
+}
+
+// IsDeprecatedBy returns true if the From apiVersions are deprecated by the kubernetes version
+func (c *Conversion) IsDeprecatedBy(version KubernetesVersion) bool {
+	return !version.Less(MustParseKubernetesVersion(c.DeprecatedIn))
+}
+
+// IsRemovedBy returns true if the From apiVersions are no longer served by the kubernetes version
+func (c *Conversion) IsRemovedBy(version KubernetesVersion) bool {
+	return !version.Less(MustParseKubernetesVersion(c.RemovedIn))
+}
+
+// Result a resource whose apiVersion has been converted
+type Result struct {
+	// Path the file containing the resource
+	Path string
+
+	// Resource the key of the resource
+	Resource string
+
+	// From the deprecated apiVersion
+	From string
+
+	// To the current apiVersion
+	To string
+}
+
+// Warning a resource with a deprecated apiVersion which could not be converted safely
+type Warning struct {
+	// Path the file containing the resource
+	Path string
+
+	// Resource the key of the resource
+	Resource string
+
+	// APIVersion the deprecated apiVersion of the resource
+	APIVersion string
+
+	// Message the reason the resource could not be converted
+	Message string
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir      string
+	Results  []Result
+	Warnings []Warning
+}
+
+// NewCmdCanonicalizeAPIVersion creates a command object for the command
+func NewCmdCanonicalizeAPIVersion() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "canonicalize-apiversion",
+		Aliases: []string{"canonicalize-apiversions"},
+		Short:   "Rewrites the deprecated apiVersions of the kubernetes resources in the given directory tree to their current equivalents",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.Results = nil
+	o.Warnings = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		apiVersion := u.GetAPIVersion()
+		c := FindConversion(apiVersion, u.GetKind())
+		if c == nil {
+			return false, nil
+		}
+		if c.To == "" {
+			o.Warnings = append(o.Warnings, Warning{
+				Path:       path,
+				Resource:   resourcehelpers.ResourceKey(u),
+				APIVersion: apiVersion,
+				Message:    c.Guidance,
+			})
+			return false, nil
+		}
+		if c.Convert != nil {
+			reason, err := c.Convert(u)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to convert from %s to %s", apiVersion, c.To)
+			}
+			if reason != "" {
+				o.Warnings = append(o.Warnings, Warning{
+					Path:       path,
+					Resource:   resourcehelpers.ResourceKey(u),
+					APIVersion: apiVersion,
+					Message:    reason,
+				})
+				return false, nil
+			}
+		}
+		u.SetAPIVersion(c.To)
+		o.Results = append(o.Results, Result{
+			Path:     path,
+			Resource: resourcehelpers.ResourceKey(u),
+			From:     apiVersion,
+			To:       c.To,
+		})
+		return true, nil
+	}
+	err := resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to canonicalize the apiVersions of resources in dir %s", o.Dir)
+	}
+	for _, r := range o.Results {
+		log.Logger().Infof("converted %s in file %s from %s to %s", r.Resource, o.relPath(r.Path), r.From, termcolor.ColorInfo(r.To))
+	}
+	for _, w := range o.Warnings {
+		log.Logger().Warnf("cannot convert %s in file %s from %s: %s", w.Resource, o.relPath(w.Path), w.APIVersion, w.Message)
+	}
+	log.Logger().Infof("converted %d resources with deprecated apiVersions with %d warnings", len(o.Results), len(o.Warnings))
+	return nil
+}
+
+func (o *Options) relPath(path string) string {
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// convertWorkload defaults the selector which is required by apps/v1 from the labels of the pod template
+func convertWorkload(u *unstructured.Unstructured) (string, error) {
+	spec, _ := u.Object["spec"].(map[string]interface{})
+	for _, field := range []string{"rollbackTo", "templateGeneration"} {
+		if _, found := spec[field]; found {
+			return fmt.Sprintf("spec.%s is not supported by apps/v1", field), nil
+		}
+	}
+	_, found, err := unstructured.NestedFieldNoCopy(u.Object, "spec", "selector")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get spec.selector")
+	}
+	if found {
+		return "", nil
+	}
+	labels, _, err := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "labels")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get spec.template.metadata.labels")
+	}
+	if len(labels) == 0 {
+		return "spec.selector is required by apps/v1 but the pod template has no labels to default it from", nil
+	}
+	err = unstructured.SetNestedStringMap(u.Object, labels, "spec", "selector", "matchLabels")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to set spec.selector.matchLabels")
+	}
+	return "", nil
+}
+
+// convertIngress converts the service backends to the networking.k8s.io/v1 structure, renames spec.backend to
+// spec.defaultBackend and defaults the pathType which is required by networking.k8s.io/v1
+func convertIngress(u *unstructured.Unstructured) (string, error) {
+	spec, _ := u.Object["spec"].(map[string]interface{})
+	if spec == nil {
+		return "", nil
+	}
+	// lets convert a copy so that the resource is untouched if it cannot be converted
+	spec = runtime.DeepCopyJSON(spec)
+
+	if backend, ok := spec["backend"]; ok {
+		delete(spec, "backend")
+		spec["defaultBackend"] = backend
+	}
+	if backend, ok := spec["defaultBackend"].(map[string]interface{}); ok {
+		reason := convertBackend(backend, "spec.defaultBackend")
+		if reason != "" {
+			return reason, nil
+		}
+	}
+	rules, _ := spec["rules"].([]interface{})
+	for i, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		paths, _, _ := unstructured.NestedSlice(rule, "http", "paths")
+		for j, p := range paths {
+			path, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := path["pathType"]; !ok {
+				path["pathType"] = "ImplementationSpecific"
+			}
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				reason := convertBackend(backend, fmt.Sprintf("spec.rules[%d].http.paths[%d].backend", i, j))
+				if reason != "" {
+					return reason, nil
+				}
+			}
+		}
+		if paths != nil {
+			err := unstructured.SetNestedSlice(rule, paths, "http", "paths")
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to set spec.rules[%d].http.paths", i)
+			}
+		}
+	}
+	u.Object["spec"] = spec
+	return "", nil
+}
+
+// convertBackend converts the serviceName and servicePort of an Ingress backend to a service
+func convertBackend(backend map[string]interface{}, field string) string {
+	if _, ok := backend["resource"]; ok {
+		return ""
+	}
+	name, _ := backend["serviceName"].(string)
+	if name == "" {
+		return fmt.Sprintf("%s has no serviceName", field)
+	}
+	port := map[string]interface{}{}
+	switch v := backend["servicePort"].(type) {
+	case int64:
+		port["number"] = v
+	case float64:
+		port["number"] = int64(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			port["number"] = n
+		} else {
+			port["name"] = v
+		}
+	default:
+		return fmt.Sprintf("%s has no servicePort", field)
+	}
+	delete(backend, "serviceName")
+	delete(backend, "servicePort")
+	backend["service"] = map[string]interface{}{
+		"name": name,
+		"port": port,
+	}
+	return ""
+}
+
+// convertPodDisruptionBudget fails for an empty selector as it selects no pods in policy/v1beta1 but every pod
+// in policy/v1
+func convertPodDisruptionBudget(u *unstructured.Unstructured) (string, error) {
+	selector, found, err := unstructured.NestedMap(u.Object, "spec", "selector")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get spec.selector")
+	}
+	if found && len(selector) == 0 {
+		return "an empty spec.selector selects no pods in policy/v1beta1 but every pod in policy/v1", nil
+	}
+	return "", nil
+}
+
+// convertHorizontalPodAutoscaler fails for the metrics of autoscaling/v2beta1 which have a different structure
+func convertHorizontalPodAutoscaler(u *unstructured.Unstructured) (string, error) {
+	if u.GetAPIVersion() != "autoscaling/v2beta1" {
+		return "", nil
+	}
+	metrics, _, err := unstructured.NestedSlice(u.Object, "spec", "metrics")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get spec.metrics")
+	}
+	if len(metrics) > 0 {
+		return "the spec.metrics of autoscaling/v2beta1 have a different structure in autoscaling/v2", nil
+	}
+	return "", nil
+}
+
+// convertWebhookConfiguration sets the defaults which changed in admissionregistration.k8s.io/v1 and fails if the
+// fields required by admissionregistration.k8s.io/v1 are missing
+func convertWebhookConfiguration(u *unstructured.Unstructured) (string, error) {
+	webhooks, _, err := unstructured.NestedSlice(u.Object, "webhooks")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get webhooks")
+	}
+	defaults := map[string]interface{}{
+		"failurePolicy":  "Ignore",
+		"matchPolicy":    "Exact",
+		"timeoutSeconds": int64(30),
+	}
+	for i, w := range webhooks {
+		webhook, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sideEffects, _ := webhook["sideEffects"].(string)
+		if sideEffects != "None" && sideEffects != "NoneOnDryRun" {
+			return fmt.Sprintf("webhooks[%d].sideEffects must be None or NoneOnDryRun in admissionregistration.k8s.io/v1", i), nil
+		}
+		if _, ok := webhook["admissionReviewVersions"]; !ok {
+			return fmt.Sprintf("webhooks[%d].admissionReviewVersions is required by admissionregistration.k8s.io/v1", i), nil
+		}
+	}
+	for _, w := range webhooks {
+		webhook, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range defaults {
+			if _, ok := webhook[k]; !ok {
+				webhook[k] = v
+			}
+		}
+	}
+	if webhooks != nil {
+		err = unstructured.SetNestedSlice(u.Object, webhooks, "webhooks")
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to set webhooks")
+		}
+	}
+	return "", nil
+}
+
+// convertCustomResourceDefinition always fails as the schemas must be structural and are defined per version in
+// apiextensions.k8s.io/v1
+func convertCustomResourceDefinition(u *unstructured.Unstructured) (string, error) {
+	return "the schemas of apiextensions.k8s.io/v1 must be structural and defined for each version so the CustomResourceDefinition must be converted by hand", nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
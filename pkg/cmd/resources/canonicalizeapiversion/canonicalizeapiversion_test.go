@@ -0,0 +1,55 @@
+package canonicalizeapiversion_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/canonicalizeapiversion"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeAPIVersion(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := canonicalizeapiversion.NewCmdCanonicalizeAPIVersion()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to canonicalize the apiVersions")
+
+	var conversions []string
+	for _, r := range o.Results {
+		conversions = append(conversions, r.Resource+" "+r.From+" => "+r.To)
+	}
+	assert.Equal(t, []string{
+		"jx/Ingress/web extensions/v1beta1 => networking.k8s.io/v1",
+		"jx/CronJob/cleanup batch/v1beta1 => batch/v1",
+		"jx/Role/reader rbac.authorization.k8s.io/v1beta1 => rbac.authorization.k8s.io/v1",
+		"jx/RoleBinding/reader rbac.authorization.k8s.io/v1beta1 => rbac.authorization.k8s.io/v1",
+		"MutatingWebhookConfiguration/injector admissionregistration.k8s.io/v1beta1 => admissionregistration.k8s.io/v1",
+		"jx/Deployment/web extensions/v1beta1 => apps/v1",
+		"jx/StatefulSet/db apps/v1beta2 => apps/v1",
+	}, conversions, "conversions")
+
+	var warnings []string
+	for _, w := range o.Warnings {
+		warnings = append(warnings, w.Resource+" "+w.APIVersion+": "+w.Message)
+	}
+	assert.Equal(t, []string{
+		"jx/PodDisruptionBudget/web policy/v1beta1: an empty spec.selector selects no pods in policy/v1beta1 but every pod in policy/v1",
+		"CustomResourceDefinition/widgets.example.io apiextensions.k8s.io/v1beta1: the schemas of apiextensions.k8s.io/v1 must be structural and defined for each version so the CustomResourceDefinition must be converted by hand",
+		"ValidatingWebhookConfiguration/validator admissionregistration.k8s.io/v1beta1: webhooks[0].sideEffects must be None or NoneOnDryRun in admissionregistration.k8s.io/v1",
+		"jx/DaemonSet/agent extensions/v1beta1: spec.selector is required by apps/v1 but the pod template has no labels to default it from",
+	}, warnings, "warnings")
+
+	for _, name := range []string{"ingress.yaml", "misc.yaml", "rbac.yaml", "webhooks.yaml", "workloads.yaml"} {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-"+name), filepath.Join(tmpDir, name))
+	}
+
+	// converting again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to canonicalize the apiVersions again")
+	assert.Empty(t, o.Results, "conversions on the second run")
+	assert.Len(t, o.Warnings, 4, "warnings on the second run")
+}
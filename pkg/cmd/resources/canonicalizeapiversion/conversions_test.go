@@ -0,0 +1,98 @@
+package canonicalizeapiversion_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/canonicalizeapiversion"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestConversions converts the before.yaml of each directory in test_data/conversions comparing the result with
+// the after.yaml or the reason the resource cannot be converted with the reason.txt
+func TestConversions(t *testing.T) {
+	dirs, err := ioutil.ReadDir(filepath.Join("test_data", "conversions"))
+	require.NoError(t, err, "failed to read the conversions test data")
+	require.NotEmpty(t, dirs, "no conversions test data")
+
+	for _, d := range dirs {
+		name := d.Name()
+		dir := filepath.Join("test_data", "conversions", name)
+		t.Run(name, func(t *testing.T) {
+			u := testhelpers.LoadResource(t, filepath.Join(dir, "before.yaml"))
+			before := u.DeepCopy()
+
+			c := canonicalizeapiversion.FindConversion(u.GetAPIVersion(), u.GetKind())
+			require.NotNil(t, c, "no conversion of %s %s", u.GetAPIVersion(), u.GetKind())
+			require.NotNil(t, c.Convert, "no convert function for %s %s", u.GetAPIVersion(), u.GetKind())
+
+			reason, err := c.Convert(u)
+			require.NoError(t, err, "failed to convert %s", name)
+
+			reasonFile := filepath.Join(dir, "reason.txt")
+			exists, err := files.FileExists(reasonFile)
+			require.NoError(t, err, "failed to check if file exists %s", reasonFile)
+			if exists {
+				data, err := ioutil.ReadFile(reasonFile)
+				require.NoError(t, err, "failed to load %s", reasonFile)
+				assert.Equal(t, strings.TrimSpace(string(data)), reason, "reason for %s", name)
+				assert.Equal(t, before.Object, u.Object, "the resource should not be modified when it cannot be converted for %s", name)
+				return
+			}
+
+			assert.Empty(t, reason, "reason for %s", name)
+			u.SetAPIVersion(c.To)
+			expected := testhelpers.LoadResource(t, filepath.Join(dir, "after.yaml"))
+
+			// lets compare the YAML as the converted numbers are int64 rather than the float64 values of loaded files
+			assert.Equal(t, toYAML(t, expected), toYAML(t, u), "converted resource for %s", name)
+		})
+	}
+}
+
+func TestConversionVersions(t *testing.T) {
+	testCases := []struct {
+		apiVersion string
+		kind       string
+		version    string
+		deprecated bool
+		removed    bool
+	}{
+		{"extensions/v1beta1", "Deployment", "1.15", true, false},
+		{"extensions/v1beta1", "Deployment", "1.16", true, true},
+		{"networking.k8s.io/v1beta1", "Ingress", "1.18", false, false},
+		{"networking.k8s.io/v1beta1", "Ingress", "v1.22.3", true, true},
+		{"batch/v1beta1", "CronJob", "1.21", true, false},
+		{"policy/v1beta1", "PodSecurityPolicy", "1.25", true, true},
+		{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.25", true, false},
+		{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.26", true, true},
+		{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "2.0", true, true},
+	}
+	for _, tc := range testCases {
+		version, err := canonicalizeapiversion.ParseKubernetesVersion(tc.version)
+		require.NoError(t, err, "failed to parse version %s", tc.version)
+
+		c := canonicalizeapiversion.FindConversion(tc.apiVersion, tc.kind)
+		require.NotNil(t, c, "no conversion of %s %s", tc.apiVersion, tc.kind)
+		assert.Equal(t, tc.deprecated, c.IsDeprecatedBy(version), "deprecated %s %s in %s", tc.apiVersion, tc.kind, tc.version)
+		assert.Equal(t, tc.removed, c.IsRemovedBy(version), "removed %s %s in %s", tc.apiVersion, tc.kind, tc.version)
+	}
+
+	for _, text := range []string{"", "1", "1.x", "one.25", "1.25.0.1"} {
+		_, err := canonicalizeapiversion.ParseKubernetesVersion(text)
+		assert.Error(t, err, "should fail to parse version '%s'", text)
+	}
+}
+
+func toYAML(t *testing.T, u *unstructured.Unstructured) string {
+	data, err := resourcehelpers.ToYAML([]*unstructured.Unstructured{u})
+	require.NoError(t, err, "failed to marshal %s", resourcehelpers.ResourceKey(u))
+	return string(data)
+}
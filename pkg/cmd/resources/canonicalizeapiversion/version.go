@@ -0,0 +1,55 @@
+package canonicalizeapiversion
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KubernetesVersion the major and minor version of kubernetes
+type KubernetesVersion struct {
+	Major int
+	Minor int
+}
+
+// ParseKubernetesVersion parses versions like '1.25', 'v1.25' or '1.25.3' ignoring the patch version
+func ParseKubernetesVersion(text string) (KubernetesVersion, error) {
+	answer := KubernetesVersion{}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(text), "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return answer, errors.Errorf("invalid kubernetes version '%s' should be of the form 'major.minor'", text)
+	}
+	var err error
+	answer.Major, err = strconv.Atoi(parts[0])
+	if err != nil || answer.Major < 0 {
+		return answer, errors.Errorf("invalid major version in kubernetes version '%s'", text)
+	}
+	answer.Minor, err = strconv.Atoi(parts[1])
+	if err != nil || answer.Minor < 0 {
+		return answer, errors.Errorf("invalid minor version in kubernetes version '%s'", text)
+	}
+	return answer, nil
+}
+
+// MustParseKubernetesVersion parses the kubernetes version panicking if it is invalid
+func MustParseKubernetesVersion(text string) KubernetesVersion {
+	answer, err := ParseKubernetesVersion(text)
+	if err != nil {
+		panic(err)
+	}
+	return answer
+}
+
+// Less returns true if the version is older than the other version
+func (v KubernetesVersion) Less(other KubernetesVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// String returns the 'major.minor' text of the version
+func (v KubernetesVersion) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
+}
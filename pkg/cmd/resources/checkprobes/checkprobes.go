@@ -0,0 +1,187 @@
+package checkprobes
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Reports the containers of the workloads in the given directory tree which are missing health probes
+
+		The containers of the Deployments, StatefulSets and DaemonSets matching the selector are checked for the readiness and liveness probes unless other kinds or probes are specified. Workloads can be ignored via --ignore using their 'Kind/name' or 'namespace/Kind/name' which can include wildcards.
+
+		The missing probes are logged as warnings unless --enforce is specified in which case the command fails if any probes are missing.
+`)
+
+	cmdExample = templates.Examples(`
+		# reports the containers missing readiness or liveness probes
+		%s resources check-probes --dir config-root
+
+		# fails if any containers are missing a readiness probe ignoring the jobs of a chart
+		%s resources check-probes --probe readiness --ignore 'Deployment/lighthouse-*' --enforce
+	`)
+
+	// DefaultKinds the kinds of workload which are checked by default as they have long running pods
+	DefaultKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+	// Probes the probes of a container which can be checked
+	Probes = []string{"readiness", "liveness", "startup"}
+
+	// DefaultProbes the probes which are checked by default
+	DefaultProbes = []string{"readiness", "liveness"}
+)
+
+// Finding a container which is missing probes
+type Finding struct {
+	// File the file containing the workload relative to the directory
+	File string
+
+	// Resource the key of the workload
+	Resource string
+
+	// Container the name of the container
+	Container string
+
+	// Missing the probes which are missing such as 'readiness'
+	Missing []string
+}
+
+// String returns a description of the finding
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s container %s is missing %s probes", f.File, f.Resource, f.Container, strings.Join(f.Missing, " and "))
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir      string
+	Probes   []string
+	Ignore   []string
+	Enforce  bool
+	Findings []Finding
+	Checked  int
+}
+
+// NewCmdCheckProbes creates a command object for the command
+func NewCmdCheckProbes() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "check-probes",
+		Short:   "Reports the containers of the workloads in the given directory tree which are missing health probes",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Probes, "probe", "p", nil, fmt.Sprintf("the probes the containers must have. Values: %s. Defaults to %s", strings.Join(Probes, ", "), strings.Join(DefaultProbes, " and ")))
+	cmd.Flags().StringArrayVarP(&o.Ignore, "ignore", "", nil, "the workloads to ignore of the form 'Kind/name' or 'namespace/Kind/name' which can include wildcards such as 'Deployment/jx-*'")
+	cmd.Flags().BoolVarP(&o.Enforce, "enforce", "", false, "fails if any containers are missing probes")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if len(o.Probes) == 0 {
+		o.Probes = DefaultProbes
+	}
+	for _, p := range o.Probes {
+		if stringhelpers.StringArrayIndex(Probes, p) < 0 {
+			return options.InvalidOption("probe", p, Probes)
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Findings = nil
+	o.Checked = 0
+	err = resourcehelpers.VisitFiles(o.Dir, o.Selector, func(u *unstructured.Unstructured, path string) error {
+		kind := u.GetKind()
+		if len(o.Selector.Kinds) == 0 && stringhelpers.StringArrayIndex(DefaultKinds, kind) < 0 {
+			return nil
+		}
+		key := resourcehelpers.ResourceKey(u)
+		if resourcehelpers.MatchesAnyPattern(key, o.Ignore) || resourcehelpers.MatchesAnyPattern(kind+"/"+u.GetName(), o.Ignore) {
+			return nil
+		}
+		file, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			file = path
+		}
+		return o.checkResource(u, file)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the probes of the resources in dir %s", o.Dir)
+	}
+
+	for i := range o.Findings {
+		log.Logger().Warnf(o.Findings[i].String())
+	}
+	count := len(o.Findings)
+	if count == 0 {
+		log.Logger().Infof("all %d containers have %s probes", o.Checked, strings.Join(o.Probes, " and "))
+		return nil
+	}
+	if !o.Enforce {
+		log.Logger().Warnf("found %s containers missing probes", termcolor.ColorWarning(fmt.Sprintf("%d", count)))
+		return nil
+	}
+	return errors.Errorf("found %d containers missing probes", count)
+}
+
+// checkResource checks the probes of the containers of the pod spec of the resource
+func (o *Options) checkResource(u *unstructured.Unstructured, file string) error {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return nil
+	}
+	path := append(append([]string{}, podSpecPath...), "containers")
+	containers, _, err := unstructured.NestedSlice(u.Object, path...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s of %s", strings.Join(path, "."), resourcehelpers.ResourceKey(u))
+	}
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		o.Checked++
+		var missing []string
+		for _, p := range o.Probes {
+			if container[p+"Probe"] == nil {
+				missing = append(missing, p)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		name, _ := container["name"].(string)
+		o.Findings = append(o.Findings, Finding{File: file, Resource: resourcehelpers.ResourceKey(u), Container: name, Missing: missing})
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package checkprobes_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/checkprobes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProbes(t *testing.T) {
+	_, o := checkprobes.NewCmdCheckProbes()
+	o.Dir = "test_data"
+	err := o.Run()
+	require.NoError(t, err, "should not fail without --enforce")
+
+	assert.Equal(t, []checkprobes.Finding{
+		{
+			File:      filepath.Join("jx", "db.yaml"),
+			Resource:  "jx/StatefulSet/db",
+			Container: "postgres",
+			Missing:   []string{"liveness"},
+		},
+		{
+			File:      filepath.Join("jx", "lighthouse.yaml"),
+			Resource:  "jx/Deployment/lighthouse-webhooks",
+			Container: "lighthouse",
+			Missing:   []string{"readiness", "liveness"},
+		},
+		{
+			File:      filepath.Join("jx", "web.yaml"),
+			Resource:  "jx/Deployment/web",
+			Container: "sidecar",
+			Missing:   []string{"readiness", "liveness"},
+		},
+	}, o.Findings, "findings")
+	assert.Equal(t, 4, o.Checked, "checked containers")
+	assert.Equal(t, "jx/web.yaml: jx/Deployment/web container sidecar is missing readiness and liveness probes", o.Findings[2].String(), "description")
+
+	o.Enforce = true
+	err = o.Run()
+	require.Error(t, err, "should fail with --enforce")
+	assert.Contains(t, err.Error(), "found 3 containers missing probes", "error message")
+}
+
+func TestCheckProbesIgnore(t *testing.T) {
+	_, o := checkprobes.NewCmdCheckProbes()
+	o.Dir = "test_data"
+	o.Probes = []string{"readiness"}
+	o.Ignore = []string{"Deployment/lighthouse-*", "jx/Deployment/web"}
+	o.Enforce = true
+	err := o.Run()
+	require.NoError(t, err, "should not fail as the workloads missing readiness probes are ignored")
+	assert.Empty(t, o.Findings, "findings")
+	assert.Equal(t, 1, o.Checked, "checked containers")
+}
+
+func TestCheckProbesKinds(t *testing.T) {
+	_, o := checkprobes.NewCmdCheckProbes()
+	o.Dir = "test_data"
+	o.Kinds = []string{"Job"}
+	o.Probes = []string{"startup"}
+	err := o.Run()
+	require.NoError(t, err, "failed to check probes")
+	require.Len(t, o.Findings, 1, "findings")
+	assert.Equal(t, "jx/Job/migrate", o.Findings[0].Resource, "resource")
+	assert.Equal(t, []string{"startup"}, o.Findings[0].Missing, "missing probes")
+
+	o.Probes = []string{"ready"}
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid probe")
+}
@@ -0,0 +1,160 @@
+package convertlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Converts the List resources in the given directory tree into separate documents or the other way around
+
+		By default the items of each List resource are expanded into separate documents of the same file dropping the List wrapper. If --split is specified each document is saved to a separate file instead. If --to-list is specified the files containing multiple resources are converted into a single List resource.
+`)
+
+	cmdExample = templates.Examples(`
+		# expands the List resources into separate documents
+		%s resources convert-list --dir config-root
+
+		# expands the List resources into separate files
+		%s resources convert-list --split
+
+		# wraps the files containing multiple resources into a List resource
+		%s resources convert-list --to-list
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir    string
+	Split  bool
+	ToList bool
+	Files  []string
+}
+
+// NewCmdConvertList creates a command object for the command
+func NewCmdConvertList() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "convert-list",
+		Short:   "Converts the List resources in the given directory tree into separate documents or the other way around",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.Split, "split", "", false, "saves the items of the List resources to separate files rather than separate documents of the same file")
+	cmd.Flags().BoolVarP(&o.ToList, "to-list", "", false, "converts the files containing multiple resources into a single List resource")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Split && o.ToList {
+		return errors.Errorf("cannot specify both --split and --to-list")
+	}
+	// lets find the files first so that any split files are not processed again
+	var paths []string
+	err := filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the YAML files in dir %s", o.Dir)
+	}
+
+	o.Files = nil
+	for _, path := range paths {
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		var modified bool
+		if o.ToList {
+			modified, err = o.toList(path, resources)
+		} else {
+			modified, err = o.expandLists(path, resources)
+		}
+		if err != nil {
+			return err
+		}
+		if modified {
+			o.Files = append(o.Files, path)
+		}
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("converted file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("converted %d files", len(o.Files))
+	return nil
+}
+
+// expandLists replaces the List resources in the file with their items
+func (o *Options) expandLists(path string, resources []*unstructured.Unstructured) (bool, error) {
+	resources, expanded := resourcehelpers.ExpandLists(resources)
+	if !expanded || len(resources) == 0 {
+		return false, nil
+	}
+	if !o.Split || len(resources) <= 1 {
+		return true, resourcehelpers.SaveFile(resources, path)
+	}
+
+	// lets name the files like the split command does
+	ext := filepath.Ext(path)
+	names := []string{path}
+	for i := 1; i < len(resources); i++ {
+		name := strings.TrimSuffix(path, ext) + strconv.Itoa(i+1) + ext
+		exists, err := files.FileExists(name)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check if file exists %s", name)
+		}
+		if exists {
+			return false, errors.Errorf("cannot split the List resource in file %s as file %s already exists", path, name)
+		}
+		names = append(names, name)
+	}
+	for i, u := range resources {
+		err := resourcehelpers.SaveFile([]*unstructured.Unstructured{u}, names[i])
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// toList replaces the resources in the file with a List resource if there are multiple resources
+func (o *Options) toList(path string, resources []*unstructured.Unstructured) (bool, error) {
+	if len(resources) <= 1 {
+		return false, nil
+	}
+	for _, u := range resources {
+		if !resourcehelpers.IsResource(u) {
+			log.Logger().Warnf("not converting file %s to a List as it contains a document which is not a kubernetes resource", termcolor.ColorInfo(path))
+			return false, nil
+		}
+	}
+	items, _ := resourcehelpers.ExpandLists(resources)
+	list := resourcehelpers.NewList(items)
+	return true, resourcehelpers.SaveFile([]*unstructured.Unstructured{list}, path)
+}
@@ -0,0 +1,77 @@
+package convertlist_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/convertlist"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertList(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	listFile := filepath.Join(tmpDir, "list.yaml")
+
+	_, o := convertlist.NewCmdConvertList()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to expand the lists")
+	assert.Equal(t, []string{listFile}, o.Files, "converted files")
+	assertResources(t, listFile, "Service/cheese", "ConfigMap/cheese-config")
+
+	// the documents can be wrapped back into a list
+	_, o = convertlist.NewCmdConvertList()
+	o.Dir = tmpDir
+	o.ToList = true
+	err = o.Run()
+	require.NoError(t, err, "failed to convert to lists")
+	assert.Equal(t, []string{listFile, filepath.Join(tmpDir, "resources.yaml")}, o.Files, "converted files")
+	assertResources(t, listFile, "List/")
+	resources, err := resourcehelpers.LoadFile(listFile)
+	require.NoError(t, err, "failed to load %s", listFile)
+	items, _ := resourcehelpers.ExpandLists(resources)
+	require.Len(t, items, 2, "items of the list")
+	assert.Equal(t, "Service/cheese", resourcehelpers.ResourceKey(items[0]), "first item")
+	assert.Equal(t, "ConfigMap/cheese-config", resourcehelpers.ResourceKey(items[1]), "second item")
+
+	// files which are not kubernetes resources are never converted
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "values.yaml"))
+	require.NoError(t, err, "failed to load values.yaml")
+	assert.Equal(t, "replicas: 2\n---\nimage: cheese\n", string(data), "values.yaml should not be modified")
+
+	// converting again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to convert to lists again")
+	assert.Empty(t, o.Files, "converted files on second run")
+}
+
+func TestConvertListSplit(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := convertlist.NewCmdConvertList()
+	o.Dir = tmpDir
+	o.Split = true
+	err := o.Run()
+	require.NoError(t, err, "failed to split the lists")
+	assertResources(t, filepath.Join(tmpDir, "list.yaml"), "Service/cheese")
+	assertResources(t, filepath.Join(tmpDir, "list2.yaml"), "ConfigMap/cheese-config")
+	assertResources(t, filepath.Join(tmpDir, "resources.yaml"), "ServiceAccount/wine", "Secret/wine-secret")
+
+	o.ToList = true
+	err = o.Run()
+	require.Error(t, err, "should fail with both --split and --to-list")
+}
+
+func assertResources(t *testing.T, path string, expected ...string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	var actual []string
+	for _, u := range resources {
+		actual = append(actual, resourcehelpers.ResourceKey(u))
+	}
+	assert.Equal(t, expected, actual, "resources in %s", path)
+}
@@ -0,0 +1,277 @@
+package dedupelabels
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Reports the labels of the workloads in the given directory tree which are duplicated with the same value on their pod template
+
+		By default the duplicated labels are only reported. If --remove is specified the redundant copies are removed from the pod template or, if --policy metadata is specified, from the metadata of the workload.
+
+		Pod template labels which are used by the spec.selector of the workload or by the selector of any Service, PodDisruptionBudget or NetworkPolicy in the directory tree are never removed as the pods would no longer be selected.
+`)
+
+	cmdExample = templates.Examples(`
+		# reports the labels duplicated on the pod templates of the workloads in the current directory
+		%s resources dedupe-labels
+
+		# removes the redundant copies of the labels from the pod templates
+		%s resources dedupe-labels --dir config-root --remove
+
+		# removes the redundant copies of the labels from the metadata of the deployments
+		%s resources dedupe-labels --kind Deployment --policy metadata --remove
+	`)
+
+	// podSelectorPaths the paths of the pod selectors of the kinds which select pods by their labels
+	podSelectorPaths = map[string][]string{
+		"Service":             {"spec", "selector"},
+		"PodDisruptionBudget": {"spec", "selector", "matchLabels"},
+		"NetworkPolicy":       {"spec", "podSelector", "matchLabels"},
+	}
+)
+
+const (
+	// PolicyPodTemplate removes the redundant labels from the pod template
+	PolicyPodTemplate = "pod-template"
+
+	// PolicyMetadata removes the redundant labels from the metadata of the workload
+	PolicyMetadata = "metadata"
+
+	// ActionWouldRemove the label would be removed with --remove
+	ActionWouldRemove = "would remove"
+
+	// ActionRemoved the label was removed
+	ActionRemoved = "removed"
+
+	// ActionKept the label is kept as it is used by a selector
+	ActionKept = "kept: used by a selector"
+)
+
+var (
+	// Policies the supported removal policies
+	Policies = []string{PolicyPodTemplate, PolicyMetadata}
+)
+
+// Duplicate a label of a workload duplicated on its pod template
+type Duplicate struct {
+	// Path the file containing the workload relative to the directory
+	Path string `json:"path"`
+
+	// Resource the kind and name of the workload
+	Resource string `json:"resource"`
+
+	// Label the key of the label
+	Label string `json:"label"`
+
+	// Value the value of the label
+	Value string `json:"value"`
+
+	// Action what happens to the redundant copy of the label
+	Action string `json:"action"`
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir        string
+	Policy     string
+	Remove     bool
+	Out        io.Writer
+	Duplicates []Duplicate
+}
+
+// NewCmdDedupeLabels creates a command object for the command
+func NewCmdDedupeLabels() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "dedupe-labels",
+		Short:   "Reports the labels of the workloads in the given directory tree which are duplicated with the same value on their pod template",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Policy, "policy", "", PolicyPodTemplate, fmt.Sprintf("where the redundant copies of the labels are removed from. Values: %s", strings.Join(Policies, ", ")))
+	cmd.Flags().BoolVarP(&o.Remove, "remove", "", false, "removes the redundant copies of the labels. By default the labels which would be removed are only reported")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Policy == "" {
+		o.Policy = PolicyPodTemplate
+	}
+	if stringhelpers.StringArrayIndex(Policies, o.Policy) < 0 {
+		return options.InvalidOption("policy", o.Policy, Policies)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	selected, err := o.selectedPodLabels()
+	if err != nil {
+		return err
+	}
+
+	o.Duplicates = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		return o.dedupe(u, path, selected)
+	}
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to dedupe the labels of resources in dir %s", o.Dir)
+	}
+
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "FILE", Field: "Path"},
+		output.Column{Header: "RESOURCE", Field: "Resource"},
+		output.Column{Header: "LABEL", Value: func(item interface{}) string {
+			d := item.(Duplicate)
+			return d.Label + "=" + d.Value
+		}},
+		output.Column{Header: "ACTION", Field: "Action"},
+	)
+	err = renderer.Render(o.Duplicates)
+	if err != nil {
+		return errors.Wrap(err, "failed to render the duplicated labels")
+	}
+	if !o.Remove {
+		removable := 0
+		for _, d := range o.Duplicates {
+			if d.Action == ActionWouldRemove {
+				removable++
+			}
+		}
+		if removable > 0 {
+			log.Logger().Infof("use --remove to remove %d redundant labels", removable)
+		}
+	}
+	return nil
+}
+
+// dedupe finds the labels of the workload duplicated on its pod template removing the redundant copies if enabled
+func (o *Options) dedupe(u *unstructured.Unstructured, path string, selected map[string]bool) (bool, error) {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if len(podSpecPath) < 2 {
+		return false, nil
+	}
+	labelsPath := append(append([]string{}, podSpecPath[:len(podSpecPath)-1]...), "metadata", "labels")
+	podLabels, _, err := unstructured.NestedStringMap(u.Object, labelsPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get %s", strings.Join(labelsPath, "."))
+	}
+	labels := u.GetLabels()
+	matchLabels, _, err := unstructured.NestedStringMap(u.Object, "spec", "selector", "matchLabels")
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get spec.selector.matchLabels")
+	}
+
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		rel = path
+	}
+	modified := false
+	for _, k := range stringhelpers.SortedMapKeys(labels) {
+		v := labels[k]
+		podValue, ok := podLabels[k]
+		if !ok || podValue != v {
+			continue
+		}
+		d := Duplicate{
+			Path:     filepath.ToSlash(rel),
+			Resource: u.GetKind() + "/" + u.GetName(),
+			Label:    k,
+			Value:    v,
+			Action:   ActionWouldRemove,
+		}
+		selectorValue, inSelector := matchLabels[k]
+		if o.Policy == PolicyPodTemplate && ((inSelector && selectorValue == v) || selected[k+"="+v]) {
+			d.Action = ActionKept
+		} else if o.Remove {
+			d.Action = ActionRemoved
+			if o.Policy == PolicyPodTemplate {
+				delete(podLabels, k)
+			} else {
+				delete(labels, k)
+			}
+			modified = true
+		}
+		o.Duplicates = append(o.Duplicates, d)
+	}
+	if !modified {
+		return false, nil
+	}
+	if o.Policy == PolicyMetadata && len(labels) == 0 {
+		u.SetLabels(nil)
+		return true, nil
+	} else if o.Policy == PolicyMetadata {
+		u.SetLabels(labels)
+		return true, nil
+	}
+	if len(podLabels) == 0 {
+		unstructured.RemoveNestedField(u.Object, labelsPath...)
+		return true, nil
+	}
+	err = unstructured.SetNestedStringMap(u.Object, podLabels, labelsPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to set %s", strings.Join(labelsPath, "."))
+	}
+	return true, nil
+}
+
+// selectedPodLabels returns the 'key=value' labels used by the pod selectors in the directory tree
+func (o *Options) selectedPodLabels() (map[string]bool, error) {
+	answer := map[string]bool{}
+	visitFn := func(u *unstructured.Unstructured, path string) error {
+		selectorPath := podSelectorPaths[u.GetKind()]
+		if selectorPath == nil {
+			return nil
+		}
+		selector, _, err := unstructured.NestedStringMap(u.Object, selectorPath...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s", strings.Join(selectorPath, "."))
+		}
+		for k, v := range selector {
+			answer[k+"="+v] = true
+		}
+		return nil
+	}
+	err := resourcehelpers.VisitFiles(o.Dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, visitFn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the pod selectors in dir %s", o.Dir)
+	}
+	return answer, nil
+}
@@ -0,0 +1,77 @@
+package dedupelabels_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/dedupelabels"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDedupeLabelsReport(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	source, err := ioutil.ReadFile(deployFile)
+	require.NoError(t, err, "failed to load %s", deployFile)
+
+	out := &bytes.Buffer{}
+	_, o := dedupelabels.NewCmdDedupeLabels()
+	o.Dir = tmpDir
+	o.Out = out
+	err = o.Run()
+	require.NoError(t, err, "failed to report duplicate labels")
+
+	actualFile := filepath.Join(tmpDir, "actual.txt")
+	err = ioutil.WriteFile(actualFile, out.Bytes(), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", actualFile)
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected.txt"), actualFile)
+
+	data, err := ioutil.ReadFile(deployFile)
+	require.NoError(t, err, "failed to load %s", deployFile)
+	assert.Equal(t, string(source), string(data), "the report should not modify the file")
+}
+
+func TestDedupeLabelsRemove(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+
+	_, o := dedupelabels.NewCmdDedupeLabels()
+	o.Dir = tmpDir
+	o.Out = &bytes.Buffer{}
+	o.Remove = true
+	err := o.Run()
+	require.NoError(t, err, "failed to remove duplicate labels")
+	require.Len(t, o.Duplicates, 3, "duplicates")
+	assert.Equal(t, dedupelabels.ActionRemoved, o.Duplicates[1].Action, "action of %s", o.Duplicates[1].Label)
+
+	u := testhelpers.LoadResource(t, deployFile)
+	podLabels, _, _ := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"app": "web", "tier": "frontend", "version": "2.0"}, podLabels, "pod template labels")
+	assert.Len(t, u.GetLabels(), 4, "the labels of the deployment should not be modified")
+
+	// the metadata policy removes the labels from the deployment
+	tmpDir = testhelpers.CopyTestData(t, "test_data")
+	deployFile = filepath.Join(tmpDir, "deploy.yaml")
+	_, o = dedupelabels.NewCmdDedupeLabels()
+	o.Dir = tmpDir
+	o.Out = &bytes.Buffer{}
+	o.Remove = true
+	o.Policy = dedupelabels.PolicyMetadata
+	err = o.Run()
+	require.NoError(t, err, "failed to remove duplicate labels from the metadata")
+
+	u = testhelpers.LoadResource(t, deployFile)
+	assert.Equal(t, map[string]string{"version": "1.0"}, u.GetLabels(), "labels of the deployment")
+	podLabels, _, _ = unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "labels")
+	assert.Len(t, podLabels, 4, "the pod template labels should not be modified")
+
+	o.Policy = "cheese"
+	err = o.Run()
+	assert.Error(t, err, "should fail for an invalid policy")
+}
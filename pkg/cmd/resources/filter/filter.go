@@ -0,0 +1,159 @@
+package filter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Copies the resources matching the selector into a new directory tree preserving their relative paths
+
+		Files containing multiple documents are split so that only the matching resources are copied. This can be used to carve a per team or per environment slice out of a large tree.
+`)
+
+	cmdExample = templates.Examples(`
+		# copies all the resources in the staging namespace
+		%s resources filter --namespace jx-staging --out-dir /tmp/staging
+
+		# copies all the resources matching a label selector
+		%s resources filter --dir config-root --selector team=payments --out-dir /tmp/payments
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir       string
+	OutDir    string
+	Resources int
+	Split     int
+	Files     []string
+}
+
+// NewCmdFilter creates a command object for the command
+func NewCmdFilter() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "filter",
+		Short:   "Copies the resources matching the selector into a new directory tree preserving their relative paths",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "", "the output directory the matching resources are copied to")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.OutDir == "" {
+		return errors.Errorf("missing option: --out-dir")
+	}
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find abs dir of %s", o.Dir)
+	}
+	outDir, err := filepath.Abs(o.OutDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find abs dir of %s", o.OutDir)
+	}
+	rel, err := filepath.Rel(dir, outDir)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Errorf("the --out-dir %s cannot be inside the --dir %s", o.OutDir, o.Dir)
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	matches := map[string][]*unstructured.Unstructured{}
+	err = resourcehelpers.VisitFiles(o.Dir, o.Selector, func(u *unstructured.Unstructured, path string) error {
+		if matches[path] == nil {
+			paths = append(paths, path)
+		}
+		matches[path] = append(matches[path], u)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to filter resources in dir %s", o.Dir)
+	}
+
+	o.Resources = 0
+	o.Split = 0
+	o.Files = nil
+	for _, path := range paths {
+		resources := matches[path]
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get relative path of %s", path)
+		}
+		outFile := filepath.Join(o.OutDir, rel)
+		err = os.MkdirAll(filepath.Dir(outFile), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir for %s", outFile)
+		}
+
+		split, err := o.copyResources(path, outFile, resources)
+		if err != nil {
+			return err
+		}
+		if split {
+			o.Split++
+		}
+		o.Resources += len(resources)
+		o.Files = append(o.Files, outFile)
+	}
+	log.Logger().Infof("copied %d resources in %d files to %s splitting %d files", o.Resources, len(o.Files), termcolor.ColorInfo(o.OutDir), o.Split)
+	return nil
+}
+
+// copyResources copies the file as is if every document matched so that the formatting is preserved,
+// otherwise only the matching resources are saved. Returns true if the file was split
+func (o *Options) copyResources(path, outFile string, resources []*unstructured.Unstructured) (bool, error) {
+	all, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(all) != len(resources) {
+		return true, resourcehelpers.SaveFile(resources, outFile)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	err = ioutil.WriteFile(outFile, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to save file %s", outFile)
+	}
+	return false, nil
+}
@@ -0,0 +1,79 @@
+package filter_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/filter"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterNamespace(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	_, o := filter.NewCmdFilter()
+	o.Dir = "test_data"
+	o.OutDir = outDir
+	o.Namespaces = []string{"jx-staging"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run filter")
+
+	assert.Equal(t, []string{
+		filepath.Join(outDir, "namespaces", "jx-staging", "config.yaml"),
+		filepath.Join(outDir, "namespaces", "jx-staging", "web.yaml"),
+	}, o.Files, "copied files")
+	assert.Equal(t, 4, o.Resources, "copied resources")
+	assert.Equal(t, 0, o.Split, "split files")
+	assert.NoDirExists(t, filepath.Join(outDir, "namespaces", "jx-production"))
+	assert.NoDirExists(t, filepath.Join(outDir, "cluster"))
+
+	// files which match completely are copied as is
+	expected, err := ioutil.ReadFile(filepath.Join("test_data", "namespaces", "jx-staging", "web.yaml"))
+	require.NoError(t, err, "failed to read source file")
+	actual, err := ioutil.ReadFile(filepath.Join(outDir, "namespaces", "jx-staging", "web.yaml"))
+	require.NoError(t, err, "failed to read copied file")
+	assert.Equal(t, string(expected), string(actual), "the copied file should be unchanged")
+}
+
+func TestFilterSelectorSplitsFiles(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	_, o := filter.NewCmdFilter()
+	o.Dir = "test_data"
+	o.OutDir = outDir
+	o.Labels = map[string]string{"team": "payments"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run filter")
+
+	assert.Equal(t, []string{
+		filepath.Join(outDir, "namespaces", "jx-production", "web.yaml"),
+		filepath.Join(outDir, "namespaces", "jx-staging", "config.yaml"),
+		filepath.Join(outDir, "namespaces", "jx-staging", "web.yaml"),
+	}, o.Files, "copied files")
+	assert.Equal(t, 4, o.Resources, "copied resources")
+	assert.Equal(t, 1, o.Split, "split files")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(outDir, "namespaces", "jx-staging", "config.yaml"))
+	require.NoError(t, err, "failed to load split file")
+	require.Len(t, resources, 1, "resources in the split file")
+	assert.Equal(t, "payments", resources[0].GetName(), "the matching resource should be copied")
+}
+
+func TestFilterInvalidOptions(t *testing.T) {
+	_, o := filter.NewCmdFilter()
+	o.Dir = "test_data"
+	err := o.Run()
+	assert.Error(t, err, "should fail without --out-dir")
+
+	_, o = filter.NewCmdFilter()
+	o.Dir = "test_data"
+	o.OutDir = filepath.Join("test_data", "output")
+	err = o.Run()
+	assert.Error(t, err, "should fail if --out-dir is inside --dir")
+	assert.NoDirExists(t, o.OutDir)
+}
@@ -0,0 +1,217 @@
+package fromhelm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm/strip"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/rename"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Renders a helm chart and its values into a plain tree of kubernetes resources which can be committed to git
+
+		The chart is rendered via 'helm template' then:
+
+		* the helm specific labels and annotations such as 'helm.sh/chart', 'app.kubernetes.io/managed-by' and 'checksum/*' are removed unless --no-strip is specified
+		* the helm hook resources such as tests are removed unless --keep-hooks is specified in which case they are kept as regular resources
+		* the files are split into a file per resource which is renamed to the canonical '<name>-<kind>.yaml' file name so that the file names do not depend on the template names of the chart
+
+		The output directory is removed first unless --no-clean is specified so that the generated tree only contains the resources of the chart and is the same every time the command is run with the same chart and values.
+`)
+
+	cmdExample = templates.Examples(`
+		# renders a local chart into a directory
+		%s resources from-helm --chart charts/myapp --values values.yaml --out-dir config-root/namespaces/jx/myapp
+
+		# renders a remote chart into a namespace keeping its hooks
+		%s resources from-helm --repo https://charts.jenkins.io --chart jenkins --version 3.0.0 --namespace jx --out-dir config-root/namespaces/jx/jenkins --keep-hooks
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	OutDir            string
+	HelmBinary        string
+	ReleaseName       string
+	Namespace         string
+	Chart             string
+	Version           string
+	Repository        string
+	ValuesFiles       []string
+	SetValues         []string
+	KindAbbreviations []string
+	KeepHooks         bool
+	NoStrip           bool
+	NoClean           bool
+	Files             []string
+	CommandRunner     cmdrunner.CommandRunner
+}
+
+// NewCmdFromHelm creates a command object for the command
+func NewCmdFromHelm() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "from-helm",
+		Short:   "Renders a helm chart and its values into a plain tree of kubernetes resources which can be committed to git",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "", "the directory to generate the resources into")
+	cmd.Flags().StringVarP(&o.Chart, "chart", "c", "", "the local directory of the chart or the name of the chart in the --repo")
+	cmd.Flags().StringVarP(&o.ReleaseName, "release", "r", "", "the name of the helm release. Defaults to the name of the chart")
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "", "", "the namespace of the helm release which is set on the namespaced resources which do not have a namespace")
+	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "the version of the chart. If not specified then the latest one is used")
+	cmd.Flags().StringVarP(&o.Repository, "repo", "", "", "the helm chart repository to fetch the chart from")
+	cmd.Flags().StringArrayVarP(&o.ValuesFiles, "values", "f", nil, "the helm values.yaml files used to render the chart")
+	cmd.Flags().StringArrayVarP(&o.SetValues, "set", "", nil, "the helm values to set on the command line as 'key=value'")
+	cmd.Flags().StringArrayVarP(&o.KindAbbreviations, "kind-abbreviation", "", nil, "the abbreviation of a kind used in the file names of the form 'Kind=abbreviation' which overrides the built in abbreviations")
+	cmd.Flags().BoolVarP(&o.KeepHooks, "keep-hooks", "", false, "keeps the helm hook resources such as tests as regular resources")
+	cmd.Flags().BoolVarP(&o.NoStrip, "no-strip", "", false, "does not remove the helm specific labels and annotations")
+	cmd.Flags().BoolVarP(&o.NoClean, "no-clean", "", false, "does not remove the output directory before generating the resources")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Chart == "" {
+		return options.MissingOption("chart")
+	}
+	if o.OutDir == "" {
+		return options.MissingOption("out-dir")
+	}
+	if o.ReleaseName == "" {
+		o.ReleaseName = filepath.Base(o.Chart)
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.DefaultCommandRunner
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// lets render and process the resources in a temporary directory so that the output directory is only
+	// modified if every step succeeds
+	dir := filepath.Join(tmpDir, "resources")
+	to := &helm.TemplateOptions{
+		OutDir:        dir,
+		HelmBinary:    o.HelmBinary,
+		ReleaseName:   o.ReleaseName,
+		Namespace:     o.Namespace,
+		Chart:         o.Chart,
+		ValuesFiles:   o.ValuesFiles,
+		SetValues:     o.SetValues,
+		Version:       o.Version,
+		Repository:    o.Repository,
+		NoSplit:       true,
+		IncludeCRDs:   true,
+		SkipTests:     !o.KeepHooks,
+		NoStripLabels: o.NoStrip,
+		CommandRunner: o.CommandRunner,
+	}
+	err = to.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to render the chart %s", o.Chart)
+	}
+
+	if !o.NoStrip || !o.KeepHooks {
+		so := &strip.Options{
+			Dir:         dir,
+			RemoveHooks: !o.KeepHooks,
+		}
+		if !o.NoStrip {
+			so.Labels = strip.DefaultLabels
+			so.Annotations = strip.DefaultAnnotations
+		}
+		err = so.Run()
+		if err != nil {
+			return errors.Wrapf(err, "failed to strip the helm metadata of the chart %s", o.Chart)
+		}
+	}
+
+	ro := &rename.Options{
+		Dir:               dir,
+		SplitFirst:        true,
+		KindAbbreviations: o.KindAbbreviations,
+	}
+	err = ro.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to rename the resources of the chart %s", o.Chart)
+	}
+
+	o.Files, err = relativeFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if !o.NoClean {
+		err = os.RemoveAll(o.OutDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove output directory %s", o.OutDir)
+		}
+	}
+	err = os.MkdirAll(o.OutDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create output directory %s", o.OutDir)
+	}
+	err = files.CopyDirOverwrite(dir, o.OutDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy the generated resources to %s", o.OutDir)
+	}
+	log.Logger().Infof("generated %s files from chart %s into %s", termcolor.ColorInfo(fmt.Sprintf("%d", len(o.Files))), termcolor.ColorInfo(o.Chart), o.OutDir)
+	return nil
+}
+
+// relativeFiles returns the sorted slash separated paths of the YAML files relative to the directory
+func relativeFiles(dir string) ([]string, error) {
+	var answer []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		answer = append(answer, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the generated files in dir %s", dir)
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
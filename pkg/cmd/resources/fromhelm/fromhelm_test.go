@@ -0,0 +1,155 @@
+package fromhelm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/fromhelm"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// renderedResources simulates the resources rendered by helm template including a test hook
+const renderedResources = `apiVersion: v1
+kind: Service
+metadata:
+  name: myapp
+  labels:
+    app: myapp
+    app.kubernetes.io/managed-by: Helm
+    helm.sh/chart: myapp-1.2.3
+  annotations:
+    meta.helm.sh/release-name: myapp
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+  labels:
+    app: myapp
+    helm.sh/chart: myapp-1.2.3
+spec:
+  selector:
+    matchLabels:
+      app: myapp
+  template:
+    metadata:
+      labels:
+        app: myapp
+        helm.sh/chart: myapp-1.2.3
+      annotations:
+        checksum/config: 0123456789abcdef
+    spec:
+      containers:
+      - name: myapp
+        image: myapp:1.0.0
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: myapp-test-connection
+  annotations:
+    helm.sh/hook: test
+`
+
+func TestFromHelm(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "config-root", "namespaces", "jx", "myapp")
+	staleFile := filepath.Join(outDir, "stale.yaml")
+	err := os.MkdirAll(outDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(staleFile, []byte("stale: true\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err)
+
+	chart := filepath.Join("test_data", "myapp")
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--values", "values.yaml", "--namespace", "jx", "--include-crds", "myapp", chart},
+			Callback: fakeHelmTemplate,
+		},
+	)
+
+	_, o := fromhelm.NewCmdFromHelm()
+	o.HelmBinary = "helm"
+	o.Chart = chart
+	o.OutDir = outDir
+	o.Namespace = "jx"
+	o.ValuesFiles = []string{"values.yaml"}
+	o.CommandRunner = runner.Run
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command")
+	runner.Verify(t)
+
+	assert.Equal(t, []string{"myapp-deploy.yaml", "myapp-svc.yaml"}, o.Files, "generated files")
+	assert.NoFileExists(t, staleFile, "the output dir should have been cleaned")
+	assert.NoFileExists(t, filepath.Join(outDir, "app.yaml"), "the rendered file should have been split")
+
+	u := testhelpers.LoadResource(t, filepath.Join(outDir, "myapp-svc.yaml"))
+	assert.Equal(t, "jx", u.GetNamespace(), "namespace of the service")
+	assert.Equal(t, map[string]string{"app": "myapp"}, u.GetLabels(), "labels of the service")
+	assert.Empty(t, u.GetAnnotations(), "annotations of the service")
+
+	u = testhelpers.LoadResource(t, filepath.Join(outDir, "myapp-deploy.yaml"))
+	podAnnotations, found, err := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "annotations")
+	require.NoError(t, err, "failed to get the pod template annotations")
+	assert.False(t, found && len(podAnnotations) > 0, "the pod template annotations should have been removed but got %v", podAnnotations)
+
+	// lets check the output is the same when run again
+	runner = testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--values", "values.yaml", "--namespace", "jx", "--include-crds", "myapp", chart},
+			Callback: fakeHelmTemplate,
+		},
+	)
+	o.CommandRunner = runner.Run
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command again")
+	assert.Equal(t, []string{"myapp-deploy.yaml", "myapp-svc.yaml"}, o.Files, "generated files when run again")
+}
+
+func TestFromHelmKeepHooks(t *testing.T) {
+	outDir := t.TempDir()
+	chart := filepath.Join("test_data", "myapp")
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:     "helm",
+			Args:     []string{"template", "--output-dir", "*", "--include-crds", "mine", chart},
+			Callback: fakeHelmTemplate,
+		},
+	)
+
+	_, o := fromhelm.NewCmdFromHelm()
+	o.HelmBinary = "helm"
+	o.Chart = chart
+	o.ReleaseName = "mine"
+	o.OutDir = outDir
+	o.KeepHooks = true
+	o.KindAbbreviations = []string{"Pod=po"}
+	o.CommandRunner = runner.Run
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run the command")
+	runner.Verify(t)
+
+	assert.Equal(t, []string{"myapp-deploy.yaml", "myapp-svc.yaml", "myapp-test-connection-po.yaml"}, o.Files, "generated files")
+	u := testhelpers.LoadResource(t, filepath.Join(outDir, "myapp-test-connection-po.yaml"))
+	assert.Empty(t, u.GetAnnotations(), "the hook annotations should have been removed")
+}
+
+func fakeHelmTemplate(c *cmdrunner.Command) error {
+	// helm renders the templates into a directory named after the release
+	dir := filepath.Join(c.Args[2], c.Args[len(c.Args)-2], "templates")
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "app.yaml"), []byte(renderedResources), files.DefaultFileWritePermissions)
+}
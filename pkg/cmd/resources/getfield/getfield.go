@@ -0,0 +1,174 @@
+package getfield
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Displays the value of a field for all the kubernetes resources in the given directory tree which match the selector.
+
+		If the path contains a list then the rest of the path is evaluated for each element of the list. The values are displayed in a table with one row for each value or with --output json or --output yaml as a map of the resource keys to their values where resources without the field have a null value.
+`)
+
+	cmdExample = templates.Examples(`
+		# displays the image of every container of every Deployment
+		%s resources get-field --kind Deployment --path spec.template.spec.containers.image
+
+		# displays the replicas of all Deployments as JSON
+		%s resources get-field --kind Deployment --path spec.replicas --output json
+	`)
+
+	// AbsentValue the text displayed when a field is not present
+	AbsentValue = "<absent>"
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir     string
+	Path    string
+	Out     io.Writer
+	Results []Result
+}
+
+// Result the field values for a resource
+type Result struct {
+	// Key the key of the resource
+	Key string
+
+	// Path the file containing the resource
+	Path string
+
+	// Values the values of the field which are empty if the field is not present
+	Values []interface{}
+}
+
+// fieldValue a row of the table of the field values
+type fieldValue struct {
+	Key  string
+	Text string
+}
+
+// NewCmdGetField creates a command object for the command
+func NewCmdGetField() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "get-field",
+		Short:   "Displays the value of a field for all the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the dot separated path of the field to display such as 'spec.replicas'")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Path == "" {
+		return options.MissingOption("path")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	err := o.Options.Validate()
+	if err != nil {
+		return err
+	}
+	fields := strings.Split(o.Path, ".")
+
+	o.Results = nil
+	visitFn := func(u *unstructured.Unstructured, path string) error {
+		values, _ := resourcehelpers.GetFieldValues(u.Object, fields...)
+		o.Results = append(o.Results, Result{
+			Key:    resourcehelpers.ResourceKey(u),
+			Path:   path,
+			Values: values,
+		})
+		return nil
+	}
+	err = resourcehelpers.VisitFiles(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get field %s in dir %s", o.Path, o.Dir)
+	}
+
+	return o.render()
+}
+
+// Values returns the field values of the results keyed by the resource key. Resources without the field have a
+// single nil value so that they are reported as absent
+func (o *Options) Values() map[string][]interface{} {
+	answer := map[string][]interface{}{}
+	for _, r := range o.Results {
+		values := r.Values
+		if len(values) == 0 {
+			values = []interface{}{nil}
+		}
+		answer[r.Key] = append(answer[r.Key], values...)
+	}
+	return answer
+}
+
+func (o *Options) render() error {
+	if o.Format == output.FormatJSON || o.Format == output.FormatYAML {
+		return output.NewRenderer(o.Out, o.Options).Render(o.Values())
+	}
+	var rows []fieldValue
+	for _, r := range o.Results {
+		if len(r.Values) == 0 {
+			rows = append(rows, fieldValue{Key: r.Key, Text: AbsentValue})
+			continue
+		}
+		for _, v := range r.Values {
+			text, err := ToText(v)
+			if err != nil {
+				return errors.Wrapf(err, "failed to format value for %s", r.Key)
+			}
+			rows = append(rows, fieldValue{Key: r.Key, Text: text})
+		}
+	}
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "RESOURCE", Field: "Key"},
+		output.Column{Header: "VALUE", Field: "Text"},
+	)
+	return renderer.Render(rows)
+}
+
+// ToText converts a value to text with complex values converted to JSON
+func ToText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
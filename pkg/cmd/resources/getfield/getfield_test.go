@@ -0,0 +1,86 @@
+package getfield_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/getfield"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFieldContainerImages(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	_, o := getfield.NewCmdGetField()
+	o.Dir = filepath.Join("test_data")
+	o.Path = "spec.template.spec.containers.image"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err, "failed to run get-field")
+
+	expected := `RESOURCE                   VALUE
+Deployment/backend         backend:1.0.0
+Service/backend            <absent>
+jx-staging/Deployment/web  nginx:1.19
+jx-staging/Deployment/web  envoy:1.16
+`
+	assert.Equal(t, expected, buf.String(), "output")
+}
+
+func TestGetFieldJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	_, o := getfield.NewCmdGetField()
+	o.Dir = filepath.Join("test_data")
+	o.Kinds = []string{"Deployment"}
+	o.Path = "spec.replicas"
+	o.Format = "json"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err, "failed to run get-field")
+
+	expected := `{
+  "Deployment/backend": [
+    null
+  ],
+  "jx-staging/Deployment/web": [
+    2
+  ]
+}
+`
+	assert.Equal(t, expected, buf.String(), "the values should be keyed by the resource with absent values as null")
+	assert.Len(t, o.Results, 2, "results")
+}
+
+func TestGetFieldYAML(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	_, o := getfield.NewCmdGetField()
+	o.Dir = filepath.Join("test_data")
+	o.Path = "spec.template.spec.containers.image"
+	o.Format = "yaml"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err, "failed to run get-field")
+
+	expected := `Deployment/backend:
+- backend:1.0.0
+Service/backend:
+- null
+jx-staging/Deployment/web:
+- nginx:1.19
+- envoy:1.16
+`
+	assert.Equal(t, expected, buf.String(), "output")
+}
+
+func TestGetFieldInvalidOutput(t *testing.T) {
+	_, o := getfield.NewCmdGetField()
+	o.Dir = filepath.Join("test_data")
+	o.Path = "spec.replicas"
+	o.Format = "xml"
+	err := o.Run()
+	require.Error(t, err, "should fail for invalid output format")
+}
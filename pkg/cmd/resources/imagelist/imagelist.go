@@ -0,0 +1,312 @@
+package imagelist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lists the container images used by the kubernetes resources in the given directory tree
+
+		The images of the containers, init containers and ephemeral containers of the pods, workloads, jobs and cron jobs are listed once each in alphabetical order. The image and its tag or digest are listed in a table. Use --with-resources to also list the resources which use each image. With --output json or --output yaml the images are mapped to their registry, repository, tag, digest and the resources which use them.
+
+		If --fail-on is specified the command fails if any images match the patterns. A pattern starting with ':' matches the tag of the image where ':latest' also matches images without a tag or digest. Other patterns are matched against the image both as it is written and with its registry such as 'docker.io/library/*' where '*' matches any characters other than '/'.
+`)
+
+	cmdExample = templates.Examples(`
+		# lists the images used in the current directory
+		%s resources image-list
+
+		# lists the images and the resources which use them as JSON
+		%s resources image-list --dir config-root --output json
+
+		# fails if any images use the latest tag or are official docker hub images
+		%s resources image-list --fail-on :latest --fail-on 'docker.io/library/*'
+	`)
+
+	// containerFields the fields of a pod spec which contain containers
+	containerFields = []string{"containers", "initContainers", "ephemeralContainers"}
+)
+
+const (
+	// DefaultRegistry the registry of images which do not specify a registry
+	DefaultRegistry = "docker.io"
+)
+
+// Image a container image and the resources which use it
+type Image struct {
+	// Registry the host of the registry of the image
+	Registry string `json:"registry"`
+
+	// Repository the repository of the image within the registry
+	Repository string `json:"repository"`
+
+	// Tag the tag of the image if specified
+	Tag string `json:"tag,omitempty"`
+
+	// Digest the digest of the image if specified
+	Digest string `json:"digest,omitempty"`
+
+	// Resources the keys of the resources which use the image
+	Resources []string `json:"resources"`
+
+	// Files the files relative to the directory containing the resources which use the image if enabled
+	Files []string `json:"files,omitempty"`
+}
+
+// imageRow a row of the table of images
+type imageRow struct {
+	reference string
+	image     *Image
+}
+
+// Name returns the registry and repository of the image adding the 'library/' prefix of the official docker
+// hub images such as 'docker.io/library/nginx'
+func (i *Image) Name() string {
+	repository := i.Repository
+	if i.Registry == DefaultRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return i.Registry + "/" + repository
+}
+
+// Version returns the tag and digest of the image such as '1.2.3@sha256:abc'
+func (i *Image) Version() string {
+	if i.Tag == "" || i.Digest == "" {
+		return i.Tag + i.Digest
+	}
+	return i.Tag + "@" + i.Digest
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir           string
+	WithResources bool
+	WithFiles     bool
+	FailOn        []string
+	Out           io.Writer
+
+	// Images the images indexed by the image reference
+	Images map[string]*Image
+
+	// Forbidden the image references which match the --fail-on patterns
+	Forbidden []string
+}
+
+// NewCmdImageList creates a command object for the command
+func NewCmdImageList() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "image-list",
+		Aliases: []string{"images"},
+		Short:   "Lists the container images used by the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd, o
+}
+
+// AddFlags adds the flags to the command
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.WithResources, "with-resources", "", o.WithResources, "lists the resources which use each image in tables")
+	cmd.Flags().StringArrayVarP(&o.FailOn, "fail-on", "", nil, "fails if any images match the pattern such as ':latest' or 'docker.io/library/*'. Can be specified multiple times")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Images = map[string]*Image{}
+	visitFn := func(u *unstructured.Unstructured, path string) error {
+		key := resourcehelpers.ResourceKey(u)
+		images, err := ResourceImages(u)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the images of %s in file %s", key, path)
+		}
+		for _, image := range images {
+			img := o.Images[image]
+			if img == nil {
+				img = ParseImage(image)
+				o.Images[image] = img
+			}
+			if stringhelpers.StringArrayIndex(img.Resources, key) < 0 {
+				img.Resources = append(img.Resources, key)
+			}
+			if o.WithFiles {
+				rel, err := filepath.Rel(o.Dir, path)
+				if err != nil {
+					rel = path
+				}
+				if stringhelpers.StringArrayIndex(img.Files, rel) < 0 {
+					img.Files = append(img.Files, rel)
+				}
+			}
+		}
+		return nil
+	}
+	err = resourcehelpers.VisitFiles(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the images in dir %s", o.Dir)
+	}
+	var names []string
+	o.Forbidden = nil
+	for name, img := range o.Images {
+		names = append(names, name)
+		sort.Strings(img.Resources)
+		sort.Strings(img.Files)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if MatchesAnyImagePattern(name, o.FailOn) {
+			o.Forbidden = append(o.Forbidden, name)
+		}
+	}
+
+	err = o.writeImages(names)
+	if err != nil {
+		return err
+	}
+	if len(o.Forbidden) > 0 {
+		return errors.Errorf("found %d forbidden images: %s", len(o.Forbidden), strings.Join(o.Forbidden, ", "))
+	}
+	return nil
+}
+
+// writeImages writes the images in the output format
+func (o *Options) writeImages(names []string) error {
+	if o.Format == output.FormatJSON || o.Format == output.FormatYAML {
+		return output.NewRenderer(o.Out, o.Options).Render(o.Images)
+	}
+	var rows []imageRow
+	for _, name := range names {
+		rows = append(rows, imageRow{reference: name, image: o.Images[name]})
+	}
+	columns := []output.Column{
+		{Header: "IMAGE", Value: func(item interface{}) string {
+			row := item.(imageRow)
+			return strings.TrimSuffix(strings.TrimSuffix(row.reference, "@"+row.image.Digest), ":"+row.image.Tag)
+		}},
+		{Header: "TAG/DIGEST", Value: func(item interface{}) string {
+			return item.(imageRow).image.Version()
+		}},
+	}
+	if o.WithResources {
+		columns = append(columns, output.Column{Header: "RESOURCES", Value: func(item interface{}) string {
+			return strings.Join(item.(imageRow).image.Resources, ",")
+		}})
+	}
+	return output.NewRenderer(o.Out, o.Options, columns...).Render(rows)
+}
+
+// MatchesAnyImagePattern returns true if the image reference matches any of the patterns. A pattern starting with
+// ':' matches the tag where ':latest' also matches an image without a tag or digest. Other patterns are matched
+// against the image as it is written and with its registry such as 'docker.io/library/nginx:1.21'
+func MatchesAnyImagePattern(image string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	img := ParseImage(image)
+	fullName := img.Name()
+	if img.Tag != "" {
+		fullName += ":" + img.Tag
+	}
+	if img.Digest != "" {
+		fullName += "@" + img.Digest
+	}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, ":") {
+			tag := img.Tag
+			if tag == "" && img.Digest == "" {
+				tag = "latest"
+			}
+			if tag != "" && resourcehelpers.MatchesAnyPattern(tag, []string{pattern[1:]}) {
+				return true
+			}
+			continue
+		}
+		if resourcehelpers.MatchesAnyPattern(image, []string{pattern}) || resourcehelpers.MatchesAnyPattern(fullName, []string{pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceImages returns the images of the containers of the pod spec of the resource if it has one
+func ResourceImages(u *unstructured.Unstructured) ([]string, error) {
+	containers, err := resourcehelpers.PodContainers(u, containerFields...)
+	if err != nil {
+		return nil, err
+	}
+	var answer []string
+	for _, c := range containers {
+		image, ok := c.Object["image"].(string)
+		if ok && image != "" {
+			answer = append(answer, image)
+		}
+	}
+	return answer, nil
+}
+
+// ParseImage parses the image reference into its registry, repository, tag and digest such as
+// 'gcr.io/myorg/myapp:1.2.3@sha256:abc'
+func ParseImage(image string) *Image {
+	answer := &Image{Registry: DefaultRegistry}
+	name := image
+	idx := strings.Index(name, "@")
+	if idx >= 0 {
+		answer.Digest = name[idx+1:]
+		name = name[:idx]
+	}
+	idx = strings.LastIndex(name, ":")
+	if idx >= 0 && !strings.Contains(name[idx+1:], "/") {
+		answer.Tag = name[idx+1:]
+		name = name[:idx]
+	}
+	idx = strings.Index(name, "/")
+	if idx >= 0 {
+		host := name[:idx]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			answer.Registry = host
+			name = name[idx+1:]
+		}
+	}
+	answer.Repository = name
+	return answer
+}
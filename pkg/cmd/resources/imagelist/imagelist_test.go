@@ -0,0 +1,189 @@
+package imagelist_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestImageList(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := imagelist.NewCmdImageList()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to list the images")
+	assert.Equal(t, `IMAGE                        TAG/DIGEST
+busybox                      1.33
+envoyproxy/envoy             sha256:0123456789abcdef
+gcr.io/myorg/web             1.2.3
+localhost:5000/tools/backup  latest
+nicolaka/netshoot
+`, out.String(), "images")
+
+	out.Reset()
+	o.WithResources = true
+	o.Kinds = []string{"Pod"}
+	err = o.Run()
+	require.NoError(t, err, "failed to list the images with resources")
+	assert.Equal(t, `IMAGE              TAG/DIGEST  RESOURCES
+gcr.io/myorg/web   1.2.3       Pod/debug
+nicolaka/netshoot              Pod/debug
+`, out.String(), "images of the pods with resources")
+}
+
+func TestImageListJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := imagelist.NewCmdImageList()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Format = "json"
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to list the images")
+
+	expected, err := ioutil.ReadFile(filepath.Join("test_data", "expected.json"))
+	require.NoError(t, err, "failed to load expected.json")
+	assert.JSONEq(t, string(expected), out.String(), "JSON output")
+
+	o.Format = "xml"
+	err = o.Run()
+	require.Error(t, err, "should fail with an invalid output format")
+}
+
+func TestImageListTable(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := imagelist.NewCmdImageList()
+	o.Dir = filepath.Join("test_data", "src")
+	o.WithResources = true
+	o.Out = out
+	o.Namespaces = []string{"jx"}
+	err := o.Run()
+	require.NoError(t, err, "failed to list the images")
+	assert.Equal(t, `IMAGE                        TAG/DIGEST               RESOURCES
+busybox                      1.33                     jx/CronJob/backup,jx/Deployment/web
+envoyproxy/envoy             sha256:0123456789abcdef  jx/Deployment/web
+gcr.io/myorg/web             1.2.3                    jx/Deployment/web
+localhost:5000/tools/backup  latest                   jx/CronJob/backup
+`, out.String(), "table")
+}
+
+func TestImageListFailOn(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := imagelist.NewCmdImageList()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Out = out
+	o.FailOn = []string{":latest"}
+	err := o.Run()
+	require.Error(t, err, "should fail for the forbidden images")
+	assert.Equal(t, "found 2 forbidden images: localhost:5000/tools/backup:latest, nicolaka/netshoot", err.Error(), "error message")
+	assert.Contains(t, out.String(), "busybox", "the images should still be listed")
+
+	o.FailOn = []string{"docker.io/library/*", "gcr.io/otherorg/*"}
+	err = o.Run()
+	require.Error(t, err, "should fail for the forbidden images")
+	assert.Equal(t, []string{"busybox:1.33"}, o.Forbidden, "forbidden images")
+
+	o.FailOn = []string{"quay.io/*"}
+	err = o.Run()
+	require.NoError(t, err, "should not fail if no images match")
+	assert.Empty(t, o.Forbidden, "forbidden images")
+}
+
+func TestMatchesAnyImagePattern(t *testing.T) {
+	testCases := []struct {
+		image    string
+		pattern  string
+		expected bool
+	}{
+		{image: "nginx", pattern: ":latest", expected: true},
+		{image: "nginx:latest", pattern: ":latest", expected: true},
+		{image: "nginx:1.21", pattern: ":latest"},
+		{image: "nginx@sha256:abc", pattern: ":latest"},
+		{image: "nginx:1.21-alpine", pattern: ":*-alpine", expected: true},
+		{image: "nginx:1.21", pattern: "docker.io/library/*", expected: true},
+		{image: "docker.io/library/nginx", pattern: "docker.io/library/*", expected: true},
+		{image: "myorg/nginx:1.21", pattern: "docker.io/library/*"},
+		{image: "myorg/nginx:1.21", pattern: "docker.io/myorg/*", expected: true},
+		{image: "gcr.io/myorg/web:1.2.3", pattern: "gcr.io/myorg/*", expected: true},
+		{image: "gcr.io/myorg/web:1.2.3", pattern: "gcr.io/*"},
+		{image: "gcr.io/myorg/web:1.2.3", pattern: "gcr.io/myorg/web:1.2.3", expected: true},
+		{image: "localhost:5000/app:dev", pattern: "localhost:5000/*", expected: true},
+	}
+	for _, tc := range testCases {
+		actual := imagelist.MatchesAnyImagePattern(tc.image, []string{tc.pattern})
+		assert.Equal(t, tc.expected, actual, "image %s with pattern %s", tc.image, tc.pattern)
+	}
+	assert.False(t, imagelist.MatchesAnyImagePattern("nginx", nil), "no patterns")
+}
+
+func TestResourceImages(t *testing.T) {
+	podSpec := map[string]interface{}{
+		"initContainers": []interface{}{
+			map[string]interface{}{"name": "init", "image": "busybox:1.33"},
+		},
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "gcr.io/myorg/app:1.0.0"},
+			map[string]interface{}{"name": "no-image"},
+		},
+		"ephemeralContainers": []interface{}{
+			map[string]interface{}{"name": "debug", "image": "nicolaka/netshoot"},
+		},
+	}
+	template := map[string]interface{}{"spec": podSpec}
+	expected := []string{"gcr.io/myorg/app:1.0.0", "busybox:1.33", "nicolaka/netshoot"}
+
+	testCases := []struct {
+		kind     string
+		spec     map[string]interface{}
+		expected []string
+	}{
+		{kind: "Pod", spec: podSpec, expected: expected},
+		{kind: "Deployment", spec: map[string]interface{}{"template": template}, expected: expected},
+		{kind: "StatefulSet", spec: map[string]interface{}{"template": template}, expected: expected},
+		{kind: "DaemonSet", spec: map[string]interface{}{"template": template}, expected: expected},
+		{kind: "ReplicaSet", spec: map[string]interface{}{"template": template}, expected: expected},
+		{kind: "Job", spec: map[string]interface{}{"template": template}, expected: expected},
+		{kind: "CronJob", spec: map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"template": template}}}, expected: expected},
+		{kind: "Deployment", spec: map[string]interface{}{"replicas": int64(1)}},
+		{kind: "Service", spec: podSpec},
+		{kind: "ConfigMap"},
+	}
+	for _, tc := range testCases {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       tc.kind,
+			"metadata":   map[string]interface{}{"name": "test"},
+		}}
+		if tc.spec != nil {
+			u.Object["spec"] = tc.spec
+		}
+		actual, err := imagelist.ResourceImages(u)
+		require.NoError(t, err, "failed to get the images of %s", tc.kind)
+		assert.Equal(t, tc.expected, actual, "images of %s", tc.kind)
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected imagelist.Image
+	}{
+		{image: "nginx", expected: imagelist.Image{Registry: "docker.io", Repository: "nginx"}},
+		{image: "nginx:1.21", expected: imagelist.Image{Registry: "docker.io", Repository: "nginx", Tag: "1.21"}},
+		{image: "myorg/app:1.0.0", expected: imagelist.Image{Registry: "docker.io", Repository: "myorg/app", Tag: "1.0.0"}},
+		{image: "ghcr.io/jenkins-x/jx-gitops:0.1.2@sha256:abc", expected: imagelist.Image{Registry: "ghcr.io", Repository: "jenkins-x/jx-gitops", Tag: "0.1.2", Digest: "sha256:abc"}},
+		{image: "localhost:5000/app", expected: imagelist.Image{Registry: "localhost:5000", Repository: "app"}},
+		{image: "localhost/app:dev", expected: imagelist.Image{Registry: "localhost", Repository: "app", Tag: "dev"}},
+	}
+	for _, tc := range testCases {
+		actual := imagelist.ParseImage(tc.image)
+		require.NotNil(t, actual, "image %s", tc.image)
+		assert.Equal(t, tc.expected, *actual, "image %s", tc.image)
+	}
+}
@@ -0,0 +1,104 @@
+package merge
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Deep merges a YAML file into all the kubernetes resources in the given directory tree which match the selector
+
+		Objects are merged recursively and a null value removes a field. Lists are replaced unless a strategy is specified via --list-merge. The containers, initContainers, env, volumes, volumeMounts, imagePullSecrets and ports lists are merged by their key field by default like kubernetes strategic merge patches.
+`)
+
+	cmdExample = templates.Examples(`
+		# merges the file into all the Deployments in the current directory
+		%s resources merge --file sidecar.yaml --kind Deployment
+
+		# appends the tolerations rather than replacing them
+		%s resources merge --file tolerations.yaml --list-merge spec.template.spec.tolerations=append
+
+		# merges the args of the containers by replacing them
+		%s resources merge --file args.yaml --list-merge spec.template.spec.containers=merge-by-key=name --list-merge spec.template.spec.containers.args=replace
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	resourcehelpers.MergeOptions
+	Dir   string
+	File  string
+	Count int
+}
+
+// NewCmdMerge creates a command object for the command
+func NewCmdMerge() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "merge",
+		Short:   "Deep merges a YAML file into all the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "the YAML file to merge into the resources")
+	o.Selector.AddFlags(cmd)
+	o.MergeOptions.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.File == "" {
+		return options.MissingOption("file")
+	}
+	err := o.MergeOptions.Validate()
+	if err != nil {
+		return err
+	}
+	docs, err := resourcehelpers.LoadFile(o.File)
+	if err != nil {
+		return err
+	}
+	if len(docs) != 1 {
+		return errors.Errorf("the file %s should contain a single YAML document but has %d", o.File, len(docs))
+	}
+	patch := docs[0].Object
+
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		merged := o.Merge(runtime.DeepCopyJSON(u.Object), runtime.DeepCopyJSON(patch))
+		if reflect.DeepEqual(merged, u.Object) {
+			return false, nil
+		}
+		u.Object = merged
+		log.Logger().Infof("merged %s into %s %s in file %s", o.File, u.GetKind(), termcolor.ColorInfo(u.GetName()), path)
+		o.Count++
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to merge %s into the resources in dir %s", o.File, o.Dir)
+	}
+	log.Logger().Infof("modified %d resources", o.Count)
+	return nil
+}
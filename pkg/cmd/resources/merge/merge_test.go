@@ -0,0 +1,167 @@
+package merge_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/merge"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMerge(t *testing.T) {
+	testCases := []struct {
+		name       string
+		listMerges []string
+		noDefaults bool
+		expected   string
+	}{
+		{
+			name: "defaults",
+			expected: `containers:
+- args:
+  - --debug
+  env:
+  - name: LOG_LEVEL
+    value: debug
+  - name: REGION
+    value: us-east1
+  - name: TRACING
+    value: "true"
+  image: nginx:1.21
+  name: web
+  volumeMounts:
+  - mountPath: /config
+    name: config
+  - mountPath: /cache
+    name: cache
+- image: envoy:1.17
+  name: sidecar
+tolerations:
+- key: spot
+  operator: Exists
+volumes:
+- configMap:
+    name: web-config-v2
+  name: config
+- emptyDir: {}
+  name: cache
+`,
+		},
+		{
+			name:       "custom",
+			listMerges: []string{"spec.template.spec.tolerations=append", "spec.template.spec.containers.args=append", "spec.template.spec.containers.env=replace", "spec.template.spec.volumes=append"},
+			expected: `containers:
+- args:
+  - --port=8080
+  - --debug
+  env:
+  - name: LOG_LEVEL
+    value: debug
+  - name: TRACING
+    value: "true"
+  image: nginx:1.21
+  name: web
+  volumeMounts:
+  - mountPath: /config
+    name: config
+  - mountPath: /cache
+    name: cache
+- image: envoy:1.17
+  name: sidecar
+tolerations:
+- key: dedicated
+  operator: Exists
+- key: spot
+  operator: Exists
+volumes:
+- configMap:
+    name: web-config
+  name: config
+- configMap:
+    name: web-config-v2
+  name: config
+- emptyDir: {}
+  name: cache
+`,
+		},
+		{
+			name:       "no defaults",
+			noDefaults: true,
+			listMerges: []string{"spec.template.spec.containers=merge-by-key=name"},
+			expected: `containers:
+- args:
+  - --debug
+  env:
+  - name: LOG_LEVEL
+    value: debug
+  - name: TRACING
+    value: "true"
+  image: nginx:1.21
+  name: web
+  volumeMounts:
+  - mountPath: /cache
+    name: cache
+- image: envoy:1.17
+  name: sidecar
+tolerations:
+- key: spot
+  operator: Exists
+volumes:
+- configMap:
+    name: web-config-v2
+  name: config
+- emptyDir: {}
+  name: cache
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+		_, o := merge.NewCmdMerge()
+		o.Dir = filepath.Join(tmpDir, "resources")
+		o.File = filepath.Join(tmpDir, "patch.yaml")
+		o.Kinds = []string{"Deployment"}
+		o.ListMerges = tc.listMerges
+		o.NoDefaultListMerges = tc.noDefaults
+		err := o.Run()
+		require.NoError(t, err, "failed to run merge for %s", tc.name)
+		assert.Equal(t, 1, o.Count, "modified count for %s", tc.name)
+
+		resources, err := resourcehelpers.LoadFile(filepath.Join(o.Dir, "deploy.yaml"))
+		require.NoError(t, err)
+		require.Len(t, resources, 2)
+		podSpec := resources[0].Object["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"]
+		data, err := yaml.Marshal(podSpec)
+		require.NoError(t, err, "failed to marshal pod spec for %s", tc.name)
+		assert.Equal(t, tc.expected, string(data), "pod spec for %s", tc.name)
+
+		ports := resources[1].Object["spec"].(map[string]interface{})["ports"]
+		assert.Equal(t, []interface{}{map[string]interface{}{"port": int64(80)}}, ports, "the Service should not match the selector for %s", tc.name)
+	}
+}
+
+func TestMergeInvalidListMerge(t *testing.T) {
+	for _, listMerge := range []string{"spec.template.spec.tolerations", "spec.template.spec.tolerations=prepend", "spec.template.spec.containers=merge-by-key=", "=append"} {
+		_, o := merge.NewCmdMerge()
+		o.Dir = "test_data"
+		o.File = filepath.Join("test_data", "patch.yaml")
+		o.ListMerges = []string{listMerge}
+		err := o.Run()
+		require.Error(t, err, "should have failed for %s", listMerge)
+		t.Logf("got expected error for %s: %s", listMerge, err.Error())
+	}
+}
+
+func TestParseListMergeStrategy(t *testing.T) {
+	for _, text := range []string{"replace", "append", "merge-by-key=name"} {
+		s, err := resourcehelpers.ParseListMergeStrategy(text)
+		require.NoError(t, err, "failed to parse %s", text)
+		assert.Equal(t, text, s.String())
+	}
+}
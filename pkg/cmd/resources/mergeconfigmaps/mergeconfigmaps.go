@@ -0,0 +1,338 @@
+package mergeconfigmaps
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Merges the ConfigMaps in the given directory tree which share a target name into a single ConfigMap
+
+		By default the target name of each ConfigMap is the value of its --group-label label. If --target-name is specified all the ConfigMaps which match the selector are merged into a ConfigMap of that name. ConfigMaps are only merged with the ConfigMaps of the same namespace.
+
+		The data and binaryData keys of the fragments are merged into the ConfigMap with the target name if there is one or the first fragment otherwise which is renamed. The other fragments are removed along with any files which no longer contain any documents. Fails if fragments have different values for the same key unless --overwrite is specified in which case the last value wins.
+`)
+
+	cmdExample = templates.Examples(`
+		# merges the ConfigMaps which have a 'gitops.jenkins-x.io/merge-configmap' label into the ConfigMap named by the label
+		%s resources merge-configmaps --dir config-root
+
+		# merges all the ConfigMaps with a name starting with 'dashboard-' into one
+		%s resources merge-configmaps --name 'dashboard-*' --target-name dashboards --overwrite
+	`)
+
+	// dataFields the fields of the ConfigMaps which are merged
+	dataFields = []string{"data", "binaryData"}
+)
+
+const (
+	// DefaultGroupLabel the default label of the ConfigMaps whose value is the name of the ConfigMap to merge into
+	DefaultGroupLabel = "gitops.jenkins-x.io/merge-configmap"
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir        string
+	TargetName string
+	GroupLabel string
+	Overwrite  bool
+	Results    []Result
+}
+
+// Result the result of merging the ConfigMaps of a target
+type Result struct {
+	// Namespace the namespace of the ConfigMaps
+	Namespace string
+
+	// Name the name of the merged ConfigMap
+	Name string
+
+	// Fragments the keys of the ConfigMaps which have been merged
+	Fragments []string
+
+	// Keys the keys of the merged data
+	Keys []string
+
+	// Conflicts the keys which had different values in the fragments
+	Conflicts []string
+}
+
+// fragment a ConfigMap to be merged
+type fragment struct {
+	path  string
+	index int
+	u     *unstructured.Unstructured
+}
+
+// group the fragments to be merged into the same ConfigMap
+type group struct {
+	namespace string
+	name      string
+	fragments []*fragment
+}
+
+// NewCmdMergeConfigMaps creates a command object for the command
+func NewCmdMergeConfigMaps() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "merge-configmaps",
+		Short:   "Merges the ConfigMaps in the given directory tree which share a target name into a single ConfigMap",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.TargetName, "target-name", "t", "", "the name of the ConfigMap to merge all the matching ConfigMaps into. If not specified the value of the --group-label is used")
+	cmd.Flags().StringVarP(&o.GroupLabel, "group-label", "", DefaultGroupLabel, "the label whose value is the name of the ConfigMap to merge into")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "if fragments have different values for the same key the last value is used rather than failing")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.GroupLabel == "" {
+		o.GroupLabel = DefaultGroupLabel
+	}
+	groups, err := o.findGroups()
+	if err != nil {
+		return err
+	}
+
+	o.Results = nil
+	merged := map[*fragment]*unstructured.Unstructured{}
+	removed := map[*fragment]bool{}
+	for _, g := range groups {
+		base, u, r, err := o.merge(g)
+		if err != nil {
+			return err
+		}
+		if base == nil {
+			continue
+		}
+		merged[base] = u
+		for _, f := range g.fragments {
+			if f != base {
+				removed[f] = true
+			}
+		}
+		o.Results = append(o.Results, *r)
+	}
+	if len(o.Results) == 0 {
+		log.Logger().Infof("no ConfigMaps to merge")
+		return nil
+	}
+
+	err = o.saveFiles(groups, merged, removed)
+	if err != nil {
+		return err
+	}
+	for _, r := range o.Results {
+		log.Logger().Infof("merged %d ConfigMaps into %s with keys: %s", len(r.Fragments), termcolor.ColorInfo(resourceName(r.Namespace, r.Name)), strings.Join(r.Keys, ", "))
+		if len(r.Conflicts) > 0 {
+			log.Logger().Warnf("overwrote the conflicting keys of %s: %s", resourceName(r.Namespace, r.Name), strings.Join(r.Conflicts, ", "))
+		}
+	}
+	return nil
+}
+
+// findGroups finds the ConfigMaps grouped by their target namespace and name in the order they are found
+func (o *Options) findGroups() ([]*group, error) {
+	var groups []*group
+	groupMap := map[string]*group{}
+	var configMaps []*fragment
+	visitFn := func(u *unstructured.Unstructured, path string, index int) error {
+		if u.GetKind() == "ConfigMap" {
+			configMaps = append(configMaps, &fragment{path: path, index: index, u: u})
+		}
+		return nil
+	}
+	err := resourcehelpers.VisitDocuments(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the ConfigMaps in dir %s", o.Dir)
+	}
+
+	targetOf := func(u *unstructured.Unstructured) string {
+		if o.TargetName != "" {
+			return o.TargetName
+		}
+		return u.GetLabels()[o.GroupLabel]
+	}
+	for _, f := range configMaps {
+		target := targetOf(f.u)
+		if target == "" {
+			continue
+		}
+		key := resourceName(f.u.GetNamespace(), target)
+		g := groupMap[key]
+		if g == nil {
+			g = &group{namespace: f.u.GetNamespace(), name: target}
+			groupMap[key] = g
+			groups = append(groups, g)
+		}
+		g.fragments = append(g.fragments, f)
+	}
+
+	// lets include any existing ConfigMap with the target name which is not labelled
+	for _, f := range configMaps {
+		g := groupMap[resourceName(f.u.GetNamespace(), f.u.GetName())]
+		if g != nil && targetOf(f.u) == "" {
+			g.fragments = append([]*fragment{f}, g.fragments...)
+		}
+	}
+	return groups, nil
+}
+
+// merge merges the fragments of the group returning the fragment to replace with the merged ConfigMap or nil if
+// there is nothing to merge
+func (o *Options) merge(g *group) (*fragment, *unstructured.Unstructured, *Result, error) {
+	var base *fragment
+	for _, f := range g.fragments {
+		if f.u.GetName() == g.name {
+			base = f
+			break
+		}
+	}
+	if base == nil {
+		base = g.fragments[0]
+	}
+	if len(g.fragments) == 1 && base.u.GetName() == g.name {
+		// a single ConfigMap which is already named after the target only needs its label removing
+		if _, ok := base.u.GetLabels()[o.GroupLabel]; !ok {
+			return nil, nil, nil, nil
+		}
+	}
+
+	u := base.u.DeepCopy()
+	u.SetName(g.name)
+	labels := u.GetLabels()
+	delete(labels, o.GroupLabel)
+	if len(labels) == 0 {
+		u.SetLabels(nil)
+	} else {
+		u.SetLabels(labels)
+	}
+
+	r := &Result{Namespace: g.namespace, Name: g.name}
+	conflicts := map[string]bool{}
+	for _, field := range dataFields {
+		data := map[string]string{}
+		for _, f := range g.fragments {
+			m, _, err := unstructured.NestedStringMap(f.u.Object, field)
+			if err != nil {
+				return nil, nil, nil, errors.Wrapf(err, "failed to get the %s of ConfigMap %s in file %s", field, f.u.GetName(), f.path)
+			}
+			for k, v := range m {
+				existing, ok := data[k]
+				if ok && existing != v {
+					conflicts[k] = true
+				}
+				data[k] = v
+			}
+		}
+		if len(data) == 0 {
+			unstructured.RemoveNestedField(u.Object, field)
+			continue
+		}
+		err := unstructured.SetNestedStringMap(u.Object, data, field)
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to set the %s of ConfigMap %s", field, g.name)
+		}
+		for k := range data {
+			r.Keys = append(r.Keys, k)
+		}
+	}
+	for _, f := range g.fragments {
+		r.Fragments = append(r.Fragments, resourcehelpers.ResourceKey(f.u))
+	}
+	for k := range conflicts {
+		r.Conflicts = append(r.Conflicts, k)
+	}
+	sort.Strings(r.Keys)
+	sort.Strings(r.Conflicts)
+	if len(r.Conflicts) > 0 && !o.Overwrite {
+		return nil, nil, nil, errors.Errorf("cannot merge the ConfigMaps %s into %s as they have different values for the keys: %s. Use --overwrite to use the last value", strings.Join(r.Fragments, ", "), resourceName(g.namespace, g.name), strings.Join(r.Conflicts, ", "))
+	}
+	return base, u, r, nil
+}
+
+// saveFiles replaces the base fragments with the merged ConfigMaps and removes the other fragments
+func (o *Options) saveFiles(groups []*group, merged map[*fragment]*unstructured.Unstructured, removed map[*fragment]bool) error {
+	type change struct {
+		u      *unstructured.Unstructured
+		remove bool
+	}
+	fileChanges := map[string]map[int]change{}
+	var paths []string
+	add := func(f *fragment, c change) {
+		if fileChanges[f.path] == nil {
+			fileChanges[f.path] = map[int]change{}
+			paths = append(paths, f.path)
+		}
+		fileChanges[f.path][f.index] = c
+	}
+	for _, g := range groups {
+		for _, f := range g.fragments {
+			if u := merged[f]; u != nil {
+				add(f, change{u: u})
+			} else if removed[f] {
+				add(f, change{remove: true})
+			}
+		}
+	}
+
+	for _, path := range paths {
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		var answer []*unstructured.Unstructured
+		for i, u := range resources {
+			c, ok := fileChanges[path][i]
+			switch {
+			case !ok:
+				answer = append(answer, u)
+			case !c.remove:
+				answer = append(answer, c.u)
+			}
+		}
+		if len(answer) == 0 {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove file %s", path)
+			}
+			log.Logger().Debugf("removed file %s", termcolor.ColorInfo(path))
+			continue
+		}
+		err = resourcehelpers.SaveFile(answer, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resourceName(ns, name string) string {
+	if ns == "" {
+		return name
+	}
+	return ns + "/" + name
+}
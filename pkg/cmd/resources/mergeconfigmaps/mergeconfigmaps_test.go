@@ -0,0 +1,64 @@
+package mergeconfigmaps_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/mergeconfigmaps"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeConfigMapsByLabel(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := mergeconfigmaps.NewCmdMergeConfigMaps()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to merge the ConfigMaps")
+
+	require.Len(t, o.Results, 2, "results")
+	assert.Equal(t, "monitoring/grafana-dashboards", o.Results[0].Namespace+"/"+o.Results[0].Name, "first target")
+	assert.Equal(t, []string{"logo.png", "nodes.json", "overview.json", "pods.json"}, o.Results[0].Keys, "merged keys of the first target")
+	assert.Equal(t, []string{
+		"monitoring/ConfigMap/grafana-dashboards",
+		"monitoring/ConfigMap/grafana-dashboards-nodes",
+		"monitoring/ConfigMap/grafana-dashboards-pods",
+	}, o.Results[0].Fragments, "fragments of the first target")
+	assert.Equal(t, "settings", o.Results[1].Name, "second target")
+	assert.Equal(t, []string{"a", "b", "shared"}, o.Results[1].Keys, "merged keys of the second target")
+	assert.Empty(t, o.Results[1].Conflicts, "the same value is not a conflict")
+
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-grafana.yaml"), filepath.Join(tmpDir, "grafana.yaml"))
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-pods.yaml"), filepath.Join(tmpDir, filepath.Join("dashboards", "pods.yaml")))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "dashboards", "nodes.yaml"), "the file of the merged fragment should be removed")
+
+	// merging again should not do anything
+	err = o.Run()
+	require.NoError(t, err, "failed to merge the ConfigMaps again")
+	assert.Empty(t, o.Results, "results of the second run")
+}
+
+func TestMergeConfigMapsConflicts(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "conflict"))
+
+	_, o := mergeconfigmaps.NewCmdMergeConfigMaps()
+	o.Dir = tmpDir
+	o.TargetName = "config"
+	err := o.Run()
+	require.Error(t, err, "should fail with conflicting keys")
+	assert.Contains(t, err.Error(), "url", "the error should name the conflicting key")
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "config.yaml"))
+	require.NoError(t, err, "failed to load config.yaml")
+	assert.Contains(t, string(data), "name: config-b", "the file should not be modified on a conflict")
+
+	o.Overwrite = true
+	err = o.Run()
+	require.NoError(t, err, "failed to merge the ConfigMaps with --overwrite")
+	require.Len(t, o.Results, 1, "results")
+	assert.Equal(t, []string{"url"}, o.Results[0].Conflicts, "conflicts")
+	testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-conflict.yaml"), filepath.Join(tmpDir, "config.yaml"))
+}
@@ -0,0 +1,232 @@
+package normalizequantities
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Normalizes the resource quantities of the kubernetes resources in the given directory tree
+
+		Equivalent quantities such as '1000m' and '1' or '1024Mi' and '1Gi' are replaced with the canonical form of the kubernetes quantity parser so that the same quantity is always written in the same way.
+
+		Only the quantity fields are modified. These are the requests and limits of the containers, init containers and ephemeral containers and the overhead of pods, the requests and limits of PersistentVolumeClaims and the volumeClaimTemplates of StatefulSets, the capacity of PersistentVolumes, the hard limits of ResourceQuotas and the limits of LimitRanges. Values which are not valid quantities such as templates are left untouched.
+`)
+
+	cmdExample = templates.Examples(`
+		# normalizes the quantities of all the resources
+		%s resources normalize-quantities --dir config-root
+
+		# lists the quantities which would be normalized without modifying them
+		%s resources normalize-quantities --dry-run
+	`)
+
+	// podSpecQuantityFields the paths of the maps of quantities in the pod spec
+	podSpecQuantityFields = [][]string{
+		{"containers", "resources", "requests"},
+		{"containers", "resources", "limits"},
+		{"initContainers", "resources", "requests"},
+		{"initContainers", "resources", "limits"},
+		{"ephemeralContainers", "resources", "requests"},
+		{"ephemeralContainers", "resources", "limits"},
+		{"overhead"},
+	}
+
+	// QuantityFields the paths of the maps of quantities indexed by kind other than the pod specs
+	QuantityFields = map[string][][]string{
+		"PersistentVolumeClaim": {
+			{"spec", "resources", "requests"},
+			{"spec", "resources", "limits"},
+		},
+		"StatefulSet": {
+			{"spec", "volumeClaimTemplates", "spec", "resources", "requests"},
+			{"spec", "volumeClaimTemplates", "spec", "resources", "limits"},
+		},
+		"PersistentVolume": {
+			{"spec", "capacity"},
+		},
+		"ResourceQuota": {
+			{"spec", "hard"},
+		},
+		"LimitRange": {
+			{"spec", "limits", "max"},
+			{"spec", "limits", "min"},
+			{"spec", "limits", "default"},
+			{"spec", "limits", "defaultRequest"},
+			{"spec", "limits", "maxLimitRequestRatio"},
+		},
+	}
+)
+
+// Change a quantity which is normalized
+type Change struct {
+	// File the file containing the resource
+	File string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Field the path of the quantity such as 'spec.template.spec.containers[web].resources.limits.cpu'
+	Field string
+
+	// From the original quantity
+	From string
+
+	// To the normalized quantity
+	To string
+}
+
+// String returns a description of the change
+func (c *Change) String() string {
+	return fmt.Sprintf("%s: %s %s: %s => %s", c.File, c.Resource, c.Field, c.From, c.To)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir     string
+	DryRun  bool
+	Changes []Change
+}
+
+// NewCmdNormalizeQuantities creates a command object for the command
+func NewCmdNormalizeQuantities() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "normalize-quantities",
+		Short:   "Normalizes the resource quantities of the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "lists the quantities which would be normalized without modifying any files")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	o.Changes = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		count := len(o.Changes)
+		for _, fields := range quantityFields(u.GetKind()) {
+			o.normalize(u.Object, fields, "", func(field, from, to string) {
+				o.Changes = append(o.Changes, Change{File: path, Resource: resourcehelpers.ResourceKey(u), Field: field, From: from, To: to})
+			})
+		}
+		return !o.DryRun && len(o.Changes) > count, nil
+	}
+	err := resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to normalize the quantities of the resources in dir %s", o.Dir)
+	}
+
+	verb := "normalized"
+	if o.DryRun {
+		verb = "would normalize"
+	}
+	for i := range o.Changes {
+		log.Logger().Infof("%s %s", verb, termcolor.ColorInfo(o.Changes[i].String()))
+	}
+	log.Logger().Infof("%s %d quantities", verb, len(o.Changes))
+	return nil
+}
+
+// quantityFields returns the paths of the maps of quantities of the kind
+func quantityFields(kind string) [][]string {
+	answer := append([][]string{}, QuantityFields[kind]...)
+	podSpecPath := resourcehelpers.PodSpecPath(kind)
+	if podSpecPath != nil {
+		for _, fields := range podSpecQuantityFields {
+			answer = append(answer, append(append([]string{}, podSpecPath...), fields...))
+		}
+	}
+	return answer
+}
+
+// normalize walks the fields of the value, iterating over the elements of any lists, and normalizes the
+// quantities of the map at the end of the path
+func (o *Options) normalize(value interface{}, fields []string, path string, fn func(field, from, to string)) {
+	switch v := value.(type) {
+	case []interface{}:
+		for i, e := range v {
+			id := strconv.Itoa(i)
+			if m, ok := e.(map[string]interface{}); ok {
+				metadata, _ := m["metadata"].(map[string]interface{})
+				if name, ok := m["name"].(string); ok && name != "" {
+					id = name
+				} else if name, ok := metadata["name"].(string); ok && name != "" {
+					id = name
+				}
+			}
+			o.normalize(e, fields, path+"["+id+"]", fn)
+		}
+	case map[string]interface{}:
+		if len(fields) == 0 {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				from, to, ok := normalizeQuantity(v[k])
+				if ok {
+					v[k] = to
+					fn(strings.TrimPrefix(path+"."+k, "."), from, to)
+				}
+			}
+			return
+		}
+		child, ok := v[fields[0]]
+		if !ok {
+			return
+		}
+		o.normalize(child, fields[1:], path+"."+fields[0], fn)
+	}
+}
+
+// normalizeQuantity returns the original and canonical form of the quantity and true if they are different.
+// Returns false if the value is not a valid quantity
+func normalizeQuantity(value interface{}) (string, string, bool) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case int:
+		text = strconv.Itoa(v)
+	default:
+		return "", "", false
+	}
+	q, err := resource.ParseQuantity(strings.TrimSpace(text))
+	if err != nil {
+		return "", "", false
+	}
+	to := q.String()
+	if to == text {
+		return "", "", false
+	}
+	return text, to, true
+}
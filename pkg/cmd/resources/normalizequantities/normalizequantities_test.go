@@ -0,0 +1,75 @@
+package normalizequantities_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/normalizequantities"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeQuantities(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	err = files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	storageFile := filepath.Join(tmpDir, "storage.yaml")
+
+	_, o := normalizequantities.NewCmdNormalizeQuantities()
+	o.Dir = tmpDir
+	o.DryRun = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run a dry run")
+	assert.Len(t, o.Changes, 5, "changes")
+	data, err := ioutil.ReadFile(deployFile)
+	require.NoError(t, err, "failed to load file")
+	assert.Contains(t, string(data), "cpu: 1000m", "the files should not be modified in a dry run")
+
+	o.DryRun = false
+	err = o.Run()
+	require.NoError(t, err, "failed to normalize the quantities")
+
+	var fields []string
+	for _, c := range o.Changes {
+		fields = append(fields, c.String())
+	}
+	assert.Equal(t, []string{
+		deployFile + ": jx/Deployment/web spec.template.spec.containers[web].resources.requests.memory: 1024Mi => 1Gi",
+		deployFile + ": jx/Deployment/web spec.template.spec.containers[web].resources.limits.cpu: 1000m => 1",
+		deployFile + ": jx/Deployment/web spec.template.spec.initContainers[migrate].resources.limits.cpu: 0.5 => 500m",
+		storageFile + ": jx/StatefulSet/db spec.volumeClaimTemplates[data].spec.resources.requests.storage: 10240Mi => 10Gi",
+		storageFile + ": jx/ResourceQuota/quota spec.hard.requests.cpu: 4000m => 4",
+	}, fields, "changes")
+
+	resources := loadResources(t, deployFile)
+	deploy := resources[0]
+	containers, _, err := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err, "failed to get containers")
+	web := containers[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "1Gi"},
+		"limits":   map[string]interface{}{"cpu": "1", "memory": "2Gi"},
+	}, web["resources"], "resources of the web container")
+	assert.Equal(t, "1000m", web["env"].([]interface{})[0].(map[string]interface{})["value"], "the env var should not be modified")
+	assert.EqualValues(t, 2, containers[1].(map[string]interface{})["resources"].(map[string]interface{})["limits"].(map[string]interface{})["cpu"], "the canonical number should not be modified")
+	assert.Equal(t, "1024Mi", deploy.GetAnnotations()["memory"], "the annotations should not be modified")
+
+	resources = loadResources(t, storageFile)
+	assert.Equal(t, "1024Mi", resources[2].Object["data"].(map[string]interface{})["memory"], "the config map should not be modified")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to normalize the quantities again")
+	assert.Empty(t, o.Changes, "changes on the second run")
+}
+
+func loadResources(t *testing.T, path string) []*unstructured.Unstructured {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load file %s", path)
+	return resources
+}
@@ -0,0 +1,206 @@
+package order
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Outputs the files or resources in the given directory tree in an order which is safe to apply
+
+		The resources are sorted by the install order of their kind so that Namespaces and CustomResourceDefinitions come before the resources which depend on them. Resources whose kind is defined by a CustomResourceDefinition in the directory tree come after all the other resources.
+
+		With --output files the relative paths of the files are output one per line where each file is ordered by the first resource it contains. With --output yaml the resources are output as a single multi-document YAML.
+`)
+
+	cmdExample = templates.Examples(`
+		# outputs the files in the order they should be applied
+		%s resources order --dir config-root
+
+		# applies the files in order
+		%s resources order --dir config-root | xargs -n1 kubectl apply -f
+
+		# saves the ordered resources to a single file
+		%s resources order --output yaml --file all.yaml
+	`)
+
+	// OutputFormats the supported output formats
+	OutputFormats = []string{"files", "yaml"}
+)
+
+// Options the options for the command
+type Options struct {
+	Dir    string
+	Output string
+	File   string
+	Out    io.Writer
+
+	// Files the relative paths of the files containing resources in the order they should be applied
+	Files []string
+
+	// Resources the resources in the order they should be applied
+	Resources []*unstructured.Unstructured
+}
+
+// NewCmdOrder creates a command object for the command
+func NewCmdOrder() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "order",
+		Short:   "Outputs the files or resources in the given directory tree in an order which is safe to apply",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "files", fmt.Sprintf("the output format. Values: %s", strings.Join(OutputFormats, ", ")))
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "the file to save the output to. If not specified the output is written to stdout")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Output == "" {
+		o.Output = "files"
+	}
+	if stringhelpers.StringArrayIndex(OutputFormats, o.Output) < 0 {
+		return options.InvalidOption("output", o.Output, OutputFormats)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	fileResources := map[string][]*unstructured.Unstructured{}
+	o.Files = nil
+	o.Resources = nil
+	err = filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		resources, err := resourcehelpers.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		resources, _ = resourcehelpers.ExpandLists(resources)
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		rel = filepath.ToSlash(rel)
+		for _, u := range resources {
+			if !resourcehelpers.IsResource(u) {
+				continue
+			}
+			if fileResources[rel] == nil {
+				o.Files = append(o.Files, rel)
+			}
+			fileResources[rel] = append(fileResources[rel], u)
+			o.Resources = append(o.Resources, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the resources in dir %s", o.Dir)
+	}
+
+	rankFn := NewRankFunc(o.Resources)
+	resourcehelpers.SortResources(o.Resources, rankFn)
+
+	// each file is ordered by the first resource it needs to apply
+	fileRanks := map[string]int{}
+	for _, f := range o.Files {
+		rank := -1
+		for _, u := range fileResources[f] {
+			r := rankFn(u)
+			if rank < 0 || r < rank {
+				rank = r
+			}
+		}
+		fileRanks[f] = rank
+	}
+	sort.SliceStable(o.Files, func(i, j int) bool {
+		a := o.Files[i]
+		b := o.Files[j]
+		if fileRanks[a] != fileRanks[b] {
+			return fileRanks[a] < fileRanks[b]
+		}
+		return a < b
+	})
+
+	var data []byte
+	if o.Output == "yaml" {
+		data, err = resourcehelpers.ToYAML(o.Resources)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the resources")
+		}
+	} else {
+		for _, f := range o.Files {
+			data = append(data, []byte(f+"\n")...)
+		}
+	}
+
+	if o.File != "" {
+		err = ioutil.WriteFile(o.File, data, files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", o.File)
+		}
+		return nil
+	}
+	_, err = o.Out.Write(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to write the output")
+	}
+	return nil
+}
+
+// NewRankFunc returns a function which ranks the resources by the install order of their kind. Resources whose
+// kind is defined by one of the CustomResourceDefinitions are ranked after all the other resources
+func NewRankFunc(resources []*unstructured.Unstructured) func(u *unstructured.Unstructured) int {
+	customKinds := map[string]bool{}
+	for _, u := range resources {
+		if u.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+		if kind != "" {
+			customKinds[group+"/"+kind] = true
+		}
+	}
+	return func(u *unstructured.Unstructured) int {
+		if customKinds[u.GroupVersionKind().Group+"/"+u.GetKind()] {
+			return len(resourcehelpers.InstallOrder) + 1
+		}
+		return resourcehelpers.KindRank(u.GetKind())
+	}
+}
@@ -0,0 +1,73 @@
+package order_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/order"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+func TestOrderFiles(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := order.NewCmdOrder()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to order the files")
+
+	expected := []string{
+		"cluster/namespaces.yaml",
+		"cluster/crds.yaml",
+		"namespaces/jx/deployment.yaml",
+		"namespaces/jx/ingress.yaml",
+		"namespaces/jx/certificate.yaml",
+	}
+	assert.Equal(t, expected, o.Files, "ordered files")
+	assert.Equal(t, "cluster/namespaces.yaml\ncluster/crds.yaml\nnamespaces/jx/deployment.yaml\nnamespaces/jx/ingress.yaml\nnamespaces/jx/certificate.yaml\n", out.String(), "output")
+}
+
+func TestOrderYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+	actualFile := filepath.Join(tmpDir, "all.yaml")
+
+	_, o := order.NewCmdOrder()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Output = "yaml"
+	o.File = actualFile
+	err := o.Run()
+	require.NoError(t, err, "failed to order the resources")
+
+	var kinds []string
+	for _, u := range o.Resources {
+		kinds = append(kinds, u.GetKind())
+	}
+	assert.Equal(t, []string{"Namespace", "CustomResourceDefinition", "Service", "Deployment", "Ingress", "ServiceMonitor", "Certificate"}, kinds, "ordered kinds")
+
+	expectedFile := filepath.Join("test_data", "expected.yaml")
+	if generateTestOutput {
+		data, err := ioutil.ReadFile(actualFile)
+		require.NoError(t, err, "failed to load %s", actualFile)
+		err = ioutil.WriteFile(expectedFile, data, files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", expectedFile)
+	}
+	testhelpers.AssertTextFilesEqual(t, expectedFile, actualFile, "ordered resources")
+}
+
+func TestOrderInvalidOutput(t *testing.T) {
+	_, o := order.NewCmdOrder()
+	o.Dir = filepath.Join("test_data", "src")
+	o.Output = "json"
+	err := o.Run()
+	require.Error(t, err, "should fail for an invalid output")
+}
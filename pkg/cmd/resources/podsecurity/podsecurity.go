@@ -0,0 +1,295 @@
+package podsecurity
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds secure defaults to the security contexts of the workloads in the given directory tree so that they meet the restricted Pod Security Standard
+
+		The security context of the pods of the Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs matching the selector defaults to 'runAsNonRoot: true' and a 'RuntimeDefault' seccompProfile. The security context of each container and init container defaults to 'allowPrivilegeEscalation: false' and dropping all capabilities.
+
+		Only the missing fields are added. Fields which are already specified are left untouched unless --overwrite is specified in which case they are replaced by the secure values. Containers which drop some capabilities but not 'ALL' are also left untouched unless --overwrite is specified. With --overwrite any runAsNonRoot or seccompProfile of a container which would override the secure value of the pod is replaced too.
+
+		Individual defaults can be skipped via --skip.
+`)
+
+	cmdExample = templates.Examples(`
+		# adds the secure defaults to all the workloads
+		%s resources pod-security
+
+		# replaces any insecure settings of the deployments in a namespace
+		%s resources pod-security --dir config-root/namespaces/jx --kind Deployment --overwrite
+
+		# adds the secure defaults except for the seccomp profile
+		%s resources pod-security --skip seccompProfile
+	`)
+
+	// Settings the secure defaults which can be skipped
+	Settings = []string{SettingRunAsNonRoot, SettingSeccompProfile, SettingAllowPrivilegeEscalation, SettingCapabilities}
+
+	// runtimeDefaultSeccompProfile the default seccomp profile of the container runtime
+	runtimeDefaultSeccompProfile = map[string]interface{}{"type": "RuntimeDefault"}
+)
+
+const (
+	// SettingRunAsNonRoot the 'runAsNonRoot: true' default of the pod security context
+	SettingRunAsNonRoot = "runAsNonRoot"
+
+	// SettingSeccompProfile the 'RuntimeDefault' seccompProfile default of the pod security context
+	SettingSeccompProfile = "seccompProfile"
+
+	// SettingAllowPrivilegeEscalation the 'allowPrivilegeEscalation: false' default of the container security contexts
+	SettingAllowPrivilegeEscalation = "allowPrivilegeEscalation"
+
+	// SettingCapabilities the default of the container security contexts which drops all capabilities
+	SettingCapabilities = "capabilities"
+
+	securityContext = "securityContext"
+)
+
+// Change a field of a security context which was set
+type Change struct {
+	// Resource the key of the resource
+	Resource string
+
+	// Container the name of the container or blank for the security context of the pod
+	Container string
+
+	// Field the field of the security context which was set
+	Field string
+}
+
+// String returns a description of the change
+func (c Change) String() string {
+	if c.Container == "" {
+		return fmt.Sprintf("%s pod %s", c.Resource, c.Field)
+	}
+	return fmt.Sprintf("%s container %s %s", c.Resource, c.Container, c.Field)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir            string
+	Containers     []string
+	InitContainers bool
+	Overwrite      bool
+	Skip           []string
+	Changes        []Change
+	Files          []string
+}
+
+// NewCmdPodSecurity creates a command object for the command
+func NewCmdPodSecurity() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "pod-security",
+		Short:   "Adds secure defaults to the security contexts of the workloads in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Containers, "container", "c", nil, "the names of the containers to modify. If not specified all the containers are modified")
+	cmd.Flags().BoolVarP(&o.InitContainers, "init-containers", "", true, "also adds the defaults to the init containers")
+	cmd.Flags().BoolVarP(&o.Overwrite, "overwrite", "", false, "replaces the settings which are already specified with the secure values")
+	cmd.Flags().StringArrayVarP(&o.Skip, "skip", "", nil, "the defaults which are not added. Supported values: "+strings.Join(Settings, ", "))
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	for _, s := range o.Skip {
+		if stringhelpers.StringArrayIndex(Settings, s) < 0 {
+			return options.InvalidOption("skip", s, Settings)
+		}
+	}
+	if len(o.Skip) == len(Settings) {
+		return errors.Errorf("cannot skip all of the defaults")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Changes = nil
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified, err := o.secure(u)
+		if err != nil || !modified {
+			return false, err
+		}
+		if len(o.Files) == 0 || o.Files[len(o.Files)-1] != path {
+			o.Files = append(o.Files, path)
+		}
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add the pod security defaults to resources in dir %s", o.Dir)
+	}
+	for _, c := range o.Changes {
+		log.Logger().Infof("set %s", termcolor.ColorInfo(c.String()))
+	}
+	log.Logger().Infof("set %d security context fields in %d files", len(o.Changes), len(o.Files))
+	return nil
+}
+
+// secure adds the secure defaults to the security contexts of the pod spec of the resource
+func (o *Options) secure(u *unstructured.Unstructured) (bool, error) {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return false, nil
+	}
+	value, found, err := unstructured.NestedFieldNoCopy(u.Object, podSpecPath...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get %s of %s", strings.Join(podSpecPath, "."), resourcehelpers.ResourceKey(u))
+	}
+	podSpec, ok := value.(map[string]interface{})
+	if !found || !ok {
+		return false, nil
+	}
+	key := resourcehelpers.ResourceKey(u)
+
+	modified := false
+	sc, _ := podSpec[securityContext].(map[string]interface{})
+	if sc == nil {
+		sc = map[string]interface{}{}
+	}
+	changed := o.setField(sc, SettingRunAsNonRoot, true, o.Overwrite, key, "")
+	changed = o.setField(sc, SettingSeccompProfile, runtimeDefaultSeccompProfile, o.Overwrite, key, "") || changed
+	if changed {
+		podSpec[securityContext] = sc
+		modified = true
+	}
+
+	fields := []string{"containers"}
+	if o.InitContainers {
+		fields = append(fields, "initContainers")
+	}
+	for _, field := range fields {
+		containers, _ := podSpec[field].([]interface{})
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			if len(o.Containers) > 0 && stringhelpers.StringArrayIndex(o.Containers, name) < 0 {
+				continue
+			}
+			if o.secureContainer(container, key, name) {
+				modified = true
+			}
+		}
+	}
+	return modified, nil
+}
+
+// secureContainer adds the secure defaults to the security context of the container returning true if it was
+// modified
+func (o *Options) secureContainer(container map[string]interface{}, key, name string) bool {
+	sc, _ := container[securityContext].(map[string]interface{})
+	if sc == nil {
+		sc = map[string]interface{}{}
+	}
+	changed := o.setField(sc, SettingAllowPrivilegeEscalation, false, o.Overwrite, key, name)
+	if !o.skipped(SettingCapabilities) {
+		capabilities, _ := sc[SettingCapabilities].(map[string]interface{})
+		if capabilities == nil {
+			capabilities = map[string]interface{}{}
+		}
+		drop, found := capabilities["drop"]
+		if !found || (o.Overwrite && !dropsAll(drop)) {
+			capabilities["drop"] = []interface{}{"ALL"}
+			sc[SettingCapabilities] = capabilities
+			o.addChange(key, name, SettingCapabilities)
+			changed = true
+		}
+	}
+
+	// lets replace any container settings which override the secure values of the pod
+	if o.Overwrite {
+		if _, ok := sc[SettingRunAsNonRoot]; ok {
+			changed = o.setField(sc, SettingRunAsNonRoot, true, true, key, name) || changed
+		}
+		if _, ok := sc[SettingSeccompProfile]; ok {
+			changed = o.setField(sc, SettingSeccompProfile, runtimeDefaultSeccompProfile, true, key, name) || changed
+		}
+	}
+	if changed {
+		container[securityContext] = sc
+	}
+	return changed
+}
+
+// setField sets the field of the security context to the value if it is missing or if overwrite is enabled and
+// it has a different value returning true if it was modified
+func (o *Options) setField(sc map[string]interface{}, field string, value interface{}, overwrite bool, key, name string) bool {
+	if o.skipped(field) {
+		return false
+	}
+	existing, ok := sc[field]
+	if ok && (!overwrite || reflect.DeepEqual(existing, value)) {
+		return false
+	}
+	if m, isMap := value.(map[string]interface{}); isMap {
+		// lets avoid sharing the default value between resources
+		copied := map[string]interface{}{}
+		for k, v := range m {
+			copied[k] = v
+		}
+		value = copied
+	}
+	sc[field] = value
+	o.addChange(key, name, field)
+	return true
+}
+
+func (o *Options) addChange(key, name, field string) {
+	c := Change{Resource: key, Container: name, Field: field}
+	o.Changes = append(o.Changes, c)
+	log.Logger().Debugf("set %s", c.String())
+}
+
+func (o *Options) skipped(setting string) bool {
+	return stringhelpers.StringArrayIndex(o.Skip, setting) >= 0
+}
+
+// dropsAll returns true if the dropped capabilities include 'ALL'
+func dropsAll(drop interface{}) bool {
+	values, _ := drop.([]interface{})
+	for _, v := range values {
+		if s, ok := v.(string); ok && s == "ALL" {
+			return true
+		}
+	}
+	return false
+}
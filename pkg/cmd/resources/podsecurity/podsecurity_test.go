@@ -0,0 +1,148 @@
+package podsecurity_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/podsecurity"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPodSecurity(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	cronJobFile := filepath.Join(tmpDir, "cronjob.yaml")
+
+	_, o := podsecurity.NewCmdPodSecurity()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to add the pod security defaults")
+
+	assert.Equal(t, []string{cronJobFile, deployFile}, o.Files, "modified files")
+	assert.Equal(t, []string{
+		"jx/CronJob/cleanup pod runAsNonRoot",
+		"jx/Deployment/web pod seccompProfile",
+		"jx/Deployment/web container web allowPrivilegeEscalation",
+		"jx/Deployment/web container web capabilities",
+		"jx/Deployment/web container migrate allowPrivilegeEscalation",
+		"jx/Deployment/web container migrate capabilities",
+	}, changes(o), "changes")
+
+	podSpec := loadPodSpec(t, deployFile, "spec", "template", "spec")
+	assert.Equal(t, map[string]interface{}{
+		"runAsUser":      int64(1000),
+		"runAsNonRoot":   false,
+		"seccompProfile": map[string]interface{}{"type": "RuntimeDefault"},
+	}, podSpec["securityContext"], "the explicit runAsNonRoot of the pod should be kept")
+
+	secure := map[string]interface{}{
+		"allowPrivilegeEscalation": false,
+		"capabilities":             map[string]interface{}{"drop": []interface{}{"ALL"}},
+	}
+	assert.Equal(t, secure, container(t, podSpec, "containers", 0)["securityContext"], "security context of the web container")
+	assert.Equal(t, secure, container(t, podSpec, "initContainers", 0)["securityContext"], "security context of the init container")
+	assert.Equal(t, map[string]interface{}{
+		"allowPrivilegeEscalation": true,
+		"runAsNonRoot":             false,
+		"capabilities": map[string]interface{}{
+			"add":  []interface{}{"NET_BIND_SERVICE"},
+			"drop": []interface{}{"NET_RAW"},
+		},
+	}, container(t, podSpec, "containers", 1)["securityContext"], "the explicit settings of the proxy container should be kept")
+
+	podSpec = loadPodSpec(t, cronJobFile, "spec", "jobTemplate", "spec", "template", "spec")
+	assert.Equal(t, map[string]interface{}{
+		"runAsNonRoot": true,
+		"seccompProfile": map[string]interface{}{
+			"type":             "Localhost",
+			"localhostProfile": "profiles/cleanup.json",
+		},
+	}, podSpec["securityContext"], "the explicit seccompProfile of the cron job should be kept")
+
+	// adding the defaults again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to add the pod security defaults again")
+	assert.Empty(t, o.Files, "modified files on second run")
+	assert.Empty(t, o.Changes, "changes on second run")
+}
+
+func TestPodSecurityOverwrite(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+
+	_, o := podsecurity.NewCmdPodSecurity()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.Containers = []string{"proxy"}
+	o.InitContainers = false
+	o.Overwrite = true
+	o.Skip = []string{podsecurity.SettingSeccompProfile}
+	err := o.Run()
+	require.NoError(t, err, "failed to add the pod security defaults")
+
+	assert.Equal(t, []string{deployFile}, o.Files, "modified files")
+	assert.Equal(t, []string{
+		"jx/Deployment/web pod runAsNonRoot",
+		"jx/Deployment/web container proxy allowPrivilegeEscalation",
+		"jx/Deployment/web container proxy capabilities",
+		"jx/Deployment/web container proxy runAsNonRoot",
+	}, changes(o), "changes")
+
+	podSpec := loadPodSpec(t, deployFile, "spec", "template", "spec")
+	assert.Equal(t, map[string]interface{}{
+		"runAsUser":    int64(1000),
+		"runAsNonRoot": true,
+	}, podSpec["securityContext"], "the runAsNonRoot of the pod should be overwritten")
+	assert.Equal(t, map[string]interface{}{
+		"allowPrivilegeEscalation": false,
+		"runAsNonRoot":             true,
+		"capabilities": map[string]interface{}{
+			"add":  []interface{}{"NET_BIND_SERVICE"},
+			"drop": []interface{}{"ALL"},
+		},
+	}, container(t, podSpec, "containers", 1)["securityContext"], "the settings of the proxy container should be overwritten")
+	assert.Nil(t, container(t, podSpec, "containers", 0)["securityContext"], "the web container does not match")
+	assert.Nil(t, container(t, podSpec, "initContainers", 0)["securityContext"], "init containers should not be modified")
+}
+
+func TestPodSecurityInvalid(t *testing.T) {
+	for _, skip := range [][]string{
+		{"privileged"},
+		podsecurity.Settings,
+	} {
+		_, o := podsecurity.NewCmdPodSecurity()
+		o.Dir = "test_data"
+		o.Skip = skip
+		err := o.Run()
+		assert.Error(t, err, "should fail for --skip %v", skip)
+	}
+}
+
+func changes(o *podsecurity.Options) []string {
+	var answer []string
+	for _, c := range o.Changes {
+		answer = append(answer, c.String())
+	}
+	return answer
+}
+
+func loadPodSpec(t *testing.T, path string, fields ...string) map[string]interface{} {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, 1, "resources in %s", path)
+
+	podSpec, found, err := unstructured.NestedMap(resources[0].Object, fields...)
+	require.NoError(t, err, "failed to get the pod spec of %s", path)
+	require.True(t, found, "no pod spec in %s", path)
+	return podSpec
+}
+
+func container(t *testing.T, podSpec map[string]interface{}, field string, index int) map[string]interface{} {
+	containers, _ := podSpec[field].([]interface{})
+	require.True(t, index < len(containers), "no %s %d", field, index)
+	return containers[index].(map[string]interface{})
+}
@@ -0,0 +1,79 @@
+package prefixnames
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/renamer"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Prefixes the names of the kubernetes resources in the given directory tree such as for a preview environment
+
+		The prefix is added to the name of each resource matching the selector which does not already start with the prefix. Namespaces, CustomResourceDefinitions and APIServices are never renamed.
+
+		The references to the renamed resources from all the resources in the tree are rewritten in the same way as the kustomize namePrefix. These are the ConfigMaps, Secrets and ServiceAccounts used by pods, the Services and TLS Secrets used by Ingresses, the Service of a StatefulSet and the roles and ServiceAccounts of RoleBindings and ClusterRoleBindings. A warning is logged for each reference to a resource which is not in the tree.
+`)
+
+	cmdExample = templates.Examples(`
+		# prefixes the names of all the resources for a preview environment
+		%s resources prefix-names --prefix pr-42-
+
+		# only prefixes the names of the resources in a namespace
+		%s resources prefix-names --dir config-root/namespaces/jx-preview --prefix pr-42-
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	renamer.Renamer
+	Prefix string
+}
+
+// NewCmdPrefixNames creates a command object for the command
+func NewCmdPrefixNames() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "prefix-names",
+		Short:   "Prefixes the names of the kubernetes resources in the given directory tree and the references to them",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Prefix, "prefix", "p", "", "the prefix to add to the names such as 'pr-42-'")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Prefix == "" {
+		return options.MissingOption("prefix")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	return o.Rename(func(name string) (string, bool) {
+		if strings.HasPrefix(name, o.Prefix) {
+			return name, false
+		}
+		return o.Prefix + name, true
+	})
+}
@@ -0,0 +1,170 @@
+package prefixnames_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/prefixnames"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPrefixNames(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := prefixnames.NewCmdPrefixNames()
+	o.Dir = tmpDir
+	o.Prefix = "pr-42-"
+	err := o.Run()
+	require.NoError(t, err, "failed to prefix the names")
+
+	assert.Equal(t, map[string]string{
+		"jx/ConfigMap/web-config":     "pr-42-web-config",
+		"jx/Secret/web-secret":        "pr-42-web-secret",
+		"jx/Secret/web-tls":           "pr-42-web-tls",
+		"jx/Secret/registry":          "pr-42-registry",
+		"jx/Deployment/web":           "pr-42-web",
+		"jx/Ingress/web":              "pr-42-web",
+		"jx/ServiceAccount/web":       "pr-42-web",
+		"jx/Role/web":                 "pr-42-web",
+		"jx/RoleBinding/web":          "pr-42-web",
+		"ClusterRoleBinding/web-view": "pr-42-web-view",
+		"jx/Service/web":              "pr-42-web",
+		"jx/Service/db":               "pr-42-db",
+		"jx/StatefulSet/db":           "pr-42-db",
+		"other/Deployment/other":      "pr-42-other",
+	}, o.RenameMap(), "rename map")
+
+	resources := loadResources(t, tmpDir)
+	assert.Contains(t, resources, "jx/ConfigMap/pr-42-already", "the already prefixed resource should not be renamed")
+	assert.Contains(t, resources, "Namespace/jx", "the namespace should not be renamed")
+
+	deploy := resources["jx/Deployment/pr-42-web"]
+	require.NotNil(t, deploy, "the deployment should be renamed")
+	podSpec := []string{"spec", "template", "spec"}
+	assert.Equal(t, "pr-42-web", nestedString(t, deploy, append(podSpec, "serviceAccountName")...), "service account")
+	assert.Equal(t, []string{"pr-42-registry"}, fieldValues(deploy, append(podSpec, "imagePullSecrets", "name")...), "image pull secrets")
+	assert.Equal(t, []string{"pr-42-web-secret"}, fieldValues(deploy, append(podSpec, "initContainers", "envFrom", "secretRef", "name")...), "init container secret ref")
+	assert.Equal(t, []string{"pr-42-web-config"}, fieldValues(deploy, append(podSpec, "containers", "envFrom", "configMapRef", "name")...), "config map ref")
+	assert.Equal(t, []string{"pr-42-web-secret"}, fieldValues(deploy, append(podSpec, "containers", "env", "valueFrom", "secretKeyRef", "name")...), "secret key ref")
+	assert.Equal(t, []string{"pr-42-web-config", "external-config"}, fieldValues(deploy, append(podSpec, "containers", "env", "valueFrom", "configMapKeyRef", "name")...), "the optional reference should be renamed but not the external config map")
+	assert.Equal(t, []string{"pr-42-web-config"}, fieldValues(deploy, append(podSpec, "volumes", "configMap", "name")...), "config map volume")
+	assert.Equal(t, []string{"pr-42-web-tls"}, fieldValues(deploy, append(podSpec, "volumes", "secret", "secretName")...), "secret volume")
+	assert.Equal(t, []string{"pr-42-web-config"}, fieldValues(deploy, append(podSpec, "volumes", "projected", "sources", "configMap", "name")...), "projected config map")
+	assert.Equal(t, []string{"pr-42-web-secret"}, fieldValues(deploy, append(podSpec, "volumes", "projected", "sources", "secret", "name")...), "projected secret")
+	assert.Equal(t, "web", nestedString(t, deploy, "spec", "template", "metadata", "labels", "app"), "labels should not be modified")
+
+	ingress := resources["jx/Ingress/pr-42-web"]
+	require.NotNil(t, ingress, "the ingress should be renamed")
+	assert.Equal(t, []string{"pr-42-web", "api"}, fieldValues(ingress, "spec", "rules", "http", "paths", "backend", "service", "name"), "ingress services")
+	assert.Equal(t, []string{"pr-42-web-tls", "cert-manager-tls"}, fieldValues(ingress, "spec", "tls", "secretName"), "ingress TLS secrets")
+
+	statefulSet := resources["jx/StatefulSet/pr-42-db"]
+	require.NotNil(t, statefulSet, "the statefulset should be renamed")
+	assert.Equal(t, "pr-42-db", nestedString(t, statefulSet, "spec", "serviceName"), "service of the statefulset")
+
+	roleBinding := resources["jx/RoleBinding/pr-42-web"]
+	require.NotNil(t, roleBinding, "the role binding should be renamed")
+	assert.Equal(t, "pr-42-web", nestedString(t, roleBinding, "roleRef", "name"), "role of the role binding")
+	assert.Equal(t, []string{"pr-42-web", "web", "web"}, fieldValues(roleBinding, "subjects", "name"), "only the service account in the tree should be renamed")
+
+	clusterRoleBinding := resources["ClusterRoleBinding/pr-42-web-view"]
+	require.NotNil(t, clusterRoleBinding, "the cluster role binding should be renamed")
+	assert.Equal(t, "view", nestedString(t, clusterRoleBinding, "roleRef", "name"), "the cluster role is not in the tree")
+	assert.Equal(t, []string{"pr-42-web"}, fieldValues(clusterRoleBinding, "subjects", "name"), "subjects of the cluster role binding")
+
+	other := resources["other/Deployment/pr-42-other"]
+	require.NotNil(t, other, "the deployment in the other namespace should be renamed")
+	assert.Equal(t, "web", nestedString(t, other, append(podSpec, "serviceAccountName")...), "the service account is in another namespace")
+	assert.Equal(t, []string{"web-config"}, fieldValues(other, append(podSpec, "containers", "envFrom", "configMapRef", "name")...), "the config map is in another namespace")
+
+	assert.Len(t, o.References, 16, "renamed references")
+
+	// lets check running again does not prefix the names twice
+	err = o.Run()
+	require.NoError(t, err, "failed to prefix the names again")
+	assert.Empty(t, o.Renames, "renames on the second run")
+	assert.Empty(t, o.References, "renamed references on the second run")
+}
+
+func TestPrefixNamesSelector(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := prefixnames.NewCmdPrefixNames()
+	o.Dir = tmpDir
+	o.Prefix = "preview-"
+	o.Kinds = []string{"ConfigMap"}
+	err := o.Run()
+	require.NoError(t, err, "failed to prefix the names")
+
+	assert.Equal(t, map[string]string{
+		"jx/ConfigMap/web-config":    "preview-web-config",
+		"jx/ConfigMap/pr-42-already": "preview-pr-42-already",
+	}, o.RenameMap(), "rename map")
+
+	resources := loadResources(t, tmpDir)
+	deploy := resources["jx/Deployment/web"]
+	require.NotNil(t, deploy, "the deployment should not be renamed")
+	assert.Equal(t, []string{"preview-web-config"}, fieldValues(deploy, "spec", "template", "spec", "volumes", "configMap", "name"), "config map volume")
+	assert.Equal(t, []string{"web-secret"}, fieldValues(deploy, "spec", "template", "spec", "volumes", "projected", "sources", "secret", "name"), "the secret should not be renamed")
+}
+
+func TestPrefixNamesInvalid(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := prefixnames.NewCmdPrefixNames()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.Error(t, err, "should fail without a prefix")
+
+	// the services would be renamed with a name which is too long
+	o.Prefix = strings.Repeat("x", 60) + "-"
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid service name")
+	assert.Contains(t, err.Error(), "jx/Service/", "error message")
+
+	// the deployment would be renamed to the name of an existing deployment
+	o.Prefix = "we"
+	o.Kinds = []string{"Deployment"}
+	o.Names = []string{"b"}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "jx", "b.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: b\n  namespace: jx\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write deployment")
+	err = o.Run()
+	require.Error(t, err, "should fail if the new name is already used")
+	assert.Contains(t, err.Error(), "there is already a resource jx/Deployment/web", "error message")
+
+	resources := loadResources(t, tmpDir)
+	assert.Contains(t, resources, "jx/Deployment/b", "no resources should be renamed if a name conflicts")
+}
+
+// loadResources loads the resources in the directory indexed by their key
+func loadResources(t *testing.T, dir string) map[string]*unstructured.Unstructured {
+	answer := map[string]*unstructured.Unstructured{}
+	err := resourcehelpers.VisitFiles(dir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+		answer[resourcehelpers.ResourceKey(u)] = u
+		return nil
+	})
+	require.NoError(t, err, "failed to load resources in %s", dir)
+	return answer
+}
+
+func nestedString(t *testing.T, u *unstructured.Unstructured, fields ...string) string {
+	value, _, err := unstructured.NestedString(u.Object, fields...)
+	require.NoError(t, err, "failed to get %s", strings.Join(fields, "."))
+	return value
+}
+
+func fieldValues(u *unstructured.Unstructured, fields ...string) []string {
+	values, _ := resourcehelpers.GetFieldValues(u.Object, fields...)
+	var answer []string
+	for _, v := range values {
+		answer = append(answer, v.(string))
+	}
+	return answer
+}
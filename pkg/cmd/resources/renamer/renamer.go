@@ -0,0 +1,212 @@
+package renamer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	// ExcludeKinds the kinds which are never renamed as their names are significant
+	ExcludeKinds = []string{"Namespace", "CustomResourceDefinition", "APIService"}
+)
+
+// Rename a resource which has been renamed
+type Rename struct {
+	// File the file containing the resource relative to the directory
+	File string
+
+	// Kind the kind of the resource
+	Kind string
+
+	// Namespace the namespace of the resource
+	Namespace string
+
+	// From the old name
+	From string
+
+	// To the new name
+	To string
+}
+
+// String returns a description of the rename
+func (r *Rename) String() string {
+	name := r.Kind + "/" + r.From
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s: %s => %s", r.File, name, r.To)
+}
+
+// Renamer renames the resources matching the selector in a directory tree and rewrites the references to them
+// from all the resources in the tree
+type Renamer struct {
+	resourcehelpers.Selector
+	Dir        string
+	Renames    []Rename
+	References []validaterefs.ResourceReference
+	Unresolved []validaterefs.ResourceReference
+}
+
+type renamed struct {
+	namespace string
+	name      string
+}
+
+// Rename renames the resources using the function which returns the new name of a resource and true or false if
+// the resource should not be renamed such as if it is already renamed
+func (r *Renamer) Rename(newName func(name string) (string, bool)) error {
+	r.Renames = nil
+	r.References = nil
+	r.Unresolved = nil
+
+	// lets find the new names of the resources indexed by kind and old name
+	index := map[string][]renamed{}
+	existing := map[string]bool{}
+	namespaces := map[string][]string{}
+	err := resourcehelpers.VisitFiles(r.Dir, resourcehelpers.Selector{WalkOptions: r.WalkOptions}, func(u *unstructured.Unstructured, path string) error {
+		kind := u.GetKind()
+		name := u.GetName()
+		existing[resourcehelpers.ResourceKey(u)] = true
+		namespaces[kind+"/"+name] = append(namespaces[kind+"/"+name], u.GetNamespace())
+		if name == "" || !r.Selector.Matches(u) || stringhelpers.StringArrayIndex(ExcludeKinds, kind) >= 0 {
+			return nil
+		}
+		to, ok := newName(name)
+		if !ok || to == name {
+			return nil
+		}
+		err := validateName(kind, to)
+		if err != nil {
+			return errors.Wrapf(err, "invalid new name of %s in file %s", resourcehelpers.ResourceKey(u), path)
+		}
+		index[kind+"/"+name] = append(index[kind+"/"+name], renamed{namespace: u.GetNamespace(), name: to})
+		r.Renames = append(r.Renames, Rename{File: r.relPath(path), Kind: kind, Namespace: u.GetNamespace(), From: name, To: to})
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to load resources in dir %s", r.Dir)
+	}
+	for _, rn := range r.Renames {
+		key := rn.Kind + "/" + rn.To
+		if rn.Namespace != "" {
+			key = rn.Namespace + "/" + key
+		}
+		if existing[key] {
+			return errors.Errorf("cannot rename %s as there is already a resource %s", rn.String(), key)
+		}
+	}
+
+	// resources without a namespace may be defaulted later so lets treat them as matching
+	matchesNamespace := func(a, b string) bool {
+		return a == b || a == "" || b == ""
+	}
+	lookup := func(kind, ns, name string) string {
+		for _, rn := range index[kind+"/"+name] {
+			if matchesNamespace(rn.namespace, ns) {
+				return rn.name
+			}
+		}
+		return ""
+	}
+	exists := func(kind, ns, name string) bool {
+		for _, n := range namespaces[kind+"/"+name] {
+			if matchesNamespace(n, ns) {
+				return true
+			}
+		}
+		return false
+	}
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified := false
+		ns := u.GetNamespace()
+		for _, ref := range validaterefs.ResourceReferences(u) {
+			refNS := ref.Namespace
+			if refNS == "" {
+				refNS = ns
+			}
+			if !exists(ref.Kind, refNS, ref.Name) {
+				ref.File = r.relPath(path)
+				r.Unresolved = append(r.Unresolved, ref)
+			}
+		}
+		if r.Selector.Matches(u) {
+			for _, rn := range index[u.GetKind()+"/"+u.GetName()] {
+				if rn.namespace == ns {
+					u.SetName(rn.name)
+					modified = true
+					break
+				}
+			}
+		}
+		refs := validaterefs.RenameReferences(u, func(kind, refNs, name string) string {
+			if refNs == "" {
+				refNs = ns
+			}
+			return lookup(kind, refNs, name)
+		})
+		for i := range refs {
+			refs[i].File = r.relPath(path)
+			r.References = append(r.References, refs[i])
+		}
+		return modified || len(refs) > 0, nil
+	}
+	err = resourcehelpers.ModifyFiles(r.Dir, resourcehelpers.Selector{WalkOptions: r.WalkOptions}, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to rename resources in dir %s", r.Dir)
+	}
+
+	for i := range r.Renames {
+		log.Logger().Infof("renamed %s", termcolor.ColorInfo(r.Renames[i].String()))
+	}
+	for i := range r.Unresolved {
+		log.Logger().Warnf("could not resolve %s", termcolor.ColorWarning(r.Unresolved[i].String()))
+	}
+	log.Logger().Infof("renamed %d resources and %d references to them", len(r.Renames), len(r.References))
+	return nil
+}
+
+// RenameMap returns the new names of the resources indexed by the key of the resource before it was renamed
+func (r *Renamer) RenameMap() map[string]string {
+	answer := map[string]string{}
+	for _, rn := range r.Renames {
+		key := rn.Kind + "/" + rn.From
+		if rn.Namespace != "" {
+			key = rn.Namespace + "/" + key
+		}
+		answer[key] = rn.To
+	}
+	return answer
+}
+
+func (r *Renamer) relPath(path string) string {
+	rel, err := filepath.Rel(r.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// validateName validates the new name is a valid DNS label for a Service and is not too long for other kinds
+// whose names such as those of RBAC resources may not be DNS subdomains
+func validateName(kind, name string) error {
+	if kind == "Service" {
+		msgs := validation.IsDNS1035Label(name)
+		if len(msgs) > 0 {
+			return errors.Errorf("invalid name '%s': %s", name, msgs[0])
+		}
+		return nil
+	}
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		return errors.Errorf("invalid name '%s': %s", name, validation.MaxLenError(validation.DNS1123SubdomainMaxLength))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package resources
+
+import (
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addenv"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addownerref"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/addresourcelimits"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/base64"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/canonicalizeapiversion"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/checkprobes"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/convertlist"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/dedupelabels"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/filter"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/fromhelm"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/getfield"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/merge"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/mergeconfigmaps"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/normalizequantities"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/order"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/podsecurity"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/prefixnames"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/rewritehost"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/setfield"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/setreplicas"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/stripstatus"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/suffixnames"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/tohelm"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/topologyspread"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validatenames"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdResources creates the new command
+func NewCmdResources() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "resources",
+		Aliases: []string{"resource", "res"},
+		Short:   "Commands for modifying the kubernetes resources in a directory tree",
+		Run: func(command *cobra.Command, args []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Errorf(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(addenv.NewCmdAddEnv()))
+	command.AddCommand(cobras.SplitCommand(addownerref.NewCmdAddOwnerRef()))
+	command.AddCommand(cobras.SplitCommand(addresourcelimits.NewCmdAddResourceLimits()))
+	command.AddCommand(cobras.SplitCommand(base64.NewCmdBase64()))
+	command.AddCommand(cobras.SplitCommand(canonicalizeapiversion.NewCmdCanonicalizeAPIVersion()))
+	command.AddCommand(cobras.SplitCommand(checkprobes.NewCmdCheckProbes()))
+	command.AddCommand(cobras.SplitCommand(convertlist.NewCmdConvertList()))
+	command.AddCommand(cobras.SplitCommand(dedupelabels.NewCmdDedupeLabels()))
+	command.AddCommand(cobras.SplitCommand(filter.NewCmdFilter()))
+	command.AddCommand(cobras.SplitCommand(fromhelm.NewCmdFromHelm()))
+	command.AddCommand(cobras.SplitCommand(getfield.NewCmdGetField()))
+	command.AddCommand(cobras.SplitCommand(imagelist.NewCmdImageList()))
+	command.AddCommand(cobras.SplitCommand(merge.NewCmdMerge()))
+	command.AddCommand(cobras.SplitCommand(mergeconfigmaps.NewCmdMergeConfigMaps()))
+	command.AddCommand(cobras.SplitCommand(normalizequantities.NewCmdNormalizeQuantities()))
+	command.AddCommand(cobras.SplitCommand(order.NewCmdOrder()))
+	command.AddCommand(cobras.SplitCommand(podsecurity.NewCmdPodSecurity()))
+	command.AddCommand(cobras.SplitCommand(prefixnames.NewCmdPrefixNames()))
+	command.AddCommand(cobras.SplitCommand(rewritehost.NewCmdRewriteHost()))
+	command.AddCommand(cobras.SplitCommand(setfield.NewCmdSetField()))
+	command.AddCommand(cobras.SplitCommand(setreplicas.NewCmdSetReplicas()))
+	command.AddCommand(cobras.SplitCommand(stripstatus.NewCmdStripStatus()))
+	command.AddCommand(cobras.SplitCommand(suffixnames.NewCmdSuffixNames()))
+	command.AddCommand(cobras.SplitCommand(tohelm.NewCmdToHelm()))
+	command.AddCommand(cobras.SplitCommand(topologyspread.NewCmdTopologySpread()))
+	command.AddCommand(cobras.SplitCommand(validatenames.NewCmdValidateNames()))
+	command.AddCommand(cobras.SplitCommand(validaterefs.NewCmdValidateRefs()))
+	return command
+}
@@ -0,0 +1,206 @@
+package rewritehost
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Rewrites the host names of the Ingress, Gateway and route resources in the given directory tree from one domain to another
+
+		The hosts of the rules and TLS sections of Ingresses, the host names of the listeners of Gateway API Gateways, the hosts of the servers of Istio Gateways and the host names of HTTPRoutes, GRPCRoutes and TLSRoutes are rewritten. A host is rewritten if it is the domain itself or a sub domain of it including wildcard hosts such as '*.dev.example.com'. Other hosts are left untouched.
+`)
+
+	cmdExample = templates.Examples(`
+		# rewrites the hosts from the dev domain to the prod domain
+		%s resources rewrite-host --from-domain dev.example.com --to-domain prod.example.com
+
+		# rewrites the hosts of the resources in a directory
+		%s resources rewrite-host --dir config-root/namespaces/jx-production --from-domain staging.example.com --to-domain example.com
+	`)
+
+	// HostPaths the dot separated paths of the host fields of each kind. Any lists along the paths are traversed
+	HostPaths = map[string][]string{
+		"Ingress":   {"spec.rules.host", "spec.tls.hosts"},
+		"Gateway":   {"spec.listeners.hostname", "spec.servers.hosts"},
+		"HTTPRoute": {"spec.hostnames"},
+		"GRPCRoute": {"spec.hostnames"},
+		"TLSRoute":  {"spec.hostnames"},
+	}
+)
+
+// Rewrite a host which has been rewritten
+type Rewrite struct {
+	// Path the file containing the resource
+	Path string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Field the path of the host field
+	Field string
+
+	// From the original host
+	From string
+
+	// To the rewritten host
+	To string
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir        string
+	FromDomain string
+	ToDomain   string
+	Rewrites   []Rewrite
+}
+
+// NewCmdRewriteHost creates a command object for the command
+func NewCmdRewriteHost() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "rewrite-host",
+		Short:   "Rewrites the host names of the Ingress, Gateway and route resources in the given directory tree from one domain to another",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.FromDomain, "from-domain", "", "", "the domain of the hosts to rewrite such as 'dev.example.com'")
+	cmd.Flags().StringVarP(&o.ToDomain, "to-domain", "", "", "the domain to rewrite the hosts to such as 'prod.example.com'")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	o.FromDomain = normaliseDomain(o.FromDomain)
+	o.ToDomain = normaliseDomain(o.ToDomain)
+	if o.FromDomain == "" {
+		return options.MissingOption("from-domain")
+	}
+	if o.ToDomain == "" {
+		return options.MissingOption("to-domain")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Rewrites = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified := false
+		for _, hostPath := range HostPaths[u.GetKind()] {
+			rewriteFn := func(host, field string) string {
+				newHost := RewriteHost(host, o.FromDomain, o.ToDomain)
+				if newHost == host {
+					return host
+				}
+				modified = true
+				o.Rewrites = append(o.Rewrites, Rewrite{
+					Path:     path,
+					Resource: resourcehelpers.ResourceKey(u),
+					Field:    field,
+					From:     host,
+					To:       newHost,
+				})
+				return newHost
+			}
+			rewriteHosts(u.Object, strings.Split(hostPath, "."), "", rewriteFn)
+		}
+		return modified, nil
+	}
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to rewrite the hosts of resources in dir %s", o.Dir)
+	}
+	for _, r := range o.Rewrites {
+		rel, err := filepath.Rel(o.Dir, r.Path)
+		if err != nil {
+			rel = r.Path
+		}
+		log.Logger().Infof("rewrote %s of %s in file %s from %s to %s", r.Field, r.Resource, rel, r.From, termcolor.ColorInfo(r.To))
+	}
+	log.Logger().Infof("rewrote %d hosts from domain %s to %s", len(o.Rewrites), termcolor.ColorInfo(o.FromDomain), termcolor.ColorInfo(o.ToDomain))
+	return nil
+}
+
+// RewriteHost returns the host in the new domain if it is the domain or a sub domain of it otherwise the host.
+// Any namespace prefix of the host such as 'ns/' used by Istio is kept
+func RewriteHost(host, fromDomain, toDomain string) string {
+	prefix := ""
+	name := host
+	idx := strings.LastIndex(host, "/")
+	if idx >= 0 {
+		prefix = host[:idx+1]
+		name = host[idx+1:]
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case lower == fromDomain:
+		return prefix + toDomain
+	case strings.HasSuffix(lower, "."+fromDomain):
+		return prefix + name[:len(name)-len(fromDomain)] + toDomain
+	default:
+		return host
+	}
+}
+
+// rewriteHosts invokes the function on the hosts at the path of the node traversing any lists and returns the
+// node with the rewritten hosts
+func rewriteHosts(node interface{}, path []string, field string, fn func(host, field string) string) interface{} {
+	switch v := node.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = rewriteHosts(v[i], path, fmt.Sprintf("%s[%d]", field, i), fn)
+		}
+		return v
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return v
+		}
+		child, ok := v[path[0]]
+		if ok {
+			childField := path[0]
+			if field != "" {
+				childField = field + "." + childField
+			}
+			v[path[0]] = rewriteHosts(child, path[1:], childField, fn)
+		}
+		return v
+	case string:
+		if len(path) == 0 && v != "" {
+			return fn(v, field)
+		}
+	}
+	return node
+}
+
+// normaliseDomain removes any wildcard or leading dot from the domain
+func normaliseDomain(domain string) string {
+	domain = strings.TrimPrefix(strings.TrimSpace(domain), "*")
+	return strings.ToLower(strings.Trim(domain, "."))
+}
@@ -0,0 +1,69 @@
+package rewritehost_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/rewritehost"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteHost(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := rewritehost.NewCmdRewriteHost()
+	o.Dir = tmpDir
+	o.FromDomain = "*.dev.example.com"
+	o.ToDomain = "prod.example.com"
+	err := o.Run()
+	require.NoError(t, err, "failed to rewrite the hosts")
+
+	var fields []string
+	for _, r := range o.Rewrites {
+		fields = append(fields, r.Resource+" "+r.Field+" "+r.From+" => "+r.To)
+	}
+	assert.Equal(t, []string{
+		"jx/Gateway/gateway spec.listeners[0].hostname *.dev.example.com => *.prod.example.com",
+		"jx/HTTPRoute/web spec.hostnames[0] web.dev.example.com => web.prod.example.com",
+		"jx/HTTPRoute/web spec.hostnames[1] WWW.Dev.Example.com => WWW.prod.example.com",
+		"istio-system/Gateway/istio-gateway spec.servers[0].hosts[0] jx/api.dev.example.com => jx/api.prod.example.com",
+		"jx/Ingress/web spec.rules[0].host web.dev.example.com => web.prod.example.com",
+		"jx/Ingress/web spec.rules[1].host dev.example.com => prod.example.com",
+		"jx/Ingress/web spec.tls[0].hosts[0] web.dev.example.com => web.prod.example.com",
+		"jx/Ingress/web spec.tls[1].hosts[0] *.dev.example.com => *.prod.example.com",
+	}, fields, "rewrites")
+
+	for _, name := range []string{"gateway.yaml", "ingress.yaml"} {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-"+name), filepath.Join(tmpDir, name))
+	}
+
+	// rewriting again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to rewrite the hosts again")
+	assert.Empty(t, o.Rewrites, "rewrites on the second run")
+
+	o.ToDomain = ""
+	err = o.Run()
+	require.Error(t, err, "should fail without --to-domain")
+}
+
+func TestRewriteHostNames(t *testing.T) {
+	testCases := []struct {
+		host     string
+		expected string
+	}{
+		{host: "dev.example.com", expected: "prod.example.com"},
+		{host: "a.b.dev.example.com", expected: "a.b.prod.example.com"},
+		{host: "*.dev.example.com", expected: "*.prod.example.com"},
+		{host: "ns/web.dev.example.com", expected: "ns/web.prod.example.com"},
+		{host: "mydev.example.com", expected: "mydev.example.com"},
+		{host: "dev.example.com.evil.io", expected: "dev.example.com.evil.io"},
+		{host: "*", expected: "*"},
+	}
+	for _, tc := range testCases {
+		actual := rewritehost.RewriteHost(tc.host, "dev.example.com", "prod.example.com")
+		assert.Equal(t, tc.expected, actual, "host %s", tc.host)
+	}
+}
@@ -0,0 +1,123 @@
+package setfield
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Sets a field on all the kubernetes resources in the given directory tree which match the selector
+`)
+
+	cmdExample = templates.Examples(`
+		# sets the replicas on all Deployments with a name starting with 'web'
+		%s resources set-field --kind Deployment --name 'web*' --path spec.replicas --value 3 --type int
+
+		# sets an annotation on the pod template of all Deployments
+		%s resources set-field --kind Deployment --path spec.template.metadata.annotations.cheese --value edam
+	`)
+
+	// ValueTypes the supported value types
+	ValueTypes = []string{"string", "int", "bool"}
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir   string
+	Path  string
+	Value string
+	Type  string
+	Count int
+}
+
+// NewCmdSetField creates a command object for the command
+func NewCmdSetField() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "set-field",
+		Short:   "Sets a field on all the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the dot separated path of the field to set such as 'spec.replicas'")
+	cmd.Flags().StringVarP(&o.Value, "value", "", "", "the value to set the field to")
+	cmd.Flags().StringVarP(&o.Type, "type", "t", "string", fmt.Sprintf("the type of the value. Values: %s", strings.Join(ValueTypes, ", ")))
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Path == "" {
+		return options.MissingOption("path")
+	}
+	value, err := ParseValue(o.Value, o.Type)
+	if err != nil {
+		return err
+	}
+	fields := strings.Split(o.Path, ".")
+
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		existing, found, _ := unstructured.NestedFieldNoCopy(u.Object, fields...)
+		if found && reflect.DeepEqual(existing, value) {
+			return false, nil
+		}
+		err := unstructured.SetNestedField(u.Object, value, fields...)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set field %s", o.Path)
+		}
+		log.Logger().Infof("set %s to %s on %s %s in file %s", o.Path, termcolor.ColorInfo(o.Value), u.GetKind(), termcolor.ColorInfo(u.GetName()), path)
+		o.Count++
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set field %s in dir %s", o.Path, o.Dir)
+	}
+	log.Logger().Infof("modified %d resources", o.Count)
+	return nil
+}
+
+// ParseValue parses the text as a value of the given type
+func ParseValue(text string, valueType string) (interface{}, error) {
+	switch valueType {
+	case "", "string":
+		return text, nil
+	case "int":
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse int value %s", text)
+		}
+		return i, nil
+	case "bool":
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse bool value %s", text)
+		}
+		return b, nil
+	default:
+		return nil, options.InvalidOption("type", valueType, ValueTypes)
+	}
+}
@@ -0,0 +1,99 @@
+package setfield_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/setfield"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetField(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := setfield.NewCmdSetField()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.Names = []string{"web*"}
+	o.Path = "spec.replicas"
+	o.Value = "3"
+	o.Type = "int"
+	err := o.Run()
+	require.NoError(t, err, "failed to run set-field")
+	assert.Equal(t, 2, o.Count, "modified count")
+
+	assertIntField(t, filepath.Join(tmpDir, "web-deploy.yaml"), 0, 3, "spec", "replicas")
+	assertIntField(t, filepath.Join(tmpDir, "multi.yaml"), 0, 3, "spec", "replicas")
+	assertIntField(t, filepath.Join(tmpDir, "backend-deploy.yaml"), 0, 2, "spec", "replicas")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "multi.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 2, "should have preserved the Service document")
+	assert.Equal(t, "Service", resources[1].GetKind())
+
+	// running again should not modify anything
+	o.Count = 0
+	err = o.Run()
+	require.NoError(t, err, "failed to run set-field again")
+	assert.Equal(t, 0, o.Count, "modified count on second run")
+}
+
+func TestSetFieldCreatesIntermediateMaps(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := setfield.NewCmdSetField()
+	o.Dir = tmpDir
+	o.Kinds = []string{"apps/v1/Deployment"}
+	o.Names = []string{"backend"}
+	o.Path = "spec.template.metadata.annotations.cheese"
+	o.Value = "edam"
+	err := o.Run()
+	require.NoError(t, err, "failed to run set-field")
+	assert.Equal(t, 1, o.Count, "modified count")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "backend-deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	value, _, err := unstructured.NestedString(resources[0].Object, "spec", "template", "metadata", "annotations", "cheese")
+	require.NoError(t, err)
+	assert.Equal(t, "edam", value)
+}
+
+func TestParseValue(t *testing.T) {
+	testCases := []struct {
+		text      string
+		valueType string
+		expected  interface{}
+		expectErr bool
+	}{
+		{text: "3", valueType: "int", expected: int64(3)},
+		{text: "true", valueType: "bool", expected: true},
+		{text: "3", valueType: "string", expected: "3"},
+		{text: "3", valueType: "", expected: "3"},
+		{text: "abc", valueType: "int", expectErr: true},
+		{text: "abc", valueType: "float", expectErr: true},
+	}
+	for _, tc := range testCases {
+		value, err := setfield.ParseValue(tc.text, tc.valueType)
+		if tc.expectErr {
+			assert.Error(t, err, "for %s of type %s", tc.text, tc.valueType)
+			continue
+		}
+		require.NoError(t, err, "for %s of type %s", tc.text, tc.valueType)
+		assert.Equal(t, tc.expected, value, "for %s of type %s", tc.text, tc.valueType)
+	}
+}
+
+func assertIntField(t *testing.T, path string, index int, expected int64, fields ...string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err)
+	require.True(t, len(resources) > index, "not enough documents in file %s", path)
+	value, found, err := unstructured.NestedInt64(resources[index].Object, fields...)
+	require.NoError(t, err, "failed to get field for file %s", path)
+	require.True(t, found, "field not found for file %s", path)
+	assert.Equal(t, expected, value, "field value for file %s", path)
+}
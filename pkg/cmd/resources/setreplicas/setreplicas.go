@@ -0,0 +1,206 @@
+package setreplicas
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Sets the replicas of the Deployment and StatefulSet resources in the given directory tree
+
+		When scaling down the original replicas are recorded in an annotation so that the resources can be scaled back up again via --restore. This gives a reversible scale down of preview and staging environments.
+`)
+
+	cmdExample = templates.Examples(`
+		# scales all the deployments and statefulsets in the current directory to zero
+		%s resources set-replicas --scale-to-zero
+
+		# scales the matching resources to 1
+		%s resources set-replicas --dir config-root/namespaces/jx-staging --replicas 1 --selector tier=web
+
+		# restores the original replicas of the resources that were scaled down
+		%s resources set-replicas --restore
+	`)
+
+	// DefaultKinds the kinds of resource which are scaled if no kinds are specified
+	DefaultKinds = []string{"Deployment", "StatefulSet"}
+)
+
+const (
+	// OriginalReplicasAnnotation the annotation recording the replicas of a resource before it was scaled down
+	OriginalReplicasAnnotation = "gitops.jenkins-x.io/original-replicas"
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir         string
+	Replicas    int
+	ScaleToZero bool
+	Restore     bool
+	Count       int
+	Files       []string
+}
+
+// NewCmdSetReplicas creates a command object for the command
+func NewCmdSetReplicas() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "set-replicas",
+		Short:   "Sets the replicas of the Deployment and StatefulSet resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().IntVarP(&o.Replicas, "replicas", "r", -1, "the number of replicas to scale the resources to")
+	cmd.Flags().BoolVarP(&o.ScaleToZero, "scale-to-zero", "", false, "scales the resources to zero replicas")
+	cmd.Flags().BoolVarP(&o.Restore, "restore", "", false, "restores the original replicas recorded in the "+OriginalReplicasAnnotation+" annotation when the resources were scaled down")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	modes := 0
+	if o.Replicas >= 0 {
+		modes++
+	}
+	if o.ScaleToZero {
+		modes++
+	}
+	if o.Restore {
+		modes++
+	}
+	if modes != 1 {
+		return errors.Errorf("please specify exactly one of the --replicas, --scale-to-zero or --restore flags")
+	}
+	if len(o.Kinds) == 0 {
+		o.Kinds = DefaultKinds
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	replicas := int64(o.Replicas)
+	if o.ScaleToZero {
+		replicas = 0
+	}
+	o.Count = 0
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		var modified bool
+		var err error
+		if o.Restore {
+			modified, err = restore(u)
+		} else {
+			modified, err = scale(u, replicas)
+		}
+		if err != nil || !modified {
+			return false, err
+		}
+		o.Count++
+		if len(o.Files) == 0 || o.Files[len(o.Files)-1] != path {
+			o.Files = append(o.Files, path)
+		}
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set the replicas of resources in dir %s", o.Dir)
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("modified file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("set the replicas of %d resources", o.Count)
+	return nil
+}
+
+// scale sets the replicas annotating the resource with the original replicas if it is being scaled down
+func scale(u *unstructured.Unstructured, replicas int64) (bool, error) {
+	current, err := currentReplicas(u)
+	if err != nil {
+		return false, err
+	}
+	if current == replicas {
+		return false, nil
+	}
+	if replicas < current {
+		// lets keep the original replicas if the resource has already been scaled down
+		ann := u.GetAnnotations()
+		if ann == nil {
+			ann = map[string]string{}
+		}
+		if ann[OriginalReplicasAnnotation] == "" {
+			ann[OriginalReplicasAnnotation] = strconv.FormatInt(current, 10)
+			u.SetAnnotations(ann)
+		}
+	}
+	err = unstructured.SetNestedField(u.Object, replicas, "spec", "replicas")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to set spec.replicas")
+	}
+	log.Logger().Debugf("scaled %s %s from %d to %d replicas", u.GetKind(), u.GetName(), current, replicas)
+	return true, nil
+}
+
+// restore sets the replicas to the value of the original replicas annotation
+func restore(u *unstructured.Unstructured) (bool, error) {
+	ann := u.GetAnnotations()
+	text := ann[OriginalReplicasAnnotation]
+	if text == "" {
+		return false, nil
+	}
+	replicas, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || replicas < 0 {
+		return false, errors.Errorf("invalid %s annotation value '%s'", OriginalReplicasAnnotation, text)
+	}
+	err = unstructured.SetNestedField(u.Object, replicas, "spec", "replicas")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to set spec.replicas")
+	}
+	delete(ann, OriginalReplicasAnnotation)
+	u.SetAnnotations(ann)
+	log.Logger().Debugf("restored %s %s to %d replicas", u.GetKind(), u.GetName(), replicas)
+	return true, nil
+}
+
+// currentReplicas returns the replicas of the resource defaulting to 1 like kubernetes does if they are not specified
+func currentReplicas(u *unstructured.Unstructured) (int64, error) {
+	value, found, err := unstructured.NestedFieldNoCopy(u.Object, "spec", "replicas")
+	if err != nil || !found || value == nil {
+		return 1, nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, errors.Errorf("spec.replicas is not a number: %v", value)
+	}
+}
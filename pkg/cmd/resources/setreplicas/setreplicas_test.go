@@ -0,0 +1,167 @@
+package setreplicas_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/setreplicas"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetReplicasScaleToZeroAndRestore(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "jx", "deploy.yaml")
+	statefulSetFile := filepath.Join(tmpDir, "jx", "statefulset.yaml")
+
+	_, o := setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.ScaleToZero = true
+	err := o.Run()
+	require.NoError(t, err, "failed to scale to zero")
+
+	assert.Equal(t, []string{deployFile, statefulSetFile}, o.Files, "modified files")
+	assert.Equal(t, 3, o.Count, "modified resources")
+	assertReplicas(t, deployFile, "web", 0, "3")
+	assertReplicas(t, deployFile, "worker", 0, "1")
+	assertReplicas(t, statefulSetFile, "db", 0, "2")
+
+	resources, err := resourcehelpers.LoadFile(deployFile)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", resources[1].GetAnnotations()["jenkins-x.io/hash"], "existing annotations should be kept")
+
+	// scaling down again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to scale to zero again")
+	assert.Empty(t, o.Files, "modified files on second run")
+
+	_, o = setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.Restore = true
+	err = o.Run()
+	require.NoError(t, err, "failed to restore")
+
+	assert.Equal(t, 3, o.Count, "restored resources")
+	assertReplicas(t, deployFile, "web", 3, "")
+	assertReplicas(t, deployFile, "worker", 1, "")
+	assertReplicas(t, statefulSetFile, "db", 2, "")
+
+	// restoring again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to restore again")
+	assert.Empty(t, o.Files, "restored files on second run")
+}
+
+func TestSetReplicasSelector(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "jx", "deploy.yaml")
+	statefulSetFile := filepath.Join(tmpDir, "jx", "statefulset.yaml")
+
+	_, o := setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.Replicas = 1
+	o.Labels = map[string]string{"tier": "web"}
+	err := o.Run()
+	require.NoError(t, err, "failed to set replicas")
+
+	assert.Equal(t, []string{deployFile}, o.Files, "modified files")
+	assertReplicas(t, deployFile, "web", 1, "3")
+	assertReplicas(t, deployFile, "worker", 1, "")
+	assertReplicas(t, statefulSetFile, "db", 2, "")
+
+	// scaling down further should keep the original replicas and scaling up should not record them
+	_, o = setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.ScaleToZero = true
+	o.Names = []string{"web"}
+	err = o.Run()
+	require.NoError(t, err, "failed to scale to zero")
+	assertReplicas(t, deployFile, "web", 0, "3")
+
+	_, o = setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.Replicas = 5
+	o.Kinds = []string{"StatefulSet"}
+	err = o.Run()
+	require.NoError(t, err, "failed to scale up")
+	assertReplicas(t, statefulSetFile, "db", 5, "")
+	assertReplicas(t, deployFile, "web", 0, "3")
+}
+
+func TestSetReplicasValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options func(o *setreplicas.Options)
+	}{
+		{"no flags", func(o *setreplicas.Options) {}},
+		{"replicas and restore", func(o *setreplicas.Options) {
+			o.Replicas = 2
+			o.Restore = true
+		}},
+		{"scale to zero and restore", func(o *setreplicas.Options) {
+			o.ScaleToZero = true
+			o.Restore = true
+		}},
+		{"replicas and scale to zero", func(o *setreplicas.Options) {
+			o.Replicas = 2
+			o.ScaleToZero = true
+		}},
+	}
+	for _, tc := range testCases {
+		_, o := setreplicas.NewCmdSetReplicas()
+		o.Dir = "test_data"
+		tc.options(o)
+		err := o.Run()
+		assert.Error(t, err, "expected an error for %s", tc.name)
+	}
+}
+
+func TestSetReplicasInvalidAnnotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+
+	source := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    gitops.jenkins-x.io/original-replicas: "many"
+spec:
+  replicas: 0
+`
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "deploy.yaml"), []byte(source), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write file")
+
+	_, o := setreplicas.NewCmdSetReplicas()
+	o.Dir = tmpDir
+	o.Restore = true
+	err = o.Run()
+	require.Error(t, err, "expected an error for an invalid annotation")
+	t.Logf("got expected error: %s", err.Error())
+}
+
+func assertReplicas(t *testing.T, path, name string, expected int64, original string) {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+
+	var u *unstructured.Unstructured
+	for _, r := range resources {
+		if r.GetName() == name {
+			u = r
+		}
+	}
+	require.NotNil(t, u, "could not find resource %s in %s", name, path)
+
+	replicas, found, err := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	require.NoError(t, err, "failed to get spec.replicas of %s", name)
+	if !found {
+		replicas = 1
+	}
+	assert.Equal(t, expected, replicas, "spec.replicas of %s", name)
+	assert.Equal(t, original, u.GetAnnotations()[setreplicas.OriginalReplicasAnnotation], "original replicas annotation of %s", name)
+}
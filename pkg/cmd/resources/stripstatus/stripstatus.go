@@ -0,0 +1,107 @@
+package stripstatus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Removes the status and the server generated metadata from all the kubernetes resources in the given directory tree
+
+		This lets resources exported from a cluster, such as via 'kubectl get -o yaml', be committed to git and applied.
+`)
+
+	cmdExample = templates.Examples(`
+		# removes the status and server generated metadata of all the resources in the current directory
+		%s resources strip-status
+
+		# only removes the status and the managed fields
+		%s resources strip-status --dir config-root --field status --field metadata.managedFields
+	`)
+
+	// DefaultFields the fields removed by default
+	DefaultFields = []string{
+		"status",
+		"metadata.resourceVersion",
+		"metadata.uid",
+		"metadata.generation",
+		"metadata.creationTimestamp",
+		"metadata.managedFields",
+	}
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir    string
+	Fields []string
+	Files  []string
+}
+
+// NewCmdStripStatus creates a command object for the command
+func NewCmdStripStatus() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "strip-status",
+		Short:   "Removes the status and the server generated metadata from all the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Fields, "field", "f", DefaultFields, "the dot separated paths of the fields to remove")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if len(o.Fields) == 0 {
+		o.Fields = DefaultFields
+	}
+	var paths [][]string
+	for _, f := range o.Fields {
+		paths = append(paths, strings.Split(f, "."))
+	}
+
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified := false
+		for _, fields := range paths {
+			_, found, _ := unstructured.NestedFieldNoCopy(u.Object, fields...)
+			if found {
+				unstructured.RemoveNestedField(u.Object, fields...)
+				modified = true
+			}
+		}
+		if modified && (len(o.Files) == 0 || o.Files[len(o.Files)-1] != path) {
+			o.Files = append(o.Files, path)
+		}
+		return modified, nil
+	}
+
+	err := resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to strip the status of resources in dir %s", o.Dir)
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("cleaned file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("cleaned %d files", len(o.Files))
+	return nil
+}
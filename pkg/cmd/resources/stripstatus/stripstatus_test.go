@@ -0,0 +1,76 @@
+package stripstatus_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/stripstatus"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStripStatus(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := stripstatus.NewCmdStripStatus()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to run strip-status")
+
+	assert.Equal(t, []string{
+		filepath.Join(tmpDir, "jx", "configmaps.yaml"),
+		filepath.Join(tmpDir, "jx", "deploy.yaml"),
+	}, o.Files, "cleaned files")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "jx", "deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	u := resources[0]
+	for _, f := range stripstatus.DefaultFields {
+		assertNoField(t, u, f)
+	}
+	assert.Equal(t, "web", u.GetName(), "name")
+	assert.Equal(t, map[string]string{"app": "web"}, u.GetLabels(), "labels")
+	assert.Equal(t, map[string]string{"deployment.kubernetes.io/revision": "1"}, u.GetAnnotations(), "annotations")
+	replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	assert.Equal(t, int64(1), replicas, "spec.replicas")
+
+	resources, err = resourcehelpers.LoadFile(filepath.Join(tmpDir, "jx", "configmaps.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Empty(t, resources[0].GetResourceVersion(), "resourceVersion of the first ConfigMap")
+	assert.Empty(t, string(resources[1].GetUID()), "uid of the second ConfigMap")
+
+	// running again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to run strip-status again")
+	assert.Empty(t, o.Files, "cleaned files on second run")
+}
+
+func TestStripStatusCustomFields(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := stripstatus.NewCmdStripStatus()
+	o.Dir = tmpDir
+	o.Fields = []string{"status", "metadata.annotations"}
+	err := o.Run()
+	require.NoError(t, err, "failed to run strip-status")
+	assert.Equal(t, []string{filepath.Join(tmpDir, "jx", "deploy.yaml")}, o.Files, "cleaned files")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "jx", "deploy.yaml"))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	u := resources[0]
+	assertNoField(t, u, "status")
+	assertNoField(t, u, "metadata.annotations")
+	assert.Equal(t, "123456", u.GetResourceVersion(), "the resourceVersion should not have been removed")
+}
+
+func assertNoField(t *testing.T, u *unstructured.Unstructured, field string) {
+	_, found, _ := unstructured.NestedFieldNoCopy(u.Object, strings.Split(field, ".")...)
+	assert.False(t, found, "should have removed field %s from %s", field, u.GetName())
+}
@@ -0,0 +1,79 @@
+package suffixnames
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/renamer"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Suffixes the names of the kubernetes resources in the given directory tree such as for versioned immutable ConfigMaps or blue/green deployments
+
+		The suffix is appended to the name of each resource matching the selector which does not already end with the suffix. Namespaces, CustomResourceDefinitions and APIServices are never renamed.
+
+		The references to the renamed resources from all the resources in the tree are rewritten in the same way as the kustomize nameSuffix. These are the ConfigMaps, Secrets and ServiceAccounts used by pods, the Services and TLS Secrets used by Ingresses, the Service of a StatefulSet and the roles and ServiceAccounts of RoleBindings and ClusterRoleBindings. A warning is logged for each reference to a resource which is not in the tree.
+`)
+
+	cmdExample = templates.Examples(`
+		# suffixes the names of the ConfigMaps with a version so they can be immutable
+		%s resources suffix-names --suffix -v2 --kind ConfigMap
+
+		# suffixes the names of all the resources for a green deployment
+		%s resources suffix-names --dir config-root/namespaces/jx --suffix -green
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	renamer.Renamer
+	Suffix string
+}
+
+// NewCmdSuffixNames creates a command object for the command
+func NewCmdSuffixNames() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "suffix-names",
+		Short:   "Suffixes the names of the kubernetes resources in the given directory tree and the references to them",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Suffix, "suffix", "s", "", "the suffix to append to the names such as '-v2'")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Suffix == "" {
+		return options.MissingOption("suffix")
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	return o.Rename(func(name string) (string, bool) {
+		if strings.HasSuffix(name, o.Suffix) {
+			return name, false
+		}
+		return name + o.Suffix, true
+	})
+}
@@ -0,0 +1,101 @@
+package suffixnames_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/suffixnames"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSuffixNames(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := suffixnames.NewCmdSuffixNames()
+	o.Dir = tmpDir
+	o.Suffix = "-green"
+	err := o.Run()
+	require.NoError(t, err, "failed to suffix the names")
+
+	assert.Equal(t, map[string]string{
+		"jx/ConfigMap/app-config": "app-config-green",
+		"jx/ConfigMap/legacy-v2":  "legacy-v2-green",
+		"jx/Deployment/web":       "web-green",
+		"jx/Service/web":          "web-green",
+		"jx/Ingress/web":          "web-green",
+	}, o.RenameMap(), "rename map")
+
+	resources := loadResources(t, tmpDir)
+	deploy := resources["jx/Deployment/web-green"]
+	require.NotNil(t, deploy, "the deployment should be renamed")
+	assert.Equal(t, []string{"app-config-green"}, fieldValues(deploy, "spec", "template", "spec", "volumes", "configMap", "name"), "config map volume")
+	assert.Equal(t, []string{"legacy-v2-green"}, fieldValues(deploy, "spec", "template", "spec", "containers", "envFrom", "configMapRef", "name"), "config map ref")
+	assert.Equal(t, []string{"missing-secret"}, fieldValues(deploy, "spec", "template", "spec", "containers", "env", "valueFrom", "secretKeyRef", "name"), "the missing secret should not be renamed")
+
+	ingress := resources["jx/Ingress/web-green"]
+	require.NotNil(t, ingress, "the ingress should be renamed")
+	assert.Equal(t, []string{"web-green"}, fieldValues(ingress, "spec", "rules", "http", "paths", "backend", "service", "name"), "ingress service")
+
+	assert.Len(t, o.References, 3, "renamed references")
+	require.Len(t, o.Unresolved, 1, "unresolved references")
+	assert.Equal(t, "Secret", o.Unresolved[0].Kind, "kind of the unresolved reference")
+	assert.Equal(t, "missing-secret", o.Unresolved[0].Name, "name of the unresolved reference")
+	assert.Equal(t, "deploy.yaml", o.Unresolved[0].File, "file of the unresolved reference")
+
+	// lets check running again does not suffix the names twice
+	err = o.Run()
+	require.NoError(t, err, "failed to suffix the names again")
+	assert.Empty(t, o.Renames, "renames on the second run")
+	assert.Len(t, o.Unresolved, 1, "unresolved references on the second run")
+}
+
+func TestSuffixNamesConfigMaps(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := suffixnames.NewCmdSuffixNames()
+	o.Dir = tmpDir
+	o.Suffix = "-v2"
+	o.Kinds = []string{"ConfigMap"}
+	err := o.Run()
+	require.NoError(t, err, "failed to suffix the names")
+
+	assert.Equal(t, map[string]string{
+		"jx/ConfigMap/app-config": "app-config-v2",
+	}, o.RenameMap(), "the config map which already has the suffix should not be renamed")
+
+	resources := loadResources(t, tmpDir)
+	deploy := resources["jx/Deployment/web"]
+	require.NotNil(t, deploy, "the deployment should not be renamed")
+	assert.Equal(t, []string{"app-config-v2"}, fieldValues(deploy, "spec", "template", "spec", "volumes", "configMap", "name"), "config map volume")
+	assert.Equal(t, []string{"legacy-v2"}, fieldValues(deploy, "spec", "template", "spec", "containers", "envFrom", "configMapRef", "name"), "config map ref")
+}
+
+func TestSuffixNamesMissingSuffix(t *testing.T) {
+	_, o := suffixnames.NewCmdSuffixNames()
+	o.Dir = "test_data"
+	err := o.Run()
+	require.Error(t, err, "should fail without a suffix")
+}
+
+// loadResources loads the resources in the directory indexed by their key
+func loadResources(t *testing.T, dir string) map[string]*unstructured.Unstructured {
+	answer := map[string]*unstructured.Unstructured{}
+	err := resourcehelpers.VisitFiles(dir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+		answer[resourcehelpers.ResourceKey(u)] = u
+		return nil
+	})
+	require.NoError(t, err, "failed to load resources in %s", dir)
+	return answer
+}
+
+func fieldValues(u *unstructured.Unstructured, fields ...string) []string {
+	values, _ := resourcehelpers.GetFieldValues(u.Object, fields...)
+	var answer []string
+	for _, v := range values {
+		answer = append(answer, v.(string))
+	}
+	return answer
+}
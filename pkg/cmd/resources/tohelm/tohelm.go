@@ -0,0 +1,369 @@
+package tohelm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/imagelist"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Generates a helm chart skeleton from the kubernetes resources in the given directory tree
+
+		The resources are copied into the templates directory of the chart keeping their relative paths and a Chart.yaml is generated from the --name and --version. The replicas of Deployments, StatefulSets and ReplicaSets and the tagged images of the containers and init containers of the workloads are extracted into the values.yaml file and replaced with references to the values in the templates.
+
+		The values of each workload are stored under the camel case name of the workload such as 'myApp.replicaCount' and 'myApp.image.repository'. If a workload has more than one container the images are stored under the camel case name of each container such as 'myApp.sidecar.image.tag'. Each parameterized field is reported.
+`)
+
+	cmdExample = templates.Examples(`
+		# generates a chart from the resources in the config dir
+		%s resources to-helm --dir config --name myapp
+
+		# generates a chart with a version in a specific directory
+		%s resources to-helm --dir config --name myapp --version 1.0.0 --out-dir charts/myapp
+	`)
+
+	// replicaKinds the kinds of resource whose replicas are parameterized
+	replicaKinds = []string{"Deployment", "StatefulSet", "ReplicaSet"}
+
+	// containerFields the fields of a pod spec which contain the containers whose images are parameterized
+	containerFields = []string{"containers", "initContainers"}
+)
+
+const (
+	// DefaultVersion the default version of the generated chart
+	DefaultVersion = "0.1.0"
+
+	// placeholderFormat the format of the placeholders replaced by the value references after the templates
+	// are marshalled so that the references are not quoted
+	placeholderFormat = "__tohelm_%d__"
+)
+
+// Parameter a field of a resource which was extracted into the values
+type Parameter struct {
+	// File the template file containing the resource relative to the chart
+	File string
+
+	// Resource the key of the resource
+	Resource string
+
+	// Field the path of the field
+	Field string
+
+	// Value the dot separated path of the value in the values.yaml file
+	Value string
+}
+
+// String returns a description of the parameter
+func (p *Parameter) String() string {
+	return fmt.Sprintf("%s %s %s => .Values.%s", p.File, p.Resource, p.Field, p.Value)
+}
+
+// Options the options for the command
+type Options struct {
+	Dir          string
+	OutDir       string
+	Name         string
+	Version      string
+	Parameters   []Parameter
+	Values       map[string]interface{}
+	placeholders map[string]string
+	valueKeys    map[string]string
+}
+
+// NewCmdToHelm creates a command object for the command
+func NewCmdToHelm() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "to-helm",
+		Short:   "Generates a helm chart skeleton from the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "", "the directory to generate the chart in. Defaults to the --name")
+	cmd.Flags().StringVarP(&o.Name, "name", "n", "", "the name of the chart")
+	cmd.Flags().StringVarP(&o.Version, "version", "", DefaultVersion, "the version of the chart")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Name == "" {
+		return options.MissingOption("name")
+	}
+	if o.Version == "" {
+		o.Version = DefaultVersion
+	}
+	if o.OutDir == "" {
+		o.OutDir = o.Name
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	chartFile := filepath.Join(o.OutDir, "Chart.yaml")
+	exists, err := files.FileExists(chartFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", chartFile)
+	}
+	if exists {
+		return errors.Errorf("the chart %s already exists", chartFile)
+	}
+	absOutDir, err := filepath.Abs(o.OutDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the absolute dir of %s", o.OutDir)
+	}
+
+	o.Parameters = nil
+	o.Values = map[string]interface{}{}
+	o.valueKeys = map[string]string{}
+	templatesDir := filepath.Join(o.OutDir, "templates")
+	err = filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return nil
+		}
+		if info.IsDir() {
+			// lets not convert the chart if it is generated inside the directory
+			if abs, _ := filepath.Abs(path); abs == absOutDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		return o.convertFile(path, filepath.Join(templatesDir, rel))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert the resources in dir %s", o.Dir)
+	}
+
+	err = o.saveYAML(filepath.Join(o.OutDir, "values.yaml"), o.Values)
+	if err != nil {
+		return err
+	}
+	err = o.saveYAML(chartFile, map[string]interface{}{
+		"apiVersion":  "v2",
+		"name":        o.Name,
+		"description": fmt.Sprintf("A Helm chart for %s", o.Name),
+		"type":        "application",
+		"version":     o.Version,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range o.Parameters {
+		log.Logger().Infof("parameterized %s", termcolor.ColorInfo(o.Parameters[i].String()))
+	}
+	log.Logger().Infof("generated chart %s with %d parameters", termcolor.ColorInfo(o.OutDir), len(o.Parameters))
+	return nil
+}
+
+// convertFile parameterizes the resources of the file writing them to the template file
+func (o *Options) convertFile(path, templateFile string) error {
+	resources, err := resourcehelpers.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(o.OutDir, templateFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the relative path of %s", templateFile)
+	}
+	rel = filepath.ToSlash(rel)
+
+	o.placeholders = map[string]string{}
+	for _, u := range resources {
+		if !resourcehelpers.IsResource(u) {
+			continue
+		}
+		err = o.parameterize(u, rel)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parameterize %s in file %s", resourcehelpers.ResourceKey(u), path)
+		}
+	}
+	data, err := resourcehelpers.ToYAML(resources)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the resources of file %s", path)
+	}
+	text := string(data)
+	for placeholder, reference := range o.placeholders {
+		text = strings.ReplaceAll(text, placeholder, reference)
+	}
+	err = os.MkdirAll(filepath.Dir(templateFile), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(templateFile))
+	}
+	err = ioutil.WriteFile(templateFile, []byte(text), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", templateFile)
+	}
+	return nil
+}
+
+// parameterize replaces the replicas and images of the workload with references to the values
+func (o *Options) parameterize(u *unstructured.Unstructured, file string) error {
+	podSpecPath := resourcehelpers.PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return nil
+	}
+	key := o.valueKey(u)
+	values := map[string]interface{}{}
+
+	if stringhelpers.StringArrayIndex(replicaKinds, u.GetKind()) >= 0 {
+		replicas, found, err := unstructured.NestedFieldNoCopy(u.Object, "spec", "replicas")
+		if err == nil && found && replicas != nil {
+			values["replicaCount"] = replicas
+			err = unstructured.SetNestedField(u.Object, o.reference(u, file, "spec.replicas", key+".replicaCount"), "spec", "replicas")
+			if err != nil {
+				return errors.Wrapf(err, "failed to set spec.replicas")
+			}
+		}
+	}
+
+	var containers []map[string]interface{}
+	var paths []string
+	for _, field := range containerFields {
+		fieldPath := append(append([]string{}, podSpecPath...), field)
+		list, _, err := unstructured.NestedFieldNoCopy(u.Object, fieldPath...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s", strings.Join(fieldPath, "."))
+		}
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			c, ok := item.(map[string]interface{})
+			if ok {
+				containers = append(containers, c)
+				name, _ := c["name"].(string)
+				paths = append(paths, fmt.Sprintf("%s[%s].image", strings.Join(fieldPath, "."), name))
+			}
+		}
+	}
+	for i, c := range containers {
+		image, _ := c["image"].(string)
+		if image == "" || strings.Contains(image, "{{") {
+			continue
+		}
+		img := imagelist.ParseImage(image)
+		if img.Tag == "" || img.Digest != "" {
+			continue
+		}
+		imageKey := "image"
+		imageValues := values
+		if len(containers) > 1 {
+			name, _ := c["name"].(string)
+			containerValues := map[string]interface{}{}
+			values[camelCase(name)] = containerValues
+			imageKey = camelCase(name) + ".image"
+			imageValues = containerValues
+		}
+		imageValues["image"] = map[string]interface{}{
+			"repository": strings.TrimSuffix(image, ":"+img.Tag),
+			"tag":        img.Tag,
+		}
+		valuePath := key + "." + imageKey
+		repository := o.reference(u, file, paths[i], valuePath+".repository")
+		c["image"] = repository + ":" + o.reference(u, file, paths[i], valuePath+".tag")
+	}
+	if len(values) > 0 {
+		o.Values[key] = values
+	}
+	return nil
+}
+
+// reference records the parameter returning a placeholder which is replaced by the reference to the value
+func (o *Options) reference(u *unstructured.Unstructured, file, field, valuePath string) string {
+	o.Parameters = append(o.Parameters, Parameter{
+		File:     file,
+		Resource: resourcehelpers.ResourceKey(u),
+		Field:    field,
+		Value:    valuePath,
+	})
+	placeholder := fmt.Sprintf(placeholderFormat, len(o.placeholders))
+	o.placeholders[placeholder] = "{{ .Values." + valuePath + " }}"
+	return placeholder
+}
+
+// valueKey returns the unique key of the values of the workload
+func (o *Options) valueKey(u *unstructured.Unstructured) string {
+	resourceKey := resourcehelpers.ResourceKey(u)
+	key := camelCase(u.GetName())
+	for i := 2; ; i++ {
+		existing, ok := o.valueKeys[key]
+		if !ok || existing == resourceKey {
+			o.valueKeys[key] = resourceKey
+			return key
+		}
+		key = fmt.Sprintf("%s%d", camelCase(u.GetName()), i)
+	}
+}
+
+func (o *Options) saveYAML(path string, value interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal file %s", path)
+	}
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// camelCase converts a kubernetes name such as 'my-app' into a key which can be used in a go template such as
+// 'myApp'
+func camelCase(name string) string {
+	var buf strings.Builder
+	upper := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = buf.Len() > 0
+			continue
+		}
+		if buf.Len() == 0 && unicode.IsDigit(r) {
+			buf.WriteRune('v')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,88 @@
+package tohelm_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/tohelm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestToHelm(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "mychart")
+
+	_, o := tohelm.NewCmdToHelm()
+	o.Dir = filepath.Join("test_data", "config")
+	o.OutDir = outDir
+	o.Name = "mychart"
+	err := o.Run()
+	require.NoError(t, err, "failed to generate the chart")
+
+	chart := loadYAML(t, filepath.Join(outDir, "Chart.yaml"))
+	assert.Equal(t, "v2", chart["apiVersion"], "apiVersion")
+	assert.Equal(t, "mychart", chart["name"], "name")
+	assert.Equal(t, tohelm.DefaultVersion, chart["version"], "version")
+
+	values := loadYAML(t, filepath.Join(outDir, "values.yaml"))
+	assert.Equal(t, map[string]interface{}{
+		"webApp": map[string]interface{}{
+			"replicaCount": float64(2),
+			"image": map[string]interface{}{
+				"repository": "nginx",
+				"tag":        "1.19.2",
+			},
+		},
+		"db": map[string]interface{}{
+			"replicaCount": float64(1),
+			"postgres": map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "postgres",
+					"tag":        "12.4",
+				},
+			},
+		},
+	}, values, "values")
+
+	web := readFile(t, filepath.Join(outDir, "templates", "web.yaml"))
+	assert.Contains(t, web, "  replicas: {{ .Values.webApp.replicaCount }}\n", "web template")
+	assert.Contains(t, web, "- image: {{ .Values.webApp.image.repository }}:{{ .Values.webApp.image.tag }}\n", "web template")
+	assert.Contains(t, web, "kind: Service", "the service should be copied")
+
+	db := readFile(t, filepath.Join(outDir, "templates", "db", "statefulset.yaml"))
+	assert.Contains(t, db, "- image: {{ .Values.db.postgres.image.repository }}:{{ .Values.db.postgres.image.tag }}\n", "db template")
+	assert.Contains(t, db, "image: busybox\n", "the image without a tag should not be parameterized")
+	assert.Contains(t, db, "postgres-exporter@sha256:", "the image with a digest should not be parameterized")
+
+	var fields []string
+	for _, p := range o.Parameters {
+		fields = append(fields, p.Resource+" "+p.Field)
+	}
+	assert.Equal(t, []string{
+		"StatefulSet/db spec.replicas",
+		"StatefulSet/db spec.template.spec.containers[postgres].image",
+		"StatefulSet/db spec.template.spec.containers[postgres].image",
+		"Deployment/web-app spec.replicas",
+		"Deployment/web-app spec.template.spec.containers[nginx].image",
+		"Deployment/web-app spec.template.spec.containers[nginx].image",
+	}, fields, "parameters")
+
+	// lets not overwrite an existing chart
+	err = o.Run()
+	require.Error(t, err, "should fail if the chart already exists")
+}
+
+func loadYAML(t *testing.T, path string) map[string]interface{} {
+	answer := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(readFile(t, path)), &answer)
+	require.NoError(t, err, "failed to parse %s", path)
+	return answer
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	return string(data)
+}
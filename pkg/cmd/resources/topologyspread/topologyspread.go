@@ -0,0 +1,198 @@
+package topologyspread
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds a topology spread constraint to the Deployment and StatefulSet resources in the given directory tree
+
+		The constraint spreads the pods of each workload across the --topology-key, which defaults to the zone of the nodes, using a label selector of the labels of the workload's own pods from its spec.selector.matchLabels or its pod template labels. This applies a high availability policy to all the workloads in bulk.
+
+		Workloads which already have a constraint with the same topology key and label selector are left untouched even if its maxSkew or whenUnsatisfiable are different. Any other constraints are kept.
+`)
+
+	cmdExample = templates.Examples(`
+		# spreads the pods of all the deployments and statefulsets across zones
+		%s resources topology-spread
+
+		# spreads the pods of the matching resources across nodes
+		%s resources topology-spread --dir config-root/namespaces/jx --topology-key kubernetes.io/hostname --selector tier=web
+
+		# requires the pods of the statefulsets to be spread evenly across zones
+		%s resources topology-spread --kind StatefulSet --when-unsatisfiable DoNotSchedule
+	`)
+
+	// DefaultKinds the kinds of resource which are modified if no kinds are specified
+	DefaultKinds = []string{"Deployment", "StatefulSet"}
+
+	// WhenUnsatisfiableValues the supported values of whenUnsatisfiable
+	WhenUnsatisfiableValues = []string{"ScheduleAnyway", "DoNotSchedule"}
+)
+
+const (
+	// DefaultTopologyKey the default topology key which spreads the pods across zones
+	DefaultTopologyKey = "topology.kubernetes.io/zone"
+
+	topologySpreadConstraints = "topologySpreadConstraints"
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir               string
+	TopologyKey       string
+	MaxSkew           int
+	WhenUnsatisfiable string
+	Modified          []string
+	Files             []string
+}
+
+// NewCmdTopologySpread creates a command object for the command
+func NewCmdTopologySpread() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "topology-spread",
+		Short:   "Adds a topology spread constraint to the Deployment and StatefulSet resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.TopologyKey, "topology-key", "t", DefaultTopologyKey, "the node label the pods are spread across")
+	cmd.Flags().IntVarP(&o.MaxSkew, "max-skew", "", 1, "the maximum difference in the number of pods between the topology domains")
+	cmd.Flags().StringVarP(&o.WhenUnsatisfiable, "when-unsatisfiable", "", "ScheduleAnyway", fmt.Sprintf("how to schedule a pod if the constraint cannot be satisfied. Values: %s", strings.Join(WhenUnsatisfiableValues, ", ")))
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.TopologyKey == "" {
+		return options.MissingOption("topology-key")
+	}
+	if o.MaxSkew < 1 {
+		return options.InvalidOptionf("max-skew", o.MaxSkew, "must be at least 1")
+	}
+	if o.WhenUnsatisfiable == "" {
+		o.WhenUnsatisfiable = "ScheduleAnyway"
+	}
+	if stringhelpers.StringArrayIndex(WhenUnsatisfiableValues, o.WhenUnsatisfiable) < 0 {
+		return options.InvalidOption("when-unsatisfiable", o.WhenUnsatisfiable, WhenUnsatisfiableValues)
+	}
+	if len(o.Kinds) == 0 {
+		o.Kinds = DefaultKinds
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.Modified = nil
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		if stringhelpers.StringArrayIndex(DefaultKinds, u.GetKind()) < 0 {
+			return false, nil
+		}
+		modified, err := o.addConstraint(u)
+		if err != nil || !modified {
+			return false, err
+		}
+		o.Modified = append(o.Modified, resourcehelpers.ResourceKey(u))
+		if len(o.Files) == 0 || o.Files[len(o.Files)-1] != path {
+			o.Files = append(o.Files, path)
+		}
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add the topology spread constraints to resources in dir %s", o.Dir)
+	}
+	for _, key := range o.Modified {
+		log.Logger().Infof("added a topology spread constraint to %s", termcolor.ColorInfo(key))
+	}
+	log.Logger().Infof("added topology spread constraints to %d resources in %d files", len(o.Modified), len(o.Files))
+	return nil
+}
+
+// addConstraint adds the topology spread constraint to the pod template of the workload if it does not already
+// have an equivalent constraint
+func (o *Options) addConstraint(u *unstructured.Unstructured) (bool, error) {
+	key := resourcehelpers.ResourceKey(u)
+	matchLabels, err := podLabels(u)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find the pod labels of %s", key)
+	}
+	if len(matchLabels) == 0 {
+		log.Logger().Warnf("cannot add a topology spread constraint to %s as it has no spec.selector.matchLabels or pod template labels", key)
+		return false, nil
+	}
+
+	path := []string{"spec", "template", "spec", topologySpreadConstraints}
+	constraints, _, err := unstructured.NestedSlice(u.Object, path...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get spec.template.spec.%s of %s", topologySpreadConstraints, key)
+	}
+	labelSelector := map[string]interface{}{"matchLabels": matchLabels}
+	for _, c := range constraints {
+		m, ok := c.(map[string]interface{})
+		if ok && m["topologyKey"] == o.TopologyKey && reflect.DeepEqual(m["labelSelector"], labelSelector) {
+			log.Logger().Debugf("%s already has a topology spread constraint for %s", key, o.TopologyKey)
+			return false, nil
+		}
+	}
+
+	constraints = append(constraints, map[string]interface{}{
+		"maxSkew":           int64(o.MaxSkew),
+		"topologyKey":       o.TopologyKey,
+		"whenUnsatisfiable": o.WhenUnsatisfiable,
+		"labelSelector":     labelSelector,
+	})
+	err = unstructured.SetNestedSlice(u.Object, constraints, path...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to set spec.template.spec.%s of %s", topologySpreadConstraints, key)
+	}
+	return true, nil
+}
+
+// podLabels returns the labels of the pods of the workload from its selector or from its pod template if it
+// has no selector
+func podLabels(u *unstructured.Unstructured) (map[string]interface{}, error) {
+	labels, _, err := unstructured.NestedMap(u.Object, "spec", "selector", "matchLabels")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get spec.selector.matchLabels")
+	}
+	if len(labels) > 0 {
+		return labels, nil
+	}
+	labels, _, err = unstructured.NestedMap(u.Object, "spec", "template", "metadata", "labels")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get spec.template.metadata.labels")
+	}
+	return labels, nil
+}
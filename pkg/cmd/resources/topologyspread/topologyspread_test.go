@@ -0,0 +1,118 @@
+package topologyspread_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/topologyspread"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTopologySpread(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	deployFile := filepath.Join(tmpDir, "deploy.yaml")
+	statefulSetFile := filepath.Join(tmpDir, "statefulset.yaml")
+	existingFile := filepath.Join(tmpDir, "existing.yaml")
+
+	_, o := topologyspread.NewCmdTopologySpread()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to add the topology spread constraints")
+
+	assert.Equal(t, []string{"jx/Deployment/web", "jx/StatefulSet/db"}, o.Modified, "modified resources")
+	assert.Equal(t, []string{deployFile, statefulSetFile}, o.Files, "modified files")
+
+	// the constraint should use the selector labels rather than all the labels of the pods
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"maxSkew":           int64(1),
+			"topologyKey":       "topology.kubernetes.io/zone",
+			"whenUnsatisfiable": "ScheduleAnyway",
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "web"},
+			},
+		},
+	}, loadConstraints(t, deployFile, 0), "constraints of the web deployment")
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"maxSkew":           int64(1),
+			"topologyKey":       "kubernetes.io/hostname",
+			"whenUnsatisfiable": "DoNotSchedule",
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "db"},
+			},
+		},
+		map[string]interface{}{
+			"maxSkew":           int64(1),
+			"topologyKey":       "topology.kubernetes.io/zone",
+			"whenUnsatisfiable": "ScheduleAnyway",
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "db"},
+			},
+		},
+	}, loadConstraints(t, statefulSetFile, 0), "the existing constraint of the statefulset should be kept")
+
+	// the equivalent constraint should be left untouched
+	constraints := loadConstraints(t, existingFile, 0)
+	require.Len(t, constraints, 1, "constraints of the api deployment")
+	assert.Equal(t, int64(2), constraints[0].(map[string]interface{})["maxSkew"], "maxSkew of the existing constraint")
+
+	// adding the constraints again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to add the topology spread constraints again")
+	assert.Empty(t, o.Modified, "modified resources on second run")
+	assert.Empty(t, o.Files, "modified files on second run")
+}
+
+func TestTopologySpreadOptions(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+	existingFile := filepath.Join(tmpDir, "existing.yaml")
+
+	_, o := topologyspread.NewCmdTopologySpread()
+	o.Dir = tmpDir
+	o.Names = []string{"api"}
+	o.TopologyKey = "kubernetes.io/hostname"
+	o.MaxSkew = 2
+	o.WhenUnsatisfiable = "DoNotSchedule"
+	err := o.Run()
+	require.NoError(t, err, "failed to add the topology spread constraints")
+
+	assert.Equal(t, []string{"jx/Deployment/api"}, o.Modified, "modified resources")
+	constraints := loadConstraints(t, existingFile, 0)
+	require.Len(t, constraints, 2, "constraints of the api deployment")
+	assert.Equal(t, map[string]interface{}{
+		"maxSkew":           int64(2),
+		"topologyKey":       "kubernetes.io/hostname",
+		"whenUnsatisfiable": "DoNotSchedule",
+		"labelSelector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"app": "api"},
+		},
+	}, constraints[1], "the added constraint")
+}
+
+func TestTopologySpreadInvalid(t *testing.T) {
+	for _, o := range []*topologyspread.Options{
+		{TopologyKey: "", MaxSkew: 1},
+		{TopologyKey: topologyspread.DefaultTopologyKey, MaxSkew: 0},
+		{TopologyKey: topologyspread.DefaultTopologyKey, MaxSkew: 1, WhenUnsatisfiable: "Sometimes"},
+	} {
+		o.Dir = "test_data"
+		err := o.Run()
+		assert.Error(t, err, "should fail for %#v", o)
+	}
+}
+
+func loadConstraints(t *testing.T, path string, index int) []interface{} {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.True(t, index < len(resources), "no document %d in %s", index, path)
+
+	constraints, _, err := unstructured.NestedSlice(resources[index].Object, "spec", "template", "spec", "topologySpreadConstraints")
+	require.NoError(t, err, "failed to get the topology spread constraints in %s", path)
+	return constraints
+}
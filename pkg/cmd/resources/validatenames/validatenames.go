@@ -0,0 +1,115 @@
+package validatenames
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/policies"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Validates the names of the kubernetes resources in the given directory tree are valid RFC 1123 DNS names
+
+		The names of Namespaces must be DNS-1123 labels of up to 63 characters, the names of Services must be DNS-1035 labels which also start with a letter and the names of the other kinds must be DNS-1123 subdomains of up to 253 characters. Additional kinds whose names must be labels, such as StatefulSets whose names are used as pod host names, can be specified via --label-kind. The names of RBAC resources such as ClusterRoles are not checked as they can contain ':'.
+
+		Each invalid name is logged as a warning with a suggested valid name unless --enforce is specified in which case the command fails if any names are invalid. This catches names which are too long or contain invalid characters, such as those generated by the prefix-names and suffix-names commands, before they are applied.
+`)
+
+	cmdExample = templates.Examples(`
+		# validates the names of the resources in the current directory
+		%s resources validate-names
+
+		# fails if any names are invalid also checking the names of the StatefulSets are labels
+		%s resources validate-names --dir config-root --label-kind StatefulSet --enforce
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir        string
+	LabelKinds []string
+	Enforce    bool
+	Violations []policies.Violation
+}
+
+// NewCmdValidateNames creates a command object for the command
+func NewCmdValidateNames() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "validate-names",
+		Short:   "Validates the names of the kubernetes resources in the given directory tree are valid RFC 1123 DNS names",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.LabelKinds, "label-kind", "", nil, "the additional kinds whose names must be DNS-1123 labels of up to 63 characters such as StatefulSet")
+	cmd.Flags().BoolVarP(&o.Enforce, "enforce", "", false, "fails if any names are invalid")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	formats := map[string]string{}
+	for k, v := range policies.DefaultNameFormats {
+		formats[k] = v
+	}
+	for _, kind := range o.LabelKinds {
+		formats[kind] = policies.NameFormatLabel
+	}
+	engine := &policies.Engine{
+		Selector: o.Selector,
+		Rules: []policies.Rule{
+			&policies.RFC1123NameRule{
+				Formats:   formats,
+				SkipKinds: policies.DefaultSkipNameKinds,
+			},
+		},
+	}
+	var err error
+	o.Violations, err = engine.CheckDir(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate the names of the resources in dir %s", o.Dir)
+	}
+
+	for _, v := range o.Violations {
+		text := fmt.Sprintf("%s: %s %s", v.File, v.Kind, v.Message)
+		if v.Suggestion != "" {
+			text += fmt.Sprintf(" so try '%s'", v.Suggestion)
+		}
+		log.Logger().Warnf(text)
+	}
+	count := len(o.Violations)
+	if count == 0 {
+		log.Logger().Infof("the names of the resources in dir %s are valid", o.Dir)
+		return nil
+	}
+	if !o.Enforce {
+		log.Logger().Warnf("found %s invalid names", termcolor.ColorWarning(fmt.Sprintf("%d", count)))
+		return nil
+	}
+	return errors.Errorf("found %d invalid names: %s", count, strings.Join(names(o.Violations), ", "))
+}
+
+func names(violations []policies.Violation) []string {
+	var answer []string
+	for _, v := range violations {
+		answer = append(answer, v.Kind+"/"+v.Name)
+	}
+	return answer
+}
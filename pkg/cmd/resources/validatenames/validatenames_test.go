@@ -0,0 +1,51 @@
+package validatenames_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validatenames"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNames(t *testing.T) {
+	_, o := validatenames.NewCmdValidateNames()
+	o.Dir = filepath.Join("test_data")
+
+	err := o.Run()
+	require.NoError(t, err, "should not fail without --enforce")
+
+	require.Len(t, o.Violations, 2, "violations")
+	v := o.Violations[0]
+	assert.Equal(t, "apps/resources.yaml", v.File, "file")
+	assert.Equal(t, "Service", v.Kind, "kind")
+	assert.Contains(t, v.Message, "DNS-1035 label", "message")
+	assert.Len(t, v.Suggestion, 63, "the suggestion %s should be truncated", v.Suggestion)
+
+	v = o.Violations[1]
+	assert.Equal(t, "ConfigMap", v.Kind, "kind")
+	assert.Equal(t, "web-settings", v.Suggestion, "suggestion")
+}
+
+func TestValidateNamesEnforce(t *testing.T) {
+	_, o := validatenames.NewCmdValidateNames()
+	o.Dir = filepath.Join("test_data")
+	o.LabelKinds = []string{"StatefulSet"}
+	o.Enforce = true
+
+	err := o.Run()
+	require.Error(t, err, "should fail with --enforce")
+	assert.Contains(t, err.Error(), "StatefulSet/web.cache")
+	require.Len(t, o.Violations, 3, "violations")
+	assert.Equal(t, "web-cache", o.Violations[2].Suggestion, "suggestion")
+
+	_, o = validatenames.NewCmdValidateNames()
+	o.Dir = filepath.Join("test_data")
+	o.Kinds = []string{"Deployment", "ClusterRole"}
+	o.Enforce = true
+
+	err = o.Run()
+	require.NoError(t, err, "should not fail if the selected resources have valid names")
+	assert.Empty(t, o.Violations, "violations")
+}
@@ -0,0 +1,342 @@
+package validaterefs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Validates that the resources referenced by the kubernetes resources in the given directory tree exist in the tree
+
+		The ConfigMaps, Secrets and ServiceAccounts used by pods and the Services used by Ingresses are checked. Optional references are ignored.
+`)
+
+	cmdExample = templates.Examples(`
+		# validates the references of all the resources in the current directory
+		%s resources validate-references
+
+		# reports the dangling references without failing
+		%s resources validate-references --dir config-root --warn-only
+	`)
+
+	// podSpecReferences the references within a pod spec
+	podSpecReferences = []Reference{
+		{Path: "volumes.configMap", NameField: "name", Kind: "ConfigMap"},
+		{Path: "volumes.secret", NameField: "secretName", Kind: "Secret"},
+		{Path: "volumes.projected.sources.configMap", NameField: "name", Kind: "ConfigMap"},
+		{Path: "volumes.projected.sources.secret", NameField: "name", Kind: "Secret"},
+		{Path: "containers.envFrom.configMapRef", NameField: "name", Kind: "ConfigMap"},
+		{Path: "containers.envFrom.secretRef", NameField: "name", Kind: "Secret"},
+		{Path: "containers.env.valueFrom.configMapKeyRef", NameField: "name", Kind: "ConfigMap"},
+		{Path: "containers.env.valueFrom.secretKeyRef", NameField: "name", Kind: "Secret"},
+		{Path: "initContainers.envFrom.configMapRef", NameField: "name", Kind: "ConfigMap"},
+		{Path: "initContainers.envFrom.secretRef", NameField: "name", Kind: "Secret"},
+		{Path: "initContainers.env.valueFrom.configMapKeyRef", NameField: "name", Kind: "ConfigMap"},
+		{Path: "initContainers.env.valueFrom.secretKeyRef", NameField: "name", Kind: "Secret"},
+		{Path: "imagePullSecrets", NameField: "name", Kind: "Secret"},
+		{NameField: "serviceAccountName", Kind: "ServiceAccount", Ignore: []string{"default"}},
+	}
+
+	// kindReferences the references of other kinds
+	kindReferences = map[string][]Reference{
+		"Ingress": {
+			{Path: "spec.rules.http.paths.backend.service", NameField: "name", Kind: "Service"},
+			{Path: "spec.rules.http.paths.backend", NameField: "serviceName", Kind: "Service"},
+			{Path: "spec.defaultBackend.service", NameField: "name", Kind: "Service"},
+			{Path: "spec.backend", NameField: "serviceName", Kind: "Service"},
+			{Path: "spec.tls", NameField: "secretName", Kind: "Secret", RenameOnly: true},
+		},
+		"StatefulSet": {
+			{Path: "spec", NameField: "serviceName", Kind: "Service", RenameOnly: true},
+		},
+		"RoleBinding": {
+			{Path: "subjects", NameField: "name", Kind: "ServiceAccount", KindField: "kind", NamespaceField: "namespace", RenameOnly: true},
+			{Path: "roleRef", NameField: "name", Kind: "Role", KindField: "kind", RenameOnly: true},
+			{Path: "roleRef", NameField: "name", Kind: "ClusterRole", KindField: "kind", RenameOnly: true},
+		},
+		"ClusterRoleBinding": {
+			{Path: "subjects", NameField: "name", Kind: "ServiceAccount", KindField: "kind", NamespaceField: "namespace", RenameOnly: true},
+			{Path: "roleRef", NameField: "name", Kind: "ClusterRole", KindField: "kind", RenameOnly: true},
+		},
+	}
+)
+
+// Reference describes a field which references another resource by name
+type Reference struct {
+	// Path the dot separated path to the objects containing the name field. Blank means the object itself
+	Path string
+
+	// NameField the field containing the name of the referenced resource
+	NameField string
+
+	// Kind the kind of the referenced resource
+	Kind string
+
+	// Ignore the names which do not need to exist such as the default ServiceAccount
+	Ignore []string
+
+	// KindField if specified the field of the object which must contain the Kind such as the kind of an RBAC subject
+	KindField string
+
+	// NamespaceField if specified the field of the object containing the namespace of the referenced resource.
+	// Otherwise the referenced resource is in the namespace of the resource
+	NamespaceField string
+
+	// RenameOnly the reference is renamed but not validated as the referenced resource is often created outside
+	// of the tree such as a TLS secret or a binding of a role
+	RenameOnly bool
+}
+
+// ResourceReference a reference from a resource to another resource
+type ResourceReference struct {
+	// File the file containing the resource with the reference relative to the directory
+	File string
+
+	// Resource the key of the resource with the reference
+	Resource string
+
+	// Field the path of the field containing the reference
+	Field string
+
+	// Kind the kind of the referenced resource
+	Kind string
+
+	// Namespace the namespace of the referenced resource if it is not the namespace of the resource
+	Namespace string
+
+	// Name the name of the referenced resource
+	Name string
+
+	// Optional true if the referenced resource does not need to exist
+	Optional bool
+}
+
+// String returns a description of the reference as a dangling reference
+func (r *ResourceReference) String() string {
+	return fmt.Sprintf("%s: %s references missing %s %s via %s", r.File, r.Resource, r.Kind, r.Name, r.Field)
+}
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir      string
+	WarnOnly bool
+	Dangling []ResourceReference
+}
+
+type resource struct {
+	u    *unstructured.Unstructured
+	path string
+}
+
+// NewCmdValidateRefs creates a command object for the command
+func NewCmdValidateRefs() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "validate-references",
+		Aliases: []string{"validate-refs"},
+		Short:   "Validates that the resources referenced by the kubernetes resources in the given directory tree exist in the tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().BoolVarP(&o.WarnOnly, "warn-only", "w", false, "only log the dangling references rather than failing")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	var resources []resource
+	err := resourcehelpers.VisitFiles(o.Dir, resourcehelpers.Selector{WalkOptions: o.WalkOptions}, func(u *unstructured.Unstructured, path string) error {
+		resources = append(resources, resource{u: u, path: path})
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to load resources in dir %s", o.Dir)
+	}
+
+	// lets index the namespaces of the resources by kind and name
+	index := map[string][]string{}
+	for _, r := range resources {
+		key := r.u.GetKind() + "/" + r.u.GetName()
+		index[key] = append(index[key], r.u.GetNamespace())
+	}
+	exists := func(kind, ns, name string) bool {
+		for _, n := range index[kind+"/"+name] {
+			// resources without a namespace may be defaulted later so lets treat them as matching
+			if n == ns || n == "" || ns == "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	o.Dangling = nil
+	for _, r := range resources {
+		if !o.Selector.Matches(r.u) {
+			continue
+		}
+		file, err := filepath.Rel(o.Dir, r.path)
+		if err != nil {
+			file = r.path
+		}
+		for _, ref := range ResourceReferences(r.u) {
+			if !exists(ref.Kind, r.u.GetNamespace(), ref.Name) {
+				ref.File = file
+				o.Dangling = append(o.Dangling, ref)
+			}
+		}
+	}
+	sort.SliceStable(o.Dangling, func(i, j int) bool {
+		return o.Dangling[i].File < o.Dangling[j].File
+	})
+
+	for i := range o.Dangling {
+		log.Logger().Warnf(o.Dangling[i].String())
+	}
+	count := len(o.Dangling)
+	if count == 0 {
+		log.Logger().Infof("validated the references of %d resources", len(resources))
+		return nil
+	}
+	if o.WarnOnly {
+		log.Logger().Warnf("found %s dangling references", termcolor.ColorWarning(fmt.Sprintf("%d", count)))
+		return nil
+	}
+	return errors.Errorf("found %d dangling references", count)
+}
+
+// ResourceReferences returns all the references to other resources by the given resource
+func ResourceReferences(u *unstructured.Unstructured) []ResourceReference {
+	var answer []ResourceReference
+	visitReferences(u, func(m map[string]interface{}, ref *Reference, rr ResourceReference) {
+		if ref.RenameOnly || rr.Optional || stringhelpers.StringArrayIndex(ref.Ignore, rr.Name) >= 0 {
+			return
+		}
+		answer = append(answer, rr)
+	})
+	return answer
+}
+
+// AllReferences returns all the references to other resources by the given resource including the optional
+// references and the references which are only renamed
+func AllReferences(u *unstructured.Unstructured) []ResourceReference {
+	var answer []ResourceReference
+	visitReferences(u, func(m map[string]interface{}, ref *Reference, rr ResourceReference) {
+		answer = append(answer, rr)
+	})
+	return answer
+}
+
+// RenameReferences renames the references to other resources by the given resource, including the optional
+// references, using the rename function which returns the new name of the referenced resource or blank if it is
+// not renamed. The blank namespace is passed for references in the namespace of the resource. The renamed
+// references are returned with their old names
+func RenameReferences(u *unstructured.Unstructured, rename func(kind, ns, name string) string) []ResourceReference {
+	var answer []ResourceReference
+	visitReferences(u, func(m map[string]interface{}, ref *Reference, rr ResourceReference) {
+		newName := rename(rr.Kind, rr.Namespace, rr.Name)
+		if newName == "" || newName == rr.Name {
+			return
+		}
+		m[ref.NameField] = newName
+		answer = append(answer, rr)
+	})
+	return answer
+}
+
+// visitReferences invokes the function with the object containing the name field of each reference of the resource
+func visitReferences(u *unstructured.Unstructured, fn func(m map[string]interface{}, ref *Reference, rr ResourceReference)) {
+	kind := u.GetKind()
+	key := resourcehelpers.ResourceKey(u)
+	podSpecPath := resourcehelpers.PodSpecPath(kind)
+	if podSpecPath != nil {
+		// lets use the pod spec in place rather than unstructured.NestedMap which returns a copy
+		podSpec := u.Object
+		for _, f := range podSpecPath {
+			podSpec, _ = podSpec[f].(map[string]interface{})
+		}
+		if podSpec != nil {
+			findReferences(podSpec, strings.Join(podSpecPath, "."), key, podSpecReferences, fn)
+		}
+	}
+	findReferences(u.Object, "", key, kindReferences[kind], fn)
+}
+
+func findReferences(obj map[string]interface{}, prefix, key string, references []Reference, fn func(m map[string]interface{}, ref *Reference, rr ResourceReference)) {
+	for i := range references {
+		ref := &references[i]
+		values := []interface{}{obj}
+		if ref.Path != "" {
+			values, _ = resourcehelpers.GetFieldValues(obj, strings.Split(ref.Path, ".")...)
+		}
+		field := joinPath(prefix, ref.Path, ref.NameField)
+
+		// lets expand the elements if the path ends with a list
+		var objects []interface{}
+		for _, v := range values {
+			if list, ok := v.([]interface{}); ok {
+				objects = append(objects, list...)
+			} else {
+				objects = append(objects, v)
+			}
+		}
+		values = objects
+		for _, v := range values {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := m[ref.NameField].(string)
+			if name == "" {
+				continue
+			}
+			if ref.KindField != "" && m[ref.KindField] != ref.Kind {
+				continue
+			}
+			ns := ""
+			if ref.NamespaceField != "" {
+				ns, _ = m[ref.NamespaceField].(string)
+			}
+			optional, _ := m["optional"].(bool)
+			fn(m, ref, ResourceReference{
+				Resource:  key,
+				Field:     field,
+				Kind:      ref.Kind,
+				Namespace: ns,
+				Name:      name,
+				Optional:  optional,
+			})
+		}
+	}
+}
+
+func joinPath(paths ...string) string {
+	var answer []string
+	for _, p := range paths {
+		if p != "" {
+			answer = append(answer, p)
+		}
+	}
+	return strings.Join(answer, ".")
+}
@@ -0,0 +1,73 @@
+package validaterefs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/validaterefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRefs(t *testing.T) {
+	_, o := validaterefs.NewCmdValidateRefs()
+	o.Dir = "test_data"
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with dangling references")
+	assert.Equal(t, "found 4 dangling references", err.Error())
+
+	deployFile := filepath.Join("jx", "deploy.yaml")
+	expected := []validaterefs.ResourceReference{
+		{
+			File:     deployFile,
+			Resource: "jx/Deployment/web",
+			Field:    "spec.template.spec.volumes.secret.secretName",
+			Kind:     "Secret",
+			Name:     "missing-certs",
+		},
+		{
+			File:     deployFile,
+			Resource: "jx/Deployment/web",
+			Field:    "spec.template.spec.containers.envFrom.configMapRef.name",
+			Kind:     "ConfigMap",
+			Name:     "missing-env",
+		},
+		{
+			File:     deployFile,
+			Resource: "jx/Deployment/web",
+			Field:    "spec.template.spec.imagePullSecrets.name",
+			Kind:     "Secret",
+			Name:     "registry",
+		},
+		{
+			File:     filepath.Join("jx", "ingress.yaml"),
+			Resource: "jx/Ingress/web",
+			Field:    "spec.rules.http.paths.backend.service.name",
+			Kind:     "Service",
+			Name:     "api",
+		},
+	}
+	assert.Equal(t, expected, o.Dangling, "dangling references")
+}
+
+func TestValidateRefsWarnOnly(t *testing.T) {
+	_, o := validaterefs.NewCmdValidateRefs()
+	o.Dir = "test_data"
+	o.WarnOnly = true
+
+	err := o.Run()
+	require.NoError(t, err, "should not fail with --warn-only")
+	assert.Len(t, o.Dangling, 4, "dangling references")
+}
+
+func TestValidateRefsSelector(t *testing.T) {
+	_, o := validaterefs.NewCmdValidateRefs()
+	o.Dir = "test_data"
+	o.Kinds = []string{"Ingress"}
+
+	err := o.Run()
+	require.Error(t, err, "should have failed with dangling references")
+	require.Len(t, o.Dangling, 1, "dangling references")
+	assert.Equal(t, "jx/ingress.yaml: jx/Ingress/web references missing Service api via spec.rules.http.paths.backend.service.name", o.Dangling[0].String())
+}
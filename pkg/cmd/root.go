@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/annotate"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/apply"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/combine"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/condition"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/convert"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/copy"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/dedupe"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/delete"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/format"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/git"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/hash"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/hashsuffix"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helm"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/helmfile"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/image"
@@ -17,20 +25,28 @@ import (
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/label"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/lint"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/namespace"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/patch"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/plugin"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/postprocess"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/pr"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/rename"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/repository"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/requirement"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/sa"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/scheduler"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/split"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/strip"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/summary"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/upgrade"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/validate"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/variables"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/version"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/versionstream"
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/webhook"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/yget"
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/yset"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -49,6 +65,19 @@ func Main() *cobra.Command {
 			}
 		},
 	}
+	cmd.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		common.ApplyBatchModeFlags(c)
+		err := common.DefaultDirToRoot(c)
+		if err != nil {
+			return err
+		}
+		subcommand := strings.TrimSpace(strings.TrimPrefix(c.CommandPath(), cmd.Name()))
+		return common.OpenDefaultAuditLog(subcommand)
+	}
+	common.AddAuditFlags(cmd)
+	common.AddBatchModeFlags(cmd)
+	common.AddFindRootFlags(cmd)
+	cmd.AddCommand(convert.NewCmdConvert())
 	cmd.AddCommand(helm.NewCmdHelm())
 	cmd.AddCommand(helmfile.NewCmdHelmfile())
 	cmd.AddCommand(git.NewCmdGit())
@@ -58,27 +87,39 @@ func Main() *cobra.Command {
 	cmd.AddCommand(pr.NewCmdPR())
 	cmd.AddCommand(requirement.NewCmdRequirement())
 	cmd.AddCommand(repository.NewCmdRepository())
+	cmd.AddCommand(resources.NewCmdResources())
 	cmd.AddCommand(sa.NewCmdServiceAccount())
 	cmd.AddCommand(webhook.NewCmdWebhook())
 
 	cmd.AddCommand(cobras.SplitCommand(annotate.NewCmdUpdateAnnotate()))
 	cmd.AddCommand(cobras.SplitCommand(apply.NewCmdApply()))
+	cmd.AddCommand(cobras.SplitCommand(combine.NewCmdCombine()))
 	cmd.AddCommand(cobras.SplitCommand(condition.NewCmdCondition()))
 	cmd.AddCommand(cobras.SplitCommand(copy.NewCmdCopy()))
+	cmd.AddCommand(cobras.SplitCommand(dedupe.NewCmdDedupe()))
+	cmd.AddCommand(cobras.SplitCommand(delete.NewCmdDelete()))
+	cmd.AddCommand(cobras.SplitCommand(format.NewCmdFormat()))
 	cmd.AddCommand(cobras.SplitCommand(hash.NewCmdHashAnnotate()))
+	cmd.AddCommand(cobras.SplitCommand(hashsuffix.NewCmdHashSuffix()))
 	cmd.AddCommand(cobras.SplitCommand(image.NewCmdUpdateImage()))
 	cmd.AddCommand(cobras.SplitCommand(ingress.NewCmdUpdateIngress()))
 	cmd.AddCommand(cobras.SplitCommand(kustomize.NewCmdKustomize()))
 	cmd.AddCommand(cobras.SplitCommand(label.NewCmdUpdateLabel()))
 	cmd.AddCommand(cobras.SplitCommand(lint.NewCmdLint()))
 	cmd.AddCommand(cobras.SplitCommand(namespace.NewCmdUpdateNamespace()))
+	cmd.AddCommand(cobras.SplitCommand(patch.NewCmdPatch()))
 	cmd.AddCommand(cobras.SplitCommand(rename.NewCmdRename()))
 	cmd.AddCommand(cobras.SplitCommand(postprocess.NewCmdPostProcess()))
 	cmd.AddCommand(cobras.SplitCommand(scheduler.NewCmdScheduler()))
 	cmd.AddCommand(cobras.SplitCommand(split.NewCmdSplit()))
+	cmd.AddCommand(cobras.SplitCommand(strip.NewCmdStrip()))
+	cmd.AddCommand(cobras.SplitCommand(summary.NewCmdSummary()))
 	cmd.AddCommand(cobras.SplitCommand(upgrade.NewCmdUpgrade()))
+	cmd.AddCommand(cobras.SplitCommand(validate.NewCmdValidate()))
 	cmd.AddCommand(cobras.SplitCommand(variables.NewCmdVariables()))
 	cmd.AddCommand(cobras.SplitCommand(version.NewCmdVersion()))
 	cmd.AddCommand(cobras.SplitCommand(versionstream.NewCmdVersionstream()))
+	cmd.AddCommand(cobras.SplitCommand(yget.NewCmdYGet()))
+	cmd.AddCommand(cobras.SplitCommand(yset.NewCmdYSet()))
 	return cmd
 }
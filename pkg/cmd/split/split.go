@@ -7,8 +7,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
 	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -22,13 +24,24 @@ import (
 var (
 	splitLong = templates.LongDesc(`
 		Splits any YAML files which define multiple resources into separate files
+
+		Each resource is saved to a file named by the --filename-template in the directory of the original file which is then removed. The template can use the .Kind, .Name and .Namespace of the resource along with the lower function. If a file name is already used an index is appended. Any documents which are not kubernetes resources are saved to a 'misc-' file and empty documents are removed.
 `)
 
 	splitExample = templates.Examples(`
 		# splits any files containing multiple resources
 		%s split --dir .
+
+		# splits the files into a directory per namespace
+		%s split --filename-template '{{ .Namespace }}/{{ .Name }}-{{ lower .Kind }}.yaml'
 	`)
 
+	// DefaultFilenameTemplate the default template of the names of the split files
+	DefaultFilenameTemplate = "{{ .Name }}-{{ lower .Kind }}.yaml"
+
+	// invalidFilenameChars the characters of the resource names which cannot be used in file names
+	invalidFilenameChars = strings.NewReplacer("/", "-", "\\", "-", ":", "-")
+
 	// resourcesSeparator is used to separate multiple objects stored in the same YAML file
 	resourcesSeparator = "---\n"
 )
@@ -36,6 +49,20 @@ var (
 // Options the options for the command
 type Options struct {
 	Dir string
+
+	// FilenameTemplate the template of the names of the split files. If blank each document is saved to the
+	// original file name with an index appended such as 'foo2.yaml'
+	FilenameTemplate string
+
+	// Files the files which have been split
+	Files []string
+}
+
+// FilenameData the fields of a resource which can be used in the filename template
+type FilenameData struct {
+	Kind      string
+	Name      string
+	Namespace string
 }
 
 // NewCmdSplit creates a command object for the command
@@ -46,19 +73,150 @@ func NewCmdSplit() (*cobra.Command, *Options) {
 		Use:     "split",
 		Short:   "Splits any YAML files which define multiple resources into separate files",
 		Long:    splitLong,
-		Example: fmt.Sprintf(splitExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(splitExample, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.FilenameTemplate, "filename-template", "", DefaultFilenameTemplate, "the go template of the names of the split files relative to the directory of the original file")
 	return cmd, o
 }
 
 // Run implements the command
 func (o *Options) Run() error {
-	return ProcessYamlFiles(o.Dir)
+	if o.FilenameTemplate == "" {
+		return ProcessYamlFiles(o.Dir)
+	}
+	tmpl, err := template.New("filename").Funcs(template.FuncMap{"lower": strings.ToLower}).Parse(o.FilenameTemplate)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the filename template %s", o.FilenameTemplate)
+	}
+
+	// lets find the files first so that the split files are not processed again
+	var paths []string
+	err = filepath.Walk(o.Dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !resourcehelpers.IsYAMLFile(path) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the YAML files in dir %s", o.Dir)
+	}
+
+	o.Files = nil
+	for _, path := range paths {
+		err = o.splitFile(path, tmpl)
+		if err != nil {
+			return errors.Wrapf(err, "failed to split file %s", path)
+		}
+	}
+	log.Logger().Infof("split %d files", len(o.Files))
+	return nil
+}
+
+// splitFile saves each resource in the file to a separate file named by the template then removes the file.
+// Files with a single document or no resources are not modified
+func (o *Options) splitFile(path string, tmpl *template.Template) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", path)
+	}
+	docs := splitDocuments(string(data))
+	if len(docs) <= 1 {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	taken := map[string]bool{}
+	var names, texts, misc []string
+	for _, doc := range docs {
+		// lets remove any empty documents before the document
+		for strings.HasPrefix(doc, resourcesSeparator) || strings.HasPrefix(doc, "\n") {
+			doc = strings.TrimPrefix(strings.TrimPrefix(doc, resourcesSeparator), "\n")
+		}
+
+		// lets preserve any documents which cannot be parsed as a single resource
+		resources, err := resourcehelpers.ParseDocuments([]byte(doc))
+		if err != nil || len(resources) != 1 || !resourcehelpers.IsResource(resources[0]) {
+			misc = append(misc, doc)
+			continue
+		}
+		u := resources[0]
+		buf := &strings.Builder{}
+		err = tmpl.Execute(buf, &FilenameData{
+			Kind:      invalidFilenameChars.Replace(u.GetKind()),
+			Name:      invalidFilenameChars.Replace(u.GetName()),
+			Namespace: invalidFilenameChars.Replace(u.GetNamespace()),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate the filename template for %s", resourcehelpers.ResourceKey(u))
+		}
+		name, err := uniqueFileName(filepath.Join(dir, buf.String()), path, taken)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		texts = append(texts, doc)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if len(misc) > 0 {
+		name, err := uniqueFileName(filepath.Join(dir, "misc-"+filepath.Base(path)), path, taken)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		texts = append(texts, strings.Join(misc, resourcesSeparator))
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove file %s", path)
+	}
+	for i, name := range names {
+		text := texts[i]
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		err = os.MkdirAll(filepath.Dir(name), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create dir %s", filepath.Dir(name))
+		}
+		err = ioutil.WriteFile(name, []byte(text), files.DefaultFileWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save %s", name)
+		}
+	}
+	o.Files = append(o.Files, path)
+	log.Logger().Debugf("split file %s into %d files", termcolor.ColorInfo(path), len(names))
+	return nil
+}
+
+// uniqueFileName returns the file name appending an index if the file exists or has already been used. The
+// original file can be reused as it is removed
+func uniqueFileName(name, original string, taken map[string]bool) (string, error) {
+	ext := filepath.Ext(name)
+	answer := name
+	for i := 2; ; i++ {
+		exists := false
+		if answer != original {
+			var err error
+			exists, err = files.FileExists(answer)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to check if file exists %s", answer)
+			}
+		}
+		if !exists && !taken[answer] {
+			taken[answer] = true
+			return answer, nil
+		}
+		answer = strings.TrimSuffix(name, ext) + "-" + strconv.Itoa(i) + ext
+	}
 }
 
 // ProcessYamlFiles splits any files with multiple resources into separate files
@@ -75,36 +233,8 @@ func ProcessYamlFiles(dir string) error {
 			return errors.Wrapf(err, "failed to load file %s", path)
 		}
 
-		input := string(data)
-		if strings.HasPrefix(input, resourcesSeparator) {
-			input = "\n" + input
-		}
-		sections := strings.Split(input, "\n"+resourcesSeparator)
-
-		count := 0
-		var fileNames []string
-		buf := strings.Builder{}
-		for _, section := range sections {
-			if buf.Len() > 0 {
-				buf.WriteString(resourcesSeparator)
-			}
-			buf.WriteString(section)
-			if !helmhelpers.IsWhitespaceOrComments(section) {
-				count++
-
-				text := buf.String()
-				// remove all newline prefixes
-				for {
-					if !strings.HasPrefix(text, "\n") {
-						break
-					}
-					text = strings.TrimPrefix(text, "\n")
-				}
-				fileNames = append(fileNames, text)
-				buf.Reset()
-			}
-		}
-		if count >= 1 {
+		fileNames := splitDocuments(string(data))
+		if len(fileNames) >= 1 {
 			for i, text := range fileNames {
 				name := path
 				if i > 0 {
@@ -154,3 +284,34 @@ func ProcessYamlFiles(dir string) error {
 	}
 	return nil
 }
+
+// splitDocuments splits the YAML text into its documents. Any documents which only contain whitespace or
+// comments are merged into the next document
+func splitDocuments(input string) []string {
+	if strings.HasPrefix(input, resourcesSeparator) {
+		input = "\n" + input
+	}
+	sections := strings.Split(input, "\n"+resourcesSeparator)
+
+	var answer []string
+	buf := strings.Builder{}
+	for _, section := range sections {
+		if buf.Len() > 0 {
+			buf.WriteString(resourcesSeparator)
+		}
+		buf.WriteString(section)
+		if !helmhelpers.IsWhitespaceOrComments(section) {
+			text := buf.String()
+			// remove all newline prefixes
+			for {
+				if !strings.HasPrefix(text, "\n") {
+					break
+				}
+				text = strings.TrimPrefix(text, "\n")
+			}
+			answer = append(answer, text)
+			buf.Reset()
+		}
+	}
+	return answer
+}
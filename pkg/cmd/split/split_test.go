@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/jenkins-x/jx-gitops/pkg/cmd/split"
@@ -74,3 +75,59 @@ func TestSplitHelmTemplateYamlFiles(t *testing.T) {
 		i++
 	}
 }
+
+func TestSplitYamlFilesFilenameTemplate(t *testing.T) {
+	srcFile := filepath.Join("test_data", "filename-template")
+	require.DirExists(t, srcFile)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "could not create temp dir")
+
+	err = files.CopyDirOverwrite(srcFile, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
+
+	o := &split.Options{
+		Dir:              tmpDir,
+		FilenameTemplate: split.DefaultFilenameTemplate,
+	}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run in dir %s", tmpDir)
+
+	t.Logf("split files in dir %s\n", tmpDir)
+
+	assert.Equal(t, []string{filepath.Join(tmpDir, "resources.yaml")}, o.Files, "split files")
+	expected := map[string]string{
+		"bar-service.yaml":            "name: bar\n",
+		"foo-service.yaml":            "namespace: jx\n",
+		"foo-deployment.yaml":         "image: foo:1.0.0\n",
+		"foo-service-2.yaml":          "namespace: other\n",
+		"system-foo-clusterrole.yaml": "name: system:foo\n",
+		"misc-resources.yaml":         "replicaCount: 2\n",
+	}
+	before := readDir(t, tmpDir)
+	assert.Len(t, before, len(expected), "files in dir %s", tmpDir)
+	for name, text := range expected {
+		assert.Contains(t, before[name], text, "file %s", name)
+	}
+	assert.True(t, strings.HasPrefix(before["foo-service.yaml"], "# the services\n"), "the comment should be preserved")
+	assert.NotContains(t, before["foo-service-2.yaml"], "---", "the empty document should be removed")
+
+	// running the command again should not modify any files
+	err = o.Run()
+	require.NoError(t, err, "failed to run again in dir %s", tmpDir)
+	assert.Empty(t, o.Files, "split files on the second run")
+	assert.Equal(t, before, readDir(t, tmpDir), "files after the second run")
+}
+
+func readDir(t *testing.T, dir string) map[string]string {
+	fs, err := ioutil.ReadDir(dir)
+	require.NoError(t, err, "failed to read dir %s", dir)
+	answer := map[string]string{}
+	for _, f := range fs {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err, "failed to read file %s", f.Name())
+		answer[f.Name()] = string(data)
+	}
+	return answer
+}
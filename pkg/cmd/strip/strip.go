@@ -0,0 +1,188 @@
+package strip
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jenkins-x/jx-gitops/pkg/fieldpaths"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Removes the fields populated by the server from all the kubernetes resources in the given directory tree
+
+		This lets resources exported from a cluster, such as via 'kubectl get -o yaml', be committed to git without noisy diffs. By default the status, the server generated metadata, the managed fields and the kubectl last applied configuration annotation are removed. Use --keep to keep any of the default paths and --extra to remove other paths. Any maps left empty by removing a field, such as the annotations, are also removed.
+
+		The paths are dot separated and can include list indexes such as 'containers[0]' or match the element of a list by a field such as 'containers[name=foo]'. A dot in a field name can be escaped with a backslash.
+`)
+
+	cmdExample = templates.Examples(`
+		# removes the server populated fields of all the resources in the current directory
+		%s strip --dir .
+
+		# keeps the status and also removes the cluster IP of the services
+		%s strip --keep status --extra spec.clusterIP --kind Service
+	`)
+
+	// DefaultPaths the paths removed by default
+	DefaultPaths = []string{
+		"status",
+		"metadata.creationTimestamp",
+		"metadata.deletionTimestamp",
+		"metadata.deletionGracePeriodSeconds",
+		"metadata.generation",
+		"metadata.managedFields",
+		"metadata.resourceVersion",
+		"metadata.selfLink",
+		"metadata.uid",
+		`metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration`,
+		"spec.template.metadata.creationTimestamp",
+	}
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir   string
+	Keep  []string
+	Extra []string
+	Files []string
+}
+
+// NewCmdStrip creates a command object for the command
+func NewCmdStrip() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "strip",
+		Short:   "Removes the fields populated by the server from all the kubernetes resources in the given directory tree",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringArrayVarP(&o.Keep, "keep", "", nil, "the default paths to keep rather than remove")
+	cmd.Flags().StringArrayVarP(&o.Extra, "extra", "", nil, "the paths of the fields to remove in addition to the default paths")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	paths, err := o.paths()
+	if err != nil {
+		return err
+	}
+
+	o.Files = nil
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		modified := false
+		for _, steps := range paths {
+			removed, err := removePath(u.Object, steps)
+			if err != nil {
+				return false, err
+			}
+			if removed {
+				modified = true
+			}
+		}
+		if modified && (len(o.Files) == 0 || o.Files[len(o.Files)-1] != path) {
+			o.Files = append(o.Files, path)
+		}
+		return modified, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to strip the resources in dir %s", o.Dir)
+	}
+	for _, f := range o.Files {
+		log.Logger().Infof("stripped file %s", termcolor.ColorInfo(f))
+	}
+	log.Logger().Infof("stripped %d files", len(o.Files))
+	return nil
+}
+
+// paths returns the parsed paths to remove which are the default paths that are not kept and the extra paths
+func (o *Options) paths() ([][]fieldpaths.Step, error) {
+	parse := func(flag string, texts []string) ([][]fieldpaths.Step, error) {
+		var answer [][]fieldpaths.Step
+		for _, text := range texts {
+			steps, err := fieldpaths.ParsePath(text)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid --%s", flag)
+			}
+			answer = append(answer, steps)
+		}
+		return answer, nil
+	}
+	defaults, err := parse("default", DefaultPaths)
+	if err != nil {
+		return nil, err
+	}
+	keep, err := parse("keep", o.Keep)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := parse("extra", o.Extra)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer [][]fieldpaths.Step
+	for _, steps := range append(defaults, extra...) {
+		if !containsPath(keep, steps) && !containsPath(answer, steps) {
+			answer = append(answer, steps)
+		}
+	}
+	return answer, nil
+}
+
+// removePath removes the path from the object along with any maps left empty by the removal
+func removePath(obj map[string]interface{}, steps []fieldpaths.Step) (bool, error) {
+	removed, err := fieldpaths.Remove(obj, steps)
+	if err != nil || !removed {
+		return removed, err
+	}
+	for i := len(steps) - 1; i > 0; i-- {
+		parent := steps[:i]
+		if parent[len(parent)-1].Field == "" {
+			// lets leave the empty elements of lists alone
+			break
+		}
+		value, _, err := fieldpaths.Get(obj, parent)
+		if err != nil {
+			return true, err
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok || len(m) > 0 {
+			break
+		}
+		_, err = fieldpaths.Remove(obj, parent)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func containsPath(paths [][]fieldpaths.Step, steps []fieldpaths.Step) bool {
+	for _, p := range paths {
+		if reflect.DeepEqual(p, steps) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package strip_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/strip"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStrip(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := strip.NewCmdStrip()
+	o.Dir = tmpDir
+	err := o.Run()
+	require.NoError(t, err, "failed to strip")
+
+	assert.Equal(t, []string{
+		filepath.Join(tmpDir, "deployment.yaml"),
+		filepath.Join(tmpDir, "service.yaml"),
+	}, o.Files, "stripped files")
+	for _, name := range []string{"deployment.yaml", "service.yaml"} {
+		testhelpers.AssertGoldenFile(t, filepath.Join("test_data", "expected-"+name), filepath.Join(tmpDir, name))
+	}
+
+	// stripping again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to strip again")
+	assert.Empty(t, o.Files, "stripped files on the second run")
+}
+
+func TestStripKeepAndExtra(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, filepath.Join("test_data", "src"))
+
+	_, o := strip.NewCmdStrip()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Service"}
+	o.Keep = []string{"status", `metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration`}
+	o.Extra = []string{"spec.clusterIP", "spec.clusterIPs"}
+	err := o.Run()
+	require.NoError(t, err, "failed to strip")
+	assert.Equal(t, []string{filepath.Join(tmpDir, "service.yaml")}, o.Files, "stripped files")
+
+	resources, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "service.yaml"))
+	require.NoError(t, err, "failed to load service.yaml")
+	require.Len(t, resources, 1, "resources")
+	u := resources[0]
+	assert.Contains(t, u.GetAnnotations(), "kubectl.kubernetes.io/last-applied-configuration", "kept annotation")
+	_, found, _ := unstructured.NestedFieldNoCopy(u.Object, "status")
+	assert.True(t, found, "the status should be kept")
+	_, found, _ = unstructured.NestedFieldNoCopy(u.Object, "spec", "clusterIP")
+	assert.False(t, found, "the extra path should be removed")
+	assert.Empty(t, u.GetUID(), "uid")
+
+	o.Extra = []string{"spec.ports["}
+	err = o.Run()
+	require.Error(t, err, "should fail with an invalid path")
+}
@@ -0,0 +1,289 @@
+package summary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Summarises the kubernetes resources in the given directory tree by counting them by kind
+
+		The items of List resources and every document of files containing multiple resources are counted. If --by-namespace is specified the counts of each kind are shown for each namespace where cluster scoped resources are shown in the '-' column.
+
+		If --compare-dir is specified, such as a checkout of the previous commit, the number of resources of each kind which were added, removed or changed compared to that directory tree are shown instead. Resources are identified by their namespace, kind and name.
+`)
+
+	cmdExample = templates.Examples(`
+		# counts the resources in the current directory by kind
+		%s summary
+
+		# counts the resources by kind and namespace
+		%s summary --dir config-root --by-namespace
+
+		# compares the resources with the previous commit
+		git worktree add /tmp/previous HEAD~1
+		%s summary --dir config-root --compare-dir /tmp/previous/config-root --output json
+	`)
+
+	// clusterScopedColumn the column of the resources without a namespace
+	clusterScopedColumn = "-"
+)
+
+// Options the options for the command
+type Options struct {
+	output.Options
+	Dir         string
+	CompareDir  string
+	ByNamespace bool
+	Out         io.Writer
+	Result      *Result
+}
+
+// Result the summary of the resources
+type Result struct {
+	// Total the total number of resources
+	Total int `json:"total"`
+
+	// Kinds the number of resources of each kind sorted by kind
+	Kinds []KindCount `json:"kinds"`
+
+	// Changes the changes to each kind compared to the --compare-dir sorted by kind
+	Changes []KindChange `json:"changes,omitempty"`
+}
+
+// KindCount the number of resources of a kind
+type KindCount struct {
+	// Kind the kind of the resources
+	Kind string `json:"kind"`
+
+	// Count the number of resources
+	Count int `json:"count"`
+
+	// Namespaces the number of resources in each namespace if --by-namespace is specified. Cluster scoped
+	// resources have a blank namespace
+	Namespaces map[string]int `json:"namespaces,omitempty"`
+}
+
+// KindChange the changes to the resources of a kind compared to another directory tree
+type KindChange struct {
+	// Kind the kind of the resources
+	Kind string `json:"kind"`
+
+	// Before the number of resources in the --compare-dir
+	Before int `json:"before"`
+
+	// After the number of resources in the --dir
+	After int `json:"after"`
+
+	// Added the number of resources which are not in the --compare-dir
+	Added int `json:"added"`
+
+	// Removed the number of resources which are only in the --compare-dir
+	Removed int `json:"removed"`
+
+	// Changed the number of resources which are in both directory trees but are different
+	Changed int `json:"changed"`
+}
+
+// NewCmdSummary creates a command object for the command
+func NewCmdSummary() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "summary",
+		Short:   "Summarises the kubernetes resources in the given directory tree by counting them by kind",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.CompareDir, "compare-dir", "", "", "the directory tree to compare the resources with such as a checkout of the previous commit")
+	cmd.Flags().BoolVarP(&o.ByNamespace, "by-namespace", "", false, "shows the number of resources of each kind in each namespace")
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	after, err := loadResources(o.Dir)
+	if err != nil {
+		return err
+	}
+	o.Result = o.summarise(after)
+	if o.CompareDir != "" {
+		before, err := loadResources(o.CompareDir)
+		if err != nil {
+			return err
+		}
+		o.Result.Changes = compare(before, after)
+	}
+	err = o.render()
+	if err != nil {
+		return errors.Wrap(err, "failed to output the summary")
+	}
+	return nil
+}
+
+// loadResources loads the resources in the directory tree including the items of any Lists
+func loadResources(dir string) ([]*unstructured.Unstructured, error) {
+	var answer []*unstructured.Unstructured
+	err := resourcehelpers.VisitResources(dir, resourcehelpers.Selector{}, func(u *unstructured.Unstructured, path string) error {
+		answer = append(answer, u)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the resources in dir %s", dir)
+	}
+	return answer, nil
+}
+
+func (o *Options) summarise(resources []*unstructured.Unstructured) *Result {
+	counts := map[string]*KindCount{}
+	for _, u := range resources {
+		kind := u.GetKind()
+		kc := counts[kind]
+		if kc == nil {
+			kc = &KindCount{Kind: kind}
+			if o.ByNamespace {
+				kc.Namespaces = map[string]int{}
+			}
+			counts[kind] = kc
+		}
+		kc.Count++
+		if o.ByNamespace {
+			kc.Namespaces[u.GetNamespace()]++
+		}
+	}
+	answer := &Result{Total: len(resources), Kinds: []KindCount{}}
+	for _, kc := range counts {
+		answer.Kinds = append(answer.Kinds, *kc)
+	}
+	sort.Slice(answer.Kinds, func(i, j int) bool {
+		return answer.Kinds[i].Kind < answer.Kinds[j].Kind
+	})
+	return answer
+}
+
+// compare returns the changes to each kind of resource between the directory trees
+func compare(before, after []*unstructured.Unstructured) []KindChange {
+	changes := map[string]*KindChange{}
+	change := func(kind string) *KindChange {
+		kc := changes[kind]
+		if kc == nil {
+			kc = &KindChange{Kind: kind}
+			changes[kind] = kc
+		}
+		return kc
+	}
+	beforeKeys := map[string]*unstructured.Unstructured{}
+	for _, u := range before {
+		change(u.GetKind()).Before++
+		beforeKeys[resourcehelpers.ResourceKey(u)] = u
+	}
+	afterKeys := map[string]*unstructured.Unstructured{}
+	for _, u := range after {
+		change(u.GetKind()).After++
+		afterKeys[resourcehelpers.ResourceKey(u)] = u
+	}
+	for key, u := range afterKeys {
+		previous, ok := beforeKeys[key]
+		switch {
+		case !ok:
+			change(u.GetKind()).Added++
+		case !reflect.DeepEqual(previous.Object, u.Object):
+			change(u.GetKind()).Changed++
+		}
+	}
+	for key, u := range beforeKeys {
+		if _, ok := afterKeys[key]; !ok {
+			change(u.GetKind()).Removed++
+		}
+	}
+
+	var answer []KindChange
+	for _, kc := range changes {
+		answer = append(answer, *kc)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Kind < answer[j].Kind
+	})
+	return answer
+}
+
+func (o *Options) render() error {
+	r := o.Result
+	if o.Format == output.FormatJSON || o.Format == output.FormatYAML {
+		return output.NewRenderer(o.Out, o.Options).Render(r)
+	}
+
+	if o.CompareDir != "" {
+		renderer := output.NewRenderer(o.Out, o.Options,
+			output.Column{Header: "KIND", Field: "Kind"},
+			output.Column{Header: "BEFORE", Field: "Before"},
+			output.Column{Header: "AFTER", Field: "After"},
+			output.Column{Header: "ADDED", Field: "Added"},
+			output.Column{Header: "REMOVED", Field: "Removed"},
+			output.Column{Header: "CHANGED", Field: "Changed"},
+		)
+		return renderer.Render(r.Changes)
+	}
+	if !o.ByNamespace {
+		renderer := output.NewRenderer(o.Out, o.Options,
+			output.Column{Header: "KIND", Field: "Kind"},
+			output.Column{Header: "COUNT", Field: "Count"},
+		)
+		return renderer.Render(append(append([]KindCount{}, r.Kinds...), KindCount{Kind: "TOTAL", Count: r.Total}))
+	}
+
+	var namespaces []string
+	for _, kc := range r.Kinds {
+		for ns := range kc.Namespaces {
+			if stringhelpers.StringArrayIndex(namespaces, ns) < 0 {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	columns := []output.Column{{Header: "KIND", Field: "Kind"}}
+	for _, ns := range namespaces {
+		header := ns
+		if header == "" {
+			header = clusterScopedColumn
+		}
+		namespace := ns
+		columns = append(columns, output.Column{Header: header, Value: func(item interface{}) string {
+			return fmt.Sprintf("%d", item.(KindCount).Namespaces[namespace])
+		}})
+	}
+	columns = append(columns, output.Column{Header: "TOTAL", Field: "Count"})
+	return output.NewRenderer(o.Out, o.Options, columns...).Render(r.Kinds)
+}
@@ -0,0 +1,82 @@
+package summary_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := summary.NewCmdSummary()
+	o.Dir = filepath.Join("test_data", "after")
+	o.Out = &buf
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	assert.Equal(t, 7, o.Result.Total, "total")
+	assert.Equal(t, []summary.KindCount{
+		{Kind: "Deployment", Count: 2},
+		{Kind: "Namespace", Count: 2},
+		{Kind: "Secret", Count: 1},
+		{Kind: "Service", Count: 2},
+	}, o.Result.Kinds, "kinds")
+
+	expected := `KIND        COUNT
+Deployment  2
+Namespace   2
+Secret      1
+Service     2
+TOTAL       7
+`
+	assert.Equal(t, expected, buf.String(), "output")
+}
+
+func TestSummaryByNamespace(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := summary.NewCmdSummary()
+	o.Dir = filepath.Join("test_data", "after")
+	o.ByNamespace = true
+	o.Out = &buf
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	expected := `KIND        -  billing  shop  TOTAL
+Deployment  0  1        1     2
+Namespace   2  0        0     2
+Secret      0  1        0     1
+Service     0  1        1     2
+`
+	assert.Equal(t, expected, buf.String(), "output")
+}
+
+func TestSummaryCompare(t *testing.T) {
+	var buf bytes.Buffer
+	_, o := summary.NewCmdSummary()
+	o.Dir = filepath.Join("test_data", "after")
+	o.CompareDir = filepath.Join("test_data", "before")
+	o.Format = "json"
+	o.Out = &buf
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	result := &summary.Result{}
+	err = json.Unmarshal(buf.Bytes(), result)
+	require.NoError(t, err, "failed to parse the JSON %s", buf.String())
+
+	assert.Equal(t, 7, result.Total, "total")
+	assert.Equal(t, []summary.KindChange{
+		{Kind: "Deployment", Before: 1, After: 2, Added: 1, Changed: 1},
+		{Kind: "Namespace", Before: 1, After: 2, Added: 1},
+		{Kind: "Secret", Before: 1, After: 1, Added: 1, Removed: 1},
+		{Kind: "Service", Before: 1, After: 2, Added: 1},
+	}, result.Changes, "changes")
+}
@@ -0,0 +1,232 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/kubeschemas"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Validates the kubernetes resources in the given directory tree against the OpenAPI schemas of a kubernetes version
+
+		Unknown fields, values of the wrong type and missing required fields are reported with the file and document index of the resource. Kinds which are not in the schemas, such as custom resources, are reported as warnings unless --strict is specified.
+
+		Custom resources are validated against the schema of the matching version of any CustomResourceDefinition found in the --crd-dir which defaults to the --dir so that vendored CRDs are used.
+
+		The schemas never use the network: the schemas of kubernetes %s are bundled in the binary and the schemas of other versions are loaded from the OpenAPI file <schema-dir>/<version>/swagger.json such as ~/.jx-gitops/schemas/v1.19/swagger.json
+`)
+
+	cmdExample = templates.Examples(`
+		# validates the resources in the current directory
+		%s validate
+
+		# validates the resources against the cached schemas of another kubernetes version failing on custom resources
+		%s validate --dir config-root --kubernetes-version 1.19 --strict
+
+		# displays the findings as JSON
+		%s validate --output json
+
+		# validates the custom resources against the CustomResourceDefinitions in another directory
+		%s validate --dir config-root/namespaces --crd-dir config-root/customresourcedefinitions
+	`)
+)
+
+const (
+	// SeverityError a problem which fails the validation
+	SeverityError = "error"
+
+	// SeverityWarning a problem which only fails the validation with --strict
+	SeverityWarning = "warning"
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir               string
+	CRDDir            string
+	KubernetesVersion string
+	SchemaDir         string
+	Strict            bool
+	Out               io.Writer
+	Findings          []Finding
+	CRDs              int
+}
+
+// Finding a problem found in a resource
+type Finding struct {
+	// File the file containing the resource relative to the directory
+	File string `json:"file"`
+
+	// Document the index of the document of the resource in the file
+	Document int `json:"document"`
+
+	// Resource the key of the resource
+	Resource string `json:"resource"`
+
+	// Field the path of the field if the problem is in a field
+	Field string `json:"field,omitempty"`
+
+	// Type the type of the problem
+	Type string `json:"type"`
+
+	// Severity either error or warning
+	Severity string `json:"severity"`
+
+	// Message the description of the problem
+	Message string `json:"message"`
+}
+
+// NewCmdValidate creates a command object for the command
+func NewCmdValidate() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Short:   "Validates the kubernetes resources in the given directory tree against the OpenAPI schemas of a kubernetes version",
+		Long:    fmt.Sprintf(cmdLong, kubeschemas.BundledVersion),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.CRDDir, "crd-dir", "", "", "the directory to recursively look for the CustomResourceDefinitions used to validate custom resources. Defaults to the --dir")
+	cmd.Flags().StringVarP(&o.KubernetesVersion, "kubernetes-version", "", kubeschemas.BundledVersion, "the kubernetes version of the schemas to validate against")
+	cmd.Flags().StringVarP(&o.SchemaDir, "schema-dir", "", kubeschemas.DefaultSchemaDir(), "the directory containing the OpenAPI file of each kubernetes version which is not bundled")
+	cmd.Flags().BoolVarP(&o.Strict, "strict", "", false, "fails on kinds which are not in the schemas such as custom resources")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	schemas, err := kubeschemas.Load(o.KubernetesVersion, o.SchemaDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the schemas")
+	}
+	err = o.loadCRDs(schemas)
+	if err != nil {
+		return err
+	}
+
+	o.Findings = nil
+	visitFn := func(u *unstructured.Unstructured, path string, index int) error {
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		for _, p := range schemas.Validate(u.Object) {
+			severity := SeverityError
+			if p.Type == kubeschemas.ProblemUnknownKind && !o.Strict {
+				severity = SeverityWarning
+			}
+			o.Findings = append(o.Findings, Finding{
+				File:     filepath.ToSlash(rel),
+				Document: index,
+				Resource: resourcehelpers.ResourceKey(u),
+				Field:    p.Field,
+				Type:     p.Type,
+				Severity: severity,
+				Message:  p.Message,
+			})
+		}
+		return nil
+	}
+	err = resourcehelpers.VisitDocuments(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate resources in dir %s", o.Dir)
+	}
+
+	err = o.render()
+	if err != nil {
+		return err
+	}
+	errorCount := 0
+	for _, f := range o.Findings {
+		if f.Severity == SeverityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return errors.Errorf("found %d errors in %d findings validating the resources in dir %s against kubernetes %s", errorCount, len(o.Findings), o.Dir, schemas.Version)
+	}
+	log.Logger().Infof("validated the resources in dir %s against kubernetes %s with %d warnings", termcolor.ColorInfo(o.Dir), termcolor.ColorInfo(schemas.Version), len(o.Findings))
+	return nil
+}
+
+// loadCRDs adds the schemas of the CustomResourceDefinitions in the CRD directory
+func (o *Options) loadCRDs(schemas *kubeschemas.Schemas) error {
+	dir := o.CRDDir
+	if dir == "" {
+		dir = o.Dir
+	}
+	o.CRDs = 0
+	visitFn := func(u *unstructured.Unstructured, path string, index int) error {
+		if !kubeschemas.IsCRD(u.Object) {
+			return nil
+		}
+		added, err := schemas.AddCRD(u.Object)
+		if err != nil {
+			return errors.Wrapf(err, "invalid CustomResourceDefinition")
+		}
+		if !added {
+			log.Logger().Debugf("ignoring duplicate CustomResourceDefinition %s in file %s", u.GetName(), path)
+			return nil
+		}
+		o.CRDs++
+		return nil
+	}
+	err := resourcehelpers.VisitDocuments(dir, resourcehelpers.Selector{}, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the CustomResourceDefinitions in dir %s", dir)
+	}
+	if o.CRDs > 0 {
+		log.Logger().Infof("loaded the schemas of %d CustomResourceDefinitions in dir %s", o.CRDs, termcolor.ColorInfo(dir))
+	}
+	return nil
+}
+
+func (o *Options) render() error {
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "SEVERITY", Field: "Severity"},
+		output.Column{Header: "FILE", Field: "File"},
+		output.Column{Header: "DOCUMENT", Field: "Document"},
+		output.Column{Header: "RESOURCE", Field: "Resource"},
+		output.Column{Header: "FIELD", Field: "Field"},
+		output.Column{Header: "MESSAGE", Field: "Message"},
+	)
+	err := renderer.Render(o.Findings)
+	if err != nil {
+		return errors.Wrap(err, "failed to render the findings")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package validate_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/validate"
+	"github.com/jenkins-x/jx-gitops/pkg/kubeschemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValid(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := validate.NewCmdValidate()
+	o.Dir = filepath.Join("test_data", "valid")
+	o.SchemaDir = ""
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to validate")
+	require.Len(t, o.Findings, 1, "findings")
+	assert.Equal(t, validate.SeverityWarning, o.Findings[0].Severity, "severity of the custom resource")
+	assert.Equal(t, kubeschemas.ProblemUnknownKind, o.Findings[0].Type, "type of the custom resource")
+	expected := "SEVERITY  FILE           DOCUMENT  RESOURCE             FIELD  MESSAGE\n" +
+		"warning   pipeline.yaml  0         jx/Pipeline/release         unknown kind Pipeline with apiVersion tekton.dev/v1beta1 in kubernetes v1.17\n"
+	assert.Equal(t, expected, out.String(), "table output")
+
+	o.Strict = true
+	err = o.Run()
+	require.Error(t, err, "should fail on custom resources with --strict")
+	t.Logf("got expected error: %s", err.Error())
+}
+
+func TestValidateInvalid(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := validate.NewCmdValidate()
+	o.Dir = filepath.Join("test_data", "invalid")
+	o.SchemaDir = ""
+	o.Format = "json"
+	o.Out = out
+	err := o.Run()
+	require.Error(t, err, "should fail to validate")
+	t.Logf("got expected error: %s", err.Error())
+
+	expected, err := ioutil.ReadFile(filepath.Join("test_data", "expected.json"))
+	require.NoError(t, err, "failed to load expected.json")
+	assert.JSONEq(t, string(expected), out.String(), "JSON output")
+}
+
+func TestValidateMissingVersion(t *testing.T) {
+	_, o := validate.NewCmdValidate()
+	o.Dir = filepath.Join("test_data", "valid")
+	o.SchemaDir = t.TempDir()
+	o.KubernetesVersion = "1.42"
+	err := o.Run()
+	require.Error(t, err, "should fail without cached schemas")
+	assert.Contains(t, err.Error(), filepath.Join(o.SchemaDir, "v1.42", "swagger.json"), "error message")
+}
+
+func TestValidateCRDs(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := validate.NewCmdValidate()
+	o.Dir = filepath.Join("test_data", "crds")
+	o.SchemaDir = ""
+	o.Format = "json"
+	o.Out = out
+	err := o.Run()
+	require.Error(t, err, "should fail to validate the invalid custom resources")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Equal(t, 1, o.CRDs, "loaded CRDs")
+
+	expected, err := ioutil.ReadFile(filepath.Join("test_data", "expected-crds.json"))
+	require.NoError(t, err, "failed to load expected-crds.json")
+	assert.JSONEq(t, string(expected), out.String(), "JSON output")
+
+	// lets check the custom resources are unknown kinds without the CRDs
+	_, o = validate.NewCmdValidate()
+	o.Dir = filepath.Join("test_data", "crds")
+	o.CRDDir = filepath.Join("test_data", "valid")
+	o.SchemaDir = ""
+	o.Out = &bytes.Buffer{}
+	err = o.Run()
+	require.NoError(t, err, "should only warn about custom resources without a CRD")
+	assert.Equal(t, 0, o.CRDs, "loaded CRDs")
+	for _, f := range o.Findings {
+		assert.Equal(t, validate.SeverityWarning, f.Severity, "severity of %s", f.Resource)
+	}
+}
@@ -1,33 +1,58 @@
 package version
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
-	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 )
 
-// Build information. Populated at build-time.
 var (
-	Version   string
-	Revision  string
-	Branch    string
-	BuildUser string
-	BuildDate string
-	GoVersion string
+	cmdLong = templates.LongDesc(`
+		Displays the version of this command along with the git SHA, build date and go version it was built with
+`)
+
+	cmdExample = templates.Examples(`
+		# displays the version details
+		%s version
+
+		# displays just the version
+		%s version --short
+
+		# displays the version details as JSON
+		%s version --output json
+	`)
+
+	unknown = "unknown"
 )
 
 const (
-
 	// TestVersion used in test cases for the current version if no
 	// version can be found - such as if the version property is not properly
 	// included in the go test flags
-	TestVersion = "1.0.0-SNAPSHOT"
+	TestVersion = common.TestVersion
+
+	// OutputJSON outputs the version details as JSON
+	OutputJSON = "json"
+
+	// OutputYAML outputs the version details as YAML
+	OutputYAML = "yaml"
 )
 
-// ShowOptions the options for viewing running PRs
+// Options the options for the command
 type Options struct {
-	Verbose bool
+	Output string
+	Short  bool
+	Out    io.Writer
 }
 
 // NewCmdVersion creates a command object for the "version" command
@@ -35,26 +60,79 @@ func NewCmdVersion() (*cobra.Command, *Options) {
 	o := &Options{}
 
 	cmd := &cobra.Command{
-		Use:   "version",
-		Short: "Displays the version of this command",
+		Use:     "version",
+		Short:   "Displays the version of this command",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format of the version details. Supported values: json, yaml")
+	cmd.Flags().BoolVarP(&o.Short, "short", "", false, "only displays the version")
 	return cmd, o
 }
 
 // Run implements the command
 func (o *Options) Run() error {
-	v := GetVersion()
-	log.Logger().Infof("version: %s", termcolor.ColorInfo(v))
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	info := common.GetVersionInfo()
+	if o.Short {
+		if o.Output != "" {
+			return errors.Errorf("the --short and --output flags are mutually exclusive")
+		}
+		_, err := fmt.Fprintln(o.Out, info.Version)
+		return err
+	}
+
+	var data []byte
+	var err error
+	switch o.Output {
+	case "":
+		return o.printText(info)
+	case OutputJSON:
+		data, err = json.MarshalIndent(info, "", "  ")
+		data = append(data, '\n')
+	case OutputYAML:
+		data, err = yaml.Marshal(info)
+	default:
+		return errors.Errorf("unsupported output format '%s'. Supported values: %s, %s", o.Output, OutputJSON, OutputYAML)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the version details as %s", o.Output)
+	}
+	_, err = o.Out.Write(data)
+	return err
+}
+
+func (o *Options) printText(info common.VersionInfo) error {
+	rows := []struct {
+		name  string
+		value string
+	}{
+		{"version", info.Version},
+		{"git SHA", info.Revision},
+		{"branch", info.Branch},
+		{"build date", info.BuildDate},
+		{"go version", info.GoVersion},
+	}
+	for _, r := range rows {
+		value := r.value
+		if value == "" {
+			value = unknown
+		}
+		_, err := fmt.Fprintf(o.Out, "%s: %s\n", r.name, termcolor.ColorInfo(value))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// GetVersion returns the version of the binary
 func GetVersion() string {
-	if Version != "" {
-		return Version
-	}
-	return TestVersion
+	return common.Version()
 }
@@ -0,0 +1,97 @@
+package version_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/version"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestVersionDevBuild(t *testing.T) {
+	setBuildInfo(t, "", "", "", "", "")
+
+	goVersion := strings.TrimPrefix(runtime.Version(), "go")
+	assert.Equal(t, version.TestVersion, common.Version(), "common.Version()")
+	assert.Equal(t, version.TestVersion, version.GetVersion(), "version.GetVersion()")
+
+	text := runVersion(t, "", false)
+	expected := "version: " + termcolor.ColorInfo(version.TestVersion) + "\n" +
+		"git SHA: " + termcolor.ColorInfo("unknown") + "\n" +
+		"branch: " + termcolor.ColorInfo("unknown") + "\n" +
+		"build date: " + termcolor.ColorInfo("unknown") + "\n" +
+		"go version: " + termcolor.ColorInfo(goVersion) + "\n"
+	assert.Equal(t, expected, text, "text output")
+
+	text = runVersion(t, version.OutputJSON, false)
+	info := common.VersionInfo{}
+	err := json.Unmarshal([]byte(text), &info)
+	require.NoError(t, err, "failed to parse JSON %s", text)
+	assert.Equal(t, common.VersionInfo{Version: version.TestVersion, GoVersion: goVersion}, info, "JSON output")
+
+	assert.Equal(t, version.TestVersion+"\n", runVersion(t, "", true), "short output")
+}
+
+func TestVersionRelease(t *testing.T) {
+	setBuildInfo(t, "1.2.3", "abc123", "main", "2020-11-20T10:00:00Z", "1.15.2")
+
+	expected := common.VersionInfo{
+		Version:   "1.2.3",
+		Revision:  "abc123",
+		Branch:    "main",
+		BuildDate: "2020-11-20T10:00:00Z",
+		GoVersion: "1.15.2",
+	}
+
+	text := runVersion(t, version.OutputJSON, false)
+	info := common.VersionInfo{}
+	err := json.Unmarshal([]byte(text), &info)
+	require.NoError(t, err, "failed to parse JSON %s", text)
+	assert.Equal(t, expected, info, "JSON output")
+
+	text = runVersion(t, version.OutputYAML, false)
+	info = common.VersionInfo{}
+	err = yaml.Unmarshal([]byte(text), &info)
+	require.NoError(t, err, "failed to parse YAML %s", text)
+	assert.Equal(t, expected, info, "YAML output")
+
+	text = runVersion(t, "", false)
+	assert.Contains(t, text, "git SHA: "+termcolor.ColorInfo("abc123")+"\n", "text output")
+
+	assert.Equal(t, "1.2.3\n", runVersion(t, "", true), "short output")
+}
+
+func TestVersionInvalidOutput(t *testing.T) {
+	_, o := version.NewCmdVersion()
+	o.Out = &bytes.Buffer{}
+	o.Output = "xml"
+	err := o.Run()
+	require.Error(t, err, "should fail for an unsupported output format")
+	assert.Contains(t, err.Error(), "unsupported output format 'xml'")
+}
+
+func runVersion(t *testing.T, output string, short bool) string {
+	buf := &bytes.Buffer{}
+	_, o := version.NewCmdVersion()
+	o.Out = buf
+	o.Output = output
+	o.Short = short
+	err := o.Run()
+	require.NoError(t, err, "failed to run version command")
+	return buf.String()
+}
+
+func setBuildInfo(t *testing.T, v, revision, branch, buildDate, goVersion string) {
+	oldVersion, oldRevision, oldBranch, oldBuildDate, oldGoVersion := common.BuildVersion, common.Revision, common.Branch, common.BuildDate, common.GoVersion
+	t.Cleanup(func() {
+		common.BuildVersion, common.Revision, common.Branch, common.BuildDate, common.GoVersion = oldVersion, oldRevision, oldBranch, oldBuildDate, oldGoVersion
+	})
+	common.BuildVersion, common.Revision, common.Branch, common.BuildDate, common.GoVersion = v, revision, branch, buildDate, goVersion
+}
@@ -0,0 +1,167 @@
+package yget
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/getfield"
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/jenkins-x/jx-gitops/pkg/fieldpaths"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Displays the value of a nested field of the kubernetes resources in the given directory tree which match the selector
+
+		The path uses the same syntax as the yset command so it can include list indexes such as 'containers[0]' or match the element of a list by a field such as 'containers[name=foo]'. The values are displayed in a table with the file, document index and key of each resource or as JSON or YAML with --output json or --output yaml. Complex values are displayed as JSON in tables.
+
+		Fails if no resources have the field unless --allow-missing is specified or if more than one resource has the field and --unique is specified.
+`)
+
+	cmdExample = templates.Examples(`
+		# displays the image of a deployment
+		%s yget --kind Deployment --name myapp --path 'spec.template.spec.containers[0].image'
+
+		# displays the replicas of all the deployments as JSON
+		%s yget --dir config-root --kind Deployment --path spec.replicas --output json
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	output.Options
+	Dir          string
+	Path         string
+	AllowMissing bool
+	Unique       bool
+	Out          io.Writer
+	Results      []Result
+}
+
+// Result the value of the field of a resource
+type Result struct {
+	// File the file containing the resource relative to the directory
+	File string `json:"file"`
+
+	// Document the index of the document of the resource in the file
+	Document int `json:"document"`
+
+	// Resource the key of the resource
+	Resource string `json:"resource"`
+
+	// Value the value of the field
+	Value interface{} `json:"value"`
+}
+
+// NewCmdYGet creates a command object for the command
+func NewCmdYGet() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "yget",
+		Short:   "Displays the value of a nested field of the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the dot separated path of the field to display such as 'spec.replicas' or 'spec.template.spec.containers[name=foo].image'")
+	cmd.Flags().BoolVarP(&o.AllowMissing, "allow-missing", "", false, "does not fail if no resources have the field")
+	cmd.Flags().BoolVarP(&o.Unique, "unique", "", false, "fails if more than one resource has the field")
+	o.Selector.AddFlags(cmd)
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Path == "" {
+		return options.MissingOption("path")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return o.Options.Validate()
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	steps, err := fieldpaths.ParsePath(o.Path)
+	if err != nil {
+		return options.InvalidOptionf("path", o.Path, err.Error())
+	}
+
+	o.Results = nil
+	visitFn := func(u *unstructured.Unstructured, path string, index int) error {
+		value, found, err := fieldpaths.Get(u.Object, steps)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s", o.Path)
+		}
+		if !found {
+			return nil
+		}
+		rel, err := filepath.Rel(o.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		o.Results = append(o.Results, Result{
+			File:     filepath.ToSlash(rel),
+			Document: index,
+			Resource: resourcehelpers.ResourceKey(u),
+			Value:    value,
+		})
+		return nil
+	}
+	err = resourcehelpers.VisitDocuments(o.Dir, o.Selector, visitFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s in dir %s", o.Path, o.Dir)
+	}
+
+	if len(o.Results) == 0 && !o.AllowMissing {
+		return errors.Errorf("no resources have the field %s in dir %s", o.Path, o.Dir)
+	}
+	if len(o.Results) > 1 && o.Unique {
+		var keys []string
+		for _, r := range o.Results {
+			keys = append(keys, r.Resource)
+		}
+		return errors.Errorf("expected one resource to have the field %s but found %d: %s", o.Path, len(o.Results), strings.Join(keys, ", "))
+	}
+
+	renderer := output.NewRenderer(o.Out, o.Options,
+		output.Column{Header: "FILE", Field: "File"},
+		output.Column{Header: "DOCUMENT", Field: "Document"},
+		output.Column{Header: "RESOURCE", Field: "Resource"},
+		output.Column{Header: "VALUE", Value: func(item interface{}) string {
+			text, err := getfield.ToText(item.(Result).Value)
+			if err != nil {
+				return fmt.Sprint(item.(Result).Value)
+			}
+			return text
+		}},
+	)
+	err = renderer.Render(o.Results)
+	if err != nil {
+		return errors.Wrap(err, "failed to render the results")
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package yget_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/yget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYGet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		kinds    []string
+		names    []string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "list index",
+			kinds:    []string{"Deployment"},
+			names:    []string{"myapp"},
+			path:     "spec.template.spec.containers[0].image",
+			expected: []string{"myapp:1.0.0"},
+		},
+		{
+			name:     "list match",
+			kinds:    []string{"Deployment"},
+			path:     "spec.template.spec.containers[name=sidecar].image",
+			expected: []string{"sidecar:1.0.0"},
+		},
+		{
+			name:     "multiple matches",
+			kinds:    []string{"Deployment"},
+			path:     "spec.replicas",
+			expected: []string{"1", "1"},
+		},
+		{
+			name:     "complex value",
+			names:    []string{"myapp"},
+			path:     "spec.selector",
+			expected: []string{`{"matchLabels":{"app":"myapp"}}`},
+		},
+	}
+	for _, tc := range testCases {
+		out := &bytes.Buffer{}
+		_, o := yget.NewCmdYGet()
+		o.Dir = "test_data"
+		o.Kinds = tc.kinds
+		o.Names = tc.names
+		o.Path = tc.path
+		o.NoHeaders = true
+		o.Out = out
+		err := o.Run()
+		require.NoError(t, err, "failed to run yget for %s", tc.name)
+
+		var values []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			fields := strings.Fields(line)
+			values = append(values, fields[len(fields)-1])
+		}
+		assert.Equal(t, tc.expected, values, "values for %s", tc.name)
+	}
+}
+
+func TestYGetTable(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := yget.NewCmdYGet()
+	o.Dir = "test_data"
+	o.Kinds = []string{"Deployment"}
+	o.Names = []string{"myapp"}
+	o.Path = "spec.template.spec.containers[0].image"
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to run yget")
+
+	expected := "FILE        DOCUMENT  RESOURCE             VALUE\n" +
+		"myapp.yaml  0         jx/Deployment/myapp  myapp:1.0.0\n"
+	assert.Equal(t, expected, out.String(), "output")
+}
+
+func TestYGetJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := yget.NewCmdYGet()
+	o.Dir = "test_data"
+	o.Names = []string{"myapp"}
+	o.Path = "metadata.name"
+	o.Format = "json"
+	o.Out = out
+	err := o.Run()
+	require.NoError(t, err, "failed to run yget")
+
+	var results []yget.Result
+	err = json.Unmarshal(out.Bytes(), &results)
+	require.NoError(t, err, "failed to parse the JSON output %s", out.String())
+	expected := []yget.Result{
+		{File: "myapp.yaml", Document: 0, Resource: "jx/Deployment/myapp", Value: "myapp"},
+		{File: "myapp.yaml", Document: 1, Resource: "jx/Service/myapp", Value: "myapp"},
+	}
+	assert.Equal(t, expected, results, "results")
+}
+
+func TestYGetMissingAndUnique(t *testing.T) {
+	_, o := yget.NewCmdYGet()
+	o.Dir = "test_data"
+	o.Path = "spec.missing"
+	o.Out = &bytes.Buffer{}
+	err := o.Run()
+	require.Error(t, err, "should fail if no resources have the field")
+
+	o.AllowMissing = true
+	err = o.Run()
+	require.NoError(t, err, "should not fail with --allow-missing")
+	assert.Empty(t, o.Results, "results")
+
+	o.Path = "spec.replicas"
+	o.Unique = true
+	err = o.Run()
+	require.Error(t, err, "should fail as more than one resource has the field")
+	assert.Contains(t, err.Error(), "found 2", "error message")
+
+	o.Names = []string{"other"}
+	err = o.Run()
+	require.NoError(t, err, "should not fail with a unique match")
+	require.Len(t, o.Results, 1, "results")
+	assert.Equal(t, "other.yaml", o.Results[0].File, "file")
+}
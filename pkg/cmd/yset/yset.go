@@ -0,0 +1,142 @@
+package yset
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/resources/setfield"
+	"github.com/jenkins-x/jx-gitops/pkg/fieldpaths"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Sets the value of a nested field of the kubernetes resources in the given directory tree which match the selector
+
+		The path is dot separated and can include list indexes such as 'containers[0]' or match the element of a list by a field such as 'containers[name=foo]'. Any missing maps in the path are created. The value is converted to an int or bool if it looks like one unless --string is specified.
+`)
+
+	cmdExample = templates.Examples(`
+		# sets the replicas of a deployment
+		%s yset --path spec.replicas --value 3 --kind Deployment --name myapp
+
+		# sets the image of a container of a deployment
+		%s yset --path 'spec.template.spec.containers[name=myapp].image' --value myapp:1.2.3 --kind Deployment --name myapp
+
+		# sets an annotation to a string which looks like a bool failing if no resources match
+		%s yset --path 'metadata.annotations.fluxcd\.io/automated' --value true --string --expect-matches 1 --name myapp
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	resourcehelpers.Selector
+	Dir           string
+	Path          string
+	Value         string
+	String        bool
+	ExpectMatches int
+	Matched       int
+	Modified      int
+}
+
+// NewCmdYSet creates a command object for the command
+func NewCmdYSet() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "yset",
+		Short:   "Sets the value of a nested field of the kubernetes resources in the given directory tree which match the selector",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
+	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the dot separated path of the field to set such as 'spec.replicas' or 'spec.template.spec.containers[name=foo].image'")
+	cmd.Flags().StringVarP(&o.Value, "value", "", "", "the value to set the field to")
+	cmd.Flags().BoolVarP(&o.String, "string", "", false, "sets the value as a string rather than converting it to an int or bool")
+	cmd.Flags().IntVarP(&o.ExpectMatches, "expect-matches", "", -1, "if specified fails unless this number of resources match the selector")
+	o.Selector.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Path == "" {
+		return options.MissingOption("path")
+	}
+	steps, err := fieldpaths.ParsePath(o.Path)
+	if err != nil {
+		return options.InvalidOptionf("path", o.Path, err.Error())
+	}
+	value, err := setfield.ParseValue(o.Value, o.valueType())
+	if err != nil {
+		return err
+	}
+
+	// lets check the number of matches before modifying any files
+	if o.ExpectMatches >= 0 {
+		matched := 0
+		err = resourcehelpers.VisitFiles(o.Dir, o.Selector, func(u *unstructured.Unstructured, path string) error {
+			matched++
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the resources in dir %s", o.Dir)
+		}
+		if matched != o.ExpectMatches {
+			return errors.Errorf("expected %d resources to match but %d matched", o.ExpectMatches, matched)
+		}
+	}
+
+	o.Matched = 0
+	o.Modified = 0
+	modifyFn := func(u *unstructured.Unstructured, path string) (bool, error) {
+		o.Matched++
+		original := u.DeepCopy()
+		err := fieldpaths.Set(u.Object, steps, value)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set %s on %s in file %s", o.Path, resourcehelpers.ResourceKey(u), path)
+		}
+		if reflect.DeepEqual(original.Object, u.Object) {
+			return false, nil
+		}
+		log.Logger().Infof("set %s to %s on %s %s in file %s", o.Path, termcolor.ColorInfo(o.Value), u.GetKind(), termcolor.ColorInfo(u.GetName()), path)
+		o.Modified++
+		return true, nil
+	}
+
+	err = resourcehelpers.ModifyFiles(o.Dir, o.Selector, modifyFn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set %s in dir %s", o.Path, o.Dir)
+	}
+	log.Logger().Infof("matched %d resources and modified %d resources", o.Matched, o.Modified)
+	return nil
+}
+
+// valueType returns the type of the value inferring an int or bool unless the value should be a string
+func (o *Options) valueType() string {
+	if o.String {
+		return "string"
+	}
+	if _, err := strconv.ParseInt(o.Value, 10, 64); err == nil {
+		return "int"
+	}
+	if o.Value == "true" || o.Value == "false" {
+		return "bool"
+	}
+	return "string"
+}
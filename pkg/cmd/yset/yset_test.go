@@ -0,0 +1,159 @@
+package yset_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/cmd/yset"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestYSet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		value    string
+		str      bool
+		fields   []string
+		expected interface{}
+	}{
+		{
+			name:     "int",
+			path:     "spec.replicas",
+			value:    "3",
+			fields:   []string{"spec", "replicas"},
+			expected: int64(3),
+		},
+		{
+			name:     "bool",
+			path:     "spec.paused",
+			value:    "true",
+			fields:   []string{"spec", "paused"},
+			expected: true,
+		},
+		{
+			name:     "string override",
+			path:     `spec.template.metadata.annotations.fluxcd\.io/automated`,
+			value:    "true",
+			str:      true,
+			fields:   []string{"spec", "template", "metadata", "annotations", "fluxcd.io/automated"},
+			expected: "true",
+		},
+		{
+			name:     "missing intermediate maps",
+			path:     "spec.strategy.rollingUpdate.maxSurge",
+			value:    "25%",
+			fields:   []string{"spec", "strategy", "rollingUpdate", "maxSurge"},
+			expected: "25%",
+		},
+	}
+	for _, tc := range testCases {
+		tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+		_, o := yset.NewCmdYSet()
+		o.Dir = tmpDir
+		o.Kinds = []string{"Deployment"}
+		o.Names = []string{"myapp"}
+		o.Path = tc.path
+		o.Value = tc.value
+		o.String = tc.str
+		o.ExpectMatches = 1
+		err := o.Run()
+		require.NoError(t, err, "failed to run yset for %s", tc.name)
+		assert.Equal(t, 1, o.Matched, "matched for %s", tc.name)
+		assert.Equal(t, 1, o.Modified, "modified for %s", tc.name)
+
+		resources := loadFile(t, filepath.Join(tmpDir, "myapp.yaml"))
+		require.Len(t, resources, 2, "should have preserved the Service document for %s", tc.name)
+		value, found, err := unstructured.NestedFieldNoCopy(resources[0].Object, tc.fields...)
+		require.NoError(t, err, "failed to get field for %s", tc.name)
+		assert.True(t, found, "should have found the field for %s", tc.name)
+		assert.Equal(t, tc.expected, value, "value for %s", tc.name)
+
+		// the other deployment should not be modified
+		others := loadFile(t, filepath.Join(tmpDir, "other.yaml"))
+		_, found, _ = unstructured.NestedFieldNoCopy(others[0].Object, tc.fields...)
+		if tc.name == "int" {
+			replicas, _, _ := unstructured.NestedInt64(others[0].Object, "spec", "replicas")
+			assert.Equal(t, int64(1), replicas, "replicas of the other deployment")
+		} else {
+			assert.False(t, found, "the other deployment should not be modified for %s", tc.name)
+		}
+
+		// running again should not modify anything
+		err = o.Run()
+		require.NoError(t, err, "failed to run yset again for %s", tc.name)
+		assert.Equal(t, 0, o.Modified, "modified on the second run for %s", tc.name)
+	}
+}
+
+func TestYSetListIndexes(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := yset.NewCmdYSet()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.Path = "spec.template.spec.containers[0].imagePullPolicy"
+	o.Value = "Always"
+	err := o.Run()
+	require.NoError(t, err, "failed to set the first container")
+	assert.Equal(t, 2, o.Matched, "matched")
+	assert.Equal(t, 2, o.Modified, "modified")
+
+	_, o = yset.NewCmdYSet()
+	o.Dir = tmpDir
+	o.Names = []string{"myapp"}
+	o.Kinds = []string{"Deployment"}
+	o.Path = "spec.template.spec.containers[name=sidecar].image"
+	o.Value = "sidecar:2.0.0"
+	err = o.Run()
+	require.NoError(t, err, "failed to set the sidecar container")
+
+	resources := loadFile(t, filepath.Join(tmpDir, "myapp.yaml"))
+	containers, _, err := unstructured.NestedSlice(resources[0].Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err, "failed to get containers")
+	require.Len(t, containers, 2, "containers")
+	assert.Equal(t, map[string]interface{}{"name": "myapp", "image": "myapp:1.0.0", "imagePullPolicy": "Always"}, containers[0], "first container")
+	assert.Equal(t, map[string]interface{}{"name": "sidecar", "image": "sidecar:2.0.0"}, containers[1], "sidecar container")
+
+	o.Path = "spec.template.spec.containers[name=missing].image"
+	err = o.Run()
+	require.Error(t, err, "should fail for a missing list element")
+	assert.Contains(t, err.Error(), "no element of spec.template.spec.containers has name=missing", "error message")
+
+	o.Path = "spec.template.spec.containers[5].image"
+	err = o.Run()
+	require.Error(t, err, "should fail for an index out of range")
+
+	o.Path = "spec.selector.matchLabels[0]"
+	err = o.Run()
+	require.Error(t, err, "should fail for an index of a map")
+}
+
+func TestYSetExpectMatches(t *testing.T) {
+	tmpDir := testhelpers.CopyTestData(t, "test_data")
+
+	_, o := yset.NewCmdYSet()
+	o.Dir = tmpDir
+	o.Kinds = []string{"Deployment"}
+	o.Path = "spec.replicas"
+	o.Value = "5"
+	o.ExpectMatches = 1
+	err := o.Run()
+	require.Error(t, err, "should fail as 2 deployments match")
+	assert.Contains(t, err.Error(), "expected 1 resources to match but 2 matched", "error message")
+
+	resources := loadFile(t, filepath.Join(tmpDir, "other.yaml"))
+	replicas, _, _ := unstructured.NestedInt64(resources[0].Object, "spec", "replicas")
+	assert.Equal(t, int64(1), replicas, "no resources should be modified")
+}
+
+func loadFile(t *testing.T, path string) []*unstructured.Unstructured {
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	return resources
+}
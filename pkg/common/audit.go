@@ -0,0 +1,179 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// AuditFileEnvVar the environment variable used to enable the audit log if the --audit-file flag is not specified
+const AuditFileEnvVar = "JX_GITOPS_AUDIT_FILE"
+
+var (
+	// AuditFile the file the audit log is appended to. Populated by the --audit-file flag
+	AuditFile string
+
+	defaultAuditLock sync.Mutex
+	defaultAuditLog  *AuditLog
+)
+
+// AuditEntry the audit record of a command execution
+type AuditEntry struct {
+	// Timestamp when the command started
+	Timestamp time.Time `json:"timestamp"`
+
+	// Subcommand the name of the invoking subcommand such as 'kpt recreate'
+	Subcommand string `json:"subcommand,omitempty"`
+
+	// Binary the name of the binary executed
+	Binary string `json:"binary"`
+
+	// Args the arguments with any secret values masked
+	Args []string `json:"args"`
+
+	// Dir the working directory of the command
+	Dir string `json:"dir,omitempty"`
+
+	// DurationMillis how long the command took in milliseconds
+	DurationMillis int64 `json:"durationMs"`
+
+	// ExitCode the exit code of the command or -1 if it did not exit normally
+	ExitCode int `json:"exitCode"`
+}
+
+// AuditLog appends a JSON line for each command execution to a file. It is safe for concurrent use
+type AuditLog struct {
+	// Path the path of the audit file
+	Path string
+
+	// Subcommand the name of the invoking subcommand recorded in each entry
+	Subcommand string
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens the audit file for appending creating it if it does not exist
+func OpenAuditLog(path, subcommand string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open audit file %s", path)
+	}
+	return &AuditLog{
+		Path:       path,
+		Subcommand: subcommand,
+		file:       f,
+	}, nil
+}
+
+// Write appends the entry to the audit file as a single JSON line
+func (a *AuditLog) Write(entry *AuditEntry) error {
+	if entry.Subcommand == "" {
+		entry.Subcommand = a.Subcommand
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit entry")
+	}
+	data = append(data, '\n')
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	_, err = a.file.Write(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write to audit file %s", a.Path)
+	}
+	return nil
+}
+
+// Close closes the audit file
+func (a *AuditLog) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.file.Close()
+}
+
+// NewResultRunner creates a runner which records an entry for each command run by the next runner. The mask
+// function is used to redact the secret values in the arguments. If the next runner is nil then RunCommandResult is used
+func (a *AuditLog) NewResultRunner(next ResultCommandRunner, mask func(c *cmdrunner.Command, text string) string) ResultCommandRunner {
+	if next == nil {
+		next = RunCommandResult
+	}
+	return func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+		start := time.Now()
+		result, err := next(ctx, c)
+
+		entry := &AuditEntry{
+			Timestamp: start.UTC(),
+			Binary:    c.Name,
+			Args:      []string{},
+			Dir:       c.Dir,
+			ExitCode:  ExitCode(err),
+		}
+		for _, arg := range c.Args {
+			if mask != nil {
+				arg = mask(c, arg)
+			}
+			entry.Args = append(entry.Args, arg)
+		}
+		if result != nil {
+			entry.ExitCode = result.ExitCode
+			entry.DurationMillis = result.Duration.Milliseconds()
+		} else {
+			entry.DurationMillis = time.Since(start).Milliseconds()
+		}
+		auditErr := a.Write(entry)
+		if err != nil {
+			return result, err
+		}
+		return result, auditErr
+	}
+}
+
+// AddAuditFlags adds the persistent --audit-file flag to the root command. The audit log should be opened
+// via OpenDefaultAuditLog before any subcommand runs so that every command run via a CommandEnvironment is recorded
+func AddAuditFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&AuditFile, "audit-file", "", "", "if specified a JSON line is appended to this file for every external command executed. Defaults to the $"+AuditFileEnvVar+" environment variable")
+}
+
+// OpenDefaultAuditLog opens the default audit log if the AuditFile or the $JX_GITOPS_AUDIT_FILE environment
+// variable is specified
+func OpenDefaultAuditLog(subcommand string) error {
+	path := AuditFile
+	if path == "" {
+		path = os.Getenv(AuditFileEnvVar)
+	}
+
+	defaultAuditLock.Lock()
+	defer defaultAuditLock.Unlock()
+
+	if defaultAuditLog != nil {
+		_ = defaultAuditLog.Close()
+		defaultAuditLog = nil
+	}
+	if path == "" {
+		return nil
+	}
+	a, err := OpenAuditLog(path, subcommand)
+	if err != nil {
+		return err
+	}
+	defaultAuditLog = a
+	return nil
+}
+
+// DefaultAuditLog returns the default audit log or nil if auditing is not enabled
+func DefaultAuditLog() *AuditLog {
+	defaultAuditLock.Lock()
+	defer defaultAuditLock.Unlock()
+
+	return defaultAuditLog
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// BatchModeFlag the name of the persistent flag enabling batch mode
+	BatchModeFlag = "batch-mode"
+
+	// CIEnvVar the environment variable set by most CI systems
+	CIEnvVar = "CI"
+)
+
+var (
+	// PromptIn the input prompts are read from. Can be replaced in tests
+	PromptIn io.Reader = os.Stdin
+
+	// PromptOut the output prompts are written to. Can be replaced in tests
+	PromptOut io.Writer = os.Stdout
+
+	// IsTerminal returns true if the file is a terminal. Can be replaced in tests
+	IsTerminal = func(f *os.File) bool {
+		info, err := f.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+
+	batchMode      *bool
+	batchModeValue bool
+	defaultNoColor = color.NoColor
+)
+
+// AddBatchModeFlags adds the persistent --batch-mode flag to the root command
+func AddBatchModeFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVarP(&batchModeValue, BatchModeFlag, "", false, "disables colors and prompts. If not specified batch mode is enabled if the output is not a terminal or $"+CIEnvVar+" is set")
+}
+
+// ApplyBatchModeFlags enables batch mode if the --batch-mode flag is specified or if it is detected from the
+// environment when the flag is not specified
+func ApplyBatchModeFlags(cmd *cobra.Command) {
+	flag := cmd.Flags().Lookup(BatchModeFlag)
+	if flag != nil && flag.Changed {
+		SetBatchMode(batchModeValue)
+		return
+	}
+	SetBatchMode(DetectBatchMode(IsTerminal(os.Stdout), os.Getenv(CIEnvVar)))
+}
+
+// DetectBatchMode returns true if the output is not a terminal or the value of the $CI environment variable
+// is not blank or false
+func DetectBatchMode(terminal bool, ci string) bool {
+	ci = strings.TrimSpace(ci)
+	if ci != "" {
+		enabled, err := strconv.ParseBool(ci)
+		if err != nil || enabled {
+			return true
+		}
+	}
+	return !terminal
+}
+
+// SetBatchMode enables or disables batch mode. Colors are disabled in batch mode
+func SetBatchMode(enabled bool) {
+	batchMode = &enabled
+	color.NoColor = enabled || defaultNoColor
+}
+
+// BatchMode returns true if batch mode is enabled. If it has not been set yet it is detected from the environment
+func BatchMode() bool {
+	if batchMode == nil {
+		return DetectBatchMode(IsTerminal(os.Stdout), os.Getenv(CIEnvVar))
+	}
+	return *batchMode
+}
+
+// Prompt asks for a value returning the default if nothing is entered. In batch mode the default is returned
+// without asking or an error if there is no default
+func Prompt(message, defaultValue string) (string, error) {
+	if BatchMode() {
+		if defaultValue == "" {
+			return "", errors.Errorf("cannot prompt for %s in batch mode as there is no default value", message)
+		}
+		return defaultValue, nil
+	}
+	if defaultValue != "" {
+		fmt.Fprintf(PromptOut, "%s [%s]: ", message, defaultValue)
+	} else {
+		fmt.Fprintf(PromptOut, "%s: ", message)
+	}
+	line, err := bufio.NewReader(PromptIn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrapf(err, "failed to read the answer to %s", message)
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		if defaultValue == "" {
+			return "", errors.Errorf("no value entered for %s", message)
+		}
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// Confirm asks a yes or no question returning the default if nothing is entered or in batch mode
+func Confirm(message string, defaultValue bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultValue {
+		defaultAnswer = "y"
+	}
+	answer, err := Prompt(message+" (y/n)", defaultAnswer)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return false, errors.Errorf("invalid answer '%s' to %s. Please answer y or n", answer, message)
+	}
+}
@@ -0,0 +1,130 @@
+package common_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectBatchMode(t *testing.T) {
+	testCases := []struct {
+		terminal bool
+		ci       string
+		expected bool
+	}{
+		{terminal: true, ci: "", expected: false},
+		{terminal: false, ci: "", expected: true},
+		{terminal: true, ci: "true", expected: true},
+		{terminal: true, ci: "1", expected: true},
+		{terminal: true, ci: "woodpecker", expected: true},
+		{terminal: true, ci: "false", expected: false},
+		{terminal: true, ci: "0", expected: false},
+		{terminal: false, ci: "false", expected: true},
+	}
+	for _, tc := range testCases {
+		actual := common.DetectBatchMode(tc.terminal, tc.ci)
+		assert.Equal(t, tc.expected, actual, "batch mode for terminal %v and $CI '%s'", tc.terminal, tc.ci)
+	}
+}
+
+func TestApplyBatchModeFlags(t *testing.T) {
+	oldIsTerminal := common.IsTerminal
+	common.IsTerminal = func(f *os.File) bool {
+		return true
+	}
+	t.Cleanup(func() {
+		common.IsTerminal = oldIsTerminal
+		common.SetBatchMode(false)
+	})
+	setEnv(t, common.CIEnvVar, "")
+
+	testCases := []struct {
+		args     []string
+		ci       string
+		expected bool
+	}{
+		{expected: false},
+		{ci: "true", expected: true},
+		{args: []string{"--batch-mode"}, expected: true},
+		{args: []string{"--batch-mode=false"}, ci: "true", expected: false},
+	}
+	for _, tc := range testCases {
+		os.Setenv(common.CIEnvVar, tc.ci)
+
+		root := &cobra.Command{Use: "root"}
+		common.AddBatchModeFlags(root)
+		child := &cobra.Command{
+			Use: "child",
+			Run: func(cmd *cobra.Command, args []string) {
+				common.ApplyBatchModeFlags(cmd)
+			},
+		}
+		root.AddCommand(child)
+		root.SetArgs(append([]string{"child"}, tc.args...))
+		err := root.Execute()
+		require.NoError(t, err, "failed to run with args %v", tc.args)
+
+		assert.Equal(t, tc.expected, common.BatchMode(), "batch mode for args %v and $CI '%s'", tc.args, tc.ci)
+	}
+}
+
+func TestBatchModeStripsColors(t *testing.T) {
+	oldNoColor := color.NoColor
+	t.Cleanup(func() {
+		common.SetBatchMode(false)
+		color.NoColor = oldNoColor
+	})
+
+	color.NoColor = false
+	require.Contains(t, termcolor.ColorInfo("kpt"), "\x1b[", "colors should be enabled before batch mode")
+
+	common.SetBatchMode(true)
+	assert.Equal(t, "kpt", termcolor.ColorInfo("kpt"), "colors should be stripped in batch mode")
+	assert.False(t, strings.Contains(termcolor.ColorError("failed"), "\x1b["), "colors should be stripped in batch mode")
+}
+
+func TestPrompt(t *testing.T) {
+	out := &bytes.Buffer{}
+	oldIn, oldOut := common.PromptIn, common.PromptOut
+	common.PromptOut = out
+	t.Cleanup(func() {
+		common.PromptIn, common.PromptOut = oldIn, oldOut
+		common.SetBatchMode(false)
+	})
+
+	// batch mode answers prompts with their default without asking
+	common.SetBatchMode(true)
+	answer, err := common.Prompt("namespace", "jx")
+	require.NoError(t, err, "failed to prompt in batch mode")
+	assert.Equal(t, "jx", answer, "default answer")
+	_, err = common.Prompt("git token", "")
+	assert.Error(t, err, "should fail in batch mode without a default")
+	ok, err := common.Confirm("recreate the packages", true)
+	require.NoError(t, err, "failed to confirm in batch mode")
+	assert.True(t, ok, "default confirmation")
+	assert.Empty(t, out.String(), "nothing should be asked in batch mode")
+
+	common.SetBatchMode(false)
+	common.PromptIn = strings.NewReader("jx-staging\n")
+	answer, err = common.Prompt("namespace", "jx")
+	require.NoError(t, err, "failed to prompt")
+	assert.Equal(t, "jx-staging", answer, "entered answer")
+	assert.Equal(t, "namespace [jx]: ", out.String(), "prompt")
+
+	common.PromptIn = strings.NewReader("\n")
+	answer, err = common.Prompt("namespace", "jx")
+	require.NoError(t, err, "failed to prompt")
+	assert.Equal(t, "jx", answer, "default answer")
+
+	common.PromptIn = strings.NewReader("maybe\n")
+	_, err = common.Confirm("recreate the packages", false)
+	assert.Error(t, err, "should fail for an invalid answer")
+}
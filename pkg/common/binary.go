@@ -0,0 +1,170 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BinaryVersionTimeout the maximum time a binary can take to report its version
+	BinaryVersionTimeout = 30 * time.Second
+)
+
+var (
+	// InstallHints the install instructions of the well known binaries
+	InstallHints = map[string]string{
+		"git":  "see https://git-scm.com/downloads",
+		"helm": "see https://helm.sh/docs/intro/install/",
+		"kpt":  "see https://kpt.dev/installation/",
+	}
+
+	resolvedBinaries     = map[string]resolvedBinary{}
+	resolvedBinariesLock sync.Mutex
+)
+
+type resolvedBinary struct {
+	path    string
+	version string
+}
+
+// ResolveOption an option for resolving a binary
+type ResolveOption func(o *resolveOptions)
+
+type resolveOptions struct {
+	flag        string
+	override    string
+	envVar      string
+	versionArgs []string
+	installHint string
+}
+
+// WithOverride uses the given path of the binary specified via the given flag instead of looking on the PATH
+func WithOverride(flag, path string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.flag = flag
+		o.override = path
+	}
+}
+
+// WithEnvVar sets the name of the environment variable which overrides the location of the binary.
+// Defaults to $JX_GITOPS_<NAME>_BINARY
+func WithEnvVar(name string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.envVar = name
+	}
+}
+
+// WithVersionArgs sets the arguments used to verify the binary runs. Defaults to --version
+func WithVersionArgs(args ...string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.versionArgs = args
+	}
+}
+
+// WithInstallHint sets the install instructions included in the error if the binary cannot be used
+func WithInstallHint(hint string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.installHint = hint
+	}
+}
+
+// BinaryEnvVar returns the default name of the environment variable which overrides the location of a binary
+func BinaryEnvVar(name string) string {
+	key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	return "JX_GITOPS_" + key + "_BINARY"
+}
+
+// ResolveBinary finds the binary with the given name and verifies that it runs, returning its path and the
+// first line of its version output. An explicit override or the environment variable is used before looking
+// on the PATH. Successful results are cached for the process so that commands can resolve the binaries they
+// need while validating their options before doing any work
+func ResolveBinary(name string, opts ...ResolveOption) (string, string, error) {
+	o := &resolveOptions{
+		envVar:      BinaryEnvVar(name),
+		versionArgs: []string{"--version"},
+		installHint: InstallHints[name],
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	bin := o.override
+	source := ""
+	if bin != "" {
+		source = "the override"
+		if o.flag != "" {
+			source = "--" + o.flag
+		}
+	} else if o.envVar != "" {
+		bin = os.Getenv(o.envVar)
+		source = "$" + o.envVar
+	}
+	if bin == "" {
+		bin = name
+		source = ""
+	}
+
+	key := strings.Join(append([]string{bin, os.Getenv("PATH")}, o.versionArgs...), "\x00")
+	resolvedBinariesLock.Lock()
+	cached, ok := resolvedBinaries[key]
+	resolvedBinariesLock.Unlock()
+	if ok {
+		return cached.path, cached.version, nil
+	}
+
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		if source != "" {
+			return "", "", o.binaryError(name, errors.Errorf("the %s binary %s specified via %s could not be found or is not executable", name, bin, source))
+		}
+		return "", "", o.binaryError(name, errors.Errorf("could not find the %s binary on the PATH", name))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), BinaryVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, o.versionArgs...).CombinedOutput() // #nosec
+	if err != nil {
+		text := strings.TrimSpace(string(out))
+		if text != "" {
+			err = errors.Errorf("%s: %s", err.Error(), text)
+		}
+		return "", "", o.binaryError(name, errors.Wrapf(err, "failed to run %s %s", path, strings.Join(o.versionArgs, " ")))
+	}
+	version := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0])
+
+	resolvedBinariesLock.Lock()
+	resolvedBinaries[key] = resolvedBinary{path: path, version: version}
+	resolvedBinariesLock.Unlock()
+	return path, version, nil
+}
+
+// binaryError adds the platform and install instructions to the error
+func (o *resolveOptions) binaryError(name string, err error) error {
+	hint := o.installHint
+	if hint == "" {
+		hint = "see its documentation"
+	}
+	override := ""
+	if o.flag != "" {
+		override = "--" + o.flag
+	}
+	if o.envVar != "" {
+		if override != "" {
+			override += " or "
+		}
+		override += "$" + o.envVar
+	}
+	msg := fmt.Sprintf("%s. Please install %s for %s/%s: %s", err.Error(), name, runtime.GOOS, runtime.GOARCH, hint)
+	if override != "" {
+		msg += fmt.Sprintf(". Alternatively specify its location via %s", override)
+	}
+	return errors.New(msg)
+}
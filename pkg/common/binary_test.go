@@ -0,0 +1,100 @@
+package common_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub binaries are shell scripts")
+	}
+	binDir := tempDir(t)
+	emptyDir := tempDir(t)
+	otherDir := tempDir(t)
+
+	kpt := writeStub(t, binDir, "kpt", "echo 'kpt version 1.0.0'\necho 'built by stub'", 0700)
+	otherKpt := writeStub(t, otherDir, "kpt", "echo 'kpt version 2.0.0'", 0700)
+	broken := writeStub(t, otherDir, "broken-kpt", "echo 'boom' >&2\nexit 3", 0700)
+	unexecutable := writeStub(t, otherDir, "unexecutable-kpt", "echo 'kpt version 3.0.0'", 0600)
+
+	envVar := common.BinaryEnvVar("kpt")
+	assert.Equal(t, "JX_GITOPS_KPT_BINARY", envVar, "env var")
+	setEnv(t, "PATH", binDir)
+	setEnv(t, envVar, "")
+
+	path, version, err := common.ResolveBinary("kpt", common.WithVersionArgs("version"))
+	require.NoError(t, err, "failed to resolve kpt on the PATH")
+	assert.Equal(t, kpt, path, "path")
+	assert.Equal(t, "kpt version 1.0.0", version, "version")
+
+	// the result should be cached for the process
+	require.NoError(t, os.Remove(kpt))
+	path, _, err = common.ResolveBinary("kpt", common.WithVersionArgs("version"))
+	require.NoError(t, err, "failed to resolve the cached kpt")
+	assert.Equal(t, kpt, path, "cached path")
+
+	// the environment variable should be used before the PATH and the flag before the environment variable
+	setEnv(t, envVar, otherKpt)
+	_, version, err = common.ResolveBinary("kpt", common.WithVersionArgs("version"))
+	require.NoError(t, err, "failed to resolve kpt via $%s", envVar)
+	assert.Equal(t, "kpt version 2.0.0", version, "version via $%s", envVar)
+
+	_, _, err = common.ResolveBinary("kpt", common.WithOverride("bin", unexecutable), common.WithVersionArgs("version"))
+	require.Error(t, err, "should not be able to use an unexecutable binary")
+	assert.Contains(t, err.Error(), "specified via --bin could not be found or is not executable", "error")
+	assert.Contains(t, err.Error(), "--bin or $"+envVar, "error")
+
+	_, _, err = common.ResolveBinary("kpt", common.WithOverride("bin", broken))
+	require.Error(t, err, "should not be able to use a binary which fails to run")
+	assert.Contains(t, err.Error(), "boom", "the output should be included in the error")
+
+	setEnv(t, envVar, "")
+	setEnv(t, "PATH", emptyDir)
+	_, _, err = common.ResolveBinary("kpt", common.WithVersionArgs("version"))
+	require.Error(t, err, "should not find kpt")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), "could not find the kpt binary on the PATH", "error")
+	assert.Contains(t, err.Error(), runtime.GOOS+"/"+runtime.GOARCH, "the error should include the platform")
+	assert.Contains(t, err.Error(), common.InstallHints["kpt"], "the error should include the install hint")
+
+	_, _, err = common.ResolveBinary("cheese", common.WithEnvVar(""), common.WithInstallHint("see https://cheese.example.com"))
+	require.Error(t, err, "should not find cheese")
+	assert.Contains(t, err.Error(), "see https://cheese.example.com", "the error should include the install hint")
+	assert.NotContains(t, err.Error(), "Alternatively", "there is no way to override the binary")
+}
+
+func writeStub(t *testing.T, dir, name, script string, mode os.FileMode) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), mode)
+	require.NoError(t, err, "failed to write %s", path)
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func setEnv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value), "failed to set $%s", key)
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+)
+
+// MaskedValue the text used in place of secret values
+const MaskedValue = "****"
+
+var secretEnvKeyWords = []string{"TOKEN", "PASSWORD", "SECRET", "CREDENTIAL"}
+
+// CommandLine returns a stable single line rendering of the command including the directory and environment
+// variables with any secret values masked
+func CommandLine(c *cmdrunner.Command) string {
+	var builder strings.Builder
+	if c.Dir != "" {
+		builder.WriteString("cd ")
+		builder.WriteString(QuoteArg(c.Dir))
+		builder.WriteString(" && ")
+	}
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := QuoteArg(c.Env[k])
+		if IsSecretEnvKey(k) {
+			v = MaskedValue
+		}
+		builder.WriteString(k)
+		builder.WriteString("=")
+		builder.WriteString(v)
+		builder.WriteString(" ")
+	}
+	builder.WriteString(QuoteArg(c.Name))
+	for _, arg := range c.Args {
+		builder.WriteString(" ")
+		builder.WriteString(QuoteArg(arg))
+	}
+	return builder.String()
+}
+
+// QuoteArg quotes the argument using single quotes if it contains whitespace or shell special characters
+func QuoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(arg, " \t\n\"'\\$`!*?&|;<>()[]{}#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// IsSecretEnvKey returns true if the environment variable name looks like it contains a secret value
+func IsSecretEnvKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, w := range secretEnvKeyWords {
+		if strings.Contains(upper, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+)
+
+// DryRunCommandRunner records and prints the commands that would be run without running them
+type DryRunCommandRunner struct {
+	Out      io.Writer
+	lock     sync.Mutex
+	commands []*cmdrunner.Command
+}
+
+// NewDryRunCommandRunner creates a new dry run command runner which prints the commands to the given writer
+func NewDryRunCommandRunner(out io.Writer) *DryRunCommandRunner {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &DryRunCommandRunner{Out: out}
+}
+
+// Run records and prints the command returning success without running it
+func (r *DryRunCommandRunner) Run(c *cmdrunner.Command) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.commands = append(r.commands, c)
+	_, err := fmt.Fprintf(r.Out, "would run: %s\n", CommandLine(c))
+	return "", err
+}
+
+// Commands returns the commands recorded so far
+func (r *DryRunCommandRunner) Commands() []*cmdrunner.Command {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	answer := make([]*cmdrunner.Command, len(r.commands))
+	copy(answer, r.commands)
+	return answer
+}
+
+// CommandLines returns the single line rendering of the commands recorded so far
+func (r *DryRunCommandRunner) CommandLines() []string {
+	var answer []string
+	for _, c := range r.Commands() {
+		answer = append(answer, CommandLine(c))
+	}
+	return answer
+}
@@ -0,0 +1,68 @@
+package common_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunCommandRunner(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	runner := common.NewDryRunCommandRunner(buf)
+
+	commands := []*cmdrunner.Command{
+		{
+			Name: "kpt",
+			Args: []string{"pkg", "get", "https://github.com/foo/bar.git/thingy@v1", "config-root/namespaces/foo"},
+			Dir:  "/tmp/out",
+		},
+		{
+			Name: "git",
+			Args: []string{"commit", "-m", "chore: some message", "it's here"},
+			Env: map[string]string{
+				"GIT_TOKEN":   "mysecret",
+				"HTTPS_PROXY": "http://proxy:8080",
+			},
+		},
+	}
+	for _, c := range commands {
+		text, err := runner.Run(c)
+		require.NoError(t, err, "failed to run %s", c.CLI())
+		assert.Empty(t, text, "output for %s", c.CLI())
+	}
+
+	expected := []string{
+		"cd /tmp/out && kpt pkg get https://github.com/foo/bar.git/thingy@v1 config-root/namespaces/foo",
+		`GIT_TOKEN=**** HTTPS_PROXY=http://proxy:8080 git commit -m 'chore: some message' 'it'\''s here'`,
+	}
+	assert.Equal(t, expected, runner.CommandLines(), "command lines")
+	assert.Len(t, runner.Commands(), 2, "recorded commands")
+
+	assert.Equal(t, "would run: "+expected[0]+"\nwould run: "+expected[1]+"\n", buf.String(), "output")
+	assert.NotContains(t, buf.String(), "mysecret", "output should not contain secrets")
+}
+
+func TestQuoteArg(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"":                "''",
+		"simple":          "simple",
+		"a/b.git@v1":      "a/b.git@v1",
+		"with space":      "'with space'",
+		"with\ttab":       "'with\ttab'",
+		"$HOME":           "'$HOME'",
+		"it's":            `'it'\''s'`,
+		"key=value":       "key=value",
+		`say "something"`: `'say "something"'`,
+	}
+	for arg, expected := range testCases {
+		assert.Equal(t, expected, common.QuoteArg(arg), "for arg %s", arg)
+	}
+}
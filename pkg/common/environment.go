@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// CommandEnvironment the extra environment variables passed to commands along with the secret values
+// which must never appear in the logs or errors
+type CommandEnvironment struct {
+	// Env the environment variables merged into the environment of each command
+	Env map[string]string
+
+	// MaskedEnvKeys the names of the environment variables whose values are masked.
+	// Environment variables which look like secrets such as *_TOKEN are always masked
+	MaskedEnvKeys []string
+
+	// MaskedArgs the argument values which are masked
+	MaskedArgs []string
+
+	// QuietLogging if enabled the command lines are only logged at debug level
+	QuietLogging bool
+
+	// Log the logger the command lines are logged to. Defaults to log.Logger()
+	Log Logger
+}
+
+// AddFlags adds the environment flags to the command
+func (e *CommandEnvironment) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringToStringVarP(&e.Env, "env", "", nil, "the environment variables to pass to the commands such as HTTPS_PROXY=http://myproxy:3128")
+	cmd.Flags().StringArrayVarP(&e.MaskedEnvKeys, "masked-env", "", nil, "the names of the environment variables whose values are masked in the logs")
+}
+
+// NewCommandRunner creates a command runner which merges the environment into each command and logs the
+// command line with the secret values masked. The output and errors of the next runner are also masked.
+// If the next runner is nil then RunCommandResult is used
+func (e *CommandEnvironment) NewCommandRunner(next cmdrunner.CommandRunner) cmdrunner.CommandRunner {
+	var resultRunner ResultCommandRunner
+	if next != nil {
+		resultRunner = FromCommandRunner(next)
+	}
+	return ToCommandRunner(e.NewResultRunner(resultRunner))
+}
+
+// NewResultRunner creates a runner like NewCommandRunner which returns the structured result.
+// If the default audit log is enabled then each command is recorded with the secret values masked
+func (e *CommandEnvironment) NewResultRunner(next ResultCommandRunner) ResultCommandRunner {
+	if next == nil {
+		next = RunCommandResult
+	}
+	if audit := DefaultAuditLog(); audit != nil {
+		next = audit.NewResultRunner(next, e.Mask)
+	}
+	return func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+		e.apply(c)
+		mask := e.maskFunc(c)
+
+		var logger Logger = log.Logger()
+		if e.Log != nil {
+			logger = e.Log
+		}
+		if e.QuietLogging {
+			logger.Debugf("about to run: %s", termcolor.ColorInfo(mask(CommandLine(c))))
+		} else {
+			logger.Infof("about to run: %s", termcolor.ColorInfo(mask(CommandLine(c))))
+		}
+
+		result, err := next(ctx, c)
+		if result != nil {
+			result.Stdout = mask(result.Stdout)
+			result.Stderr = mask(result.Stderr)
+		}
+		if err != nil {
+			return result, maskError(err, mask)
+		}
+		return result, nil
+	}
+}
+
+// maskError masks the message of the error. If it was caused by a CommandError then the cause is replaced by
+// a masked copy so that callers using errors.As cannot see the secret values. Otherwise the cause is a masked
+// copy of the message as the original error cannot be exposed without revealing the secret values
+func maskError(err error, mask func(string) string) error {
+	message := mask(err.Error())
+	var commandErr *CommandError
+	if errors.As(err, &commandErr) {
+		return &maskedError{message: message, cause: commandErr.masked(mask)}
+	}
+	return &maskedError{message: message, cause: errors.New(message)}
+}
+
+// Mask masks any secret values in the given text for the command
+func (e *CommandEnvironment) Mask(c *cmdrunner.Command, text string) string {
+	return e.maskFunc(c)(text)
+}
+
+// apply merges the environment into the command leaving any variables already on the command unchanged
+func (e *CommandEnvironment) apply(c *cmdrunner.Command) {
+	if len(e.Env) == 0 {
+		return
+	}
+	env := map[string]string{}
+	for k, v := range e.Env {
+		env[k] = v
+	}
+	for k, v := range c.Env {
+		env[k] = v
+	}
+	c.Env = env
+}
+
+// maskFunc returns a function which replaces the secret values with the masked value
+func (e *CommandEnvironment) maskFunc(c *cmdrunner.Command) func(string) string {
+	var secrets []string
+	addEnv := func(env map[string]string) {
+		for k, v := range env {
+			if IsSecretEnvKey(k) || stringhelpers.StringArrayIndex(e.MaskedEnvKeys, k) >= 0 {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	addEnv(e.Env)
+	addEnv(c.Env)
+	for _, k := range e.MaskedEnvKeys {
+		secrets = append(secrets, os.Getenv(k))
+	}
+	secrets = append(secrets, e.MaskedArgs...)
+
+	// lets replace the longest values first in case one secret contains another
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	var oldnew []string
+	for _, s := range secrets {
+		if s != "" && s != MaskedValue {
+			oldnew = append(oldnew, s, MaskedValue)
+		}
+	}
+	replacer := strings.NewReplacer(oldnew...)
+	return replacer.Replace
+}
+
+// maskedError an error whose message has had the secret values masked
+type maskedError struct {
+	message string
+	cause   error
+}
+
+// Error returns the masked error message
+func (e *maskedError) Error() string {
+	return e.message
+}
+
+// Cause returns the masked cause of the error
+func (e *maskedError) Cause() error {
+	return e.cause
+}
+
+// Unwrap returns the masked cause of the error so that a CommandError can be matched via errors.As
+func (e *maskedError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,132 @@
+package common_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envHelperEnv = "JX_GITOPS_ENV_HELPER"
+
+// TestEnvHelperProcess is not a real test; it is the child process run by the environment tests.
+// It writes the secret environment variable to a file, echos its arguments then fails
+func TestEnvHelperProcess(t *testing.T) {
+	outFile := os.Getenv(envHelperEnv)
+	if outFile == "" {
+		return
+	}
+	err := ioutil.WriteFile(outFile, []byte(os.Getenv("GIT_TOKEN")+"\n"+os.Getenv("HTTPS_PROXY")), 0600)
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Printf("args: %v\n", os.Args[1:])
+	fmt.Printf("token: %s\n", os.Getenv("GIT_TOKEN"))
+	os.Exit(1)
+}
+
+func TestCommandEnvironmentMasksSecrets(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	outFile := filepath.Join(tmpDir, "env.txt")
+
+	token := "mysecrettoken"
+	password := "mypassword"
+	e := &common.CommandEnvironment{
+		Env: map[string]string{
+			"GIT_TOKEN":   token,
+			"HTTPS_PROXY": "http://myproxy:3128",
+		},
+		MaskedArgs: []string{password},
+	}
+	runner := e.NewCommandRunner(nil)
+
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestEnvHelperProcess", "--", "--password", password},
+		Env:  map[string]string{envHelperEnv: outFile},
+	}
+
+	var output string
+	logs := log.CaptureOutput(func() {
+		output, err = runner(c)
+	})
+	require.Error(t, err, "the child process should have failed")
+	wrapped := errors.Wrapf(err, "failed to run kpt").Error()
+	t.Logf("logs: %s", logs)
+	t.Logf("error: %s", wrapped)
+
+	for _, secret := range []string{token, password} {
+		assert.NotContains(t, logs, secret, "logs")
+		assert.NotContains(t, output, secret, "output")
+		assert.NotContains(t, wrapped, secret, "error")
+	}
+	assert.Contains(t, logs, "GIT_TOKEN="+common.MaskedValue, "logs")
+	assert.Contains(t, logs, "HTTPS_PROXY=http://myproxy:3128", "logs")
+	assert.Contains(t, output, "token: "+common.MaskedValue, "output")
+	assert.Contains(t, wrapped, "--password "+common.MaskedValue, "error")
+
+	// the command error found by errors.As should be masked too
+	var commandErr *common.CommandError
+	require.True(t, errors.As(err, &commandErr), "should be a command error")
+	assert.NotContains(t, commandErr.Command, password, "command of the error")
+	assert.NotContains(t, commandErr.Output, token, "output of the error")
+	assert.NotContains(t, commandErr.Error(), password, "message of the command error")
+	for _, secret := range []string{token, password} {
+		assert.NotContains(t, errors.Cause(err).Error(), secret, "cause of the error")
+	}
+
+	data, err := ioutil.ReadFile(outFile)
+	require.NoError(t, err, "the child process should have written %s", outFile)
+	assert.Equal(t, token+"\nhttp://myproxy:3128", string(data), "the child process environment")
+}
+
+func TestCommandEnvironmentMaskedEnvKeys(t *testing.T) {
+	e := &common.CommandEnvironment{
+		Env: map[string]string{
+			"MY_KEY":      "cheese",
+			"HTTPS_PROXY": "http://myproxy:3128",
+		},
+		MaskedEnvKeys: []string{"MY_KEY"},
+	}
+	var received *cmdrunner.Command
+	fake := testhelpers.NewFakeCommandRunner().Expect(testhelpers.Expectation{
+		Name:   "git",
+		Output: "using cheese",
+		Callback: func(c *cmdrunner.Command) error {
+			received = c
+			return errors.New("failed with cheese")
+		},
+	})
+	runner := e.NewCommandRunner(fake.Run)
+
+	c := cmdrunner.NewCommand("", "git", "fetch")
+	c.Env = map[string]string{"HTTPS_PROXY": "http://other:3128"}
+
+	var output string
+	var err error
+	logs := log.CaptureOutput(func() {
+		output, err = runner(c)
+	})
+	require.Error(t, err)
+	fake.Verify(t)
+
+	assert.NotContains(t, logs, "cheese", "logs")
+	assert.Equal(t, "using "+common.MaskedValue, output, "output")
+	assert.Equal(t, "failed with "+common.MaskedValue, err.Error(), "error")
+	assert.Equal(t, "failed with "+common.MaskedValue, errors.Cause(err).Error(), "the cause should be masked")
+	assert.Equal(t, "failed with "+common.MaskedValue, errors.Unwrap(err).Error(), "the unwrapped error should be masked")
+
+	require.NotNil(t, received, "should have invoked the next runner")
+	assert.Equal(t, "cheese", received.Env["MY_KEY"], "should have passed the environment")
+	assert.Equal(t, "http://other:3128", received.Env["HTTPS_PROXY"], "should not override the environment of the command")
+}
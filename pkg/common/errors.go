@@ -0,0 +1,276 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrorOutputText prints errors as text
+	ErrorOutputText = "text"
+
+	// ErrorOutputJSON prints errors as JSON so that they can be processed by other tools
+	ErrorOutputJSON = "json"
+)
+
+// ErrorItem an error with the context of the item which failed
+type ErrorItem struct {
+	// Path the file or directory which failed
+	Path string
+
+	// Package the package which failed
+	Package string
+
+	// Phase the phase of the processing which failed such as 'kpt pkg get'
+	Phase string
+
+	// Err the error
+	Err error
+}
+
+// Error returns the error prefixed with the context
+func (e *ErrorItem) Error() string {
+	var prefix []string
+	if e.Package != "" {
+		prefix = append(prefix, "package "+e.Package)
+	}
+	if e.Path != "" {
+		prefix = append(prefix, "file "+e.Path)
+	}
+	if e.Phase != "" {
+		prefix = append(prefix, "phase "+e.Phase)
+	}
+	msg := "<nil>"
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if len(prefix) == 0 {
+		return msg
+	}
+	return strings.Join(prefix, " ") + ": " + msg
+}
+
+// Unwrap returns the underlying error
+func (e *ErrorItem) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON marshals the context and error message
+func (e *ErrorItem) MarshalJSON() ([]byte, error) {
+	msg := ""
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Path    string `json:"path,omitempty"`
+		Package string `json:"package,omitempty"`
+		Phase   string `json:"phase,omitempty"`
+		Error   string `json:"error"`
+	}{e.Path, e.Package, e.Phase, msg})
+}
+
+// CommandError a command failed to run or exited with a non-zero exit code
+type CommandError struct {
+	// Command the command line which failed
+	Command string
+
+	// Dir the directory the command ran in
+	Dir string
+
+	// ExitCode the exit code of the command or -1 if it did not exit normally
+	ExitCode int
+
+	// Output the output of the command
+	Output string
+
+	// Err the error running the command
+	Err error
+}
+
+// NewCommandError creates an error for the failed command using the result if it is not nil
+func NewCommandError(c *cmdrunner.Command, result *CommandResult, err error) *CommandError {
+	answer := &CommandError{
+		Command:  cmdrunner.CLI(c),
+		Dir:      c.Dir,
+		ExitCode: ExitCode(err),
+		Err:      err,
+	}
+	if result != nil {
+		answer.ExitCode = result.ExitCode
+		answer.Output = result.Output()
+	}
+	return answer
+}
+
+// Error returns the error message
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("failed to run '%s' command in directory '%s', output: '%s'", e.Command, e.Dir, e.Output)
+	if e.Err == nil {
+		return msg
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// masked returns a copy of the error with the secret values masked
+func (e *CommandError) masked(mask func(string) string) *CommandError {
+	answer := *e
+	answer.Command = mask(e.Command)
+	answer.Output = mask(e.Output)
+	if e.Err != nil {
+		answer.Err = errors.New(mask(e.Err.Error()))
+	}
+	return &answer
+}
+
+// IsCommandError returns true if the error was caused by a command failing rather than by invalid input.
+// It can be used as the RetryOptions.Retryable function
+func IsCommandError(_ string, err error) bool {
+	var commandErr *CommandError
+	return errors.As(err, &commandErr)
+}
+
+// ErrorList aggregates the errors of the items processed by a bulk command so that every failure is reported
+type ErrorList struct {
+	// Subject the kind of item processed such as 'package' used in the summary
+	Subject string
+
+	// Items the errors
+	Items []*ErrorItem
+}
+
+// NewErrorList creates an empty error list for the given kind of item
+func NewErrorList(subject string) *ErrorList {
+	return &ErrorList{Subject: subject}
+}
+
+// Append adds the error with its context. Nil errors are ignored and appending an ErrorList adds its items
+func (l *ErrorList) Append(item *ErrorItem) {
+	if item == nil || item.Err == nil {
+		return
+	}
+	if nested, ok := item.Err.(*ErrorList); ok {
+		l.Items = append(l.Items, nested.Items...)
+		return
+	}
+	l.Items = append(l.Items, item)
+}
+
+// Empty returns true if there are no errors
+func (l *ErrorList) Empty() bool {
+	return l == nil || len(l.Items) == 0
+}
+
+// Len returns the number of errors
+func (l *ErrorList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.Items)
+}
+
+// ErrorOrNil returns nil if there are no errors otherwise the list
+func (l *ErrorList) ErrorOrNil() error {
+	if l.Empty() {
+		return nil
+	}
+	return l
+}
+
+// Error returns a summary such as '2 packages failed: a; b'
+func (l *ErrorList) Error() string {
+	subject := l.Subject
+	if subject == "" {
+		subject = "item"
+	}
+	if len(l.Items) != 1 {
+		subject += "s"
+	}
+	var msgs []string
+	for _, item := range l.Items {
+		msgs = append(msgs, item.Error())
+	}
+	return fmt.Sprintf("%d %s failed: %s", len(l.Items), subject, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors
+func (l *ErrorList) Unwrap() []error {
+	var answer []error
+	for _, item := range l.Items {
+		answer = append(answer, item)
+	}
+	return answer
+}
+
+// Is returns true if any of the errors matches the target
+func (l *ErrorList) Is(target error) bool {
+	for _, item := range l.Items {
+		if errors.Is(item, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error which matches the target
+func (l *ErrorList) As(target interface{}) bool {
+	for _, item := range l.Items {
+		if errors.As(item, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON marshals the summary and the errors
+func (l *ErrorList) MarshalJSON() ([]byte, error) {
+	items := l.Items
+	if items == nil {
+		items = []*ErrorItem{}
+	}
+	return json.Marshal(struct {
+		Message string       `json:"message"`
+		Errors  []*ErrorItem `json:"errors"`
+	}{l.Error(), items})
+}
+
+// WriteErrorJSON writes the error as JSON including the items of any ErrorList it wraps
+func WriteErrorJSON(w io.Writer, err error) error {
+	answer := struct {
+		Message string       `json:"message"`
+		Errors  []*ErrorItem `json:"errors,omitempty"`
+	}{Message: err.Error()}
+	var list *ErrorList
+	if errors.As(err, &list) {
+		answer.Errors = list.Items
+	}
+	data, err := json.MarshalIndent(answer, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the error to JSON")
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// CheckErr prints the error in the given output format and exits with a non-zero exit code
+func CheckErr(err error, output string) {
+	if err == nil {
+		return
+	}
+	if output == ErrorOutputJSON && WriteErrorJSON(os.Stderr, err) == nil {
+		helper.CheckErr(helper.ErrExit)
+		return
+	}
+	helper.CheckErr(err)
+}
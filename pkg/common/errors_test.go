@@ -0,0 +1,97 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func TestErrorList(t *testing.T) {
+	l := common.NewErrorList("package")
+	assert.True(t, l.Empty(), "new list should be empty")
+	assert.NoError(t, l.ErrorOrNil(), "empty list")
+
+	l.Append(nil)
+	l.Append(&common.ErrorItem{Package: "ignored"})
+	assert.Equal(t, 0, l.Len(), "nil errors should be ignored")
+
+	l.Append(&common.ErrorItem{Path: "a/Kptfile", Package: "a", Phase: "kpt pkg get", Err: errors.Wrap(os.ErrNotExist, "failed to clone")})
+	assert.Equal(t, "1 package failed: package a file a/Kptfile phase kpt pkg get: failed to clone: file does not exist", l.Error(), "summary")
+
+	nested := common.NewErrorList("package")
+	nested.Append(&common.ErrorItem{Package: "b", Err: &exitError{code: 3}})
+	nested.Append(&common.ErrorItem{Err: errors.New("broken")})
+	l.Append(&common.ErrorItem{Err: nested})
+	require.Equal(t, 3, l.Len(), "nested lists should be flattened")
+	assert.Len(t, l.Unwrap(), 3, "unwrapped errors")
+	assert.Equal(t, "3 packages failed: package a file a/Kptfile phase kpt pkg get: failed to clone: file does not exist; package b: exit status 3; broken", l.Error(), "summary")
+
+	// errors.Is and errors.As should work through the aggregate even when it is wrapped
+	err := errors.Wrap(l.ErrorOrNil(), "failed to recreate packages")
+	assert.True(t, errors.Is(err, os.ErrNotExist), "errors.Is should find the cause of an item")
+	assert.False(t, errors.Is(err, os.ErrPermission), "errors.Is should not match other errors")
+
+	var exitErr *exitError
+	require.True(t, errors.As(err, &exitErr), "errors.As should find the error of an item")
+	assert.Equal(t, 3, exitErr.code, "exit code")
+
+	var item *common.ErrorItem
+	require.True(t, errors.As(err, &item), "errors.As should find the first item")
+	assert.Equal(t, "a", item.Package, "package of the first item")
+
+	var list *common.ErrorList
+	require.True(t, errors.As(err, &list), "errors.As should find the list")
+	assert.Equal(t, 3, list.Len(), "length of the found list")
+}
+
+func TestWriteErrorJSON(t *testing.T) {
+	l := common.NewErrorList("package")
+	l.Append(&common.ErrorItem{Path: "a/Kptfile", Package: "a", Phase: "kpt pkg get", Err: errors.New("failed to clone")})
+	l.Append(&common.ErrorItem{Package: "b", Err: errors.New("timed out")})
+
+	buf := &bytes.Buffer{}
+	err := common.WriteErrorJSON(buf, errors.Wrap(l, "failed to recreate"))
+	require.NoError(t, err, "failed to write the JSON")
+
+	expected := `{
+  "message": "failed to recreate: 2 packages failed: package a file a/Kptfile phase kpt pkg get: failed to clone; package b: timed out",
+  "errors": [
+    {
+      "path": "a/Kptfile",
+      "package": "a",
+      "phase": "kpt pkg get",
+      "error": "failed to clone"
+    },
+    {
+      "package": "b",
+      "error": "timed out"
+    }
+  ]
+}
+`
+	assert.Equal(t, expected, buf.String(), "JSON of a wrapped list")
+
+	buf.Reset()
+	err = common.WriteErrorJSON(buf, errors.New("boom"))
+	require.NoError(t, err, "failed to write the JSON")
+	assert.Equal(t, "{\n  \"message\": \"boom\"\n}\n", buf.String(), "JSON of a plain error")
+
+	data, err := json.Marshal(l)
+	require.NoError(t, err, "failed to marshal the list")
+	assert.Contains(t, string(data), `"message":"2 packages failed:`, "JSON of the list")
+}
@@ -0,0 +1,203 @@
+package common
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitSuffix the suffix of git repository URLs
+	GitSuffix = ".git"
+)
+
+var scpGitURL = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):(.+)$`)
+
+// GitURL a parsed git repository URL such as 'https://github.com/org/repo.git', 'ssh://git@host/org/repo',
+// 'git@github.com:org/repo.git' or 'file:///repos/repo'
+type GitURL struct {
+	// Scheme the URL scheme such as 'https', 'ssh' or 'file'. scp style URLs use 'ssh'
+	Scheme string
+
+	// User the user name if specified such as 'git'
+	User string
+
+	// Host the host name without the port. Blank for file URLs
+	Host string
+
+	// Port the port if specified
+	Port string
+
+	// Organisation the owner of the repository. Nested groups are separated by '/'. For file URLs this is
+	// the parent directory of the repository
+	Organisation string
+
+	// Name the name of the repository without the '.git' suffix
+	Name string
+
+	// SCP whether the URL uses the scp style 'user@host:org/repo' syntax
+	SCP bool
+}
+
+// ParseGitURL parses the given git repository URL
+func ParseGitURL(raw string) (*GitURL, error) {
+	text := strings.TrimSpace(raw)
+	if text == "" {
+		return nil, errors.Errorf("no git URL specified")
+	}
+	if strings.HasPrefix(text, "file://") {
+		p := trimGitPath(strings.TrimPrefix(text, "file://"))
+		dir, name := path.Split(p)
+		if name == "" {
+			return nil, errors.Errorf("no repository name in git URL %s", raw)
+		}
+		return &GitURL{
+			Scheme:       "file",
+			Organisation: strings.TrimSuffix(dir, "/"),
+			Name:         name,
+		}, nil
+	}
+
+	u := &GitURL{}
+	var p string
+	if strings.Contains(text, "://") {
+		parsed, err := url.Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse git URL %s", raw)
+		}
+		u.Scheme = strings.ToLower(parsed.Scheme)
+		switch u.Scheme {
+		case "http", "https", "ssh", "git", "git+ssh":
+		default:
+			return nil, errors.Errorf("unsupported scheme %s in git URL %s", parsed.Scheme, raw)
+		}
+		if parsed.User != nil {
+			u.User = parsed.User.Username()
+		}
+		u.Host = parsed.Hostname()
+		u.Port = parsed.Port()
+		p = parsed.Path
+	} else {
+		m := scpGitURL.FindStringSubmatch(text)
+		if m == nil {
+			return nil, errors.Errorf("unsupported git URL %s", raw)
+		}
+		u.Scheme = "ssh"
+		u.SCP = true
+		u.User = m[1]
+		u.Host = m[2]
+		p = m[3]
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("no host in git URL %s", raw)
+	}
+
+	p = strings.TrimPrefix(trimGitPath(p), "/")
+	i := strings.LastIndex(p, "/")
+	if i <= 0 || i == len(p)-1 {
+		return nil, errors.Errorf("git URL %s should include an organisation and repository name", raw)
+	}
+	u.Organisation = p[:i]
+	u.Name = p[i+1:]
+	return u, nil
+}
+
+// trimGitPath removes any trailing slashes and '.git' suffix
+func trimGitPath(p string) string {
+	return strings.TrimSuffix(strings.TrimRight(p, "/"), GitSuffix)
+}
+
+// IsFile returns true if this is a local file URL
+func (u *GitURL) IsFile() bool {
+	return u.Scheme == "file"
+}
+
+// FullName returns the 'organisation/name' of the repository
+func (u *GitURL) FullName() string {
+	if u.Organisation == "" {
+		return u.Name
+	}
+	return u.Organisation + "/" + u.Name
+}
+
+// CloneURL returns the URL in its original form with a '.git' suffix
+func (u *GitURL) CloneURL() string {
+	switch {
+	case u.IsFile():
+		return "file://" + u.FullName() + GitSuffix
+	case u.SCP:
+		return u.userPrefix() + u.Host + ":" + u.FullName() + GitSuffix
+	default:
+		return u.Scheme + "://" + u.userPrefix() + u.hostAndPort() + "/" + u.FullName() + GitSuffix
+	}
+}
+
+// HTTPSCloneURL returns the https clone URL of the repository or blank for file URLs
+func (u *GitURL) HTTPSCloneURL() string {
+	base := u.BrowseURL()
+	if base == "" {
+		return ""
+	}
+	return base + GitSuffix
+}
+
+// BrowseURL returns the web URL of the repository or blank for file URLs. The port is only kept for
+// http and https URLs as other schemes such as ssh use a different port
+func (u *GitURL) BrowseURL() string {
+	if u.IsFile() {
+		return ""
+	}
+	scheme := "https"
+	host := u.Host
+	if u.Scheme == "http" || u.Scheme == "https" {
+		scheme = u.Scheme
+		host = u.hostAndPort()
+	}
+	return scheme + "://" + host + "/" + u.FullName()
+}
+
+// CommitURL returns the web URL of the given commit or blank for file URLs
+func (u *GitURL) CommitURL(sha string) string {
+	base := u.BrowseURL()
+	if base == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Host)
+	switch {
+	case strings.Contains(host, "gitlab"):
+		return base + "/-/commit/" + sha
+	case strings.Contains(host, "bitbucket"):
+		return base + "/commits/" + sha
+	default:
+		return base + "/commit/" + sha
+	}
+}
+
+// SameRepository returns true if both URLs refer to the same repository ignoring the scheme, user, port,
+// '.git' suffix and the case of the host and name
+func (u *GitURL) SameRepository(o *GitURL) bool {
+	if u == nil || o == nil {
+		return false
+	}
+	if u.IsFile() || o.IsFile() {
+		return u.IsFile() && o.IsFile() && u.FullName() == o.FullName()
+	}
+	return strings.EqualFold(u.Host, o.Host) && strings.EqualFold(u.FullName(), o.FullName())
+}
+
+func (u *GitURL) userPrefix() string {
+	if u.User == "" {
+		return ""
+	}
+	return u.User + "@"
+}
+
+func (u *GitURL) hostAndPort() string {
+	if u.Port == "" {
+		return u.Host
+	}
+	return u.Host + ":" + u.Port
+}
@@ -0,0 +1,312 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FormatTable renders the columns as an aligned table
+	FormatTable = "table"
+
+	// FormatJSON renders the items as JSON
+	FormatJSON = "json"
+
+	// FormatYAML renders the items as YAML
+	FormatYAML = "yaml"
+
+	// FormatMarkdown renders the columns as a markdown table suitable for a pull request comment
+	FormatMarkdown = "markdown"
+
+	// columnSeparator the padding between the columns of a table
+	columnSeparator = "  "
+
+	// ellipsis the suffix of truncated values
+	ellipsis = "..."
+)
+
+var (
+	// Formats the supported output formats
+	Formats = []string{FormatTable, FormatJSON, FormatYAML, FormatMarkdown}
+)
+
+// Column a column of a table
+type Column struct {
+	// Header the header of the column
+	Header string
+
+	// Field the name or JSON name of the struct field or the map key of the value. Nested values can be
+	// specified using dots such as 'Spec.Name'. Defaults to the Header
+	Field string
+
+	// MaxWidth if greater than zero longer values are truncated in table and markdown output
+	MaxWidth int
+
+	// Value if specified returns the value of the item rather than using the Field
+	Value func(item interface{}) string
+}
+
+// Options the output options of a command
+type Options struct {
+	// Format the output format
+	Format string
+
+	// NoHeaders disables the header of tables
+	NoHeaders bool
+}
+
+// AddFlags registers the --output and --no-headers flags
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Format, "output", "", FormatTable, fmt.Sprintf("the output format. Values: %s", strings.Join(Formats, ", ")))
+	cmd.Flags().BoolVarP(&o.NoHeaders, "no-headers", "", false, "disables the header of tables")
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Format == "" {
+		o.Format = FormatTable
+	}
+	for _, f := range Formats {
+		if o.Format == f {
+			return nil
+		}
+	}
+	return options.InvalidOption("output", o.Format, Formats)
+}
+
+// Renderer renders a slice of structs or maps in the output format
+type Renderer struct {
+	Options
+
+	// Columns the columns of tables
+	Columns []Column
+
+	// Out the writer the output is written to
+	Out io.Writer
+}
+
+// NewRenderer creates a renderer for the given columns
+func NewRenderer(out io.Writer, o Options, columns ...Column) *Renderer {
+	return &Renderer{
+		Options: o,
+		Columns: columns,
+		Out:     out,
+	}
+}
+
+// Render renders the items which should be a slice of structs, pointers to structs or maps
+func (r *Renderer) Render(items interface{}) error {
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+	switch r.Format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(toSlice(items), "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the output to JSON")
+		}
+		_, err = fmt.Fprintln(r.Out, string(data))
+		return err
+	case FormatYAML:
+		data, err := yaml.Marshal(toSlice(items))
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the output to YAML")
+		}
+		_, err = r.Out.Write(data)
+		return err
+	}
+
+	rows, err := r.rows(items)
+	if err != nil {
+		return err
+	}
+	if r.Format == FormatMarkdown {
+		return r.renderMarkdown(rows)
+	}
+	return r.renderTable(rows)
+}
+
+// rows returns the truncated values of the columns for each item
+func (r *Renderer) rows(items interface{}) ([][]string, error) {
+	v := reflect.ValueOf(items)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("cannot render %s as it is not a slice", v.Type().String())
+	}
+	var answer [][]string
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i).Interface()
+		var row []string
+		for _, c := range r.Columns {
+			var value string
+			if c.Value != nil {
+				value = c.Value(item)
+			} else {
+				field := c.Field
+				if field == "" {
+					field = c.Header
+				}
+				value = FieldValue(item, field)
+			}
+			if r.Format == FormatTable {
+				// tables cannot contain multiple lines
+				value = strings.Join(strings.Fields(value), " ")
+			}
+			row = append(row, truncate(value, c.MaxWidth))
+		}
+		answer = append(answer, row)
+	}
+	return answer, nil
+}
+
+func (r *Renderer) renderTable(rows [][]string) error {
+	widths := make([]int, len(r.Columns))
+	if !r.NoHeaders {
+		for i, c := range r.Columns {
+			widths[i] = utf8.RuneCountInString(c.Header)
+		}
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if w := utf8.RuneCountInString(value); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	buf := &strings.Builder{}
+	writeRow := func(values []string) {
+		line := &strings.Builder{}
+		for i, value := range values {
+			if i > 0 {
+				line.WriteString(columnSeparator)
+			}
+			line.WriteString(value)
+			line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+		}
+		buf.WriteString(strings.TrimRight(line.String(), " "))
+		buf.WriteString("\n")
+	}
+	if !r.NoHeaders {
+		var headers []string
+		for _, c := range r.Columns {
+			headers = append(headers, c.Header)
+		}
+		writeRow(headers)
+	}
+	for _, row := range rows {
+		writeRow(row)
+	}
+	_, err := io.WriteString(r.Out, buf.String())
+	return err
+}
+
+// renderMarkdown renders a markdown table. The header is always included as it is required by markdown
+func (r *Renderer) renderMarkdown(rows [][]string) error {
+	buf := &strings.Builder{}
+	var headers, separators []string
+	for _, c := range r.Columns {
+		headers = append(headers, markdownCell(c.Header))
+		separators = append(separators, "---")
+	}
+	fmt.Fprintf(buf, "| %s |\n", strings.Join(headers, " | "))
+	fmt.Fprintf(buf, "| %s |\n", strings.Join(separators, " | "))
+	for _, row := range rows {
+		var cells []string
+		for _, value := range row {
+			cells = append(cells, markdownCell(value))
+		}
+		fmt.Fprintf(buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+	_, err := io.WriteString(r.Out, buf.String())
+	return err
+}
+
+// FieldValue returns the text of the struct field or map key of the item. Nested values can be specified
+// using dots. Returns an empty string if the value does not exist or is nil
+func FieldValue(item interface{}, field string) string {
+	v := reflect.ValueOf(item)
+	for _, name := range strings.Split(field, ".") {
+		v = lookup(indirect(v), name)
+		if !v.IsValid() {
+			return ""
+		}
+	}
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func lookup(v reflect.Value, name string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+	case reflect.Struct:
+		f := v.FieldByName(name)
+		if f.IsValid() {
+			return f
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			jsonName := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if jsonName == name {
+				return v.Field(i)
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// toSlice returns an empty slice for nil so that JSON and YAML output an empty list rather than null
+func toSlice(items interface{}) interface{} {
+	v := reflect.ValueOf(items)
+	if !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		return []interface{}{}
+	}
+	return items
+}
+
+// truncate truncates the value to the maximum width in runes adding an ellipsis
+func truncate(value string, maxWidth int) string {
+	if maxWidth <= 0 || utf8.RuneCountInString(value) <= maxWidth {
+		return value
+	}
+	runes := []rune(value)
+	if maxWidth <= len(ellipsis) {
+		return string(runes[:maxWidth])
+	}
+	return string(runes[:maxWidth-len(ellipsis)]) + ellipsis
+}
+
+// markdownCell escapes the text so that it can be used in a table cell
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "|", "\\|")
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
@@ -0,0 +1,136 @@
+package output_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/output"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+type upstream struct {
+	Repo string `json:"repo"`
+}
+
+type pkg struct {
+	Name     string        `json:"name"`
+	Version  string        `json:"version,omitempty"`
+	Upstream *upstream     `json:"upstream,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+var (
+	packages = []*pkg{
+		{
+			Name:     "config-root/namespaces/jx",
+			Version:  "4cc6b80d49808060b1f06f530399b986ed344f23",
+			Upstream: &upstream{Repo: "https://github.com/jenkins-x/jxr-kube-resources.git"},
+			Duration: 1500 * time.Millisecond,
+		},
+		{
+			Name:  "config-root/namespaces/ñandú",
+			Error: "failed to clone:\nrepository not found | exit status 128",
+		},
+	}
+
+	columns = []output.Column{
+		{Header: "NAME", Field: "Name"},
+		{Header: "VERSION", Field: "Version", MaxWidth: 10},
+		{Header: "REPOSITORY", Field: "upstream.repo"},
+		{Header: "DURATION", Field: "Duration"},
+		{Header: "ERROR", Field: "Error", MaxWidth: 30},
+	}
+)
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		name      string
+		format    string
+		noHeaders bool
+		items     interface{}
+	}{
+		{name: "table", format: output.FormatTable, items: packages},
+		{name: "table-no-headers", format: output.FormatTable, noHeaders: true, items: packages},
+		{name: "table-empty", format: output.FormatTable, items: []*pkg{}},
+		{name: "markdown", format: output.FormatMarkdown, items: packages},
+		{name: "json", format: output.FormatJSON, items: packages},
+		{name: "json-empty", format: output.FormatJSON, items: []pkg(nil)},
+		{name: "yaml", format: output.FormatYAML, items: packages},
+		{
+			name:   "table-maps",
+			format: output.FormatTable,
+			items: []map[string]interface{}{
+				{"Name": "app", "Version": "1.2.3", "upstream": map[string]interface{}{"repo": "https://github.com/a/b.git"}},
+				{"Name": "another-app-with-a-long-name"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		r := output.NewRenderer(buf, output.Options{Format: tc.format, NoHeaders: tc.noHeaders}, columns...)
+		err := r.Render(tc.items)
+		require.NoError(t, err, "failed to render %s", tc.name)
+
+		expectedFile := filepath.Join("test_data", tc.name+".txt")
+		if generateTestOutput {
+			err = ioutil.WriteFile(expectedFile, buf.Bytes(), 0666)
+			require.NoError(t, err, "failed to save file %s", expectedFile)
+			continue
+		}
+		data, err := ioutil.ReadFile(expectedFile)
+		require.NoError(t, err, "failed to load %s", expectedFile)
+		assert.Equal(t, string(data), buf.String(), "output of %s", tc.name)
+	}
+}
+
+func TestRenderCustomValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := output.NewRenderer(buf, output.Options{NoHeaders: true}, output.Column{
+		Header: "STATUS",
+		Value: func(item interface{}) string {
+			if item.(*pkg).Error != "" {
+				return "failed"
+			}
+			return "ok"
+		},
+	}, output.Column{Header: "NAME", Field: "Name", MaxWidth: 3})
+	err := r.Render(packages)
+	require.NoError(t, err, "failed to render")
+	assert.Equal(t, "ok      con\nfailed  con\n", buf.String(), "output")
+}
+
+func TestRenderInvalid(t *testing.T) {
+	r := output.NewRenderer(&bytes.Buffer{}, output.Options{Format: "xml"}, columns...)
+	err := r.Render(packages)
+	require.Error(t, err, "should fail for an invalid format")
+	assert.Contains(t, err.Error(), "xml", "error message")
+
+	r = output.NewRenderer(&bytes.Buffer{}, output.Options{}, columns...)
+	err = r.Render(packages[0])
+	require.Error(t, err, "should fail if the items are not a slice")
+}
+
+func TestAddFlags(t *testing.T) {
+	o := &output.Options{}
+	cmd := &cobra.Command{}
+	o.AddFlags(cmd)
+
+	err := cmd.ParseFlags([]string{"--output", "yaml", "--no-headers"})
+	require.NoError(t, err, "failed to parse flags")
+	assert.Equal(t, output.FormatYAML, o.Format, "format")
+	assert.True(t, o.NoHeaders, "no headers")
+	assert.True(t, strings.Contains(cmd.Flags().Lookup("output").Usage, "markdown"), "usage should list the formats")
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+)
+
+// Task a unit of work run by RunParallel which either runs a command or invokes a function
+type Task struct {
+	// Name the name of the task used in errors. Defaults to the command line of the command
+	Name string
+
+	// Command the command to run if Func is nil
+	Command *cmdrunner.Command
+
+	// Runner the runner of the command. Defaults to RunCommandResult
+	Runner ResultCommandRunner
+
+	// Func if specified is invoked rather than running a command and returns the output
+	Func func(ctx context.Context) (string, error)
+}
+
+// Result the result of a task run by RunParallel
+type Result struct {
+	// Index the index of the task in the tasks passed to RunParallel
+	Index int
+
+	// Name the name of the task
+	Name string
+
+	// Output the output of the task
+	Output string
+
+	// ExitCode the exit code of the command or 0 if the task is a function
+	ExitCode int
+
+	// Err the error of the task if it failed, panicked or was skipped
+	Err error
+
+	// Skipped true if the task was never started as the context was done or an earlier task failed fast
+	Skipped bool
+
+	// Duration how long the task took
+	Duration time.Duration
+}
+
+// PanicError a task panicked
+type PanicError struct {
+	// Name the name of the task
+	Name string
+
+	// Value the value passed to panic
+	Value interface{}
+
+	// Stack the stack trace of the panic
+	Stack []byte
+}
+
+// Error returns the error message
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task %s panicked: %v", e.Name, e.Value)
+}
+
+// ParallelOption an option for RunParallel
+type ParallelOption func(o *parallelOptions)
+
+type parallelOptions struct {
+	failFast        bool
+	completionOrder bool
+}
+
+// WithFailFast cancels the running tasks and skips the remaining tasks as soon as a task fails.
+// By default all the tasks are run and every result is collected
+func WithFailFast() ParallelOption {
+	return func(o *parallelOptions) {
+		o.failFast = true
+	}
+}
+
+// WithCompletionOrder returns the results in the order the tasks completed followed by the skipped tasks.
+// By default the results are returned in the order of the tasks
+func WithCompletionOrder() ParallelOption {
+	return func(o *parallelOptions) {
+		o.completionOrder = true
+	}
+}
+
+// RunParallel runs the tasks using at most the given number of concurrent workers returning a result for every
+// task. If the concurrency is not positive the number of CPUs is used. When the context is done no more tasks
+// are started and the context passed to the running tasks is done so that their commands are killed
+func RunParallel(ctx context.Context, concurrency int, tasks []Task, opts ...ParallelOption) []Result {
+	o := &parallelOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]Result, len(tasks))
+	done := make([]bool, len(tasks))
+	var order []int
+	var lock sync.Mutex
+	record := func(r Result) {
+		lock.Lock()
+		defer lock.Unlock()
+		results[r.Index] = r
+		done[r.Index] = true
+		order = append(order, r.Index)
+		if r.Err != nil && !r.Skipped && o.failFast {
+			cancel()
+		}
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// lets not start tasks received after the context was done
+				if ctx.Err() != nil {
+					record(skippedResult(ctx, i, tasks[i]))
+					continue
+				}
+				record(runTask(ctx, i, tasks[i]))
+			}
+		}()
+	}
+schedule:
+	for i := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := range tasks {
+		if !done[i] {
+			results[i] = skippedResult(ctx, i, tasks[i])
+			order = append(order, i)
+		}
+	}
+	if !o.completionOrder {
+		return results
+	}
+	answer := make([]Result, 0, len(results))
+	for _, i := range order {
+		answer = append(answer, results[i])
+	}
+	return answer
+}
+
+// runTask runs the task converting any panic into a PanicError
+func runTask(ctx context.Context, index int, t Task) (r Result) {
+	r.Index = index
+	r.Name = taskName(t)
+	start := time.Now()
+	defer func() {
+		if v := recover(); v != nil {
+			r.Err = &PanicError{Name: r.Name, Value: v, Stack: debug.Stack()}
+		}
+		r.Duration = time.Since(start)
+	}()
+
+	switch {
+	case t.Func != nil:
+		r.Output, r.Err = t.Func(ctx)
+	case t.Command != nil:
+		runner := t.Runner
+		if runner == nil {
+			runner = RunCommandResult
+		}
+		var result *CommandResult
+		result, r.Err = runner(ctx, t.Command)
+		r.Output = result.Output()
+		if result != nil {
+			r.ExitCode = result.ExitCode
+		}
+	default:
+		r.Err = errors.Errorf("task %s has no command or function", r.Name)
+	}
+	return r
+}
+
+func skippedResult(ctx context.Context, index int, t Task) Result {
+	err := ctx.Err()
+	if err == nil {
+		err = context.Canceled
+	}
+	return Result{
+		Index:   index,
+		Name:    taskName(t),
+		Err:     errors.Wrapf(err, "skipped task %s", taskName(t)),
+		Skipped: true,
+	}
+}
+
+func taskName(t Task) string {
+	if t.Name == "" && t.Command != nil {
+		return cmdrunner.CLI(t.Command)
+	}
+	return t.Name
+}
@@ -0,0 +1,258 @@
+package common_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunParallelBoundedAndOrdered(t *testing.T) {
+	var running, maxRunning int32
+	var tasks []common.Task
+	for i := 0; i < 10; i++ {
+		i := i
+		tasks = append(tasks, common.Task{
+			Name: "task-" + strconv.Itoa(i),
+			Func: func(ctx context.Context) (string, error) {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				// lets make the later tasks finish first
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				return fmt.Sprintf("output %d", i), nil
+			},
+		})
+	}
+
+	results := common.RunParallel(context.Background(), 3, tasks)
+	require.Len(t, results, 10, "results")
+	for i, r := range results {
+		assert.Equal(t, i, r.Index, "index of result %d", i)
+		assert.Equal(t, "task-"+strconv.Itoa(i), r.Name, "name of result %d", i)
+		assert.Equal(t, fmt.Sprintf("output %d", i), r.Output, "output of result %d", i)
+		assert.NoError(t, r.Err, "error of result %d", i)
+		assert.False(t, r.Skipped, "result %d should not be skipped", i)
+	}
+	assert.LessOrEqual(t, int(maxRunning), 3, "the concurrency should be bounded")
+	assert.Greater(t, int(maxRunning), 1, "the tasks should run in parallel")
+}
+
+func TestRunParallelConcurrencyOneIsSerial(t *testing.T) {
+	var lock sync.Mutex
+	var executed []int
+	newTasks := func() []common.Task {
+		var tasks []common.Task
+		for i := 0; i < 5; i++ {
+			i := i
+			tasks = append(tasks, common.Task{
+				Func: func(ctx context.Context) (string, error) {
+					lock.Lock()
+					executed = append(executed, i)
+					lock.Unlock()
+					if i == 2 {
+						return "", errors.Errorf("task %d failed", i)
+					}
+					return strconv.Itoa(i), nil
+				},
+			})
+		}
+		return tasks
+	}
+
+	// the serial equivalent
+	var expected []string
+	for _, task := range newTasks() {
+		output, err := task.Func(context.Background())
+		if err != nil {
+			output = err.Error()
+		}
+		expected = append(expected, output)
+	}
+	executed = nil
+
+	results := common.RunParallel(context.Background(), 1, newTasks(), common.WithCompletionOrder())
+	var actual []string
+	for _, r := range results {
+		if r.Err != nil {
+			actual = append(actual, r.Err.Error())
+			continue
+		}
+		actual = append(actual, r.Output)
+	}
+	assert.Equal(t, expected, actual, "results should be the same as running the tasks serially")
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, executed, "the tasks should run in order")
+}
+
+func TestRunParallelCompletionOrder(t *testing.T) {
+	tasks := []common.Task{
+		{Name: "slow", Func: sleepTask(50 * time.Millisecond)},
+		{Name: "fast", Func: sleepTask(0)},
+	}
+	results := common.RunParallel(context.Background(), 2, tasks, common.WithCompletionOrder())
+	require.Len(t, results, 2, "results")
+	assert.Equal(t, "fast", results[0].Name, "the fastest task should be first")
+	assert.Equal(t, 1, results[0].Index, "the index of the task")
+	assert.Equal(t, "slow", results[1].Name, "the slowest task should be last")
+}
+
+func TestRunParallelFailFast(t *testing.T) {
+	var started int32
+	cause := errors.New("boom")
+	newTasks := func() []common.Task {
+		var tasks []common.Task
+		for i := 0; i < 5; i++ {
+			i := i
+			tasks = append(tasks, common.Task{
+				Name: strconv.Itoa(i),
+				Func: func(ctx context.Context) (string, error) {
+					atomic.AddInt32(&started, 1)
+					if i == 1 {
+						return "", cause
+					}
+					return "", nil
+				},
+			})
+		}
+		return tasks
+	}
+
+	results := common.RunParallel(context.Background(), 1, newTasks(), common.WithFailFast())
+	require.Len(t, results, 5, "results")
+	assert.Equal(t, int32(2), started, "no tasks should start after the failure")
+	assert.NoError(t, results[0].Err, "first task")
+	assert.Equal(t, cause, results[1].Err, "failed task")
+	for _, r := range results[2:] {
+		assert.True(t, r.Skipped, "task %s should be skipped", r.Name)
+		assert.True(t, errors.Is(r.Err, context.Canceled), "task %s should be canceled", r.Name)
+	}
+
+	// by default all the tasks are run
+	started = 0
+	results = common.RunParallel(context.Background(), 1, newTasks())
+	assert.Equal(t, int32(5), started, "all the tasks should start")
+	for _, r := range results {
+		assert.False(t, r.Skipped, "task %s should not be skipped", r.Name)
+	}
+}
+
+func TestRunParallelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{}, 2)
+	blockUntilDone := func(ctx context.Context) (string, error) {
+		running <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	tasks := []common.Task{
+		{Name: "a", Func: blockUntilDone},
+		{Name: "b", Func: blockUntilDone},
+		{Name: "c", Func: blockUntilDone},
+		{Name: "d", Func: blockUntilDone},
+	}
+	go func() {
+		<-running
+		<-running
+		cancel()
+	}()
+
+	resultsCh := make(chan []common.Result)
+	go func() {
+		resultsCh <- common.RunParallel(ctx, 2, tasks)
+	}()
+	var results []common.Result
+	select {
+	case results = <-resultsCh:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "the running tasks should have been canceled")
+	}
+
+	require.Len(t, results, 4, "results")
+	for _, r := range results[:2] {
+		assert.False(t, r.Skipped, "running task %s should not be skipped", r.Name)
+		assert.True(t, errors.Is(r.Err, context.Canceled), "running task %s should see the cancellation", r.Name)
+	}
+	for _, r := range results[2:] {
+		assert.True(t, r.Skipped, "task %s should not have been started", r.Name)
+		assert.True(t, errors.Is(r.Err, context.Canceled), "task %s should be canceled", r.Name)
+	}
+
+	// an already done context should skip every task
+	results = common.RunParallel(ctx, 2, tasks)
+	for _, r := range results {
+		assert.True(t, r.Skipped, "task %s should be skipped", r.Name)
+	}
+}
+
+func TestRunParallelPanic(t *testing.T) {
+	tasks := []common.Task{
+		{Name: "ok", Func: sleepTask(0)},
+		{Name: "panics", Func: func(ctx context.Context) (string, error) {
+			var m map[string]string
+			m["boom"] = "bang"
+			return "", nil
+		}},
+		{Name: "after", Func: sleepTask(0)},
+	}
+	results := common.RunParallel(context.Background(), 1, tasks)
+	require.Len(t, results, 3, "results")
+	assert.NoError(t, results[0].Err, "first task")
+	assert.NoError(t, results[2].Err, "the tasks after the panic should still run")
+
+	var panicErr *common.PanicError
+	require.True(t, errors.As(results[1].Err, &panicErr), "should be a panic error but was %v", results[1].Err)
+	assert.Equal(t, "panics", panicErr.Name, "name of the task")
+	assert.Contains(t, panicErr.Error(), "assignment to entry in nil map", "message")
+	assert.NotEmpty(t, panicErr.Stack, "stack trace")
+}
+
+func TestRunParallelCommands(t *testing.T) {
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{Name: "kpt", Args: []string{"pkg", "get", "a"}, Output: "fetched a"},
+		testhelpers.Expectation{Name: "kpt", Args: []string{"pkg", "get", "b"}, Output: "failed b", Error: os.ErrNotExist},
+	)
+	resultRunner := common.FromCommandRunner(runner.Run)
+	tasks := []common.Task{
+		{Command: &cmdrunner.Command{Name: "kpt", Args: []string{"pkg", "get", "a"}}, Runner: resultRunner},
+		{Command: &cmdrunner.Command{Name: "kpt", Args: []string{"pkg", "get", "b"}}, Runner: resultRunner},
+		{Name: "empty"},
+	}
+	results := common.RunParallel(context.Background(), 0, tasks)
+	runner.Verify(t)
+	require.Len(t, results, 3, "results")
+
+	assert.Equal(t, "kpt pkg get a", results[0].Name, "name defaults to the command line")
+	assert.Equal(t, "fetched a", results[0].Output, "output")
+	assert.NoError(t, results[0].Err, "error")
+
+	assert.Equal(t, "failed b", results[1].Output, "output of the failed command")
+	assert.Equal(t, 1, results[1].ExitCode, "exit code of the failed command")
+	assert.True(t, errors.Is(results[1].Err, os.ErrNotExist), "error of the failed command")
+
+	assert.Error(t, results[2].Err, "a task without a command or function should fail")
+
+	assert.Empty(t, common.RunParallel(context.Background(), 2, nil), "no tasks")
+}
+
+func sleepTask(d time.Duration) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		time.Sleep(d)
+		return "", nil
+	}
+}
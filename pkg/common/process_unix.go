@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group so that it can be killed along with its descendants
+func setProcessGroup(e *exec.Cmd) {
+	if e.SysProcAttr == nil {
+		e.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	e.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup kills the process group of the command
+func killProcessGroup(e *exec.Cmd) error {
+	if e.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-e.Process.Pid, syscall.SIGKILL)
+}
+
+// forwardInterrupts forwards any SIGINT received by this process to the process group of the started command
+// as the group no longer receives the signals sent by the terminal. The returned function stops forwarding and
+// then raises any SIGINT which was received so that this process is interrupted as it would have been
+func forwardInterrupts(e *exec.Cmd) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT)
+	done := make(chan bool)
+	interrupted := make(chan bool, 1)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				_ = syscall.Kill(-e.Process.Pid, syscall.SIGINT)
+				select {
+				case interrupted <- true:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		select {
+		case <-interrupted:
+			_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+		default:
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+package common_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const processGroupHelperEnv = "JX_GITOPS_PROCESS_GROUP_HELPER"
+
+// TestProcessGroupHelperProcess is not a real test; it is the child process run by TestProcessGroup which
+// prints its process group
+func TestProcessGroupHelperProcess(t *testing.T) {
+	if os.Getenv(processGroupHelperEnv) == "" {
+		return
+	}
+	fmt.Printf("%d\n", syscall.Getpgrp())
+	os.Exit(0)
+}
+
+func TestProcessGroup(t *testing.T) {
+	pgid := syscall.Getpgrp()
+
+	childGroup := func(ctx context.Context) int {
+		c := &cmdrunner.Command{
+			Name: os.Args[0],
+			Args: []string{"-test.run=TestProcessGroupHelperProcess"},
+			Env:  map[string]string{processGroupHelperEnv: "true"},
+		}
+		result, err := common.RunCommandResult(ctx, c)
+		require.NoError(t, err, "failed to run %s", c.CLI())
+		answer, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+		require.NoError(t, err, "failed to parse the process group from output %s", result.Stdout)
+		return answer
+	}
+
+	// a command which cannot be killed stays in our process group so that it receives any SIGINT from the terminal
+	assert.Equal(t, pgid, childGroup(context.Background()), "process group without a deadline or cancel")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NotEqual(t, pgid, childGroup(ctx), "process group with a cancel")
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package common
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on windows
+func setProcessGroup(e *exec.Cmd) {
+}
+
+// killProcessGroup kills the process of the command. This is best effort on windows as any descendants are not killed
+func killProcessGroup(e *exec.Cmd) error {
+	if e.Process == nil {
+		return nil
+	}
+	return e.Process.Kill()
+}
+
+// forwardInterrupts does nothing on windows as the command is not started in its own process group
+func forwardInterrupts(e *exec.Cmd) func() {
+	return func() {}
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+)
+
+// CommandResult the result of running a command
+type CommandResult struct {
+	// Stdout the standard output of the command
+	Stdout string
+
+	// Stderr the standard error of the command
+	Stderr string
+
+	// ExitCode the exit code of the command or -1 if it did not exit normally such as if it was killed
+	ExitCode int
+
+	// Duration how long the command took
+	Duration time.Duration
+}
+
+// Output returns the trimmed standard output followed by the trimmed standard error
+func (r *CommandResult) Output() string {
+	if r == nil {
+		return ""
+	}
+	var lines []string
+	for _, text := range []string{r.Stdout, r.Stderr} {
+		text = strings.TrimSpace(text)
+		if text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ResultCommandRunner runs a command returning a structured result. The command is killed if the context is done
+// before it completes
+type ResultCommandRunner func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error)
+
+// RunCommandResult runs the command capturing the standard output and error separately. If the context is done
+// before the command completes then the command and its descendants are killed and any partial output is returned
+func RunCommandResult(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+	stdoutBuf := &syncBuffer{}
+	stderrBuf := &syncBuffer{}
+	var stdout, stderr io.Writer = stdoutBuf, stderrBuf
+	if c.Out != nil {
+		stdout = io.MultiWriter(stdoutBuf, c.Out)
+	}
+	if c.Err != nil {
+		stderr = io.MultiWriter(stderrBuf, c.Err)
+	}
+
+	e := exec.Command(c.Name, c.Args...) // #nosec
+	e.Dir = c.Dir
+	e.Env = Environ(c.Env)
+	e.Stdout = stdout
+	e.Stderr = stderr
+	if c.In != nil {
+		e.Stdin = c.In
+	}
+	start := time.Now()
+	err := runProcess(ctx, e)
+	result := &CommandResult{
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		ExitCode: ExitCode(err),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		return result, NewCommandError(c, result, err)
+	}
+	return result, nil
+}
+
+// ExitCode returns the exit code of the process for the error. Returns 0 if there is no error, the exit code of
+// the process if it exited with an error or -1 otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// FromCommandRunner adapts a command runner so it can be used as a ResultCommandRunner with the output of the
+// runner used as the standard output. The runner cannot be killed so it is left running in the background if
+// the context is done first
+func FromCommandRunner(runner cmdrunner.CommandRunner) ResultCommandRunner {
+	return func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+		start := time.Now()
+		output, err := WithContext(runner)(ctx, c)
+		exitCode := ExitCode(err)
+		if err != nil && exitCode < 0 && ctx.Err() == nil {
+			exitCode = 1
+		}
+		return &CommandResult{
+			Stdout:   output,
+			ExitCode: exitCode,
+			Duration: time.Since(start),
+		}, err
+	}
+}
+
+// ToCommandRunner adapts the runner so it can be used as a command runner which returns the output
+func ToCommandRunner(runner ResultCommandRunner) cmdrunner.CommandRunner {
+	return func(c *cmdrunner.Command) (string, error) {
+		result, err := runner(context.Background(), c)
+		return result.Output(), err
+	}
+}
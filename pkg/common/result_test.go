@@ -0,0 +1,87 @@
+package common_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const resultHelperEnv = "JX_GITOPS_RESULT_HELPER"
+
+// TestResultHelperProcess is not a real test; it is the child process run by the result tests.
+// It writes to both standard output and error then exits with the exit code from the environment variable
+func TestResultHelperProcess(t *testing.T) {
+	exitCode := os.Getenv(resultHelperEnv)
+	if exitCode == "" {
+		return
+	}
+	fmt.Println(`{"version": "1.0.0"}`)
+	fmt.Fprintln(os.Stderr, "warning: deprecated flag")
+	code, _ := strconv.Atoi(exitCode)
+	os.Exit(code)
+}
+
+func TestRunCommandResult(t *testing.T) {
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestResultHelperProcess"},
+		Env:  map[string]string{resultHelperEnv: "0"},
+	}
+	result, err := common.RunCommandResult(context.Background(), c)
+	require.NoError(t, err, "failed to run command")
+	require.NotNil(t, result)
+
+	assert.Equal(t, "{\"version\": \"1.0.0\"}\n", result.Stdout, "stdout")
+	assert.Equal(t, "warning: deprecated flag\n", result.Stderr, "stderr")
+	assert.Equal(t, 0, result.ExitCode, "exit code")
+	assert.True(t, result.Duration > 0, "duration")
+	assert.Equal(t, "{\"version\": \"1.0.0\"}\nwarning: deprecated flag", result.Output(), "output")
+}
+
+func TestRunCommandResultNonZeroExit(t *testing.T) {
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestResultHelperProcess"},
+		Env:  map[string]string{resultHelperEnv: "3"},
+	}
+	result, err := common.RunCommandResult(context.Background(), c)
+	require.Error(t, err, "should have failed")
+	require.NotNil(t, result)
+
+	assert.Equal(t, 3, result.ExitCode, "exit code")
+	assert.Equal(t, 3, common.ExitCode(err), "exit code of the error")
+	assert.Equal(t, "warning: deprecated flag\n", result.Stderr, "stderr")
+	assert.Contains(t, err.Error(), "warning: deprecated flag", "error should include the output")
+
+	var commandErr *common.CommandError
+	require.True(t, errors.As(err, &commandErr), "should be a command error")
+	assert.Equal(t, 3, commandErr.ExitCode, "exit code of the command error")
+	assert.Equal(t, "{\"version\": \"1.0.0\"}\nwarning: deprecated flag", commandErr.Output, "output of the command error")
+	assert.True(t, common.IsCommandError("", errors.Wrap(err, "failed to get version")), "should be retryable")
+	assert.False(t, common.IsCommandError("", errors.New("invalid Kptfile")), "should not be retryable")
+
+	// the string runner wrapper returns the combined output
+	output, err := common.ToCommandRunner(common.RunCommandResult)(c)
+	require.Error(t, err, "should have failed")
+	assert.Equal(t, "{\"version\": \"1.0.0\"}\nwarning: deprecated flag", output, "output")
+}
+
+func TestFromCommandRunner(t *testing.T) {
+	runner := common.FromCommandRunner(func(c *cmdrunner.Command) (string, error) {
+		return "failed to clone", errors.New("exit status 1")
+	})
+	result, err := runner(context.Background(), cmdrunner.NewCommand("", "kpt", "pkg", "get"))
+	require.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "failed to clone", result.Stdout, "stdout")
+	assert.Equal(t, 1, result.ExitCode, "exit code")
+	assert.Equal(t, "", (*common.CommandResult)(nil).Output(), "output of a nil result")
+}
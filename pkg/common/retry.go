@@ -0,0 +1,83 @@
+package common
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// RetryOptions the options for retrying commands
+type RetryOptions struct {
+	// MaxAttempts the maximum number of times a command is run. Defaults to 3
+	MaxAttempts int
+
+	// InitialBackoff the delay before the first retry which doubles on each subsequent retry. Defaults to 1 second
+	InitialBackoff time.Duration
+
+	// MaxBackoff the maximum delay between retries. Defaults to 30 seconds
+	MaxBackoff time.Duration
+
+	// Jitter the fraction of the delay which is randomly added or removed such as 0.2 for +/- 20%
+	Jitter float64
+
+	// Retryable returns true if the failed command should be retried. If nil every error is retried
+	Retryable func(output string, err error) bool
+
+	// Sleep sleeps for the given duration. Defaults to time.Sleep and can be replaced in tests
+	Sleep func(time.Duration)
+}
+
+// NewRetryingCommandRunner creates a command runner which retries failed commands using the next runner
+func NewRetryingCommandRunner(next cmdrunner.CommandRunner, opts RetryOptions) cmdrunner.CommandRunner {
+	if next == nil {
+		next = cmdrunner.DefaultCommandRunner
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.Sleep == nil {
+		opts.Sleep = time.Sleep
+	}
+	return func(c *cmdrunner.Command) (string, error) {
+		delay := opts.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			output, err := next(c)
+			if err == nil {
+				return output, nil
+			}
+			if opts.Retryable != nil && !opts.Retryable(output, err) {
+				return output, err
+			}
+			if attempt >= opts.MaxAttempts {
+				return output, errors.Wrapf(err, "failed after %d attempts", attempt)
+			}
+			wait := opts.jitter(delay)
+			log.Logger().Warnf("attempt %d of %d to run %s failed, retrying in %s: %s", attempt, opts.MaxAttempts, termcolor.ColorInfo(cmdrunner.CLI(c)), wait.String(), err.Error())
+			opts.Sleep(wait)
+
+			delay *= 2
+			if delay > opts.MaxBackoff {
+				delay = opts.MaxBackoff
+			}
+		}
+	}
+}
+
+func (o *RetryOptions) jitter(delay time.Duration) time.Duration {
+	if o.Jitter <= 0 {
+		return delay
+	}
+	// #nosec
+	f := 1 + o.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * f)
+}
@@ -0,0 +1,84 @@
+package common_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryingCommandRunnerSuccessAfterRetry(t *testing.T) {
+	fake := testhelpers.NewFakeCommandRunner()
+	fake.Ordered = true
+	fake.Expect(
+		testhelpers.Expectation{Name: "kpt", Output: "connection reset", Error: errors.New("exit status 1")},
+		testhelpers.Expectation{Name: "kpt", Output: "connection reset", Error: errors.New("exit status 1")},
+		testhelpers.Expectation{Name: "kpt", Output: "fetched"},
+	)
+
+	var delays []time.Duration
+	runner := common.NewRetryingCommandRunner(fake.Run, common.RetryOptions{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second + time.Second/2,
+		Sleep: func(d time.Duration) {
+			delays = append(delays, d)
+		},
+	})
+
+	output, err := runner(cmdrunner.NewCommand("", "kpt", "pkg", "get"))
+	require.NoError(t, err, "should have succeeded after retrying")
+	assert.Equal(t, "fetched", output)
+	assert.Equal(t, []time.Duration{time.Second, time.Second + time.Second/2}, delays, "delays should be capped by the max backoff")
+	fake.Verify(t)
+}
+
+func TestRetryingCommandRunnerExhausted(t *testing.T) {
+	fake := testhelpers.NewFakeCommandRunner()
+	for i := 0; i < 3; i++ {
+		fake.Expect(testhelpers.Expectation{Name: "kpt", Output: "timeout", Error: errors.New("exit status 1")})
+	}
+
+	sleeps := 0
+	runner := common.NewRetryingCommandRunner(fake.Run, common.RetryOptions{
+		MaxAttempts: 3,
+		Jitter:      0.5,
+		Sleep: func(d time.Duration) {
+			assert.True(t, d >= time.Second/2 && d <= 3*time.Second, "delay %s should be within the jitter", d.String())
+			sleeps++
+		},
+	})
+
+	output, err := runner(cmdrunner.NewCommand("", "kpt", "pkg", "get"))
+	require.Error(t, err, "should have failed")
+	assert.Equal(t, "timeout", output)
+	assert.Contains(t, err.Error(), "failed after 3 attempts")
+	assert.Equal(t, 2, sleeps, "sleeps")
+	fake.Verify(t)
+}
+
+func TestRetryingCommandRunnerNotRetryable(t *testing.T) {
+	fake := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{Name: "kpt", Output: "error: not found", Error: errors.New("exit status 1")},
+	)
+
+	runner := common.NewRetryingCommandRunner(fake.Run, common.RetryOptions{
+		Retryable: func(output string, err error) bool {
+			return !strings.Contains(output, "not found")
+		},
+		Sleep: func(d time.Duration) {
+			assert.Fail(t, "should not have retried")
+		},
+	})
+
+	_, err := runner(cmdrunner.NewCommand("", "kpt", "pkg", "get"))
+	require.Error(t, err, "should have failed")
+	assert.Equal(t, "exit status 1", err.Error(), "should return the original error")
+	fake.Verify(t)
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// FindRoot if enabled the commands whose --dir flag is not specified use the root directory of the git repository.
+// Populated by the --find-root flag
+var FindRoot bool
+
+// AddFindRootFlags adds the persistent --find-root flag to the root command
+func AddFindRootFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVarP(&FindRoot, "find-root", "", false, "if the --dir flag is not specified then the root directory of the git repository containing the current directory is used like git does")
+}
+
+// FindRepositoryRoot returns the closest directory to the given directory or one of its parents which contains
+// a .git directory or file. Returns blank if the directory is not inside a git repository
+func FindRepositoryRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find abs dir of %s", dir)
+	}
+	for {
+		path := filepath.Join(dir, ".git")
+		_, err := os.Lstat(path)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", errors.Wrapf(err, "failed to check if %s exists", path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// DefaultDirToRoot if FindRoot is enabled and the command has a --dir flag which defaults to the current directory
+// and is not specified then the flag is set to the root directory of the git repository. The flag is left unchanged
+// if the current directory is not inside a git repository
+func DefaultDirToRoot(cmd *cobra.Command) error {
+	if !FindRoot {
+		return nil
+	}
+	flag := cmd.Flags().Lookup("dir")
+	if flag == nil || flag.Changed || flag.DefValue != "." {
+		return nil
+	}
+	root, err := FindRepositoryRoot(".")
+	if err != nil {
+		return errors.Wrap(err, "failed to find the root directory of the git repository")
+	}
+	if root == "" {
+		log.Logger().Debugf("could not find the root directory of a git repository so using the current directory")
+		return nil
+	}
+	log.Logger().Debugf("using the root directory of the git repository %s", root)
+	err = flag.Value.Set(root)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set the --dir flag to %s", root)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package common_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRepositoryRoot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err, "failed to resolve temp dir")
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	subDir := filepath.Join(repoDir, "config-root", "namespaces")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0700))
+	require.NoError(t, os.MkdirAll(subDir, 0700))
+
+	// worktrees and submodules use a .git file
+	worktreeDir := filepath.Join(tmpDir, "worktree")
+	require.NoError(t, os.MkdirAll(filepath.Join(worktreeDir, "charts"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(worktreeDir, ".git"), []byte("gitdir: ../repo/.git/worktrees/worktree\n"), 0600))
+
+	testCases := []struct {
+		dir      string
+		expected string
+	}{
+		{subDir, repoDir},
+		{repoDir, repoDir},
+		{filepath.Join(worktreeDir, "charts"), worktreeDir},
+	}
+	for _, tc := range testCases {
+		root, err := common.FindRepositoryRoot(tc.dir)
+		require.NoError(t, err, "failed to find root of %s", tc.dir)
+		assert.Equal(t, tc.expected, root, "root of %s", tc.dir)
+	}
+}
+
+func TestDefaultDirToRoot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err, "failed to resolve temp dir")
+
+	subDir := filepath.Join(tmpDir, "config-root")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, ".git"), 0700))
+	require.NoError(t, os.MkdirAll(subDir, 0700))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err, "failed to get the current dir")
+	require.NoError(t, os.Chdir(subDir))
+	t.Cleanup(func() {
+		common.FindRoot = false
+		_ = os.Chdir(wd)
+	})
+
+	newCmd := func() (*cobra.Command, *string) {
+		dir := ""
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().StringVarP(&dir, "dir", "d", ".", "the directory")
+		return cmd, &dir
+	}
+
+	common.FindRoot = false
+	cmd, dir := newCmd()
+	require.NoError(t, common.DefaultDirToRoot(cmd))
+	assert.Equal(t, ".", *dir, "the dir should be unchanged if --find-root is not specified")
+
+	common.FindRoot = true
+	cmd, dir = newCmd()
+	require.NoError(t, common.DefaultDirToRoot(cmd))
+	assert.Equal(t, tmpDir, *dir, "the dir should default to the repository root")
+
+	cmd, dir = newCmd()
+	require.NoError(t, cmd.Flags().Set("dir", "charts"))
+	require.NoError(t, common.DefaultDirToRoot(cmd))
+	assert.Equal(t, "charts", *dir, "an explicit dir should be used")
+}
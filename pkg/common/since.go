@@ -0,0 +1,121 @@
+package common
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// SinceOptions the options for only processing the files changed since a git ref
+type SinceOptions struct {
+	// Since the git ref such as 'origin/main' or 'HEAD~1' the files must have changed since. Blank means all files
+	Since string
+}
+
+// AddFlags adds the since flags to the command
+func (o *SinceOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Since, "since", "", "", "if specified only the files changed since the given git ref such as 'origin/main' or 'HEAD~1' are processed. If the ref does not exist all the files are processed")
+}
+
+// ChangedFiles returns the files in the given directory which have changed since the git ref including any
+// untracked files. Nil is returned if no ref is specified or the ref does not exist so that all the files are
+// processed. If the runner is nil then cmdrunner.QuietCommandRunner is used
+func (o *SinceOptions) ChangedFiles(runner cmdrunner.CommandRunner, dir string) (*ChangedFiles, error) {
+	if o.Since == "" {
+		return nil, nil
+	}
+	if runner == nil {
+		runner = cmdrunner.QuietCommandRunner
+	}
+	_, err := runner(&cmdrunner.Command{
+		Name: "git",
+		Args: []string{"rev-parse", "--verify", "--quiet", o.Since + "^{commit}"},
+		Dir:  dir,
+	})
+	if err != nil {
+		log.Logger().Warnf("processing all the files in dir %s as the git ref %s does not exist: %s", dir, termcolor.ColorInfo(o.Since), err.Error())
+		return nil, nil
+	}
+
+	changed := &ChangedFiles{
+		Ref:   o.Since,
+		Files: map[string]bool{},
+	}
+	commands := [][]string{
+		{"diff", "--name-only", "--relative", o.Since, "--"},
+		{"ls-files", "--others", "--exclude-standard"},
+	}
+	for _, args := range commands {
+		c := &cmdrunner.Command{
+			Name: "git",
+			Args: args,
+			Dir:  dir,
+		}
+		text, err := runner(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find the files changed since %s in dir %s", o.Since, dir)
+		}
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				changed.Files[filepath.ToSlash(filepath.Clean(line))] = true
+			}
+		}
+	}
+	log.Logger().Infof("found %d files changed since %s in dir %s", len(changed.Files), termcolor.ColorInfo(o.Since), termcolor.ColorInfo(dir))
+	return changed, nil
+}
+
+// ChangedFiles the files which have changed since a git ref. A nil value contains every file
+type ChangedFiles struct {
+	// Ref the git ref the files have changed since
+	Ref string
+
+	// Files the slash separated paths of the changed files relative to the directory
+	Files map[string]bool
+}
+
+// Contains returns true if the file with the given path relative to the directory has changed
+func (c *ChangedFiles) Contains(rel string) bool {
+	if c == nil {
+		return true
+	}
+	return c.Files[filepath.ToSlash(filepath.Clean(rel))]
+}
+
+// ContainsDir returns true if any file in the directory tree with the given path relative to the directory
+// has changed
+func (c *ChangedFiles) ContainsDir(rel string) bool {
+	if c == nil {
+		return true
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." {
+		return len(c.Files) > 0
+	}
+	for f := range c.Files {
+		if f == rel || strings.HasPrefix(f, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths returns the sorted paths of the changed files
+func (c *ChangedFiles) Paths() []string {
+	if c == nil {
+		return nil
+	}
+	answer := make([]string, 0, len(c.Files))
+	for f := range c.Files {
+		answer = append(answer, f)
+	}
+	sort.Strings(answer)
+	return answer
+}
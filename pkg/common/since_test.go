@@ -0,0 +1,68 @@
+package common_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinceChangedFiles(t *testing.T) {
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"rev-parse", "--verify", "--quiet", "HEAD~1^{commit}"},
+		},
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"diff", "--name-only", "--relative", "HEAD~1", "--"},
+			Output: "config-root/namespaces/jx/lighthouse/Kptfile\nREADME.md\n",
+		},
+		testhelpers.Expectation{
+			Name:   "git",
+			Args:   []string{"ls-files", "--others", "--exclude-standard"},
+			Output: "config-root/namespaces/jx/new.yaml\n",
+		},
+	)
+	o := common.SinceOptions{Since: "HEAD~1"}
+	changed, err := o.ChangedFiles(runner.Run, "my-dir")
+	require.NoError(t, err, "failed to find the changed files")
+	runner.Verify(t)
+	for _, c := range runner.Invocations() {
+		assert.Equal(t, "my-dir", c.Dir, "dir of %s", c.CLI())
+	}
+
+	assert.Equal(t, []string{"README.md", "config-root/namespaces/jx/lighthouse/Kptfile", "config-root/namespaces/jx/new.yaml"}, changed.Paths(), "paths")
+	assert.True(t, changed.Contains("README.md"), "changed file")
+	assert.True(t, changed.Contains("config-root/namespaces/jx/new.yaml"), "untracked file")
+	assert.False(t, changed.Contains("config-root/namespaces/jx/other.yaml"), "unchanged file")
+	assert.True(t, changed.ContainsDir("config-root/namespaces/jx/lighthouse"), "changed package")
+	assert.True(t, changed.ContainsDir("."), "root dir")
+	assert.False(t, changed.ContainsDir("config-root/namespaces/jx/light"), "dir with a common prefix")
+	assert.False(t, changed.ContainsDir("config-root/namespaces/tekton"), "unchanged dir")
+}
+
+func TestSinceMissingRef(t *testing.T) {
+	runner := testhelpers.NewFakeCommandRunner().Expect(
+		testhelpers.Expectation{
+			Name:  "git",
+			Args:  []string{"rev-parse", "--verify", "--quiet", "does-not-exist^{commit}"},
+			Error: errors.New("exit status 1"),
+		},
+	)
+	o := common.SinceOptions{Since: "does-not-exist"}
+	changed, err := o.ChangedFiles(runner.Run, ".")
+	require.NoError(t, err, "should not fail if the ref does not exist")
+	runner.Verify(t)
+	assert.Nil(t, changed, "changed files")
+	assert.True(t, changed.Contains("any.yaml"), "all files should be processed")
+	assert.True(t, changed.ContainsDir("any"), "all dirs should be processed")
+
+	o.Since = ""
+	changed, err = o.ChangedFiles(nil, ".")
+	require.NoError(t, err, "failed without a ref")
+	assert.Nil(t, changed, "changed files without a ref")
+}
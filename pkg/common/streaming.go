@@ -0,0 +1,149 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+)
+
+// StreamingCommandRunner runs commands relaying each line of their output to the writers as it arrives
+// while still capturing the combined output so it can be returned
+type StreamingCommandRunner struct {
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Prefix an optional function to return the prefix written before each line of output for a command
+	Prefix func(c *cmdrunner.Command) string
+}
+
+// NewStreamingCommandRunner creates a new streaming command runner which relays output to the given writers
+func NewStreamingCommandRunner(stdout, stderr io.Writer) *StreamingCommandRunner {
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return &StreamingCommandRunner{Stdout: stdout, Stderr: stderr}
+}
+
+// Run runs the command streaming its output and returning the captured output
+func (r *StreamingCommandRunner) Run(c *cmdrunner.Command) (string, error) {
+	return r.RunContext(context.Background(), c)
+}
+
+// RunContext runs the command like Run but kills the command if the context is done before it completes
+func (r *StreamingCommandRunner) RunContext(ctx context.Context, c *cmdrunner.Command) (string, error) {
+	result, err := r.RunResult(ctx, c)
+	return result.Output(), err
+}
+
+// RunResult runs the command like RunContext returning the standard output and error separately
+func (r *StreamingCommandRunner) RunResult(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+	prefix := ""
+	if r.Prefix != nil {
+		prefix = r.Prefix(c)
+	}
+	lock := &sync.Mutex{}
+	stdout := &lineWriter{out: r.Stdout, prefix: prefix, captured: &bytes.Buffer{}, lock: lock}
+	stderr := &lineWriter{out: r.Stderr, prefix: prefix, captured: &bytes.Buffer{}, lock: lock}
+
+	e := exec.Command(c.Name, c.Args...) // #nosec
+	e.Dir = c.Dir
+	e.Env = Environ(c.Env)
+	e.Stdout = stdout
+	e.Stderr = stderr
+	if c.In != nil {
+		e.Stdin = c.In
+	}
+	start := time.Now()
+	err := runProcess(ctx, e)
+
+	stdout.Flush()
+	stderr.Flush()
+	result := &CommandResult{
+		Stdout:   stdout.captured.String(),
+		Stderr:   stderr.captured.String(),
+		ExitCode: ExitCode(err),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		return result, NewCommandError(c, result, err)
+	}
+	return result, nil
+}
+
+// Environ returns the environment of the current process with the given variables added or nil if there are none
+// so that the child process inherits the current environment
+func Environ(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	var answer []string
+	for _, kv := range os.Environ() {
+		k := strings.SplitN(kv, "=", 2)[0]
+		if _, ok := env[k]; !ok {
+			answer = append(answer, kv)
+		}
+	}
+	for k, v := range env {
+		answer = append(answer, k+"="+v)
+	}
+	return answer
+}
+
+// lineWriter writes each complete line to the output with a prefix and captures the raw output
+type lineWriter struct {
+	out      io.Writer
+	prefix   string
+	captured *bytes.Buffer
+	lock     *sync.Mutex
+	partial  []byte
+}
+
+// Write writes any complete lines to the output and buffers any partial line
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.captured.Write(p)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		err := w.writeLine(w.partial[:i+1])
+		if err != nil {
+			return 0, err
+		}
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any remaining partial line
+func (w *lineWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.partial) > 0 {
+		_ = w.writeLine(append(w.partial, '\n'))
+		w.partial = nil
+	}
+}
+
+func (w *lineWriter) writeLine(line []byte) error {
+	if w.prefix != "" {
+		line = append([]byte(w.prefix), line...)
+	}
+	_, err := w.out.Write(line)
+	return err
+}
@@ -0,0 +1,124 @@
+package common_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helperEnv = "JX_GITOPS_STREAMING_HELPER"
+
+// TestStreamingHelperProcess is not a real test; it is the fake slow child process run by the streaming tests.
+// It writes the first line then waits for the signal file to exist before writing the rest of its output
+func TestStreamingHelperProcess(t *testing.T) {
+	signalFile := os.Getenv(helperEnv)
+	if signalFile == "" {
+		return
+	}
+	fmt.Println("cloning")
+	for i := 0; i < 500; i++ {
+		if _, err := os.Stat(signalFile); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fmt.Fprintln(os.Stderr, "warning: slow")
+	fmt.Print("done")
+	os.Exit(0)
+}
+
+// chanWriter sends each write to a channel
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStreamingCommandRunner(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	signalFile := filepath.Join(tmpDir, "signal")
+
+	lines := make(chanWriter, 10)
+	r := common.NewStreamingCommandRunner(lines, lines)
+	r.Prefix = func(c *cmdrunner.Command) string {
+		return "myapp: "
+	}
+
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestStreamingHelperProcess"},
+		Env:  map[string]string{helperEnv: signalFile},
+	}
+
+	type result struct {
+		text string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		text, err := r.Run(c)
+		done <- result{text, err}
+	}()
+
+	select {
+	case line := <-lines:
+		assert.Equal(t, "myapp: cloning\n", line, "first line")
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for the first line")
+	}
+
+	select {
+	case <-done:
+		require.Fail(t, "the command completed before the first line was relayed")
+	default:
+	}
+
+	err = ioutil.WriteFile(signalFile, []byte("go"), 0600)
+	require.NoError(t, err, "failed to write signal file")
+
+	res := <-done
+	require.NoError(t, res.err, "failed to run command")
+	close(lines)
+
+	var rest []string
+	for line := range lines {
+		rest = append(rest, line)
+	}
+	assert.ElementsMatch(t, []string{"myapp: warning: slow\n", "myapp: done\n"}, rest, "remaining lines")
+	assert.True(t, strings.HasPrefix(res.text, "cloning\n"), "captured output %s", res.text)
+	assert.Contains(t, res.text, "warning: slow", "captured output")
+	assert.Contains(t, res.text, "done", "captured output")
+}
+
+func TestStreamingCommandRunnerFailure(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := common.NewStreamingCommandRunner(out, out)
+
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestDoesNotExist", "-test.badflag"},
+	}
+	text, err := r.Run(c)
+	require.Error(t, err, "should have failed")
+	assert.NotEmpty(t, text, "should have captured the output")
+	assert.Contains(t, err.Error(), text, "error should include the output")
+	assert.Contains(t, out.String(), "badflag", "should have relayed the output")
+
+	var commandErr *common.CommandError
+	require.True(t, errors.As(err, &commandErr), "should be a command error")
+	assert.NotEqual(t, 0, commandErr.ExitCode, "exit code")
+	assert.Equal(t, text, commandErr.Output, "output of the command error")
+}
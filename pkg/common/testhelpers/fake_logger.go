@@ -0,0 +1,46 @@
+package testhelpers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeLogger a logger which records the messages so that tests can assert on them
+type FakeLogger struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+// Debugf records a debug message
+func (l *FakeLogger) Debugf(format string, args ...interface{}) {
+	l.record("debug", format, args...)
+}
+
+// Infof records an info message
+func (l *FakeLogger) Infof(format string, args ...interface{}) {
+	l.record("info", format, args...)
+}
+
+// Warnf records a warning message
+func (l *FakeLogger) Warnf(format string, args ...interface{}) {
+	l.record("warn", format, args...)
+}
+
+// Messages returns the recorded messages prefixed with their level such as 'info: recreated app'
+func (l *FakeLogger) Messages() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string{}, l.messages...)
+}
+
+// String returns the recorded messages one per line
+func (l *FakeLogger) String() string {
+	return strings.Join(l.Messages(), "\n")
+}
+
+func (l *FakeLogger) record(level, format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,188 @@
+package testhelpers
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+)
+
+// Expectation an expected command invocation along with the canned results to return
+type Expectation struct {
+	// Name the exact command name to match if not blank
+	Name string
+
+	// Args the argument patterns to match if not nil. Each pattern can use '*' as a wildcard
+	Args []string
+
+	// Matcher an optional custom matcher
+	Matcher func(c *cmdrunner.Command) bool
+
+	// Output the canned output returned
+	Output string
+
+	// Error the canned error returned
+	Error error
+
+	// Callback invoked when the expectation matches to simulate side effects such as creating files.
+	// If it returns an error the error is returned from the runner
+	Callback func(c *cmdrunner.Command) error
+
+	matched bool
+}
+
+// Matches returns true if the expectation matches the command
+func (e *Expectation) Matches(c *cmdrunner.Command) bool {
+	if e.Name != "" && e.Name != c.Name {
+		return false
+	}
+	if e.Args != nil {
+		if len(e.Args) != len(c.Args) {
+			return false
+		}
+		for i, pattern := range e.Args {
+			if !MatchesPattern(pattern, c.Args[i]) {
+				return false
+			}
+		}
+	}
+	if e.Matcher != nil && !e.Matcher(c) {
+		return false
+	}
+	return true
+}
+
+// String returns a description of the expectation
+func (e *Expectation) String() string {
+	text := strings.TrimSpace(e.Name + " " + strings.Join(e.Args, " "))
+	if text == "" {
+		return "custom matcher"
+	}
+	return text
+}
+
+// FakeCommandRunner a fake command runner for testing which verifies the commands invoked
+// against a list of expectations
+type FakeCommandRunner struct {
+	// Ordered if enabled the commands must be invoked in the order of the expectations
+	Ordered bool
+
+	lock         sync.Mutex
+	expectations []*Expectation
+	invocations  []*cmdrunner.Command
+	unexpected   []*cmdrunner.Command
+}
+
+// NewFakeCommandRunner creates a new fake command runner
+func NewFakeCommandRunner() *FakeCommandRunner {
+	return &FakeCommandRunner{}
+}
+
+// Expect adds the expectations
+func (f *FakeCommandRunner) Expect(expectations ...Expectation) *FakeCommandRunner {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i := range expectations {
+		e := expectations[i]
+		f.expectations = append(f.expectations, &e)
+	}
+	return f
+}
+
+// ExpectCommand adds an expectation for the command name and argument patterns returning the given output
+func (f *FakeCommandRunner) ExpectCommand(output string, name string, args ...string) *FakeCommandRunner {
+	if args == nil {
+		args = []string{}
+	}
+	return f.Expect(Expectation{
+		Name:   name,
+		Args:   args,
+		Output: output,
+	})
+}
+
+// Run the command runner function which can be used as a cmdrunner.CommandRunner
+func (f *FakeCommandRunner) Run(c *cmdrunner.Command) (string, error) {
+	e := f.match(c)
+	if e == nil {
+		return "", errors.Errorf("unexpected command: %s", c.CLI())
+	}
+	if e.Callback != nil {
+		err := e.Callback(c)
+		if err != nil {
+			return e.Output, err
+		}
+	}
+	return e.Output, e.Error
+}
+
+func (f *FakeCommandRunner) match(c *cmdrunner.Command) *Expectation {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.invocations = append(f.invocations, c)
+	for _, e := range f.expectations {
+		if e.matched {
+			continue
+		}
+		if e.Matches(c) {
+			e.matched = true
+			return e
+		}
+		if f.Ordered {
+			break
+		}
+	}
+	f.unexpected = append(f.unexpected, c)
+	return nil
+}
+
+// Invocations returns all the commands invoked in order
+func (f *FakeCommandRunner) Invocations() []*cmdrunner.Command {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	answer := make([]*cmdrunner.Command, len(f.invocations))
+	copy(answer, f.invocations)
+	return answer
+}
+
+// Verify fails the test if there were any unexpected commands invoked or expectations not met
+func (f *FakeCommandRunner) Verify(t testing.TB) {
+	t.Helper()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, c := range f.invocations {
+		t.Logf("got command %s\n", c.CLI())
+	}
+	for _, c := range f.unexpected {
+		t.Errorf("unexpected command: %s", c.CLI())
+	}
+	for _, e := range f.expectations {
+		if !e.matched {
+			t.Errorf("expected command was not invoked: %s", e.String())
+		}
+	}
+}
+
+// MatchesPattern returns true if the text matches the pattern where '*' matches any characters
+func MatchesPattern(pattern string, text string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == text
+	}
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(text)
+}
@@ -0,0 +1,119 @@
+package testhelpers_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records test failures rather than failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Logf(format string, args ...interface{}) {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFakeCommandRunnerUnordered(t *testing.T) {
+	t.Parallel()
+
+	var callbackCommand *cmdrunner.Command
+	runner := testhelpers.NewFakeCommandRunner().
+		ExpectCommand("v1.2.3", "kpt", "version").
+		Expect(testhelpers.Expectation{
+			Name: "git",
+			Args: []string{"clone", "https://github.com/*", "*"},
+			Callback: func(c *cmdrunner.Command) error {
+				callbackCommand = c
+				return nil
+			},
+		})
+
+	_, err := runner.Run(cmdrunner.NewCommand("", "git", "clone", "https://github.com/foo/bar.git", "/tmp/dir"))
+	require.NoError(t, err)
+	text, err := runner.Run(cmdrunner.NewCommand("", "kpt", "version"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", text)
+
+	require.NotNil(t, callbackCommand, "callback should have been invoked")
+	assert.Equal(t, "/tmp/dir", callbackCommand.Args[2])
+	assert.Len(t, runner.Invocations(), 2)
+
+	rt := &recordingT{}
+	runner.Verify(rt)
+	assert.Empty(t, rt.errors, "verify errors")
+}
+
+func TestFakeCommandRunnerOrdered(t *testing.T) {
+	t.Parallel()
+
+	runner := testhelpers.NewFakeCommandRunner().
+		ExpectCommand("", "git", "fetch").
+		ExpectCommand("", "git", "merge")
+	runner.Ordered = true
+
+	_, err := runner.Run(cmdrunner.NewCommand("", "git", "merge"))
+	assert.Error(t, err, "should fail when invoked out of order")
+
+	rt := &recordingT{}
+	runner.Verify(rt)
+	assert.Equal(t, []string{
+		"unexpected command: git merge",
+		"expected command was not invoked: git fetch",
+		"expected command was not invoked: git merge",
+	}, rt.errors)
+}
+
+func TestFakeCommandRunnerErrors(t *testing.T) {
+	t.Parallel()
+
+	runner := testhelpers.NewFakeCommandRunner().Expect(testhelpers.Expectation{
+		Matcher: func(c *cmdrunner.Command) bool {
+			return c.Dir == "/workspace"
+		},
+		Output: "boom",
+		Error:  errors.New("command failed"),
+	})
+
+	text, err := runner.Run(cmdrunner.NewCommand("/workspace", "make", "all"))
+	require.Error(t, err)
+	assert.Equal(t, "boom", text)
+
+	_, err = runner.Run(cmdrunner.NewCommand("/workspace", "make", "all"))
+	require.Error(t, err, "expectation should only match once")
+
+	rt := &recordingT{}
+	runner.Verify(rt)
+	assert.Equal(t, []string{"unexpected command: make all"}, rt.errors)
+}
+
+func TestMatchesPattern(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		pattern  string
+		text     string
+		expected bool
+	}{
+		{"foo", "foo", true},
+		{"foo", "foobar", false},
+		{"foo*", "foobar", true},
+		{"*/bar@*", "https://github.com/foo/bar@v1", true},
+		{"a.b", "axb", false},
+		{"*", "", true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, testhelpers.MatchesPattern(tc.pattern, tc.text), "pattern %s for text %s", tc.pattern, tc.text)
+	}
+}
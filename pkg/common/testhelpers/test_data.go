@@ -0,0 +1,54 @@
+package testhelpers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// GenerateTestOutputEnvVar the environment variable which if set to 'true' regenerates the expected files of the
+// golden file assertions from the actual files
+const GenerateTestOutputEnvVar = "JX_GITOPS_GENERATE_TEST_OUTPUT"
+
+// CopyTestData copies the test data directory to a temporary directory which is removed when the test completes so
+// that the test can modify the files. Returns the temporary directory
+func CopyTestData(t testing.TB, srcDir string) string {
+	t.Helper()
+	require.DirExists(t, srcDir)
+
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcDir, tmpDir)
+	return tmpDir
+}
+
+// AssertGoldenFile asserts the actual file has the same text as the expected file. If $JX_GITOPS_GENERATE_TEST_OUTPUT
+// is 'true' the expected file is regenerated from the actual file first
+func AssertGoldenFile(t *testing.T, expectedFile, actualFile string) {
+	t.Helper()
+	if os.Getenv(GenerateTestOutputEnvVar) == "true" {
+		data, err := ioutil.ReadFile(actualFile)
+		require.NoError(t, err, "failed to load %s", actualFile)
+		err = os.MkdirAll(filepath.Dir(expectedFile), files.DefaultDirWritePermissions)
+		require.NoError(t, err, "failed to create the dir of %s", expectedFile)
+		err = ioutil.WriteFile(expectedFile, data, files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", expectedFile)
+	}
+	testhelpers.AssertTextFilesEqual(t, expectedFile, actualFile, filepath.Base(actualFile))
+}
+
+// LoadResource loads the only resource in the file failing the test if the file does not contain one resource
+func LoadResource(t testing.TB, path string) *unstructured.Unstructured {
+	t.Helper()
+	resources, err := resourcehelpers.LoadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, resources, 1, "resources in %s", path)
+	return resources[0]
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/pkg/errors"
+)
+
+// CommandRunnerContext runs a command which is killed if the context is done before the command completes
+type CommandRunnerContext func(ctx context.Context, c *cmdrunner.Command) (string, error)
+
+// NewTimeoutCommandRunner creates a command runner which kills any command which takes longer than the timeout.
+// If the next runner is nil then RunCommandContext is used
+func NewTimeoutCommandRunner(next CommandRunnerContext, timeout time.Duration) cmdrunner.CommandRunner {
+	var resultRunner ResultCommandRunner
+	if next != nil {
+		resultRunner = func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+			output, err := next(ctx, c)
+			return &CommandResult{Stdout: output, ExitCode: ExitCode(err)}, err
+		}
+	}
+	return ToCommandRunner(NewTimeoutResultRunner(resultRunner, timeout))
+}
+
+// NewTimeoutResultRunner creates a runner which kills any command which takes longer than the timeout.
+// If the next runner is nil then RunCommandResult is used
+func NewTimeoutResultRunner(next ResultCommandRunner, timeout time.Duration) ResultCommandRunner {
+	if next == nil {
+		next = RunCommandResult
+	}
+	return func(ctx context.Context, c *cmdrunner.Command) (*CommandResult, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		result, err := next(ctx, c)
+		if ctx.Err() == context.DeadlineExceeded {
+			return result, errors.Errorf("command '%s' in directory '%s' timed out after %s, output: '%s'", cmdrunner.CLI(c), c.Dir, timeout.String(), result.Output())
+		}
+		return result, err
+	}
+}
+
+// RunCommandContext runs the command returning its standard output followed by its standard error.
+// If the context is done before the command completes then the command and its descendants are killed and any
+// partial output is returned
+func RunCommandContext(ctx context.Context, c *cmdrunner.Command) (string, error) {
+	result, err := RunCommandResult(ctx, c)
+	return result.Output(), err
+}
+
+// WithContext adapts the command runner so it can be used as a CommandRunnerContext. The runner cannot be
+// killed so it is left running in the background if the context is done first
+func WithContext(runner cmdrunner.CommandRunner) CommandRunnerContext {
+	return func(ctx context.Context, c *cmdrunner.Command) (string, error) {
+		type result struct {
+			output string
+			err    error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			output, err := runner(c)
+			ch <- result{output, err}
+		}()
+		select {
+		case r := <-ch:
+			return r.output, r.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// runProcess runs the process killing it along with its descendants if the context is done first. The process is
+// only started in its own process group if the context can be done so that any SIGINT is still received by the
+// process directly otherwise the SIGINT is forwarded to its process group
+func runProcess(ctx context.Context, e *exec.Cmd) error {
+	if ctx.Done() == nil {
+		return e.Run()
+	}
+	setProcessGroup(e)
+	err := e.Start()
+	if err != nil {
+		return err
+	}
+	stop := forwardInterrupts(e)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Wait()
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		_ = killProcessGroup(e)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// syncBuffer a buffer which can be written to concurrently
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+// Write appends the data to the buffer
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the contents of the buffer
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
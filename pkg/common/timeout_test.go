@@ -0,0 +1,93 @@
+package common_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sleepHelperEnv = "JX_GITOPS_SLEEP_HELPER"
+
+// TestSleepHelperProcess is not a real test; it is the slow child process run by the timeout tests.
+// The child starts a grandchild which shares its output then both sleep
+func TestSleepHelperProcess(t *testing.T) {
+	mode := os.Getenv(sleepHelperEnv)
+	if mode == "" {
+		return
+	}
+	if mode == "child" {
+		fmt.Println("starting")
+		grandchild := exec.Command(os.Args[0], "-test.run=TestSleepHelperProcess")
+		grandchild.Env = append(os.Environ(), sleepHelperEnv+"=grandchild")
+		grandchild.Stdout = os.Stdout
+		grandchild.Stderr = os.Stderr
+		err := grandchild.Start()
+		if err != nil {
+			os.Exit(2)
+		}
+	}
+	time.Sleep(30 * time.Second)
+	os.Exit(0)
+}
+
+func TestTimeoutCommandRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("descendant processes are not killed on windows")
+	}
+	timeout := 500 * time.Millisecond
+	runner := common.NewTimeoutCommandRunner(nil, timeout)
+
+	c := &cmdrunner.Command{
+		Name: os.Args[0],
+		Args: []string{"-test.run=TestSleepHelperProcess"},
+		Env:  map[string]string{sleepHelperEnv: "child"},
+	}
+
+	start := time.Now()
+	output, err := runner(c)
+	elapsed := time.Since(start)
+
+	require.Error(t, err, "should have timed out")
+	t.Logf("timed out after %s with error: %s", elapsed.String(), err.Error())
+
+	// if the grandchild was not killed it would keep the output open until it exits
+	assert.True(t, elapsed >= timeout, "should not complete before the timeout but took %s", elapsed.String())
+	assert.True(t, elapsed < timeout+2*time.Second, "should have been killed shortly after the timeout but took %s", elapsed.String())
+	assert.Equal(t, "starting", output, "partial output")
+	assert.Contains(t, err.Error(), "timed out after 500ms")
+	assert.Contains(t, err.Error(), "-test.run=TestSleepHelperProcess")
+	assert.Contains(t, err.Error(), "output: 'starting'")
+}
+
+func TestTimeoutCommandRunnerCompletes(t *testing.T) {
+	runner := common.NewTimeoutCommandRunner(func(ctx context.Context, c *cmdrunner.Command) (string, error) {
+		return "done", nil
+	}, time.Minute)
+
+	output, err := runner(cmdrunner.NewCommand("", "kpt", "version"))
+	require.NoError(t, err)
+	assert.Equal(t, "done", output)
+}
+
+func TestTimeoutCommandRunnerWithContext(t *testing.T) {
+	slow := func(c *cmdrunner.Command) (string, error) {
+		time.Sleep(2 * time.Second)
+		return "done", nil
+	}
+	runner := common.NewTimeoutCommandRunner(common.WithContext(slow), 100*time.Millisecond)
+
+	start := time.Now()
+	_, err := runner(cmdrunner.NewCommand("", "kpt", "version"))
+	require.Error(t, err, "should have timed out")
+	assert.True(t, time.Since(start) < time.Second, "should not wait for the runner to complete")
+	assert.Contains(t, err.Error(), "command 'kpt version' in directory '' timed out after 100ms")
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// VerbosityLevel how much output a command produces
+type VerbosityLevel int
+
+const (
+	// VerbosityNormal logs the output of child processes once they complete
+	VerbosityNormal VerbosityLevel = iota
+
+	// VerbosityQuiet suppresses the output of child processes and only logs warnings and errors
+	VerbosityQuiet
+
+	// VerbosityVerbose streams the output of child processes as they run and logs the debug details
+	VerbosityVerbose
+)
+
+// Logger logs formatted messages. The entry returned by log.Logger() implements it
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+// Verbosity the verbosity flags shared by commands
+type Verbosity struct {
+	// Verbose streams the output of child processes and logs the debug details
+	Verbose bool
+
+	// Quiet suppresses the output of child processes
+	Quiet bool
+
+	// Log the logger to use so that library callers can capture the logs. Defaults to log.Logger()
+	Log Logger
+}
+
+// AddFlags adds the persistent verbosity flags to the command
+func (v *Verbosity) AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVarP(&v.Verbose, "verbose", "v", false, "streams the output of the commands as they run and logs the debug details")
+	cmd.PersistentFlags().BoolVarP(&v.Quiet, "quiet", "q", false, "suppresses the output of the commands and only logs warnings and errors")
+}
+
+// Validate returns an error if both the verbose and quiet flags are specified
+func (v *Verbosity) Validate() error {
+	if v.Verbose && v.Quiet {
+		return errors.Errorf("the --verbose and --quiet flags are mutually exclusive")
+	}
+	return nil
+}
+
+// Level returns the verbosity level
+func (v *Verbosity) Level() VerbosityLevel {
+	switch {
+	case v.Verbose:
+		return VerbosityVerbose
+	case v.Quiet:
+		return VerbosityQuiet
+	default:
+		return VerbosityNormal
+	}
+}
+
+// Logger returns the logger to use
+func (v *Verbosity) Logger() Logger {
+	if v.Log != nil {
+		return v.Log
+	}
+	return log.Logger()
+}
+
+// Infof logs at info level unless quiet in which case debug level is used
+func (v *Verbosity) Infof(format string, args ...interface{}) {
+	if v.Level() == VerbosityQuiet {
+		v.Logger().Debugf(format, args...)
+		return
+	}
+	v.Logger().Infof(format, args...)
+}
+
+// Debugf logs at debug level unless verbose in which case info level is used
+func (v *Verbosity) Debugf(format string, args ...interface{}) {
+	if v.Level() == VerbosityVerbose {
+		v.Logger().Infof(format, args...)
+		return
+	}
+	v.Logger().Debugf(format, args...)
+}
+
+// Warnf logs at warning level whatever the verbosity
+func (v *Verbosity) Warnf(format string, args ...interface{}) {
+	v.Logger().Warnf(format, args...)
+}
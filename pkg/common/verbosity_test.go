@@ -0,0 +1,59 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerbosity(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected common.VerbosityLevel
+		fail     bool
+	}{
+		{
+			expected: common.VerbosityNormal,
+		},
+		{
+			args:     []string{"--verbose"},
+			expected: common.VerbosityVerbose,
+		},
+		{
+			args:     []string{"-q"},
+			expected: common.VerbosityQuiet,
+		},
+		{
+			args: []string{"-v", "--quiet"},
+			fail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		v := &common.Verbosity{}
+		root := &cobra.Command{Use: "root"}
+		v.AddFlags(root)
+		child := &cobra.Command{
+			Use: "child",
+			Run: func(cmd *cobra.Command, args []string) {},
+		}
+		root.AddCommand(child)
+
+		// the flags are persistent so can be specified on sub commands
+		root.SetArgs(append([]string{"child"}, tc.args...))
+		err := root.Execute()
+		require.NoError(t, err, "failed to parse args %v", tc.args)
+
+		err = v.Validate()
+		if tc.fail {
+			require.Error(t, err, "should have failed for args %v", tc.args)
+			assert.Equal(t, "the --verbose and --quiet flags are mutually exclusive", err.Error())
+			continue
+		}
+		require.NoError(t, err, "failed to validate args %v", tc.args)
+		assert.Equal(t, tc.expected, v.Level(), "level for args %v", tc.args)
+	}
+}
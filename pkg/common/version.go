@@ -0,0 +1,65 @@
+package common
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Build information. Populated at build-time via -ldflags using '-X $(ROOT_PACKAGE)/pkg/common.BuildVersion=$(VERSION)'
+var (
+	BuildVersion string
+	Revision     string
+	Branch       string
+	BuildDate    string
+	GoVersion    string
+)
+
+const (
+	// TestVersion used in test cases and dev builds for the current version if no
+	// version can be found - such as if the version property is not properly
+	// included in the go build flags
+	TestVersion = "1.0.0-SNAPSHOT"
+)
+
+// VersionInfo the build metadata of the binary
+type VersionInfo struct {
+	// Version the semantic version
+	Version string `json:"version"`
+
+	// Revision the git SHA the binary was built from
+	Revision string `json:"revision,omitempty"`
+
+	// Branch the git branch the binary was built from
+	Branch string `json:"branch,omitempty"`
+
+	// BuildDate the date the binary was built
+	BuildDate string `json:"buildDate,omitempty"`
+
+	// GoVersion the version of go used to build the binary
+	GoVersion string `json:"goVersion"`
+}
+
+// Version returns the semantic version of the binary or the TestVersion for dev builds so that
+// it can be included in error messages and bug reports
+func Version() string {
+	if BuildVersion != "" {
+		return BuildVersion
+	}
+	return TestVersion
+}
+
+// GetVersionInfo returns the build metadata of the binary. The go version of the runtime is used
+// if it was not populated at build-time
+func GetVersionInfo() VersionInfo {
+	goVersion := GoVersion
+	if goVersion == "" {
+		goVersion = strings.TrimPrefix(runtime.Version(), "go")
+	}
+	return VersionInfo{
+		Version:   Version(),
+		Revision:  Revision,
+		Branch:    Branch,
+		BuildDate: BuildDate,
+		GoVersion: goVersion,
+	}
+}
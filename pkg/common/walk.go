@@ -0,0 +1,177 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// WalkOptions the options for walking a directory tree
+type WalkOptions struct {
+	// MaxDepth limits how deep to look for files relative to the directory. 1 means only the files in the
+	// directory itself. 0 or less means there is no limit
+	MaxDepth int
+
+	// FollowSymlinks if enabled symbolic links to directories are walked. Each real directory is only walked
+	// once so that symlink cycles terminate
+	FollowSymlinks bool
+
+	// SkipSymlinks if enabled symbolic links are ignored rather than being reported as files. Ignored if
+	// FollowSymlinks is enabled
+	SkipSymlinks bool
+
+	// Patterns the file name patterns such as '*.yaml' of the files to walk. A pattern containing a path
+	// separator is matched against the relative path instead. Empty means all files
+	Patterns []string
+
+	// ExcludeDirs the directory name patterns such as '.git' of the directories which are not walked. A pattern
+	// containing a path separator is matched against the relative path instead
+	ExcludeDirs []string
+
+	// OnSkip if specified is invoked for each file which does not match the patterns and each excluded directory
+	OnSkip func(path, rel string)
+}
+
+// AddFlags adds the walk flags to the command
+func (o *WalkOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&o.MaxDepth, "max-depth", "", 0, "the maximum depth of directories to look for files. 1 means only the files in the directory itself. 0 means no limit")
+	cmd.Flags().BoolVarP(&o.FollowSymlinks, "follow-symlinks", "", false, "if enabled follows symbolic links to directories")
+	cmd.Flags().StringArrayVarP(&o.ExcludeDirs, "exclude-dir", "", nil, "the name patterns of the directories to ignore such as '.git'")
+}
+
+// WalkFn is invoked for each file with its path and its path relative to the directory being walked
+type WalkFn func(path, rel string, info os.FileInfo) error
+
+// WalkFiles recursively walks the files in the given directory in lexical order honouring the options. If the
+// directory is a file then the function is only invoked for it if it matches the patterns. An error is returned if
+// the directory does not exist or cannot be read whereas subdirectories which cannot be read are skipped
+func WalkFiles(dir string, opts WalkOptions, fn WalkFn) error {
+	w := &walker{
+		opts:    opts,
+		fn:      fn,
+		visited: map[string]bool{},
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find dir %s", dir)
+	}
+	if !info.IsDir() {
+		name := filepath.Base(dir)
+		if len(opts.Patterns) > 0 && !MatchesAnyPattern(name, opts.Patterns) {
+			w.skip(dir, name)
+			return nil
+		}
+		return fn(dir, name, info)
+	}
+	err = w.walkDir(dir, ".")
+	if err != nil {
+		return errors.Wrapf(err, "failed to walk dir %s", dir)
+	}
+	return nil
+}
+
+// MatchesAnyPattern returns true if the file name or the relative path for patterns containing a path
+// separator matches any of the patterns
+func MatchesAnyPattern(rel string, patterns []string) bool {
+	rel = filepath.Clean(rel)
+	name := filepath.Base(rel)
+	for _, pattern := range patterns {
+		text := name
+		if strings.ContainsRune(filepath.FromSlash(pattern), os.PathSeparator) {
+			text = rel
+			pattern = filepath.Clean(filepath.FromSlash(pattern))
+		}
+		matched, err := filepath.Match(pattern, text)
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Depth returns the number of path elements in the relative path
+func Depth(rel string) int {
+	rel = filepath.Clean(rel)
+	if rel == "." {
+		return 0
+	}
+	return len(strings.Split(rel, string(os.PathSeparator)))
+}
+
+type walker struct {
+	opts    WalkOptions
+	fn      WalkFn
+	visited map[string]bool
+}
+
+func (w *walker) walkDir(path, rel string) error {
+	if rel != "." && w.opts.MaxDepth > 0 && Depth(rel) >= w.opts.MaxDepth {
+		return nil
+	}
+	if rel != "." && MatchesAnyPattern(rel, w.opts.ExcludeDirs) {
+		w.skip(path, rel)
+		return nil
+	}
+	if w.opts.FollowSymlinks {
+		realPath, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			if w.visited[realPath] {
+				log.Logger().Debugf("ignoring directory %s as %s has already been walked", path, realPath)
+				return nil
+			}
+			w.visited[realPath] = true
+		}
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		if rel == "." {
+			return errors.Wrapf(err, "failed to read dir %s", path)
+		}
+		log.Logger().Debugf("ignoring directory %s which cannot be read: %s", path, err.Error())
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		childPath := filepath.Join(path, name)
+		childRel := filepath.Join(rel, name)
+
+		info := entry
+		if entry.Mode()&os.ModeSymlink != 0 {
+			switch {
+			case w.opts.FollowSymlinks:
+				info, err = os.Stat(childPath)
+				if err != nil {
+					log.Logger().Debugf("ignoring broken symlink %s: %s", childPath, err.Error())
+					continue
+				}
+			case w.opts.SkipSymlinks:
+				w.skip(childPath, childRel)
+				continue
+			}
+		}
+		switch {
+		case info.IsDir():
+			err = w.walkDir(childPath, childRel)
+		case len(w.opts.Patterns) > 0 && !MatchesAnyPattern(childRel, w.opts.Patterns):
+			w.skip(childPath, childRel)
+		default:
+			err = w.fn(childPath, childRel, info)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) skip(path, rel string) {
+	if w.opts.OnSkip != nil {
+		w.opts.OnSkip(path, rel)
+	}
+}
@@ -0,0 +1,236 @@
+package common_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkFilesMaxDepth(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	for _, f := range []string{"a.yaml", "b/b.yaml", "b/c/c.yaml", "b/c/d/d.yaml"} {
+		path := filepath.Join(tmpDir, f)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		require.NoError(t, err, "failed to create dir for %s", path)
+		err = ioutil.WriteFile(path, []byte("a: b\n"), 0600)
+		require.NoError(t, err, "failed to write %s", path)
+	}
+
+	testCases := []struct {
+		maxDepth int
+		expected []string
+	}{
+		{
+			maxDepth: 0,
+			expected: []string{"a.yaml", "b/b.yaml", "b/c/c.yaml", "b/c/d/d.yaml"},
+		},
+		{
+			maxDepth: 1,
+			expected: []string{"a.yaml"},
+		},
+		{
+			maxDepth: 2,
+			expected: []string{"a.yaml", "b/b.yaml"},
+		},
+		{
+			maxDepth: 10,
+			expected: []string{"a.yaml", "b/b.yaml", "b/c/c.yaml", "b/c/d/d.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var actual []string
+		err = common.WalkFiles(tmpDir, common.WalkOptions{MaxDepth: tc.maxDepth}, func(path, rel string, info os.FileInfo) error {
+			assert.Equal(t, filepath.Join(tmpDir, rel), path, "path for %s", rel)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		})
+		require.NoError(t, err, "failed to walk with max depth %d", tc.maxDepth)
+		assert.Equal(t, tc.expected, actual, "files for max depth %d", tc.maxDepth)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	assert.Equal(t, 0, common.Depth("."))
+	assert.Equal(t, 1, common.Depth("a.yaml"))
+	assert.Equal(t, 3, common.Depth(filepath.Join("a", "b", "c.yaml")))
+}
+
+func TestWalkFilesFollowSymlinks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	// repo/a.yaml
+	// repo/linked -> ../shared
+	// repo/loop -> .
+	// shared/b.yaml
+	// shared/back -> ../repo
+	for _, f := range []string{"repo/a.yaml", "shared/b.yaml"} {
+		path := filepath.Join(tmpDir, f)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		require.NoError(t, err, "failed to create dir for %s", path)
+		err = ioutil.WriteFile(path, []byte("a: b\n"), 0600)
+		require.NoError(t, err, "failed to write %s", path)
+	}
+	repoDir := filepath.Join(tmpDir, "repo")
+	require.NoError(t, os.Symlink(filepath.Join("..", "shared"), filepath.Join(repoDir, "linked")))
+	require.NoError(t, os.Symlink(".", filepath.Join(repoDir, "loop")))
+	require.NoError(t, os.Symlink(filepath.Join("..", "repo"), filepath.Join(tmpDir, "shared", "back")))
+
+	walk := func(opts common.WalkOptions) []string {
+		var actual []string
+		err := common.WalkFiles(repoDir, opts, func(path, rel string, info os.FileInfo) error {
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		})
+		require.NoError(t, err, "failed to walk %s", repoDir)
+		return actual
+	}
+
+	// without following symlinks the links are reported as files like filepath.Walk
+	assert.Equal(t, []string{"a.yaml", "linked", "loop"}, walk(common.WalkOptions{}))
+
+	assert.Equal(t, []string{"a.yaml", "linked/b.yaml"}, walk(common.WalkOptions{FollowSymlinks: true}), "should follow the symlink and terminate the cycles")
+
+	assert.Equal(t, []string{"a.yaml"}, walk(common.WalkOptions{SkipSymlinks: true}), "should skip the symlinks")
+}
+
+func TestWalkFilesPatternsAndExcludes(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	for _, f := range []string{"a.yaml", "README.md", ".git/config.yaml", "b/Kptfile", "b/b.yml", "b/node_modules/n.yaml", "c/skipped/c.yaml", "d/skipped/d.yaml"} {
+		path := filepath.Join(tmpDir, f)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		require.NoError(t, err, "failed to create dir for %s", path)
+		err = ioutil.WriteFile(path, []byte("a: b\n"), 0600)
+		require.NoError(t, err, "failed to write %s", path)
+	}
+
+	testCases := []struct {
+		name     string
+		opts     common.WalkOptions
+		expected []string
+		skipped  []string
+	}{
+		{
+			name:     "all",
+			expected: []string{".git/config.yaml", "README.md", "a.yaml", "b/Kptfile", "b/b.yml", "b/node_modules/n.yaml", "c/skipped/c.yaml", "d/skipped/d.yaml"},
+		},
+		{
+			name: "patterns",
+			opts: common.WalkOptions{
+				Patterns: []string{"*.yaml", "Kptfile"},
+			},
+			expected: []string{".git/config.yaml", "a.yaml", "b/Kptfile", "b/node_modules/n.yaml", "c/skipped/c.yaml", "d/skipped/d.yaml"},
+			skipped:  []string{"README.md", "b/b.yml"},
+		},
+		{
+			name: "excludes",
+			opts: common.WalkOptions{
+				ExcludeDirs: []string{".git", "node_modules", "c/skipped"},
+			},
+			expected: []string{"README.md", "a.yaml", "b/Kptfile", "b/b.yml", "d/skipped/d.yaml"},
+			skipped:  []string{".git", "b/node_modules", "c/skipped"},
+		},
+		{
+			name: "relative path patterns",
+			opts: common.WalkOptions{
+				Patterns: []string{"b/*"},
+			},
+			expected: []string{"b/Kptfile", "b/b.yml"},
+			skipped:  []string{".git/config.yaml", "README.md", "a.yaml", "b/node_modules/n.yaml", "c/skipped/c.yaml", "d/skipped/d.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var actual, skipped []string
+		opts := tc.opts
+		opts.OnSkip = func(path, rel string) {
+			assert.Equal(t, filepath.Join(tmpDir, rel), path, "skipped path for %s", rel)
+			skipped = append(skipped, filepath.ToSlash(rel))
+		}
+		err = common.WalkFiles(tmpDir, opts, func(path, rel string, info os.FileInfo) error {
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		})
+		require.NoError(t, err, "failed to walk for %s", tc.name)
+		assert.Equal(t, tc.expected, actual, "files for %s", tc.name)
+		assert.Equal(t, tc.skipped, skipped, "skipped files for %s", tc.name)
+	}
+}
+
+func TestWalkFilesFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	path := filepath.Join(tmpDir, "a.yaml")
+	err = ioutil.WriteFile(path, []byte("a: b\n"), 0600)
+	require.NoError(t, err, "failed to write %s", path)
+
+	var actual []string
+	fn := func(path, rel string, info os.FileInfo) error {
+		actual = append(actual, rel)
+		return nil
+	}
+	err = common.WalkFiles(path, common.WalkOptions{Patterns: []string{"*.yaml"}}, fn)
+	require.NoError(t, err, "failed to walk the file")
+	assert.Equal(t, []string{"a.yaml"}, actual, "files")
+
+	actual = nil
+	err = common.WalkFiles(path, common.WalkOptions{Patterns: []string{"*.yml"}}, fn)
+	require.NoError(t, err, "failed to walk the file")
+	assert.Empty(t, actual, "files not matching the patterns")
+}
+
+func TestWalkFilesMissingDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	fn := func(path, rel string, info os.FileInfo) error {
+		return nil
+	}
+	dir := filepath.Join(tmpDir, "does-not-exist")
+	err = common.WalkFiles(dir, common.WalkOptions{}, fn)
+	require.Error(t, err, "should fail to walk a missing dir")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), dir, "the error should include the dir")
+}
+
+func TestWalkFilesUnreadableDir(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("the permissions of the directories cannot be enforced")
+	}
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	for _, path := range []string{"a.yaml", filepath.Join("locked", "b.yaml")} {
+		path = filepath.Join(tmpDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700), "failed to create dir of %s", path)
+		require.NoError(t, ioutil.WriteFile(path, []byte("a: b\n"), 0600), "failed to write %s", path)
+	}
+	lockedDir := filepath.Join(tmpDir, "locked")
+	require.NoError(t, os.Chmod(lockedDir, 0), "failed to lock %s", lockedDir)
+	t.Cleanup(func() {
+		_ = os.Chmod(lockedDir, 0700)
+	})
+
+	var actual []string
+	fn := func(path, rel string, info os.FileInfo) error {
+		actual = append(actual, rel)
+		return nil
+	}
+	err = common.WalkFiles(tmpDir, common.WalkOptions{}, fn)
+	require.NoError(t, err, "unreadable subdirectories should be skipped")
+	assert.Equal(t, []string{"a.yaml"}, actual, "files")
+
+	err = common.WalkFiles(lockedDir, common.WalkOptions{}, fn)
+	require.Error(t, err, "should fail to walk an unreadable dir")
+	t.Logf("got expected error: %s", err.Error())
+}
@@ -0,0 +1,188 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultDebounce the default time to wait after a change for any more changes before re-running
+	DefaultDebounce = 500 * time.Millisecond
+
+	// settleTime the time without any events after a run before the changes made by the run are assumed done
+	settleTime = 100 * time.Millisecond
+)
+
+// Options the options for re-running a command whenever the files it reads change
+type Options struct {
+	// Watch if enabled the command is re-run whenever the files change until interrupted
+	Watch bool
+
+	// Debounce the time to wait after a change for any more changes before re-running
+	Debounce time.Duration
+}
+
+// AddFlags registers the --watch and --watch-debounce flags
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&o.Watch, "watch", "", false, "re-runs the command whenever the files in the directory change until interrupted")
+	cmd.Flags().DurationVarP(&o.Debounce, "watch-debounce", "", DefaultDebounce, "the time to wait after a change for any more changes before re-running the command with --watch")
+}
+
+// Run runs the function and then, if watching is enabled, re-runs it whenever the files in the directories change
+// until the process is interrupted
+func (o *Options) Run(dirs []string, fn func() error) error {
+	if !o.Watch {
+		return fn()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			log.Logger().Infof("stopped watching")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return o.RunContext(ctx, dirs, fn)
+}
+
+// RunContext runs the function and then, if watching is enabled, re-runs it whenever the files in the directories
+// change until the context is done. Failures of the re-runs are reported rather than returned so that the files
+// can be fixed. Any changes made while the function runs, such as by the function itself, are ignored
+func (o *Options) RunContext(ctx context.Context, dirs []string, fn func() error) error {
+	err := fn()
+	if !o.Watch {
+		return err
+	}
+	if err != nil {
+		log.Logger().Warnf("failed: %s", err.Error())
+	}
+	debounce := o.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the file watcher")
+	}
+	defer watcher.Close()
+	for _, dir := range dirs {
+		err = addDirs(watcher, dir)
+		if err != nil {
+			return err
+		}
+	}
+	log.Logger().Infof("watching %s for changes", termcolor.ColorInfo(strings.Join(dirs, ", ")))
+
+	var timer <-chan time.Time
+	changes := 0
+	runs := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !o.onEvent(watcher, event) {
+				continue
+			}
+			changes++
+			timer = time.After(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Logger().Warnf("failed to watch for changes: %s", err.Error())
+
+		case <-timer:
+			timer = nil
+			runs++
+			log.Logger().Infof("re-running after %d changes", changes)
+			start := time.Now()
+			err = fn()
+			o.settle(ctx, watcher)
+			if err != nil {
+				log.Logger().Warnf("re-run %d failed: %s", runs, err.Error())
+			} else {
+				log.Logger().Infof("re-run %d completed in %s", runs, time.Since(start).Round(time.Millisecond).String())
+			}
+			changes = 0
+		}
+	}
+}
+
+// onEvent watches any new directories returning true if the event is a change to the files
+func (o *Options) onEvent(watcher *fsnotify.Watcher, event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if event.Op&fsnotify.Create != 0 {
+		info, err := os.Stat(event.Name)
+		if err == nil && info.IsDir() {
+			err = addDirs(watcher, event.Name)
+			if err != nil {
+				log.Logger().Warnf("failed to watch new directory: %s", err.Error())
+			}
+		}
+	}
+	log.Logger().Debugf("%s %s", event.Op.String(), event.Name)
+	return true
+}
+
+// settle discards the events until there have been none for a while so that the changes made by a run do not
+// trigger another run
+func (o *Options) settle(ctx context.Context, watcher *fsnotify.Watcher) {
+	quiet := time.After(settleTime)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-quiet:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			o.onEvent(watcher, event)
+			quiet = time.After(settleTime)
+		}
+	}
+}
+
+// addDirs watches the directory and all of its child directories other than hidden directories such as .git
+func addDirs(watcher *fsnotify.Watcher, dir string) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil || !info.IsDir() {
+			return err
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to watch dir %s", dir)
+	}
+	return nil
+}
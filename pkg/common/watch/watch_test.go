@@ -0,0 +1,77 @@
+package watch_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common/watch"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchDisabledRunsOnce(t *testing.T) {
+	runs := 0
+	cause := errors.New("boom")
+	o := &watch.Options{}
+	err := o.Run([]string{t.TempDir()}, func() error {
+		runs++
+		return cause
+	})
+	assert.Equal(t, cause, err, "the error should be returned without --watch")
+	assert.Equal(t, 1, runs, "runs")
+}
+
+func TestWatchRerunsOnChanges(t *testing.T) {
+	dir := t.TempDir()
+	subDir := filepath.Join(dir, "sub")
+	err := os.MkdirAll(subDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create %s", subDir)
+
+	var runs int32
+	rerun := make(chan struct{}, 10)
+	fn := func() error {
+		if atomic.AddInt32(&runs, 1) > 1 {
+			rerun <- struct{}{}
+		}
+		// lets make a change like a command modifying its files which should not trigger another run
+		return ioutil.WriteFile(filepath.Join(dir, "output.yaml"), []byte("kind: ConfigMap\n"), files.DefaultFileWritePermissions)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &watch.Options{Watch: true, Debounce: 50 * time.Millisecond}
+	done := make(chan error, 1)
+	go func() {
+		done <- o.RunContext(ctx, []string{dir}, fn)
+	}()
+
+	// lets wait for the watches to be added
+	time.Sleep(200 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		err = ioutil.WriteFile(filepath.Join(subDir, "source.yaml"), []byte("kind: Service\n"), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to write source.yaml")
+	}
+	select {
+	case <-rerun:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "the function should be re-run after the changes")
+	}
+
+	// lets check the rapid changes and the changes made by the function only caused one re-run
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs), "runs")
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(t, err, "watching should stop cleanly")
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "watching should stop when the context is done")
+	}
+}
@@ -0,0 +1,264 @@
+package fieldpaths
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Step a step of a field path which is either a field of a map, an index of a list or the element of a list
+// whose field matches a value
+type Step struct {
+	// Field the name of the field of a map
+	Field string
+
+	// Index the index of the element of a list if IsIndex is true
+	Index int
+
+	// IsIndex true if this step is an index of a list
+	IsIndex bool
+
+	// MatchKey the field of the elements of a list to match such as 'name'
+	MatchKey string
+
+	// MatchValue the value of the MatchKey field of the element of a list
+	MatchValue string
+}
+
+// String returns the text of the step
+func (s Step) String() string {
+	switch {
+	case s.IsIndex:
+		return "[" + strconv.Itoa(s.Index) + "]"
+	case s.MatchKey != "":
+		return "[" + s.MatchKey + "=" + s.MatchValue + "]"
+	default:
+		return s.Field
+	}
+}
+
+// ParsePath parses a dot separated path such as 'spec.template.spec.containers[0].image' or
+// 'spec.template.spec.containers[name=foo].image'. A dot in a field name can be escaped with a backslash
+func ParsePath(text string) ([]Step, error) {
+	var answer []Step
+	field := strings.Builder{}
+	addField := func() {
+		if field.Len() > 0 {
+			answer = append(answer, Step{Field: field.String()})
+			field.Reset()
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '\\':
+			if i+1 < len(text) {
+				i++
+				field.WriteByte(text[i])
+			}
+		case '.':
+			if field.Len() == 0 && (len(answer) == 0 || !isListStep(answer[len(answer)-1])) {
+				return nil, errors.Errorf("invalid path %s: empty field at position %d", text, i+1)
+			}
+			addField()
+		case '[':
+			addField()
+			if len(answer) == 0 {
+				return nil, errors.Errorf("invalid path %s: a list step must follow a field", text)
+			}
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, errors.Errorf("invalid path %s: missing ']'", text)
+			}
+			step, err := parseListStep(text[i+1 : i+end])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid path %s", text)
+			}
+			answer = append(answer, step)
+			i += end
+		default:
+			field.WriteByte(c)
+		}
+	}
+	if strings.HasSuffix(text, ".") && !strings.HasSuffix(text, "\\.") {
+		return nil, errors.Errorf("invalid path %s: empty field at the end", text)
+	}
+	addField()
+	if len(answer) == 0 {
+		return nil, errors.Errorf("invalid path %s: no fields", text)
+	}
+	return answer, nil
+}
+
+func isListStep(s Step) bool {
+	return s.IsIndex || s.MatchKey != ""
+}
+
+func parseListStep(text string) (Step, error) {
+	idx := strings.Index(text, "=")
+	if idx < 0 {
+		i, err := strconv.Atoi(text)
+		if err != nil || i < 0 {
+			return Step{}, errors.Errorf("invalid list index '%s'", text)
+		}
+		return Step{Index: i, IsIndex: true}, nil
+	}
+	key := strings.TrimSpace(text[:idx])
+	if key == "" {
+		return Step{}, errors.Errorf("missing key in '[%s]'", text)
+	}
+	return Step{MatchKey: key, MatchValue: strings.TrimSpace(text[idx+1:])}, nil
+}
+
+// Get returns the value at the path of the object and whether it exists. Fails if a map or list in the path
+// is some other kind of value
+func Get(obj map[string]interface{}, steps []Step) (interface{}, bool, error) {
+	var node interface{} = obj
+	for i, step := range steps {
+		if node == nil {
+			return nil, false, nil
+		}
+		if !isListStep(step) {
+			m, ok := node.(map[string]interface{})
+			if !ok {
+				return nil, false, errors.Errorf("%s is not a map", pathText(steps[:i]))
+			}
+			node, ok = m[step.Field]
+			if !ok {
+				return nil, false, nil
+			}
+			continue
+		}
+		list, ok := node.([]interface{})
+		if !ok {
+			return nil, false, errors.Errorf("%s is not a list", pathText(steps[:i]))
+		}
+		idx := listIndex(list, step)
+		if idx < 0 || idx >= len(list) {
+			return nil, false, nil
+		}
+		node = list[idx]
+	}
+	return node, true, nil
+}
+
+// Set sets the value at the path of the object creating any missing maps. The lists and list elements in
+// the path must already exist
+func Set(obj map[string]interface{}, steps []Step, value interface{}) error {
+	_, err := setPath(obj, steps, 0, value)
+	return err
+}
+
+func setPath(node interface{}, steps []Step, i int, value interface{}) (interface{}, error) {
+	if i >= len(steps) {
+		return value, nil
+	}
+	step := steps[i]
+	if !isListStep(step) {
+		m, ok := node.(map[string]interface{})
+		if node == nil {
+			m = map[string]interface{}{}
+		} else if !ok {
+			return nil, errors.Errorf("%s is not a map", pathText(steps[:i]))
+		}
+		child, err := setPath(m[step.Field], steps, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		m[step.Field] = child
+		return m, nil
+	}
+
+	list, ok := node.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s is not a list", pathText(steps[:i]))
+	}
+	idx := listIndex(list, step)
+	if idx < 0 {
+		return nil, errors.Errorf("no element of %s has %s=%s", pathText(steps[:i]), step.MatchKey, step.MatchValue)
+	}
+	if idx >= len(list) {
+		return nil, errors.Errorf("index %d of %s is out of range as it has %d elements", idx, pathText(steps[:i]), len(list))
+	}
+	child, err := setPath(list[idx], steps, i+1, value)
+	if err != nil {
+		return nil, err
+	}
+	list[idx] = child
+	return list, nil
+}
+
+// Remove removes the value at the path of the object returning whether it existed. Removing an element of a
+// list replaces the list with a copy without the element
+func Remove(obj map[string]interface{}, steps []Step) (bool, error) {
+	if len(steps) == 0 {
+		return false, nil
+	}
+	parentSteps := steps[:len(steps)-1]
+	var parent interface{} = obj
+	if len(parentSteps) > 0 {
+		var found bool
+		var err error
+		parent, found, err = Get(obj, parentSteps)
+		if err != nil || !found {
+			return false, err
+		}
+	}
+	step := steps[len(steps)-1]
+	if !isListStep(step) {
+		m, ok := parent.(map[string]interface{})
+		if !ok {
+			if parent == nil {
+				return false, nil
+			}
+			return false, errors.Errorf("%s is not a map", pathText(parentSteps))
+		}
+		if _, ok = m[step.Field]; !ok {
+			return false, nil
+		}
+		delete(m, step.Field)
+		return true, nil
+	}
+
+	list, ok := parent.([]interface{})
+	if !ok {
+		if parent == nil {
+			return false, nil
+		}
+		return false, errors.Errorf("%s is not a list", pathText(parentSteps))
+	}
+	idx := listIndex(list, step)
+	if idx < 0 || idx >= len(list) {
+		return false, nil
+	}
+	answer := append(append([]interface{}{}, list[:idx]...), list[idx+1:]...)
+	return true, Set(obj, parentSteps, answer)
+}
+
+// listIndex returns the index of the element of the list for the step or -1 if no element matches
+func listIndex(list []interface{}, step Step) int {
+	if step.IsIndex {
+		return step.Index
+	}
+	for i, e := range list {
+		m, ok := e.(map[string]interface{})
+		if ok && m[step.MatchKey] != nil && fmt.Sprint(m[step.MatchKey]) == step.MatchValue {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathText returns the text of the steps
+func pathText(steps []Step) string {
+	buf := strings.Builder{}
+	for i, s := range steps {
+		if i > 0 && !isListStep(s) {
+			buf.WriteString(".")
+		}
+		buf.WriteString(s.String())
+	}
+	return buf.String()
+}
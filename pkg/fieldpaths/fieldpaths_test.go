@@ -0,0 +1,145 @@
+package fieldpaths_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/fieldpaths"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	testCases := []struct {
+		path      string
+		expected  []fieldpaths.Step
+		expectErr bool
+	}{
+		{
+			path:     "spec.replicas",
+			expected: []fieldpaths.Step{{Field: "spec"}, {Field: "replicas"}},
+		},
+		{
+			path:     "spec.containers[1].ports[name=http].containerPort",
+			expected: []fieldpaths.Step{{Field: "spec"}, {Field: "containers"}, {Index: 1, IsIndex: true}, {Field: "ports"}, {MatchKey: "name", MatchValue: "http"}, {Field: "containerPort"}},
+		},
+		{
+			path:     `metadata.annotations.fluxcd\.io/automated`,
+			expected: []fieldpaths.Step{{Field: "metadata"}, {Field: "annotations"}, {Field: "fluxcd.io/automated"}},
+		},
+		{path: "", expectErr: true},
+		{path: "spec..replicas", expectErr: true},
+		{path: "spec.", expectErr: true},
+		{path: "[0]", expectErr: true},
+		{path: "spec.containers[-1]", expectErr: true},
+		{path: "spec.containers[=foo]", expectErr: true},
+		{path: "spec.containers[0", expectErr: true},
+	}
+	for _, tc := range testCases {
+		steps, err := fieldpaths.ParsePath(tc.path)
+		if tc.expectErr {
+			assert.Error(t, err, "should fail to parse %s", tc.path)
+			continue
+		}
+		require.NoError(t, err, "failed to parse %s", tc.path)
+		assert.Equal(t, tc.expected, steps, "steps of %s", tc.path)
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	newObject := func() map[string]interface{} {
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:1.0.0"},
+					map[string]interface{}{"name": "sidecar", "image": "sidecar:1.0.0"},
+				},
+			},
+		}
+	}
+	testCases := []struct {
+		path      string
+		value     interface{}
+		found     bool
+		expectErr bool
+	}{
+		{path: "spec.replicas", value: int64(1), found: true},
+		{path: "spec.containers[1].image", value: "sidecar:1.0.0", found: true},
+		{path: "spec.containers[name=app].image", value: "app:1.0.0", found: true},
+		{path: "spec.containers[name=missing].image"},
+		{path: "spec.containers[5].image"},
+		{path: "spec.missing.field"},
+		{path: "spec.replicas.field", expectErr: true},
+		{path: "spec.replicas[0]", expectErr: true},
+	}
+	for _, tc := range testCases {
+		steps, err := fieldpaths.ParsePath(tc.path)
+		require.NoError(t, err, "failed to parse %s", tc.path)
+		value, found, err := fieldpaths.Get(newObject(), steps)
+		if tc.expectErr {
+			assert.Error(t, err, "should fail to get %s", tc.path)
+			continue
+		}
+		require.NoError(t, err, "failed to get %s", tc.path)
+		assert.Equal(t, tc.found, found, "found %s", tc.path)
+		assert.Equal(t, tc.value, value, "value of %s", tc.path)
+	}
+
+	obj := newObject()
+	for _, path := range []string{"spec.containers[name=sidecar].image", "spec.template.metadata.labels.app"} {
+		steps, err := fieldpaths.ParsePath(path)
+		require.NoError(t, err, "failed to parse %s", path)
+		err = fieldpaths.Set(obj, steps, "cheese")
+		require.NoError(t, err, "failed to set %s", path)
+		value, found, err := fieldpaths.Get(obj, steps)
+		require.NoError(t, err, "failed to get %s", path)
+		assert.True(t, found, "found %s", path)
+		assert.Equal(t, "cheese", value, "value of %s", path)
+	}
+
+	for _, path := range []string{"spec.containers[name=missing].image", "spec.containers[2].image", "spec.replicas.field"} {
+		steps, err := fieldpaths.ParsePath(path)
+		require.NoError(t, err, "failed to parse %s", path)
+		err = fieldpaths.Set(newObject(), steps, "cheese")
+		assert.Error(t, err, "should fail to set %s", path)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"example.com/owner": "me", "other": "thing"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app"},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		},
+	}
+	testCases := []struct {
+		path      string
+		removed   bool
+		expectErr bool
+	}{
+		{path: `metadata.annotations.example\.com/owner`, removed: true},
+		{path: `metadata.annotations.example\.com/owner`},
+		{path: "spec.containers[name=sidecar]", removed: true},
+		{path: "spec.containers[name=sidecar]"},
+		{path: "status.replicas"},
+		{path: "metadata.annotations.other.field", expectErr: true},
+	}
+	for _, tc := range testCases {
+		steps, err := fieldpaths.ParsePath(tc.path)
+		require.NoError(t, err, "failed to parse %s", tc.path)
+		removed, err := fieldpaths.Remove(obj, steps)
+		if tc.expectErr {
+			assert.Error(t, err, "should fail to remove %s", tc.path)
+			continue
+		}
+		require.NoError(t, err, "failed to remove %s", tc.path)
+		assert.Equal(t, tc.removed, removed, "removed %s", tc.path)
+	}
+	assert.Equal(t, map[string]interface{}{"other": "thing"}, obj["metadata"].(map[string]interface{})["annotations"], "annotations")
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "app"}}, obj["spec"].(map[string]interface{})["containers"], "containers")
+}
@@ -0,0 +1,151 @@
+package helmhelpers
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ChartLabel the label helm adds with the name and version of the chart
+	ChartLabel = "helm.sh/chart"
+
+	// HookAnnotation the annotation of the helm hooks
+	HookAnnotation = "helm.sh/hook"
+)
+
+var (
+	// testHooks the helm hooks of the chart tests
+	testHooks = []string{"test", "test-success", "test-failure"}
+)
+
+// IsTestHook returns true if the resource is a helm test hook which is only used by 'helm test'
+func IsTestHook(u *unstructured.Unstructured) bool {
+	hooks := u.GetAnnotations()[HookAnnotation]
+	for _, hook := range strings.Split(hooks, ",") {
+		hook = strings.TrimSpace(hook)
+		for _, t := range testHooks {
+			if hook == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoveChartLabels removes the helm chart label from the resource and any pod template of the resource which
+// would otherwise change every time the chart version changes. Returns true if the resource was modified
+func RemoveChartLabels(u *unstructured.Unstructured, podTemplatePath []string) bool {
+	labels, _ := RemoveMetadata(u, podTemplatePath, []string{ChartLabel}, nil)
+	return len(labels) > 0
+}
+
+// IsHook returns true if the resource is a helm hook
+func IsHook(u *unstructured.Unstructured) bool {
+	return strings.TrimSpace(u.GetAnnotations()[HookAnnotation]) != ""
+}
+
+// RemoveMetadata removes the labels and annotations matching the patterns from the resource and any pod template
+// of the resource. A pattern is a key which can include wildcards such as 'checksum/*' optionally followed by
+// '=value' to only remove it if it has the value. Labels used by the selector of the resource are never removed so
+// that the selector still matches the pods. Returns the keys of the removed labels and annotations
+func RemoveMetadata(u *unstructured.Unstructured, podTemplatePath, labelPatterns, annotationPatterns []string) ([]string, []string) {
+	protected := selectorLabels(u)
+
+	labels, removedLabels := removeMatching(u.GetLabels(), labelPatterns, protected)
+	if len(removedLabels) > 0 {
+		u.SetLabels(labels)
+	}
+	annotations, removedAnnotations := removeMatching(u.GetAnnotations(), annotationPatterns, nil)
+	if len(removedAnnotations) > 0 {
+		u.SetAnnotations(annotations)
+	}
+	if len(podTemplatePath) == 0 {
+		return removedLabels, removedAnnotations
+	}
+	for _, m := range []struct {
+		field    string
+		patterns []string
+		removed  *[]string
+	}{
+		{"labels", labelPatterns, &removedLabels},
+		{"annotations", annotationPatterns, &removedAnnotations},
+	} {
+		fieldPath := append(append([]string{}, podTemplatePath...), "metadata", m.field)
+		values, found, err := unstructured.NestedStringMap(u.Object, fieldPath...)
+		if err != nil || !found {
+			continue
+		}
+		values, removed := removeMatching(values, m.patterns, protected)
+		if len(removed) == 0 {
+			continue
+		}
+		if len(values) == 0 {
+			unstructured.RemoveNestedField(u.Object, fieldPath...)
+		} else if unstructured.SetNestedStringMap(u.Object, values, fieldPath...) != nil {
+			continue
+		}
+		*m.removed = append(*m.removed, removed...)
+	}
+	return removedLabels, removedAnnotations
+}
+
+// removeMatching returns the values without the keys matching the patterns, or nil if there are none left, and
+// the sorted keys which were removed
+func removeMatching(values map[string]string, patterns []string, protected map[string]bool) (map[string]string, []string) {
+	var removed []string
+	for k, v := range values {
+		if protected[k] || !matchesMetadataPattern(k, v, patterns) {
+			continue
+		}
+		removed = append(removed, k)
+	}
+	if len(removed) == 0 {
+		return values, nil
+	}
+	sort.Strings(removed)
+	for _, k := range removed {
+		delete(values, k)
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+	return values, removed
+}
+
+func matchesMetadataPattern(key, value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		p := pattern
+		idx := strings.Index(pattern, "=")
+		if idx >= 0 {
+			p = pattern[:idx]
+			if pattern[idx+1:] != value {
+				continue
+			}
+		}
+		if p == key {
+			return true
+		}
+		if matched, err := path.Match(p, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorLabels returns the keys of the labels used by the selector of the resource
+func selectorLabels(u *unstructured.Unstructured) map[string]bool {
+	answer := map[string]bool{}
+	for _, fields := range [][]string{{"spec", "selector", "matchLabels"}, {"spec", "selector"}} {
+		values, found, err := unstructured.NestedStringMap(u.Object, fields...)
+		if err != nil || !found {
+			continue
+		}
+		for k := range values {
+			answer[k] = true
+		}
+	}
+	return answer
+}
@@ -0,0 +1,42 @@
+package kptfiles
+
+import "fmt"
+
+// ParseError the Kptfile could not be parsed
+type ParseError struct {
+	// Path the path of the Kptfile
+	Path string
+
+	// Err the parse error
+	Err error
+}
+
+// Error returns the error message
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse file %s: %s", e.Path, e.Err.Error())
+}
+
+// Unwrap returns the parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// MissingFieldError a field which is required to process the Kptfile is missing
+type MissingFieldError struct {
+	// Path the path of the Kptfile
+	Path string
+
+	// Field the path of the missing field such as 'upstream.git.repo'
+	Field string
+
+	// Description a description of the field such as 'git URL'
+	Description string
+}
+
+// Error returns the error message
+func (e *MissingFieldError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("missing field %s for path %s", e.Field, e.Path)
+	}
+	return fmt.Sprintf("no %s for path %s: missing field %s", e.Description, e.Path, e.Field)
+}
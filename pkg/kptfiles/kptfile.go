@@ -0,0 +1,341 @@
+package kptfiles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/yamledit"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// FileName the name of the file which defines a kpt package
+	FileName = "Kptfile"
+
+	// APIVersionV1Alpha1 the API version of the v1alpha1 schema which records the upstream commit in the upstream
+	APIVersionV1Alpha1 = "kpt.dev/v1alpha1"
+
+	// APIVersionV1 the API version of the v1 schema which records the resolved upstream in the upstream lock
+	APIVersionV1 = "kpt.dev/v1"
+
+	// UpstreamTypeGit the upstream type for git repositories
+	UpstreamTypeGit = "git"
+)
+
+// Kptfile the upstream details of a kpt package for both the v1alpha1 and v1 schemas
+type Kptfile struct {
+	// APIVersion the API version such as kpt.dev/v1alpha1 or kpt.dev/v1
+	APIVersion string
+
+	// Name the name of the package
+	Name string
+
+	// Repo the git repository of the upstream package
+	Repo string
+
+	// Directory the directory of the upstream package in the git repository
+	Directory string
+
+	// Ref the git reference of the upstream package such as a branch or tag
+	Ref string
+
+	// Commit the upstream commit. Only used by the v1alpha1 schema as v1 records it in the lock
+	Commit string
+
+	// UpdateStrategy the strategy used by kpt to update the package in the v1 schema such as resource-merge
+	UpdateStrategy string
+
+	// Lock the resolved upstream in the v1 schema or nil if there is no lock
+	Lock *Lock
+
+	data []byte
+	node *yaml.RNode
+}
+
+// Lock the resolved upstream of a package in the v1 schema
+type Lock struct {
+	// Repo the git repository of the resolved upstream
+	Repo string
+
+	// Directory the directory in the git repository of the resolved upstream
+	Directory string
+
+	// Ref the git reference of the resolved upstream
+	Ref string
+
+	// Commit the resolved git commit
+	Commit string
+}
+
+// Load loads the Kptfile at the given path
+func Load(path string) (*Kptfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	answer, err := Parse(data)
+	if err != nil {
+		return nil, &ParseError{Path: path, Err: err}
+	}
+	return answer, nil
+}
+
+// Parse parses the Kptfile in YAML or JSON format
+func Parse(data []byte) (*Kptfile, error) {
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, errors.Errorf("the Kptfile is empty")
+	}
+	node, err := yaml.Parse(string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML")
+	}
+	if node.YNode().Kind != yaml.MappingNode {
+		return nil, errors.Errorf("the Kptfile is not a YAML object")
+	}
+
+	k := &Kptfile{data: data, node: node}
+	fields := []struct {
+		value *string
+		path  []string
+	}{
+		{&k.APIVersion, []string{"apiVersion"}},
+		{&k.Name, []string{"metadata", "name"}},
+		{&k.Repo, []string{"upstream", "git", "repo"}},
+		{&k.Directory, []string{"upstream", "git", "directory"}},
+		{&k.Ref, []string{"upstream", "git", "ref"}},
+		{&k.Commit, []string{"upstream", "git", "commit"}},
+		{&k.UpdateStrategy, []string{"upstream", "updateStrategy"}},
+	}
+	for _, f := range fields {
+		*f.value, err = getString(node, f.path...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lockNode, err := node.Pipe(yaml.Lookup("upstreamLock"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read field upstreamLock")
+	}
+	if lockNode != nil {
+		k.Lock = &Lock{}
+		lockFields := []struct {
+			value *string
+			name  string
+		}{
+			{&k.Lock.Repo, "repo"},
+			{&k.Lock.Directory, "directory"},
+			{&k.Lock.Ref, "ref"},
+			{&k.Lock.Commit, "commit"},
+		}
+		for _, f := range lockFields {
+			*f.value, err = getString(node, "upstreamLock", "git", f.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return k, nil
+}
+
+// Save saves the Kptfile to the given path preserving the comments, formatting and any fields which are not
+// part of the model. Blank values are removed from the file. The upstream lock is left unchanged if there is no lock
+func (k *Kptfile) Save(path string) error {
+	text, err := k.ToYAML()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal file %s", path)
+	}
+	err = ioutil.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+type field struct {
+	value string
+	path  []string
+}
+
+// fields returns the fields of the model in the order they are written
+func (k *Kptfile) fields() []field {
+	answer := []field{
+		{k.APIVersion, []string{"apiVersion"}},
+		{k.Name, []string{"metadata", "name"}},
+		{k.UpdateStrategy, []string{"upstream", "updateStrategy"}},
+		{k.Repo, []string{"upstream", "git", "repo"}},
+		{k.Directory, []string{"upstream", "git", "directory"}},
+		{k.Ref, []string{"upstream", "git", "ref"}},
+		{k.Commit, []string{"upstream", "git", "commit"}},
+	}
+	if k.Lock != nil {
+		answer = append(answer,
+			field{UpstreamTypeGit, []string{"upstreamLock", "type"}},
+			field{k.Lock.Repo, []string{"upstreamLock", "git", "repo"}},
+			field{k.Lock.Directory, []string{"upstreamLock", "git", "directory"}},
+			field{k.Lock.Ref, []string{"upstreamLock", "git", "ref"}},
+			field{k.Lock.Commit, []string{"upstreamLock", "git", "commit"}},
+		)
+	}
+	return answer
+}
+
+// ToYAML returns the YAML of the Kptfile. The source is edited in place so that the comments, formatting and any
+// fields which are not part of the model are preserved. If the source cannot be edited in place, such as if it uses
+// JSON, then it is formatted as YAML
+func (k *Kptfile) ToYAML() (string, error) {
+	data := k.data
+	if len(data) == 0 {
+		data = []byte("kind: Kptfile\n")
+	}
+	text, err := k.editYAML(data)
+	if err == nil {
+		return text, nil
+	}
+	return k.formatYAML()
+}
+
+func (k *Kptfile) editYAML(data []byte) (string, error) {
+	f, err := yamledit.Parse(data)
+	if err != nil {
+		return "", err
+	}
+	for _, fl := range k.fields() {
+		if fl.value == "" {
+			err = f.Delete(0, fl.path...)
+		} else {
+			err = f.SetScalar(0, fl.value, fl.path...)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if k.Repo != "" {
+		_, ok, err := f.GetScalar(0, "upstream", "type")
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			err = f.SetScalar(0, UpstreamTypeGit, "upstream", "type")
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return f.String(), nil
+}
+
+func (k *Kptfile) formatYAML() (string, error) {
+	if k.node == nil {
+		k.node = yaml.NewRNode(&yaml.Node{Kind: yaml.MappingNode})
+		err := setString(k.node, "Kptfile", "kind")
+		if err != nil {
+			return "", err
+		}
+	}
+	node := k.node
+	for _, f := range k.fields() {
+		err := setString(node, f.value, f.path...)
+		if err != nil {
+			return "", err
+		}
+	}
+	if k.Repo != "" {
+		upstreamType, err := getString(node, "upstream", "type")
+		if err != nil {
+			return "", err
+		}
+		if upstreamType == "" {
+			err = setString(node, UpstreamTypeGit, "upstream", "type")
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return node.String()
+}
+
+// IsV1 returns true if the Kptfile uses the v1 schema
+func (k *Kptfile) IsV1() bool {
+	return k.APIVersion == APIVersionV1
+}
+
+// ResolvedCommit returns the commit of the upstream lock if there is one otherwise the upstream commit
+func (k *Kptfile) ResolvedCommit() string {
+	if k.Lock != nil && k.Lock.Commit != "" {
+		return k.Lock.Commit
+	}
+	return k.Commit
+}
+
+// Version returns the resolved commit or the upstream ref if there is no commit
+func (k *Kptfile) Version() string {
+	commit := k.ResolvedCommit()
+	if commit != "" {
+		return commit
+	}
+	return k.Ref
+}
+
+// Expression returns the 'repo.git/directory@version' expression used by 'kpt pkg get' for the upstream.
+// If the version is blank then the Version of the Kptfile is used
+func (k *Kptfile) Expression(version string) string {
+	if version == "" {
+		version = k.Version()
+	}
+	return fmt.Sprintf("%s%s@%s", NormalizeRepo(k.Repo), NormalizeDirectory(k.Directory), version)
+}
+
+// NormalizeRepo returns the git repository URL with a '.git' suffix
+func NormalizeRepo(repo string) string {
+	u, err := common.ParseGitURL(repo)
+	if err != nil {
+		// lets leave URLs we cannot parse for kpt to report
+		if strings.HasSuffix(repo, common.GitSuffix) {
+			return repo
+		}
+		return strings.TrimSuffix(repo, "/") + common.GitSuffix
+	}
+	return u.CloneURL()
+}
+
+// NormalizeDirectory returns the directory with a leading '/'
+func NormalizeDirectory(directory string) string {
+	if strings.HasPrefix(directory, "/") {
+		return directory
+	}
+	return "/" + directory
+}
+
+func getString(node *yaml.RNode, fields ...string) (string, error) {
+	path := strings.Join(fields, ".")
+	valueNode, err := node.Pipe(yaml.Lookup(fields...))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read field %s", path)
+	}
+	if valueNode == nil {
+		return "", nil
+	}
+	if valueNode.YNode().Kind != yaml.ScalarNode {
+		return "", errors.Errorf("field %s is not a string", path)
+	}
+	return valueNode.YNode().Value, nil
+}
+
+func setString(node *yaml.RNode, value string, fields ...string) error {
+	path := strings.Join(fields, ".")
+	var err error
+	if value == "" {
+		err = node.PipeE(yaml.Lookup(fields[:len(fields)-1]...), yaml.Clear(fields[len(fields)-1]))
+	} else {
+		err = node.PipeE(yaml.LookupCreate(yaml.ScalarNode, fields...), yaml.FieldSetter{StringValue: value})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to set field %s", path)
+	}
+	return nil
+}
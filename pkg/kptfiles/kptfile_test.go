@@ -0,0 +1,239 @@
+package kptfiles_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/kptfiles"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		file       string
+		expected   *kptfiles.Kptfile
+		v1         bool
+		version    string
+		expression string
+		fail       bool
+	}{
+		{
+			file: "v0.yaml",
+			expected: &kptfiles.Kptfile{
+				APIVersion: "kpt.dev/v1alpha1",
+				Name:       "lighthouse",
+				Repo:       "https://github.com/jenkins-x/jxr-kube-resources",
+				Directory:  "/jenkins-x/lighthouse",
+				Ref:        "master",
+				Commit:     "4cc6b80d49808060b1f06f530399b986ed344f23",
+			},
+			version:    "4cc6b80d49808060b1f06f530399b986ed344f23",
+			expression: "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/lighthouse@4cc6b80d49808060b1f06f530399b986ed344f23",
+		},
+		{
+			file: "v1.yaml",
+			expected: &kptfiles.Kptfile{
+				APIVersion:     "kpt.dev/v1",
+				Name:           "app2",
+				Repo:           "https://github.com/another/thing.git",
+				Directory:      "kubernetes/app2",
+				Ref:            "v1.2.3",
+				UpdateStrategy: "resource-merge",
+				Lock: &kptfiles.Lock{
+					Repo:      "https://github.com/another/thing.git",
+					Directory: "kubernetes/app2",
+					Ref:       "v1.2.3",
+					Commit:    "9f8e7d6c5b4a3f2e1d0c",
+				},
+			},
+			v1:         true,
+			version:    "9f8e7d6c5b4a3f2e1d0c",
+			expression: "https://github.com/another/thing.git/kubernetes/app2@9f8e7d6c5b4a3f2e1d0c",
+		},
+		{
+			file: "json.json",
+			expected: &kptfiles.Kptfile{
+				APIVersion: "kpt.dev/v1alpha1",
+				Name:       "from-json",
+				Repo:       "https://github.com/jenkins-x/jxr-kube-resources/",
+				Directory:  "jenkins-x/tekton",
+				Ref:        "master",
+				Commit:     "abc1234",
+			},
+			version:    "abc1234",
+			expression: "https://github.com/jenkins-x/jxr-kube-resources.git/jenkins-x/tekton@abc1234",
+		},
+		{
+			file: "missing.yaml",
+			expected: &kptfiles.Kptfile{
+				APIVersion: "kpt.dev/v1alpha1",
+				Name:       "local",
+			},
+			expression: ".git/@",
+		},
+		{
+			file: "malformed.yaml",
+			fail: true,
+		},
+		{
+			file: "list.yaml",
+			fail: true,
+		},
+		{
+			file: "invalid-field.yaml",
+			fail: true,
+		},
+		{
+			file: "empty.yaml",
+			fail: true,
+		},
+		{
+			file: "does-not-exist.yaml",
+			fail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join("test_data", tc.file)
+		k, err := kptfiles.Load(path)
+		if tc.fail {
+			require.Error(t, err, "should have failed to load %s", path)
+			t.Logf("got expected error loading %s: %s", path, err.Error())
+
+			// files which exist fail to parse
+			var parseErr *kptfiles.ParseError
+			exists := tc.file != "does-not-exist.yaml"
+			require.Equal(t, exists, errors.As(err, &parseErr), "should be a parse error for %s", path)
+			if exists {
+				assert.Equal(t, path, parseErr.Path, "path of the parse error")
+			}
+			continue
+		}
+		require.NoError(t, err, "failed to load %s", path)
+
+		assert.Equal(t, tc.expected.APIVersion, k.APIVersion, "apiVersion for %s", path)
+		assert.Equal(t, tc.expected.Name, k.Name, "name for %s", path)
+		assert.Equal(t, tc.expected.Repo, k.Repo, "repo for %s", path)
+		assert.Equal(t, tc.expected.Directory, k.Directory, "directory for %s", path)
+		assert.Equal(t, tc.expected.Ref, k.Ref, "ref for %s", path)
+		assert.Equal(t, tc.expected.Commit, k.Commit, "commit for %s", path)
+		assert.Equal(t, tc.expected.UpdateStrategy, k.UpdateStrategy, "updateStrategy for %s", path)
+		assert.Equal(t, tc.expected.Lock, k.Lock, "lock for %s", path)
+		assert.Equal(t, tc.v1, k.IsV1(), "v1 for %s", path)
+		assert.Equal(t, tc.version, k.Version(), "version for %s", path)
+		assert.Equal(t, tc.expression, k.Expression(""), "expression for %s", path)
+	}
+}
+
+func TestSavePreservesUnknownFields(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	for _, file := range []string{"v0.yaml", "v1.yaml"} {
+		k, err := kptfiles.Load(filepath.Join("test_data", file))
+		require.NoError(t, err, "failed to load %s", file)
+
+		k.Ref = "v2.0.0"
+		k.Commit = ""
+		if k.Lock != nil {
+			k.Lock.Ref = "v2.0.0"
+			k.Lock.Commit = "0123456789"
+		}
+		path := filepath.Join(tmpDir, file)
+		err = k.Save(path)
+		require.NoError(t, err, "failed to save %s", path)
+
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err, "failed to read %s", path)
+		text := string(data)
+
+		k2, err := kptfiles.Load(path)
+		require.NoError(t, err, "failed to reload %s", path)
+		assert.Equal(t, "v2.0.0", k2.Ref, "ref for %s", path)
+		assert.Equal(t, "", k2.Commit, "the commit should have been removed for %s", path)
+		assert.Equal(t, k.Repo, k2.Repo, "repo for %s", path)
+
+		if k.IsV1() {
+			assert.Equal(t, "0123456789", k2.Version(), "version for %s", path)
+			assert.Contains(t, text, "gcr.io/kpt-fn/set-namespace:v0.1", "the pipeline should be preserved for %s", path)
+			assert.Contains(t, text, "config.kubernetes.io/local-config", "the annotations should be preserved for %s", path)
+		} else {
+			assert.Equal(t, "v2.0.0", k2.Version(), "version for %s", path)
+			assert.Contains(t, text, "name: nested", "the dependencies should be preserved for %s", path)
+		}
+	}
+}
+
+func TestSavePreservesFormatting(t *testing.T) {
+	path := filepath.Join("test_data", "commented.yaml")
+	source, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to read %s", path)
+
+	k, err := kptfiles.Load(path)
+	require.NoError(t, err, "failed to load %s", path)
+
+	text, err := k.ToYAML()
+	require.NoError(t, err, "failed to marshal %s", path)
+	assert.Equal(t, string(source), text, "an unmodified Kptfile should not change")
+
+	k.Commit = "def5678"
+	k.Ref = "v1.0.0"
+	text, err = k.ToYAML()
+	require.NoError(t, err, "failed to marshal %s", path)
+
+	expected := strings.Replace(string(source), `commit: "abc1234"`, `commit: "def5678"`, 1)
+	expected = strings.Replace(expected, "ref: master # kpt-set", "ref: v1.0.0 # kpt-set", 1)
+	assert.Equal(t, expected, text, "only the modified values should change")
+
+	// JSON cannot be edited in place so it is converted to YAML
+	k, err = kptfiles.Load(filepath.Join("test_data", "json.json"))
+	require.NoError(t, err, "failed to load json.json")
+	k.UpdateStrategy = "resource-merge"
+	text, err = k.ToYAML()
+	require.NoError(t, err, "failed to marshal json.json")
+
+	k2, err := kptfiles.Parse([]byte(text))
+	require.NoError(t, err, "failed to parse the converted JSON %s", text)
+	assert.Equal(t, "resource-merge", k2.UpdateStrategy, "updateStrategy for the converted JSON")
+	assert.Equal(t, "from-json", k2.Name, "name for the converted JSON")
+}
+
+func TestNewKptfile(t *testing.T) {
+	k := &kptfiles.Kptfile{
+		APIVersion: "kpt.dev/v1alpha1",
+		Name:       "new",
+		Repo:       "https://github.com/another/thing",
+		Directory:  "/kubernetes/app",
+		Ref:        "master",
+	}
+	text, err := k.ToYAML()
+	require.NoError(t, err, "failed to marshal the Kptfile")
+
+	expected := `kind: Kptfile
+apiVersion: kpt.dev/v1alpha1
+metadata:
+  name: new
+upstream:
+  git:
+    repo: https://github.com/another/thing
+    directory: /kubernetes/app
+    ref: master
+  type: git
+`
+	assert.Equal(t, expected, text)
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "https://github.com/a/b.git", kptfiles.NormalizeRepo("https://github.com/a/b"))
+	assert.Equal(t, "https://github.com/a/b.git", kptfiles.NormalizeRepo("https://github.com/a/b/"))
+	assert.Equal(t, "https://github.com/a/b.git", kptfiles.NormalizeRepo("https://github.com/a/b.git"))
+	assert.Equal(t, "https://github.com/a/b.git", kptfiles.NormalizeRepo("https://github.com/a/b.git/"))
+	assert.Equal(t, "git@github.com:a/b.git", kptfiles.NormalizeRepo("git@github.com:a/b"))
+	assert.Equal(t, "file:///repos/b.git", kptfiles.NormalizeRepo("file:///repos/b/"))
+	assert.Equal(t, "/c/d", kptfiles.NormalizeDirectory("c/d"))
+	assert.Equal(t, "/c/d", kptfiles.NormalizeDirectory("/c/d"))
+}
@@ -0,0 +1,29 @@
+package kptfiles
+
+import (
+	"github.com/jenkins-x/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+// LoadLock loads and validates the kpt lock file exported via 'kpt export-versions'
+func LoadLock(path string) (*v1alpha1.KptLock, error) {
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return nil, errors.Errorf("kpt lock file %s does not exist", path)
+	}
+	lock := &v1alpha1.KptLock{}
+	err = yamls.LoadFile(path, lock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load kpt lock file %s", path)
+	}
+	err = lock.Validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to validate kpt lock file %s", path)
+	}
+	return lock, nil
+}
@@ -0,0 +1,135 @@
+package kubeschemas
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// CRDKind the kind of the CustomResourceDefinition resources
+	CRDKind = "CustomResourceDefinition"
+
+	// CRDGroup the API group of the CustomResourceDefinition resources
+	CRDGroup = "apiextensions.k8s.io"
+
+	// objectMetaDefinition the name of the definition of the metadata of resources
+	objectMetaDefinition = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+	// crdDefinitionPrefix the prefix of the names of the definitions of custom resources
+	crdDefinitionPrefix = "crd/"
+)
+
+// IsCRD returns true if the resource is a CustomResourceDefinition
+func IsCRD(obj map[string]interface{}) bool {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	return kind == CRDKind && strings.HasPrefix(apiVersion, CRDGroup+"/")
+}
+
+// AddCRD adds the schemas of the served versions of the CustomResourceDefinition so that its custom resources
+// can be validated. Versions without a schema allow any fields. Returns false if the kind already has schemas
+func (s *Schemas) AddCRD(crd map[string]interface{}) (bool, error) {
+	group, _, _ := unstructured.NestedString(crd, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd, "spec", "names", "kind")
+	if group == "" || kind == "" {
+		return false, errors.Errorf("missing spec.group or spec.names.kind")
+	}
+	versions, _, err := unstructured.NestedSlice(crd, "spec", "versions")
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get spec.versions")
+	}
+	// lets support the single version and the shared schema of the v1beta1 API
+	globalSchema, _, _ := unstructured.NestedMap(crd, "spec", "validation", "openAPIV3Schema")
+	if len(versions) == 0 {
+		version, _, _ := unstructured.NestedString(crd, "spec", "version")
+		if version == "" {
+			return false, errors.Errorf("missing spec.versions")
+		}
+		versions = []interface{}{map[string]interface{}{"name": version, "served": true}}
+	}
+	preserveUnknownFields := false
+	apiVersion, _ := crd["apiVersion"].(string)
+	if strings.HasSuffix(apiVersion, "/v1beta1") {
+		flag, found, _ := unstructured.NestedBool(crd, "spec", "preserveUnknownFields")
+		preserveUnknownFields = flag || !found
+	}
+
+	for _, existing := range s.apiVersions[kind] {
+		if strings.HasPrefix(existing, group+"/") {
+			return false, nil
+		}
+	}
+	for i, item := range versions {
+		version, ok := item.(map[string]interface{})
+		if !ok {
+			return false, errors.Errorf("spec.versions[%d] is not an object", i)
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		if name == "" {
+			return false, errors.Errorf("missing spec.versions[%d].name", i)
+		}
+		served, found, _ := unstructured.NestedBool(version, "served")
+		if found && !served {
+			continue
+		}
+		obj, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		if !found {
+			obj = globalSchema
+		}
+		schema, err := toSchema(obj)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid schema for version %s", name)
+		}
+		if obj == nil || preserveUnknownFields {
+			schema.PreserveUnknownFields = true
+		}
+
+		apiVersion = group + "/" + name
+		definition := crdDefinitionPrefix + apiVersion + "/" + kind
+		s.Definitions[definition] = s.resourceSchema(schema)
+		s.kinds[apiVersion+"/"+kind] = definition
+		s.apiVersions[kind] = append(s.apiVersions[kind], apiVersion)
+	}
+	sort.Strings(s.apiVersions[kind])
+	return true, nil
+}
+
+// resourceSchema returns the schema of a custom resource allowing the apiVersion, kind and metadata fields which
+// are not part of the schema of a CustomResourceDefinition
+func (s *Schemas) resourceSchema(schema *Schema) *Schema {
+	answer := *schema
+	answer.Type = "object"
+	answer.Properties = map[string]*Schema{
+		"apiVersion": {Type: "string"},
+		"kind":       {Type: "string"},
+		"metadata":   {Type: "object"},
+	}
+	if s.Definitions[objectMetaDefinition] != nil {
+		answer.Properties["metadata"] = &Schema{Ref: definitionsPrefix + objectMetaDefinition}
+	}
+	for k, v := range schema.Properties {
+		answer.Properties[k] = v
+	}
+	return &answer
+}
+
+// toSchema converts the unstructured OpenAPI v3 schema of a custom resource
+func toSchema(obj map[string]interface{}) (*Schema, error) {
+	schema := &Schema{}
+	if obj == nil {
+		return schema, nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal the schema")
+	}
+	err = json.Unmarshal(data, schema)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the schema")
+	}
+	return schema, nil
+}
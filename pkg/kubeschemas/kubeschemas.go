@@ -0,0 +1,500 @@
+package kubeschemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/openapi/kubernetesapi"
+)
+
+const (
+	// BundledVersion the kubernetes version of the schemas bundled in the binary
+	BundledVersion = "v1.17"
+
+	// SchemaFileName the name of the OpenAPI file in the directory of each kubernetes version in the schema dir
+	SchemaFileName = "swagger.json"
+
+	// bundledAsset the name of the bundled OpenAPI asset
+	bundledAsset = "openapi/kubernetesapi/swagger.json"
+
+	// definitionsPrefix the prefix of the references to definitions
+	definitionsPrefix = "#/definitions/"
+)
+
+const (
+	// ProblemUnknownField a field which does not exist in the schema
+	ProblemUnknownField = "unknown-field"
+
+	// ProblemType a value of the wrong type
+	ProblemType = "type"
+
+	// ProblemRequired a missing required field
+	ProblemRequired = "required"
+
+	// ProblemUnknownAPIVersion a known kind with an apiVersion which does not exist
+	ProblemUnknownAPIVersion = "unknown-api-version"
+
+	// ProblemUnknownKind a kind which is not in the schemas such as a custom resource
+	ProblemUnknownKind = "unknown-kind"
+
+	// ProblemEnum a value which is not one of the allowed values
+	ProblemEnum = "enum"
+)
+
+// Schema the subset of an OpenAPI schema used to validate kubernetes resources
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *SchemaOrBool      `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	GroupVersionKinds    []GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+
+	// PreserveUnknownFields allows any fields which are not in the properties of a custom resource schema
+	PreserveUnknownFields bool `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+
+	// IntOrString allows either an integer or a string in a custom resource schema
+	IntOrString bool `json:"x-kubernetes-int-or-string,omitempty"`
+}
+
+// SchemaOrBool the schema of the additional properties of a map which can also be a bool
+type SchemaOrBool struct {
+	Allows bool
+	Schema *Schema
+}
+
+// UnmarshalJSON unmarshals either a bool or a schema
+func (s *SchemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Allows); err == nil {
+		return nil
+	}
+	s.Allows = true
+	s.Schema = &Schema{}
+	return json.Unmarshal(data, s.Schema)
+}
+
+// GroupVersionKind the group, version and kind of a resource defined by a schema
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// APIVersion returns the apiVersion of resources of the kind
+func (g GroupVersionKind) APIVersion() string {
+	if g.Group == "" {
+		return g.Version
+	}
+	return g.Group + "/" + g.Version
+}
+
+// Problem a problem with a resource
+type Problem struct {
+	// Type the type of the problem such as ProblemUnknownField
+	Type string
+
+	// Field the dot separated path of the field
+	Field string
+
+	// Message the description of the problem
+	Message string
+}
+
+// Schemas the schemas of the kubernetes resources of a kubernetes version
+type Schemas struct {
+	// Version the kubernetes version
+	Version     string
+	Definitions map[string]*Schema
+
+	// kinds the definition names indexed by apiVersion and kind
+	kinds map[string]string
+
+	// apiVersions the sorted apiVersions of each kind
+	apiVersions map[string][]string
+}
+
+// NormaliseVersion returns the major and minor version such as 'v1.19' for '1.19.3'
+func NormaliseVersion(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	parts := strings.Split(version, ".")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return "v" + strings.Join(parts, ".")
+}
+
+// Load loads the schemas of the kubernetes version which are either bundled in the binary or in the file
+// <schemaDir>/<version>/swagger.json such as '~/.jx-gitops/schemas/v1.19/swagger.json'
+func Load(version, schemaDir string) (*Schemas, error) {
+	version = NormaliseVersion(version)
+	if version == "v" {
+		version = BundledVersion
+	}
+	var data []byte
+	path := ""
+	if schemaDir != "" {
+		path = filepath.Join(schemaDir, version, SchemaFileName)
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+		}
+		if exists {
+			data, err = ioutil.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load file %s", path)
+			}
+		}
+	}
+	if data == nil {
+		if version != BundledVersion {
+			return nil, errors.Errorf("no schemas for kubernetes version %s as the file %s does not exist. The bundled version is %s or the OpenAPI file of the version can be saved there such as from https://raw.githubusercontent.com/kubernetes/kubernetes/release-%s/api/openapi-spec/swagger.json", version, path, BundledVersion, strings.TrimPrefix(version, "v"))
+		}
+		var err error
+		data, err = kubernetesapi.Asset(bundledAsset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the bundled schemas")
+		}
+		path = "bundled schemas"
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	s.Version = version
+	return s, nil
+}
+
+// Parse parses the definitions of an OpenAPI document
+func Parse(data []byte) (*Schemas, error) {
+	doc := struct {
+		Definitions map[string]*Schema `json:"definitions"`
+	}{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the OpenAPI document")
+	}
+	if len(doc.Definitions) == 0 {
+		return nil, errors.Errorf("the OpenAPI document has no definitions")
+	}
+	s := &Schemas{
+		Definitions: doc.Definitions,
+		kinds:       map[string]string{},
+		apiVersions: map[string][]string{},
+	}
+	for name, d := range doc.Definitions {
+		for _, gvk := range d.GroupVersionKinds {
+			apiVersion := gvk.APIVersion()
+			key := apiVersion + "/" + gvk.Kind
+			if _, ok := s.kinds[key]; ok {
+				continue
+			}
+			s.kinds[key] = name
+			s.apiVersions[gvk.Kind] = append(s.apiVersions[gvk.Kind], apiVersion)
+		}
+	}
+	for _, versions := range s.apiVersions {
+		sort.Strings(versions)
+	}
+	return s, nil
+}
+
+// Validate validates the resource against the schema of its apiVersion and kind
+func (s *Schemas) Validate(obj map[string]interface{}) []Problem {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	name, ok := s.kinds[apiVersion+"/"+kind]
+	if !ok {
+		versions := s.apiVersions[kind]
+		if len(versions) == 0 {
+			return []Problem{{
+				Type:    ProblemUnknownKind,
+				Message: fmt.Sprintf("unknown kind %s with apiVersion %s in kubernetes %s", kind, apiVersion, s.Version),
+			}}
+		}
+		return []Problem{{
+			Type:    ProblemUnknownAPIVersion,
+			Field:   "apiVersion",
+			Message: fmt.Sprintf("unknown apiVersion %s for kind %s in kubernetes %s. Supported: %s", apiVersion, kind, s.Version, strings.Join(versions, ", ")),
+		}}
+	}
+	v := &validator{schemas: s}
+	v.validate("", obj, &Schema{Ref: definitionsPrefix + name})
+	return v.problems
+}
+
+type validator struct {
+	schemas  *Schemas
+	problems []Problem
+}
+
+func (v *validator) addProblem(problemType, field, message string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Type: problemType, Field: field, Message: fmt.Sprintf(message, args...)})
+}
+
+func (v *validator) validate(path string, value interface{}, schema *Schema) {
+	schema, name := v.resolve(schema)
+	if schema == nil || value == nil {
+		return
+	}
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		v.addProblem(ProblemEnum, path, "unsupported value %v. Supported: %s", value, enumText(schema.Enum))
+		return
+	}
+	if schema.IntOrString {
+		if _, ok := value.(string); !ok && !isInteger(value) {
+			v.addProblem(ProblemType, path, "expected an integer or a string but found %s", typeName(value))
+		}
+		return
+	}
+
+	isObject := schema.Type == "object" || len(schema.Properties) > 0 || schema.AdditionalProperties != nil
+	switch {
+	case isObject:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			v.addProblem(ProblemType, path, "expected an object but found %s", typeName(value))
+			return
+		}
+		v.validateObject(path, m, schema)
+
+	case schema.Type == "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			v.addProblem(ProblemType, path, "expected an array but found %s", typeName(value))
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range list {
+				v.validate(fmt.Sprintf("%s[%d]", path, i), item, schema.Items)
+			}
+		}
+
+	case schema.Type == "string":
+		if _, ok := value.(string); ok {
+			return
+		}
+		// lets allow numbers for quantities and int or strings
+		if isNumber(value) && (schema.Format == "int-or-string" || strings.HasSuffix(name, ".Quantity")) {
+			return
+		}
+		v.addProblem(ProblemType, path, "expected a string but found %s", typeName(value))
+
+	case schema.Type == "integer":
+		if !isInteger(value) {
+			v.addProblem(ProblemType, path, "expected an integer but found %s", typeName(value))
+		}
+
+	case schema.Type == "number":
+		if !isNumber(value) {
+			v.addProblem(ProblemType, path, "expected a number but found %s", typeName(value))
+		}
+
+	case schema.Type == "boolean":
+		if _, ok := value.(bool); !ok {
+			v.addProblem(ProblemType, path, "expected a boolean but found %s", typeName(value))
+		}
+	}
+}
+
+func (v *validator) validateObject(path string, m map[string]interface{}, schema *Schema) {
+	for _, required := range schema.Required {
+		if _, ok := m[required]; !ok {
+			v.addProblem(ProblemRequired, joinPath(path, required), "missing required field %s", required)
+		}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field := joinPath(path, k)
+		if p, ok := schema.Properties[k]; ok {
+			v.validate(field, m[k], p)
+			continue
+		}
+		if ap := schema.AdditionalProperties; ap != nil {
+			if ap.Schema != nil {
+				v.validate(field, m[k], ap.Schema)
+				continue
+			}
+			if ap.Allows {
+				continue
+			}
+		} else if len(schema.Properties) == 0 || schema.PreserveUnknownFields {
+			// lets allow any fields for free form objects
+			continue
+		}
+		message := fmt.Sprintf("unknown field %s", k)
+		if suggestion := closestField(k, schema.Properties); suggestion != "" {
+			message += fmt.Sprintf(". Did you mean %s?", suggestion)
+		}
+		v.addProblem(ProblemUnknownField, field, message)
+	}
+}
+
+// resolve resolves any references returning the schema and the name of the last definition referenced
+func (v *validator) resolve(schema *Schema) (*Schema, string) {
+	name := ""
+	for i := 0; schema != nil && schema.Ref != ""; i++ {
+		if i > 20 {
+			return nil, name
+		}
+		name = strings.TrimPrefix(schema.Ref, definitionsPrefix)
+		schema = v.schemas.Definitions[name]
+	}
+	return schema, name
+}
+
+// closestField returns the property closest to the field if it is a likely typo
+func closestField(field string, properties map[string]*Schema) string {
+	best := ""
+	bestDistance := 3
+	for p := range properties {
+		d := distance(strings.ToLower(field), strings.ToLower(p))
+		if d < bestDistance || (d == bestDistance && best != "" && p < best) {
+			best = p
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between the strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+		}
+		prev = current
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	answer := values[0]
+	for _, v := range values[1:] {
+		if v < answer {
+			answer = v
+		}
+	}
+	return answer
+}
+
+// containsValue returns true if the value is one of the values comparing numbers by value
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if isNumber(v) && isNumber(value) {
+			if toFloat(v) == toFloat(value) {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumText(values []interface{}) string {
+	var texts []string
+	for _, v := range values {
+		texts = append(texts, fmt.Sprintf("%v", v))
+	}
+	return strings.Join(texts, ", ")
+}
+
+func toFloat(value interface{}) float64 {
+	switch n := value.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int32, int64, float32, float64:
+		return true
+	}
+	return false
+}
+
+func isInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	case float32:
+		return n == float32(int64(n))
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	if isNumber(value) {
+		return "a number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// DefaultSchemaDir returns the default directory of the cached schemas
+func DefaultSchemaDir() string {
+	home := os.Getenv("JX_GITOPS_HOME")
+	if home == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		home = filepath.Join(dir, ".jx-gitops")
+	}
+	return filepath.Join(home, "schemas")
+}
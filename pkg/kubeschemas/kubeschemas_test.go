@@ -0,0 +1,180 @@
+package kubeschemas_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/kubeschemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	schemas, err := kubeschemas.Load("", "")
+	require.NoError(t, err, "failed to load the bundled schemas")
+	assert.Equal(t, kubeschemas.BundledVersion, schemas.Version)
+
+	testCases := []struct {
+		name     string
+		obj      map[string]interface{}
+		expected []kubeschemas.Problem
+	}{
+		{
+			name: "valid",
+			obj: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":   "myapp",
+					"labels": map[string]interface{}{"app": "myapp"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": float64(2),
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "myapp"}},
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name":      "myapp",
+									"image":     "myapp:1.0.0",
+									"ports":     []interface{}{map[string]interface{}{"containerPort": float64(8080)}},
+									"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": float64(1), "memory": "1Gi"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "problems",
+			obj: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "myapp"},
+				"spec": map[string]interface{}{
+					"replica":  float64(2),
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "myapp"}},
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"image": "myapp:1.0.0",
+									"tty":   "yes",
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []kubeschemas.Problem{
+				{Type: kubeschemas.ProblemUnknownField, Field: "spec.replica", Message: "unknown field replica. Did you mean replicas?"},
+				{Type: kubeschemas.ProblemRequired, Field: "spec.template.spec.containers[0].name", Message: "missing required field name"},
+				{Type: kubeschemas.ProblemType, Field: "spec.template.spec.containers[0].tty", Message: "expected a boolean but found a string"},
+			},
+		},
+		{
+			name: "unknown-api-version",
+			obj: map[string]interface{}{
+				"apiVersion": "extensions/v2",
+				"kind":       "Deployment",
+			},
+			expected: []kubeschemas.Problem{
+				{Type: kubeschemas.ProblemUnknownAPIVersion, Field: "apiVersion", Message: "unknown apiVersion extensions/v2 for kind Deployment in kubernetes v1.17. Supported: apps/v1, apps/v1beta1, apps/v1beta2, extensions/v1beta1"},
+			},
+		},
+		{
+			name: "unknown-kind",
+			obj: map[string]interface{}{
+				"apiVersion": "tekton.dev/v1beta1",
+				"kind":       "Pipeline",
+			},
+			expected: []kubeschemas.Problem{
+				{Type: kubeschemas.ProblemUnknownKind, Message: "unknown kind Pipeline with apiVersion tekton.dev/v1beta1 in kubernetes v1.17"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		problems := schemas.Validate(tc.obj)
+		assert.Equal(t, tc.expected, problems, "for %s", tc.name)
+	}
+}
+
+func TestLoadSchemaDir(t *testing.T) {
+	schemas, err := kubeschemas.Load("1.99.2", "test_data")
+	require.NoError(t, err, "failed to load the schemas")
+	assert.Equal(t, "v1.99", schemas.Version)
+
+	problems := schemas.Validate(map[string]interface{}{
+		"apiVersion": "example.io/v1",
+		"kind":       "Widget",
+		"spec":       map[string]interface{}{"size": "large", "colour": "red"},
+	})
+	assert.Equal(t, []kubeschemas.Problem{
+		{Type: kubeschemas.ProblemUnknownField, Field: "spec.colour", Message: "unknown field colour"},
+		{Type: kubeschemas.ProblemType, Field: "spec.size", Message: "expected an integer but found a string"},
+	}, problems)
+
+	_, err = kubeschemas.Load("v1.42", "test_data")
+	require.Error(t, err, "should fail for a missing version")
+	t.Logf("got expected error: %s", err.Error())
+}
+
+func TestAddCRD(t *testing.T) {
+	schemas, err := kubeschemas.Load("", "")
+	require.NoError(t, err, "failed to load the bundled schemas")
+
+	crd := map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1beta1",
+		"kind":       "CustomResourceDefinition",
+		"spec": map[string]interface{}{
+			"group":                 "example.io",
+			"version":               "v1beta1",
+			"preserveUnknownFields": false,
+			"names":                 map[string]interface{}{"kind": "Gadget"},
+			"validation": map[string]interface{}{
+				"openAPIV3Schema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"enabled": map[string]interface{}{"type": "boolean"},
+								"level":   map[string]interface{}{"type": "integer", "enum": []interface{}{int64(1), int64(2)}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.True(t, kubeschemas.IsCRD(crd), "should be a CRD")
+	added, err := schemas.AddCRD(crd)
+	require.NoError(t, err, "failed to add the CRD")
+	assert.True(t, added, "should have added the CRD")
+
+	added, err = schemas.AddCRD(crd)
+	require.NoError(t, err, "failed to add the CRD again")
+	assert.False(t, added, "should not add the CRD twice")
+
+	problems := schemas.Validate(map[string]interface{}{
+		"apiVersion": "example.io/v1beta1",
+		"kind":       "Gadget",
+		"metadata":   map[string]interface{}{"name": "thing", "labelz": map[string]interface{}{}},
+		"spec":       map[string]interface{}{"enabled": true, "level": float64(2)},
+		"extra":      "field",
+	})
+	assert.Equal(t, []kubeschemas.Problem{
+		{Type: kubeschemas.ProblemUnknownField, Field: "extra", Message: "unknown field extra"},
+		{Type: kubeschemas.ProblemUnknownField, Field: "metadata.labelz", Message: "unknown field labelz. Did you mean labels?"},
+	}, problems)
+
+	problems = schemas.Validate(map[string]interface{}{
+		"apiVersion": "example.io/v1beta1",
+		"kind":       "Gadget",
+		"spec":       map[string]interface{}{"level": float64(3)},
+	})
+	assert.Equal(t, []kubeschemas.Problem{
+		{Type: kubeschemas.ProblemEnum, Field: "spec.level", Message: "unsupported value 3. Supported: 1, 2"},
+	}, problems)
+}
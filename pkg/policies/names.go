@@ -0,0 +1,85 @@
+package policies
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// RuleNamePattern the name of the rule which checks names match a regular expression
+	RuleNamePattern = "name-pattern"
+
+	// RuleNameLength the name of the rule which checks the maximum length of names
+	RuleNameLength = "name-length"
+)
+
+// NamePatternRule checks the names of the resources match a regular expression
+type NamePatternRule struct {
+	// Kinds the kinds of resource the rule applies to. If empty it applies to all kinds
+	Kinds []string
+
+	// ExcludeKinds the kinds of resource the rule does not apply to such as the kinds with their own pattern
+	ExcludeKinds []string
+
+	// Pattern the regular expression the names must match
+	Pattern *regexp.Regexp
+}
+
+// Name returns the name of the rule
+func (r *NamePatternRule) Name() string {
+	return RuleNamePattern
+}
+
+// Check returns the description of how the name of the resource does not match the pattern
+func (r *NamePatternRule) Check(u *unstructured.Unstructured) string {
+	if r.Pattern == nil || !matchesKinds(u, r.Kinds, r.ExcludeKinds) {
+		return ""
+	}
+	name := u.GetName()
+	if r.Pattern.MatchString(name) {
+		return ""
+	}
+	return fmt.Sprintf("the name '%s' does not match the pattern '%s'", name, r.Pattern.String())
+}
+
+// NameLengthRule checks the names of the resources are not too long such as for Services which are used in DNS
+type NameLengthRule struct {
+	// Kinds the kinds of resource the rule applies to. If empty it applies to all kinds
+	Kinds []string
+
+	// MaxLength the maximum length of the names
+	MaxLength int
+}
+
+// Name returns the name of the rule
+func (r *NameLengthRule) Name() string {
+	return RuleNameLength
+}
+
+// Check returns the description of how the name of the resource is too long
+func (r *NameLengthRule) Check(u *unstructured.Unstructured) string {
+	if r.MaxLength <= 0 || !matchesKinds(u, r.Kinds, nil) {
+		return ""
+	}
+	name := u.GetName()
+	if len(name) <= r.MaxLength {
+		return ""
+	}
+	return fmt.Sprintf("the name '%s' has %d characters which is more than the maximum of %d", name, len(name), r.MaxLength)
+}
+
+// matchesKinds returns true if the resource matches any of the kinds, or there are no kinds, and none of the
+// excluded kinds
+func matchesKinds(u *unstructured.Unstructured, kinds, excludeKinds []string) bool {
+	if len(excludeKinds) > 0 {
+		exclude := resourcehelpers.Selector{Kinds: excludeKinds}
+		if exclude.Matches(u) {
+			return false
+		}
+	}
+	include := resourcehelpers.Selector{Kinds: kinds}
+	return include.Matches(u)
+}
@@ -0,0 +1,132 @@
+package policies
+
+import (
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Rule a policy which the kubernetes resources must satisfy
+type Rule interface {
+	// Name returns the name of the rule which is reported in the violations
+	Name() string
+
+	// Check returns the description of how the resource breaks the rule or blank if the resource satisfies the
+	// rule or the rule does not apply to it
+	Check(u *unstructured.Unstructured) string
+}
+
+// Violation a resource which breaks a rule
+type Violation struct {
+	// File the file containing the resource relative to the directory
+	File string `json:"file"`
+
+	// Document the index of the document of the resource in the file
+	Document int `json:"document"`
+
+	// Kind the kind of the resource
+	Kind string `json:"kind"`
+
+	// Namespace the namespace of the resource if it has one
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name the name of the resource
+	Name string `json:"name"`
+
+	// Rule the name of the rule which is broken
+	Rule string `json:"rule"`
+
+	// Message the description of how the rule is broken
+	Message string `json:"message"`
+
+	// Suggestion the suggested value which would satisfy the rule if the rule can suggest one
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Suggester a rule which can suggest how to fix a violation
+type Suggester interface {
+	// Suggest returns the suggested value such as a name which would satisfy the rule or blank if there is no
+	// suggestion
+	Suggest(u *unstructured.Unstructured) string
+}
+
+// Engine checks kubernetes resources against rules
+type Engine struct {
+	// Selector the resources which are checked. If empty all the resources are checked
+	Selector resourcehelpers.Selector
+
+	// Rules the rules to check
+	Rules []Rule
+
+	// Exempt the selectors of the resources which are not checked
+	Exempt []resourcehelpers.Selector
+
+	// SkipGenerateName if enabled resources which have a generateName and no name are not checked
+	SkipGenerateName bool
+}
+
+// IsExempt returns true if the resource is not checked
+func (e *Engine) IsExempt(u *unstructured.Unstructured) bool {
+	if e.SkipGenerateName && u.GetName() == "" && u.GetGenerateName() != "" {
+		return true
+	}
+	for i := range e.Exempt {
+		if e.Exempt[i].Matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckResource returns the violations of the rules by the resource
+func (e *Engine) CheckResource(u *unstructured.Unstructured) []Violation {
+	if e.IsExempt(u) {
+		return nil
+	}
+	var answer []Violation
+	for _, rule := range e.Rules {
+		message := rule.Check(u)
+		if message == "" {
+			continue
+		}
+		v := Violation{
+			Kind:      u.GetKind(),
+			Namespace: u.GetNamespace(),
+			Name:      u.GetName(),
+			Rule:      rule.Name(),
+			Message:   message,
+		}
+		if s, ok := rule.(Suggester); ok {
+			v.Suggestion = s.Suggest(u)
+		}
+		answer = append(answer, v)
+	}
+	return answer
+}
+
+// CheckDir returns the violations of the rules by the resources in the directory tree
+func (e *Engine) CheckDir(dir string) ([]Violation, error) {
+	var answer []Violation
+	err := resourcehelpers.VisitDocuments(dir, e.Selector, func(u *unstructured.Unstructured, path string, index int) error {
+		violations := e.CheckResource(u)
+		if len(violations) == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		for i := range violations {
+			violations[i].File = filepath.ToSlash(rel)
+			violations[i].Document = index
+		}
+		answer = append(answer, violations...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check the resources in dir %s", dir)
+	}
+	return answer, nil
+}
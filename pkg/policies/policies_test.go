@@ -0,0 +1,183 @@
+package policies_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/policies"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRules(t *testing.T) {
+	pattern := regexp.MustCompile("^[a-z][a-z0-9-]{1,20}$")
+	teamPattern := regexp.MustCompile("^team-")
+
+	testCases := []struct {
+		name     string
+		rule     policies.Rule
+		resource *unstructured.Unstructured
+		expected string
+	}{
+		{
+			name:     "matching pattern",
+			rule:     &policies.NamePatternRule{Pattern: pattern},
+			resource: newResource("Deployment", "web"),
+		},
+		{
+			name:     "uppercase name",
+			rule:     &policies.NamePatternRule{Pattern: pattern},
+			resource: newResource("Deployment", "Web"),
+			expected: "the name 'Web' does not match the pattern '^[a-z][a-z0-9-]{1,20}$'",
+		},
+		{
+			name:     "pattern of another kind",
+			rule:     &policies.NamePatternRule{Kinds: []string{"Service"}, Pattern: teamPattern},
+			resource: newResource("Deployment", "web"),
+		},
+		{
+			name:     "pattern of the kind",
+			rule:     &policies.NamePatternRule{Kinds: []string{"Service"}, Pattern: teamPattern},
+			resource: newResource("Service", "web"),
+			expected: "the name 'web' does not match the pattern '^team-'",
+		},
+		{
+			name:     "pattern of an api version",
+			rule:     &policies.NamePatternRule{Kinds: []string{"v1/Service"}, Pattern: teamPattern},
+			resource: newResource("Service", "web"),
+			expected: "the name 'web' does not match the pattern '^team-'",
+		},
+		{
+			name:     "excluded kind",
+			rule:     &policies.NamePatternRule{ExcludeKinds: []string{"Service"}, Pattern: pattern},
+			resource: newResource("Service", "Web"),
+		},
+		{
+			name:     "short name",
+			rule:     &policies.NameLengthRule{Kinds: []string{"Service"}, MaxLength: 5},
+			resource: newResource("Service", "web"),
+		},
+		{
+			name:     "long name",
+			rule:     &policies.NameLengthRule{Kinds: []string{"Service"}, MaxLength: 5},
+			resource: newResource("Service", "web-front"),
+			expected: "the name 'web-front' has 9 characters which is more than the maximum of 5",
+		},
+		{
+			name:     "long name of another kind",
+			rule:     &policies.NameLengthRule{Kinds: []string{"Service"}, MaxLength: 5},
+			resource: newResource("Deployment", "web-front"),
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.rule.Check(tc.resource), tc.name)
+	}
+}
+
+func TestRFC1123NameRule(t *testing.T) {
+	rule := &policies.RFC1123NameRule{
+		Formats:   policies.DefaultNameFormats,
+		SkipKinds: policies.DefaultSkipNameKinds,
+	}
+	longName := "web-" + strings.Repeat("a", 70)
+
+	testCases := []struct {
+		kind       string
+		name       string
+		invalid    bool
+		suggestion string
+	}{
+		{kind: "ConfigMap", name: "web.config"},
+		{kind: "ConfigMap", name: "Web_Config", invalid: true, suggestion: "web-config"},
+		{kind: "ConfigMap", name: longName},
+		{kind: "Namespace", name: "web.config", invalid: true, suggestion: "web-config"},
+		{kind: "Namespace", name: longName, invalid: true, suggestion: "web-" + strings.Repeat("a", 53) + "-c4aae"},
+		{kind: "Service", name: "web"},
+		{kind: "Service", name: "1st-web", invalid: true, suggestion: "st-web"},
+		{kind: "Service", name: "--", invalid: true},
+		{kind: "ClusterRole", name: "system:web"},
+	}
+	for _, tc := range testCases {
+		u := newResource(tc.kind, tc.name)
+		message := rule.Check(u)
+		if !tc.invalid {
+			assert.Empty(t, message, "%s/%s", tc.kind, tc.name)
+			continue
+		}
+		assert.NotEmpty(t, message, "%s/%s", tc.kind, tc.name)
+		suggestion := rule.Suggest(u)
+		assert.Equal(t, tc.suggestion, suggestion, "suggestion for %s/%s", tc.kind, tc.name)
+		if suggestion != "" {
+			assert.Empty(t, rule.Check(newResource(tc.kind, suggestion)), "the suggestion %s for %s/%s should be valid", suggestion, tc.kind, tc.name)
+		}
+	}
+}
+
+func TestEngineExempt(t *testing.T) {
+	generated := newResource("Job", "")
+	generated.SetGenerateName("migrate-")
+	labelled := newResource("Deployment", "Legacy")
+	labelled.SetLabels(map[string]string{"lint": "ignore"})
+
+	testCases := []struct {
+		name     string
+		engine   policies.Engine
+		resource *unstructured.Unstructured
+		expected []string
+	}{
+		{
+			name:     "violation",
+			resource: newResource("Deployment", "Web"),
+			expected: []string{policies.RuleNamePattern},
+		},
+		{
+			name:     "generate name",
+			engine:   policies.Engine{SkipGenerateName: true},
+			resource: generated,
+		},
+		{
+			name:     "generate name not skipped",
+			resource: generated,
+			expected: []string{policies.RuleNamePattern},
+		},
+		{
+			name:     "exempt labels",
+			engine:   policies.Engine{Exempt: []resourcehelpers.Selector{{Labels: map[string]string{"lint": "ignore"}}}},
+			resource: labelled,
+		},
+		{
+			name:     "exempt names",
+			engine:   policies.Engine{Exempt: []resourcehelpers.Selector{{Kinds: []string{"Deployment"}, Names: []string{"W*"}}}},
+			resource: newResource("Deployment", "Web"),
+		},
+		{
+			name:     "exempt another kind",
+			engine:   policies.Engine{Exempt: []resourcehelpers.Selector{{Kinds: []string{"Secret"}, Names: []string{"W*"}}}},
+			resource: newResource("Deployment", "Web"),
+			expected: []string{policies.RuleNamePattern},
+		},
+	}
+	for _, tc := range testCases {
+		engine := tc.engine
+		engine.Rules = []policies.Rule{&policies.NamePatternRule{Pattern: regexp.MustCompile("^[a-z]+$")}}
+		var rules []string
+		for _, v := range engine.CheckResource(tc.resource) {
+			assert.Equal(t, tc.resource.GetKind(), v.Kind, "kind of %s", tc.name)
+			rules = append(rules, v.Rule)
+		}
+		assert.Equal(t, tc.expected, rules, tc.name)
+	}
+}
+
+func newResource(kind, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	if kind == "Deployment" {
+		u.SetAPIVersion("apps/v1")
+	}
+	u.SetKind(kind)
+	u.SetName(name)
+	return u
+}
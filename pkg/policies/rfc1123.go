@@ -0,0 +1,137 @@
+package policies
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// RuleRFC1123 the name of the rule which checks names are valid RFC 1123 DNS names for their kind
+	RuleRFC1123 = "rfc1123"
+
+	// NameFormatSubdomain the names must be DNS-1123 subdomains of up to 253 characters which is the default
+	NameFormatSubdomain = "subdomain"
+
+	// NameFormatLabel the names must be DNS-1123 labels of up to 63 characters
+	NameFormatLabel = "label"
+
+	// NameFormatDNS1035Label the names must be DNS-1035 labels of up to 63 characters which start with a letter
+	NameFormatDNS1035Label = "dns1035-label"
+
+	// suggestionHashLength the number of characters of the hash appended to the truncated suggested names so that
+	// they remain unique
+	suggestionHashLength = 5
+)
+
+var (
+	// DefaultNameFormats the formats of the names of the kinds which are not DNS-1123 subdomains
+	DefaultNameFormats = map[string]string{
+		"Namespace": NameFormatLabel,
+		"Service":   NameFormatDNS1035Label,
+	}
+
+	// DefaultSkipNameKinds the kinds whose names do not need to be DNS names such as RBAC resources which
+	// can contain ':'
+	DefaultSkipNameKinds = []string{"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"}
+
+	invalidLabelChars     = regexp.MustCompile(`[^a-z0-9-]+`)
+	invalidSubdomainChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+	repeatedDashes        = regexp.MustCompile(`-{2,}`)
+)
+
+// RFC1123NameRule checks the names of the resources are valid DNS names so that they can be applied
+type RFC1123NameRule struct {
+	// Formats the formats of the names indexed by kind. Kinds which are not in the map must be subdomains
+	Formats map[string]string
+
+	// SkipKinds the kinds which are not checked
+	SkipKinds []string
+}
+
+// Name returns the name of the rule
+func (r *RFC1123NameRule) Name() string {
+	return RuleRFC1123
+}
+
+// Check returns the description of how the name of the resource is invalid
+func (r *RFC1123NameRule) Check(u *unstructured.Unstructured) string {
+	name := u.GetName()
+	if name == "" || resourcehelpers.MatchesAnyPattern(u.GetKind(), r.SkipKinds) {
+		return ""
+	}
+	format := r.format(u.GetKind())
+	msgs := validateName(format, name)
+	if len(msgs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("the name '%s' is not a valid %s: %s", name, describeFormat(format), strings.Join(msgs, "; "))
+}
+
+// Suggest returns a valid name based on the invalid name of the resource
+func (r *RFC1123NameRule) Suggest(u *unstructured.Unstructured) string {
+	return SuggestName(r.format(u.GetKind()), u.GetName())
+}
+
+func (r *RFC1123NameRule) format(kind string) string {
+	if format := r.Formats[kind]; format != "" {
+		return format
+	}
+	return NameFormatSubdomain
+}
+
+// SuggestName returns a valid name of the given format based on the name by lower casing it, replacing the invalid
+// characters with '-' and truncating it with a hash of the name appended so that different long names do not
+// result in the same name. Returns blank if no valid name can be suggested
+func SuggestName(format, name string) string {
+	invalidChars := invalidLabelChars
+	maxLength := validation.DNS1123LabelMaxLength
+	if format == NameFormatSubdomain {
+		invalidChars = invalidSubdomainChars
+		maxLength = validation.DNS1123SubdomainMaxLength
+	}
+	answer := invalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	answer = repeatedDashes.ReplaceAllString(answer, "-")
+	answer = strings.Trim(answer, "-.")
+	if format == NameFormatDNS1035Label {
+		answer = strings.TrimLeft(answer, "0123456789-")
+	}
+	if answer == "" {
+		return ""
+	}
+	if len(answer) > maxLength {
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:suggestionHashLength]
+		answer = strings.TrimRight(answer[:maxLength-suggestionHashLength-1], "-.") + "-" + hash
+	}
+	if len(validateName(format, answer)) > 0 {
+		return ""
+	}
+	return answer
+}
+
+func validateName(format, name string) []string {
+	switch format {
+	case NameFormatLabel:
+		return validation.IsDNS1123Label(name)
+	case NameFormatDNS1035Label:
+		return validation.IsDNS1035Label(name)
+	default:
+		return validation.IsDNS1123Subdomain(name)
+	}
+}
+
+func describeFormat(format string) string {
+	switch format {
+	case NameFormatLabel:
+		return "DNS-1123 label"
+	case NameFormatDNS1035Label:
+		return "DNS-1035 label"
+	default:
+		return "DNS-1123 subdomain"
+	}
+}
@@ -0,0 +1,269 @@
+package registries
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DockerHubHost the host of the docker hub registry API
+	DockerHubHost = "registry-1.docker.io"
+
+	// DigestHeader the header of the registry API containing the digest of the manifest
+	DigestHeader = "Docker-Content-Digest"
+)
+
+var (
+	// manifestMediaTypes the media types of the manifests to accept so that the digest of multi-platform images
+	// is the digest of the index rather than the manifest of a single platform
+	manifestMediaTypes = []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}
+)
+
+// Client resolves the digests of images in container registries
+type Client interface {
+	// Digest returns the digest of the manifest of the tag of the repository in the registry
+	Digest(registry, repository, tag string) (string, error)
+}
+
+// HTTPClient a client of the docker registry v2 API used by Docker Hub, GHCR, GCR, Artifact Registry and
+// other registries. Anonymous and bearer token authentication challenges are handled using the credentials
+// of the registry
+type HTTPClient struct {
+	// Client the HTTP client to use. Defaults to http.DefaultClient
+	Client *http.Client
+
+	// Credentials the credentials of each registry
+	Credentials CredentialSet
+
+	// Scheme the scheme of the registry API. Defaults to https
+	Scheme string
+}
+
+// NewClient creates a client of the registry API using the given credentials
+func NewClient(credentials CredentialSet) *HTTPClient {
+	return &HTTPClient{Credentials: credentials}
+}
+
+// Digest returns the digest of the manifest of the tag of the repository by requesting the headers of the manifest
+func (c *HTTPClient) Digest(registry, repository, tag string) (string, error) {
+	host := NormalizeHost(registry)
+	if host == DockerHubHost && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, repository, tag)
+
+	resp, err := c.head(u, host, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		authorization, err := c.authorize(host, repository, challenge)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to authenticate with registry %s", host)
+		}
+		resp, err = c.head(u, host, authorization)
+		if err != nil {
+			return "", err
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", errors.Errorf("tag %s of %s not found in registry %s", tag, repository, host)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", errors.Errorf("not authorized to access %s in registry %s: %s", repository, host, resp.Status)
+	default:
+		return "", errors.Errorf("failed to get the manifest of %s:%s from registry %s: %s", repository, tag, host, resp.Status)
+	}
+	digest := resp.Header.Get(DigestHeader)
+	if digest == "" {
+		return "", errors.Errorf("no %s header returned for %s:%s by registry %s", DigestHeader, repository, tag, host)
+	}
+	return digest, nil
+}
+
+func (c *HTTPClient) head(u, host, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to request %s", u)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// authorize returns the authorization header for the challenge of the registry requesting a bearer token if required
+func (c *HTTPClient) authorize(host, repository, challenge string) (string, error) {
+	creds := c.Credentials.Find(host)
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds.IsEmpty() || creds.Username == "" {
+			return "", errors.Errorf("no credentials for registry %s", host)
+		}
+		return basicAuthorization(creds), nil
+	case "bearer":
+	default:
+		return "", errors.Errorf("unsupported authentication challenge '%s'", challenge)
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("no realm in authentication challenge '%s'", challenge)
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse realm %s", realm)
+	}
+	query := u.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + repository + ":pull"
+	}
+	query.Set("scope", scope)
+	u.RawQuery = query.Encode()
+
+	req, err := tokenRequest(u, creds)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create token request for %s", realm)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to request token from %s", realm)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to request token from %s: %s", realm, resp.Status)
+	}
+	result := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse token response from %s", realm)
+	}
+	token := result.Token
+	if token == "" {
+		token = result.AccessToken
+	}
+	if token == "" {
+		return "", errors.Errorf("no token returned from %s", realm)
+	}
+	return "Bearer " + token, nil
+}
+
+// tokenRequest creates the request for a bearer token. An identity token is exchanged for an access token via the
+// OAuth2 refresh token grant otherwise the token is requested using any user name and password
+func tokenRequest(u *url.URL, creds *Credentials) (*http.Request, error) {
+	if creds.IsEmpty() || creds.IdentityToken == "" {
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if !creds.IsEmpty() && creds.Username != "" {
+			req.Header.Set("Authorization", basicAuthorization(creds))
+		}
+		return req, nil
+	}
+	query := u.Query()
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", creds.IdentityToken)
+	form.Set("service", query.Get("service"))
+	form.Set("scope", query.Get("scope"))
+	form.Set("client_id", "jx-gitops")
+	tokenURL := *u
+	tokenURL.RawQuery = ""
+	req, err := http.NewRequest(http.MethodPost, tokenURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+func (c *HTTPClient) httpClient() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return http.DefaultClient
+}
+
+func basicAuthorization(creds *Credentials) string {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(creds.Username, creds.Password)
+	return req.Header.Get("Authorization")
+}
+
+// parseChallenge parses the scheme and parameters of a WWW-Authenticate header such as
+// 'Bearer realm="https://auth.docker.io/token",service="registry.docker.io"'
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	challenge = strings.TrimSpace(challenge)
+	idx := strings.Index(challenge, " ")
+	if idx < 0 {
+		return challenge, params
+	}
+	scheme := challenge[:idx]
+	rest := challenge[idx+1:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		value := ""
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value = rest[1:]
+				rest = ""
+			} else {
+				value = rest[1 : end+1]
+				rest = rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				value = rest
+				rest = ""
+			} else {
+				value = rest[:end]
+				rest = rest[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return scheme, params
+}
@@ -0,0 +1,194 @@
+package registries_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/registries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+// newFakeRegistry creates a registry which requires a bearer token from its token endpoint which is only
+// issued for the user 'myuser' with the password 'mypassword'
+func newFakeRegistry(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/token":
+			user, password, ok := r.BasicAuth()
+			if !ok || user != "myuser" || password != "mypassword" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "myregistry", r.URL.Query().Get("service"), "service of the token request")
+			assert.Equal(t, "repository:org/app:pull", r.URL.Query().Get("scope"), "scope of the token request")
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "mytoken"})
+
+		case strings.HasPrefix(r.URL.Path, "/v2/"):
+			if r.Header.Get("Authorization") != "Bearer mytoken" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="myregistry",scope="repository:org/app:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json", "accepted media types")
+			if r.URL.Path != "/v2/org/app/manifests/1.0.0" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set(registries.DigestHeader, testDigest)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &requests
+}
+
+func TestHTTPClientDigest(t *testing.T) {
+	server, requests := newFakeRegistry(t)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err, "failed to parse server URL")
+	host := u.Host
+
+	client := registries.NewClient(registries.CredentialSet{
+		host: {Username: "myuser", Password: "mypassword"},
+	})
+	client.Client = server.Client()
+
+	digest, err := client.Digest(host, "org/app", "1.0.0")
+	require.NoError(t, err, "failed to get the digest")
+	assert.Equal(t, testDigest, digest, "digest")
+	assert.Equal(t, []string{"HEAD /v2/org/app/manifests/1.0.0", "GET /token", "HEAD /v2/org/app/manifests/1.0.0"}, *requests, "requests")
+
+	_, err = client.Digest(host, "org/app", "2.0.0")
+	require.Error(t, err, "should fail for a missing tag")
+	assert.Contains(t, err.Error(), "tag 2.0.0 of org/app not found", "error message")
+
+	client.Credentials = registries.CredentialSet{}
+	_, err = client.Digest(host, "org/app", "1.0.0")
+	require.Error(t, err, "should fail without credentials")
+	assert.Contains(t, err.Error(), "failed to authenticate", "error message")
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	path := filepath.Join(tmpDir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "ZG9ja2VydXNlcjpzM2NyZXQ="},
+    "ghcr.io": {"username": "ghuser", "password": "ghp_token"},
+    "europe-docker.pkg.dev": {"identitytoken": "refresh"},
+    "empty.io": {}
+  }
+}`), 0600)
+	require.NoError(t, err, "failed to write docker config")
+
+	creds, err := registries.LoadDockerConfig(path)
+	require.NoError(t, err, "failed to load docker config")
+	assert.Len(t, creds, 3, "credentials")
+	assert.Equal(t, &registries.Credentials{Username: "dockeruser", Password: "s3cret"}, creds.Find("docker.io"), "docker hub credentials")
+	assert.Equal(t, &registries.Credentials{Username: "ghuser", Password: "ghp_token"}, creds.Find("ghcr.io"), "GHCR credentials")
+	assert.Equal(t, &registries.Credentials{IdentityToken: "refresh"}, creds.Find("europe-docker.pkg.dev"), "artifact registry credentials")
+	assert.Nil(t, creds.Find("empty.io"), "empty credentials should be ignored")
+
+	creds, err = registries.LoadDockerConfig(filepath.Join(tmpDir, "missing.json"))
+	require.NoError(t, err, "a missing docker config should be ignored")
+	assert.Empty(t, creds, "credentials of missing file")
+}
+
+func TestLoadDockerConfigCredentialHelpers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the credential helpers are shell scripts")
+	}
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+	t.Cleanup(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	writeHelper(t, tmpDir, "desktop", `read host
+case "$host" in
+  store.io) echo '{"ServerURL":"store.io","Username":"storeuser","Secret":"storepassword"}';;
+  *) echo 'credentials not found in native keychain'; exit 1;;
+esac`)
+	writeHelper(t, tmpDir, "gcloud", `echo '{"ServerURL":"gcr.io","Username":"<token>","Secret":"refresh"}'`)
+	oldPath := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", tmpDir), "failed to set $PATH")
+	t.Cleanup(func() {
+		_ = os.Setenv("PATH", oldPath)
+	})
+
+	path := filepath.Join(tmpDir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+  "auths": {
+    "ghcr.io": {"username": "ghuser", "password": "ghp_token"},
+    "store.io": {},
+    "empty.io": {},
+    "gcr.io": {"username": "old", "password": "old"}
+  },
+  "credsStore": "desktop",
+  "credHelpers": {
+    "gcr.io": "gcloud"
+  }
+}`), 0600)
+	require.NoError(t, err, "failed to write docker config")
+
+	creds, err := registries.LoadDockerConfig(path)
+	require.NoError(t, err, "failed to load docker config")
+	assert.Len(t, creds, 3, "credentials")
+	assert.Equal(t, &registries.Credentials{Username: "ghuser", Password: "ghp_token"}, creds.Find("ghcr.io"), "the credentials in the file should be used")
+	assert.Equal(t, &registries.Credentials{Username: "storeuser", Password: "storepassword"}, creds.Find("store.io"), "credentials of the credsStore")
+	assert.Equal(t, &registries.Credentials{IdentityToken: "refresh"}, creds.Find("gcr.io"), "the credHelpers should take precedence")
+	assert.Nil(t, creds.Find("empty.io"), "hosts without credentials in the credsStore should be ignored")
+
+	err = ioutil.WriteFile(path, []byte(`{"credHelpers": {"ecr.io": "ecr-login"}}`), 0600)
+	require.NoError(t, err, "failed to write docker config")
+	_, err = registries.LoadDockerConfig(path)
+	require.Error(t, err, "should fail if the credential helper is not installed")
+	t.Logf("got expected error: %s", err.Error())
+	assert.Contains(t, err.Error(), "the docker credential helper docker-credential-ecr-login is not installed", "error")
+}
+
+func writeHelper(t *testing.T, dir, helper, script string) {
+	path := filepath.Join(dir, registries.CredentialHelperPrefix+helper)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0700)
+	require.NoError(t, err, "failed to write %s", path)
+}
+
+func TestParseCredentials(t *testing.T) {
+	host, creds, err := registries.ParseCredentials("docker.io=user:pass:word")
+	require.NoError(t, err, "failed to parse credentials")
+	assert.Equal(t, registries.DockerHubHost, host, "host")
+	assert.Equal(t, &registries.Credentials{Username: "user", Password: "pass:word"}, creds, "credentials")
+
+	for _, text := range []string{"", "ghcr.io", "=user:password", "ghcr.io=user", "ghcr.io=:password"} {
+		_, _, err = registries.ParseCredentials(text)
+		assert.Error(t, err, "should fail to parse '%s'", text)
+	}
+
+	_, _, err = registries.ParseCredentials("ghcr.io:secret-token")
+	require.Error(t, err, "should fail to parse credentials without a host")
+	assert.NotContains(t, err.Error(), "secret-token", "the error should not include the credentials")
+
+	set := registries.CredentialSet{"https://index.docker.io/v1/": {Username: "old"}}
+	set.Merge(registries.CredentialSet{"docker.io": {Username: "new"}})
+	assert.Len(t, set, 1, "merged credentials")
+	assert.Equal(t, "new", set.Find(registries.DockerHubHost).Username, "the merged credentials should replace the existing ones")
+}
@@ -0,0 +1,238 @@
+package registries
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DockerConfigEnvVar the environment variable of the directory of the docker config file
+	DockerConfigEnvVar = "DOCKER_CONFIG"
+
+	// DockerConfigFileName the name of the docker config file
+	DockerConfigFileName = "config.json"
+
+	// CredentialHelperPrefix the prefix of the binaries of the docker credential helpers
+	CredentialHelperPrefix = "docker-credential-"
+
+	// identityTokenUsername the user name returned by credential helpers when the secret is an identity token
+	identityTokenUsername = "<token>"
+)
+
+// Credentials the credentials of a registry
+type Credentials struct {
+	// Username the user name for basic authentication or to request a token
+	Username string
+
+	// Password the password or access token of the user
+	Password string
+
+	// IdentityToken the refresh token used to request an access token instead of the user name and password
+	IdentityToken string
+}
+
+// IsEmpty returns true if there are no credentials
+func (c *Credentials) IsEmpty() bool {
+	return c == nil || (c.Username == "" && c.Password == "" && c.IdentityToken == "")
+}
+
+// CredentialSet the credentials of each registry host
+type CredentialSet map[string]*Credentials
+
+// Find returns the credentials of the registry host or nil if there are none
+func (s CredentialSet) Find(host string) *Credentials {
+	host = NormalizeHost(host)
+	for key, c := range s {
+		if NormalizeHost(key) == host {
+			return c
+		}
+	}
+	return nil
+}
+
+// Merge adds the credentials of the other set replacing any credentials for the same registry
+func (s CredentialSet) Merge(other CredentialSet) {
+	for key, c := range other {
+		host := NormalizeHost(key)
+		for existing := range s {
+			if NormalizeHost(existing) == host {
+				delete(s, existing)
+			}
+		}
+		s[host] = c
+	}
+}
+
+// dockerConfig the auths and credential helpers of the docker config file
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+type dockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// helperCredentials the output of the get command of a docker credential helper
+type helperCredentials struct {
+	Username string `json:"Username"`
+	Secret   string `json:"Secret"`
+}
+
+// DefaultDockerConfigFile returns the path of the docker config file using $DOCKER_CONFIG if it is set
+// otherwise ~/.docker/config.json
+func DefaultDockerConfigFile() string {
+	dir := os.Getenv(DockerConfigEnvVar)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, DockerConfigFileName)
+}
+
+// LoadDockerConfig loads the credentials of the auths of the docker config file. An empty set is returned if
+// the file does not exist. The credentials of the hosts in credHelpers and of the hosts in auths without any
+// credentials when there is a credsStore are looked up using the docker-credential-<helper> binaries
+func LoadDockerConfig(path string) (CredentialSet, error) {
+	answer := CredentialSet{}
+	if path == "" {
+		return answer, nil
+	}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return answer, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	config := &dockerConfig{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal docker config file %s", path)
+	}
+	for key, a := range config.Auths {
+		c := &Credentials{Username: a.Username, Password: a.Password, IdentityToken: a.IdentityToken}
+		if a.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode the auth of %s in docker config file %s", key, path)
+			}
+			values := strings.SplitN(string(decoded), ":", 2)
+			if len(values) != 2 {
+				return nil, errors.Errorf("invalid auth of %s in docker config file %s should be of the form user:password", key, path)
+			}
+			c.Username, c.Password = values[0], values[1]
+		}
+		if !c.IsEmpty() {
+			answer[NormalizeHost(key)] = c
+			continue
+		}
+		if config.CredsStore == "" || config.CredHelpers[key] != "" {
+			continue
+		}
+		c, err = HelperCredentials(config.CredsStore, key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the credentials of %s from the credsStore of docker config file %s", key, path)
+		}
+		if !c.IsEmpty() {
+			answer[NormalizeHost(key)] = c
+		}
+	}
+
+	// the credential helpers of a host take precedence over the auths
+	for key, helper := range config.CredHelpers {
+		if helper == "" {
+			continue
+		}
+		c, err := HelperCredentials(helper, key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the credentials of %s from the credHelpers of docker config file %s", key, path)
+		}
+		if !c.IsEmpty() {
+			answer.Merge(CredentialSet{key: c})
+		}
+	}
+	return answer, nil
+}
+
+// HelperCredentials returns the credentials of the registry host using the get command of the docker credential
+// helper binary docker-credential-<helper>. Returns nil if the helper has no credentials for the host
+func HelperCredentials(helper, host string) (*Credentials, error) {
+	name := CredentialHelperPrefix + helper
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, errors.Errorf("the docker credential helper %s is not installed on the $PATH", name)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	e := exec.Command(path, "get") // #nosec
+	e.Stdin = strings.NewReader(host)
+	e.Stdout = stdout
+	e.Stderr = stderr
+	err = e.Run()
+	if err != nil {
+		text := strings.TrimSpace(stdout.String() + "\n" + stderr.String())
+		if strings.Contains(strings.ToLower(text), "credentials not found") {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to run %s get: %s", name, text)
+	}
+	result := &helperCredentials{}
+	err = json.Unmarshal(stdout.Bytes(), result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the output of %s get", name)
+	}
+	if result.Username == identityTokenUsername {
+		return &Credentials{IdentityToken: result.Secret}, nil
+	}
+	return &Credentials{Username: result.Username, Password: result.Secret}, nil
+}
+
+// ParseCredentials parses credentials of the form 'host=user:password'
+func ParseCredentials(text string) (string, *Credentials, error) {
+	values := strings.SplitN(text, "=", 2)
+	if len(values) != 2 || values[0] == "" {
+		// lets not include the text as it may contain a password
+		return "", nil, errors.Errorf("invalid registry credentials should be of the form host=user:password")
+	}
+	userPassword := strings.SplitN(values[1], ":", 2)
+	if len(userPassword) != 2 || userPassword[0] == "" {
+		return "", nil, errors.Errorf("invalid registry credentials for %s should be of the form host=user:password", values[0])
+	}
+	return NormalizeHost(values[0]), &Credentials{Username: userPassword[0], Password: userPassword[1]}, nil
+}
+
+// NormalizeHost returns the host of the registry without any scheme or path mapping the docker hub aliases
+// to its registry host
+func NormalizeHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	idx := strings.Index(host, "/")
+	if idx >= 0 {
+		host = host[:idx]
+	}
+	switch host {
+	case "docker.io", "index.docker.io", "registry.hub.docker.com":
+		return DockerHubHost
+	}
+	return host
+}
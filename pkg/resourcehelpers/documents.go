@@ -0,0 +1,84 @@
+package resourcehelpers
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+// RemoveDocuments removes the documents with the given indexes from the file leaving the text of the other
+// documents untouched. The indexes ignore empty documents in the same way as LoadFile. Any comments before a
+// removed document are removed along with it. The file is deleted if it no longer contains any documents in
+// which case true is returned
+func RemoveDocuments(path string, indexes map[int]bool) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	var kept []string
+	var pending []string
+	index := 0
+	for _, section := range splitSections(text) {
+		pending = append(pending, section)
+		if helmhelpers.IsWhitespaceOrComments(section) {
+			continue
+		}
+		if !indexes[index] {
+			kept = append(kept, pending...)
+		}
+		pending = nil
+		index++
+	}
+	if len(kept) == 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to remove file %s", path)
+		}
+		return true, nil
+	}
+	// lets keep any trailing comments
+	kept = append(kept, pending...)
+
+	err = ioutil.WriteFile(path, []byte(joinSections(kept)), files.DefaultFileWritePermissions)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return false, nil
+}
+
+// joinSections joins the sections of a file split via splitSections separating them with '---' lines
+func joinSections(sections []string) string {
+	buf := strings.Builder{}
+	for i, section := range sections {
+		if i > 0 {
+			buf.WriteString(resourcesSeparator)
+		}
+		buf.WriteString(section)
+		if section != "" && !strings.HasSuffix(section, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String()
+}
+
+// splitSections splits the text on the '---' lines which separate documents in the same way as the YAML reader
+// used by LoadFile
+func splitSections(text string) []string {
+	var answer []string
+	buf := strings.Builder{}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.HasPrefix(line, "---") && strings.TrimSpace(strings.TrimPrefix(line, "---")) == "" {
+			answer = append(answer, buf.String())
+			buf.Reset()
+			continue
+		}
+		buf.WriteString(line)
+	}
+	return append(answer, buf.String())
+}
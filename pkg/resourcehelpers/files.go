@@ -0,0 +1,186 @@
+package resourcehelpers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// resourcesSeparator is used to separate multiple objects stored in the same YAML file
+const resourcesSeparator = "---\n"
+
+// ModifyFn modifies the given resource returning true if it was modified
+type ModifyFn func(u *unstructured.Unstructured, path string) (bool, error)
+
+// IsYAMLFile returns true if the file name has a YAML extension
+func IsYAMLFile(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+}
+
+// IsResource returns true if the document looks like a kubernetes resource
+func IsResource(u *unstructured.Unstructured) bool {
+	return u != nil && u.GetKind() != "" && u.GetAPIVersion() != ""
+}
+
+// LoadFile loads all the YAML documents in the given file
+func LoadFile(path string) ([]*unstructured.Unstructured, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	answer, err := ParseDocuments(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	return answer, nil
+}
+
+// ParseDocuments parses the YAML documents in the given data ignoring any empty documents
+func ParseDocuments(data []byte) ([]*unstructured.Unstructured, error) {
+	var answer []*unstructured.Unstructured
+
+	// lets normalise windows line endings so that the document separators are found
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for i := 1; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return answer, errors.Wrapf(err, "failed to read YAML document %d", i)
+		}
+		if helmhelpers.IsWhitespaceOrComments(string(doc)) {
+			continue
+		}
+		jsonData, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return answer, errors.Wrapf(err, "failed to convert YAML document %d to JSON", i)
+		}
+		obj := map[string]interface{}{}
+		err = json.Unmarshal(jsonData, &obj)
+		if err != nil {
+			return answer, errors.Wrapf(err, "YAML document %d is not an object", i)
+		}
+		answer = append(answer, &unstructured.Unstructured{Object: obj})
+	}
+	return answer, nil
+}
+
+// ToYAML marshals the documents to YAML separating multiple documents
+func ToYAML(resources []*unstructured.Unstructured) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for i, u := range resources {
+		data, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal YAML document %d", i+1)
+		}
+		if i > 0 {
+			buf.WriteString(resourcesSeparator)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveFile saves the documents to the given file
+func SaveFile(resources []*unstructured.Unstructured, path string) error {
+	data, err := ToYAML(resources)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal file %s", path)
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// ModifyFiles recursively walks the given directory invoking the modify function on each resource
+// which matches the selector and saves any files which are modified
+func ModifyFiles(dir string, selector Selector, modifyFn ModifyFn) error {
+	modified := false
+	err := WalkYAMLDocuments(dir, selector.WalkOptions, func(file *YAMLFile, index int, u *unstructured.Unstructured) error {
+		if index == 0 {
+			modified = false
+		}
+		if IsResource(u) && selector.Matches(u) {
+			flag, err := modifyFn(u, file.Path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to modify %s %s", u.GetKind(), u.GetName())
+			}
+			if flag {
+				modified = true
+			}
+		}
+		if !modified || index < len(file.Documents)-1 {
+			return nil
+		}
+		return file.Save()
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify files in dir %s", dir)
+	}
+	return nil
+}
+
+// VisitFn visits the given resource
+type VisitFn func(u *unstructured.Unstructured, path string) error
+
+// VisitFiles recursively walks the given directory invoking the visit function on each resource
+// which matches the selector
+func VisitFiles(dir string, selector Selector, visitFn VisitFn) error {
+	return VisitDocuments(dir, selector, func(u *unstructured.Unstructured, path string, index int) error {
+		return visitFn(u, path)
+	})
+}
+
+// VisitResources recursively walks the given directory invoking the visit function on each resource which
+// matches the selector. The items of any List resources are visited instead of the List itself
+func VisitResources(dir string, selector Selector, visitFn VisitFn) error {
+	return VisitDocuments(dir, Selector{WalkOptions: selector.WalkOptions}, func(u *unstructured.Unstructured, path string, index int) error {
+		resources, _ := ExpandLists([]*unstructured.Unstructured{u})
+		for _, r := range resources {
+			if !IsResource(r) || !selector.Matches(r) {
+				continue
+			}
+			err := visitFn(r, path)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// VisitDocumentFn visits the given resource which is the document with the given index of the file
+type VisitDocumentFn func(u *unstructured.Unstructured, path string, index int) error
+
+// VisitDocuments recursively walks the given directory invoking the visit function on each resource
+// which matches the selector along with the index of its document in the file ignoring any empty documents
+func VisitDocuments(dir string, selector Selector, visitFn VisitDocumentFn) error {
+	err := WalkYAMLDocuments(dir, selector.WalkOptions, func(file *YAMLFile, index int, u *unstructured.Unstructured) error {
+		if !IsResource(u) || !selector.Matches(u) {
+			return nil
+		}
+		err := visitFn(u, file.Path, index)
+		if err != nil {
+			return errors.Wrapf(err, "failed to process %s %s", u.GetKind(), u.GetName())
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to visit files in dir %s", dir)
+	}
+	return nil
+}
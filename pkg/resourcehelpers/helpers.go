@@ -0,0 +1,45 @@
+package resourcehelpers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceKey returns a key for the resource of the form 'kind/name' or 'namespace/kind/name' if it has a namespace
+func ResourceKey(u *unstructured.Unstructured) string {
+	key := u.GetKind() + "/" + u.GetName()
+	ns := u.GetNamespace()
+	if ns != "" {
+		key = ns + "/" + key
+	}
+	return key
+}
+
+// GetFieldValues returns the values at the given path of fields. Any lists found along the path are traversed
+// so that the remaining path is evaluated on each element. Returns false if no values could be found
+func GetFieldValues(value interface{}, fields ...string) ([]interface{}, bool) {
+	if len(fields) == 0 {
+		if value == nil {
+			return nil, false
+		}
+		return []interface{}{value}, true
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, ok := v[fields[0]]
+		if !ok {
+			return nil, false
+		}
+		return GetFieldValues(child, fields[1:]...)
+	case []interface{}:
+		var answer []interface{}
+		for _, item := range v {
+			values, ok := GetFieldValues(item, fields...)
+			if ok {
+				answer = append(answer, values...)
+			}
+		}
+		return answer, len(answer) > 0
+	default:
+		return nil, false
+	}
+}
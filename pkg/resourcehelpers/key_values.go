@@ -0,0 +1,79 @@
+package resourcehelpers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeyValueChanges the changes to make to a map of labels or annotations
+type KeyValueChanges struct {
+	// Set the keys and values to add
+	Set map[string]string
+
+	// Remove the keys to remove
+	Remove []string
+
+	// Overwrite replaces the values of existing keys. By default existing keys are kept
+	Overwrite bool
+}
+
+// ParseKeyValueChanges parses the 'key=value' arguments to add and the 'key-' arguments to remove. If invert is
+// true all the arguments are treated as keys to remove
+func ParseKeyValueChanges(args []string, invert bool) (*KeyValueChanges, error) {
+	if len(args) == 0 {
+		return nil, errors.Errorf("missing arguments to add such as key=value or to remove such as key-")
+	}
+	answer := &KeyValueChanges{Set: map[string]string{}}
+	for _, a := range args {
+		paths := strings.SplitN(a, "=", 2)
+		k := paths[0]
+		if invert || (len(paths) == 1 && strings.HasSuffix(k, "-")) {
+			k = strings.TrimSuffix(k, "-")
+			if k == "" {
+				return nil, errors.Errorf("invalid argument '%s' as the key is empty", a)
+			}
+			answer.Remove = append(answer.Remove, k)
+			continue
+		}
+		if k == "" {
+			return nil, errors.Errorf("invalid argument '%s' as the key is empty", a)
+		}
+		v := ""
+		if len(paths) > 1 {
+			v = paths[1]
+		}
+		answer.Set[k] = v
+	}
+	return answer, nil
+}
+
+// Apply returns a copy of the existing values with the changes applied, true if they were modified and the
+// number of existing keys which were kept with a different value as overwrite is disabled
+func (c *KeyValueChanges) Apply(existing map[string]string) (map[string]string, bool, int) {
+	answer := map[string]string{}
+	for k, v := range existing {
+		answer[k] = v
+	}
+	modified := false
+	kept := 0
+	for _, k := range c.Remove {
+		if _, ok := answer[k]; ok {
+			delete(answer, k)
+			modified = true
+		}
+	}
+	for k, v := range c.Set {
+		current, ok := answer[k]
+		if ok && current == v {
+			continue
+		}
+		if ok && !c.Overwrite {
+			kept++
+			continue
+		}
+		answer[k] = v
+		modified = true
+	}
+	return answer, modified, kept
+}
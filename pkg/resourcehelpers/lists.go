@@ -0,0 +1,59 @@
+package resourcehelpers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// KindList the kind of the generic list of resources
+	KindList = "List"
+)
+
+// IsList returns true if the resource is a List wrapper of items such as the output of 'kubectl get -o yaml'
+func IsList(u *unstructured.Unstructured) bool {
+	return IsResource(u) && u.IsList()
+}
+
+// ListItems returns the items of the list. The items share their objects with the list so that any changes
+// to the items modify the list
+func ListItems(u *unstructured.Unstructured) []*unstructured.Unstructured {
+	items, _ := u.Object["items"].([]interface{})
+	var answer []*unstructured.Unstructured
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if ok {
+			answer = append(answer, &unstructured.Unstructured{Object: m})
+		}
+	}
+	return answer
+}
+
+// ExpandLists returns the resources replacing any lists with their items and true if any lists were expanded
+func ExpandLists(resources []*unstructured.Unstructured) ([]*unstructured.Unstructured, bool) {
+	var answer []*unstructured.Unstructured
+	expanded := false
+	for _, u := range resources {
+		if !IsList(u) {
+			answer = append(answer, u)
+			continue
+		}
+		answer = append(answer, ListItems(u)...)
+		expanded = true
+	}
+	return answer, expanded
+}
+
+// NewList returns a List wrapping the given resources
+func NewList(resources []*unstructured.Unstructured) *unstructured.Unstructured {
+	items := []interface{}{}
+	for _, u := range resources {
+		items = append(items, u.Object)
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       KindList,
+			"items":      items,
+		},
+	}
+}
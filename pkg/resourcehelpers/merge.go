@@ -0,0 +1,212 @@
+package resourcehelpers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ListMergeReplace replaces the list with the list being merged
+	ListMergeReplace = "replace"
+
+	// ListMergeAppend appends the elements of the list being merged
+	ListMergeAppend = "append"
+
+	// ListMergeByKey merges the elements which have the same value of a key field such as 'name' and appends the others
+	ListMergeByKey = "merge-by-key"
+)
+
+// DefaultListMergeKeys the merge keys of the common lists indexed by the field name based on the kubernetes
+// patch strategies so that containers, environment variables and volumes are merged by name
+var DefaultListMergeKeys = map[string]string{
+	"containers":       "name",
+	"initContainers":   "name",
+	"env":              "name",
+	"volumes":          "name",
+	"volumeMounts":     "mountPath",
+	"imagePullSecrets": "name",
+	"ports":            "containerPort",
+}
+
+// ListMergeStrategy how a list is merged
+type ListMergeStrategy struct {
+	// Type the type of the strategy such as replace, append or merge-by-key
+	Type string
+
+	// Key the field used to match elements for the merge-by-key strategy
+	Key string
+}
+
+// String returns the strategy in the format used by the CLI
+func (s ListMergeStrategy) String() string {
+	if s.Type == ListMergeByKey {
+		return s.Type + "=" + s.Key
+	}
+	return s.Type
+}
+
+// ParseListMergeStrategy parses a strategy such as 'replace', 'append' or 'merge-by-key=name'
+func ParseListMergeStrategy(text string) (ListMergeStrategy, error) {
+	switch {
+	case text == ListMergeReplace || text == ListMergeAppend:
+		return ListMergeStrategy{Type: text}, nil
+	case strings.HasPrefix(text, ListMergeByKey+"="):
+		key := strings.TrimPrefix(text, ListMergeByKey+"=")
+		if key == "" {
+			return ListMergeStrategy{}, errors.Errorf("missing key field for list merge strategy %s", text)
+		}
+		return ListMergeStrategy{Type: ListMergeByKey, Key: key}, nil
+	default:
+		return ListMergeStrategy{}, errors.Errorf("unknown list merge strategy '%s'. Expected one of: %s, %s, %s=<field>", text, ListMergeReplace, ListMergeAppend, ListMergeByKey)
+	}
+}
+
+// MergeOptions the options for deep merging resources
+type MergeOptions struct {
+	// ListMerges the list merge strategies in the format 'path=strategy' such as 'spec.template.spec.containers=merge-by-key=name'
+	ListMerges []string
+
+	// NoDefaultListMerges disables the default merge keys for common lists such as containers so they are replaced
+	NoDefaultListMerges bool
+
+	// DefaultStrategy the strategy of the lists which have no other strategy. Defaults to replace
+	DefaultStrategy ListMergeStrategy
+
+	strategies map[string]ListMergeStrategy
+}
+
+// AddFlags adds the CLI flags for merging
+func (o *MergeOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&o.ListMerges, "list-merge", "", nil, "the merge strategy of the list at a dot separated path in the format 'path=strategy' where the strategy is replace, append or merge-by-key=<field> such as 'spec.template.spec.containers=merge-by-key=name'")
+	cmd.Flags().BoolVarP(&o.NoDefaultListMerges, "no-default-list-merges", "", false, "disables merging the common lists such as containers, env and volumes by their name so that they are replaced unless a --list-merge is specified")
+}
+
+// Validate parses the list merge strategies
+func (o *MergeOptions) Validate() error {
+	o.strategies = map[string]ListMergeStrategy{}
+	for _, text := range o.ListMerges {
+		idx := strings.Index(text, "=")
+		if idx <= 0 {
+			return errors.Errorf("invalid list merge '%s'. Expected 'path=strategy'", text)
+		}
+		s, err := ParseListMergeStrategy(text[idx+1:])
+		if err != nil {
+			return errors.Wrapf(err, "invalid list merge for path %s", text[:idx])
+		}
+		o.strategies[text[:idx]] = s
+	}
+	return nil
+}
+
+// Strategy returns the strategy of the list at the given dot separated path. Lists inside list elements use the
+// path of the list followed by the field name such as 'spec.template.spec.containers.env'
+func (o *MergeOptions) Strategy(path string) ListMergeStrategy {
+	if o.strategies == nil && len(o.ListMerges) > 0 {
+		// lets ignore invalid strategies as Validate reports them
+		_ = o.Validate()
+	}
+	s, ok := o.strategies[path]
+	if ok {
+		return s
+	}
+	if !o.NoDefaultListMerges {
+		name := path[strings.LastIndex(path, ".")+1:]
+		key := DefaultListMergeKeys[name]
+		if key != "" {
+			return ListMergeStrategy{Type: ListMergeByKey, Key: key}
+		}
+	}
+	if o.DefaultStrategy.Type != "" {
+		return o.DefaultStrategy
+	}
+	return ListMergeStrategy{Type: ListMergeReplace}
+}
+
+// Merge deep merges the source object into the destination object returning the result. Objects are merged
+// recursively, a null value removes the field and lists are merged using the strategy for their path
+func (o *MergeOptions) Merge(dst, src map[string]interface{}) map[string]interface{} {
+	return o.mergeMaps("", dst, src)
+}
+
+func (o *MergeOptions) mergeMaps(path string, dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		childPath := k
+		if path != "" {
+			childPath = path + "." + k
+		}
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		dst[k] = o.mergeValues(childPath, dst[k], v)
+	}
+	return dst
+}
+
+func (o *MergeOptions) mergeValues(path string, dst, src interface{}) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			d = nil
+		}
+		return o.mergeMaps(path, d, s)
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			return s
+		}
+		return o.mergeLists(path, d, s)
+	default:
+		return src
+	}
+}
+
+func (o *MergeOptions) mergeLists(path string, dst, src []interface{}) []interface{} {
+	s := o.Strategy(path)
+	switch s.Type {
+	case ListMergeAppend:
+		return append(dst, src...)
+	case ListMergeByKey:
+		answer := append([]interface{}{}, dst...)
+		for _, v := range src {
+			key := keyValue(v, s.Key)
+			idx := -1
+			if key != nil {
+				for i, e := range answer {
+					if keyValue(e, s.Key) == key {
+						idx = i
+						break
+					}
+				}
+			}
+			if idx < 0 {
+				answer = append(answer, v)
+				continue
+			}
+			answer[idx] = o.mergeValues(path, answer[idx], v)
+		}
+		return answer
+	default:
+		return src
+	}
+}
+
+// keyValue returns the scalar value of the key field of a list element or nil if it does not have one
+func keyValue(v interface{}, key string) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value := m[key]
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,212 @@
+package resourcehelpers
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ModifyNodeFn modifies the YAML node of the given resource returning true if it was modified
+type ModifyNodeFn func(node *yaml.RNode, path string) (bool, error)
+
+// ModifyFilteredNodes recursively walks the given directory honouring the walk options invoking the modify function
+// on the YAML node of each resource which matches the filter including the items of List resources. The nodes are
+// edited in place so that the comments and formatting are preserved and only the documents which are modified are
+// rewritten
+func ModifyFilteredNodes(dir string, opts common.WalkOptions, filter kyamls.Filter, modifyFn ModifyNodeFn) error {
+	filterFn, err := filter.ToFilterFn()
+	if err != nil {
+		return errors.Wrapf(err, "failed to create filter")
+	}
+	modifyResource := func(node *yaml.RNode, path string) (bool, error) {
+		if !IsResourceNode(node) {
+			return false, nil
+		}
+		if filterFn != nil {
+			matched, err := filterFn(node, path)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		flag, err := modifyFn(node, path)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to modify %s %s in file %s", kyamls.GetKind(node, path), kyamls.GetName(node, path), path)
+		}
+		return flag, nil
+	}
+
+	err = WalkYAMLFiles(dir, opts, func(path, rel string, info os.FileInfo) error {
+		return modifyNodesInFile(path, modifyResource)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to modify files in dir %s", dir)
+	}
+	return nil
+}
+
+// modifyNodesInFile invokes the modify function on each document of the file and saves the file if any documents
+// were modified. The text of the documents which are not modified is left untouched
+func modifyNodesInFile(path string, modifyFn ModifyNodeFn) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", path)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	sections := splitSections(text)
+	modified := false
+	for i, section := range sections {
+		if helmhelpers.IsWhitespaceOrComments(section) {
+			continue
+		}
+		node, err := yaml.Parse(section)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse YAML document %d of file %s", i+1, path)
+		}
+		flag := false
+		items, err := listItemNodes(node)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the items of YAML document %d of file %s", i+1, path)
+		}
+		if items == nil {
+			items = []*yaml.RNode{node}
+		}
+		for _, item := range items {
+			itemModified, err := modifyFn(item, path)
+			if err != nil {
+				return err
+			}
+			if itemModified {
+				flag = true
+			}
+		}
+		if !flag {
+			continue
+		}
+		sections[i], err = node.String()
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal YAML document %d of file %s", i+1, path)
+		}
+		modified = true
+	}
+	if !modified {
+		return nil
+	}
+	err = ioutil.WriteFile(path, []byte(joinSections(sections)), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// IsResourceNode returns true if the YAML node looks like a kubernetes resource
+func IsResourceNode(node *yaml.RNode) bool {
+	if node == nil || node.YNode().Kind != yaml.MappingNode {
+		return false
+	}
+	for _, field := range []string{"apiVersion", "kind"} {
+		value, err := node.Pipe(yaml.Lookup(field))
+		if err != nil || value == nil || value.YNode().Kind != yaml.ScalarNode || value.YNode().Value == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// listItemNodes returns the items of the node if it is a List resource or nil if it is not a list
+func listItemNodes(node *yaml.RNode) ([]*yaml.RNode, error) {
+	if !IsResourceNode(node) {
+		return nil, nil
+	}
+	items, err := node.Pipe(yaml.Lookup("items"))
+	if err != nil || items == nil || items.YNode().Kind != yaml.SequenceNode {
+		return nil, err
+	}
+	elements, err := items.Elements()
+	if err != nil {
+		return nil, err
+	}
+	if elements == nil {
+		elements = []*yaml.RNode{}
+	}
+	return elements, nil
+}
+
+// NodeStringMap returns the map of strings at the given path of the node or nil if there is no map
+func NodeStringMap(node *yaml.RNode, path ...string) (map[string]string, error) {
+	mapNode, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", strings.Join(path, "."))
+	}
+	if mapNode == nil {
+		return nil, nil
+	}
+	if mapNode.YNode().Kind != yaml.MappingNode {
+		return nil, errors.Errorf("%s is not a map", strings.Join(path, "."))
+	}
+	answer := map[string]string{}
+	content := mapNode.YNode().Content
+	for i := 0; i+1 < len(content); i += 2 {
+		answer[content[i].Value] = content[i+1].Value
+	}
+	return answer, nil
+}
+
+// SetNodeStringMap updates the map of strings at the given path of the node to the given values. Only the entries
+// which are added, changed or removed are modified so that the order and comments of the others are preserved. New
+// values are single quoted in the same way as yaml.SetLabel. The map is removed if there are no values
+func SetNodeStringMap(node *yaml.RNode, values map[string]string, path ...string) error {
+	name := strings.Join(path, ".")
+	existing, err := NodeStringMap(node, path...)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		if existing == nil {
+			return nil
+		}
+		err = node.PipeE(yaml.Lookup(path[:len(path)-1]...), yaml.Clear(path[len(path)-1]))
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", name)
+		}
+		return nil
+	}
+
+	for k := range existing {
+		if _, ok := values[k]; ok {
+			continue
+		}
+		err = node.PipeE(yaml.Lookup(path...), yaml.Clear(k))
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s from %s", k, name)
+		}
+	}
+
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := values[k]
+		if old, ok := existing[k]; ok && old == v {
+			continue
+		}
+		value := yaml.NewScalarRNode(v)
+		value.YNode().Tag = yaml.NodeTagString
+		value.YNode().Style = yaml.SingleQuotedStyle
+		err = node.PipeE(yaml.PathGetter{Path: path, Create: yaml.MappingNode}, yaml.FieldSetter{Name: k, Value: value})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set %s in %s", k, name)
+		}
+	}
+	return nil
+}
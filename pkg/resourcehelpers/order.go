@@ -0,0 +1,85 @@
+package resourcehelpers
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// InstallOrder the order in which the kinds of resources should be installed which is the same order helm uses
+	InstallOrder = []string{
+		"Namespace",
+		"NetworkPolicy",
+		"ResourceQuota",
+		"LimitRange",
+		"PodSecurityPolicy",
+		"PodDisruptionBudget",
+		"ServiceAccount",
+		"Secret",
+		"SecretList",
+		"ConfigMap",
+		"StorageClass",
+		"PersistentVolume",
+		"PersistentVolumeClaim",
+		"CustomResourceDefinition",
+		"ClusterRole",
+		"ClusterRoleList",
+		"ClusterRoleBinding",
+		"ClusterRoleBindingList",
+		"Role",
+		"RoleList",
+		"RoleBinding",
+		"RoleBindingList",
+		"Service",
+		"DaemonSet",
+		"Pod",
+		"ReplicationController",
+		"ReplicaSet",
+		"Deployment",
+		"HorizontalPodAutoscaler",
+		"StatefulSet",
+		"Job",
+		"CronJob",
+		"Ingress",
+		"APIService",
+	}
+)
+
+// KindRank returns the index of the kind in the InstallOrder or the length of the InstallOrder if it is not known
+func KindRank(kind string) int {
+	for i, k := range InstallOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(InstallOrder)
+}
+
+// SortByInstallOrder sorts the resources by the InstallOrder of their kind then by their namespace and name.
+// Kinds which are not in the InstallOrder are sorted by kind after the known kinds
+func SortByInstallOrder(resources []*unstructured.Unstructured) {
+	SortResources(resources, func(u *unstructured.Unstructured) int {
+		return KindRank(u.GetKind())
+	})
+}
+
+// SortResources sorts the resources by the given rank then by their kind, namespace and name
+func SortResources(resources []*unstructured.Unstructured, rankFn func(u *unstructured.Unstructured) int) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		a := resources[i]
+		b := resources[j]
+		ra := rankFn(a)
+		rb := rankFn(b)
+		if ra != rb {
+			return ra < rb
+		}
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+}
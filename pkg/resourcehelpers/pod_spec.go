@@ -0,0 +1,68 @@
+package resourcehelpers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// PodSpecPaths the paths to the pod specs of the kinds which have pods
+	PodSpecPaths = map[string][]string{
+		"Pod":         {"spec"},
+		"Deployment":  {"spec", "template", "spec"},
+		"StatefulSet": {"spec", "template", "spec"},
+		"DaemonSet":   {"spec", "template", "spec"},
+		"ReplicaSet":  {"spec", "template", "spec"},
+		"Job":         {"spec", "template", "spec"},
+		"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+	}
+)
+
+// PodSpecPath returns the path to the pod spec of the kind or nil if the kind has no pods
+func PodSpecPath(kind string) []string {
+	return PodSpecPaths[kind]
+}
+
+// Container a container of the pod spec of a resource
+type Container struct {
+	// Field the field of the pod spec containing the container such as 'containers' or 'initContainers'
+	Field string
+
+	// Index the index of the container in the field
+	Index int
+
+	// Object the container which is a copy so changes must be set on the resource
+	Object map[string]interface{}
+}
+
+// Name returns the name of the container
+func (c *Container) Name() string {
+	name, _ := c.Object["name"].(string)
+	return name
+}
+
+// PodContainers returns the containers in the given fields of the pod spec of the resource such as 'containers'
+// and 'initContainers'. Returns nil if the kind of the resource has no pods
+func PodContainers(u *unstructured.Unstructured, fields ...string) ([]Container, error) {
+	podSpecPath := PodSpecPath(u.GetKind())
+	if podSpecPath == nil {
+		return nil, nil
+	}
+	var answer []Container
+	for _, field := range fields {
+		path := append(append([]string{}, podSpecPath...), field)
+		containers, _, err := unstructured.NestedSlice(u.Object, path...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s", strings.Join(path, "."))
+		}
+		for i, c := range containers {
+			m, ok := c.(map[string]interface{})
+			if ok {
+				answer = append(answer, Container{Field: field, Index: i, Object: m})
+			}
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,101 @@
+package resourcehelpers
+
+import (
+	"path"
+	"strings"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Selector selects resources by kind, name, namespace and labels along with the options for walking the
+// directory tree such as the maximum depth
+type Selector struct {
+	common.WalkOptions
+
+	// Kinds the kinds to match. A kind can be prefixed with an API version such as 'apps/v1/Deployment'
+	Kinds []string
+
+	// Names the names to match which can contain wildcards such as 'web*'
+	Names []string
+
+	// Namespaces the namespaces to match
+	Namespaces []string
+
+	// Labels the labels which must match
+	Labels map[string]string
+}
+
+// AddFlags adds the CLI flags for the selector
+func (s *Selector) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&s.Kinds, "kind", "k", nil, "the kinds of resource to match. You can prefix the kind with an API version such as 'apps/v1/Deployment'")
+	cmd.Flags().StringArrayVarP(&s.Names, "name", "", nil, "the names of the resources to match which can include wildcards such as 'web*'")
+	cmd.Flags().StringArrayVarP(&s.Namespaces, "namespace", "", nil, "the namespaces of the resources to match")
+	cmd.Flags().StringToStringVarP(&s.Labels, "selector", "", nil, "the label selector to match resources, e.g. --selector app=foo,tier=web")
+	s.WalkOptions.AddFlags(cmd)
+}
+
+// Matches returns true if the resource matches the selector
+func (s *Selector) Matches(u *unstructured.Unstructured) bool {
+	if u == nil {
+		return false
+	}
+	if len(s.Kinds) > 0 && !s.matchesKind(u) {
+		return false
+	}
+	if len(s.Names) > 0 && !MatchesAnyPattern(u.GetName(), s.Names) {
+		return false
+	}
+	if len(s.Namespaces) > 0 && stringhelpers.StringArrayIndex(s.Namespaces, u.GetNamespace()) < 0 {
+		return false
+	}
+	if len(s.Labels) > 0 {
+		labels := u.GetLabels()
+		for k, v := range s.Labels {
+			actual, ok := labels[k]
+			if !ok || actual != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Selector) matchesKind(u *unstructured.Unstructured) bool {
+	kind := u.GetKind()
+	apiVersion := u.GetAPIVersion()
+	for _, k := range s.Kinds {
+		idx := strings.LastIndex(k, "/")
+		if idx < 0 {
+			if k == kind {
+				return true
+			}
+			continue
+		}
+		filterAPIVersion := k[0:idx]
+		filterKind := k[idx+1:]
+		if filterKind != "" && filterKind != kind {
+			continue
+		}
+		if apiVersion == filterAPIVersion || strings.HasPrefix(apiVersion, filterAPIVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesAnyPattern returns true if the text matches any of the given wildcard patterns
+func MatchesAnyPattern(text string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == text {
+			return true
+		}
+		flag, err := path.Match(pattern, text)
+		if err == nil && flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package resourcehelpers
+
+import (
+	"os"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// YAMLFilePatterns the default file name patterns of the files walked by WalkYAMLDocuments
+var YAMLFilePatterns = []string{"*.yaml", "*.yml"}
+
+// YAMLFile a YAML file containing one or more documents
+type YAMLFile struct {
+	// Path the path of the file
+	Path string
+
+	// Rel the path of the file relative to the directory being walked
+	Rel string
+
+	// Documents the parsed documents in the file
+	Documents []*unstructured.Unstructured
+}
+
+// Save writes the documents back to the file
+func (f *YAMLFile) Save() error {
+	return SaveFile(f.Documents, f.Path)
+}
+
+// YAMLDocumentFn is invoked for each document in a file along with the index of the document in the file
+type YAMLDocumentFn func(file *YAMLFile, index int, doc *unstructured.Unstructured) error
+
+// WalkYAMLFiles recursively walks the YAML files in the given directory. If the options have no patterns then
+// the *.yaml and *.yml files are walked
+func WalkYAMLFiles(dir string, opts common.WalkOptions, fn common.WalkFn) error {
+	if len(opts.Patterns) == 0 {
+		opts.Patterns = YAMLFilePatterns
+	}
+	return common.WalkFiles(dir, opts, fn)
+}
+
+// WalkYAMLDocuments recursively walks the YAML files in the given directory invoking the function on each
+// document. If the options have no patterns then the *.yaml and *.yml files are walked
+func WalkYAMLDocuments(dir string, opts common.WalkOptions, fn YAMLDocumentFn) error {
+	return WalkYAMLFiles(dir, opts, func(path, rel string, info os.FileInfo) error {
+		docs, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		file := &YAMLFile{
+			Path:      path,
+			Rel:       rel,
+			Documents: docs,
+		}
+		for i, doc := range docs {
+			err = fn(file, i, doc)
+			if err != nil {
+				return errors.Wrapf(err, "failed to process document %d in file %s", i+1, path)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+package resourcehelpers_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/common"
+	"github.com/jenkins-x/jx-gitops/pkg/resourcehelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWalkYAMLDocuments(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	sources := map[string]string{
+		"multi.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\n# just a comment\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: second\n",
+		"crlf.yml":   "apiVersion: v1\r\nkind: Secret\r\nmetadata:\r\n  name: third\r\n---\r\napiVersion: v1\r\nkind: Secret\r\nmetadata:\r\n  name: fourth\r\n",
+		"notes.txt":  "not: yaml: at all",
+	}
+	for f, text := range sources {
+		err = ioutil.WriteFile(filepath.Join(tmpDir, f), []byte(text), 0600)
+		require.NoError(t, err, "failed to write %s", f)
+	}
+
+	var actual []string
+	err = resourcehelpers.WalkYAMLDocuments(tmpDir, common.WalkOptions{}, func(file *resourcehelpers.YAMLFile, index int, doc *unstructured.Unstructured) error {
+		assert.Equal(t, filepath.Join(tmpDir, file.Rel), file.Path, "path for %s", file.Rel)
+		assert.Equal(t, doc, file.Documents[index], "document %d of %s", index, file.Rel)
+		actual = append(actual, fmt.Sprintf("%s/%d/%s/%s", file.Rel, index, doc.GetKind(), doc.GetName()))
+
+		if doc.GetName() == "second" {
+			doc.SetLabels(map[string]string{"modified": "true"})
+			return file.Save()
+		}
+		return nil
+	})
+	require.NoError(t, err, "failed to walk YAML documents")
+	assert.Equal(t, []string{"crlf.yml/0/Secret/third", "crlf.yml/1/Secret/fourth", "multi.yaml/0/ConfigMap/first", "multi.yaml/1/ConfigMap/second"}, actual)
+
+	docs, err := resourcehelpers.LoadFile(filepath.Join(tmpDir, "multi.yaml"))
+	require.NoError(t, err, "failed to load the saved file")
+	require.Len(t, docs, 2, "documents in the saved file")
+	assert.Equal(t, "first", docs[0].GetName())
+	assert.Equal(t, map[string]string{"modified": "true"}, docs[1].GetLabels(), "labels of the modified document")
+}
+
+func TestVisitDocumentsMaxDepth(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err, "failed to create temp dir")
+
+	for _, name := range []string{"top", "nested/child"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name)+".yaml")
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		require.NoError(t, err, "failed to create dir for %s", path)
+		text := fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", filepath.Base(name))
+		err = ioutil.WriteFile(path, []byte(text), 0600)
+		require.NoError(t, err, "failed to write %s", path)
+	}
+
+	testCases := []struct {
+		maxDepth int
+		expected []string
+	}{
+		{maxDepth: 0, expected: []string{"child", "top"}},
+		{maxDepth: 1, expected: []string{"top"}},
+	}
+	for _, tc := range testCases {
+		var actual []string
+		selector := resourcehelpers.Selector{WalkOptions: common.WalkOptions{MaxDepth: tc.maxDepth}}
+		err = resourcehelpers.VisitDocuments(tmpDir, selector, func(u *unstructured.Unstructured, path string, index int) error {
+			actual = append(actual, u.GetName())
+			return nil
+		})
+		require.NoError(t, err, "failed to visit documents with max depth %d", tc.maxDepth)
+		assert.Equal(t, tc.expected, actual, "documents with max depth %d", tc.maxDepth)
+	}
+}
@@ -0,0 +1,592 @@
+package yamledit
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// File a YAML file containing one or more documents which can be edited while leaving the comments, formatting
+// and key order of everything that is not modified untouched. Each edit splices the changed text into the
+// original source rather than marshalling the documents again
+type File struct {
+	data []byte
+}
+
+// Load loads the YAML file at the given path
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	return f, nil
+}
+
+// Parse parses the YAML source
+func Parse(data []byte) (*File, error) {
+	f := &File{data: data}
+	_, err := f.documents()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Bytes returns the current YAML source
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// String returns the current YAML source
+func (f *File) String() string {
+	return string(f.data)
+}
+
+// Save saves the YAML source to the given path
+func (f *File) Save(path string) error {
+	err := ioutil.WriteFile(path, f.data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// Documents returns the number of documents in the file
+func (f *File) Documents() (int, error) {
+	docs, err := f.documents()
+	return len(docs), err
+}
+
+// GetScalar returns the value of the scalar at the path of the given document and whether it exists
+func (f *File) GetScalar(doc int, path ...string) (string, bool, error) {
+	e, err := f.editor(doc)
+	if err != nil {
+		return "", false, err
+	}
+	_, node, err := e.lookup(path)
+	if err != nil || node == nil {
+		return "", false, err
+	}
+	if node.Kind != yaml.ScalarNode {
+		return "", false, errors.Errorf("field %s is not a scalar", strings.Join(path, "."))
+	}
+	return node.Value, true, nil
+}
+
+// SetScalar sets the scalar at the path of the given document creating any missing fields. The quoting style of
+// an existing value is preserved along with any comment on the same line
+func (f *File) SetScalar(doc int, value string, path ...string) error {
+	if len(path) == 0 {
+		return errors.Errorf("no path specified")
+	}
+	e, err := f.editor(doc)
+	if err != nil {
+		return err
+	}
+	err = e.setScalar(value, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set field %s of document %d", strings.Join(path, "."), doc+1)
+	}
+	f.data = []byte(strings.Join(e.lines, ""))
+	return nil
+}
+
+// Delete removes the field at the path of the given document along with its comment lines. Does nothing if the
+// field does not exist
+func (f *File) Delete(doc int, path ...string) error {
+	if len(path) == 0 {
+		return errors.Errorf("no path specified")
+	}
+	e, err := f.editor(doc)
+	if err != nil {
+		return err
+	}
+	err = e.delete(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete field %s of document %d", strings.Join(path, "."), doc+1)
+	}
+	f.data = []byte(strings.Join(e.lines, ""))
+	return nil
+}
+
+// SetAnnotation sets the metadata annotation of the given document
+func (f *File) SetAnnotation(doc int, key, value string) error {
+	return f.SetScalar(doc, value, "metadata", "annotations", key)
+}
+
+// RemoveAnnotation removes the metadata annotation of the given document
+func (f *File) RemoveAnnotation(doc int, key string) error {
+	return f.Delete(doc, "metadata", "annotations", key)
+}
+
+func (f *File) documents() ([]*yaml.Node, error) {
+	var answer []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(f.data))
+	for {
+		node := &yaml.Node{}
+		err := decoder.Decode(node)
+		if err == io.EOF {
+			return answer, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse YAML document %d", len(answer)+1)
+		}
+		answer = append(answer, node)
+	}
+}
+
+func (f *File) editor(doc int) (*editor, error) {
+	docs, err := f.documents()
+	if err != nil {
+		return nil, err
+	}
+	if doc < 0 || doc >= len(docs) {
+		return nil, errors.Errorf("there is no document %d as the file has %d documents", doc+1, len(docs))
+	}
+	d := docs[doc]
+	if len(d.Content) == 0 || d.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.Errorf("document %d is not a YAML object", doc+1)
+	}
+	return &editor{
+		root:  d.Content[0],
+		lines: strings.SplitAfter(string(f.data), "\n"),
+	}, nil
+}
+
+// editor edits a single document by splicing the lines of the source
+type editor struct {
+	root  *yaml.Node
+	lines []string
+}
+
+// lookup returns the key and value nodes at the path or nil if they do not exist
+func (e *editor) lookup(path []string) (*yaml.Node, *yaml.Node, error) {
+	node := e.root
+	var key *yaml.Node
+	for i, name := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, nil, errors.Errorf("field %s is not an object", strings.Join(path[:i], "."))
+		}
+		idx := fieldIndex(node, name)
+		if idx < 0 {
+			return nil, nil, nil
+		}
+		key = node.Content[idx]
+		node = node.Content[idx+1]
+	}
+	return key, node, nil
+}
+
+func (e *editor) setScalar(value string, path []string) error {
+	// lets find the closest existing parent
+	parentKey, parent := (*yaml.Node)(nil), e.root
+	for i, name := range path {
+		if parent.Kind == yaml.ScalarNode && parent.Tag == "!!null" {
+			return e.setNullValue(parentKey, path[i:], value)
+		}
+		if parent.Kind != yaml.MappingNode {
+			return errors.Errorf("field %s is not an object", strings.Join(path[:i], "."))
+		}
+		idx := fieldIndex(parent, name)
+		if idx < 0 {
+			return e.addFields(parentKey, parent, path[i:], value)
+		}
+		parentKey, parent = parent.Content[idx], parent.Content[idx+1]
+	}
+	node := parent
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.Value == "" {
+		return e.setNullValue(parentKey, nil, value)
+	}
+	if node.Kind != yaml.ScalarNode {
+		return errors.Errorf("the field is not a scalar")
+	}
+	text, err := formatValue(value, node)
+	if err != nil {
+		return err
+	}
+	lineIdx := node.Line - 1
+	line, eol := splitEOL(e.lines[lineIdx])
+	start := byteOffset(line, node.Column-1)
+	end, err := scalarEnd(line, start, node)
+	if err != nil {
+		return err
+	}
+	e.lines[lineIdx] = line[:start] + text + line[end:] + eol
+	return nil
+}
+
+// setNullValue replaces the empty value of the key with the fields or the scalar if there are no fields
+func (e *editor) setNullValue(key *yaml.Node, fields []string, value string) error {
+	lineIdx := key.Line - 1
+	line, eol := splitEOL(e.lines[lineIdx])
+	colon, err := colonEnd(line, key)
+	if err != nil {
+		return err
+	}
+	rest := line[colon:]
+	trimmed := strings.TrimLeft(rest, " ")
+	lead := rest[:len(rest)-len(trimmed)]
+
+	// lets remove any explicit null such as '~' leaving any comment
+	comment := ""
+	if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+		idx := strings.Index(trimmed, " #")
+		if idx >= 0 {
+			comment = " " + strings.TrimLeft(trimmed[idx:], " ")
+		}
+	} else if trimmed != "" {
+		comment = lead + trimmed
+	}
+	if len(fields) == 0 {
+		text, err := formatValue(value, nil)
+		if err != nil {
+			return err
+		}
+		e.lines[lineIdx] = line[:colon] + " " + text + comment + eol
+		return nil
+	}
+	e.lines[lineIdx] = line[:colon] + comment + eol
+	indent := key.Column - 1 + e.indentStep()
+	return e.insertLines(lineIdx+1, e.fieldLines(indent, fields, value))
+}
+
+// addFields adds the missing fields to the end of the parent mapping
+func (e *editor) addFields(parentKey, parent *yaml.Node, fields []string, value string) error {
+	if parent.Style&yaml.FlowStyle != 0 {
+		if len(parent.Content) > 0 || parentKey == nil {
+			return errors.Errorf("cannot add fields to a flow style object")
+		}
+		// lets replace the empty '{}' with the new fields
+		lineIdx := parentKey.Line - 1
+		line, eol := splitEOL(e.lines[lineIdx])
+		colon, err := colonEnd(line, parentKey)
+		if err != nil {
+			return err
+		}
+		start := byteOffset(line, parent.Column-1)
+		end := strings.Index(line[start:], "}")
+		if end < 0 {
+			return errors.Errorf("cannot add fields to a multi-line flow style object")
+		}
+		e.lines[lineIdx] = line[:colon] + line[start+end+1:] + eol
+		indent := parentKey.Column - 1 + e.indentStep()
+		return e.insertLines(lineIdx+1, e.fieldLines(indent, fields, value))
+	}
+	if len(parent.Content) == 0 {
+		return errors.Errorf("cannot add fields to an empty object")
+	}
+	first := parent.Content[0]
+	last := len(parent.Content) - 2
+	end := e.entryEnd(parent.Content[last], parent.Content[last+1])
+	return e.insertLines(end, e.fieldLines(first.Column-1, fields, value))
+}
+
+func (e *editor) delete(path []string) error {
+	parentPath := path[:len(path)-1]
+	parentKey, parent, err := e.lookup(parentPath)
+	if err != nil || parent == nil {
+		return err
+	}
+	if parent.Kind != yaml.MappingNode {
+		return nil
+	}
+	idx := fieldIndex(parent, path[len(path)-1])
+	if idx < 0 {
+		return nil
+	}
+	if parent.Style&yaml.FlowStyle != 0 {
+		return errors.Errorf("cannot delete fields from a flow style object")
+	}
+	key := parent.Content[idx]
+	start := key.Line - 1
+	line, _ := splitEOL(e.lines[start])
+	indent := key.Column - 1
+	if strings.TrimSpace(line[:byteOffset(line, key.Column-1)]) != "" {
+		return errors.Errorf("cannot delete the first field of a sequence item")
+	}
+	end := e.entryEnd(key, parent.Content[idx+1])
+
+	// lets remove the comment lines directly above the field too
+	for start > 0 {
+		prev, _ := splitEOL(e.lines[start-1])
+		trimmed := strings.TrimLeft(prev, " ")
+		if !strings.HasPrefix(trimmed, "#") || len(prev)-len(trimmed) != indent {
+			break
+		}
+		start--
+	}
+	e.lines = append(e.lines[:start], e.lines[end:]...)
+
+	// lets keep the parent as an empty object rather than making it null
+	if len(parent.Content) == 2 && parentKey != nil {
+		lineIdx := parentKey.Line - 1
+		line, eol := splitEOL(e.lines[lineIdx])
+		colon, err := colonEnd(line, parentKey)
+		if err != nil {
+			return err
+		}
+		e.lines[lineIdx] = line[:colon] + " {}" + line[colon:] + eol
+	}
+	return nil
+}
+
+// entryEnd returns the index of the line after the last line of the mapping entry
+func (e *editor) entryEnd(key, value *yaml.Node) int {
+	last := maxLine(value)
+	if key.Line > last {
+		last = key.Line
+	}
+	end := last
+	line, _ := splitEOL(e.lines[last-1])
+	base := lineIndent(line)
+
+	// lets include any continuation lines such as the contents of block scalars
+	for i := last; i < len(e.lines); i++ {
+		line, _ := splitEOL(e.lines[i])
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if lineIndent(line) <= base {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// fieldLines returns the lines of the nested fields with the scalar value
+func (e *editor) fieldLines(indent int, fields []string, value string) []string {
+	eol := e.eol()
+	var answer []string
+	for i, name := range fields {
+		prefix := strings.Repeat(" ", indent+i*e.indentStep()) + formatKey(name) + ":"
+		if i < len(fields)-1 {
+			answer = append(answer, prefix+eol)
+			continue
+		}
+		text, _ := formatValue(value, nil)
+		answer = append(answer, prefix+" "+text+eol)
+	}
+	return answer
+}
+
+func (e *editor) insertLines(idx int, lines []string) error {
+	if idx > len(e.lines) {
+		idx = len(e.lines)
+	}
+	// lets make sure the previous line is terminated
+	if idx > 0 && !strings.HasSuffix(e.lines[idx-1], "\n") {
+		e.lines[idx-1] += e.eol()
+	}
+	answer := make([]string, 0, len(e.lines)+len(lines))
+	answer = append(answer, e.lines[:idx]...)
+	answer = append(answer, lines...)
+	answer = append(answer, e.lines[idx:]...)
+	e.lines = answer
+	return nil
+}
+
+// eol returns the line ending used by the source
+func (e *editor) eol() string {
+	for _, l := range e.lines {
+		if strings.HasSuffix(l, "\r\n") {
+			return "\r\n"
+		}
+		if strings.HasSuffix(l, "\n") {
+			return "\n"
+		}
+	}
+	return "\n"
+}
+
+// indentStep returns the indentation used by the nested objects of the document defaulting to 2
+func (e *editor) indentStep() int {
+	step := findIndentStep(e.root)
+	if step <= 0 {
+		return 2
+	}
+	return step
+}
+
+func findIndentStep(node *yaml.Node) int {
+	if node.Kind != yaml.MappingNode || node.Style&yaml.FlowStyle != 0 {
+		return 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 && value.Line > key.Line {
+			return value.Content[0].Column - key.Column
+		}
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		step := findIndentStep(node.Content[i])
+		if step > 0 {
+			return step
+		}
+	}
+	return 0
+}
+
+func fieldIndex(node *yaml.Node, name string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxLine returns the last line of the node or 0 for empty values as their position is not part of the source
+func maxLine(node *yaml.Node) int {
+	answer := node.Line
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.Value == "" {
+		answer = 0
+	}
+	for _, c := range node.Content {
+		l := maxLine(c)
+		if l > answer {
+			answer = l
+		}
+	}
+	return answer
+}
+
+// colonEnd returns the byte offset after the ':' following the key on its line
+func colonEnd(line string, key *yaml.Node) (int, error) {
+	start := byteOffset(line, key.Column-1)
+	end, err := scalarEnd(line, start, key)
+	if err != nil {
+		return 0, err
+	}
+	idx := strings.Index(line[end:], ":")
+	if idx < 0 {
+		return 0, errors.Errorf("cannot find the ':' after the key %s", key.Value)
+	}
+	return end + idx + 1, nil
+}
+
+// scalarEnd returns the byte offset after a single line scalar which starts at the given offset of the line
+func scalarEnd(line string, start int, node *yaml.Node) (int, error) {
+	rest := line[start:]
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + i + 1, nil
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\'' {
+				if i+1 < len(rest) && rest[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start + i + 1, nil
+			}
+		}
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle|yaml.TaggedStyle) != 0:
+		return 0, errors.Errorf("cannot edit block or tagged scalars")
+	default:
+		end := len(rest)
+		if idx := strings.Index(rest, " #"); idx >= 0 {
+			end = idx
+		}
+		if idx := strings.Index(rest, ": "); idx >= 0 && idx < end {
+			end = idx
+		}
+		if strings.HasSuffix(rest[:end], ":") {
+			end--
+		}
+		text := strings.TrimRight(rest[:end], " ")
+		if len(text) > len(node.Value) && strings.HasPrefix(text, node.Value) {
+			// lets handle flow style collections such as '[a, b]'
+			text = node.Value
+		}
+		if text == node.Value {
+			return start + len(text), nil
+		}
+	}
+	return 0, errors.Errorf("cannot edit multi-line scalars")
+}
+
+// formatValue formats the value using the quoting style of the existing node if there is one
+func formatValue(value string, old *yaml.Node) (string, error) {
+	if old != nil && !strings.ContainsAny(value, "\n\r\t") {
+		switch {
+		case old.Style&yaml.DoubleQuotedStyle != 0:
+			return strconv.Quote(value), nil
+		case old.Style&yaml.SingleQuotedStyle != 0:
+			return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+		case old.Tag != "!!str" && plainTag(value) == old.Tag:
+			// lets preserve the type of values such as numbers and booleans
+			return value, nil
+		}
+	}
+	if plainTag(value) == "!!str" {
+		return value, nil
+	}
+	return strconv.Quote(value), nil
+}
+
+func formatKey(key string) string {
+	if plainTag(key) == "!!str" {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// plainTag returns the tag of the value if it were written unquoted or blank if it would not be read back unchanged
+func plainTag(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "\n\r\t") {
+		return ""
+	}
+	node := &yaml.Node{}
+	err := yaml.Unmarshal([]byte("k: "+value), node)
+	if err != nil || len(node.Content) == 0 || len(node.Content[0].Content) != 2 {
+		return ""
+	}
+	v := node.Content[0].Content[1]
+	if v.Kind != yaml.ScalarNode || v.Style != 0 || v.Value != value {
+		return ""
+	}
+	return v.Tag
+}
+
+func splitEOL(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// byteOffset converts the 0 based character column to a byte offset in the line
+func byteOffset(line string, column int) int {
+	offset := 0
+	for i := 0; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
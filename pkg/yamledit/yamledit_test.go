@@ -0,0 +1,154 @@
+package yamledit_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx-gitops/pkg/yamledit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditGoldenFiles(t *testing.T) {
+	testCases := []struct {
+		name string
+		edit func(f *yamledit.File) error
+	}{
+		{
+			name: "kptfile",
+			edit: func(f *yamledit.File) error {
+				return all(
+					f.SetScalar(0, "5dd7c9e8e2a2ab2a4a0f8e6fb5e1b9c1b3d4e5f6", "upstream", "git", "commit"),
+					f.SetScalar(0, "v1.2.3", "upstream", "git", "ref"),
+					f.SetScalar(0, "resource-merge", "upstream", "updateStrategy"),
+				)
+			},
+		},
+		{
+			name: "multi-doc",
+			edit: func(f *yamledit.File) error {
+				return all(
+					f.RemoveAnnotation(0, "jenkins-x.io/hash"),
+					f.SetAnnotation(0, "wave.pusher.com/update-on-config-change", "false"),
+					f.SetAnnotation(0, "gitops.jenkins-x.io/source", "charts/lighthouse"),
+					f.SetScalar(0, "3", "spec", "replicas"),
+					f.SetAnnotation(1, "gitops.jenkins-x.io/source", "charts/lighthouse"),
+				)
+			},
+		},
+		{
+			name: "empty-fields",
+			edit: func(f *yamledit.File) error {
+				return all(
+					f.SetAnnotation(0, "description", "a: b"),
+					f.SetScalar(0, "true", "metadata", "labels", "app"),
+					f.SetScalar(0, "3", "data", "count"),
+				)
+			},
+		},
+		{
+			name: "delete",
+			edit: func(f *yamledit.File) error {
+				return all(
+					f.RemoveAnnotation(0, "owner"),
+					f.Delete(0, "data", "script"),
+					f.Delete(0, "data", "does-not-exist"),
+					f.RemoveAnnotation(0, "does-not-exist"),
+				)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		dir := filepath.Join("test_data", tc.name)
+		f, err := yamledit.Load(filepath.Join(dir, "source.yaml"))
+		require.NoError(t, err, "failed to load source for %s", tc.name)
+
+		err = tc.edit(f)
+		require.NoError(t, err, "failed to edit %s", tc.name)
+
+		expected, err := ioutil.ReadFile(filepath.Join(dir, "expected.yaml"))
+		require.NoError(t, err, "failed to load expected for %s", tc.name)
+		assert.Equal(t, string(expected), f.String(), "edited YAML for %s", tc.name)
+	}
+}
+
+func TestEditUnchangedBytes(t *testing.T) {
+	source, err := ioutil.ReadFile(filepath.Join("test_data", "multi-doc", "source.yaml"))
+	require.NoError(t, err, "failed to load source")
+
+	f, err := yamledit.Parse(source)
+	require.NoError(t, err, "failed to parse source")
+
+	n, err := f.Documents()
+	require.NoError(t, err, "failed to count documents")
+	assert.Equal(t, 2, n, "documents")
+
+	// setting the existing values should not change a single byte
+	err = all(
+		f.SetAnnotation(0, "jenkins-x.io/hash", "abc123"),
+		f.SetScalar(0, "2", "spec", "replicas"),
+		f.SetScalar(1, "lighthouse", "metadata", "labels", "app"),
+	)
+	require.NoError(t, err, "failed to edit")
+	assert.Equal(t, string(source), f.String(), "the YAML should be unchanged")
+
+	value, ok, err := f.GetScalar(0, "metadata", "annotations", "wave.pusher.com/update-on-config-change")
+	require.NoError(t, err, "failed to get annotation")
+	assert.True(t, ok, "annotation should exist")
+	assert.Equal(t, "true", value, "annotation value")
+
+	_, ok, err = f.GetScalar(1, "metadata", "annotations", "foo")
+	require.NoError(t, err, "failed to get missing annotation")
+	assert.False(t, ok, "annotation should not exist")
+}
+
+func TestEditCRLF(t *testing.T) {
+	source := strings.ReplaceAll("metadata:\n  name: cheese # the name\nspec:\n  size: 1\n", "\n", "\r\n")
+	f, err := yamledit.Parse([]byte(source))
+	require.NoError(t, err, "failed to parse source")
+
+	err = all(
+		f.SetScalar(0, "wine", "metadata", "name"),
+		f.SetAnnotation(0, "owner", "jx"),
+	)
+	require.NoError(t, err, "failed to edit")
+
+	expected := strings.ReplaceAll("metadata:\n  name: wine # the name\n  annotations:\n    owner: jx\nspec:\n  size: 1\n", "\n", "\r\n")
+	assert.Equal(t, expected, f.String(), "edited YAML")
+}
+
+func TestEditErrors(t *testing.T) {
+	f, err := yamledit.Parse([]byte("metadata:\n  name: cheese\n  labels: {app: cheese}\ndata:\n  script: |\n    echo hello\n---\n- a\n"))
+	require.NoError(t, err, "failed to parse source")
+
+	testCases := []struct {
+		name string
+		err  error
+	}{
+		{"not an object", f.SetScalar(0, "x", "metadata", "name", "foo")},
+		{"not a scalar", f.SetScalar(0, "x", "metadata")},
+		{"flow style", f.SetScalar(0, "x", "metadata", "labels", "team")},
+		{"block scalar", f.SetScalar(0, "echo bye", "data", "script")},
+		{"not a YAML object", f.SetScalar(1, "x", "name")},
+		{"no document", f.SetScalar(2, "x", "name")},
+	}
+	for _, tc := range testCases {
+		assert.Error(t, tc.err, "expected an error for %s", tc.name)
+	}
+
+	_, err = yamledit.Parse([]byte("metadata:\n  name: [\n"))
+	assert.Error(t, err, "expected an error for invalid YAML")
+}
+
+// all returns the first error
+func all(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}